@@ -17,21 +17,28 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	goruntime "runtime"
+	"sort"
 	"strings"
 	"sync"
+	"text/tabwriter"
 	"time"
 
 	"github.com/trickstercache/trickster/v2/cmd/trickster/config"
 	ro "github.com/trickstercache/trickster/v2/cmd/trickster/config/reload/options"
+	"github.com/trickstercache/trickster/v2/pkg/backends"
 	"github.com/trickstercache/trickster/v2/pkg/backends/alb"
 	"github.com/trickstercache/trickster/v2/pkg/backends/healthcheck"
 	"github.com/trickstercache/trickster/v2/pkg/cache"
 	"github.com/trickstercache/trickster/v2/pkg/cache/memory"
+	co "github.com/trickstercache/trickster/v2/pkg/cache/options"
 	"github.com/trickstercache/trickster/v2/pkg/cache/providers"
 	"github.com/trickstercache/trickster/v2/pkg/cache/registration"
 	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
@@ -46,6 +53,10 @@ import (
 var cfgLock = &sync.Mutex{}
 var hc healthcheck.HealthChecker
 
+// clients are the Backends of the currently-applied config, so that the unchanged
+// ones can be kept when the config is reloaded
+var clients backends.Backends
+
 func runConfig(oldConf *config.Config, wg *sync.WaitGroup, logger *tl.Logger,
 	oldCaches map[string]cache.Cache, args []string, errorFunc func()) error {
 
@@ -66,6 +77,12 @@ func runConfig(oldConf *config.Config, wg *sync.WaitGroup, logger *tl.Logger,
 
 	// load the config
 	conf, flags, err := config.Load(runtime.ApplicationName, runtime.ApplicationVersion, sargs)
+	if flags != nil && flags.ValidateConfig && !flags.PrintVersion {
+		return runValidation(conf, err, flags.StrictValidation, errorFunc)
+	}
+	if flags != nil && flags.TestConfig && !flags.PrintVersion {
+		return runConfigTest(conf, err, os.Stdout, errorFunc)
+	}
 	if err != nil {
 		fmt.Println("\nERROR: Could not load configuration:", err.Error())
 		if flags != nil && !flags.ValidateConfig {
@@ -86,10 +103,6 @@ func runConfig(oldConf *config.Config, wg *sync.WaitGroup, logger *tl.Logger,
 		handleStartupIssue("ERROR: Could not load configuration: "+err.Error(),
 			nil, nil, errorFunc)
 	}
-	if flags.ValidateConfig {
-		fmt.Println("Trickster configuration validation succeeded.")
-		return nil
-	}
 
 	return applyConfig(conf, oldConf, wg, logger, oldCaches, args, errorFunc)
 
@@ -133,7 +146,15 @@ func applyConfig(conf, oldConf *config.Config, wg *sync.WaitGroup, logger *tl.Lo
 	var caches = applyCachingConfig(conf, oldConf, logger, oldCaches)
 	rh := handlers.ReloadHandleFunc(runConfig, conf, wg, logger, caches, args)
 
-	o, err := routing.RegisterProxyRoutes(conf, r, mr, caches, tracers, logger, false)
+	var o backends.Backends
+	if oldConf != nil {
+		// backends that are unchanged since the previous config are kept, along with
+		// their clients, rather than being constructed anew
+		o, err = routing.ReloadProxyRoutes(conf, r, mr, caches, tracers,
+			unchangedBackends(conf, oldConf, clients), logger)
+	} else {
+		o, err = routing.RegisterProxyRoutes(conf, r, mr, caches, tracers, logger, false)
+	}
 	if err != nil {
 		handleStartupIssue("route registration failed", tl.Pairs{"detail": err.Error()},
 			logger, errorFunc)
@@ -149,13 +170,17 @@ func applyConfig(conf, oldConf *config.Config, wg *sync.WaitGroup, logger *tl.Lo
 	if err != nil {
 		return err
 	}
+	clients = o
 	alb.StartALBPools(o, hc.Statuses())
 	routing.RegisterDefaultBackendRoutes(r, o, logger, tracers)
-	routing.RegisterHealthHandler(mr, conf.Main.HealthHandlerPath, hc)
+	routing.RegisterHealthHandler(mr, conf.Main.HealthHandlerPath, hc, o, caches)
 	applyListenerConfigs(conf, oldConf, r, http.HandlerFunc(rh), mr, logger, tracers, o)
 
 	metrics.LastReloadSuccessfulTimestamp.Set(float64(time.Now().Unix()))
 	metrics.LastReloadSuccessful.Set(1)
+	if oldConf != nil {
+		logReloadResult(conf, oldConf, logger)
+	}
 	// add Config Reload HUP Signal Monitor
 	if oldConf != nil && oldConf.Resources != nil {
 		oldConf.Resources.QuitChan <- true // this signals the old hup monitor goroutine to exit
@@ -165,6 +190,32 @@ func applyConfig(conf, oldConf *config.Config, wg *sync.WaitGroup, logger *tl.Lo
 	return nil
 }
 
+// unchangedBackends returns the Backends of the previous config that are unchanged in conf
+func unchangedBackends(conf, oldConf *config.Config, prev backends.Backends) backends.Backends {
+	bd := conf.BackendsDiff(oldConf)
+	unchanged := make(backends.Backends, len(prev))
+	for k, b := range prev {
+		if _, ok := conf.Backends[k]; ok && !bd.Modified(k) {
+			unchanged[k] = b
+		}
+	}
+	return unchanged
+}
+
+func logReloadResult(conf, oldConf *config.Config, logger *tl.Logger) {
+	bd := conf.BackendsDiff(oldConf)
+	cd := conf.CachesDiff(oldConf)
+	tl.Info(logger, "configuration reloaded", tl.Pairs{
+		"backendsAdded":   strings.Join(bd.Added, ","),
+		"backendsRemoved": strings.Join(bd.Removed, ","),
+		"backendsChanged": strings.Join(bd.Changed, ","),
+		"cachesAdded":     strings.Join(cd.Added, ","),
+		"cachesRemoved":   strings.Join(cd.Removed, ","),
+		"cachesChanged":   strings.Join(cd.Changed, ","),
+		"warnings":        len(conf.LoaderWarnings),
+	})
+}
+
 func applyLoggingConfig(c, o *config.Config, oldLog *tl.Logger) *tl.Logger {
 
 	if c == nil || c.Logging == nil {
@@ -212,20 +263,30 @@ func applyCachingConfig(c, oc *config.Config, logger *tl.Logger,
 
 	if oc == nil || oldCaches == nil {
 		for k, v := range c.Caches {
+			if v.ProviderID == providers.Tiered {
+				continue
+			}
 			caches[k] = registration.NewCache(k, v, logger)
 		}
+		registration.AddTieredCaches(c.Caches, caches, logger)
 		return caches
 	}
 
+	cd := c.CachesDiff(oc)
 	for k, v := range c.Caches {
 
+		// tiered caches are handled below, once their tiers are in place
+		if v.ProviderID == providers.Tiered {
+			continue
+		}
+
 		if w, ok := oldCaches[k]; ok {
 
 			ocfg := w.Configuration()
 
 			// if a cache is in both the old and new config, and unchanged, pass the
 			// pre-existing object instead of making a new one
-			if v.Equal(ocfg) {
+			if !cd.Modified(k) {
 				caches[k] = w
 				continue
 			}
@@ -254,6 +315,22 @@ func applyCachingConfig(c, oc *config.Config, logger *tl.Logger,
 		// the newly-named cache is not in the old config or couldn't be reused, so make it anew
 		caches[k] = registration.NewCache(k, v, logger)
 	}
+
+	// an unchanged tiered cache is kept as long as both of its tiers were kept as well
+	tcs := make(map[string]*co.Options)
+	for k, v := range c.Caches {
+		if v.ProviderID != providers.Tiered {
+			continue
+		}
+		if w, ok := oldCaches[k]; ok && !cd.Modified(k) && v.Tiered != nil &&
+			caches[v.Tiered.L1CacheName] == oldCaches[v.Tiered.L1CacheName] &&
+			caches[v.Tiered.L2CacheName] == oldCaches[v.Tiered.L2CacheName] {
+			caches[k] = w
+			continue
+		}
+		tcs[k] = v
+	}
+	registration.AddTieredCaches(tcs, caches, logger)
 	return caches
 }
 
@@ -289,6 +366,7 @@ func delayedLogCloser(logger *tl.Logger, delay time.Duration) {
 
 func handleStartupIssue(event string, detail tl.Pairs, logger *tl.Logger, errorFunc func()) {
 	metrics.LastReloadSuccessful.Set(0)
+	metrics.ReloadFailures.Inc()
 	if event != "" {
 		if logger != nil {
 			if errorFunc != nil {
@@ -305,11 +383,163 @@ func handleStartupIssue(event string, detail tl.Pairs, logger *tl.Logger, errorF
 	}
 }
 
-func validateConfig(conf *config.Config) error {
+// errValidationWarnings is returned by runValidation in strict mode when the
+// configuration is otherwise valid but produced loader warnings
+var errValidationWarnings = errors.New("configuration produced warnings")
 
-	for _, w := range conf.LoaderWarnings {
-		fmt.Println(w)
+// validationReport is the structured result printed by runValidation
+type validationReport struct {
+	Valid    bool     `json:"valid"`
+	Errors   []string `json:"errors"`
+	Warnings []string `json:"warnings"`
+}
+
+// runValidation validates the loaded configuration without opening listeners,
+// connecting to caches or starting the proxy. It prints the outcome as a JSON
+// validationReport, and calls errorFunc if the configuration is invalid, or if
+// strict is true and there are any warnings
+func runValidation(conf *config.Config, loadErr error, strict bool,
+	errorFunc func()) error {
+	vr := &validationReport{Errors: []string{}, Warnings: []string{}}
+	err := loadErr
+	if err == nil {
+		vr.Warnings = append(vr.Warnings, conf.LoaderWarnings...)
+		err = validateConfig(conf)
 	}
+	if err != nil {
+		vr.Errors = append(vr.Errors, err.Error())
+	} else if strict && len(vr.Warnings) > 0 {
+		err = errValidationWarnings
+	}
+	vr.Valid = err == nil
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	enc.Encode(vr)
+	if err != nil && errorFunc != nil {
+		errorFunc()
+	}
+	return err
+}
+
+// errConfigTestFailed is returned by runConfigTest when any origin or cache
+// could not be reached
+var errConfigTestFailed = errors.New("one or more configuration checks failed")
+
+// configCheck is the outcome of checking that an origin or cache is reachable
+type configCheck struct {
+	kind string
+	name string
+	err  error
+}
+
+// runConfigTest validates the loaded configuration, and then probes the health check
+// of each backend's origin and round-trips a value through each cache, without
+// opening any listeners or starting the proxy. It prints a table of the outcomes
+// to w, and calls errorFunc if the configuration is invalid or any check failed
+func runConfigTest(conf *config.Config, loadErr error, w io.Writer,
+	errorFunc func()) error {
+	err := loadErr
+	if err == nil {
+		err = validateConfig(conf)
+	}
+	if err != nil {
+		fmt.Fprintln(w, "ERROR: Could not load configuration:", err.Error())
+		if errorFunc != nil {
+			errorFunc()
+		}
+		return err
+	}
+	checks := checkConfig(conf)
+	sort.Slice(checks, func(i, j int) bool {
+		if checks[i].kind != checks[j].kind {
+			return checks[i].kind < checks[j].kind
+		}
+		return checks[i].name < checks[j].name
+	})
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TYPE\tNAME\tRESULT\tDETAIL")
+	for _, c := range checks {
+		if c.err != nil {
+			err = errConfigTestFailed
+			fmt.Fprintf(tw, "%s\t%s\tfail\t%s\n", c.kind, c.name, c.err.Error())
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\tpass\t\n", c.kind, c.name)
+	}
+	tw.Flush()
+	if err != nil && errorFunc != nil {
+		errorFunc()
+	}
+	return err
+}
+
+// checkConfig connects to each configured cache and stores, retrieves and removes
+// a test value, and probes each backend's origin once
+func checkConfig(conf *config.Config) []configCheck {
+	// only errors are logged, so they do not interleave with the results table
+	logger := tl.ConsoleLogger("error")
+	checks := make([]configCheck, 0, len(conf.Caches)+len(conf.Backends))
+
+	caches := make(map[string]cache.Cache, len(conf.Caches))
+	connected := make(map[string]cache.Cache, len(conf.Caches))
+	for k, v := range conf.Caches {
+		if v.ProviderID == providers.Tiered {
+			continue
+		}
+		c, err := registration.ConnectCache(k, v, logger)
+		caches[k] = c
+		if err != nil {
+			checks = append(checks, configCheck{kind: "cache", name: k,
+				err: fmt.Errorf("connect failed: %w", err)})
+			continue
+		}
+		connected[k] = c
+	}
+	defer registration.CloseCaches(connected)
+	registration.AddTieredCaches(conf.Caches, caches, logger)
+
+	// a tiered cache is only checked when both of its tiers are connected
+	checked := make(map[string]cache.Cache, len(caches))
+	for k, c := range connected {
+		checked[k] = c
+	}
+	for k, v := range conf.Caches {
+		if v.ProviderID != providers.Tiered {
+			continue
+		}
+		c, ok := caches[k]
+		switch {
+		case !ok:
+			checks = append(checks, configCheck{kind: "cache", name: k,
+				err: errors.New("tiered cache could not be created")})
+		case connected[v.Tiered.L1CacheName] == nil || connected[v.Tiered.L2CacheName] == nil:
+			checks = append(checks, configCheck{kind: "cache", name: k,
+				err: errors.New("one or more tiers are not connected")})
+		default:
+			checked[k] = c
+		}
+	}
+	for k, c := range checked {
+		checks = append(checks, configCheck{kind: "cache", name: k, err: cache.Check(c)})
+	}
+
+	tracers, err := tr.RegisterAll(conf, logger, true)
+	if err != nil {
+		return append(checks, configCheck{kind: "backend", name: "*", err: err})
+	}
+	b, err := routing.RegisterProxyRoutes(conf, router.NewRouter(), http.NewServeMux(),
+		caches, tracers, logger, false)
+	if err != nil {
+		return append(checks, configCheck{kind: "backend", name: "*", err: err})
+	}
+	for k, err := range b.CheckHealth(context.Background()) {
+		checks = append(checks, configCheck{kind: "backend", name: k, err: err})
+	}
+	return checks
+}
+
+func validateConfig(conf *config.Config) error {
 
 	var caches = make(map[string]cache.Cache)
 	for k := range conf.Caches {
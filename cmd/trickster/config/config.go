@@ -20,7 +20,9 @@
 package config
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
 	"sync"
 	"time"
@@ -34,6 +36,7 @@ import (
 	lo "github.com/trickstercache/trickster/v2/pkg/observability/logging/options"
 	mo "github.com/trickstercache/trickster/v2/pkg/observability/metrics/options"
 	tracing "github.com/trickstercache/trickster/v2/pkg/observability/tracing/options"
+	po "github.com/trickstercache/trickster/v2/pkg/proxy/paths/options"
 	rewriter "github.com/trickstercache/trickster/v2/pkg/proxy/request/rewriter"
 	rwopts "github.com/trickstercache/trickster/v2/pkg/proxy/request/rewriter/options"
 	"github.com/trickstercache/trickster/v2/pkg/util/yamlx"
@@ -59,6 +62,9 @@ type Config struct {
 	TracingConfigs map[string]*tracing.Options `yaml:"tracing,omitempty"`
 	// NegativeCacheConfigs is a map of NegativeCacheConfigs
 	NegativeCacheConfigs map[string]negative.Config `yaml:"negative_caches,omitempty"`
+	// NegativeCacheResponses is a map of replacement responses served for negative-cached
+	// entries, keyed by the name of the NegativeCacheConfig they apply to
+	NegativeCacheResponses map[string]negative.ResponseConfig `yaml:"negative_cache_responses,omitempty"`
 	// Rules is a map of the Rules
 	Rules map[string]*rule.Options `yaml:"rules,omitempty"`
 	// RequestRewriters is a map of the Rewriters
@@ -73,6 +79,9 @@ type Config struct {
 	activeCaches      map[string]interface{}
 	providedOriginURL string
 	providedProvider  string
+	backendPrints     map[string]string
+	cachePrints       map[string]string
+	referencePrint    string
 
 	LoaderWarnings []string `yaml:"-"`
 }
@@ -92,12 +101,25 @@ type MainConfig struct {
 	// PurgeKeyHandlerPath provides the base Cache Purge Key Handler path
 	PurgeKeyHandlerPath  string `yaml:"purge_key_handler_path,omitempty"`
 	PurgePathHandlerPath string `yaml:"purge_path_handler_path,omitempty"`
+	// PurgeHandlerPath provides the path to register the authenticated Cache Purge Handler
+	PurgeHandlerPath string `yaml:"purge_handler_path,omitempty"`
+	// PurgeHandlerCredentials maps the usernames permitted to use the Cache Purge Handler
+	// to their passwords. The handler rejects all requests when no credentials are set.
+	// When set, the credentials are also required by the Config Handler
+	PurgeHandlerCredentials map[string]string `yaml:"purge_handler_credentials,omitempty"`
+	// WarmHandlerPath provides the path to register the Cache Warm Handler
+	WarmHandlerPath string `yaml:"warm_handler_path,omitempty"`
+	// InspectHandlerPath provides the path to register the Cache Inspect Handler
+	InspectHandlerPath string `yaml:"inspect_handler_path,omitempty"`
 	// PprofServer provides the name of the http listener that will host the pprof debugging routes
 	// Options are: "metrics", "reload", "both", or "off"; default is both
 	PprofServer string `yaml:"pprof_server,omitempty"`
 	// ServerName represents the server name that is conveyed in Via headers to upstream origins
 	// defaults to os.Hostname
 	ServerName string `yaml:"server_name,omitempty"`
+	// ShutdownDrainTimeoutMS is how long Trickster waits, on SIGTERM or SIGINT, for in-flight
+	// requests to complete before forcibly closing its listeners and exiting
+	ShutdownDrainTimeoutMS int `yaml:"shutdown_drain_timeout_ms,omitempty"`
 
 	// ReloaderLock is used to lock the config for reloading
 	ReloaderLock sync.Mutex `yaml:"-"`
@@ -129,14 +151,18 @@ func NewConfig() *Config {
 		},
 		Logging: lo.New(),
 		Main: &MainConfig{
-			ConfigHandlerPath:    DefaultConfigHandlerPath,
-			PingHandlerPath:      DefaultPingHandlerPath,
-			ReloadHandlerPath:    reload.DefaultReloadHandlerPath,
-			HealthHandlerPath:    DefaultHealthHandlerPath,
-			PurgeKeyHandlerPath:  DefaultPurgeKeyHandlerPath,
-			PurgePathHandlerPath: DefaultPurgePathHandlerPath,
-			PprofServer:          DefaultPprofServerName,
-			ServerName:           hn,
+			ConfigHandlerPath:      DefaultConfigHandlerPath,
+			PingHandlerPath:        DefaultPingHandlerPath,
+			ReloadHandlerPath:      reload.DefaultReloadHandlerPath,
+			HealthHandlerPath:      DefaultHealthHandlerPath,
+			PurgeKeyHandlerPath:    DefaultPurgeKeyHandlerPath,
+			PurgePathHandlerPath:   DefaultPurgePathHandlerPath,
+			PurgeHandlerPath:       DefaultPurgeHandlerPath,
+			WarmHandlerPath:        DefaultWarmHandlerPath,
+			InspectHandlerPath:     DefaultInspectHandlerPath,
+			PprofServer:            DefaultPprofServerName,
+			ServerName:             hn,
+			ShutdownDrainTimeoutMS: DefaultShutdownDrainTimeoutMS,
 		},
 		Metrics: mo.New(),
 		Backends: map[string]*bo.Options{
@@ -157,14 +183,25 @@ func NewConfig() *Config {
 	}
 }
 
-// loadFile loads application configuration from a YAML-formatted file.
+// loadFile loads application configuration from a YAML-formatted file, overlaid
+// with any Environment Variables under envConfigPrefix
 func (c *Config) loadFile(flags *Flags) error {
 	b, err := os.ReadFile(flags.ConfigPath)
+	if err != nil {
+		if flags.customPath || len(envConfigVars()) == 0 {
+			c.setDefaults(yamlx.KeyLookup{})
+			return err
+		}
+		// no config file is present, so the configuration is provided by env vars
+		flags = &Flags{}
+	}
+	yml, lw, err := overlayEnvConfig(string(b))
 	if err != nil {
 		c.setDefaults(yamlx.KeyLookup{})
 		return err
 	}
-	return c.loadYAMLConfig(string(b), flags)
+	c.LoaderWarnings = append(c.LoaderWarnings, lw...)
+	return c.loadYAMLConfig(yml, flags)
 }
 
 // loadYAMLConfig loads application configuration from a YAML-formatted byte slice.
@@ -209,6 +246,12 @@ func (c *Config) setDefaults(metadata yamlx.KeyLookup) error {
 		return err
 	}
 
+	if c.Logging != nil {
+		if err = c.Logging.Validate(); err != nil {
+			return err
+		}
+	}
+
 	if c.RequestRewriters != nil {
 		if c.CompiledRewriters, err = rewriter.ProcessConfigs(c.RequestRewriters); err != nil {
 			return err
@@ -222,6 +265,8 @@ func (c *Config) setDefaults(metadata yamlx.KeyLookup) error {
 			return err
 		}
 		c.Backends[k] = w
+		c.LoaderWarnings = append(c.LoaderWarnings, po.Lookup(v.Paths).CacheKeyWarnings(k)...)
+		c.LoaderWarnings = append(c.LoaderWarnings, w.CompileHeaderInjections()...)
 	}
 
 	tracing.ProcessTracingOptions(c.TracingConfigs, metadata)
@@ -247,6 +292,21 @@ func (c *Config) setDefaults(metadata yamlx.KeyLookup) error {
 	if serveTLS {
 		c.Frontend.ServeTLS = true
 	}
+
+	// frontend certificates are loaded here so that invalid or missing files fail the
+	// config load, rather than the later startup of the tls listener
+	if len(c.Frontend.TLSCertificates) > 0 {
+		if _, err = c.Frontend.LoadTLSCertificates(); err != nil {
+			return err
+		}
+		c.Frontend.ServeTLS = true
+	}
+	if _, err = c.Frontend.LoadClientCAs(); err != nil {
+		return err
+	}
+	if err = c.Frontend.ValidateTimeouts(); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -278,13 +338,26 @@ func (c *Config) Clone() *Config {
 	nc.Main.HealthHandlerPath = c.Main.HealthHandlerPath
 	nc.Main.PurgeKeyHandlerPath = c.Main.PurgeKeyHandlerPath
 	nc.Main.PurgePathHandlerPath = c.Main.PurgePathHandlerPath
+	nc.Main.PurgeHandlerPath = c.Main.PurgeHandlerPath
+	if c.Main.PurgeHandlerCredentials != nil {
+		nc.Main.PurgeHandlerCredentials = make(map[string]string, len(c.Main.PurgeHandlerCredentials))
+		for k, v := range c.Main.PurgeHandlerCredentials {
+			nc.Main.PurgeHandlerCredentials[k] = v
+		}
+	}
+	nc.Main.WarmHandlerPath = c.Main.WarmHandlerPath
+	nc.Main.InspectHandlerPath = c.Main.InspectHandlerPath
 	nc.Main.PprofServer = c.Main.PprofServer
 	nc.Main.ServerName = c.Main.ServerName
+	nc.Main.ShutdownDrainTimeoutMS = c.Main.ShutdownDrainTimeoutMS
 
 	nc.Main.configFilePath = c.Main.configFilePath
 	nc.Main.configLastModified = c.Main.configLastModified
 	nc.Main.configRateLimitTime = c.Main.configRateLimitTime
 
+	nc.backendPrints = c.backendPrints
+	nc.cachePrints = c.cachePrints
+
 	nc.Metrics.ListenAddress = c.Metrics.ListenAddress
 	nc.Metrics.ListenPort = c.Metrics.ListenPort
 
@@ -312,6 +385,13 @@ func (c *Config) Clone() *Config {
 		nc.NegativeCacheConfigs[k] = v.Clone()
 	}
 
+	if c.NegativeCacheResponses != nil {
+		nc.NegativeCacheResponses = make(map[string]negative.ResponseConfig, len(c.NegativeCacheResponses))
+		for k, v := range c.NegativeCacheResponses {
+			nc.NegativeCacheResponses[k] = v.Clone()
+		}
+	}
+
 	for k, v := range c.TracingConfigs {
 		nc.TracingConfigs[k] = v.Clone()
 	}
@@ -358,26 +438,115 @@ func (c *Config) IsStale() bool {
 }
 
 func (c *Config) String() string {
+	bytes, err := yaml.Marshal(c.Redacted())
+	if err == nil {
+		return string(bytes)
+	}
+
+	return ""
+
+}
+
+// redactedValue replaces secrets in the output of Redacted
+const redactedValue = "*****"
+
+// Redacted returns a copy of the Config that is safe to display, with passwords,
+// credentials and private key paths masked
+func (c *Config) Redacted() *Config {
 	cp := c.Clone()
 
 	for k, o := range cp.Backends {
 		cp.Backends[k] = o.CloneYAMLSafe()
+		// strip TLS private key paths
+		if tc := cp.Backends[k].TLS; tc != nil {
+			if tc.PrivateKeyPath != "" {
+				tc.PrivateKeyPath = redactedValue
+			}
+			if tc.ClientKeyPath != "" {
+				tc.ClientKeyPath = redactedValue
+			}
+		}
+	}
+
+	if cp.Frontend != nil {
+		for _, tc := range cp.Frontend.TLSCertificates {
+			if tc.PrivateKeyPath != "" {
+				tc.PrivateKeyPath = redactedValue
+			}
+		}
 	}
 
 	// strip Redis password
 	for k, v := range cp.Caches {
 		if v != nil && cp.Caches[k].Redis.Password != "" {
-			cp.Caches[k].Redis.Password = "*****"
+			cp.Caches[k].Redis.Password = redactedValue
 		}
 	}
 
-	bytes, err := yaml.Marshal(cp)
-	if err == nil {
-		return string(bytes)
+	// strip tracing collector passwords, and OTLP client key paths and export headers,
+	// which may carry auth tokens
+	for _, v := range cp.TracingConfigs {
+		if v == nil {
+			continue
+		}
+		if v.CollectorPass != "" {
+			v.CollectorPass = redactedValue
+		}
+		if v.OTLPOptions == nil {
+			continue
+		}
+		if v.OTLPOptions.ClientKeyPath != "" {
+			v.OTLPOptions.ClientKeyPath = redactedValue
+		}
+		for h := range v.OTLPOptions.Headers {
+			v.OTLPOptions.Headers[h] = redactedValue
+		}
 	}
 
-	return ""
+	// strip Purge Handler passwords
+	for k := range cp.Main.PurgeHandlerCredentials {
+		cp.Main.PurgeHandlerCredentials[k] = redactedValue
+	}
+
+	return cp
+}
+
+// JSON returns the Redacted Config as JSON, using the same key names as the YAML
+// configuration, along with any warnings from loading the Config
+func (c *Config) JSON() ([]byte, error) {
+	b, err := yaml.Marshal(c.Redacted())
+	if err != nil {
+		return nil, err
+	}
+	doc := make(map[interface{}]interface{})
+	if err = yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	out := jsonCompatible(doc).(map[string]interface{})
+	lw := c.LoaderWarnings
+	if lw == nil {
+		lw = []string{}
+	}
+	out["loader_warnings"] = lw
+	return json.Marshal(out)
+}
 
+// jsonCompatible converts the maps in a YAML document, which may have
+// non-string keys, to maps with string keys that can be encoded to JSON
+func jsonCompatible(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, v2 := range t {
+			m[fmt.Sprint(k)] = jsonCompatible(v2)
+		}
+		return m
+	case []interface{}:
+		for i := range t {
+			t[i] = jsonCompatible(t[i])
+		}
+	}
+	return v
 }
 
 // ConfigFilePath returns the file path from which this configuration is based
@@ -25,6 +25,7 @@ import (
 	bo "github.com/trickstercache/trickster/v2/pkg/backends/options"
 	rule "github.com/trickstercache/trickster/v2/pkg/backends/rule/options"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/jwt"
 	po "github.com/trickstercache/trickster/v2/pkg/proxy/paths/options"
 	rwo "github.com/trickstercache/trickster/v2/pkg/proxy/request/rewriter/options"
 	to "github.com/trickstercache/trickster/v2/pkg/proxy/tls/options"
@@ -93,6 +94,61 @@ func TestString(t *testing.T) {
 	}
 }
 
+func TestRedacted(t *testing.T) {
+	c1 := NewConfig()
+	c1.Backends["default"].TLS.PrivateKeyPath = "/path/to/key.pem"
+	c1.Backends["default"].TLS.ClientKeyPath = "/path/to/client.key.pem"
+	c1.Main.PurgeHandlerCredentials = map[string]string{"admin": "plaintext-password"}
+	c1.TracingConfigs["default"].CollectorPass = "collector-password"
+	c1.Backends["default"].Paths["root"] = &po.Options{Path: "/",
+		CacheKeyJWT: &jwt.Options{Claim: "tenant", Key: "hmac-secret"}}
+
+	r := c1.Redacted()
+	if r.Backends["default"].TLS.PrivateKeyPath != "*****" {
+		t.Errorf("expected %s got %s", "*****", r.Backends["default"].TLS.PrivateKeyPath)
+	}
+	if r.Backends["default"].TLS.ClientKeyPath != "*****" {
+		t.Errorf("expected %s got %s", "*****", r.Backends["default"].TLS.ClientKeyPath)
+	}
+	if r.Main.PurgeHandlerCredentials["admin"] != "*****" {
+		t.Errorf("expected %s got %s", "*****", r.Main.PurgeHandlerCredentials["admin"])
+	}
+	if r.TracingConfigs["default"].CollectorPass != "*****" {
+		t.Errorf("expected %s got %s", "*****", r.TracingConfigs["default"].CollectorPass)
+	}
+	if k := r.Backends["default"].Paths["root"].CacheKeyJWT.Key; k != "*****" {
+		t.Errorf("expected %s got %s", "*****", k)
+	}
+	// the subject config must not be modified
+	if c1.Backends["default"].TLS.PrivateKeyPath != "/path/to/key.pem" {
+		t.Errorf("expected %s got %s", "/path/to/key.pem", c1.Backends["default"].TLS.PrivateKeyPath)
+	}
+	if c1.Main.PurgeHandlerCredentials["admin"] != "plaintext-password" {
+		t.Error("expected unmodified purge handler credentials")
+	}
+	if c1.TracingConfigs["default"].CollectorPass != "collector-password" {
+		t.Error("expected unmodified tracing collector password")
+	}
+	if c1.Backends["default"].Paths["root"].CacheKeyJWT.Key != "hmac-secret" {
+		t.Error("expected unmodified cache key jwt key")
+	}
+}
+
+func TestJSON(t *testing.T) {
+	c1 := NewConfig()
+	c1.LoaderWarnings = []string{"test warning"}
+	b, err := c1.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"loader_warnings":["test warning"]`) {
+		t.Errorf("missing loader warnings: %s", string(b))
+	}
+	if !strings.Contains(string(b), `"backends":{"default":`) {
+		t.Errorf("missing backends: %s", string(b))
+	}
+}
+
 func TestCloneBackendOptions(t *testing.T) {
 
 	o := bo.New()
@@ -28,6 +28,18 @@ const (
 	// DefaultPurgePathHandlerPath defines the default path for the Cache Purge (by Path) Handler
 	// Requires ?backend={backend}&path={path}
 	DefaultPurgePathHandlerPath = "/trickster/purge/path"
+	// DefaultPurgeHandlerPath defines the default path for the authenticated Cache Purge Handler
+	// Requires backend={backend}, plus either key={key} or path={path} and the request's query parameters
+	DefaultPurgeHandlerPath = "/trickster/purge"
+	// DefaultWarmHandlerPath defines the default path for the Cache Warm Handler
+	// Requires ?backend={backend}&path={path}, plus the backend's query parameters
+	DefaultWarmHandlerPath = "/trickster/warm"
+	// DefaultInspectHandlerPath defines the default path for the Cache Inspect Handler
+	// Requires ?backend={backend}, plus either key={key} or path={path} and the request's query parameters
+	DefaultInspectHandlerPath = "/trickster/inspect"
 	// DefaultPprofServerName defines the default Pprof Server Name
 	DefaultPprofServerName = "both"
+	// DefaultShutdownDrainTimeoutMS is the default time allowed for in-flight requests to
+	// complete during a graceful shutdown
+	DefaultShutdownDrainTimeoutMS = 30000
 )
@@ -0,0 +1,116 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"sort"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Diff describes the differences in a named configuration map between two Configs
+type Diff struct {
+	Added   []string
+	Removed []string
+	Changed []string
+}
+
+// IsEmpty returns true if the Diff contains no differences
+func (d *Diff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Modified returns true if the named entry was added or changed
+func (d *Diff) Modified(name string) bool {
+	for _, l := range [][]string{d.Added, d.Changed} {
+		if i := sort.SearchStrings(l, name); i < len(l) && l[i] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// fingerprint captures the as-loaded state of the Backends and Caches so that they
+// can be compared against a later load; route registration mutates the Backend
+// Options in place, so they can't be compared directly once the config is applied
+func (c *Config) fingerprint() {
+	c.backendPrints = make(map[string]string, len(c.Backends))
+	for k, o := range c.Backends {
+		b, _ := yaml.Marshal(o)
+		c.backendPrints[k] = string(b)
+	}
+	c.cachePrints = make(map[string]string, len(c.Caches))
+	for k, o := range c.Caches {
+		b, _ := yaml.Marshal(o)
+		c.cachePrints[k] = string(b)
+	}
+	// the named sections that are resolved into the Backend Options at load
+	b, _ := yaml.Marshal(map[string]interface{}{
+		"request_rewriters":        c.RequestRewriters,
+		"negative_caches":          c.NegativeCacheConfigs,
+		"negative_cache_responses": c.NegativeCacheResponses,
+	})
+	c.referencePrint = string(b)
+}
+
+// BackendsDiff returns the Backends that were added, removed or changed in c, relative to oc.
+// When the request rewriters or negative caches, which Backends reference by name, have
+// changed, every Backend in both configs is changed
+func (c *Config) BackendsDiff(oc *Config) *Diff {
+	if oc == nil {
+		return diffPrints(nil, c.backendPrints)
+	}
+	d := diffPrints(oc.backendPrints, c.backendPrints)
+	if oc.referencePrint != c.referencePrint {
+		d.Changed = d.Changed[:0]
+		for k := range c.backendPrints {
+			if _, ok := oc.backendPrints[k]; ok {
+				d.Changed = append(d.Changed, k)
+			}
+		}
+		sort.Strings(d.Changed)
+	}
+	return d
+}
+
+// CachesDiff returns the Caches that were added, removed or changed in c, relative to oc
+func (c *Config) CachesDiff(oc *Config) *Diff {
+	if oc == nil {
+		return diffPrints(nil, c.cachePrints)
+	}
+	return diffPrints(oc.cachePrints, c.cachePrints)
+}
+
+func diffPrints(old, new map[string]string) *Diff {
+	d := &Diff{}
+	for k, v := range new {
+		if ov, ok := old[k]; !ok {
+			d.Added = append(d.Added, k)
+		} else if ov != v {
+			d.Changed = append(d.Changed, k)
+		}
+	}
+	for k := range old {
+		if _, ok := new[k]; !ok {
+			d.Removed = append(d.Removed, k)
+		}
+	}
+	sort.Strings(d.Added)
+	sort.Strings(d.Removed)
+	sort.Strings(d.Changed)
+	return d
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBackendsDiff(t *testing.T) {
+
+	c1, _, err := Load("trickster-test", "test",
+		[]string{"-provider", "reverseproxycache", "-origin-url", "http://1/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := c1.BackendsDiff(nil)
+	if len(d.Added) != 1 || d.Added[0] != "default" {
+		t.Errorf("expected %s got %s", "default", strings.Join(d.Added, ","))
+	}
+
+	c2, _, err := Load("trickster-test", "test",
+		[]string{"-provider", "reverseproxycache", "-origin-url", "http://1/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d = c2.BackendsDiff(c1)
+	if !d.IsEmpty() {
+		t.Errorf("expected empty diff got %v", d)
+	}
+
+	d = c2.CachesDiff(c1)
+	if !d.IsEmpty() {
+		t.Errorf("expected empty diff got %v", d)
+	}
+
+	c3, _, err := Load("trickster-test", "test",
+		[]string{"-provider", "reverseproxycache", "-origin-url", "http://2/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d = c3.BackendsDiff(c1)
+	if len(d.Changed) != 1 || d.Changed[0] != "default" {
+		t.Errorf("expected %s got %s", "default", strings.Join(d.Changed, ","))
+	}
+
+	// a change to a referenced section changes every backend
+	c2.referencePrint = "changed"
+	d = c2.BackendsDiff(c1)
+	if len(d.Changed) != 1 || d.Changed[0] != "default" {
+		t.Errorf("expected %s got %s", "default", strings.Join(d.Changed, ","))
+	}
+
+}
+
+func TestDiffPrints(t *testing.T) {
+
+	d := diffPrints(map[string]string{"a": "1", "b": "2", "c": "3"},
+		map[string]string{"b": "2", "c": "4", "d": "5"})
+
+	if strings.Join(d.Added, ",") != "d" {
+		t.Errorf("expected %s got %s", "d", strings.Join(d.Added, ","))
+	}
+	if strings.Join(d.Removed, ",") != "a" {
+		t.Errorf("expected %s got %s", "a", strings.Join(d.Removed, ","))
+	}
+	if strings.Join(d.Changed, ",") != "c" {
+		t.Errorf("expected %s got %s", "c", strings.Join(d.Changed, ","))
+	}
+	if d.IsEmpty() {
+		t.Error("expected non-empty diff")
+	}
+	if !d.Modified("c") || !d.Modified("d") || d.Modified("a") || d.Modified("b") {
+		t.Error("expected only c and d to be modified")
+	}
+}
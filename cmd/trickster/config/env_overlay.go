@@ -0,0 +1,254 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// envConfigPrefix is the prefix of Environment Variables that overlay values onto
+// the configuration file. The remainder of the name is the upper-cased, underscore-
+// joined path of yaml keys to the value, e.g., TRICKSTER_FRONTEND_LISTEN_PORT or
+// TRICKSTER_BACKENDS_DEFAULT_ORIGIN_URL
+const envConfigPrefix = "TRICKSTER_"
+
+var errUnknownEnvConfig = errors.New("does not match any configuration setting")
+
+func errInvalidEnvConfigValue(val string) error {
+	return fmt.Errorf("has an invalid value: %s", val)
+}
+
+// envConfigVars returns the name=value pairs of all Environment Variables
+// under envConfigPrefix, sorted by name
+func envConfigVars() []string {
+	var vars []string
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, envConfigPrefix) {
+			vars = append(vars, kv)
+		}
+	}
+	sort.Strings(vars)
+	return vars
+}
+
+// overlayEnvConfig sets the value of each envConfigPrefix'd Environment Variable
+// onto the provided YAML document, taking precedence over any value in the document.
+// Setting names are resolved by reflecting over the yaml tags of the Config struct.
+// The resulting YAML document is returned with a warning for each variable that
+// could not be applied
+func overlayEnvConfig(yml string) (string, []string, error) {
+	vars := envConfigVars()
+	if len(vars) == 0 {
+		return yml, nil, nil
+	}
+	doc := make(map[interface{}]interface{})
+	if err := yaml.Unmarshal([]byte(yml), &doc); err != nil {
+		return yml, nil, err
+	}
+	var warnings []string
+	for _, kv := range vars {
+		i := strings.Index(kv, "=")
+		name, val := kv[:i], kv[i+1:]
+		tokens := strings.Split(strings.TrimPrefix(name, envConfigPrefix), "_")
+		ok, err := setEnvConfigValue(doc, reflect.TypeOf(Config{}), tokens, val)
+		if !ok {
+			err = errUnknownEnvConfig
+		}
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("environment variable %s %s", name, err.Error()))
+		}
+	}
+	b, err := yaml.Marshal(doc)
+	if err != nil {
+		return yml, warnings, err
+	}
+	return string(b), warnings, nil
+}
+
+// setEnvConfigValue resolves the tokens against the type t and sets the parsed
+// value into the node. It returns false if the tokens do not resolve to a setting
+func setEnvConfigValue(node map[interface{}]interface{}, t reflect.Type,
+	tokens []string, val string) (bool, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		return setEnvConfigField(node, t, tokens, val)
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return false, nil
+		}
+		// when the map's values are scalar, all remaining tokens form the map key
+		if isEnvConfigScalar(t.Elem()) {
+			v, err := parseEnvConfigValue(t.Elem(), val)
+			if err == nil {
+				node[envConfigMapKey(node, tokens)] = v
+			}
+			return true, err
+		}
+		// otherwise, the map key is the shortest series of tokens that leaves the
+		// remaining tokens resolvable against the map's value type
+		for i := 1; i < len(tokens); i++ {
+			k := envConfigMapKey(node, tokens[:i])
+			child := envConfigChild(node, k)
+			if ok, err := setEnvConfigValue(child, t.Elem(), tokens[i:], val); ok {
+				node[k] = child
+				return true, err
+			}
+		}
+	}
+	return false, nil
+}
+
+func setEnvConfigField(node map[interface{}]interface{}, t reflect.Type,
+	tokens []string, val string) (bool, error) {
+	type candidate struct {
+		name   string
+		tokens []string
+		field  reflect.StructField
+	}
+	candidates := make([]candidate, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		tag := strings.Split(f.Tag.Get("yaml"), ",")
+		if len(tag) > 1 && tag[1] == "inline" {
+			if ok, err := setEnvConfigValue(node, f.Type, tokens, val); ok {
+				return true, err
+			}
+			continue
+		}
+		if tag[0] == "" || tag[0] == "-" {
+			continue
+		}
+		ft := strings.Split(strings.ToUpper(tag[0]), "_")
+		if len(ft) > len(tokens) || strings.Join(tokens[:len(ft)], "_") != strings.Join(ft, "_") {
+			continue
+		}
+		candidates = append(candidates, candidate{name: tag[0], tokens: ft, field: f})
+	}
+	// prefer the longest matching key name, e.g., listen_port_tls over listen_port
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return len(candidates[i].tokens) > len(candidates[j].tokens)
+	})
+	for _, c := range candidates {
+		rest := tokens[len(c.tokens):]
+		if len(rest) == 0 {
+			if !isEnvConfigScalar(c.field.Type) {
+				continue
+			}
+			v, err := parseEnvConfigValue(c.field.Type, val)
+			if err == nil {
+				node[c.name] = v
+			}
+			return true, err
+		}
+		child := envConfigChild(node, c.name)
+		if ok, err := setEnvConfigValue(child, c.field.Type, rest, val); ok {
+			node[c.name] = child
+			return true, err
+		}
+	}
+	return false, nil
+}
+
+// envConfigChild returns the mapping at key k of the node, or a new mapping
+func envConfigChild(node map[interface{}]interface{}, k string) map[interface{}]interface{} {
+	if child, ok := node[k].(map[interface{}]interface{}); ok {
+		return child
+	}
+	return make(map[interface{}]interface{})
+}
+
+// envConfigMapKey returns the existing key in the node that case-insensitively
+// matches the tokens, or the lower-cased tokens if there is no such key
+func envConfigMapKey(node map[interface{}]interface{}, tokens []string) string {
+	k := strings.Join(tokens, "_")
+	for nk := range node {
+		if s, ok := nk.(string); ok && strings.EqualFold(s, k) {
+			return s
+		}
+	}
+	return strings.ToLower(k)
+}
+
+func isEnvConfigScalar(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() != reflect.Slice && isEnvConfigScalar(t.Elem())
+	}
+	return false
+}
+
+// parseEnvConfigValue parses the value into the type t. Slices are provided as
+// comma-separated lists
+func parseEnvConfigValue(t reflect.Type, val string) (interface{}, error) {
+	v, err := parseEnvConfigScalar(t, val)
+	if err != nil {
+		return nil, errInvalidEnvConfigValue(val)
+	}
+	return v, nil
+}
+
+func parseEnvConfigScalar(t reflect.Type, val string) (interface{}, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return strconv.ParseBool(val)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.ParseInt(val, 10, t.Bits())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.ParseUint(val, 10, t.Bits())
+	case reflect.Float32, reflect.Float64:
+		return strconv.ParseFloat(val, t.Bits())
+	case reflect.Slice:
+		parts := strings.Split(val, ",")
+		out := make([]interface{}, 0, len(parts))
+		for _, p := range parts {
+			if p = strings.TrimSpace(p); p == "" {
+				continue
+			}
+			v, err := parseEnvConfigScalar(t.Elem(), p)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+		}
+		return out, nil
+	}
+	return val, nil
+}
@@ -72,3 +72,86 @@ func TestLoadEnvVars(t *testing.T) {
 	os.Unsetenv(evLogLevel)
 
 }
+
+func TestOverlayEnvConfig(t *testing.T) {
+
+	t.Setenv("TRICKSTER_FRONTEND_LISTEN_PORT", "9000")
+	t.Setenv("TRICKSTER_FRONTEND_TLS_LISTEN_PORT", "not-a-port")
+	t.Setenv("TRICKSTER_BACKENDS_DEFAULT_ORIGIN_URL", "http://2.2.2.2:9090")
+	t.Setenv("TRICKSTER_BACKENDS_DEFAULT_HOSTS", "a.example.com, b.example.com")
+	t.Setenv("TRICKSTER_BACKENDS_MY_PROM_PROVIDER", "prometheus")
+	t.Setenv("TRICKSTER_BACKENDS_MY_PROM_ORIGIN_URL", "http://3.3.3.3:9090")
+	t.Setenv("TRICKSTER_NOT_A_SETTING", "1")
+
+	yml := `
+frontend:
+  listen_port: 8000
+backends:
+  default:
+    provider: rpc
+    origin_url: http://1.1.1.1:9090
+`
+	out, lw, err := overlayEnvConfig(yml)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lw) != 2 {
+		t.Errorf("expected %d warnings got %d: %v", 2, len(lw), lw)
+	}
+	for _, name := range []string{"TRICKSTER_FRONTEND_TLS_LISTEN_PORT", "TRICKSTER_NOT_A_SETTING"} {
+		var found bool
+		for _, w := range lw {
+			found = found || strings.Contains(w, name)
+		}
+		if !found {
+			t.Errorf("expected warning for %s", name)
+		}
+	}
+
+	c := NewConfig()
+	if err := c.loadYAMLConfig(out, &Flags{}); err != nil {
+		t.Fatal(err)
+	}
+	if c.Frontend.ListenPort != 9000 {
+		t.Errorf("expected %d got %d", 9000, c.Frontend.ListenPort)
+	}
+	d := c.Backends["default"]
+	if d.OriginURL != "http://2.2.2.2:9090" {
+		t.Errorf("expected %s got %s", "http://2.2.2.2:9090", d.OriginURL)
+	}
+	if d.Provider != "rpc" {
+		t.Errorf("expected %s got %s", "rpc", d.Provider)
+	}
+	if len(d.Hosts) != 2 || d.Hosts[1] != "b.example.com" {
+		t.Errorf("unexpected hosts %v", d.Hosts)
+	}
+	p, ok := c.Backends["my_prom"]
+	if !ok {
+		t.Fatal("expected backend my_prom")
+	}
+	if p.Provider != "prometheus" || p.OriginURL != "http://3.3.3.3:9090" {
+		t.Errorf("unexpected backend %s %s", p.Provider, p.OriginURL)
+	}
+}
+
+func TestLoadEnvConfigWithoutFile(t *testing.T) {
+
+	t.Setenv("TRICKSTER_BACKENDS_DEFAULT_PROVIDER", "prometheus")
+	t.Setenv("TRICKSTER_BACKENDS_DEFAULT_ORIGIN_URL", "http://1.1.1.1:9090")
+	t.Setenv("TRICKSTER_BACKENDS_DEFAULT_TIMESERIES_RETENTION_FACTOR", "2048")
+
+	conf, _, err := Load("trickster-test", "0", []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, ok := conf.Backends["default"]
+	if !ok {
+		t.Fatal("expected default backend")
+	}
+	if d.Host != "1.1.1.1:9090" {
+		t.Errorf("expected %s got %s", "1.1.1.1:9090", d.Host)
+	}
+	if d.TimeseriesRetentionFactor != 2048 {
+		t.Errorf("expected %d got %d", 2048, d.TimeseriesRetentionFactor)
+	}
+}
@@ -25,6 +25,9 @@ const (
 	cfConfig      = "config"
 	cfVersion     = "version"
 	cfValidate    = "validate-config"
+	cfValidateAlt = "validate"
+	cfStrict      = "strict"
+	cfTestConfig  = "test-config"
 	cfLogLevel    = "log-level"
 	cfInstanceID  = "instance-id"
 	cfOrigin      = "origin-url"
@@ -37,6 +40,8 @@ const (
 type Flags struct {
 	PrintVersion      bool
 	ValidateConfig    bool
+	StrictValidation  bool
+	TestConfig        bool
 	customPath        bool
 	ProxyListenPort   int
 	MetricsListenPort int
@@ -56,6 +61,13 @@ func parseFlags(applicationName string, arguments []string) (*Flags, error) {
 		"Prints the Trickster version")
 	flagSet.BoolVar(&flags.ValidateConfig, cfValidate, false,
 		"Validates a Trickster config and exits without running the server")
+	flagSet.BoolVar(&flags.ValidateConfig, cfValidateAlt, false,
+		"Alias for -"+cfValidate)
+	flagSet.BoolVar(&flags.StrictValidation, cfStrict, false,
+		"When validating a config, also fail if there are any warnings")
+	flagSet.BoolVar(&flags.TestConfig, cfTestConfig, false,
+		"Validates a Trickster config, checks that its origins and caches are reachable,"+
+			" and exits without running the server")
 	flagSet.StringVar(&flags.ConfigPath, cfConfig, "",
 		"Path to Trickster Config File")
 	flagSet.StringVar(&flags.LogLevel, cfLogLevel, "",
@@ -57,3 +57,28 @@ func TestLoadFlags(t *testing.T) {
 		t.Errorf("wanted \"%d\". got \"%d\".", 9092, c.Metrics.ListenPort)
 	}
 }
+
+func TestParseTestConfigFlag(t *testing.T) {
+	flags, err := parseFlags("trickster-test", []string{"-test-config"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !flags.TestConfig || flags.ValidateConfig {
+		t.Errorf("expected %t %t got %t %t", true, false, flags.TestConfig, flags.ValidateConfig)
+	}
+}
+
+func TestParseValidationFlags(t *testing.T) {
+	for _, a := range [][]string{{"-validate-config", "-strict"}, {"-validate", "-strict"}} {
+		flags, err := parseFlags("trickster-test", a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !flags.ValidateConfig {
+			t.Errorf("expected validation to be enabled by %s", a[0])
+		}
+		if !flags.StrictValidation {
+			t.Error("expected strict validation to be enabled")
+		}
+	}
+}
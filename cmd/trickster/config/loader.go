@@ -84,11 +84,31 @@ func Load(applicationName string, applicationVersion string, arguments []string)
 	if err != nil {
 		return nil, flags, err
 	}
+	for _, o := range c.Backends {
+		if err = o.ResolveCacheKeyPrefix(c.Main.InstanceID); err != nil {
+			return nil, flags, err
+		}
+	}
+
+	nhl, err := negative.ConfigLookup(c.NegativeCacheConfigs).ValidateHeaderRules()
+	if err != nil {
+		return nil, flags, err
+	}
+	nrl, err := negative.ResponseConfigLookup(c.NegativeCacheResponses).Validate(c.NegativeCacheConfigs)
+	if err != nil {
+		return nil, flags, err
+	}
+	for _, o := range c.Backends {
+		o.NegativeCacheHeaderRules = nhl[o.NegativeCacheName]
+		o.NegativeCacheResponses = nrl[o.NegativeCacheName]
+	}
 
 	for _, c := range c.Caches {
 		c.Index.FlushInterval = time.Duration(c.Index.FlushIntervalMS) * time.Millisecond
 		c.Index.ReapInterval = time.Duration(c.Index.ReapIntervalMS) * time.Millisecond
 	}
 
+	c.fingerprint()
+
 	return c, flags, nil
 }
@@ -617,6 +617,32 @@ func TestLoadConfigurationWarning2(t *testing.T) {
 
 }
 
+func TestLoadConfigurationWarning3(t *testing.T) {
+
+	a := []string{"-config", "../../../testdata/test.warning3.conf"}
+	conf, _, err := Load("trickster-test", "0", a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := 2
+	l := len(conf.LoaderWarnings)
+	if l != expected {
+		t.Fatalf("expected %d got %d", expected, l)
+	}
+	if !strings.Contains(conf.LoaderWarnings[0], "X-Broken") {
+		t.Errorf("expected warning for %s got %s", "X-Broken", conf.LoaderWarnings[0])
+	}
+	if !strings.Contains(conf.LoaderWarnings[1], "X-Unknown") {
+		t.Errorf("expected warning for %s got %s", "X-Unknown", conf.LoaderWarnings[1])
+	}
+
+	o := conf.Backends["test"]
+	if len(o.HeaderInjections) != 2 {
+		t.Errorf("expected %d got %d", 2, len(o.HeaderInjections))
+	}
+}
+
 func TestLoadEmptyArgs(t *testing.T) {
 	a := []string{}
 	_, _, err := Load("trickster-test", "0", a)
@@ -624,3 +650,48 @@ func TestLoadEmptyArgs(t *testing.T) {
 		t.Error("expected error: no valid backends configured")
 	}
 }
+
+func TestLoadConfigurationExtensions(t *testing.T) {
+
+	// configuration files are always YAML, so the file extension must not
+	// change how a file is loaded or validated
+	b, err := os.ReadFile("../../../testdata/test.multiple_backends.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bad, err := os.ReadFile("../../../testdata/test.bad_origin_url.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base, _, err := Load("trickster-test", "0",
+		[]string{"-config", "../../../testdata/test.multiple_backends.conf"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	td := t.TempDir()
+	for _, ext := range []string{".yaml", ".yml"} {
+		t.Run(ext, func(t *testing.T) {
+			f := td + "/trickster" + ext
+			if err := os.WriteFile(f, b, 0600); err != nil {
+				t.Fatal(err)
+			}
+			conf, _, err := Load("trickster-test", "0", []string{"-config", f})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if conf.String() != base.String() {
+				t.Errorf("expected config loaded from %s to match", ext)
+			}
+
+			f = td + "/bad_origin_url" + ext
+			if err := os.WriteFile(f, bad, 0600); err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err = Load("trickster-test", "0", []string{"-config", f}); err == nil {
+				t.Errorf("expected error loading invalid config from %s", ext)
+			}
+		})
+	}
+}
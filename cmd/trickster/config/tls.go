@@ -18,33 +18,43 @@ package config
 
 import (
 	"crypto/tls"
+	"sort"
 
 	bo "github.com/trickstercache/trickster/v2/pkg/backends/options"
+	to "github.com/trickstercache/trickster/v2/pkg/proxy/tls/options"
 )
 
 // TLSCertConfig returns the crypto/tls configuration object with a list of name-bound
-// certs derived from the running config
+// certs derived from the running config. Frontend certs are listed first, so the first
+// frontend cert is the default when a client's SNI server name matches no cert
 func (c *Config) TLSCertConfig() (*tls.Config, error) {
-	var err error
 	if !c.Frontend.ServeTLS {
 		return nil, nil
 	}
-	to := []*bo.Options{}
-	for _, o := range c.Backends {
-		if o.TLS.ServeTLS {
-			to = append(to, o)
-		}
+	fc, err := c.Frontend.LoadTLSCertificates()
+	if err != nil {
+		return nil, err
 	}
+	bl := c.tlsBackends()
 
-	l := len(to)
+	l := len(fc) + len(bl)
 	if l == 0 {
 		return nil, nil
 	}
 
 	tlsConfig := &tls.Config{Certificates: make([]tls.Certificate, l), NextProtos: []string{"h2"}}
 
-	for i, tc := range to {
-		tlsConfig.Certificates[i], err = tls.LoadX509KeyPair(tc.TLS.FullChainCertPath, tc.TLS.PrivateKeyPath)
+	// when configured, require or verify client certificates (mutual TLS)
+	tlsConfig.ClientCAs, err = c.Frontend.LoadClientCAs()
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.ClientAuth = c.Frontend.ClientAuthType()
+
+	copy(tlsConfig.Certificates, fc)
+	for i, tc := range bl {
+		tlsConfig.Certificates[len(fc)+i], err = tls.LoadX509KeyPair(tc.TLS.FullChainCertPath,
+			tc.TLS.PrivateKeyPath)
 		if err != nil {
 			return nil, err
 		}
@@ -53,3 +63,36 @@ func (c *Config) TLSCertConfig() (*tls.Config, error) {
 	return tlsConfig, nil
 
 }
+
+// TLSKeyPairs returns the files from which each of the certs in the TLSCertConfig
+// is loaded, in the same order
+func (c *Config) TLSKeyPairs() []to.KeyPair {
+	if !c.Frontend.ServeTLS {
+		return nil
+	}
+	bl := c.tlsBackends()
+	out := make([]to.KeyPair, 0, len(c.Frontend.TLSCertificates)+len(bl))
+	for _, tc := range c.Frontend.TLSCertificates {
+		out = append(out, to.KeyPair{CertPath: tc.FullChainCertPath, KeyPath: tc.PrivateKeyPath})
+	}
+	for _, o := range bl {
+		out = append(out, to.KeyPair{CertPath: o.TLS.FullChainCertPath, KeyPath: o.TLS.PrivateKeyPath})
+	}
+	return out
+}
+
+// tlsBackends returns the Backends that serve TLS, sorted by name
+func (c *Config) tlsBackends() []*bo.Options {
+	names := make([]string, 0, len(c.Backends))
+	for k, o := range c.Backends {
+		if o.TLS.ServeTLS {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	out := make([]*bo.Options, len(names))
+	for i, k := range names {
+		out[i] = c.Backends[k]
+	}
+	return out
+}
@@ -17,8 +17,14 @@
 package config
 
 import (
+	"crypto/tls"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
 	"testing"
 
+	fropt "github.com/trickstercache/trickster/v2/pkg/frontend/options"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/tls/options"
 	tlstest "github.com/trickstercache/trickster/v2/pkg/testutil/tls"
 )
@@ -94,6 +100,177 @@ func TestTLSCertConfig(t *testing.T) {
 
 }
 
+const testFrontendMissingCert = `
+frontend:
+  tls_certificates:
+    - full_chain_cert_path: /nonexistent/cert.pem
+      private_key_path: /nonexistent/key.pem
+`
+
+func TestTLSCertConfigFrontend(t *testing.T) {
+
+	c, tml := emptyTestConfig()
+
+	tls01, closer01, err := tlsConfig("")
+	if closer01 != nil {
+		defer closer01()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	tls02, closer02, err := tlsConfig("")
+	if closer02 != nil {
+		defer closer02()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Backends["test"].TLS = tls01
+	c.Frontend.TLSCertificates = []*fropt.TLSCertificate{
+		{FullChainCertPath: tls02.FullChainCertPath, PrivateKeyPath: tls02.PrivateKeyPath},
+	}
+	c.Frontend.ServeTLS = true
+
+	tc, err := c.TLSCertConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tc.Certificates) != 2 {
+		t.Fatalf("expected %d got %d", 2, len(tc.Certificates))
+	}
+
+	// the frontend certificate is first in the list, making it the default
+	fc, _ := c.Frontend.LoadTLSCertificates()
+	if string(tc.Certificates[0].Certificate[0]) != string(fc[0].Certificate[0]) {
+		t.Error("expected frontend certificate to be first")
+	}
+
+	// the key pairs are listed in the same order as the certificates
+	kp := c.TLSKeyPairs()
+	if len(kp) != 2 {
+		t.Fatalf("expected %d got %d", 2, len(kp))
+	}
+	if kp[0].CertPath != tls02.FullChainCertPath || kp[0].KeyPath != tls02.PrivateKeyPath ||
+		kp[1].CertPath != tls01.FullChainCertPath || kp[1].KeyPath != tls01.PrivateKeyPath {
+		t.Errorf("unexpected key pairs %v", kp)
+	}
+
+	// a missing certificate file fails the config load
+	c = NewConfig()
+	err = c.loadYAMLConfig(tml+testFrontendMissingCert, &Flags{})
+	if !errors.Is(err, fropt.ErrInvalidTLSCertificate) {
+		t.Errorf("expected %v got %v", fropt.ErrInvalidTLSCertificate, err)
+	}
+}
+
+func TestTLSCertConfigClientAuth(t *testing.T) {
+
+	c, _ := emptyTestConfig()
+
+	tls01, closer01, err := tlsConfig("")
+	if closer01 != nil {
+		defer closer01()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Backends["test"].TLS = tls01
+	c.Frontend.ServeTLS = true
+
+	// the trusted client cert is self-signed, so it is also the client CA
+	ck, cc, err := tlstest.GetTestClientKeyAndCert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCert, err := tls.X509KeyPair(cc, ck)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ik, ic, err := tlstest.GetTestClientKeyAndCert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	untrustedCert, err := tls.X509KeyPair(ic, ik)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caPath := filepath.Join(t.TempDir(), "client-ca.pem")
+	if err = os.WriteFile(caPath, cc, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// require_client_cert without a client CA is invalid
+	c.Frontend.RequireClientCert = true
+	_, err = c.TLSCertConfig()
+	if !errors.Is(err, fropt.ErrInvalidClientCA) {
+		t.Errorf("expected %v got %v", fropt.ErrInvalidClientCA, err)
+	}
+
+	c.Frontend.ClientCAPath = caPath + ".nonexistent"
+	_, err = c.TLSCertConfig()
+	if !errors.Is(err, fropt.ErrInvalidClientCA) {
+		t.Errorf("expected %v got %v", fropt.ErrInvalidClientCA, err)
+	}
+
+	c.Frontend.ClientCAPath = caPath
+	tc, err := c.TLSCertConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tc.ClientAuth != tls.RequireAndVerifyClientCert {
+		t.Errorf("expected %v got %v", tls.RequireAndVerifyClientCert, tc.ClientAuth)
+	}
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", tc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				if conn.(*tls.Conn).Handshake() == nil {
+					conn.Write([]byte("ok"))
+				}
+			}()
+		}
+	}()
+
+	tests := []struct {
+		name      string
+		certs     []tls.Certificate
+		expectErr bool
+	}{
+		{"valid", []tls.Certificate{clientCert}, false},
+		{"untrusted", []tls.Certificate{untrustedCert}, true},
+		{"none", nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, err := tls.Dial("tcp", l.Addr().String(),
+				&tls.Config{InsecureSkipVerify: true, Certificates: test.certs})
+			if err == nil {
+				defer conn.Close()
+				// with TLS 1.3, the server's rejection of the client cert is
+				// received by the client on its first read after the handshake
+				_, err = io.ReadAll(conn)
+			}
+			if test.expectErr && err == nil {
+				t.Error("expected handshake error")
+			} else if !test.expectErr && err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
 func tlsConfig(condition string) (*options.Options, func(), error) {
 
 	kf, cf, closer, err := tlstest.GetTestKeyAndCertFiles(condition)
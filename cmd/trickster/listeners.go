@@ -23,6 +23,7 @@ import (
 
 	"github.com/trickstercache/trickster/v2/cmd/trickster/config"
 	"github.com/trickstercache/trickster/v2/pkg/backends"
+	fropt "github.com/trickstercache/trickster/v2/pkg/frontend/options"
 	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
 	"github.com/trickstercache/trickster/v2/pkg/observability/metrics"
 	"github.com/trickstercache/trickster/v2/pkg/observability/tracing"
@@ -48,6 +49,9 @@ func applyListenerConfigs(conf, oldConf *config.Config,
 	adminRouter := http.NewServeMux()
 	adminRouter.Handle(conf.ReloadConfig.HandlerPath, reloadHandler)
 	adminRouter.HandleFunc(conf.Main.PurgePathHandlerPath, handlers.PurgePathHandlerFunc(conf, &o))
+	adminRouter.HandleFunc(conf.Main.PurgeHandlerPath, handlers.PurgeHandlerFunc(conf, &o))
+	adminRouter.HandleFunc(conf.Main.WarmHandlerPath, handlers.WarmHandlerFunc(conf, &o))
+	adminRouter.HandleFunc(conf.Main.InspectHandlerPath, handlers.InspectHandlerFunc(conf, &o))
 
 	// No changes in frontend config
 	if oldConf != nil && oldConf.Frontend != nil &&
@@ -60,6 +64,7 @@ func applyListenerConfigs(conf, oldConf *config.Config,
 				cs := l.CertSwapper()
 				if cs != nil {
 					cs.SetCerts(tlsConfig.Certificates)
+					cs.SetKeyPairs(conf.TLSKeyPairs())
 				}
 			}
 		}
@@ -83,7 +88,10 @@ func applyListenerConfigs(conf, oldConf *config.Config,
 	if conf.Frontend.ServeTLS && conf.Frontend.TLSListenPort > 0 && (!hasOldFC ||
 		!oldConf.Frontend.ServeTLS ||
 		(oldConf.Frontend.TLSListenAddress != conf.Frontend.TLSListenAddress ||
-			oldConf.Frontend.TLSListenPort != conf.Frontend.TLSListenPort)) {
+			oldConf.Frontend.TLSListenPort != conf.Frontend.TLSListenPort ||
+			!oldConf.Frontend.TimeoutsEqual(conf.Frontend) ||
+			oldConf.Frontend.ClientCAPath != conf.Frontend.ClientCAPath ||
+			oldConf.Frontend.RequireClientCert != conf.Frontend.RequireClientCert)) {
 		lg.DrainAndClose("tlsListener", drainTimeout)
 		tlsConfig, err = conf.TLSCertConfig()
 		if err != nil {
@@ -93,7 +101,8 @@ func applyListenerConfigs(conf, oldConf *config.Config,
 			tracerFlusherSet = true
 			go lg.StartListener("tlsListener",
 				conf.Frontend.TLSListenAddress, conf.Frontend.TLSListenPort,
-				conf.Frontend.ConnectionsLimit, tlsConfig, router, wg, tracers, exitFunc,
+				conf.Frontend.ConnectionsLimit, tlsConfig, conf.TLSKeyPairs(), false,
+				frontendTimeouts(conf.Frontend), router, wg, tracers, exitFunc,
 				time.Duration(conf.ReloadConfig.DrainTimeoutMS)*time.Millisecond, log)
 		}
 	} else if !conf.Frontend.ServeTLS && hasOldFC && oldConf.Frontend.ServeTLS {
@@ -111,6 +120,7 @@ func applyListenerConfigs(conf, oldConf *config.Config,
 			cs := l.CertSwapper()
 			if cs != nil {
 				cs.SetCerts(tlsConfig.Certificates)
+				cs.SetKeyPairs(conf.TLSKeyPairs())
 			}
 		}
 	}
@@ -118,7 +128,9 @@ func applyListenerConfigs(conf, oldConf *config.Config,
 	// if the plaintext HTTP port is configured, then set up the http listener instance
 	if conf.Frontend.ListenPort > 0 && (!hasOldFC ||
 		(oldConf.Frontend.ListenAddress != conf.Frontend.ListenAddress ||
-			oldConf.Frontend.ListenPort != conf.Frontend.ListenPort)) {
+			oldConf.Frontend.ListenPort != conf.Frontend.ListenPort ||
+			oldConf.Frontend.EnableH2C != conf.Frontend.EnableH2C ||
+			!oldConf.Frontend.TimeoutsEqual(conf.Frontend))) {
 		lg.DrainAndClose("httpListener", drainTimeout)
 		wg.Add(1)
 		var t2 tracing.Tracers
@@ -127,7 +139,8 @@ func applyListenerConfigs(conf, oldConf *config.Config,
 		}
 		go lg.StartListener("httpListener",
 			conf.Frontend.ListenAddress, conf.Frontend.ListenPort,
-			conf.Frontend.ConnectionsLimit, nil, router, wg, t2, exitFunc, 0, log)
+			conf.Frontend.ConnectionsLimit, nil, nil, conf.Frontend.EnableH2C,
+			frontendTimeouts(conf.Frontend), router, wg, t2, exitFunc, 0, log)
 	}
 
 	// if the Metrics HTTP port is configured, then set up the http listener instance
@@ -143,7 +156,7 @@ func applyListenerConfigs(conf, oldConf *config.Config,
 		wg.Add(1)
 		go lg.StartListener("metricsListener",
 			conf.Metrics.ListenAddress, conf.Metrics.ListenPort,
-			conf.Frontend.ConnectionsLimit, nil, metricsRouter, wg, nil, exitFunc, 0, log)
+			conf.Frontend.ConnectionsLimit, nil, nil, false, nil, metricsRouter, wg, nil, exitFunc, 0, log)
 	} else {
 		metricsRouter.Handle("/metrics", metrics.Handler())
 		metricsRouter.HandleFunc(conf.Main.ConfigHandlerPath, handlers.ConfigHandleFunc(conf))
@@ -161,16 +174,32 @@ func applyListenerConfigs(conf, oldConf *config.Config,
 		rr.HandleFunc(conf.Main.ConfigHandlerPath, handlers.ConfigHandleFunc(conf))
 		rr.Handle(conf.ReloadConfig.HandlerPath, reloadHandler)
 		rr.HandleFunc(conf.Main.PurgePathHandlerPath, handlers.PurgePathHandlerFunc(conf, &o))
+		rr.HandleFunc(conf.Main.PurgeHandlerPath, handlers.PurgeHandlerFunc(conf, &o))
+		rr.HandleFunc(conf.Main.WarmHandlerPath, handlers.WarmHandlerFunc(conf, &o))
+		rr.HandleFunc(conf.Main.InspectHandlerPath, handlers.InspectHandlerFunc(conf, &o))
 		if conf.Main.PprofServer == "both" || conf.Main.PprofServer == "reload" {
 			routing.RegisterPprofRoutes("reload", rr, log)
 		}
 		go lg.StartListener("reloadListener",
 			conf.ReloadConfig.ListenAddress, conf.ReloadConfig.ListenPort,
-			conf.Frontend.ConnectionsLimit, nil, rr, wg, nil, exitFunc, 0, log)
+			conf.Frontend.ConnectionsLimit, nil, nil, false, nil, rr, wg, nil, exitFunc, 0, log)
 	} else {
 		rr.HandleFunc(conf.Main.ConfigHandlerPath, handlers.ConfigHandleFunc(conf))
 		rr.Handle(conf.ReloadConfig.HandlerPath, reloadHandler)
 		rr.HandleFunc(conf.Main.PurgePathHandlerPath, handlers.PurgePathHandlerFunc(conf, &o))
+		rr.HandleFunc(conf.Main.PurgeHandlerPath, handlers.PurgeHandlerFunc(conf, &o))
+		rr.HandleFunc(conf.Main.WarmHandlerPath, handlers.WarmHandlerFunc(conf, &o))
+		rr.HandleFunc(conf.Main.InspectHandlerPath, handlers.InspectHandlerFunc(conf, &o))
 		lg.UpdateRouter("reloadListener", rr)
 	}
 }
+
+// frontendTimeouts returns the listener timeouts configured in the frontend options
+func frontendTimeouts(o *fropt.Options) *listener.Timeouts {
+	return &listener.Timeouts{
+		ReadHeader: time.Duration(o.ReadHeaderTimeoutMS) * time.Millisecond,
+		Read:       time.Duration(o.ReadTimeoutMS) * time.Millisecond,
+		Write:      time.Duration(o.WriteTimeoutMS) * time.Millisecond,
+		Idle:       time.Duration(o.IdleTimeoutMS) * time.Millisecond,
+	}
+}
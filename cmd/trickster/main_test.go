@@ -17,8 +17,20 @@
 package main
 
 import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"testing"
+
+	"github.com/trickstercache/trickster/v2/cmd/trickster/config"
+	"github.com/trickstercache/trickster/v2/pkg/backends"
+	"github.com/trickstercache/trickster/v2/pkg/cache/registration"
+	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
 )
 
 func TestMain(t *testing.T) {
@@ -36,3 +48,189 @@ func TestRunConfig(t *testing.T) {
 	runConfig(nil, wg, nil, nil, []string{"-provider", "rpc", "-origin-url", "http://trickstercache.org"}, nil)
 
 }
+
+func TestApplyCachingConfigReload(t *testing.T) {
+	args := []string{"-provider", "rpc", "-origin-url", "http://trickstercache.org"}
+	c1, _, err := config.Load("trickster", "test", args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := tl.ConsoleLogger("error")
+	caches1 := applyCachingConfig(c1, nil, logger, nil)
+	defer registration.CloseCaches(caches1)
+
+	c2, _, err := config.Load("trickster", "test", args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caches2 := applyCachingConfig(c2, c1, logger, caches1)
+	if caches2["default"] != caches1["default"] {
+		t.Error("expected the unchanged cache to be kept")
+	}
+
+	prev := backends.Backends{"default": nil, "frontend": nil}
+	u := unchangedBackends(c2, c1, prev)
+	if _, ok := u["default"]; !ok || len(u) != 1 {
+		t.Errorf("expected only the default backend to be unchanged, got %d", len(u))
+	}
+
+	c3, _, err := config.Load("trickster", "test",
+		[]string{"-provider", "rpc", "-origin-url", "http://example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u = unchangedBackends(c3, c1, prev); len(u) != 0 {
+		t.Errorf("expected %d got %d", 0, len(u))
+	}
+}
+
+func TestRunValidation(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	var exits int
+	errorFunc := func() { exits++ }
+
+	tests := []struct {
+		args        []string
+		expectError bool
+	}{
+		{ // 0 - valid config
+			args: []string{"-validate", "-provider", "rpc", "-origin-url", "http://trickstercache.org"},
+		},
+		{ // 1 - warnings are permitted without -strict
+			args: []string{"-validate-config", "-config", "../../testdata/test.warning3.conf"},
+		},
+		{ // 2 - warnings fail validation with -strict
+			args:        []string{"-validate", "-strict", "-config", "../../testdata/test.warning3.conf"},
+			expectError: true,
+		},
+		{ // 3 - invalid config
+			args:        []string{"-validate", "-config", "../../testdata/test.bad_origin_url.conf"},
+			expectError: true,
+		},
+		{ // 4 - config that can't be loaded
+			args:        []string{"-validate", "-config", "../../testdata/does-not-exist.conf"},
+			expectError: true,
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			exits = 0
+			err := runConfig(nil, wg, nil, nil, test.args, errorFunc)
+			if test.expectError {
+				if err == nil {
+					t.Error("expected error")
+				}
+				if exits != 1 {
+					t.Errorf("expected %d got %d", 1, exits)
+				}
+			} else {
+				if err != nil {
+					t.Error(err)
+				}
+				if exits != 0 {
+					t.Errorf("expected %d got %d", 0, exits)
+				}
+			}
+		})
+	}
+}
+
+const testConfigTestYAML = `
+frontend:
+  listen_port: 0
+caches:
+  mem:
+    provider: memory
+  unreachable:
+    provider: redis
+    redis:
+      endpoint: 127.0.0.1:1
+backends:
+  test:
+    provider: rpc
+    origin_url: {{origin}}
+    cache_name: mem
+    healthcheck:
+      path: /health
+`
+
+const testConfigTestYAMLUnreachable = `
+  test2:
+    provider: rpc
+    origin_url: {{origin}}
+    cache_name: unreachable
+`
+
+func TestRunConfigTest(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	wg := &sync.WaitGroup{}
+	var exits int
+	errorFunc := func() { exits++ }
+	dir := t.TempDir()
+
+	tests := []struct {
+		yaml        string
+		expectError bool
+	}{
+		{ // 0 - reachable origin and cache
+			yaml: testConfigTestYAML,
+		},
+		{ // 1 - unreachable cache
+			yaml:        testConfigTestYAML + testConfigTestYAMLUnreachable,
+			expectError: true,
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			exits = 0
+			fn := filepath.Join(dir, strconv.Itoa(i)+".yaml")
+			err := os.WriteFile(fn, []byte(strings.ReplaceAll(test.yaml, "{{origin}}", ts.URL)), 0600)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = runConfig(nil, wg, nil, nil, []string{"-test-config", "-config", fn}, errorFunc)
+			if test.expectError {
+				if err != errConfigTestFailed {
+					t.Errorf("expected %v got %v", errConfigTestFailed, err)
+				}
+				if exits != 1 {
+					t.Errorf("expected %d got %d", 1, exits)
+				}
+			} else {
+				if err != nil {
+					t.Error(err)
+				}
+				if exits != 0 {
+					t.Errorf("expected %d got %d", 0, exits)
+				}
+			}
+		})
+	}
+
+	// the results table reports each origin and cache
+	conf, _, err := config.Load("trickster-test", "0", []string{"-config", filepath.Join(dir, "1.yaml")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &bytes.Buffer{}
+	runConfigTest(conf, nil, w, nil)
+	rows := make(map[string]string)
+	for _, line := range strings.Split(w.String(), "\n") {
+		if f := strings.Fields(line); len(f) >= 3 {
+			rows[f[0]+" "+f[1]] = f[2]
+		}
+	}
+	for k, expected := range map[string]string{"backend test": "pass", "backend test2": "pass",
+		"cache mem": "pass", "cache unreachable": "fail"} {
+		if rows[k] != expected {
+			t.Errorf("expected %s for %s got %s", expected, k, rows[k])
+		}
+	}
+}
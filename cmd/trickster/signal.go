@@ -21,6 +21,7 @@ import (
 	"os/signal"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/trickstercache/trickster/v2/cmd/trickster/config"
 	"github.com/trickstercache/trickster/v2/pkg/cache"
@@ -28,9 +29,11 @@ import (
 )
 
 var hups = make(chan os.Signal, 1)
+var terms = make(chan os.Signal, 1)
 
 func init() {
 	signal.Notify(hups, syscall.SIGHUP)
+	signal.Notify(terms, syscall.SIGTERM, syscall.SIGINT)
 }
 
 func startHupMonitor(conf *config.Config, wg *sync.WaitGroup, log *tl.Logger,
@@ -51,12 +54,49 @@ func startHupMonitor(conf *config.Config, wg *sync.WaitGroup, log *tl.Logger,
 						conf.Main.ReloaderLock.Unlock()
 						return // runConfig will start a new HupMonitor in place of this one
 					}
+					conf.Main.ReloaderLock.Unlock()
+					tl.Warn(log, "configuration NOT reloaded", tl.Pairs{"detail": err.Error()})
+					continue
 				}
 				conf.Main.ReloaderLock.Unlock()
 				tl.Warn(log, "configuration NOT reloaded", tl.Pairs{})
+			case sig := <-terms:
+				// the reloader lock is never released, so that a reload cannot restart
+				// any listeners while they are draining
+				conf.Main.ReloaderLock.Lock()
+				shutdown(conf, wg, log, caches, sig)
+				return
 			case <-conf.Resources.QuitChan:
 				return
 			}
 		}
 	}()
 }
+
+// shutdown stops the listeners from accepting new connections, waits up to the configured
+// drain timeout for in-flight requests to complete, and then closes the caches so that
+// their indexes are flushed. The WaitGroup is held until the caches are closed, so the
+// process does not exit as soon as the listeners stop serving
+func shutdown(conf *config.Config, wg *sync.WaitGroup, log *tl.Logger,
+	caches map[string]cache.Cache, sig os.Signal) {
+	if wg != nil {
+		wg.Add(1)
+		defer wg.Done()
+	}
+	drainTimeout := time.Duration(conf.Main.ShutdownDrainTimeoutMS) * time.Millisecond
+	tl.Warn(log, "graceful shutdown starting",
+		tl.Pairs{"signal": sig.String(), "drainTimeout": drainTimeout.String()})
+	if err := lg.Shutdown(drainTimeout); err != nil {
+		tl.Warn(log, "shutdown drain timeout exceeded, in-flight requests were cut off",
+			tl.Pairs{"drainTimeout": drainTimeout.String(), "detail": err.Error()})
+	}
+	if hc != nil {
+		hc.Shutdown()
+	}
+	for k, c := range caches {
+		if err := c.Close(); err != nil {
+			tl.Error(log, "cache close failed", tl.Pairs{"cacheName": k, "detail": err.Error()})
+		}
+	}
+	tl.Info(log, "graceful shutdown complete", tl.Pairs{})
+}
@@ -32,7 +32,10 @@ Trickster Usage:
  trickster -version
 
  Validating a configuration file:
-  trickster -validate-config -config /path/to/file.yaml
+  trickster -validate-config [-strict] -config /path/to/file.yaml
+
+ Testing that a configuration's origins and caches are reachable:
+  trickster -test-config -config /path/to/file.yaml
 
  Using a configuration file:
   trickster -config /path/to/file.yaml [-log-level DEBUG|INFO|WARN|ERROR] [-proxy-port 8480] [-metrics-port 8481]
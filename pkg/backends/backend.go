@@ -23,6 +23,7 @@ import (
 	"github.com/trickstercache/trickster/v2/pkg/backends/healthcheck"
 	ho "github.com/trickstercache/trickster/v2/pkg/backends/healthcheck/options"
 	bo "github.com/trickstercache/trickster/v2/pkg/backends/options"
+	"github.com/trickstercache/trickster/v2/pkg/backends/upstreams"
 	"github.com/trickstercache/trickster/v2/pkg/cache"
 	"github.com/trickstercache/trickster/v2/pkg/proxy"
 	po "github.com/trickstercache/trickster/v2/pkg/proxy/paths/options"
@@ -47,6 +48,9 @@ type Backend interface {
 	SetCache(cache.Cache)
 	// Router returns a Router that handles HTTP Requests for this Backend
 	Router() http.Handler
+	// SetRouter replaces the Router that handles HTTP Requests for this Backend, so that
+	// its routes can be registered anew when the Backend is kept across a config reload
+	SetRouter(http.Handler)
 	// Cache returns a handle to the Cache instance used by the Backend
 	Cache() cache.Cache
 	// BaseUpstreamURL returns the base URL for upstream requests
@@ -106,6 +110,16 @@ func New(name string, o *bo.Options, registrar Registrar,
 	var bur *url.URL
 	if o != nil {
 		bur = urls.FromParts(o.Scheme, o.Host, o.PathPrefix, "", "")
+		// distribute requests across the upstream hosts, if any are configured.
+		// the health check client is not wrapped, so it continues to probe the
+		// origin host directly
+		if c != nil && len(o.UpstreamHosts) > 0 {
+			targets := make([]upstreams.Target, len(o.UpstreamHosts))
+			for i, u := range o.UpstreamHosts {
+				targets[i] = upstreams.Target{Host: u.Host, Weight: u.Weight}
+			}
+			c.Transport = upstreams.New(o.Host, c.Transport, targets...)
+		}
 	}
 	return &backend{name: name, config: o, router: router, cache: cache,
 		webClient: c, healthCheckClient: hcc, baseUpstreamURL: bur, registrar: registrar}, err
@@ -156,6 +170,11 @@ func (b *backend) Router() http.Handler {
 	return b.router
 }
 
+// SetRouter replaces the http.Handler that handles request routing for this Client
+func (b *backend) SetRouter(r http.Handler) {
+	b.router = r
+}
+
 // RegisterHandlers registers the provided handlers with the backend
 func (b *backend) RegisterHandlers(h map[string]http.Handler) {
 	if !b.handlersRegistered {
@@ -23,6 +23,7 @@ import (
 
 	"github.com/trickstercache/trickster/v2/cmd/trickster/config"
 	bo "github.com/trickstercache/trickster/v2/pkg/backends/options"
+	"github.com/trickstercache/trickster/v2/pkg/backends/upstreams"
 	cr "github.com/trickstercache/trickster/v2/pkg/cache/registration"
 	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
 )
@@ -75,6 +76,10 @@ func TestRouter(t *testing.T) {
 	if r != nil {
 		t.Error("expected nil router")
 	}
+	client.SetRouter(http.NotFoundHandler())
+	if client.Router() == nil {
+		t.Error("expected router")
+	}
 }
 
 func TestHTTPClient(t *testing.T) {
@@ -87,6 +92,22 @@ func TestHTTPClient(t *testing.T) {
 	}
 }
 
+func TestHTTPClientUpstreamHosts(t *testing.T) {
+	o := bo.New()
+	o.Host = "origin:9090"
+	o.UpstreamHosts = []*bo.UpstreamHost{{Host: "a:9090"}, {Host: "b:9090"}}
+	b, err := New("test", o, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := b.HTTPClient().Transport.(*upstreams.Balancer); !ok {
+		t.Error("expected upstream balancer transport")
+	}
+	if _, ok := b.HealthCheckHTTPClient().Transport.(*upstreams.Balancer); ok {
+		t.Error("expected health check client not to use the upstream balancer")
+	}
+}
+
 func TestSetCache(t *testing.T) {
 
 	c := &backend{name: "test", config: bo.New()}
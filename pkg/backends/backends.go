@@ -18,10 +18,12 @@
 package backends
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/trickstercache/trickster/v2/pkg/backends/healthcheck"
 	bo "github.com/trickstercache/trickster/v2/pkg/backends/options"
+	"github.com/trickstercache/trickster/v2/pkg/backends/upstreams"
 )
 
 // Backends represents a map of Backends keyed by Name
@@ -32,29 +34,82 @@ type Backends map[string]Backend
 func (b Backends) StartHealthChecks(logger interface{}) (healthcheck.HealthChecker, error) {
 	hc := healthcheck.New()
 	for k, c := range b {
-		bo := c.Configuration()
-		if IsVirtual(bo.Provider) || k == "frontend" {
-			continue
-		}
-		hco := bo.HealthCheck
-		if hco == nil {
+		if !applyHealthCheckDefaults(k, c) {
 			continue
 		}
-		bo.HealthCheck = c.DefaultHealthCheckConfig()
-		if bo.HealthCheck == nil {
-			bo.HealthCheck = hco
-		} else {
-			bo.HealthCheck.Overlay(k, hco)
-		}
+		bo := c.Configuration()
 		st, err := hc.Register(k, bo.Provider, bo.HealthCheck, c.HealthCheckHTTPClient(), logger)
 		if err != nil {
 			return nil, err
 		}
 		c.SetHealthCheckProbe(st.Prober())
+		// each upstream host of a multi-host backend gets its own health check
+		// target, so that failing hosts can be skipped by the balancer
+		if hcl := c.HTTPClient(); hcl != nil {
+			if ub, ok := hcl.Transport.(*upstreams.Balancer); ok {
+				for _, h := range ub.Hosts() {
+					uo := bo.HealthCheck.Clone()
+					uo.Host = h
+					ust, err := hc.Register(k+"/"+h, bo.Provider, uo,
+						c.HealthCheckHTTPClient(), logger)
+					if err != nil {
+						return nil, err
+					}
+					ub.SetHealthStatus(h, ust)
+				}
+			}
+		}
 	}
 	return hc, nil
 }
 
+// CheckHealth performs a single health check probe of each backend's origin, and of
+// each upstream host of a multi-host backend, without starting any intervaled health
+// checks. It returns the outcome of each probe, keyed by the name of its target
+func (b Backends) CheckHealth(ctx context.Context) map[string]error {
+	out := make(map[string]error)
+	for k, c := range b {
+		if !applyHealthCheckDefaults(k, c) {
+			continue
+		}
+		bo := c.Configuration()
+		out[k] = healthcheck.Check(ctx, k, bo.Provider, bo.HealthCheck,
+			c.HealthCheckHTTPClient())
+		if hcl := c.HTTPClient(); hcl != nil {
+			if ub, ok := hcl.Transport.(*upstreams.Balancer); ok {
+				for _, h := range ub.Hosts() {
+					uo := bo.HealthCheck.Clone()
+					uo.Host = h
+					out[k+"/"+h] = healthcheck.Check(ctx, k+"/"+h, bo.Provider, uo,
+						c.HealthCheckHTTPClient())
+				}
+			}
+		}
+	}
+	return out
+}
+
+// applyHealthCheckDefaults overlays the backend's health check options onto its
+// provider's default health check config. It returns false if the backend is not
+// health checked
+func applyHealthCheckDefaults(name string, c Backend) bool {
+	bo := c.Configuration()
+	if IsVirtual(bo.Provider) || name == "frontend" {
+		return false
+	}
+	hco := bo.HealthCheck
+	if hco == nil {
+		return false
+	}
+	bo.HealthCheck = c.DefaultHealthCheckConfig()
+	if bo.HealthCheck == nil {
+		bo.HealthCheck = hco
+	} else {
+		bo.HealthCheck.Overlay(name, hco)
+	}
+	return true
+}
+
 // Get returns the named origin
 func (b Backends) Get(backendName string) Backend {
 	if c, ok := b[backendName]; ok {
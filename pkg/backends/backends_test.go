@@ -116,6 +116,24 @@ func TestStartHealthChecks(t *testing.T) {
 		t.Error(err)
 	}
 
+	// 3: multi-host backend registers a health check target per upstream host
+	o3 := bo.New()
+	o3.Host = "origin:9090"
+	o3.UpstreamHosts = []*bo.UpstreamHost{{Host: "a:9090"}, {Host: "b:9090", Weight: 2}}
+	o3.HealthCheck = ho.New()
+	c3, _ := New("test3", o3, nil, router.NewRouter(), nil)
+	b = Backends{"test3": c3}
+	hc, err := b.StartHealthChecks(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"test3", "test3/a:9090", "test3/b:9090"} {
+		if hc.Status(k) == nil {
+			t.Errorf("expected health check status for %s", k)
+		}
+	}
+	hc.Shutdown()
+
 }
 
 type testBackend struct {
@@ -0,0 +1,121 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package clickhouse
+
+import (
+	"strings"
+	"time"
+)
+
+// splitStatements splits a semicolon-delimited batch of SQL statements into its
+// individual statements. Semicolons within quoted strings and identifiers are not
+// treated as delimiters. Comments are removed, and the remaining statements are
+// trimmed, with any empty statements omitted
+func splitStatements(query string) []string {
+	var out []string
+	var sb strings.Builder
+	flush := func() {
+		if s := strings.TrimSpace(sb.String()); s != "" {
+			out = append(out, s)
+		}
+		sb.Reset()
+	}
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			j := i + 1
+			for ; j < len(query) && query[j] != c; j++ {
+				if query[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(query) {
+				j = len(query) - 1
+			}
+			sb.WriteString(query[i : j+1])
+			i = j
+		case strings.HasPrefix(query[i:], "--") || strings.HasPrefix(query[i:], "//"):
+			// drop the comment, but keep the newline that terminates it
+			j := strings.IndexByte(query[i:], '\n')
+			if j < 0 {
+				i = len(query)
+				continue
+			}
+			i += j - 1
+		case strings.HasPrefix(query[i:], "/*"):
+			j := strings.Index(query[i+2:], "*/")
+			if j < 0 {
+				i = len(query)
+				continue
+			}
+			sb.WriteByte(' ')
+			i += j + 3
+		case c == ';':
+			flush()
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	flush()
+	return out
+}
+
+// checkBatch returns nil if every statement in the batch is a time series query of
+// the same shape (timestamp field, step and formats) over the same time range, and
+// ErrHeterogeneousBatch otherwise
+func checkBatch(statements []string, bf time.Duration) error {
+	var first *batchShape
+	for _, s := range statements {
+		trq, ro, _, err := parseStatement(s, bf)
+		if err != nil {
+			return ErrHeterogeneousBatch
+		}
+		bs := &batchShape{
+			field:        trq.TimestampDefinition.Name,
+			step:         trq.Step,
+			start:        trq.Extent.Start,
+			end:          trq.Extent.End,
+			timeFormat:   ro.TimeFormat,
+			outputFormat: ro.OutputFormat,
+		}
+		if first == nil {
+			first = bs
+			continue
+		}
+		if !first.equal(bs) {
+			return ErrHeterogeneousBatch
+		}
+	}
+	return nil
+}
+
+// batchShape describes the parts of a time series query that must match across
+// every statement in a cacheable batch
+type batchShape struct {
+	field        string
+	step         time.Duration
+	start, end   time.Time
+	timeFormat   byte
+	outputFormat byte
+}
+
+func (bs *batchShape) equal(bs2 *batchShape) bool {
+	return bs.field == bs2.field && bs.step == bs2.step &&
+		bs.start.Equal(bs2.start) && bs.end.Equal(bs2.end) &&
+		bs.timeFormat == bs2.timeFormat && bs.outputFormat == bs2.outputFormat
+}
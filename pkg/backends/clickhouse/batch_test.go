@@ -0,0 +1,69 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package clickhouse
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var tq03b = strings.Replace(strings.Replace(tq03, "countMerge(some_count) AS cnt",
+	"sumMerge(some_sum) AS total", 1), "testdb.test_table", "testdb.other_table", 1)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"SELECT 1", []string{"SELECT 1"}},
+		{"SELECT 1; SELECT 2;", []string{"SELECT 1", "SELECT 2"}},
+		{" ;SELECT 1;\n;\n SELECT 2 ", []string{"SELECT 1", "SELECT 2"}},
+		{"SELECT 'a;b'; SELECT `c;d`", []string{"SELECT 'a;b'", "SELECT `c;d`"}},
+		{`SELECT 'a\';b'; SELECT "c;d"`, []string{`SELECT 'a\';b'`, `SELECT "c;d"`}},
+		{"SELECT 1 -- one; two\n; SELECT /* ; */ 2 // three;", []string{"SELECT 1", "SELECT   2"}},
+		{"SELECT 1 /* unclosed; ", []string{"SELECT 1"}},
+	}
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			out := splitStatements(test.query)
+			if strings.Join(out, "|") != strings.Join(test.expected, "|") {
+				t.Errorf("expected %q got %q", test.expected, out)
+			}
+		})
+	}
+}
+
+func TestCheckBatch(t *testing.T) {
+	tests := []struct {
+		statements []string
+		err        error
+	}{
+		{[]string{tq03, tq03b}, nil},
+		{[]string{tq03, tq03b, tq03}, nil},
+		{[]string{tq03, tq07}, ErrHeterogeneousBatch},
+		{[]string{tq03, "SELECT 1"}, ErrHeterogeneousBatch},
+		{[]string{tq03, bq00}, ErrHeterogeneousBatch},
+	}
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			if err := checkBatch(test.statements, defaultBackfillTolerance); err != test.err {
+				t.Errorf("expected %v got %v", test.err, err)
+			}
+		})
+	}
+}
@@ -20,6 +20,7 @@ package clickhouse
 import (
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/trickstercache/trickster/v2/pkg/backends"
@@ -36,6 +37,10 @@ import (
 
 var _ backends.TimeseriesBackend = (*Client)(nil)
 
+// defaultBackfillTolerance is the backfill tolerance used for ClickHouse when the
+// backend options are not available
+const defaultBackfillTolerance = 60 * time.Second
+
 // Client Implements the Proxy Client Interface
 type Client struct {
 	backends.TimeseriesBackend
@@ -73,24 +78,36 @@ func (c *Client) ParseTimeRangeQuery(r *http.Request) (*timeseries.TimeRangeQuer
 		}
 	}
 
-	trq, ro, canOPC, err := parse(sqlQuery)
-	if err != nil {
-		return nil, nil, canOPC, err
+	bf := defaultBackfillTolerance
+	var batchQueries bool
+	if res := request.GetResources(r); res != nil {
+		bf = res.BackendOptions.BackfillTolerance
+		batchQueries = res.BackendOptions.ClickHouse != nil &&
+			res.BackendOptions.ClickHouse.BatchQueries
 	}
 
-	var bf time.Duration
-	res := request.GetResources(r)
-	if res == nil {
-		// 60-second default backfill tolerance for ClickHouse
-		bf = 60 * time.Second
-	} else {
-		bf = res.BackendOptions.BackfillTolerance
+	if batchQueries {
+		if statements := splitStatements(sqlQuery); len(statements) > 1 {
+			if err := checkBatch(statements, bf); err != nil {
+				return nil, nil, false, err
+			}
+			// a homogeneous batch is cached whole by the object proxy cache,
+			// keyed on its normalized statements
+			sqlQuery = strings.Join(statements, ";\n")
+			if isBody {
+				r = request.SetBody(r, []byte(sqlQuery))
+			} else {
+				qi.Set(upQuery, sqlQuery)
+				r.URL.RawQuery = qi.Encode()
+			}
+			return nil, nil, true, ErrQueryBatch
+		}
 	}
 
-	if trq.BackfillTolerance == 0 {
-		trq.BackfillTolerance = bf
+	trq, ro, canOPC, err := parseStatement(sqlQuery, bf)
+	if err != nil {
+		return nil, nil, canOPC, err
 	}
-	trq.BackfillToleranceNS = bf.Nanoseconds()
 
 	trq.TemplateURL = urls.Clone(r.URL)
 
@@ -104,3 +121,18 @@ func (c *Client) ParseTimeRangeQuery(r *http.Request) (*timeseries.TimeRangeQuer
 
 	return trq, ro, canOPC, nil
 }
+
+// parseStatement parses the key parts of a TimeRangeQuery from a SQL statement,
+// independent of the transport over which the statement was received
+func parseStatement(statement string, bf time.Duration) (*timeseries.TimeRangeQuery,
+	*timeseries.RequestOptions, bool, error) {
+	trq, ro, canOPC, err := parse(statement)
+	if err != nil {
+		return nil, nil, canOPC, err
+	}
+	if trq.BackfillTolerance == 0 {
+		trq.BackfillTolerance = bf
+	}
+	trq.BackfillToleranceNS = bf.Nanoseconds()
+	return trq, ro, canOPC, nil
+}
@@ -24,9 +24,11 @@ import (
 	"github.com/trickstercache/trickster/v2/cmd/trickster/config"
 	"github.com/trickstercache/trickster/v2/pkg/backends"
 	"github.com/trickstercache/trickster/v2/pkg/backends/clickhouse/model"
+	cho "github.com/trickstercache/trickster/v2/pkg/backends/clickhouse/options"
 	bo "github.com/trickstercache/trickster/v2/pkg/backends/options"
 	cr "github.com/trickstercache/trickster/v2/pkg/cache/registration"
 	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/request"
 )
 
 var testModeler = model.NewModeler()
@@ -135,3 +137,62 @@ func TestParseTimeRangeQuery(t *testing.T) {
 	}
 
 }
+
+func TestParseTimeRangeQueryBatch(t *testing.T) {
+
+	o := bo.New()
+	client := &Client{}
+	newReq := func(query string) *http.Request {
+		req := &http.Request{URL: &url.URL{
+			Scheme:   "https",
+			Host:     "blah.com",
+			Path:     "/",
+			RawQuery: url.Values{"query": {query}}.Encode(),
+		},
+			Header: http.Header{},
+		}
+		return request.SetResources(req, &request.Resources{BackendOptions: o})
+	}
+
+	homogeneous := tq03 + ";\n " + tq03b + ";"
+	heterogeneous := tq03 + "; " + tq07
+
+	// batches are not considered unless enabled
+	_, _, _, err := client.ParseTimeRangeQuery(newReq(homogeneous))
+	if err == ErrQueryBatch {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	o.ClickHouse = &cho.Options{BatchQueries: true}
+
+	req := newReq(homogeneous)
+	trq, _, canOPC, err := client.ParseTimeRangeQuery(req)
+	if err != ErrQueryBatch {
+		t.Errorf("expected %v got %v", ErrQueryBatch, err)
+	}
+	if trq != nil {
+		t.Error("expected nil time range query")
+	}
+	if !canOPC {
+		t.Errorf("expected %t got %t", true, canOPC)
+	}
+	if v := req.URL.Query().Get("query"); v != tq03+";\n"+tq03b {
+		t.Errorf("unexpected normalized batch: %s", v)
+	}
+
+	_, _, canOPC, err = client.ParseTimeRangeQuery(newReq(heterogeneous))
+	if err != ErrHeterogeneousBatch {
+		t.Errorf("expected %v got %v", ErrHeterogeneousBatch, err)
+	}
+	if canOPC {
+		t.Errorf("expected %t got %t", false, canOPC)
+	}
+
+	// a single statement with a trailing semicolon is not a batch
+	trq, _, _, err = client.ParseTimeRangeQuery(newReq(tq03 + ";"))
+	if err != nil {
+		t.Error(err)
+	} else if trq.Step.Seconds() != 60 {
+		t.Errorf("expected 60 got %f", trq.Step.Seconds())
+	}
+}
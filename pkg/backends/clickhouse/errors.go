@@ -33,3 +33,11 @@ var ErrUnsupportedToStartOfFunc = errors.New("unsupported ToStartOf* func")
 
 // ErrNotAtPreWhere indicates AtPreWhere was called but the current token is not of type tokenPreWhere
 var ErrNotAtPreWhere = errors.New("not at PREWHERE")
+
+// ErrHeterogeneousBatch indicates a batch of statements includes a statement that is not
+// a time series query, or whose shape or time range differs from the others in the batch
+var ErrHeterogeneousBatch = errors.New("batch statements are not identically-shaped time series queries")
+
+// ErrQueryBatch indicates the query is a homogeneous batch of statements, which is cached
+// as a whole by the object proxy cache, rather than merged by the delta proxy cache
+var ErrQueryBatch = errors.New("query is a batch of statements")
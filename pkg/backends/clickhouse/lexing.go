@@ -54,6 +54,9 @@ const (
 	tokenValBy         = "by"
 	tokenValWithTotals = "with totals"
 	tokenValInterval   = "interval"
+	tokenValFill       = "fill"
+	tokenValTo         = "to"
+	tokenValStep       = "step"
 )
 
 var chKey = map[string]token.Typ{
@@ -214,6 +214,14 @@ func marshalTimeseriesJSON(ds *dataset.DataSet, rlo *timeseries.RequestOptions,
 				if i >= len(p.Values) {
 					continue
 				}
+				// a nil value is a point inserted by a fill policy
+				if p.Values[i] == nil {
+					c[fd.OutputPosition] = col{
+						name: mds[fd.OutputPosition].name,
+						val:  "null",
+					}
+					continue
+				}
 				c[fd.OutputPosition] = col{
 					name: mds[fd.OutputPosition].name,
 					val:  p.Values[i].(string),
@@ -370,6 +378,10 @@ func marshalTimeseriesXSV(ds *dataset.DataSet, rlo *timeseries.RequestOptions,
 				if i >= len(p.Values) {
 					continue
 				}
+				if p.Values[i] == nil {
+					rowVals[fd.OutputPosition] = `\N`
+					continue
+				}
 				rowVals[fd.OutputPosition] = wrapCSVCell(p.Values[i].(string), tw.separator)
 			}
 			tw.Write([]byte(strings.Join(rowVals, tw.separator) + "\n"))
@@ -19,6 +19,7 @@ package model
 import (
 	"io"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -275,6 +276,25 @@ func TestMarshalTSVWithNamesAndTypes(t *testing.T) {
 	}
 }
 
+func TestMarshalNilValues(t *testing.T) {
+	ds := testDataset.Clone().(*dataset.DataSet)
+	ds.Results[0].SeriesList[0].Points[1].Values = []interface{}{nil, nil}
+
+	w := httptest.NewRecorder()
+	marshalTimeseriesTSV(ds, &timeseries.RequestOptions{OutputFormat: 3}, 200, w)
+	b, _ := io.ReadAll(w.Result().Body)
+	if !strings.Contains(string(b), "1577836860000\tlocalhost\t\\N\t\\N\n") {
+		t.Errorf("expected null values in %s", string(b))
+	}
+
+	w = httptest.NewRecorder()
+	marshalTimeseriesJSON(ds, &timeseries.RequestOptions{}, 200, w)
+	b, _ = io.ReadAll(w.Result().Body)
+	if !strings.Contains(string(b), `"avg_query": null`) {
+		t.Errorf("expected null values in %s", string(b))
+	}
+}
+
 func TestUnmarshalTimeseries(t *testing.T) {
 
 	ts, err := UnmarshalTimeseries([]byte(testDataTSVWithNamesAndTypes), testTRQ.Clone())
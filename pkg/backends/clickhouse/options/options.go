@@ -0,0 +1,32 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+// Options stores information about ClickHouse Options
+type Options struct {
+	// BatchQueries, when true, allows a request containing several semicolon-delimited
+	// statements to be cached when every statement is an identically-shaped time series
+	// query over the same time range
+	BatchQueries bool `yaml:"batch_queries,omitempty"`
+}
+
+// Clone returns an exact copy of the subject Options
+func (o *Options) Clone() *Options {
+	return &Options{
+		BatchQueries: o.BatchQueries,
+	}
+}
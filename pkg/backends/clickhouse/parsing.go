@@ -48,8 +48,9 @@ var parser = &chParser{
 			).
 			WithDecisions("SelectQueryKeywords",
 				parsing.DecisionSet{
-					tokenPreWhere: atPreWhere,
-					tokenFormat:   atFormat,
+					tokenPreWhere:     atPreWhere,
+					lsql.TokenOrderBy: atOrderBy,
+					tokenFormat:       atFormat,
 				},
 			),
 	).(*sqlparser.Parser),
@@ -80,11 +81,32 @@ func parse(statement string) (*timeseries.TimeRangeQuery, *timeseries.RequestOpt
 	if t, err = parseGroupByTokens(results, trq, ro); err != nil {
 		return nil, nil, canObjectCache, parsing.ParserError(err, t)
 	}
-	if t, err = parseSelectTokens(results, trq, ro); err != nil {
+	var wf *withFill
+	if wf, t, err = parseWithFillTokens(results, trq); err != nil {
 		return nil, nil, canObjectCache, parsing.ParserError(err, t)
 	}
+	if t, err = parseSelectTokens(results, trq, ro); err != nil {
+		// a WITH FILL modifier is an alternative to intDiv / toStartOf bucketing
+		if wf == nil || err != sqlparser.ErrMissingTimeseries {
+			return nil, nil, canObjectCache, parsing.ParserError(err, t)
+		}
+		ro.BaseTimestampFieldName = wf.field
+		trq.TimestampDefinition.Name = wf.field
+	}
+	if wf != nil {
+		if wf.step > 0 {
+			trq.Step = wf.step
+		} else if trq.Step == 0 {
+			// ClickHouse fills in increments of 1 (second) when STEP is omitted
+			trq.Step = time.Second
+		}
+	}
 	if t, err = parseWhereTokens(results, trq, ro); err != nil {
-		return nil, nil, canObjectCache, parsing.ParserError(err, t)
+		if wf == nil || wf.start.IsZero() ||
+			(err != sqlparser.ErrNotTimeRangeQuery && err != sqlparser.ErrNoLowerBound) {
+			return nil, nil, canObjectCache, parsing.ParserError(err, t)
+		}
+		trq.Extent = wf.extent(trq.Step)
 	}
 	return trq, ro, canObjectCache, nil
 }
@@ -94,6 +116,7 @@ const (
 	tkRange  = "<$RANGE$>"
 	tkTS1    = "<$TS1$>"
 	tkTS2    = "<$TS2$>"
+	tkFillTo = "<$FILLTO$>"
 	tkFormat = "<$FORMAT$>"
 )
 
@@ -265,6 +288,201 @@ func scanForCommentsUntilEOF(bp, ip parsing.Parser, rs *parsing.RunState) parsin
 	return nil
 }
 
+// atOrderBy is the ClickHouse variant of the ORDER BY state, which splits any
+// WITH FILL modifier from the ordering fields. The FROM keyword of the modifier
+// would otherwise be mistaken for an out-of-order FROM clause.
+func atOrderBy(bp, ip parsing.Parser, rs *parsing.RunState) parsing.StateFn {
+	p, ok := bp.(*chParser)
+	if !ok {
+		rs.WithError(parsing.ErrUnsupportedParser)
+		return nil
+	}
+	fl := p.GetFieldList(rs, lsql.TokenOrderBy, sql.ErrNotAtOrderBy,
+		token.IsComma, sql.DefaultIsBreakable, sql.DefaultIsContinuable, false)
+	for i, fieldParts := range fl {
+		j := withFillIndex(fieldParts)
+		if j < 0 {
+			continue
+		}
+		wf := append(make(token.Tokens, 0, 16), fieldParts[j+2:]...)
+		fl[i] = fieldParts[:j]
+		if i == len(fl)-1 && rs.Current().Typ == lsql.TokenFrom {
+			var more []token.Tokens
+			wf, more = collectWithFill(rs, wf)
+			fl = append(fl, more...)
+		}
+		rs.SetResultsCollection("withFillField", fl[i])
+		rs.SetResultsCollection("withFillTokens", wf)
+		break
+	}
+	rs.SetResultsCollection("orderByTokens", fl)
+	return rs.GetReturnFunc(nil, p.SelectQueryKeywords(), false)
+}
+
+// withFillIndex returns the index of the WITH token in a WITH FILL modifier
+// within the field, or -1 if the field has no such modifier
+func withFillIndex(fieldParts token.Tokens) int {
+	for i := 1; i < len(fieldParts)-1; i++ {
+		if fieldParts[i].Typ == lsql.TokenWith &&
+			fieldParts[i+1].Typ == token.Identifier && fieldParts[i+1].Val == tokenValFill {
+			return i
+		}
+	}
+	return -1
+}
+
+// collectWithFill appends the tokens of a WITH FILL modifier to wf, starting
+// from the current FROM token, until the next primary keyword. Any ORDER BY
+// fields that follow the modifier are returned separately.
+func collectWithFill(rs *parsing.RunState, wf token.Tokens) (token.Tokens, []token.Tokens) {
+	var more []token.Tokens
+	var field token.Tokens
+	var pd int
+	t := rs.Current()
+	for {
+		if t.Typ <= token.EOF {
+			break
+		}
+		if t.Typ == token.LeftParen {
+			pd++
+		} else if t.Typ == token.RightParen {
+			pd--
+		}
+		switch {
+		case sql.DefaultIsContinuable(t.Typ):
+		case pd == 0 && t.Typ != lsql.TokenFrom && sql.DefaultIsBreakable(t.Typ):
+			// like GetField, leave the RunState at the keyword
+			if len(field) > 0 {
+				more = append(more, field)
+			}
+			return wf, more
+		case pd == 0 && token.IsComma(t.Typ):
+			if len(field) > 0 {
+				more = append(more, field)
+			}
+			field = make(token.Tokens, 0, 8)
+		case field != nil:
+			field = append(field, t)
+		default:
+			wf = append(wf, t)
+		}
+		t = rs.Next()
+	}
+	if len(field) > 0 {
+		more = append(more, field)
+	}
+	return wf, more
+}
+
+// withFill represents the time series attributes of a WITH FILL modifier
+type withFill struct {
+	field      string
+	start, end time.Time
+	step       time.Duration
+}
+
+// extent returns the Extent described by the FROM and TO bounds of the
+// modifier. Since TO is exclusive, the last point is one step before it.
+func (wf *withFill) extent(step time.Duration) timeseries.Extent {
+	e := timeseries.Extent{Start: wf.start, End: wf.end}
+	if e.End.IsZero() {
+		e.End = time.Now()
+	} else {
+		e.End = e.End.Add(-step)
+	}
+	return e
+}
+
+// parseWithFillTokens parses the FROM, TO and STEP values of a WITH FILL
+// modifier, and tokenizes the FROM and TO values in the statement
+func parseWithFillTokens(results map[string]interface{},
+	trq *timeseries.TimeRangeQuery) (*withFill, *token.Token, error) {
+	v, ok := results["withFillTokens"]
+	if !ok {
+		return nil, nil, nil
+	}
+	wft, ok := v.(token.Tokens)
+	if !ok {
+		return nil, nil, nil
+	}
+	wf := &withFill{}
+	if v, ok = results["withFillField"]; ok {
+		if ft, ok := v.(token.Tokens); ok && len(ft) > 0 {
+			last := ft[len(ft)-1]
+			wf.field = trq.Statement[ft[0].Pos : last.Pos+len(last.Val)]
+		}
+	}
+	var from, to *token.Token
+	var state, x int
+	var isInterval bool
+	var err error
+	for _, t := range wft {
+		if t.Typ == token.LeftParen || t.Typ == token.RightParen ||
+			t.Typ == token.Space || t.Typ == lsql.TokenComment || t.Typ == tokenToDateFunc {
+			continue
+		}
+		switch {
+		case t.Typ == lsql.TokenFrom:
+			state = 1
+			continue
+		case t.Typ == token.Identifier && t.Val == tokenValTo:
+			state = 2
+			continue
+		case t.Typ == token.Identifier && t.Val == tokenValStep:
+			state = 3
+			continue
+		}
+		switch state {
+		case 1, 2: // FROM or TO time value
+			var ts time.Time
+			ts, _, err = lsql.TokenToTime(t)
+			if err != nil {
+				return nil, t, err
+			}
+			if state == 1 {
+				wf.start, from = ts, t
+			} else {
+				wf.end, to = ts, t
+			}
+			state = 0
+		case 3: // STEP as seconds or as an INTERVAL
+			if t.Typ == tokenInterval {
+				isInterval = true
+				continue
+			}
+			if isInterval && x > 0 {
+				d, ok := tokenDurations[t.Typ]
+				if !ok {
+					return nil, t, sqlparser.ErrStepParse
+				}
+				wf.step = d * time.Duration(x)
+				state = 0
+				continue
+			}
+			x, err = getInt(t)
+			if err != nil || x <= 0 {
+				return nil, t, sqlparser.ErrStepParse
+			}
+			if !isInterval {
+				wf.step = time.Duration(x) * time.Second
+				state = 0
+			}
+		}
+	}
+	if state == 3 {
+		return nil, nil, sqlparser.ErrStepParse
+	}
+	// the modifier follows the WHERE clause, so tokenizing by position, from
+	// the end, leaves the positions used by parseWhereTokens intact
+	if to != nil {
+		trq.Statement = trq.Statement[:to.Pos] + tkFillTo + trq.Statement[to.Pos+len(to.Val):]
+	}
+	if from != nil {
+		trq.Statement = trq.Statement[:from.Pos] + tkTS1 + trq.Statement[from.Pos+len(from.Val):]
+	}
+	return wf, nil, nil
+}
+
 func parseSelectTokens(results map[string]interface{},
 	trq *timeseries.TimeRangeQuery, ro *timeseries.RequestOptions) (*token.Token, error) {
 	if results == nil {
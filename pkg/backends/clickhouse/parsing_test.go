@@ -74,6 +74,16 @@ const tq08 = `SELECT (  intDiv(toUInt32(datetime), 300) * 300) * 1000 AS t,` +
 	` count() as cnt FROM test_db.test_table WHERE datetime >= now() - 900` +
 	` GROUP BY t ORDER BY  t DESC FORMAT JSON`
 
+const tq09 = `SELECT toStartOfMinute(time_column) AS t, countMerge(some_count) AS cnt, field1 ` +
+	`FROM testdb.test_table WHERE time_column BETWEEN toDateTime(1516665600) AND toDateTime(1516687200) ` +
+	`AND field1 > 0 GROUP BY t, field1 ORDER BY t WITH FILL FROM toDateTime(1516665600) ` +
+	`TO toDateTime(1516687200) STEP 60, field1 FORMAT JSON`
+
+const tq10 = `SELECT toDateTime(time_column) AS t, countMerge(some_count) AS cnt, field1 ` +
+	`FROM testdb.test_table WHERE field1 > 0 GROUP BY t, field1 ` +
+	`ORDER BY t WITH FILL FROM toDateTime(1516665600) TO toDateTime(1516687200) STEP INTERVAL 5 minute ` +
+	`FORMAT JSON`
+
 const bq00 = `SELECT toStartOfFiveMinute(datetime) AS t, l FROM table WHERE datetime BETWEEN 1 AND 5 LIMIT 10`
 
 func TestParseRawQuery(t *testing.T) {
@@ -90,6 +100,8 @@ func TestParseRawQuery(t *testing.T) {
 		{tq06, nil},
 		{tq07, nil},
 		{tq08, nil},
+		{tq09, nil},
+		{tq10, nil},
 		{bq00, ErrLimitUnsupported},
 	}
 	for i, test := range tests {
@@ -153,6 +165,69 @@ func TestGoodQueries(t *testing.T) {
 	}
 }
 
+func TestWithFillQueries(t *testing.T) {
+	tests := []struct {
+		query     string
+		step      time.Duration
+		start     int64
+		end       int64
+		statement string
+		err       error
+	}{
+		{ // WITH FILL alongside toStartOf bucketing and a WHERE time range
+			query: tq09,
+			step:  time.Minute,
+			start: 1516665600,
+			end:   1516687200,
+			statement: `SELECT toStartOfMinute(time_column) AS t, countMerge(some_count) AS cnt, field1 ` +
+				`FROM testdb.test_table WHERE <$RANGE$> AND field1 > 0 GROUP BY t, field1 ORDER BY t ` +
+				`WITH FILL FROM toDateTime(<$TS1$>) TO toDateTime(<$FILLTO$>) STEP 60, field1 FORMAT <$FORMAT$>`,
+		},
+		{ // WITH FILL as the only source of the extent, with an INTERVAL step
+			query: tq10,
+			step:  5 * time.Minute,
+			start: 1516665600,
+			end:   1516686900,
+			statement: `SELECT toDateTime(time_column) AS t, countMerge(some_count) AS cnt, field1 ` +
+				`FROM testdb.test_table WHERE field1 > 0 GROUP BY t, field1 ` +
+				`ORDER BY t WITH FILL FROM toDateTime(<$TS1$>) TO toDateTime(<$FILLTO$>) STEP INTERVAL 5 minute ` +
+				`FORMAT <$FORMAT$>`,
+		},
+		{ // WITH FILL without a FROM bound, or a WHERE time range, is not a time range
+			query: `SELECT t, count() AS cnt FROM test_db.test_table GROUP BY t ORDER BY t WITH FILL STEP 300`,
+			err:   sqlparser.ErrNotTimeRangeQuery,
+		},
+		{
+			query: `SELECT t, count() AS cnt FROM test_db.test_table GROUP BY t ` +
+				`ORDER BY t WITH FILL FROM 1516665600 TO 1516687200 STEP INTERVAL 5 fortnight`,
+			err: sqlparser.ErrStepParse,
+		},
+	}
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			trq, _, _, err := parse(test.query)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("got '%v' expected '%v'", err, test.err)
+			}
+			if err != nil {
+				return
+			}
+			if trq.Step != test.step {
+				t.Errorf("got %s expected %s", trq.Step, test.step)
+			}
+			if trq.Extent.Start.Unix() != test.start {
+				t.Errorf("got %d expected %d", trq.Extent.Start.Unix(), test.start)
+			}
+			if trq.Extent.End.Unix() != test.end {
+				t.Errorf("got %d expected %d", trq.Extent.End.Unix(), test.end)
+			}
+			if trq.Statement != test.statement {
+				t.Errorf("\nexpected [%s]\ngot      [%s]", test.statement, trq.Statement)
+			}
+		})
+	}
+}
+
 func TestParseErrors(t *testing.T) {
 	_, _, _, err := parse("")
 	if err != sqlparser.ErrNotTimeRangeQuery {
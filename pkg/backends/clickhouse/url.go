@@ -55,20 +55,23 @@ func (c *Client) SetExtent(r *http.Request, trq *timeseries.TimeRangeQuery, exte
 
 func interpolateTimeQuery(template string, tsFieldName string, timeFormat int, extent *timeseries.Extent, step time.Duration) string {
 
-	var start, end int64
+	var start, end, fillTo int64
 
 	switch timeFormat {
 	case 1:
 		start = extent.Start.UnixNano() / 1000000
 		end = extent.End.UnixNano() / 1000000
+		fillTo = end + step.Milliseconds()
 	default:
 		start = extent.Start.Unix()
 		end = extent.End.Unix()
+		fillTo = end + int64(step.Seconds())
 	}
 
 	rangeCondition := fmt.Sprintf("%s BETWEEN %d AND %d", tsFieldName, start, end)
-	x := strings.Replace(strings.Replace(strings.Replace(strings.Replace(template,
+	x := strings.Replace(strings.Replace(strings.Replace(strings.Replace(strings.Replace(template,
 		tkRange, rangeCondition, -1), tkTS1, strconv.FormatInt(start, 10), -1), tkTS2,
-		strconv.FormatInt(end, 10), -1), "<$FORMAT$>", "TSVWithNamesAndTypes", -1)
+		strconv.FormatInt(end, 10), -1), tkFillTo, strconv.FormatInt(fillTo, 10), -1),
+		"<$FORMAT$>", "TSVWithNamesAndTypes", -1)
 	return x
 }
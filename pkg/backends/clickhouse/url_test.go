@@ -58,3 +58,13 @@ func TestSetExtent(t *testing.T) {
 	}
 
 }
+
+func TestInterpolateTimeQueryWithFill(t *testing.T) {
+	e := &timeseries.Extent{Start: time.Unix(1516665600, 0), End: time.Unix(1516687200, 0)}
+	const template = `ORDER BY t WITH FILL FROM toDateTime(<$TS1$>) TO toDateTime(<$FILLTO$>) STEP 60`
+	// the fill upper bound is exclusive, so it must be one step past the extent end
+	const expected = `ORDER BY t WITH FILL FROM toDateTime(1516665600) TO toDateTime(1516687260) STEP 60`
+	if s := interpolateTimeQuery(template, "t", 0, e, time.Minute); s != expected {
+		t.Errorf("\nexpected [%s]\ngot      [%s]", expected, s)
+	}
+}
@@ -0,0 +1,185 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package healthcheck
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	ho "github.com/trickstercache/trickster/v2/pkg/backends/healthcheck/options"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+
+	"golang.org/x/net/http2"
+)
+
+// grpcHealthCheckPath is the path of the gRPC Health Checking Protocol's Check method
+const grpcHealthCheckPath = "/grpc.health.v1.Health/Check"
+
+// grpcServingStatus is the grpc.health.v1.HealthCheckResponse.ServingStatus enum
+type grpcServingStatus uint64
+
+const (
+	grpcStatusUnknown grpcServingStatus = iota
+	grpcStatusServing
+	grpcStatusNotServing
+	grpcStatusServiceUnknown
+)
+
+func (s grpcServingStatus) String() string {
+	switch s {
+	case grpcStatusUnknown:
+		return "UNKNOWN"
+	case grpcStatusServing:
+		return "SERVING"
+	case grpcStatusNotServing:
+		return "NOT_SERVING"
+	case grpcStatusServiceUnknown:
+		return "SERVICE_UNKNOWN"
+	}
+	return fmt.Sprintf("ServingStatus(%d)", uint64(s))
+}
+
+var errGRPCMalformedResponse = errors.New("malformed grpc health check response")
+
+// newGRPCRequest returns an HTTP/2 request that calls grpc.health.v1.Health/Check on
+// the Options' host, for the Options' service
+func newGRPCRequest(o *ho.Options) (*http.Request, error) {
+	u := &url.URL{Scheme: o.Scheme, Host: o.Host, Path: grpcHealthCheckPath}
+	r, err := http.NewRequest(http.MethodPost, u.String(),
+		bytes.NewReader(encodeGRPCHealthCheckRequest(o.Service)))
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set(headers.NameContentType, headers.ValueApplicationGRPC)
+	r.Header.Set(headers.NameTe, headers.ValueTrailers)
+	for k, v := range o.Headers {
+		r.Header.Set(k, v)
+	}
+	return r, nil
+}
+
+// encodeGRPCHealthCheckRequest returns a length-prefixed grpc message containing
+// the protobuf encoding of a grpc.health.v1.HealthCheckRequest
+func encodeGRPCHealthCheckRequest(service string) []byte {
+	var msg []byte
+	if service != "" {
+		msg = append(msg, 0x0a) // field 1 (service), wire type 2 (length-delimited)
+		msg = binary.AppendUvarint(msg, uint64(len(service)))
+		msg = append(msg, service...)
+	}
+	b := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(b[1:], uint32(len(msg)))
+	return append(b, msg...)
+}
+
+// decodeGRPCHealthCheckResponse returns the ServingStatus from a length-prefixed grpc
+// message containing the protobuf encoding of a grpc.health.v1.HealthCheckResponse
+func decodeGRPCHealthCheckResponse(b []byte) (grpcServingStatus, error) {
+	if len(b) < 5 || b[0] != 0 {
+		return grpcStatusUnknown, errGRPCMalformedResponse
+	}
+	l := binary.BigEndian.Uint32(b[1:5])
+	if uint32(len(b)-5) < l {
+		return grpcStatusUnknown, errGRPCMalformedResponse
+	}
+	msg := b[5 : 5+l]
+	var st grpcServingStatus
+	for len(msg) > 0 {
+		tag, n := binary.Uvarint(msg)
+		if n <= 0 {
+			return grpcStatusUnknown, errGRPCMalformedResponse
+		}
+		msg = msg[n:]
+		switch tag & 0x7 {
+		case 0: // varint
+			v, n := binary.Uvarint(msg)
+			if n <= 0 {
+				return grpcStatusUnknown, errGRPCMalformedResponse
+			}
+			msg = msg[n:]
+			if tag>>3 == 1 {
+				st = grpcServingStatus(v)
+			}
+		case 2: // length-delimited, which is skipped
+			v, n := binary.Uvarint(msg)
+			if n <= 0 || uint64(len(msg)-n) < v {
+				return grpcStatusUnknown, errGRPCMalformedResponse
+			}
+			msg = msg[n+int(v):]
+		default:
+			return grpcStatusUnknown, errGRPCMalformedResponse
+		}
+	}
+	return st, nil
+}
+
+// checkGRPCResponse returns an error describing why the grpc health check response does
+// not indicate a SERVING status, or nil if it does. The response body is consumed
+func checkGRPCResponse(resp *http.Response) error {
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected http status code [%d]", resp.StatusCode)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading response body from target: %w", err)
+	}
+	// grpc-status is sent in the trailers, or in the headers of a trailers-only response
+	gs := resp.Trailer.Get(headers.NameGRPCStatus)
+	if gs == "" {
+		gs = resp.Header.Get(headers.NameGRPCStatus)
+	}
+	if gs != "0" {
+		gm := resp.Trailer.Get(headers.NameGRPCMessage)
+		if gm == "" {
+			gm = resp.Header.Get(headers.NameGRPCMessage)
+		}
+		return fmt.Errorf("grpc status [%s] %s", gs, gm)
+	}
+	st, err := decodeGRPCHealthCheckResponse(b)
+	if err != nil {
+		return err
+	}
+	if st != grpcStatusServing {
+		return fmt.Errorf("grpc health status is %s", st)
+	}
+	return nil
+}
+
+// newGRPCClient returns an HTTP/2 client for grpc health checks. When cleartext is true,
+// the client uses unencrypted HTTP/2 (h2c), as expected by targets with an http scheme
+func newGRPCClient(timeout time.Duration, cleartext bool) *http.Client {
+	tr := &http2.Transport{}
+	if cleartext {
+		tr.AllowHTTP = true
+		tr.DialTLSContext = func(ctx context.Context, network, addr string,
+			_ *tls.Config) (net.Conn, error) {
+			return (&net.Dialer{KeepAlive: time.Duration(5) * time.Second}).
+				DialContext(ctx, network, addr)
+		}
+	}
+	return &http.Client{Timeout: timeout, Transport: tr}
+}
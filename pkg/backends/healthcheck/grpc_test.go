@@ -0,0 +1,199 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package healthcheck
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	ho "github.com/trickstercache/trickster/v2/pkg/backends/healthcheck/options"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// newStubGRPCHealthServer returns a cleartext HTTP/2 server implementing
+// grpc.health.v1.Health/Check, reporting the provided status for each service
+func newStubGRPCHealthServer(statuses map[string]grpcServingStatus,
+	delay time.Duration) *httptest.Server {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		if r.URL.Path != grpcHealthCheckPath ||
+			r.Header.Get(headers.NameContentType) != headers.ValueApplicationGRPC {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		var service string
+		if len(b) > 7 {
+			service = string(b[7:]) // skips the prefix, tag and (short) length
+		}
+		w.Header().Set(headers.NameContentType, headers.ValueApplicationGRPC)
+		st, ok := statuses[service]
+		if !ok {
+			// trailers-only response
+			w.Header().Set(headers.NameGRPCStatus, "5")
+			w.Header().Set(headers.NameGRPCMessage, "unknown service")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.Header().Set(headers.NameTrailer, headers.NameGRPCStatus)
+		w.WriteHeader(http.StatusOK)
+		msg := binary.AppendUvarint([]byte{0x08}, uint64(st))
+		out := make([]byte, 5, 5+len(msg))
+		binary.BigEndian.PutUint32(out[1:], uint32(len(msg)))
+		w.Write(append(out, msg...))
+		w.Header().Set(headers.NameGRPCStatus, "0")
+	})
+	return httptest.NewServer(h2c.NewHandler(h, &http2.Server{}))
+}
+
+func newTestGRPCTarget(t *testing.T, ts *httptest.Server, service string) *target {
+	o := ho.New()
+	o.Type = ho.ProbeTypeGRPC
+	o.Host = strings.TrimPrefix(ts.URL, "http://")
+	o.Service = service
+	o.TimeoutMS = 100
+	tgt, err := newTarget(context.Background(), "test", "test", o, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tgt.ctx = context.Background()
+	return tgt
+}
+
+func TestGRPCProbe(t *testing.T) {
+
+	ts := newStubGRPCHealthServer(map[string]grpcServingStatus{
+		"":     grpcStatusServing,
+		"up":   grpcStatusServing,
+		"down": grpcStatusNotServing,
+	}, 0)
+	defer ts.Close()
+
+	tests := []struct {
+		service        string
+		expectedStatus int
+		expectedDetail string
+	}{
+		{"", 1, ""},
+		{"up", 1, ""},
+		{"down", -1, "grpc health status is NOT_SERVING"},
+		{"missing", -1, "grpc status [5] unknown service"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.service, func(t *testing.T) {
+			tgt := newTestGRPCTarget(t, ts, test.service)
+			tgt.probe()
+			if v := tgt.status.Get(); v != test.expectedStatus {
+				t.Errorf("expected %d got %d", test.expectedStatus, v)
+			}
+			if tgt.status.detail != test.expectedDetail {
+				t.Errorf("expected %s got %s", test.expectedDetail, tgt.status.detail)
+			}
+		})
+	}
+}
+
+func TestGRPCProbeTimeout(t *testing.T) {
+
+	ts := newStubGRPCHealthServer(map[string]grpcServingStatus{"": grpcStatusServing},
+		300*time.Millisecond)
+	defer ts.Close()
+
+	tgt := newTestGRPCTarget(t, ts, "")
+	tgt.probe()
+	if v := tgt.status.Get(); v != -1 {
+		t.Errorf("expected %d got %d", -1, v)
+	}
+	if !strings.HasPrefix(tgt.status.detail, "error probing target") {
+		t.Errorf("unexpected detail: %s", tgt.status.detail)
+	}
+}
+
+func TestGRPCDemandProbe(t *testing.T) {
+
+	ts := newStubGRPCHealthServer(map[string]grpcServingStatus{
+		"up":   grpcStatusServing,
+		"down": grpcStatusNotServing,
+	}, 0)
+	defer ts.Close()
+
+	w := httptest.NewRecorder()
+	newTestGRPCTarget(t, ts, "up").demandProbe(w)
+	if w.Code != http.StatusOK || w.Body.String() != "SERVING" {
+		t.Errorf("expected %d %s got %d %s", http.StatusOK, "SERVING", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	newTestGRPCTarget(t, ts, "down").demandProbe(w)
+	const expected = "error performing health check: grpc health status is NOT_SERVING"
+	if w.Code != http.StatusInternalServerError || w.Body.String() != expected {
+		t.Errorf("expected %d %s got %d %s", http.StatusInternalServerError, expected,
+			w.Code, w.Body.String())
+	}
+}
+
+func TestNewTargetInvalidProbeType(t *testing.T) {
+	o := ho.New()
+	o.Type = "invalid"
+	_, err := newTarget(context.Background(), "test", "test", o, nil, nil)
+	if err != ho.ErrInvalidProbeType {
+		t.Errorf("expected %v got %v", ho.ErrInvalidProbeType, err)
+	}
+}
+
+func TestDecodeGRPCHealthCheckResponse(t *testing.T) {
+
+	tests := []struct {
+		b        []byte
+		expected grpcServingStatus
+		err      error
+	}{
+		{[]byte{0, 0, 0, 0, 2, 0x08, 0x01}, grpcStatusServing, nil},
+		{[]byte{0, 0, 0, 0, 0}, grpcStatusUnknown, nil},
+		// an unknown length-delimited field is skipped
+		{[]byte{0, 0, 0, 0, 5, 0x12, 0x01, 'x', 0x08, 0x02}, grpcStatusNotServing, nil},
+		{[]byte{0, 0, 0}, grpcStatusUnknown, errGRPCMalformedResponse},
+		{[]byte{1, 0, 0, 0, 2, 0x08, 0x01}, grpcStatusUnknown, errGRPCMalformedResponse},
+		{[]byte{0, 0, 0, 0, 9, 0x08, 0x01}, grpcStatusUnknown, errGRPCMalformedResponse},
+		{[]byte{0, 0, 0, 0, 2, 0x12, 0x05}, grpcStatusUnknown, errGRPCMalformedResponse},
+	}
+
+	for _, test := range tests {
+		st, err := decodeGRPCHealthCheckResponse(test.b)
+		if err != test.err {
+			t.Errorf("expected %v got %v", test.err, err)
+		}
+		if st != test.expected {
+			t.Errorf("expected %s got %s", test.expected, st)
+		}
+	}
+
+	b := encodeGRPCHealthCheckRequest("svc")
+	if string(b) != "\x00\x00\x00\x00\x05\x0a\x03svc" {
+		t.Errorf("unexpected request encoding %q", b)
+	}
+}
@@ -18,10 +18,13 @@ package healthcheck
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
 	ho "github.com/trickstercache/trickster/v2/pkg/backends/healthcheck/options"
+	tctx "github.com/trickstercache/trickster/v2/pkg/proxy/context"
 )
 
 // HealthChecker defines the Health Checker interface
@@ -131,3 +134,22 @@ func (hc *healthChecker) Probe(name string) *Status {
 func (hc *healthChecker) Statuses() StatusLookup {
 	return hc.statuses
 }
+
+// Check performs a single health check probe, without registering a target, and
+// returns an error describing why the probe failed, or nil if it passed
+func Check(ctx context.Context, name, description string, o *ho.Options,
+	client *http.Client) error {
+	t, err := newTarget(ctx, name, description, o, client, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := t.httpClient.Do(t.newRequest(tctx.WithHealthCheckFlag(ctx, true)))
+	if err != nil {
+		return fmt.Errorf("error probing target: %w", err)
+	}
+	defer resp.Body.Close()
+	if !t.isGoodResponse(resp) {
+		return errors.New(t.status.detail)
+	}
+	return nil
+}
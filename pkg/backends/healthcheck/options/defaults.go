@@ -19,6 +19,11 @@ package options
 import "net/http"
 
 const (
+	// ProbeTypeHTTP is the probe type for health checks made as plain HTTP requests
+	ProbeTypeHTTP = "http"
+	// ProbeTypeGRPC is the probe type for health checks made with the gRPC Health Checking Protocol
+	ProbeTypeGRPC = "grpc"
+
 	// DefaultHealthCheckPath is the default value (noop) for Backends' Health Check Path
 	DefaultHealthCheckPath = "/"
 	// DefaultHealthCheckQuery is the default value (noop) for Backends' Health Check Query Parameters
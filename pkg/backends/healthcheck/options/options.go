@@ -35,6 +35,9 @@ const MinProbeWaitMS = 100
 // ErrNoOptionsProvided returns an error for no health check options provided
 var ErrNoOptionsProvided = errors.New("no health check options provided")
 
+// ErrInvalidProbeType returns an error for an unsupported health check probe type
+var ErrInvalidProbeType = errors.New("invalid health check probe type")
+
 // Options defines Health Checking Options
 type Options struct {
 
@@ -47,6 +50,13 @@ type Options struct {
 	// mark an unavailable target as available
 	RecoveryThreshold int `yaml:"recovery_threshold,omitempty"`
 
+	// Type is the type of probe used to check the target, either http or grpc. A grpc probe
+	// calls the standard grpc.health.v1.Health/Check method on the target host
+	Type string `yaml:"type,omitempty"`
+	// Service is the service name sent in a grpc probe's health check request. When empty,
+	// the overall health of the gRPC server is checked
+	Service string `yaml:"service,omitempty"`
+
 	// Target Outbound Request Options
 	// Verb provides the HTTP verb to use when making an upstream health check
 	Verb string `yaml:"verb,omitempty"`
@@ -81,6 +91,7 @@ type Options struct {
 // New returns a new Options reference with default values
 func New() *Options {
 	return &Options{
+		Type:              ProbeTypeHTTP,
 		Verb:              DefaultHealthCheckVerb,
 		Scheme:            "http",
 		Headers:           make(map[string]string),
@@ -100,6 +111,8 @@ func (o *Options) SetMetaData(md yamlx.KeyLookup) {
 // Clone returns an exact copy of a *healthcheck.Options
 func (o *Options) Clone() *Options {
 	c := &Options{}
+	c.Type = o.Type
+	c.Service = o.Service
 	c.Verb = o.Verb
 	c.Scheme = o.Scheme
 	c.Host = o.Host
@@ -107,6 +120,9 @@ func (o *Options) Clone() *Options {
 	c.Query = o.Query
 	c.Body = o.Body
 	c.IntervalMS = o.IntervalMS
+	c.FailureThreshold = o.FailureThreshold
+	c.RecoveryThreshold = o.RecoveryThreshold
+	c.TimeoutMS = o.TimeoutMS
 	c.ExpectedBody = o.ExpectedBody
 	if o.Headers != nil {
 		c.Headers = headers.Lookup(o.Headers).Clone()
@@ -129,6 +145,12 @@ func (o *Options) Overlay(name string, custom *Options) {
 	if custom == nil || custom.md == nil {
 		return
 	}
+	if custom.md.IsDefined("backends", name, "healthcheck", "type") {
+		o.Type = custom.Type
+	}
+	if custom.md.IsDefined("backends", name, "healthcheck", "service") {
+		o.Service = custom.Service
+	}
 	if custom.md.IsDefined("backends", name, "healthcheck", "path") {
 		o.Path = custom.Path
 	}
@@ -45,11 +45,13 @@ func TestMetadata(t *testing.T) {
 func TestClone(t *testing.T) {
 	o := New()
 	o.Verb = "trickster"
+	o.Type = ProbeTypeGRPC
+	o.Service = "trickster"
 	o.ExpectedHeaders = map[string]string{}
 
 	o2 := o.Clone()
 
-	if o2.Verb != "trickster" {
+	if o2.Verb != "trickster" || o2.Type != ProbeTypeGRPC || o2.Service != "trickster" {
 		t.Error("clone mismatch")
 	}
 
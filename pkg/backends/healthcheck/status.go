@@ -35,6 +35,7 @@ type Status struct {
 	status       atomic.Int32
 	detail       string
 	failingSince time.Time
+	lastCheck    atomic.Int64
 	subscribers  []chan bool
 	mtx          sync.Mutex
 	prober       func(http.ResponseWriter)
@@ -98,6 +99,15 @@ func (s *Status) FailingSince() time.Time {
 	return s.failingSince
 }
 
+// LastCheck provides the time of the most recent probe, or the zero time if the
+// target has not been probed
+func (s *Status) LastCheck() time.Time {
+	if n := s.lastCheck.Load(); n > 0 {
+		return time.Unix(0, n)
+	}
+	return time.Time{}
+}
+
 // RegisterSubscriber registers a subscriber with the Status
 func (s *Status) RegisterSubscriber(ch chan bool) {
 	s.mtx.Lock()
@@ -56,6 +56,7 @@ type target struct {
 	ec                    []int
 	logger                interface{}
 	isInLoop              bool
+	isGRPC                bool
 }
 
 // DemandProbe defines a health check probe that makes an HTTP Request to the backend and writes the
@@ -71,16 +72,32 @@ func newTarget(ctx context.Context,
 	if o == nil {
 		return nil, ho.ErrNoOptionsProvided
 	}
-	var rd io.Reader
-	if o.Body != "" {
-		rd = bytes.NewReader([]byte(o.Body))
-	}
-	r, err := http.NewRequest(o.Verb, o.URL().String(), rd)
-	if err != nil {
-		return nil, err
-	}
-	if len(o.Headers) > 0 {
-		r.Header = headers.Lookup(o.Headers).ToHeader()
+	var r *http.Request
+	var err error
+	var isGRPC bool
+	switch o.Type {
+	case "", ho.ProbeTypeHTTP:
+		var rd io.Reader
+		if o.Body != "" {
+			rd = bytes.NewReader([]byte(o.Body))
+		}
+		r, err = http.NewRequest(o.Verb, o.URL().String(), rd)
+		if err != nil {
+			return nil, err
+		}
+		if len(o.Headers) > 0 {
+			r.Header = headers.Lookup(o.Headers).ToHeader()
+		}
+	case ho.ProbeTypeGRPC:
+		r, err = newGRPCRequest(o)
+		if err != nil {
+			return nil, err
+		}
+		// the provided client may not speak HTTP/2, so grpc targets always use their own
+		client = newGRPCClient(ho.CalibrateTimeout(o.TimeoutMS), r.URL.Scheme != "https")
+		isGRPC = true
+	default:
+		return nil, ho.ErrInvalidProbeType
 	}
 	interval := time.Duration(o.IntervalMS) * time.Millisecond
 	if client == nil {
@@ -102,6 +119,7 @@ func newTarget(ctx context.Context,
 		recoveryThreshold: o.RecoveryThreshold,
 		interval:          interval,
 		logger:            logger,
+		isGRPC:            isGRPC,
 	}
 	t.status = &Status{name: name, detail: isd, description: description, prober: t.demandProbe}
 	if len(o.ExpectedHeaders) > 0 {
@@ -151,6 +169,27 @@ func (t *target) isGoodCode(i int) bool {
 	return false
 }
 
+// isGoodResponse returns true if the probe response indicates the target is healthy
+func (t *target) isGoodResponse(resp *http.Response) bool {
+	if t.isGRPC {
+		if err := checkGRPCResponse(resp); err != nil {
+			t.status.detail = err.Error()
+			return false
+		}
+		return true
+	}
+	return t.isGoodCode(resp.StatusCode) && t.isGoodHeader(resp.Header) && t.isGoodBody(resp.Body)
+}
+
+// newRequest returns a copy of the target's base request with a fresh body
+func (t *target) newRequest(ctx context.Context) *http.Request {
+	r := t.baseRequest.Clone(ctx)
+	if r.GetBody != nil {
+		r.Body, _ = r.GetBody()
+	}
+	return r
+}
+
 func (t *target) isGoodBody(r io.ReadCloser) bool {
 	if !t.ceb {
 		return true
@@ -204,7 +243,7 @@ func (t *target) probeLoop() {
 }
 
 func (t *target) probe() {
-	r := t.baseRequest.Clone(t.ctx)
+	r := t.newRequest(t.ctx)
 	resp, err := t.httpClient.Do(r)
 	var errCnt, successCnt int
 	var passed bool
@@ -212,7 +251,7 @@ func (t *target) probe() {
 		t.status.detail = fmt.Sprintf("error probing target: %v", err)
 		errCnt = int(t.failConsecutiveCnt.Add(1))
 		t.successConsecutiveCnt.Store(0)
-	} else if !t.isGoodCode(resp.StatusCode) || !t.isGoodHeader(resp.Header) || !t.isGoodBody(resp.Body) {
+	} else if !t.isGoodResponse(resp) {
 		errCnt = int(t.failConsecutiveCnt.Add(1))
 		t.successConsecutiveCnt.Store(0)
 	} else {
@@ -221,6 +260,7 @@ func (t *target) probe() {
 		t.failConsecutiveCnt.Store(0)
 		passed = true
 	}
+	t.status.lastCheck.Store(time.Now().UnixNano())
 	if !passed && t.ks != -1 && (errCnt == t.failureThreshold || t.ks == 0) {
 		t.status.failingSince = time.Now()
 		t.status.Set(-1)
@@ -240,9 +280,24 @@ func (t *target) probe() {
 }
 
 func (t *target) demandProbe(w http.ResponseWriter) {
-	r := t.baseRequest.Clone(context.Background())
+	r := t.newRequest(context.Background())
 	resp, err := t.httpClient.Do(r)
 	h := w.Header()
+	if err == nil && t.isGRPC {
+		// the grpc response body is binary, so its outcome is written instead
+		err = checkGRPCResponse(resp)
+		if err == nil {
+			if t.status != nil && t.status.Get() != 0 {
+				sh := t.status.Headers()
+				for k := range sh {
+					h.Set(k, sh.Get(k))
+				}
+			}
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(grpcStatusServing.String()))
+			return
+		}
+	}
 	if err != nil {
 		if t.status != nil && t.status.Get() != 0 {
 			sh := t.status.Headers()
@@ -16,9 +16,28 @@
 
 package flux
 
-import "github.com/trickstercache/trickster/v2/pkg/timeseries"
+import (
+	"strings"
+	"time"
 
+	"github.com/trickstercache/trickster/v2/pkg/timeseries"
+)
+
+// tkRangeArgs is the token that replaces the arguments of the range() function
+// in a tokenized Flux statement
+const tkRangeArgs = "<$RANGE$>"
+
+// Query represents a parsed Flux script
 type Query struct {
 	Extent    timeseries.Extent
+	Step      time.Duration
 	Statement string
 }
+
+// InterpolateRange returns the tokenized statement with its range() arguments
+// set to the provided start and stop times
+func InterpolateRange(statement string, start, stop time.Time) string {
+	return strings.Replace(statement, tkRangeArgs, "start: "+
+		start.UTC().Format(time.RFC3339Nano)+", stop: "+
+		stop.UTC().Format(time.RFC3339Nano), 1)
+}
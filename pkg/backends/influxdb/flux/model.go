@@ -0,0 +1,404 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package flux
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+	"github.com/trickstercache/trickster/v2/pkg/timeseries"
+	"github.com/trickstercache/trickster/v2/pkg/timeseries/dataset"
+	"github.com/trickstercache/trickster/v2/pkg/timeseries/epoch"
+)
+
+// Output Formats for Annotated CSV responses
+const (
+	// OutputFormatAnnotated writes the #datatype, #group and #default annotations
+	OutputFormatAnnotated byte = iota
+	// OutputFormatPlain writes only the header row and the data rows
+	OutputFormatPlain
+)
+
+// column roles are stored in the ProviderData1 member of each FieldDefinition
+const (
+	columnValue = iota
+	columnGroup
+	columnResult
+	columnTable
+	columnStart
+	columnStop
+	columnTime
+)
+
+const (
+	annotationDatatype = "#datatype"
+	annotationGroup    = "#group"
+	annotationDefault  = "#default"
+	timeFormatRFC3339  = "dateTime:RFC3339"
+)
+
+var columnRoles = map[string]int{
+	"result": columnResult,
+	"table":  columnTable,
+	"_start": columnStart,
+	"_stop":  columnStop,
+	"_time":  columnTime,
+}
+
+var fieldDataTypes = map[string]timeseries.FieldDataType{
+	"long":    timeseries.Int64,
+	"double":  timeseries.Float64,
+	"boolean": timeseries.Bool,
+	"string":  timeseries.String,
+}
+
+// NewModeler returns a collection of modeling functions for Flux Annotated CSV
+// interoperability
+func NewModeler() *timeseries.Modeler {
+	return &timeseries.Modeler{
+		WireUnmarshalerReader: UnmarshalTimeseriesReader,
+		WireMarshaler:         MarshalTimeseries,
+		WireMarshalWriter:     MarshalTimeseriesWriter,
+		WireUnmarshaler:       UnmarshalTimeseries,
+		CacheMarshaler:        dataset.MarshalDataSet,
+		CacheUnmarshaler:      dataset.UnmarshalDataSet,
+	}
+}
+
+// tableSchema describes the columns of one annotated table block
+type tableSchema struct {
+	fields   []timeseries.FieldDefinition
+	defaults []string
+	timeCol  int
+}
+
+// UnmarshalTimeseries converts an Annotated CSV blob into a Timeseries
+func UnmarshalTimeseries(data []byte, trq *timeseries.TimeRangeQuery) (timeseries.Timeseries, error) {
+	return UnmarshalTimeseriesReader(bytes.NewReader(data), trq)
+}
+
+// UnmarshalTimeseriesReader converts an Annotated CSV blob into a Timeseries via io.Reader
+func UnmarshalTimeseriesReader(reader io.Reader, trq *timeseries.TimeRangeQuery) (timeseries.Timeseries, error) {
+
+	if trq == nil {
+		return nil, timeseries.ErrNoTimerangeQuery
+	}
+
+	r := &dataset.Result{
+		SeriesList: make([]*dataset.Series, 0, 8),
+	}
+	ds := &dataset.DataSet{
+		TimeRangeQuery: trq,
+		ExtentList:     timeseries.ExtentList{trq.Extent},
+		Results:        []*dataset.Result{r},
+	}
+
+	cr := csv.NewReader(reader)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = false
+
+	sl := dataset.SeriesLookup{}
+	var schema *tableSchema
+	var datatypes, groups, defaults []string
+	var key dataset.SeriesLookupKey
+
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rec) < 2 {
+			continue
+		}
+		switch rec[0] {
+		case annotationDatatype:
+			datatypes = rec
+			schema = nil
+			continue
+		case annotationGroup:
+			groups = rec
+			continue
+		case annotationDefault:
+			defaults = rec
+			continue
+		}
+		if schema == nil {
+			// this is the header row for the table block
+			schema, err = newTableSchema(rec, datatypes, groups, defaults)
+			if err != nil {
+				return nil, err
+			}
+			datatypes, groups, defaults = nil, nil, nil
+			continue
+		}
+		if len(rec) != len(schema.fields)+1 {
+			return nil, timeseries.ErrTableHeader
+		}
+
+		sh := dataset.SeriesHeader{
+			QueryStatement: trq.Statement,
+			Tags:           make(dataset.Tags),
+			FieldsList:     schema.fields,
+			TimestampIndex: schema.timeCol,
+		}
+		p := dataset.Point{Size: 12, Values: make([]interface{}, 0, len(rec))}
+		for i, fd := range schema.fields {
+			val := rec[i+1]
+			if val == "" {
+				val = schema.defaults[i]
+			}
+			switch fd.ProviderData1 {
+			case columnTime:
+				t, err := time.Parse(time.RFC3339Nano, val)
+				if err != nil {
+					return nil, timeseries.ErrInvalidTimeFormat
+				}
+				p.Epoch = epoch.Epoch(t.UnixNano())
+			case columnGroup, columnResult:
+				sh.Tags[fd.Name] = val
+			case columnValue:
+				v, sz, err := parseValue(val, fd.DataType)
+				if err != nil {
+					return nil, err
+				}
+				p.Values = append(p.Values, v)
+				p.Size += sz
+			}
+		}
+
+		sh.CalculateSize()
+		key.Hash = sh.CalculateHash()
+		s, ok := sl[key]
+		if !ok {
+			s = &dataset.Series{
+				Header: sh,
+				Points: make(dataset.Points, 0, 64),
+			}
+			sl[key] = s
+			r.SeriesList = append(r.SeriesList, s)
+		}
+		s.Points = append(s.Points, p)
+		s.PointSize += int64(p.Size)
+	}
+
+	for _, s := range r.SeriesList {
+		sort.Sort(s.Points)
+	}
+	return ds, nil
+}
+
+func newTableSchema(header, datatypes, groups, defaults []string) (*tableSchema, error) {
+	ts := &tableSchema{
+		fields:   make([]timeseries.FieldDefinition, len(header)-1),
+		defaults: make([]string, len(header)-1),
+		timeCol:  -1,
+	}
+	for i, name := range header[1:] {
+		fd := timeseries.FieldDefinition{Name: name, OutputPosition: i + 1}
+		if i+1 < len(datatypes) {
+			fd.SDataType = datatypes[i+1]
+			fd.DataType = fieldDataTypes[fd.SDataType]
+		}
+		if role, ok := columnRoles[name]; ok {
+			fd.ProviderData1 = role
+		} else if i+1 < len(groups) && groups[i+1] == "true" {
+			fd.ProviderData1 = columnGroup
+		}
+		if fd.ProviderData1 == columnTime {
+			ts.timeCol = i + 1
+		}
+		if i+1 < len(defaults) {
+			ts.defaults[i] = defaults[i+1]
+		}
+		ts.fields[i] = fd
+	}
+	if ts.timeCol == -1 {
+		return nil, timeseries.ErrTableHeader
+	}
+	return ts, nil
+}
+
+func parseValue(val string, dt timeseries.FieldDataType) (interface{}, int, error) {
+	if val == "" {
+		return nil, 0, nil
+	}
+	switch dt {
+	case timeseries.Int64:
+		i, err := strconv.ParseInt(val, 10, 64)
+		return i, 8, err
+	case timeseries.Float64:
+		f, err := strconv.ParseFloat(val, 64)
+		return f, 8, err
+	case timeseries.Bool:
+		b, err := strconv.ParseBool(val)
+		return b, 1, err
+	}
+	return val, len(val), nil
+}
+
+func formatValue(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case int64:
+		return strconv.FormatInt(t, 10)
+	case bool:
+		return strconv.FormatBool(t)
+	}
+	return fmt.Sprint(v)
+}
+
+// MarshalTimeseries converts a Timeseries into an Annotated CSV blob
+func MarshalTimeseries(ts timeseries.Timeseries, rlo *timeseries.RequestOptions, status int) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	err := MarshalTimeseriesWriter(ts, rlo, status, buf)
+	return buf.Bytes(), err
+}
+
+// MarshalTimeseriesWriter converts a Timeseries into an Annotated CSV blob via an io.Writer
+func MarshalTimeseriesWriter(ts timeseries.Timeseries, rlo *timeseries.RequestOptions,
+	status int, w io.Writer) error {
+
+	if ts == nil {
+		return timeseries.ErrUnknownFormat
+	}
+	ds, ok := ts.(*dataset.DataSet)
+	if !ok {
+		return timeseries.ErrUnknownFormat
+	}
+
+	if rw, ok := w.(http.ResponseWriter); ok {
+		rw.Header().Set(headers.NameContentType, "text/csv; charset=utf-8")
+		rw.WriteHeader(status)
+	}
+
+	var of byte
+	if rlo != nil {
+		of = rlo.OutputFormat
+	}
+
+	var extent timeseries.Extent
+	if ds.TimeRangeQuery != nil {
+		extent = ds.TimeRangeQuery.Extent
+	} else if len(ds.ExtentList) > 0 {
+		extent = timeseries.Extent{Start: ds.ExtentList[0].Start,
+			End: ds.ExtentList[len(ds.ExtentList)-1].End}
+	}
+	start := extent.Start.UTC().Format(time.RFC3339Nano)
+	stop := extent.End.UTC().Format(time.RFC3339Nano)
+
+	cw := csv.NewWriter(w)
+	cw.UseCRLF = true
+
+	var table int
+	var lastSchema string
+	for _, r := range ds.Results {
+		for _, s := range r.SeriesList {
+			if len(s.Points) == 0 {
+				continue
+			}
+			fl := s.Header.FieldsList
+			if sig := schemaSignature(fl); sig != lastSchema {
+				if lastSchema != "" {
+					cw.Flush()
+					w.Write([]byte("\r\n"))
+				}
+				lastSchema = sig
+				writeTableHeader(cw, fl, of)
+			}
+			st := strconv.Itoa(table)
+			for _, p := range s.Points {
+				rec := make([]string, len(fl)+1)
+				var vi int
+				for i, fd := range fl {
+					switch fd.ProviderData1 {
+					case columnTime:
+						rec[i+1] = time.Unix(0, int64(p.Epoch)).UTC().Format(time.RFC3339Nano)
+					case columnTable:
+						rec[i+1] = st
+					case columnStart:
+						rec[i+1] = start
+					case columnStop:
+						rec[i+1] = stop
+					case columnGroup, columnResult:
+						rec[i+1] = s.Header.Tags[fd.Name]
+					case columnValue:
+						if vi < len(p.Values) {
+							rec[i+1] = formatValue(p.Values[vi])
+						}
+						vi++
+					}
+				}
+				cw.Write(rec)
+			}
+			table++
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func schemaSignature(fl []timeseries.FieldDefinition) string {
+	var sb strings.Builder
+	for _, fd := range fl {
+		sb.WriteString(fd.Name + ":" + fd.SDataType + ":" +
+			strconv.Itoa(fd.ProviderData1) + ",")
+	}
+	return sb.String()
+}
+
+func writeTableHeader(cw *csv.Writer, fl []timeseries.FieldDefinition, of byte) {
+	l := len(fl) + 1
+	if of == OutputFormatAnnotated {
+		datatypes := make([]string, l)
+		groups := make([]string, l)
+		defaults := make([]string, l)
+		datatypes[0], groups[0], defaults[0] = annotationDatatype,
+			annotationGroup, annotationDefault
+		for i, fd := range fl {
+			datatypes[i+1] = fd.SDataType
+			if fd.ProviderData1 == columnTime && fd.SDataType == "" {
+				datatypes[i+1] = timeFormatRFC3339
+			}
+			groups[i+1] = strconv.FormatBool(fd.ProviderData1 == columnGroup ||
+				fd.ProviderData1 == columnStart || fd.ProviderData1 == columnStop)
+		}
+		cw.Write(datatypes)
+		cw.Write(groups)
+		cw.Write(defaults)
+	}
+	names := make([]string, l)
+	for i, fd := range fl {
+		names[i+1] = fd.Name
+	}
+	cw.Write(names)
+}
@@ -0,0 +1,125 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package flux
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/timeseries"
+	"github.com/trickstercache/trickster/v2/pkg/timeseries/dataset"
+)
+
+const testAnnotatedCSV = "#datatype,string,long,dateTime:RFC3339,dateTime:RFC3339,dateTime:RFC3339,double,string,string\r\n" +
+	"#group,false,false,true,true,false,false,true,true\r\n" +
+	"#default,_result,,,,,,,\r\n" +
+	",result,table,_start,_stop,_time,_value,_field,host\r\n" +
+	",,0,2023-01-01T00:00:00Z,2023-01-01T00:03:00Z,2023-01-01T00:02:00Z,2.5,usage,a\r\n" +
+	",,0,2023-01-01T00:00:00Z,2023-01-01T00:03:00Z,2023-01-01T00:01:00Z,1.5,usage,a\r\n" +
+	",,1,2023-01-01T00:00:00Z,2023-01-01T00:03:00Z,2023-01-01T00:01:00Z,,usage,b\r\n" +
+	"\r\n" +
+	"#datatype,string,long,dateTime:RFC3339,dateTime:RFC3339,dateTime:RFC3339,long,string,string\r\n" +
+	"#group,false,false,true,true,false,false,true,true\r\n" +
+	"#default,_result,,,,,,,\r\n" +
+	",result,table,_start,_stop,_time,_value,_field,host\r\n" +
+	",,2,2023-01-01T00:00:00Z,2023-01-01T00:03:00Z,2023-01-01T00:01:00Z,7,count,a\r\n"
+
+func testTRQ() *timeseries.TimeRangeQuery {
+	start := time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+	return &timeseries.TimeRangeQuery{
+		Extent: timeseries.Extent{Start: start, End: start.Add(3 * time.Minute)},
+		Step:   time.Minute,
+	}
+}
+
+func TestUnmarshalTimeseries(t *testing.T) {
+
+	_, err := UnmarshalTimeseries([]byte(testAnnotatedCSV), nil)
+	if err != timeseries.ErrNoTimerangeQuery {
+		t.Errorf("expected %v got %v", timeseries.ErrNoTimerangeQuery, err)
+	}
+
+	ts, err := UnmarshalTimeseries([]byte(testAnnotatedCSV), testTRQ())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds := ts.(*dataset.DataSet)
+	sl := ds.Results[0].SeriesList
+	if len(sl) != 3 {
+		t.Fatalf("expected %d got %d", 3, len(sl))
+	}
+	if sl[0].Header.Tags["host"] != "a" || sl[0].Header.Tags["result"] != "_result" {
+		t.Errorf("unexpected tags %v", sl[0].Header.Tags)
+	}
+	if len(sl[0].Points) != 2 || sl[0].Points[0].Values[0] != 1.5 {
+		t.Errorf("unexpected points %v", sl[0].Points)
+	}
+	if sl[1].Points[0].Values[0] != nil {
+		t.Errorf("expected nil value got %v", sl[1].Points[0].Values[0])
+	}
+	if sl[2].Points[0].Values[0] != int64(7) {
+		t.Errorf("expected %d got %v", 7, sl[2].Points[0].Values[0])
+	}
+
+	_, err = UnmarshalTimeseries([]byte("#datatype,string\r\n,result\r\n"), testTRQ())
+	if err != timeseries.ErrTableHeader {
+		t.Errorf("expected %v got %v", timeseries.ErrTableHeader, err)
+	}
+}
+
+func TestMarshalTimeseries(t *testing.T) {
+
+	_, err := MarshalTimeseries(nil, nil, 200)
+	if err != timeseries.ErrUnknownFormat {
+		t.Errorf("expected %v got %v", timeseries.ErrUnknownFormat, err)
+	}
+
+	ts, err := UnmarshalTimeseries([]byte(testAnnotatedCSV), testTRQ())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := MarshalTimeseries(ts, nil, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(b)
+	if !strings.Contains(out, ",_result,0,2023-01-01T00:00:00Z,2023-01-01T00:03:00Z,2023-01-01T00:01:00Z,1.5,usage,a\r\n") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+	if strings.Count(out, "#datatype") != 2 {
+		t.Errorf("expected %d annotation blocks got %d", 2, strings.Count(out, "#datatype"))
+	}
+
+	// the marshaled output must unmarshal back to the same series
+	ts2, err := UnmarshalTimeseries(b, testTRQ())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts2.SeriesCount() != ts.SeriesCount() || ts2.ValueCount() != ts.ValueCount() {
+		t.Errorf("expected %d/%d got %d/%d", ts.SeriesCount(), ts.ValueCount(),
+			ts2.SeriesCount(), ts2.ValueCount())
+	}
+
+	b, err = MarshalTimeseries(ts, &timeseries.RequestOptions{OutputFormat: OutputFormatPlain}, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "#") {
+		t.Errorf("expected no annotations got:\n%s", string(b))
+	}
+}
@@ -39,30 +39,67 @@ func NewParser(reader io.Reader) *Parser {
 	}
 }
 
+// ParseQuery reads the Flux script and returns its time range, aggregation step
+// and tokenized statement. Step is 0 when the script has no aggregateWindow().
 func (p *Parser) ParseQuery() (*Query, error) {
 	r := bufio.NewReader(p.reader)
 	q := &Query{}
-	if raw, err := io.ReadAll(r); err != nil {
+	raw, err := io.ReadAll(r)
+	if err != nil {
 		return nil, err
-	} else {
-		content := string(raw)
-		if idx := strings.Index(content, "|> range("); idx == -1 {
-			return nil, ErrFluxSyntax("range()", "flux timerange query scripts must contain a range() function")
-		} else {
-			q.Extent, err = parseRangeFilter(content, idx+len("|> range("))
-			if err != nil {
-				return nil, err
-			}
-		}
 	}
+	content := string(raw)
+	idx := strings.Index(content, "|> range(")
+	if idx == -1 {
+		return nil, ErrFluxSyntax("range()", "flux timerange query scripts must contain a range() function")
+	}
+	argsStart := idx + len("|> range(")
+	var argsEnd int
+	q.Extent, argsEnd, err = parseRangeFilter(content, argsStart)
+	if err != nil {
+		return nil, err
+	}
+	q.Step, err = parseAggregateWindow(content)
+	if err != nil {
+		return nil, err
+	}
+	q.Statement = content[:argsStart] + tkRangeArgs + content[argsEnd:]
 	return q, nil
 }
 
-// Parse a line that is a range filter range(start: $[start], stop: $[stop])
-func parseRangeFilter(query string, at int) (timeseries.Extent, error) {
+// parseAggregateWindow returns the every: argument of an aggregateWindow()
+// function, or 0 if the script does not contain one
+func parseAggregateWindow(query string) (time.Duration, error) {
+	idx := strings.Index(query, "aggregateWindow(")
+	if idx == -1 {
+		return 0, nil
+	}
+	args := query[idx+len("aggregateWindow("):]
+	if end := strings.Index(args, ")"); end != -1 {
+		args = args[:end]
+	}
+	i := strings.Index(args, "every:")
+	if i == -1 {
+		return 0, ErrFluxSemantics("aggregateWindow() expressions require an every argument")
+	}
+	arg := strings.TrimLeft(args[i+len("every:"):], " ")
+	if end := strings.IndexAny(arg, " ,"); end != -1 {
+		arg = arg[:end]
+	}
+	d, err := timeconv.ParseDuration(arg)
+	if err != nil {
+		return 0, err
+	}
+	return d, nil
+}
+
+// Parse a line that is a range filter range(start: $[start], stop: $[stop]),
+// returning the extent and the index of the closing parenthesis
+func parseRangeFilter(query string, at int) (timeseries.Extent, int, error) {
 	var start, stop time.Time
 	var err error
-	for i := at; i < len(query); {
+	i := at
+	for i < len(query) {
 		// If start: token at this index,
 		if token := tstrings.Substring(query, i, len("start:")); token == "start:" {
 			// find the start and end of the time argument
@@ -72,12 +109,12 @@ func parseRangeFilter(query string, at int) (timeseries.Extent, error) {
 			}
 			timeArgEnd := timeArgStart + strings.IndexAny(query[timeArgStart:], " ,)")
 			if timeArgEnd == -1 {
-				return timeseries.Extent{}, ErrFluxSyntax(query[timeArgStart:timeArgStart+10]+"...", "couldn't parse time field from start argument")
+				return timeseries.Extent{}, -1, ErrFluxSyntax(query[timeArgStart:timeArgStart+10]+"...", "couldn't parse time field from start argument")
 			}
 			// and try to parse that argument as a time field
 			start, err = tryParseTimeField(query[timeArgStart:timeArgEnd])
 			if err != nil {
-				return timeseries.Extent{}, err
+				return timeseries.Extent{}, -1, err
 			}
 			i = timeArgEnd
 			continue
@@ -90,12 +127,12 @@ func parseRangeFilter(query string, at int) (timeseries.Extent, error) {
 			}
 			timeArgEnd := timeArgStart + strings.IndexAny(query[timeArgStart:], " )")
 			if timeArgEnd == -1 {
-				return timeseries.Extent{}, ErrFluxSyntax(query[timeArgStart:timeArgStart+10]+"...", "couldn't parse time field from stop argument")
+				return timeseries.Extent{}, -1, ErrFluxSyntax(query[timeArgStart:timeArgStart+10]+"...", "couldn't parse time field from stop argument")
 			}
 			// and try to parse that argument as a time field
 			stop, err = tryParseTimeField(query[timeArgStart:timeArgEnd])
 			if err != nil {
-				return timeseries.Extent{}, err
+				return timeseries.Extent{}, -1, err
 			}
 			i = timeArgEnd
 			continue
@@ -107,9 +144,9 @@ func parseRangeFilter(query string, at int) (timeseries.Extent, error) {
 		i++
 	}
 	if start.IsZero() {
-		return timeseries.Extent{}, ErrFluxSemantics("range() expressions require a valid start argument")
+		return timeseries.Extent{}, -1, ErrFluxSemantics("range() expressions require a valid start argument")
 	}
-	return timeseries.Extent{Start: start, End: stop}, nil
+	return timeseries.Extent{Start: start, End: stop}, i, nil
 }
 
 func tryParseTimeField(s string) (time.Time, error) {
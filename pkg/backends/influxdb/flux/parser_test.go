@@ -21,6 +21,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/trickstercache/trickster/v2/pkg/timeseries"
 	"github.com/trickstercache/trickster/v2/pkg/util/timeconv"
 )
 
@@ -105,3 +106,49 @@ func TestUnixTime(t *testing.T) {
 		t.Errorf("query stop time incorrect; got %v, should be %v", q.Extent.End, stop)
 	}
 }
+
+func TestParseAggregateWindow(t *testing.T) {
+
+	script := `from(bucket: "test-bucket")
+	|> range(start: 2023-01-01T00:00:00Z, stop: 2023-01-02T00:00:00Z)
+	|> filter(fn: (r) => r._measurement == "cpu")
+	|> aggregateWindow(every: 5m, fn: mean)
+`
+	q, err := NewParser(strings.NewReader(script)).ParseQuery()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Step != 5*time.Minute {
+		t.Errorf("expected %s got %s", 5*time.Minute, q.Step)
+	}
+	if !strings.Contains(q.Statement, "range("+tkRangeArgs+")") {
+		t.Errorf("expected tokenized range in statement: %s", q.Statement)
+	}
+
+	start := time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC)
+	stop := start.Add(time.Hour)
+	out := InterpolateRange(q.Statement, start, stop)
+	q2, err := NewParser(strings.NewReader(out)).ParseQuery()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !q2.Extent.Start.Equal(start) || !q2.Extent.End.Equal(stop) {
+		t.Errorf("expected %s got %s", timeseries.Extent{Start: start, End: stop}, q2.Extent)
+	}
+
+	q, err = NewParser(strings.NewReader(testAbsoluteTime)).ParseQuery()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Step != 0 {
+		t.Errorf("expected %d got %s", 0, q.Step)
+	}
+
+	_, err = NewParser(strings.NewReader(`from(bucket: "test-bucket")
+	|> range(start: -1h)
+	|> aggregateWindow(fn: mean)
+`)).ParseQuery()
+	if err == nil {
+		t.Error("expected error for aggregateWindow without every")
+	}
+}
@@ -0,0 +1,158 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package influxdb
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/backends/influxdb/flux"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/engines"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/errors"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/handlers"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/request"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/urls"
+	"github.com/trickstercache/trickster/v2/pkg/timeseries"
+)
+
+// fluxAnnotations are the Annotated CSV annotations Trickster always requests
+// from the upstream, so that column types and group keys are known
+var fluxAnnotations = []string{"datatype", "group", "default"}
+
+// FluxHandler handles InfluxDB 2.x Flux query requests and processes them
+// through the delta proxy cache
+func (c *Client) FluxHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		c.ProxyHandler(w, r)
+		return
+	}
+	// storing the body in the request context allows it to be re-read for
+	// each upstream request the delta proxy cache makes
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		handlers.HandleBadRequestResponse(w, r)
+		return
+	}
+	r = request.SetBody(r, b)
+	r.URL = urls.BuildUpstreamURL(r, c.BaseUpstreamURL())
+	engines.DeltaProxyCacheRequest(w, r, c.fluxModeler)
+}
+
+func isFluxRequest(r *http.Request) bool {
+	return strings.HasSuffix(r.URL.Path, "/"+mnFluxQuery)
+}
+
+func isJSONBody(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get(headers.NameContentType),
+		headers.ValueApplicationJSON)
+}
+
+// fluxRequestDocument returns the JSON document for a Flux query request. Raw
+// application/vnd.flux bodies are returned as a document with only a query.
+func fluxRequestDocument(r *http.Request) (map[string]interface{}, error) {
+	b := request.GetBody(r)
+	if !isJSONBody(r) {
+		return map[string]interface{}{"query": string(b)}, nil
+	}
+	doc := make(map[string]interface{})
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// wantsAnnotations returns true if the request's dialect asks for annotations
+func wantsAnnotations(doc map[string]interface{}) bool {
+	d, ok := doc["dialect"].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	a, ok := d["annotations"].([]interface{})
+	return ok && len(a) > 0
+}
+
+// setFluxBody sets the upstream request body to a JSON document for the
+// provided script, requesting fully-annotated CSV output
+func setFluxBody(r *http.Request, script string) {
+	doc, err := fluxRequestDocument(r)
+	if err != nil {
+		doc = make(map[string]interface{})
+	}
+	doc["query"] = script
+	doc["dialect"] = map[string]interface{}{
+		"header":      true,
+		"delimiter":   ",",
+		"annotations": fluxAnnotations,
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return
+	}
+	r.Header.Set(headers.NameContentType, headers.ValueApplicationJSON)
+	r.Header.Set(headers.NameAccept, headers.ValueApplicationCSV)
+	request.SetBody(r, b)
+}
+
+// parseFluxTimeRangeQuery parses the TimeRangeQuery from an InfluxDB 2.x
+// Flux query request. Scripts without an aggregateWindow() have no step
+// and are proxied without caching.
+func (c *Client) parseFluxTimeRangeQuery(r *http.Request) (*timeseries.TimeRangeQuery,
+	*timeseries.RequestOptions, bool, error) {
+
+	doc, err := fluxRequestDocument(r)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	script, _ := doc["query"].(string)
+	if script == "" {
+		return nil, nil, false, errors.ErrNotTimeRangeQuery
+	}
+
+	fq, err := flux.NewParser(strings.NewReader(script)).ParseQuery()
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if fq.Step <= 0 {
+		return nil, nil, false, errors.ErrStepParse
+	}
+	if fq.Extent.End.IsZero() {
+		fq.Extent.End = time.Now()
+	}
+
+	trq := &timeseries.TimeRangeQuery{
+		Extent:      fq.Extent,
+		Step:        fq.Step,
+		Statement:   fq.Statement,
+		ParsedQuery: fq,
+		TemplateURL: urls.Clone(r.URL),
+	}
+	rlo := &timeseries.RequestOptions{}
+	if !wantsAnnotations(doc) {
+		rlo.OutputFormat = flux.OutputFormatPlain
+	}
+
+	// Swap in the Tokenized Script in the Template URL Params
+	qt := trq.TemplateURL.Query()
+	qt.Set(upFluxQuery, trq.Statement)
+	trq.TemplateURL.RawQuery = qt.Encode()
+
+	return trq, rlo, false, nil
+}
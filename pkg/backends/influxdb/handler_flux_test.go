@@ -0,0 +1,112 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package influxdb
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/backends/influxdb/flux"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/errors"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/request"
+	"github.com/trickstercache/trickster/v2/pkg/timeseries"
+)
+
+const testFluxScript = `from(bucket: "test-bucket")
+	|> range(start: 2023-01-01T00:00:00Z, stop: 2023-01-02T00:00:00Z)
+	|> aggregateWindow(every: 1m, fn: mean)`
+
+func newFluxRequest(body, contentType string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost,
+		"http://0/"+mnFluxQuery+"?org=test", strings.NewReader(body))
+	r.Header.Set(headers.NameContentType, contentType)
+	return r
+}
+
+func TestParseFluxTimeRangeQuery(t *testing.T) {
+
+	c := &Client{}
+
+	b, _ := json.Marshal(map[string]interface{}{"query": testFluxScript,
+		"dialect": map[string]interface{}{"annotations": []string{"datatype"}}})
+	trq, rlo, _, err := c.ParseTimeRangeQuery(newFluxRequest(string(b),
+		headers.ValueApplicationJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trq.Step != time.Minute {
+		t.Errorf("expected %s got %s", time.Minute, trq.Step)
+	}
+	if trq.Extent.End.Sub(trq.Extent.Start) != 24*time.Hour {
+		t.Errorf("expected %s got %s", 24*time.Hour, trq.Extent.End.Sub(trq.Extent.Start))
+	}
+	if rlo.OutputFormat != flux.OutputFormatAnnotated {
+		t.Errorf("expected %d got %d", flux.OutputFormatAnnotated, rlo.OutputFormat)
+	}
+	if v := trq.TemplateURL.Query(); v.Get(upFluxQuery) != trq.Statement || v.Get(upOrg) != "test" {
+		t.Errorf("unexpected template url %s", trq.TemplateURL.String())
+	}
+
+	_, rlo, _, err = c.ParseTimeRangeQuery(newFluxRequest(testFluxScript, "application/vnd.flux"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rlo.OutputFormat != flux.OutputFormatPlain {
+		t.Errorf("expected %d got %d", flux.OutputFormatPlain, rlo.OutputFormat)
+	}
+
+	_, _, _, err = c.ParseTimeRangeQuery(newFluxRequest(
+		`from(bucket: "test-bucket") |> range(start: -1h)`, "application/vnd.flux"))
+	if err != errors.ErrStepParse {
+		t.Errorf("expected %v got %v", errors.ErrStepParse, err)
+	}
+}
+
+func TestSetExtentFlux(t *testing.T) {
+
+	c := &Client{}
+	r := newFluxRequest(testFluxScript, "application/vnd.flux")
+	trq, _, _, err := c.ParseTimeRangeQuery(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC)
+	e := &timeseries.Extent{Start: start, End: start.Add(time.Hour)}
+	c.SetExtent(r, trq, e)
+
+	if r.Header.Get(headers.NameContentType) != headers.ValueApplicationJSON {
+		t.Errorf("expected %s got %s", headers.ValueApplicationJSON,
+			r.Header.Get(headers.NameContentType))
+	}
+	doc := make(map[string]interface{})
+	if err := json.Unmarshal(request.GetBody(r), &doc); err != nil {
+		t.Fatal(err)
+	}
+	q, _ := doc["query"].(string)
+	if !strings.Contains(q, "range(start: 2023-02-28T23:59:00Z, stop: 2023-03-01T01:01:00Z)") {
+		t.Errorf("unexpected interpolated query: %s", q)
+	}
+	if !wantsAnnotations(doc) {
+		t.Error("expected upstream dialect to request annotations")
+	}
+}
@@ -19,6 +19,7 @@ package influxdb
 import (
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -53,6 +54,10 @@ func (c *Client) QueryHandler(w http.ResponseWriter, r *http.Request) {
 	engines.DeltaProxyCacheRequest(w, r, c.Modeler())
 }
 
+// defaultChunkSize is the number of points per chunk InfluxDB uses for chunked
+// responses when the request does not provide a chunk_size
+const defaultChunkSize = 10000
+
 var epochToFlag = map[string]byte{
 	"ns": 1,
 	"u":  2, "µ": 2,
@@ -66,6 +71,10 @@ var epochToFlag = map[string]byte{
 func (c *Client) ParseTimeRangeQuery(r *http.Request) (*timeseries.TimeRangeQuery,
 	*timeseries.RequestOptions, bool, error) {
 
+	if isFluxRequest(r) {
+		return c.parseFluxTimeRangeQuery(r)
+	}
+
 	trq := &timeseries.TimeRangeQuery{Extent: timeseries.Extent{}}
 	rlo := &timeseries.RequestOptions{}
 
@@ -76,8 +85,18 @@ func (c *Client) ParseTimeRangeQuery(r *http.Request) (*timeseries.TimeRangeQuer
 		return nil, nil, false, errors.MissingURLParam(upQuery)
 	}
 
-	if b, ok := epochToFlag[v.Get(upEpoch)]; ok {
-		rlo.TimeFormat = b
+	if e := v.Get(upEpoch); e != "" {
+		// InfluxDB renders timestamps for any unrecognized epoch in nanoseconds
+		if rlo.TimeFormat = epochToFlag[e]; rlo.TimeFormat == 0 {
+			rlo.TimeFormat = epochToFlag["ns"]
+		}
+	}
+
+	if v.Get(upChunked) == "true" {
+		rlo.ChunkSize = defaultChunkSize
+		if n, err := strconv.Atoi(v.Get(upChunkSize)); err == nil && n > 0 {
+			rlo.ChunkSize = n
+		}
 	}
 
 	if v.Get(upPretty) == "true" {
@@ -107,6 +126,9 @@ func (c *Client) ParseTimeRangeQuery(r *http.Request) (*timeseries.TimeRangeQuer
 		trq.TemplateURL = urls.Clone(r.URL)
 		qt := url.Values(http.Header(v).Clone())
 		qt.Set(upQuery, trq.Statement)
+		if cacheError == nil {
+			qt.Del(upEpoch)
+		}
 		// Swap in the Tokenzed Query in the Url Params
 		trq.TemplateURL.RawQuery = qt.Encode()
 		return trq, rlo, cacheError != nil, cacheError
@@ -133,11 +155,16 @@ func (c *Client) ParseTimeRangeQuery(r *http.Request) (*timeseries.TimeRangeQuer
 		if err != nil {
 			cacheError = err
 		} else {
-			if trq.Step == -1 && step > 0 {
+			// the offset in GROUP BY time(interval, offset) shifts the bucket boundaries
+			offset, err := sel.GroupByOffset()
+			if err != nil {
+				cacheError = err
+			} else if trq.Step == -1 && step > 0 {
 				trq.Step = step
-			} else if trq.Step != step {
+				trq.StepOffset = offset
+			} else if trq.Step != step || trq.StepOffset != offset {
 				// this condition means multiple queries were present, and had
-				// different step widths
+				// different step widths or offsets
 				cacheError = errors.ErrStepParse
 			}
 		}
@@ -179,6 +206,13 @@ func (c *Client) ParseTimeRangeQuery(r *http.Request) (*timeseries.TimeRangeQuer
 	trq.TemplateURL = urls.Clone(r.URL)
 	qt := url.Values(http.Header(v).Clone())
 	qt.Set(upQuery, trq.Statement)
+	if cacheError == nil {
+		// delta proxy cached timestamps are fetched and stored in nanoseconds, and are
+		// rendered in the requested epoch on output, so the epoch does not vary the key.
+		// queries that fall back to the object proxy cache keep it, since their cached
+		// responses retain the origin's timestamp format
+		qt.Del(upEpoch)
+	}
 
 	// Swap in the Tokenzed Query in the Url Params
 	trq.TemplateURL.RawQuery = qt.Encode()
@@ -24,6 +24,7 @@ import (
 	"net/url"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/trickstercache/trickster/v2/pkg/proxy/errors"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/request"
@@ -103,6 +104,104 @@ func TestParseTimeRangeQuery(t *testing.T) {
 	}
 }
 
+func TestParseTimeRangeQueryGroupByOffset(t *testing.T) {
+
+	const where = `SELECT mean("value") FROM "cpu" WHERE time >= '2020-01-01T00:02:00Z' ` +
+		`AND time < '2020-01-01T01:00:00Z' `
+
+	tests := []struct {
+		query          string
+		expectedOffset time.Duration
+		expectedStart  string
+		expectedEnd    string
+		expectedErr    error
+	}{
+		{ // 0
+			query:         where + `GROUP BY time(5m)`,
+			expectedStart: "2020-01-01T00:00:00Z",
+			expectedEnd:   "2020-01-01T00:55:00Z",
+		},
+		{ // 1
+			query:          where + `GROUP BY time(5m, 30s)`,
+			expectedOffset: 30 * time.Second,
+			expectedStart:  "2020-01-01T00:00:30Z",
+			expectedEnd:    "2020-01-01T00:55:30Z",
+		},
+		{ // 2
+			query:          where + `GROUP BY time(5m, -30s)`,
+			expectedOffset: -30 * time.Second,
+			expectedStart:  "2019-12-31T23:59:30Z",
+			expectedEnd:    "2020-01-01T00:59:30Z",
+		},
+		{ // 3
+			query:          where + `GROUP BY time(5m, 7m)`,
+			expectedOffset: 2 * time.Minute,
+			expectedStart:  "2020-01-01T00:02:00Z",
+			expectedEnd:    "2020-01-01T00:57:00Z",
+		},
+		{ // 4
+			query:       where + `GROUP BY time(5m, 30s) ; ` + where + `GROUP BY time(5m)`,
+			expectedErr: errors.ErrStepParse,
+		},
+	}
+
+	client := &Client{}
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			v := url.Values{"q": {test.query}}
+			req, _ := http.NewRequest(http.MethodGet, "http://blah.com/?"+v.Encode(), nil)
+			trq, _, _, err := client.ParseTimeRangeQuery(req)
+			if err != test.expectedErr {
+				t.Fatalf("expected %v got %v", test.expectedErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if trq.Step != 5*time.Minute {
+				t.Errorf("expected %s got %s", 5*time.Minute, trq.Step)
+			}
+			if trq.StepOffset != test.expectedOffset {
+				t.Errorf("expected %s got %s", test.expectedOffset, trq.StepOffset)
+			}
+			trq.NormalizeExtent()
+			if s := trq.Extent.Start.UTC().Format(time.RFC3339); s != test.expectedStart {
+				t.Errorf("expected %s got %s", test.expectedStart, s)
+			}
+			if s := trq.Extent.End.UTC().Format(time.RFC3339); s != test.expectedEnd {
+				t.Errorf("expected %s got %s", test.expectedEnd, s)
+			}
+		})
+	}
+}
+
+func TestParseTimeRangeQueryChunked(t *testing.T) {
+
+	client := &Client{}
+	tests := []struct {
+		params   string
+		expected int
+	}{
+		{"", 0},
+		{"&chunked=true", defaultChunkSize},
+		{"&chunked=true&chunk_size=100", 100},
+		{"&chunked=true&chunk_size=x", defaultChunkSize},
+		{"&chunk_size=100", 0},
+	}
+	for _, test := range tests {
+		t.Run(test.params, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet,
+				"http://blah.com/?"+testRawQuery+test.params, nil)
+			_, rlo, _, err := client.ParseTimeRangeQuery(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rlo.ChunkSize != test.expected {
+				t.Errorf("expected %d got %d", test.expected, rlo.ChunkSize)
+			}
+		})
+	}
+}
+
 func TestQueryHandlerWithSelect(t *testing.T) {
 
 	backendClient, err := NewClient("test", nil, nil, nil, nil, nil)
@@ -186,6 +285,61 @@ func TestQueryHandlerNotSelect(t *testing.T) {
 	}
 }
 
+func TestParseTimeRangeQueryEpoch(t *testing.T) {
+
+	const dpcQuery = `SELECT mean("value") FROM "monthly"."rollup.1min" WHERE time >= now() - 6h ` +
+		`GROUP BY time(15s) fill(null)`
+	const opcQuery = `SELECT mean("value") FROM "monthly"."rollup.1min" GROUP BY time(15s) fill(null)`
+
+	parse := func(q, epoch string) (*url.URL, byte, error) {
+		req := &http.Request{Method: http.MethodGet, URL: &url.URL{Scheme: "https",
+			Host: "blah.com", Path: "/", RawQuery: url.Values(map[string][]string{
+				"q": {q}, "epoch": {epoch}}).Encode()}}
+		trq, rlo, _, err := (&Client{}).ParseTimeRangeQuery(req)
+		if trq == nil || rlo == nil {
+			return nil, 0, err
+		}
+		return trq.TemplateURL, rlo.TimeFormat, err
+	}
+
+	tests := []struct {
+		epoch      string
+		timeFormat byte
+	}{
+		{"ns", 1}, {"u", 2}, {"ms", 3}, {"s", 4}, {"h", 6},
+		{"us", 1}, // InfluxDB renders unrecognized epochs in nanoseconds
+	}
+	var dpcTemplate string
+	for _, test := range tests {
+		u, tf, err := parse(dpcQuery, test.epoch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tf != test.timeFormat {
+			t.Errorf("expected %d got %d for epoch %s", test.timeFormat, tf, test.epoch)
+		}
+		// delta proxy cached timestamps are rendered per request, so the epoch
+		// does not vary the cache key template
+		if u.Query().Has(upEpoch) {
+			t.Errorf("expected no epoch in the template url, got %s", u.RawQuery)
+		}
+		if dpcTemplate == "" {
+			dpcTemplate = u.RawQuery
+		} else if u.RawQuery != dpcTemplate {
+			t.Errorf("expected %s got %s", dpcTemplate, u.RawQuery)
+		}
+	}
+
+	// object proxy cached responses retain the origin's format, so the epoch is kept
+	u, _, err := parse(opcQuery, "ms")
+	if err != errors.ErrNotTimeRangeQuery {
+		t.Fatalf("expected %v got %v", errors.ErrNotTimeRangeQuery, err)
+	}
+	if v := u.Query().Get(upEpoch); v != "ms" {
+		t.Errorf("expected %s got %s", "ms", v)
+	}
+}
+
 func TestParseTimeRangeQueryMissingQuery(t *testing.T) {
 	expected := errors.MissingURLParam(upQuery).Error()
 	req := &http.Request{URL: &url.URL{
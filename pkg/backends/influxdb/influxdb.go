@@ -21,10 +21,12 @@ import (
 	"net/http"
 
 	"github.com/trickstercache/trickster/v2/pkg/backends"
+	"github.com/trickstercache/trickster/v2/pkg/backends/influxdb/flux"
 	modelflux "github.com/trickstercache/trickster/v2/pkg/backends/influxdb/model"
 	bo "github.com/trickstercache/trickster/v2/pkg/backends/options"
 	"github.com/trickstercache/trickster/v2/pkg/backends/providers/registration/types"
 	"github.com/trickstercache/trickster/v2/pkg/cache"
+	"github.com/trickstercache/trickster/v2/pkg/timeseries"
 )
 
 var _ backends.TimeseriesBackend = (*Client)(nil)
@@ -32,6 +34,7 @@ var _ backends.TimeseriesBackend = (*Client)(nil)
 // Client Implements the Proxy Client Interface
 type Client struct {
 	backends.TimeseriesBackend
+	fluxModeler *timeseries.Modeler
 }
 
 var _ types.NewBackendClientFunc = NewClient
@@ -42,7 +45,7 @@ func NewClient(name string, o *bo.Options, router http.Handler,
 	if o != nil {
 		o.FastForwardDisable = true
 	}
-	c := &Client{}
+	c := &Client{fluxModeler: flux.NewModeler()}
 	b, err := backends.NewTimeseriesBackend(name, o, c.RegisterHandlers,
 		router, cache, modelflux.NewModeler())
 	c.TimeseriesBackend = b
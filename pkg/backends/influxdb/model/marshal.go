@@ -61,6 +61,9 @@ func MarshalTimeseriesWriter(ts timeseries.Timeseries, rlo *timeseries.RequestOp
 	if !ok {
 		return timeseries.ErrUnknownFormat
 	}
+	if of == 0 && rlo != nil && rlo.ChunkSize > 0 {
+		marshaler = marshalTimeseriesJSONChunked
+	}
 	return marshaler(ds, rlo, status, w)
 }
 
@@ -73,6 +76,10 @@ func writeEpochTime(w io.Writer, epoch epoch.Epoch, m int64) {
 	w.Write([]byte(strconv.FormatInt(int64(epoch)/m, 10)))
 }
 
+// maxSafeJSONInt is the largest integer that a float64-based JSON client can
+// represent without loss of precision
+const maxSafeJSONInt = 1 << 53
+
 func writeValue(w io.Writer, v interface{}, nilVal string) {
 	if v == nil {
 		w.Write([]byte(nilVal))
@@ -84,6 +91,13 @@ func writeValue(w io.Writer, v interface{}, nilVal string) {
 		w.Write([]byte(strconv.FormatBool(t)))
 	case int64:
 		w.Write([]byte(strconv.FormatInt(t, 10)))
+	case uint64:
+		// values beyond 2^53 are quoted to avoid precision loss in JSON clients
+		if t > maxSafeJSONInt {
+			w.Write([]byte(`"` + strconv.FormatUint(t, 10) + `"`))
+		} else {
+			w.Write([]byte(strconv.FormatUint(t, 10)))
+		}
 	case int:
 		w.Write([]byte(strconv.Itoa(t)))
 	case float64:
@@ -107,6 +121,8 @@ func writeCSVValue(w io.Writer, v interface{}, nilVal string) {
 		w.Write([]byte(strconv.FormatBool(t)))
 	case int64:
 		w.Write([]byte(strconv.FormatInt(t, 10)))
+	case uint64:
+		w.Write([]byte(strconv.FormatUint(t, 10)))
 	case int:
 		w.Write([]byte(strconv.Itoa(t)))
 	case float64:
@@ -136,50 +152,7 @@ func marshalTimeseriesJSON(ds *dataset.DataSet, rlo *timeseries.RequestOptions,
 			if s == nil {
 				continue
 			}
-			if s.Header.Tags == nil {
-				s.Header.Tags = make(dataset.Tags)
-			}
-			w.Write([]byte(
-				fmt.Sprintf(`{"name":"%s","tags":%s,`, s.Header.Name,
-					s.Header.Tags.JSON())))
-			fl := len(s.Header.FieldsList)
-			l := fl + 1
-			cols := make([]string, l)
-			var j int
-			for _, f := range s.Header.FieldsList {
-				if j == s.Header.TimestampIndex {
-					cols[j] = "time"
-					j++
-				}
-				cols[j] = f.Name
-				j++
-			}
-			w.Write([]byte(
-				`"columns":["` + strings.Join(cols, `","`) + `"],"values":[`,
-			))
-			lp := len(s.Points) - 1
-			for j := range s.Points {
-				w.Write([]byte("["))
-				lv := len(s.Points[j].Values)
-				for n, v := range s.Points[j].Values {
-					if n == s.Header.TimestampIndex {
-						dw(w, s.Points[j].Epoch, multiplier)
-						if n < lv {
-							w.Write([]byte(","))
-						}
-						n++
-					}
-					writeValue(w, v, "null")
-					if n < lv {
-						w.Write([]byte(","))
-					}
-				}
-				w.Write([]byte("]"))
-				if j < lp {
-					w.Write([]byte(","))
-				}
-			}
-			w.Write([]byte("]}"))
+			writeSeriesJSON(w, s, s.Points, dw, multiplier, false)
 			if si < ls-1 {
 				w.Write([]byte(","))
 			}
@@ -193,6 +166,117 @@ func marshalTimeseriesJSON(ds *dataset.DataSet, rlo *timeseries.RequestOptions,
 	return nil
 }
 
+// writeSeriesJSON writes the series header and the provided points as a series
+// object, which is marked partial when more of the series' points follow in a later chunk
+func writeSeriesJSON(w io.Writer, s *dataset.Series, pts dataset.Points,
+	dw dateWriter, multiplier int64, partial bool) {
+	if s.Header.Tags == nil {
+		s.Header.Tags = make(dataset.Tags)
+	}
+	w.Write([]byte(
+		fmt.Sprintf(`{"name":"%s","tags":%s,`, s.Header.Name,
+			s.Header.Tags.JSON())))
+	fl := len(s.Header.FieldsList)
+	l := fl + 1
+	cols := make([]string, l)
+	var j int
+	for _, f := range s.Header.FieldsList {
+		if j == s.Header.TimestampIndex {
+			cols[j] = "time"
+			j++
+		}
+		cols[j] = f.Name
+		j++
+	}
+	w.Write([]byte(
+		`"columns":["` + strings.Join(cols, `","`) + `"],"values":[`,
+	))
+	lp := len(pts) - 1
+	for j := range pts {
+		w.Write([]byte("["))
+		lv := len(pts[j].Values)
+		for n, v := range pts[j].Values {
+			if n == s.Header.TimestampIndex {
+				dw(w, pts[j].Epoch, multiplier)
+				if n < lv {
+					w.Write([]byte(","))
+				}
+				n++
+			}
+			writeValue(w, v, "null")
+			if n < lv {
+				w.Write([]byte(","))
+			}
+		}
+		w.Write([]byte("]"))
+		if j < lp {
+			w.Write([]byte(","))
+		}
+	}
+	w.Write([]byte("]"))
+	if partial {
+		w.Write([]byte(`,"partial":true`))
+	}
+	w.Write([]byte("}"))
+}
+
+// marshalTimeseriesJSONChunked writes the DataSet in InfluxDB's chunked JSON format: a
+// stream of newline-delimited documents, each with up to rlo.ChunkSize points of a single
+// series. Results and series continuing into a later chunk are marked partial.
+func marshalTimeseriesJSONChunked(ds *dataset.DataSet, rlo *timeseries.RequestOptions,
+	status int, w io.Writer) error {
+	if ds == nil {
+		return nil
+	}
+	if rw, ok := w.(http.ResponseWriter); ok {
+		h := rw.Header()
+		h.Set(headers.NameContentType, headers.ValueApplicationJSON+"; charset=UTF-8")
+		rw.WriteHeader(status)
+	}
+	dw, multiplier := getDateWriter(rlo)
+	writeResult := func(id int, s *dataset.Series, pts dataset.Points, seriesPartial, partial bool) {
+		w.Write([]byte(fmt.Sprintf(`{"results":[{"statement_id":%d`, id)))
+		if s != nil {
+			w.Write([]byte(`,"series":[`))
+			writeSeriesJSON(w, s, pts, dw, multiplier, seriesPartial)
+			w.Write([]byte("]"))
+		}
+		if partial {
+			w.Write([]byte(`,"partial":true`))
+		}
+		w.Write([]byte("}]}\n"))
+	}
+	for _, r := range ds.Results {
+		if r == nil {
+			continue
+		}
+		sl := make([]*dataset.Series, 0, len(r.SeriesList))
+		for _, s := range r.SeriesList {
+			if s != nil {
+				sl = append(sl, s)
+			}
+		}
+		if len(sl) == 0 {
+			writeResult(r.StatementID, nil, nil, false, false)
+			continue
+		}
+		for si, s := range sl {
+			lp := len(s.Points)
+			// a series with no points is still written, in a single chunk
+			for start := 0; start == 0 || start < lp; start += rlo.ChunkSize {
+				end := start + rlo.ChunkSize
+				if end > lp {
+					end = lp
+				}
+				seriesPartial := end < lp
+				writeResult(r.StatementID, s, s.Points[start:end], seriesPartial,
+					seriesPartial || si < len(sl)-1)
+			}
+		}
+	}
+	return nil
+}
+
 func marshalTimeseriesJSONPretty(ds *dataset.DataSet, rlo *timeseries.RequestOptions, status int, w io.Writer) error {
 
 	if ds == nil {
@@ -18,6 +18,7 @@ package model
 
 import (
 	"io"
+	"math"
 	"net/http/httptest"
 	"strconv"
 	"strings"
@@ -111,6 +112,51 @@ func TestWriteEpochTime(t *testing.T) {
 
 }
 
+func TestMarshalTimeseriesMergedEpochs(t *testing.T) {
+
+	// a cached chunk and a fresh fetch are both ingested in nanoseconds, regardless of
+	// the epoch requested by the clients that populated them
+	const cached = `{"results":[{"statement_id":0,"series":[` +
+		`{"name":"trickster","columns":["time","value"],` +
+		`"values":[[1577836800000000000,1],[1577836815000000000,2]]}]}]}`
+	const fresh = `{"results":[{"statement_id":0,"series":[` +
+		`{"name":"trickster","columns":["time","value"],` +
+		`"values":[[1577836815000000000,2],[1577836830123456789,3]]}]}]}`
+
+	trq := &timeseries.TimeRangeQuery{Statement: "hello"}
+	ts, err := UnmarshalTimeseries([]byte(cached), trq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts2, err := UnmarshalTimeseries([]byte(fresh), trq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.Merge(true, ts2)
+
+	tests := []struct {
+		timeFormat byte
+		expected   string
+	}{
+		{1, `[[1577836800000000000,1],[1577836815000000000,2],[1577836830123456789,3]]`},
+		{2, `[[1577836800000000,1],[1577836815000000,2],[1577836830123456,3]]`},
+		{3, `[[1577836800000,1],[1577836815000,2],[1577836830123,3]]`},
+		{4, `[[1577836800,1],[1577836815,2],[1577836830,3]]`},
+	}
+	for _, test := range tests {
+		t.Run(strconv.Itoa(int(test.timeFormat)), func(t *testing.T) {
+			w := httptest.NewRecorder()
+			rlo := &timeseries.RequestOptions{TimeFormat: test.timeFormat}
+			if err := MarshalTimeseriesWriter(ts, rlo, 200, w); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(w.Body.String(), `"values":`+test.expected) {
+				t.Errorf("expected values %s got %s", test.expected, w.Body.String())
+			}
+		})
+	}
+}
+
 func TestWriteValue(t *testing.T) {
 
 	tests := []struct {
@@ -155,6 +201,18 @@ func TestWriteValue(t *testing.T) {
 			expectedErr: nil,
 			expectedVal: `1.1`,
 		},
+		{ // 6
+			val:         uint64(1 << 53),
+			nilVal:      "",
+			expectedErr: nil,
+			expectedVal: `9007199254740992`,
+		},
+		{ // 7
+			val:         uint64(math.MaxUint64),
+			nilVal:      "",
+			expectedErr: nil,
+			expectedVal: `"18446744073709551615"`,
+		},
 	}
 
 	for i, test := range tests {
@@ -222,6 +280,12 @@ func TestWriteCSVValue(t *testing.T) {
 			expectedErr: nil,
 			expectedVal: `1.1`,
 		},
+		{ // 6
+			val:         uint64(math.MaxUint64),
+			nilVal:      "",
+			expectedErr: nil,
+			expectedVal: `18446744073709551615`,
+		},
 	}
 
 	for i, test := range tests {
@@ -278,3 +342,55 @@ func TestGetDateWriter(t *testing.T) {
 		t.Errorf("expected %d got %d", 1, m)
 	}
 }
+
+func TestMarshalTimeseriesChunked(t *testing.T) {
+
+	trq := &timeseries.TimeRangeQuery{
+		Statement: "hello",
+	}
+	ts, err := UnmarshalTimeseries([]byte(testDocChunked01), trq)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rlo := &timeseries.RequestOptions{TimeFormat: 1, ChunkSize: 2}
+	w := httptest.NewRecorder()
+	err = MarshalTimeseriesWriter(ts, rlo, 200, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(w.Header().Get(headers.NameContentType), headers.ValueApplicationJSON) {
+		t.Error("expected JSON content type header")
+	}
+
+	// the 3-point trickster series is split into 2 chunks, followed by 1 chunk
+	// each for the trickster2 series and the second statement
+	chunks := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+	if len(chunks) != 4 {
+		t.Fatalf("expected %d got %d:\n%s", 4, len(chunks), w.Body.String())
+	}
+	expected := []string{
+		`{"results":[{"statement_id":0,"series":[{"name":"trickster","tags":{},` +
+			`"columns":["time","value"],"values":[[1577836800000,0.484],[1577836815000,0.452]],` +
+			`"partial":true}],"partial":true}]}`,
+		`{"results":[{"statement_id":0,"series":[{"name":"trickster","tags":{},` +
+			`"columns":["time","value"],"values":[[1577836830000,0.41]]}],"partial":true}]}`,
+	}
+	for i, e := range expected {
+		if chunks[i] != e {
+			t.Errorf("chunk %d: expected\n%s\ngot\n%s", i, e, chunks[i])
+		}
+	}
+	if strings.Contains(chunks[2], "partial") || strings.Contains(chunks[3], "partial") {
+		t.Error("expected final chunks of each statement to not be partial")
+	}
+
+	// the chunked output should reassemble into the same dataset
+	ts2, err := UnmarshalTimeseries(w.Body.Bytes(), trq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts2.ValueCount() != ts.ValueCount() {
+		t.Errorf("expected %d got %d", ts.ValueCount(), ts2.ValueCount())
+	}
+}
@@ -34,6 +34,38 @@ type WFResult struct {
 	StatementID int          `json:"statement_id"`
 	SeriesList  []models.Row `json:"series,omitempty"`
 	Err         string       `json:"error,omitempty"`
+	Partial     bool         `json:"partial,omitempty"`
+}
+
+// mergeChunk appends the next document of a chunked response to the document. A
+// chunk's result continues the preceding result when that result is partial, and a
+// chunk's series continues the preceding series when that series is partial.
+func (d *WFDocument) mergeChunk(chunk *WFDocument) {
+	if chunk.Err != "" {
+		d.Err = chunk.Err
+	}
+	for _, r := range chunk.Results {
+		l := len(d.Results)
+		if l == 0 || !d.Results[l-1].Partial || d.Results[l-1].StatementID != r.StatementID {
+			d.Results = append(d.Results, r)
+			continue
+		}
+		pr := &d.Results[l-1]
+		pr.Partial = r.Partial
+		if r.Err != "" {
+			pr.Err = r.Err
+		}
+		for _, s := range r.SeriesList {
+			sl := len(pr.SeriesList)
+			if sl > 0 && pr.SeriesList[sl-1].Partial && pr.SeriesList[sl-1].SameSeries(&s) {
+				ps := &pr.SeriesList[sl-1]
+				ps.Values = append(ps.Values, s.Values...)
+				ps.Partial = s.Partial
+				continue
+			}
+			pr.SeriesList = append(pr.SeriesList, s)
+		}
+	}
 }
 
 var epochMultipliers = map[byte]int64{
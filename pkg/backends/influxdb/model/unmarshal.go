@@ -21,7 +21,9 @@ import (
 	"encoding/json"
 	"io"
 	"sort"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/trickstercache/trickster/v2/pkg/timeseries"
 	"github.com/trickstercache/trickster/v2/pkg/timeseries/dataset"
@@ -44,10 +46,23 @@ func UnmarshalTimeseriesReader(reader io.Reader, trq *timeseries.TimeRangeQuery)
 	}
 	wfd := &WFDocument{}
 	d := json.NewDecoder(reader)
+	// numbers are decoded as json.Number so that nanosecond timestamps, which exceed
+	// float64 precision, are ingested exactly
+	d.UseNumber()
 	err := d.Decode(wfd)
 	if err != nil {
 		return nil, err
 	}
+	// chunked responses are a stream of documents, which are reassembled into one.
+	// if the final chunk is still partial (e.g., the server truncated the response),
+	// the data received so far is used, as it would be for an unchunked response
+	for d.More() {
+		chunk := &WFDocument{}
+		if err = d.Decode(chunk); err != nil {
+			return nil, err
+		}
+		wfd.mergeChunk(chunk)
+	}
 	ds := &dataset.DataSet{
 		Error:          wfd.Err,
 		TimeRangeQuery: trq,
@@ -148,13 +163,9 @@ func UnmarshalTimeseriesReader(reader io.Reader, trq *timeseries.TimeRangeQuery)
 func pointFromValues(v []interface{}, tsIndex int) (dataset.Point,
 	[]timeseries.FieldDataType, error) {
 	p := dataset.Point{}
-	ns, ok := v[tsIndex].(int64)
-	if !ok {
-		fns, ok := v[tsIndex].(float64)
-		if !ok {
-			return p, nil, timeseries.ErrInvalidTimeFormat
-		}
-		ns = int64(fns)
+	ns, err := timestampFromValue(v[tsIndex])
+	if err != nil {
+		return p, nil, err
 	}
 	p.Values = append(make([]interface{}, 0, len(v)-1), v[:tsIndex]...)
 	p.Values = append(p.Values, v[tsIndex+1:]...)
@@ -172,9 +183,30 @@ func pointFromValues(v []interface{}, tsIndex int) (dataset.Point,
 		case bool:
 			fdts[x] = timeseries.Bool
 			p.Size++
+		case json.Number:
+			// InfluxDB's JSON output does not distinguish integers from floats, so only
+			// integers too large for an int64 are kept as unsigned to avoid precision loss
+			if _, err := t.Int64(); err != nil {
+				if u, err := strconv.ParseUint(string(t), 10, 64); err == nil {
+					p.Values[x] = u
+					fdts[x] = timeseries.Uint64
+					p.Size += 8
+					continue
+				}
+			}
+			f, err := t.Float64()
+			if err != nil {
+				return p, nil, timeseries.ErrInvalidTimeFormat
+			}
+			p.Values[x] = f
+			fdts[x] = timeseries.Float64
+			p.Size += 8
 		case int64, int:
 			fdts[x] = timeseries.Int64
 			p.Size += 8
+		case uint64:
+			fdts[x] = timeseries.Uint64
+			p.Size += 8
 		case float64, float32:
 			fdts[x] = timeseries.Float64
 			p.Size += 8
@@ -184,3 +216,31 @@ func pointFromValues(v []interface{}, tsIndex int) (dataset.Point,
 	}
 	return p, fdts, nil
 }
+
+// timestampFromValue normalizes a wire format timestamp to epoch nanoseconds. Numeric
+// timestamps are expected in nanoseconds, as the upstream request sets epoch=ns, while
+// string timestamps are parsed as RFC3339
+func timestampFromValue(v interface{}) (int64, error) {
+	switch t := v.(type) {
+	case json.Number:
+		if ns, err := t.Int64(); err == nil {
+			return ns, nil
+		}
+		f, err := t.Float64()
+		if err != nil {
+			return 0, timeseries.ErrInvalidTimeFormat
+		}
+		return int64(f), nil
+	case int64:
+		return t, nil
+	case float64:
+		return int64(t), nil
+	case string:
+		ts, err := time.Parse(time.RFC3339Nano, t)
+		if err != nil {
+			return 0, timeseries.ErrInvalidTimeFormat
+		}
+		return ts.UnixNano(), nil
+	}
+	return 0, timeseries.ErrInvalidTimeFormat
+}
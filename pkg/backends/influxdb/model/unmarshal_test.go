@@ -17,10 +17,13 @@
 package model
 
 import (
+	"encoding/json"
+	"math"
 	"strings"
 	"testing"
 
 	"github.com/trickstercache/trickster/v2/pkg/timeseries"
+	"github.com/trickstercache/trickster/v2/pkg/timeseries/dataset"
 )
 
 const testDoc01 = `{"results":[{"statement_id":0,"series":[` +
@@ -45,6 +48,71 @@ const testDocInvalid01 = `{"results":[{"statement_id":0,"series":[` +
 	`{"name":"trickster","columns":["time","value"],` +
 	`"values":[["z",0]]}]}]}`
 
+// testDocChunked01 is a chunked response of 2 statements, where the first statement's
+// trickster series is split across 2 chunks
+const testDocChunked01 = `{"results":[{"statement_id":0,"series":[` +
+	`{"name":"trickster","columns":["time","value"],` +
+	`"values":[[1577836800000,0.484],[1577836815000,0.452]],"partial":true}],"partial":true}]}` + "\n" +
+	`{"results":[{"statement_id":0,"series":[` +
+	`{"name":"trickster","columns":["time","value"],` +
+	`"values":[[1577836830000,0.410]]}],"partial":true}]}` + "\n" +
+	`{"results":[{"statement_id":0,"series":[` +
+	`{"name":"trickster2","columns":["time","value"],` +
+	`"values":[[1577836800000,0.484]]}]}]}` + "\n" +
+	`{"results":[{"statement_id":1,"series":[` +
+	`{"name":"trickster","columns":["time","value"],` +
+	`"values":[[1577836800000,0.484]]}]}]}` + "\n"
+
+// testDocChunked02 is a chunked response whose final chunk is still partial
+const testDocChunked02 = `{"results":[{"statement_id":0,"series":[` +
+	`{"name":"trickster","columns":["time","value"],` +
+	`"values":[[1577836800000,0.484]],"partial":true}],"partial":true}]}` + "\n" +
+	`{"results":[{"statement_id":0,"series":[` +
+	`{"name":"trickster","columns":["time","value"],` +
+	`"values":[[1577836815000,0.452]],"partial":true}],"partial":true}]}` + "\n"
+
+func TestUnmarshalTimeseriesChunked(t *testing.T) {
+
+	trq := &timeseries.TimeRangeQuery{
+		Statement: "hello",
+	}
+
+	ts, err := UnmarshalTimeseries([]byte(testDocChunked01), trq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds := ts.(*dataset.DataSet)
+	if len(ds.Results) != 2 {
+		t.Fatalf("expected %d got %d", 2, len(ds.Results))
+	}
+	if len(ds.Results[0].SeriesList) != 2 {
+		t.Fatalf("expected %d got %d", 2, len(ds.Results[0].SeriesList))
+	}
+	if n := len(ds.Results[0].SeriesList[0].Points); n != 3 {
+		t.Errorf("expected %d got %d", 3, n)
+	}
+	if n := len(ds.Results[1].SeriesList); n != 1 {
+		t.Errorf("expected %d got %d", 1, n)
+	}
+
+	ts, err = UnmarshalTimeseries([]byte(testDocChunked02), trq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds = ts.(*dataset.DataSet)
+	if len(ds.Results) != 1 || len(ds.Results[0].SeriesList) != 1 {
+		t.Fatal("expected a single result with a single series")
+	}
+	if n := len(ds.Results[0].SeriesList[0].Points); n != 2 {
+		t.Errorf("expected %d got %d", 2, n)
+	}
+
+	_, err = UnmarshalTimeseries([]byte(testDocChunked01+"{"), trq)
+	if err == nil {
+		t.Error("expected error for truncated chunk")
+	}
+}
+
 func TestUnmarshalTimeseries(t *testing.T) {
 
 	_, err := UnmarshalTimeseries([]byte(testDoc01), nil)
@@ -93,9 +161,67 @@ func TestPointFromValues(t *testing.T) {
 		t.Error(err)
 	}
 
+	v[0] = json.Number("1577836830123456789")
+	v[1] = json.Number("0.484")
+	p, _, err := pointFromValues(v, 0)
+	if err != nil {
+		t.Error(err)
+	}
+	if p.Epoch != 1577836830123456789 {
+		t.Errorf("expected %d got %d", int64(1577836830123456789), p.Epoch)
+	}
+	if f, ok := p.Values[0].(float64); !ok || f != 0.484 {
+		t.Errorf("expected %f got %v", 0.484, p.Values[0])
+	}
+
+	v[0] = "2020-01-01T00:00:30.123456789Z"
+	p, _, err = pointFromValues(v, 0)
+	if err != nil {
+		t.Error(err)
+	}
+	if p.Epoch != 1577836830123456789 {
+		t.Errorf("expected %d got %d", int64(1577836830123456789), p.Epoch)
+	}
+
 	v[1] = &v[5] // this tests unsupported value types
 	_, _, err = pointFromValues(v, 0)
 	if err != timeseries.ErrInvalidTimeFormat {
 		t.Error("expected ErrInvalidTimeFormat, got", err)
 	}
 }
+
+func TestUnmarshalTimeseriesUint64(t *testing.T) {
+
+	const doc = `{"results":[{"statement_id":0,"series":[` +
+		`{"name":"trickster","columns":["time","value"],` +
+		`"values":[[1577836800000,18446744073709551615],[1577836815000,1]]}]}]}`
+
+	ts, err := UnmarshalTimeseries([]byte(doc), &timeseries.TimeRangeQuery{Statement: "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pts := ts.(*dataset.DataSet).Results[0].SeriesList[0].Points
+	if v, ok := pts[0].Values[0].(uint64); !ok || v != math.MaxUint64 {
+		t.Errorf("expected %d got %v", uint64(math.MaxUint64), pts[0].Values[0])
+	}
+	if v, ok := pts[1].Values[0].(float64); !ok || v != 1 {
+		t.Errorf("expected %f got %v", 1.0, pts[1].Values[0])
+	}
+
+	// values beyond 2^53 are quoted in JSON output, and written as-is in CSV output
+	for _, test := range []struct {
+		format   byte
+		expected string
+	}{
+		{0, `"18446744073709551615"`},
+		{2, "18446744073709551615\n"},
+	} {
+		b, err := MarshalTimeseries(ts, &timeseries.RequestOptions{OutputFormat: test.format}, 200)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(b), test.expected) {
+			t.Errorf("expected %s in %s", test.expected, string(b))
+		}
+	}
+}
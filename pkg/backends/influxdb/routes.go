@@ -32,6 +32,7 @@ func (c *Client) RegisterHandlers(map[string]http.Handler) {
 			// and are able to be referenced by name (map key) in Config Files
 			"health": http.HandlerFunc(c.HealthHandler),
 			"query":  http.HandlerFunc(c.QueryHandler),
+			"flux":   http.HandlerFunc(c.FluxHandler),
 			"proxy":  http.HandlerFunc(c.ProxyHandler),
 		},
 	)
@@ -45,7 +46,16 @@ func (c *Client) DefaultPathConfigs(o *bo.Options) map[string]*po.Options {
 			Path:            "/" + mnQuery,
 			HandlerName:     mnQuery,
 			Methods:         []string{http.MethodGet, http.MethodPost},
-			CacheKeyParams:  []string{upDB, upQuery, "u", "p"},
+			CacheKeyParams:  []string{upDB, upQuery, upEpoch, "u", "p"},
+			CacheKeyHeaders: []string{},
+			MatchTypeName:   "exact",
+			MatchType:       matching.PathMatchTypeExact,
+		},
+		"/" + mnFluxQuery: {
+			Path:            "/" + mnFluxQuery,
+			HandlerName:     "flux",
+			Methods:         []string{http.MethodPost},
+			CacheKeyParams:  []string{upOrg, upOrgID, upFluxQuery},
 			CacheKeyHeaders: []string{},
 			MatchTypeName:   "exact",
 			MatchType:       matching.PathMatchTypeExact,
@@ -20,6 +20,7 @@ import (
 	"net/http"
 
 	"github.com/influxdata/influxql"
+	"github.com/trickstercache/trickster/v2/pkg/backends/influxdb/flux"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/params"
 	"github.com/trickstercache/trickster/v2/pkg/timeseries"
@@ -27,16 +28,23 @@ import (
 
 // Upstream Endpoints
 const (
-	mnQuery = "query"
+	mnQuery     = "query"
+	mnFluxQuery = "api/v2/query"
 )
 
 // Common URL Parameter Names
 const (
-	upQuery   = "q"
-	upDB      = "db"
-	upEpoch   = "epoch"
-	upPretty  = "pretty"
-	upChunked = "chunked"
+	upQuery     = "q"
+	upDB        = "db"
+	upEpoch     = "epoch"
+	upPretty    = "pretty"
+	upChunked   = "chunked"
+	upChunkSize = "chunk_size"
+	upOrg       = "org"
+	upOrgID     = "orgID"
+	// upFluxQuery only exists in the TemplateURL of a Flux request, where it
+	// carries the tokenized script for cache key derivation
+	upFluxQuery = "query"
 )
 
 // SetExtent will change the upstream request query to use the provided Extent
@@ -51,6 +59,20 @@ func (c *Client) SetExtent(r *http.Request, trq *timeseries.TimeRangeQuery, exte
 		trq.ParsedQuery = t2.ParsedQuery
 	}
 
+	if fq, ok := trq.ParsedQuery.(*flux.Query); ok {
+		// the range is widened by one step on each side so that aggregate
+		// windows timestamped by either their start or stop are included
+		script := flux.InterpolateRange(fq.Statement,
+			extent.Start.Add(-trq.Step), extent.End.Add(trq.Step))
+		if isFluxRequest(r) {
+			setFluxBody(r, script)
+			return
+		}
+		v.Set(upQuery, script)
+		params.SetRequestValues(r, v)
+		return
+	}
+
 	q, ok := trq.ParsedQuery.(*influxql.Query)
 	if !ok {
 		return
@@ -66,7 +88,8 @@ func (c *Client) SetExtent(r *http.Request, trq *timeseries.TimeRangeQuery, exte
 
 	v.Set(upQuery, q.String())
 	v.Set(upEpoch, "ns") // request nanosecond epoch timestamp format from server
-	v.Del(upChunked)     // we do not support chunked output or handling chunked server responses
+	v.Del(upChunked)     // responses are re-chunked for the client, per the request options
+	v.Del(upChunkSize)
 	v.Del(upPretty)
 	r.Header.Set(headers.NameAccept, headers.ValueApplicationJSON)
 	params.SetRequestValues(r, v)
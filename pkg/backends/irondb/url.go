@@ -81,5 +81,5 @@ func (c *Client) ParseTimeRangeQuery(
 		err = terr.ErrNotTimeRangeQuery
 	}
 	rsc.TimeRangeQuery = trq
-	return trq, nil, true, err
+	return trq, &timeseries.RequestOptions{}, true, err
 }
@@ -28,6 +28,7 @@ import (
 
 	"github.com/trickstercache/trickster/v2/cmd/trickster/config"
 	"github.com/trickstercache/trickster/v2/pkg/backends/irondb/common"
+	bo "github.com/trickstercache/trickster/v2/pkg/backends/options"
 	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/errors"
 	po "github.com/trickstercache/trickster/v2/pkg/proxy/paths/options"
@@ -333,4 +334,20 @@ func TestParseTimerangeQuery(t *testing.T) {
 	if err == nil || err != expected {
 		t.Errorf("expected %s got %v", expected.Error(), err.Error())
 	}
+
+	// a parsed query returns request options, which the delta proxy cache requires
+	o := bo.New()
+	o.Paths = client.DefaultPathConfigs(o)
+	r, _ = http.NewRequest(http.MethodGet,
+		"http://127.0.0.1/rollup/00112233-4455-6677-8899-aabbccddeeff/metric"+
+			"?start_ts=0&end_ts=900&rollup_span=300s&type=average", nil)
+	r = request.SetResources(r, request.NewResources(o, &po.Options{HandlerName: "RollupHandler"},
+		nil, nil, client, nil, tl.ConsoleLogger("error")))
+	trq, ro, _, err := client.ParseTimeRangeQuery(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trq == nil || ro == nil {
+		t.Errorf("expected time range query and request options got %v %v", trq, ro)
+	}
 }
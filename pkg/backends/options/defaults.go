@@ -57,6 +57,43 @@ const (
 	DefaultForwardedHeaders = "standard"
 	// DefaullALBMechansimName defines the default ALB Mechanism Name
 	DefaullALBMechansimName = "rr" // round robin
+	// DefaultHealthCheckCritical is the default criticality of a Backend's health check
+	// to the application-wide health check
+	DefaultHealthCheckCritical = true
+	// DefaultCircuitBreakerWindowMS is the default window within which consecutive upstream
+	// failures are counted toward tripping the circuit breaker
+	DefaultCircuitBreakerWindowMS = 10000
+	// DefaultCircuitBreakerCooldownMS is the default time an open circuit breaker waits
+	// before permitting a trial request upstream
+	DefaultCircuitBreakerCooldownMS = 30000
+	// DefaultCircuitBreakerStatusCode is the default HTTP status returned to clients for
+	// requests fast-failed by an open circuit breaker
+	DefaultCircuitBreakerStatusCode = 503
+	// DefaultUpstreamQueueTimeoutMS is the default time a request waits for an upstream
+	// slot when the backend is at its max_concurrent_upstream limit
+	DefaultUpstreamQueueTimeoutMS = 5000
+	// DefaultMaxRangesPerRequest is the default maximum number of byte ranges permitted
+	// in a client request's Range header
+	DefaultMaxRangesPerRequest = 100
+	// DefaultMaxRangesStatusCode is the default HTTP status returned to clients for
+	// requests exceeding the maximum number of byte ranges
+	DefaultMaxRangesStatusCode = 416
+	// DefaultMaxResponseBytesStatusCode is the default HTTP status returned to clients for
+	// requests whose upstream response exceeds the maximum response size
+	DefaultMaxResponseBytesStatusCode = 502
+	// DefaultMaxRedirects is the default maximum number of upstream redirects followed
+	// for a single request, when following redirects is enabled
+	DefaultMaxRedirects = 5
+	// DefaultCacheBypassHeader is the default name of the request header used by clients
+	// to bypass the cache read, when permitted by the backend
+	DefaultCacheBypassHeader = "X-Trickster-Bypass-Cache"
+	// DefaultPathRewriteStopOnMatch is the default behavior of applying only the first
+	// matching path rewrite rule
+	DefaultPathRewriteStopOnMatch = true
+	// DefaultShadowSampleRate is the default fraction of requests mirrored to a shadow origin
+	DefaultShadowSampleRate = 1.0
+	// DefaultShadowTimeoutMS is the default time to wait for a shadow origin to respond
+	DefaultShadowTimeoutMS = 10000
 	// DefaultTimeseriesShardSize defines the default shard size of 0 (no sharding)
 	DefaultTimeseriesShardSize = 0
 	// DefaultTimeseriesShardStep defines the default shard step of 0 (no sharding)
@@ -34,6 +34,9 @@ var ErrInvalidMaxShardSizeMS = errors.New(
 var ErrInvalidMaxShardSize = errors.New(
 	"'shard_max_size_ms' and 'shard_max_size_points' cannot both be non-zero")
 
+// errEmptyPathRewriteRule is the cause of an ErrInvalidPathRewriteRule for a nil rule
+var errEmptyPathRewriteRule = errors.New("empty rule")
+
 // ErrMissingProvider is an error type for missing provider
 type ErrMissingProvider struct {
 	error
@@ -141,3 +144,377 @@ func NewErrInvalidRewriterName(rewriterName, backendName string) error {
 	}
 	return e
 }
+
+// ErrInvalidBackfillTolerance is an error type for an invalid backfill_tolerance duration
+type ErrInvalidBackfillTolerance struct {
+	error
+}
+
+// NewErrInvalidBackfillTolerance returns a new invalid backfill tolerance error
+func NewErrInvalidBackfillTolerance(value, backendName string) error {
+	var e *ErrInvalidBackfillTolerance = &ErrInvalidBackfillTolerance{
+		error: fmt.Errorf(`invalid backfill_tolerance "%s" provided in backend options "%s"`,
+			value, backendName),
+	}
+	return e
+}
+
+// ErrInvalidUpstreamHost is an error type for an invalid upstream_hosts entry
+type ErrInvalidUpstreamHost struct {
+	error
+}
+
+// NewErrInvalidUpstreamHost returns a new invalid upstream host error
+func NewErrInvalidUpstreamHost(host, backendName string) error {
+	var e *ErrInvalidUpstreamHost = &ErrInvalidUpstreamHost{
+		error: fmt.Errorf(`invalid upstream host "%s" provided in backend options "%s"`,
+			host, backendName),
+	}
+	return e
+}
+
+// ErrInvalidUpstreamHostHeader is an error type for an invalid upstream_host_header
+type ErrInvalidUpstreamHostHeader struct {
+	error
+}
+
+// NewErrInvalidUpstreamHostHeader returns a new invalid upstream host header error
+func NewErrInvalidUpstreamHostHeader(host, backendName string) error {
+	var e *ErrInvalidUpstreamHostHeader = &ErrInvalidUpstreamHostHeader{
+		error: fmt.Errorf(`invalid upstream host header "%s" provided in backend options "%s"`,
+			host, backendName),
+	}
+	return e
+}
+
+// ErrInvalidCircuitBreakerStatusCode is an error type for an invalid circuit_breaker_status_code
+type ErrInvalidCircuitBreakerStatusCode struct {
+	error
+}
+
+// NewErrInvalidCircuitBreakerStatusCode returns a new invalid circuit breaker status code error
+func NewErrInvalidCircuitBreakerStatusCode(code int, backendName string) error {
+	var e *ErrInvalidCircuitBreakerStatusCode = &ErrInvalidCircuitBreakerStatusCode{
+		error: fmt.Errorf(`invalid circuit_breaker_status_code %d provided in backend options "%s"`,
+			code, backendName),
+	}
+	return e
+}
+
+// ErrInvalidMaxConcurrentUpstream is an error type for an invalid max_concurrent_upstream
+type ErrInvalidMaxConcurrentUpstream struct {
+	error
+}
+
+// NewErrInvalidMaxConcurrentUpstream returns a new invalid max concurrent upstream error
+func NewErrInvalidMaxConcurrentUpstream(n int, backendName string) error {
+	var e *ErrInvalidMaxConcurrentUpstream = &ErrInvalidMaxConcurrentUpstream{
+		error: fmt.Errorf(`invalid max_concurrent_upstream %d provided in backend options "%s"`,
+			n, backendName),
+	}
+	return e
+}
+
+// ErrInvalidUpstreamQueueTimeout is an error type for an invalid upstream_queue_timeout_ms
+type ErrInvalidUpstreamQueueTimeout struct {
+	error
+}
+
+// NewErrInvalidUpstreamQueueTimeout returns a new invalid upstream queue timeout error
+func NewErrInvalidUpstreamQueueTimeout(ms int, backendName string) error {
+	var e *ErrInvalidUpstreamQueueTimeout = &ErrInvalidUpstreamQueueTimeout{
+		error: fmt.Errorf(`invalid upstream_queue_timeout_ms %d provided in backend options "%s"`,
+			ms, backendName),
+	}
+	return e
+}
+
+// ErrInvalidOriginTimeout is an error type for an invalid origin_timeout_ms
+type ErrInvalidOriginTimeout struct {
+	error
+}
+
+// NewErrInvalidOriginTimeout returns a new invalid origin timeout error
+func NewErrInvalidOriginTimeout(ms int64, backendName string) error {
+	var e *ErrInvalidOriginTimeout = &ErrInvalidOriginTimeout{
+		error: fmt.Errorf(`invalid origin_timeout_ms %d provided in backend options "%s"`,
+			ms, backendName),
+	}
+	return e
+}
+
+// ErrInvalidClientResponseTimeout is an error type for an invalid client_response_timeout_ms
+type ErrInvalidClientResponseTimeout struct {
+	error
+}
+
+// NewErrInvalidClientResponseTimeout returns a new invalid client response timeout error
+func NewErrInvalidClientResponseTimeout(ms int64, backendName string) error {
+	var e *ErrInvalidClientResponseTimeout = &ErrInvalidClientResponseTimeout{
+		error: fmt.Errorf(`invalid client_response_timeout_ms %d provided in backend options "%s"`,
+			ms, backendName),
+	}
+	return e
+}
+
+// ErrInvalidMaxRangesPerRequest is an error type for an invalid max_ranges_per_request
+type ErrInvalidMaxRangesPerRequest struct {
+	error
+}
+
+// NewErrInvalidMaxRangesPerRequest returns a new invalid max ranges per request error
+func NewErrInvalidMaxRangesPerRequest(n int, backendName string) error {
+	var e *ErrInvalidMaxRangesPerRequest = &ErrInvalidMaxRangesPerRequest{
+		error: fmt.Errorf(`invalid max_ranges_per_request %d provided in backend options "%s"`,
+			n, backendName),
+	}
+	return e
+}
+
+// ErrInvalidMaxRangesStatusCode is an error type for an invalid max_ranges_status_code
+type ErrInvalidMaxRangesStatusCode struct {
+	error
+}
+
+// NewErrInvalidMaxRangesStatusCode returns a new invalid max ranges status code error
+func NewErrInvalidMaxRangesStatusCode(code int, backendName string) error {
+	var e *ErrInvalidMaxRangesStatusCode = &ErrInvalidMaxRangesStatusCode{
+		error: fmt.Errorf(`invalid max_ranges_status_code %d provided in backend options "%s"`,
+			code, backendName),
+	}
+	return e
+}
+
+// ErrInvalidMaxResponseBytes is an error type for an invalid max_response_bytes
+type ErrInvalidMaxResponseBytes struct {
+	error
+}
+
+// NewErrInvalidMaxResponseBytes returns a new invalid max response bytes error
+func NewErrInvalidMaxResponseBytes(n int64, backendName string) error {
+	var e *ErrInvalidMaxResponseBytes = &ErrInvalidMaxResponseBytes{
+		error: fmt.Errorf(`invalid max_response_bytes %d provided in backend options "%s"`,
+			n, backendName),
+	}
+	return e
+}
+
+// ErrInvalidMaxResponseBytesStatusCode is an error type for an invalid
+// max_response_bytes_status_code
+type ErrInvalidMaxResponseBytesStatusCode struct {
+	error
+}
+
+// NewErrInvalidMaxResponseBytesStatusCode returns a new invalid max response bytes
+// status code error
+func NewErrInvalidMaxResponseBytesStatusCode(code int, backendName string) error {
+	var e *ErrInvalidMaxResponseBytesStatusCode = &ErrInvalidMaxResponseBytesStatusCode{
+		error: fmt.Errorf(`invalid max_response_bytes_status_code %d provided in backend options "%s"`,
+			code, backendName),
+	}
+	return e
+}
+
+// ErrInvalidMaxRedirects is an error type for an invalid max_redirects
+type ErrInvalidMaxRedirects struct {
+	error
+}
+
+// NewErrInvalidMaxRedirects returns a new invalid max redirects error
+func NewErrInvalidMaxRedirects(n int, backendName string) error {
+	var e *ErrInvalidMaxRedirects = &ErrInvalidMaxRedirects{
+		error: fmt.Errorf(`invalid max_redirects %d provided in backend options "%s"`,
+			n, backendName),
+	}
+	return e
+}
+
+// ErrMissingRedirectAllowedHosts is an error type for a backend that follows redirects
+// without any redirect_allowed_hosts
+type ErrMissingRedirectAllowedHosts struct {
+	error
+}
+
+// NewErrMissingRedirectAllowedHosts returns a new missing redirect allowed hosts error
+func NewErrMissingRedirectAllowedHosts(backendName string) error {
+	var e *ErrMissingRedirectAllowedHosts = &ErrMissingRedirectAllowedHosts{
+		error: fmt.Errorf(`follow_redirects requires at least one redirect_allowed_hosts entry `+
+			`in backend options "%s"`, backendName),
+	}
+	return e
+}
+
+// ErrInvalidTrustedProxyCIDR is an error type for a trusted_proxy_cidrs entry that can't be parsed
+type ErrInvalidTrustedProxyCIDR struct {
+	error
+}
+
+// NewErrInvalidTrustedProxyCIDR returns a new invalid trusted proxy cidr error
+func NewErrInvalidTrustedProxyCIDR(cidr, backendName string, err error) error {
+	var e *ErrInvalidTrustedProxyCIDR = &ErrInvalidTrustedProxyCIDR{
+		error: fmt.Errorf(`invalid trusted_proxy_cidrs entry "%s" provided in backend options "%s": %v`,
+			cidr, backendName, err),
+	}
+	return e
+}
+
+// ErrInvalidPathRewriteRule is an error type for a path_rewrite_rules entry that can't be compiled
+type ErrInvalidPathRewriteRule struct {
+	error
+}
+
+// NewErrInvalidPathRewriteRule returns a new invalid path rewrite rule error
+func NewErrInvalidPathRewriteRule(match, backendName string, err error) error {
+	var e *ErrInvalidPathRewriteRule = &ErrInvalidPathRewriteRule{
+		error: fmt.Errorf(`invalid path_rewrite_rules match "%s" provided in backend options "%s": %v`,
+			match, backendName, err),
+	}
+	return e
+}
+
+// ErrInvalidSigV4 is an error type for invalid sigv4 options
+type ErrInvalidSigV4 struct {
+	error
+}
+
+// NewErrInvalidSigV4 returns a new invalid sigv4 options error
+func NewErrInvalidSigV4(backendName string, err error) error {
+	var e *ErrInvalidSigV4 = &ErrInvalidSigV4{
+		error: fmt.Errorf(`invalid sigv4 options provided in backend options "%s": %v`,
+			backendName, err),
+	}
+	return e
+}
+
+// errOAuth2WithSigV4 is the cause of an ErrInvalidOAuth2 for a backend that also uses sigv4,
+// since each sets the Authorization header of upstream requests
+var errOAuth2WithSigV4 = errors.New("oauth2 and sigv4 cannot both be configured")
+
+// ErrInvalidOAuth2 is an error type for invalid oauth2 options
+type ErrInvalidOAuth2 struct {
+	error
+}
+
+// NewErrInvalidOAuth2 returns a new invalid oauth2 options error
+func NewErrInvalidOAuth2(backendName string, err error) error {
+	var e *ErrInvalidOAuth2 = &ErrInvalidOAuth2{
+		error: fmt.Errorf(`invalid oauth2 options provided in backend options "%s": %v`,
+			backendName, err),
+	}
+	return e
+}
+
+// ErrInvalidCacheEvents is an error type for invalid cache_events options
+type ErrInvalidCacheEvents struct {
+	error
+}
+
+// NewErrInvalidCacheEvents returns a new invalid cache_events options error
+func NewErrInvalidCacheEvents(backendName string, err error) error {
+	var e *ErrInvalidCacheEvents = &ErrInvalidCacheEvents{
+		error: fmt.Errorf(`invalid cache_events options provided in backend options "%s": %v`,
+			backendName, err),
+	}
+	return e
+}
+
+// ErrInvalidPathPattern is an error type for a cacheable_paths or non_cacheable_paths
+// entry that can't be compiled
+type ErrInvalidPathPattern struct {
+	error
+}
+
+// NewErrInvalidPathPattern returns a new invalid path pattern error
+func NewErrInvalidPathPattern(pattern, key, backendName string, err error) error {
+	var e *ErrInvalidPathPattern = &ErrInvalidPathPattern{
+		error: fmt.Errorf(`invalid %s pattern "%s" provided in backend options "%s": %v`,
+			key, pattern, backendName, err),
+	}
+	return e
+}
+
+// ErrInvalidCacheKeyPrefix is an error type for a cache_key_prefix template that can't be evaluated
+type ErrInvalidCacheKeyPrefix struct {
+	error
+}
+
+// NewErrInvalidCacheKeyPrefix returns a new invalid cache key prefix error
+func NewErrInvalidCacheKeyPrefix(prefix, backendName string, err error) error {
+	var e *ErrInvalidCacheKeyPrefix = &ErrInvalidCacheKeyPrefix{
+		error: fmt.Errorf(`invalid cache_key_prefix "%s" provided in backend options "%s": %v`,
+			prefix, backendName, err),
+	}
+	return e
+}
+
+// ErrInvalidShadowOrigin is an error type for an invalid shadow_origin
+type ErrInvalidShadowOrigin struct {
+	error
+}
+
+// NewErrInvalidShadowOrigin returns a new invalid shadow origin error
+func NewErrInvalidShadowOrigin(origin, backendName string) error {
+	var e *ErrInvalidShadowOrigin = &ErrInvalidShadowOrigin{
+		error: fmt.Errorf(`invalid shadow_origin "%s" provided in backend options "%s"`,
+			origin, backendName),
+	}
+	return e
+}
+
+// ErrInvalidShadowSampleRate is an error type for a shadow_sample_rate outside of 0 to 1
+type ErrInvalidShadowSampleRate struct {
+	error
+}
+
+// NewErrInvalidShadowSampleRate returns a new invalid shadow sample rate error
+func NewErrInvalidShadowSampleRate(rate float64, backendName string) error {
+	var e *ErrInvalidShadowSampleRate = &ErrInvalidShadowSampleRate{
+		error: fmt.Errorf(`invalid shadow_sample_rate %g provided in backend options "%s"`,
+			rate, backendName),
+	}
+	return e
+}
+
+// ErrInvalidFillPolicy is an error type for an invalid fill_policy
+type ErrInvalidFillPolicy struct {
+	error
+}
+
+// NewErrInvalidFillPolicy returns a new invalid fill policy error
+func NewErrInvalidFillPolicy(policy, backendName string) error {
+	var e *ErrInvalidFillPolicy = &ErrInvalidFillPolicy{
+		error: fmt.Errorf(`invalid fill_policy "%s" provided in backend options "%s"`,
+			policy, backendName),
+	}
+	return e
+}
+
+// ErrInvalidCollapsedForwardingTimeoutAction is an error type for an invalid
+// collapsed_forwarding_timeout_action
+type ErrInvalidCollapsedForwardingTimeoutAction struct {
+	error
+}
+
+// NewErrInvalidCollapsedForwardingTimeoutAction returns a new invalid collapsed forwarding
+// timeout action error
+func NewErrInvalidCollapsedForwardingTimeoutAction(action, backendName string) error {
+	var e *ErrInvalidCollapsedForwardingTimeoutAction = &ErrInvalidCollapsedForwardingTimeoutAction{
+		error: fmt.Errorf(`invalid collapsed_forwarding_timeout_action "%s" provided in backend options "%s"`,
+			action, backendName),
+	}
+	return e
+}
+
+// ErrInvalidMinTTL is an error type for a min_ttl_ms that is negative or exceeds max_ttl_ms
+type ErrInvalidMinTTL struct {
+	error
+}
+
+// NewErrInvalidMinTTL returns a new invalid min ttl error
+func NewErrInvalidMinTTL(minTTLMS, maxTTLMS int, backendName string) error {
+	var e *ErrInvalidMinTTL = &ErrInvalidMinTTL{
+		error: fmt.Errorf(`invalid min_ttl_ms %d provided in backend options "%s": must be between 0 and max_ttl_ms (%d)`,
+			minTTLMS, backendName, maxTTLMS),
+	}
+	return e
+}
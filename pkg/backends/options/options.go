@@ -17,26 +17,43 @@
 package options
 
 import (
+	"fmt"
 	"net/http"
 	"net/url"
+	"regexp"
+	"sort"
 	"strings"
+	"text/template"
 	"time"
 
 	ao "github.com/trickstercache/trickster/v2/pkg/backends/alb/options"
+	cho "github.com/trickstercache/trickster/v2/pkg/backends/clickhouse/options"
 	ho "github.com/trickstercache/trickster/v2/pkg/backends/healthcheck/options"
 	prop "github.com/trickstercache/trickster/v2/pkg/backends/prometheus/options"
 	ro "github.com/trickstercache/trickster/v2/pkg/backends/rule/options"
 	"github.com/trickstercache/trickster/v2/pkg/cache/evictionmethods"
 	"github.com/trickstercache/trickster/v2/pkg/cache/negative"
 	co "github.com/trickstercache/trickster/v2/pkg/cache/options"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/cacheevents"
+	ceo "github.com/trickstercache/trickster/v2/pkg/proxy/cacheevents/options"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/circuitbreaker"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/forwarding"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+	oao "github.com/trickstercache/trickster/v2/pkg/proxy/oauth2/options"
 	po "github.com/trickstercache/trickster/v2/pkg/proxy/paths/options"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/request/rewriter"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/shadow"
+	svo "github.com/trickstercache/trickster/v2/pkg/proxy/sigv4/options"
 	to "github.com/trickstercache/trickster/v2/pkg/proxy/tls/options"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/upstreamlimit"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/urls"
 	"github.com/trickstercache/trickster/v2/pkg/router"
+	"github.com/trickstercache/trickster/v2/pkg/timeseries"
 	"github.com/trickstercache/trickster/v2/pkg/util/copiers"
+	"github.com/trickstercache/trickster/v2/pkg/util/timeconv"
 	"github.com/trickstercache/trickster/v2/pkg/util/yamlx"
 
+	"golang.org/x/net/http/httpguts"
 	"gopkg.in/yaml.v2"
 )
 
@@ -45,6 +62,23 @@ var restrictedOriginNames = map[string]interface{}{"": true, "frontend": true}
 // Lookup is a map of Options
 type Lookup map[string]*Options
 
+// UpstreamHost is an upstream host, and its relative weight, to which a
+// Backend's proxied requests are distributed
+type UpstreamHost struct {
+	// Host is the host[:port] of the upstream
+	Host string `yaml:"host,omitempty"`
+	// Weight is the relative share of requests sent to the upstream. Default is 1
+	Weight int `yaml:"weight,omitempty"`
+}
+
+// PathRewriteRule rewrites the paths of upstream requests matching a regular expression
+type PathRewriteRule struct {
+	// Match is the regular expression matched against the upstream request path
+	Match string `yaml:"match,omitempty"`
+	// Replacement is the rewritten path, which may reference capture groups as $1 or ${name}
+	Replacement string `yaml:"replacement,omitempty"`
+}
+
 // Options is a collection of configurations for Trickster backends
 type Options struct {
 
@@ -57,8 +91,44 @@ type Options struct {
 	// OriginURL provides the base upstream URL for all proxied requests to this Backend.
 	// it can be as simple as http://example.com or as complex as https://example.com:8443/path/prefix
 	OriginURL string `yaml:"origin_url,omitempty"`
-	// TimeoutMS defines how long the HTTP request will wait for a response before timing out
+	// UpstreamHosts is an optional list of upstream hosts, sharing the OriginURL's scheme
+	// and path prefix, across which proxied requests are distributed by weighted round-robin
+	UpstreamHosts []*UpstreamHost `yaml:"upstream_hosts,omitempty"`
+	// UpstreamHostHeader, when set, is sent as the Host header (or HTTP/2 :authority) of
+	// requests to the origin, in place of the host from the OriginURL. Cache keys are unaffected
+	UpstreamHostHeader string `yaml:"upstream_host_header,omitempty"`
+	// UpstreamUserAgent, when set, is sent as the User-Agent header of requests to the
+	// origin, in place of the client's. Cache keys are unaffected
+	UpstreamUserAgent string `yaml:"upstream_user_agent,omitempty"`
+	// AllowWebSocketUpgrade, when true, proxies WebSocket upgrade requests directly to the
+	// origin, bypassing the path handlers and cache
+	AllowWebSocketUpgrade bool `yaml:"allow_websocket_upgrade,omitempty"`
+	// PathRewriteRules is an ordered list of rules that rewrite the path of requests sent to
+	// the origin. Paths are matched without the OriginURL's path prefix
+	PathRewriteRules []*PathRewriteRule `yaml:"path_rewrite_rules,omitempty"`
+	// PathRewriteStopOnMatch, when true, applies only the first matching PathRewriteRule;
+	// otherwise, every matching rule is applied in order. Default is true
+	PathRewriteStopOnMatch bool `yaml:"path_rewrite_stop_on_match,omitempty"`
+	// PathRewriteCacheKey, when true, derives cache keys from the rewritten path rather
+	// than the requested path
+	PathRewriteCacheKey bool `yaml:"path_rewrite_cache_key,omitempty"`
+	// CacheablePaths is a list of regular expressions matched against request paths, without
+	// the OriginURL's path prefix. When set, only requests for matching paths are cached
+	CacheablePaths []string `yaml:"cacheable_paths,omitempty"`
+	// NonCacheablePaths is a list of regular expressions matched against request paths, without
+	// the OriginURL's path prefix. Requests for matching paths are never cached, even when they
+	// also match CacheablePaths
+	NonCacheablePaths []string `yaml:"non_cacheable_paths,omitempty"`
+	// TimeoutMS defines how long the HTTP request will wait for a response before timing out.
+	// It is the origin request timeout when OriginTimeoutMS is not set
 	TimeoutMS int64 `yaml:"timeout_ms,omitempty"`
+	// OriginTimeoutMS defines how long each request to the origin will wait for a response
+	// before timing out. When not set, TimeoutMS is used
+	OriginTimeoutMS int64 `yaml:"origin_timeout_ms,omitempty"`
+	// ClientResponseTimeoutMS defines how long Trickster will spend responding to a client
+	// request, including any origin requests and cache operations needed to assemble the
+	// response, before abandoning it. 0 (the default) does not limit the response time
+	ClientResponseTimeoutMS int64 `yaml:"client_response_timeout_ms,omitempty"`
 	// KeepAliveTimeoutMS defines how long an open keep-alive HTTP connection remains idle before closing
 	KeepAliveTimeoutMS int64 `yaml:"keep_alive_timeout_ms,omitempty"`
 	// MaxIdleConns defines maximum number of open keep-alive connections to maintain
@@ -67,8 +137,22 @@ type Options struct {
 	CacheName string `yaml:"cache_name,omitempty"`
 	// CacheKeyPrefix defines the cache key prefix the backend will use when writing objects to the cache
 	CacheKeyPrefix string `yaml:"cache_key_prefix,omitempty"`
+	// CacheKeyIncludePath, when true, includes the escaped request path in cache keys, so that
+	// cached objects can be purged by path prefix. Enabling it changes the key of every object
+	CacheKeyIncludePath bool `yaml:"cache_key_include_path,omitempty"`
+	// AllowCacheBypassHeader, when true, permits clients to skip the cache read for a request
+	// by setting CacheBypassHeader to true. The fresh response is still written to the cache
+	AllowCacheBypassHeader bool `yaml:"allow_cache_bypass_header,omitempty"`
+	// CacheBypassHeader is the name of the request header used to bypass the cache read
+	CacheBypassHeader string `yaml:"cache_bypass_header,omitempty"`
+	// RefreshOnClientNoCache, when true, handles client requests with Cache-Control: no-cache
+	// or Pragma: no-cache like a cache bypass, rather than purging the object and proxying
+	RefreshOnClientNoCache bool `yaml:"refresh_on_client_no_cache,omitempty"`
 	// HealthCheck is the health check options reference for this backend
 	HealthCheck *ho.Options `yaml:"healthcheck,omitempty"`
+	// HealthCheckCritical indicates whether a failing health check for this backend
+	// fails the application-wide health check. Default is true
+	HealthCheckCritical bool `yaml:"healthcheck_critical,omitempty"`
 	// Object Proxy Cache and Delta Proxy Cache Configurations
 	// TimeseriesRetentionFactor limits the maximum the number of chronological
 	// timestamps worth of data to store in cache for each query
@@ -80,10 +164,17 @@ type Options struct {
 	// milliseconds from being cached. this allows propagation of upstream backfill operations
 	// that modify recently-cached data
 	BackfillToleranceMS int64 `yaml:"backfill_tolerance_ms,omitempty"`
+	// BackfillToleranceDuration is a duration string (e.g., "250ms", "1m30s") form of
+	// BackfillToleranceMS that allows sub-millisecond precision. When set, it overrides
+	// BackfillToleranceMS
+	BackfillToleranceDuration string `yaml:"backfill_tolerance,omitempty"`
 	// BackfillTolerancePoints is similar to the MS version, except that it's final value is dependent
 	// on the query step value to determine the relative duration of backfill tolerance per-query
 	// When both are set, the higher of the two values is used
 	BackfillTolerancePoints int `yaml:"backfill_tolerance_points,omitempty"`
+	// FillPolicyName specifies how gaps between the points of each series are filled ("none",
+	// "null", "previous", "zero") in time series responses from the Delta Proxy Cache
+	FillPolicyName string `yaml:"fill_policy,omitempty"`
 	// PathList is a list of Path Options that control the behavior of the given paths when requested
 	Paths map[string]*po.Options `yaml:"paths,omitempty"`
 	// NegativeCacheName provides the name of the Negative Cache Config to be used by this Backend
@@ -94,14 +185,27 @@ type Options struct {
 	FastForwardTTLMS int `yaml:"fastforward_ttl_ms,omitempty"`
 	// MaxTTLMS specifies the maximum allowed TTL for any cache object
 	MaxTTLMS int `yaml:"max_ttl_ms,omitempty"`
+	// MinTTLMS specifies the minimum TTL for any cache object whose TTL is derived
+	// from the origin's response caching headers
+	MinTTLMS int `yaml:"min_ttl_ms,omitempty"`
+	// NonCacheableResponseHeaders is a list of origin response headers, such as Set-Cookie,
+	// that are removed from documents before they are cached, so they are never replayed
+	// to other clients from the cache
+	NonCacheableResponseHeaders []string `yaml:"non_cacheable_response_headers,omitempty"`
 	// RevalidationFactor specifies how many times to multiply the object freshness lifetime
 	// by to calculate an absolute cache TTL
 	RevalidationFactor float64 `yaml:"revalidation_factor,omitempty"`
 	// MaxObjectSizeBytes specifies the max objectsize to be accepted for any given cache object
 	MaxObjectSizeBytes int `yaml:"max_object_size_bytes,omitempty"`
+	// MaxCacheableObjectBytes specifies the max serialized size of any document written to the cache.
+	// Larger documents are served to the client without being cached. 0 means no limit
+	MaxCacheableObjectBytes int64 `yaml:"max_cacheable_object_bytes,omitempty"`
 	// CompressibleTypeList specifies the HTTP Object Content Types that will be compressed internally
 	// when stored in the Trickster cache or served to clients with a compatible 'Accept-Encoding' header
 	CompressibleTypeList []string `yaml:"compressible_types,omitempty"`
+	// EncodeForClient indicates whether unencoded, compressible objects served from cache
+	// are encoded to match the client's Accept-Encoding, with each encoded variant cached
+	EncodeForClient bool `yaml:"encode_for_client,omitempty"`
 	// TracingConfigName provides the name of the Tracing Config to be used by this Backend
 	TracingConfigName string `yaml:"tracing_name,omitempty"`
 	// RuleName provides the name of the rule config to be used by this backend.
@@ -110,6 +214,45 @@ type Options struct {
 	// ReqRewriterName is the name of a configured Rewriter that will modify the request prior to
 	// processing by the backend client
 	ReqRewriterName string `yaml:"req_rewriter_name,omitempty"`
+	// RequestHeaderInjections maps header names to templates that are evaluated against each
+	// client request and set on the request before it is proxied to the backend
+	RequestHeaderInjections map[string]string `yaml:"request_header_injections,omitempty"`
+	// CircuitBreakerFailureThreshold is the number of consecutive upstream failures (errors or
+	// 5xx responses) within CircuitBreakerWindowMS that trips the backend's circuit breaker.
+	// 0 disables the circuit breaker
+	CircuitBreakerFailureThreshold int `yaml:"circuit_breaker_failure_threshold,omitempty"`
+	// CircuitBreakerWindowMS is the window within which consecutive failures are counted
+	CircuitBreakerWindowMS int `yaml:"circuit_breaker_window_ms,omitempty"`
+	// CircuitBreakerCooldownMS is how long a tripped circuit breaker fast-fails requests
+	// before permitting a trial request upstream
+	CircuitBreakerCooldownMS int `yaml:"circuit_breaker_cooldown_ms,omitempty"`
+	// CircuitBreakerStatusCode is the HTTP status returned for fast-failed requests
+	CircuitBreakerStatusCode int `yaml:"circuit_breaker_status_code,omitempty"`
+	// MaxConcurrentUpstream is the maximum number of concurrent requests the backend sends
+	// to its origin. Requests beyond the limit wait up to UpstreamQueueTimeoutMS for a slot
+	// before failing with a 503. A value of 0 disables the limit
+	MaxConcurrentUpstream int `yaml:"max_concurrent_upstream,omitempty"`
+	// UpstreamQueueTimeoutMS is how long a request waits for an upstream slot when the
+	// backend is at its MaxConcurrentUpstream limit
+	UpstreamQueueTimeoutMS int `yaml:"upstream_queue_timeout_ms,omitempty"`
+	// EmitServerTiming indicates the backend's responses include a Server-Timing header
+	// reporting the durations of the request's cache lookups, origin fetches and total
+	EmitServerTiming bool `yaml:"emit_server_timing,omitempty"`
+	// CollapsedForwardingTimeoutMS is how long a request collapsed into an identical in-flight
+	// request waits for it to complete before breaking away. 0 waits indefinitely
+	CollapsedForwardingTimeoutMS int `yaml:"collapsed_forwarding_timeout_ms,omitempty"`
+	// CollapsedForwardingTimeoutActionName is 'fetch' to issue an independent upstream request
+	// after a collapsed forwarding timeout, or 'fail' to respond with a 503
+	CollapsedForwardingTimeoutActionName string `yaml:"collapsed_forwarding_timeout_action,omitempty"`
+	// ShadowOrigin is the URL of an origin to which a sample of this backend's requests are
+	// asynchronously mirrored. Shadow responses are compared to the primary origin's responses,
+	// but are never cached or returned to clients
+	ShadowOrigin string `yaml:"shadow_origin,omitempty"`
+	// ShadowSampleRate is the fraction (0 to 1) of requests mirrored to the ShadowOrigin
+	ShadowSampleRate float64 `yaml:"shadow_sample_rate,omitempty"`
+	// ShadowTimeoutMS is how long to wait for the ShadowOrigin to respond before abandoning
+	// a mirrored request
+	ShadowTimeoutMS int `yaml:"shadow_timeout_ms,omitempty"`
 	// MaxShardSizePoints defines the maximum size of a timeseries request in unique timestamps,
 	// before sharding into multiple requests of this denomination and reconsitituting the results.
 	// If MaxShardSizePoints and MaxShardSizeMS are both > 0, the configuration is invalid
@@ -127,12 +270,26 @@ type Options struct {
 	ALBOptions *ao.Options `yaml:"alb,omitempty"`
 	// Prometheus holds options specific to prometheus backends
 	Prometheus *prop.Options `yaml:"prometheus,omitempty"`
+	// ClickHouse holds options specific to clickhouse backends
+	ClickHouse *cho.Options `yaml:"clickhouse,omitempty"`
 
 	// TLS is the TLS Configuration for the Frontend and Backend
 	TLS *to.Options `yaml:"tls,omitempty"`
+	// SigV4, when set, signs each upstream request with AWS Signature Version 4
+	SigV4 *svo.Options `yaml:"sigv4,omitempty"`
+	// OAuth2, when set, authenticates each upstream request with a bearer token
+	// obtained by the OAuth2 client credentials flow
+	OAuth2 *oao.Options `yaml:"oauth2,omitempty"`
+	// CacheEvents, when set, reports each of this backend's cache writes and purges
+	// to a webhook
+	CacheEvents *ceo.Options `yaml:"cache_events,omitempty"`
 
 	// ForwardedHeaders indicates the class of 'Forwarded' header to attach to upstream requests
 	ForwardedHeaders string `yaml:"forwarded_headers,omitempty"`
+	// TrustedProxyCIDRs is the list of networks (or individual IP addresses) of proxies
+	// in front of Trickster whose forwarding headers are extended, rather than replaced,
+	// in upstream requests
+	TrustedProxyCIDRs []string `yaml:"trusted_proxy_cidrs,omitempty"`
 
 	// IsDefault indicates if this is the d.Default backend for any request not matching a configured route
 	IsDefault bool `yaml:"is_default,omitempty"`
@@ -145,6 +302,29 @@ type Options struct {
 	// MultipartRangesDisabled, when true, indicates that if a downstream client requests multiple ranges
 	// in a single request, Trickster will instead request and return a 200 OK with the full object body
 	MultipartRangesDisabled bool `yaml:"multipart_ranges_disabled,omitempty"`
+	// MaxRangesPerRequest is the maximum number of byte ranges permitted in a client request's
+	// Range header. Requests with more ranges are rejected before any cache or origin work.
+	// A value of 0 disables the limit
+	MaxRangesPerRequest int `yaml:"max_ranges_per_request,omitempty"`
+	// MaxRangesStatusCode is the HTTP status returned for requests exceeding MaxRangesPerRequest
+	MaxRangesStatusCode int `yaml:"max_ranges_status_code,omitempty"`
+	// MaxResponseBytes is the maximum size of an upstream response body. Responses advertising
+	// a larger size are abandoned, while streamed responses found to be larger are cut off at
+	// the limit. Neither is cached. A value of 0 disables the limit
+	MaxResponseBytes int64 `yaml:"max_response_bytes,omitempty"`
+	// MaxResponseBytesStatusCode is the HTTP status returned for requests whose upstream
+	// response advertises a size exceeding MaxResponseBytes
+	MaxResponseBytesStatusCode int `yaml:"max_response_bytes_status_code,omitempty"`
+	// FollowRedirects, when true, causes 3xx responses from the origin that redirect to one
+	// of the RedirectAllowedHosts to be followed, so that the final response is cached and
+	// returned instead of the redirect. Other redirects are passed through to the client
+	FollowRedirects bool `yaml:"follow_redirects,omitempty"`
+	// MaxRedirects is the maximum number of redirects followed for a single upstream
+	// request. The redirect that would exceed it is passed through to the client
+	MaxRedirects int `yaml:"max_redirects,omitempty"`
+	// RedirectAllowedHosts is the list of hosts (as host or host:port) to which
+	// redirects are followed when FollowRedirects is true
+	RedirectAllowedHosts []string `yaml:"redirect_allowed_hosts,omitempty"`
 	// DearticulateUpstreamRanges, when true, indicates that when Trickster requests multiple ranges from
 	// the backend, that they be requested as individual upstream requests instead of a single request that
 	// expects a multipart response	// this optimizes Trickster to request as few bytes as possible when
@@ -170,7 +350,13 @@ type Options struct {
 	Router router.Router `yaml:"-"`
 	// Timeout is the time.Duration representation of TimeoutMS
 	Timeout time.Duration `yaml:"-"`
-	// BackfillTolerance is the time.Duration representation of BackfillToleranceMS
+	// OriginTimeout is the time.Duration representation of OriginTimeoutMS, or of
+	// TimeoutMS when OriginTimeoutMS is not set
+	OriginTimeout time.Duration `yaml:"-"`
+	// ClientResponseTimeout is the time.Duration representation of ClientResponseTimeoutMS
+	ClientResponseTimeout time.Duration `yaml:"-"`
+	// BackfillTolerance is the time.Duration representation of BackfillToleranceDuration,
+	// or of BackfillToleranceMS when BackfillToleranceDuration is not set
 	BackfillTolerance time.Duration `yaml:"-"`
 	// ValueRetention is the time.Duration representation of ValueRetentionSecs
 	ValueRetention time.Duration `yaml:"-"`
@@ -184,6 +370,10 @@ type Options struct {
 	PathPrefix string `yaml:"-"`
 	// NegativeCache provides a map for the negative cache, with TTLs converted to time.Durations
 	NegativeCache negative.Lookup `yaml:"-"`
+	// NegativeCacheHeaderRules provides the negative cache entries that also require a response header match
+	NegativeCacheHeaderRules negative.HeaderRules `yaml:"-"`
+	// NegativeCacheResponses provides the replacement responses served for negative-cached entries
+	NegativeCacheResponses negative.ResponseLookup `yaml:"-"`
 	// TimeseriesRetention when subtracted from time.Now() represents the oldest allowable timestamp in a
 	// timeseries when EvictionMethod is 'oldest'
 	TimeseriesRetention time.Duration `yaml:"-"`
@@ -197,6 +387,8 @@ type Options struct {
 	FastForwardPath *po.Options `yaml:"-"`
 	// MaxTTL is the parsed value of MaxTTLMS
 	MaxTTL time.Duration `yaml:"-"`
+	// MinTTL is the parsed value of MinTTLMS
+	MinTTL time.Duration `yaml:"-"`
 	// HTTPClient is the Client used by Trickster to communicate with the origin
 	HTTPClient *http.Client `yaml:"-"`
 	// CompressibleTypes is the map version of CompressibleTypeList for fast lookup
@@ -205,11 +397,44 @@ type Options struct {
 	RuleOptions *ro.Options `yaml:"-"`
 	// ReqRewriter is the rewriter handler as indicated by RuleName
 	ReqRewriter rewriter.RewriteInstructions
+	// HeaderInjections is the compiled version of RequestHeaderInjections
+	HeaderInjections headers.Injections `yaml:"-"`
+	// TrustedProxies is the compiled version of TrustedProxyCIDRs
+	TrustedProxies headers.TrustedProxies `yaml:"-"`
 	// DoesShard is true when sharding will be used with this origin, based on how the
 	// sharding options have been configured
 	DoesShard bool `yaml:"-"`
 	// MaxShardSize is the parsed version of MaxShardSizeMS
 	MaxShardSize time.Duration `yaml:"-"`
+	// CircuitBreakerWindow is the parsed version of CircuitBreakerWindowMS
+	CircuitBreakerWindow time.Duration `yaml:"-"`
+	// CircuitBreakerCooldown is the parsed version of CircuitBreakerCooldownMS
+	CircuitBreakerCooldown time.Duration `yaml:"-"`
+	// CircuitBreaker is the backend's circuit breaker, when CircuitBreakerFailureThreshold > 0
+	CircuitBreaker *circuitbreaker.Breaker `yaml:"-"`
+	// UpstreamQueueTimeout is the parsed version of UpstreamQueueTimeoutMS
+	UpstreamQueueTimeout time.Duration `yaml:"-"`
+	// UpstreamLimiter is the backend's upstream concurrency limiter, when MaxConcurrentUpstream > 0
+	UpstreamLimiter *upstreamlimit.Limiter `yaml:"-"`
+	// CollapsedForwardingTimeout is the parsed version of CollapsedForwardingTimeoutMS
+	CollapsedForwardingTimeout time.Duration `yaml:"-"`
+	// CollapsedForwardingTimeoutAction is the typed representation of
+	// CollapsedForwardingTimeoutActionName
+	CollapsedForwardingTimeoutAction forwarding.CollapsedForwardingTimeoutAction `yaml:"-"`
+	// FillPolicy is the typed representation of FillPolicyName
+	FillPolicy timeseries.FillPolicy `yaml:"-"`
+	// PathRewriter is the compiled version of PathRewriteRules
+	PathRewriter *urls.PathRewriter `yaml:"-"`
+	// CacheablePathPatterns is the compiled version of CacheablePaths
+	CacheablePathPatterns []*regexp.Regexp `yaml:"-"`
+	// NonCacheablePathPatterns is the compiled version of NonCacheablePaths
+	NonCacheablePathPatterns []*regexp.Regexp `yaml:"-"`
+	// ShadowTimeout is the parsed version of ShadowTimeoutMS
+	ShadowTimeout time.Duration `yaml:"-"`
+	// Shadow is the backend's shadow origin mirror, when ShadowOrigin is set
+	Shadow *shadow.Mirror `yaml:"-"`
+	// CacheEventNotifier reports cache events, when CacheEvents is set
+	CacheEventNotifier *cacheevents.Notifier `yaml:"-"`
 	// ShardStep is the parsed version of ShardStepMS
 	ShardStep time.Duration `yaml:"-"`
 
@@ -223,13 +448,26 @@ func New() *Options {
 		BackfillTolerance:            time.Duration(DefaultBackfillToleranceMS) * time.Millisecond,
 		BackfillToleranceMS:          DefaultBackfillToleranceMS,
 		BackfillTolerancePoints:      DefaultBackfillTolerancePoints,
+		CacheBypassHeader:            DefaultCacheBypassHeader,
 		CacheKeyPrefix:               "",
 		CacheName:                    DefaultBackendCacheName,
+		CircuitBreakerCooldown:       DefaultCircuitBreakerCooldownMS * time.Millisecond,
+		CircuitBreakerCooldownMS:     DefaultCircuitBreakerCooldownMS,
+		CircuitBreakerStatusCode:     DefaultCircuitBreakerStatusCode,
+		MaxRangesPerRequest:          DefaultMaxRangesPerRequest,
+		MaxRangesStatusCode:          DefaultMaxRangesStatusCode,
+		MaxResponseBytesStatusCode:   DefaultMaxResponseBytesStatusCode,
+		MaxRedirects:                 DefaultMaxRedirects,
+		UpstreamQueueTimeout:         DefaultUpstreamQueueTimeoutMS * time.Millisecond,
+		UpstreamQueueTimeoutMS:       DefaultUpstreamQueueTimeoutMS,
+		CircuitBreakerWindow:         DefaultCircuitBreakerWindowMS * time.Millisecond,
+		CircuitBreakerWindowMS:       DefaultCircuitBreakerWindowMS,
 		CompressibleTypeList:         DefaultCompressibleTypes(),
 		FastForwardTTL:               DefaultFastForwardTTLMS * time.Millisecond,
 		FastForwardTTLMS:             DefaultFastForwardTTLMS,
 		ForwardedHeaders:             DefaultForwardedHeaders,
 		HealthCheck:                  ho.New(),
+		HealthCheckCritical:          DefaultHealthCheckCritical,
 		KeepAliveTimeoutMS:           DefaultKeepAliveTimeoutMS,
 		MaxIdleConns:                 DefaultMaxIdleConns,
 		MaxObjectSizeBytes:           DefaultMaxObjectSizeBytes,
@@ -237,8 +475,12 @@ func New() *Options {
 		MaxTTLMS:                     DefaultMaxTTLMS,
 		NegativeCache:                make(map[int]time.Duration),
 		NegativeCacheName:            DefaultBackendNegativeCacheName,
+		PathRewriteStopOnMatch:       DefaultPathRewriteStopOnMatch,
 		Paths:                        make(map[string]*po.Options),
 		RevalidationFactor:           DefaultRevalidationFactor,
+		ShadowSampleRate:             DefaultShadowSampleRate,
+		ShadowTimeout:                DefaultShadowTimeoutMS * time.Millisecond,
+		ShadowTimeoutMS:              DefaultShadowTimeoutMS,
 		MaxShardSizePoints:           DefaultTimeseriesShardSize,
 		MaxShardSizeMS:               DefaultTimeseriesShardSize,
 		MaxShardSize:                 time.Duration(DefaultTimeseriesShardSize) * time.Millisecond,
@@ -247,6 +489,7 @@ func New() *Options {
 		TLS:                          &to.Options{},
 		Timeout:                      time.Millisecond * DefaultBackendTimeoutMS,
 		TimeoutMS:                    DefaultBackendTimeoutMS,
+		OriginTimeout:                time.Millisecond * DefaultBackendTimeoutMS,
 		TimeseriesEvictionMethod:     DefaultBackendTEM,
 		TimeseriesEvictionMethodName: DefaultBackendTEMName,
 		TimeseriesRetention:          DefaultBackendTRF,
@@ -264,15 +507,63 @@ func (o *Options) Clone() *Options {
 	no.DearticulateUpstreamRanges = o.DearticulateUpstreamRanges
 	no.BackfillTolerance = o.BackfillTolerance
 	no.BackfillToleranceMS = o.BackfillToleranceMS
+	no.BackfillToleranceDuration = o.BackfillToleranceDuration
 	no.BackfillTolerancePoints = o.BackfillTolerancePoints
 	no.CacheName = o.CacheName
 	no.CacheKeyPrefix = o.CacheKeyPrefix
+	no.CacheKeyIncludePath = o.CacheKeyIncludePath
+	no.AllowCacheBypassHeader = o.AllowCacheBypassHeader
+	no.CacheBypassHeader = o.CacheBypassHeader
+	no.RefreshOnClientNoCache = o.RefreshOnClientNoCache
 	no.DoesShard = o.DoesShard
 	no.FastForwardDisable = o.FastForwardDisable
 	no.FastForwardTTL = o.FastForwardTTL
 	no.FastForwardTTLMS = o.FastForwardTTLMS
 	no.ForwardedHeaders = o.ForwardedHeaders
+	no.TrustedProxyCIDRs = copiers.CopyStrings(o.TrustedProxyCIDRs)
+	no.TrustedProxies = o.TrustedProxies
 	no.Host = o.Host
+	no.CircuitBreakerFailureThreshold = o.CircuitBreakerFailureThreshold
+	no.CircuitBreakerWindowMS = o.CircuitBreakerWindowMS
+	no.CircuitBreakerWindow = o.CircuitBreakerWindow
+	no.CircuitBreakerCooldownMS = o.CircuitBreakerCooldownMS
+	no.CircuitBreakerCooldown = o.CircuitBreakerCooldown
+	no.CircuitBreakerStatusCode = o.CircuitBreakerStatusCode
+	no.MaxConcurrentUpstream = o.MaxConcurrentUpstream
+	no.UpstreamQueueTimeoutMS = o.UpstreamQueueTimeoutMS
+	no.UpstreamQueueTimeout = o.UpstreamQueueTimeout
+	no.UpstreamLimiter = o.UpstreamLimiter
+	no.EmitServerTiming = o.EmitServerTiming
+	no.CollapsedForwardingTimeoutMS = o.CollapsedForwardingTimeoutMS
+	no.CollapsedForwardingTimeout = o.CollapsedForwardingTimeout
+	no.CollapsedForwardingTimeoutActionName = o.CollapsedForwardingTimeoutActionName
+	no.CollapsedForwardingTimeoutAction = o.CollapsedForwardingTimeoutAction
+	no.FillPolicyName = o.FillPolicyName
+	no.FillPolicy = o.FillPolicy
+	no.ShadowOrigin = o.ShadowOrigin
+	no.ShadowSampleRate = o.ShadowSampleRate
+	no.ShadowTimeoutMS = o.ShadowTimeoutMS
+	no.ShadowTimeout = o.ShadowTimeout
+	no.Shadow = o.Shadow
+	if len(o.UpstreamHosts) > 0 {
+		no.UpstreamHosts = make([]*UpstreamHost, len(o.UpstreamHosts))
+		for i, u := range o.UpstreamHosts {
+			no.UpstreamHosts[i] = &UpstreamHost{Host: u.Host, Weight: u.Weight}
+		}
+	}
+	if len(o.PathRewriteRules) > 0 {
+		no.PathRewriteRules = make([]*PathRewriteRule, len(o.PathRewriteRules))
+		for i, pr := range o.PathRewriteRules {
+			no.PathRewriteRules[i] = &PathRewriteRule{Match: pr.Match, Replacement: pr.Replacement}
+		}
+	}
+	no.PathRewriteStopOnMatch = o.PathRewriteStopOnMatch
+	no.PathRewriteCacheKey = o.PathRewriteCacheKey
+	no.PathRewriter = o.PathRewriter
+	no.CacheablePaths = copiers.CopyStrings(o.CacheablePaths)
+	no.NonCacheablePaths = copiers.CopyStrings(o.NonCacheablePaths)
+	no.CacheablePathPatterns = o.CacheablePathPatterns
+	no.NonCacheablePathPatterns = o.NonCacheablePathPatterns
 	no.LatencyMinMS = o.LatencyMinMS
 	no.LatencyMaxMS = o.LatencyMaxMS
 	no.Name = o.Name
@@ -281,12 +572,28 @@ func (o *Options) Clone() *Options {
 	no.MaxIdleConns = o.MaxIdleConns
 	no.MaxTTLMS = o.MaxTTLMS
 	no.MaxTTL = o.MaxTTL
+	no.MinTTLMS = o.MinTTLMS
+	no.MinTTL = o.MinTTL
+	no.NonCacheableResponseHeaders = copiers.CopyStrings(o.NonCacheableResponseHeaders)
 	no.MaxObjectSizeBytes = o.MaxObjectSizeBytes
+	no.MaxCacheableObjectBytes = o.MaxCacheableObjectBytes
 	no.MultipartRangesDisabled = o.MultipartRangesDisabled
+	no.MaxRangesPerRequest = o.MaxRangesPerRequest
+	no.MaxRangesStatusCode = o.MaxRangesStatusCode
+	no.MaxResponseBytes = o.MaxResponseBytes
+	no.MaxResponseBytesStatusCode = o.MaxResponseBytesStatusCode
+	no.FollowRedirects = o.FollowRedirects
+	no.MaxRedirects = o.MaxRedirects
+	no.RedirectAllowedHosts = copiers.CopyStrings(o.RedirectAllowedHosts)
 	no.Provider = o.Provider
 	no.OriginURL = o.OriginURL
+	no.UpstreamHostHeader = o.UpstreamHostHeader
+	no.UpstreamUserAgent = o.UpstreamUserAgent
+	no.AllowWebSocketUpgrade = o.AllowWebSocketUpgrade
 	no.PathPrefix = o.PathPrefix
 	no.ReqRewriterName = o.ReqRewriterName
+	no.RequestHeaderInjections = copiers.CopyStringLookup(o.RequestHeaderInjections)
+	no.HeaderInjections = o.HeaderInjections
 	no.RevalidationFactor = o.RevalidationFactor
 	no.RuleName = o.RuleName
 	no.Scheme = o.Scheme
@@ -297,6 +604,10 @@ func (o *Options) Clone() *Options {
 	no.ShardStepMS = o.ShardStepMS
 	no.Timeout = o.Timeout
 	no.TimeoutMS = o.TimeoutMS
+	no.OriginTimeout = o.OriginTimeout
+	no.OriginTimeoutMS = o.OriginTimeoutMS
+	no.ClientResponseTimeout = o.ClientResponseTimeout
+	no.ClientResponseTimeoutMS = o.ClientResponseTimeoutMS
 	no.TimeseriesRetention = o.TimeseriesRetention
 	no.TimeseriesRetentionFactor = o.TimeseriesRetentionFactor
 	no.TimeseriesEvictionMethodName = o.TimeseriesEvictionMethodName
@@ -307,6 +618,8 @@ func (o *Options) Clone() *Options {
 
 	no.TracingConfigName = o.TracingConfigName
 
+	no.HealthCheckCritical = o.HealthCheckCritical
+	no.EncodeForClient = o.EncodeForClient
 	if o.HealthCheck != nil {
 		no.HealthCheck = o.HealthCheck.Clone()
 	}
@@ -334,10 +647,30 @@ func (o *Options) Clone() *Options {
 		}
 		no.NegativeCache = m
 	}
+	if o.NegativeCacheHeaderRules != nil {
+		no.NegativeCacheHeaderRules = append(negative.HeaderRules{},
+			o.NegativeCacheHeaderRules...)
+	}
+	if o.NegativeCacheResponses != nil {
+		no.NegativeCacheResponses = make(negative.ResponseLookup, len(o.NegativeCacheResponses))
+		for c, r := range o.NegativeCacheResponses {
+			no.NegativeCacheResponses[c] = r.Clone()
+		}
+	}
 
 	if o.TLS != nil {
 		no.TLS = o.TLS.Clone()
 	}
+	if o.SigV4 != nil {
+		no.SigV4 = o.SigV4.Clone()
+	}
+	if o.OAuth2 != nil {
+		no.OAuth2 = o.OAuth2.Clone()
+	}
+	if o.CacheEvents != nil {
+		no.CacheEvents = o.CacheEvents.Clone()
+	}
+	no.CacheEventNotifier = o.CacheEventNotifier
 	no.RequireTLS = o.RequireTLS
 
 	if o.FastForwardPath != nil {
@@ -356,6 +689,10 @@ func (o *Options) Clone() *Options {
 		no.Prometheus = o.Prometheus.Clone()
 	}
 
+	if o.ClickHouse != nil {
+		no.ClickHouse = o.ClickHouse.Clone()
+	}
+
 	return no
 }
 
@@ -378,11 +715,33 @@ func (l Lookup) Validate(ncl negative.Lookups) error {
 		o.Host = url.Host
 		o.PathPrefix = url.Path
 		o.Timeout = time.Duration(o.TimeoutMS) * time.Millisecond
+		if o.OriginTimeoutMS < 0 {
+			return NewErrInvalidOriginTimeout(o.OriginTimeoutMS, k)
+		}
+		o.OriginTimeout = o.Timeout
+		if o.OriginTimeoutMS > 0 {
+			o.OriginTimeout = time.Duration(o.OriginTimeoutMS) * time.Millisecond
+		}
+		if o.ClientResponseTimeoutMS < 0 {
+			return NewErrInvalidClientResponseTimeout(o.ClientResponseTimeoutMS, k)
+		}
+		o.ClientResponseTimeout = time.Duration(o.ClientResponseTimeoutMS) * time.Millisecond
 		o.BackfillTolerance = time.Duration(o.BackfillToleranceMS) * time.Millisecond
+		if o.BackfillToleranceDuration != "" {
+			d, err := timeconv.ParseDuration(o.BackfillToleranceDuration)
+			if err != nil || d < 0 {
+				return NewErrInvalidBackfillTolerance(o.BackfillToleranceDuration, k)
+			}
+			o.BackfillTolerance = d
+		}
 		o.TimeseriesRetention = time.Duration(o.TimeseriesRetentionFactor)
 		o.TimeseriesTTL = time.Duration(o.TimeseriesTTLMS) * time.Millisecond
 		o.FastForwardTTL = time.Duration(o.FastForwardTTLMS) * time.Millisecond
 		o.MaxTTL = time.Duration(o.MaxTTLMS) * time.Millisecond
+		if o.MinTTLMS < 0 || o.MinTTLMS > o.MaxTTLMS {
+			return NewErrInvalidMinTTL(o.MinTTLMS, o.MaxTTLMS, k)
+		}
+		o.MinTTL = time.Duration(o.MinTTLMS) * time.Millisecond
 		o.DoesShard = o.MaxShardSizePoints > 0 || o.MaxShardSizeMS > 0 || o.ShardStepMS > 0
 		o.ShardStep = time.Duration(o.ShardStepMS) * time.Millisecond
 		o.MaxShardSize = time.Duration(o.MaxShardSizeMS) * time.Millisecond
@@ -399,6 +758,149 @@ func (l Lookup) Validate(ncl negative.Lookups) error {
 			return ErrInvalidMaxShardSizeMS
 		}
 
+		o.CircuitBreakerWindow = time.Duration(o.CircuitBreakerWindowMS) * time.Millisecond
+		o.CircuitBreakerCooldown = time.Duration(o.CircuitBreakerCooldownMS) * time.Millisecond
+		if o.CircuitBreakerFailureThreshold > 0 {
+			if o.CircuitBreakerStatusCode < 100 || o.CircuitBreakerStatusCode > 599 {
+				return NewErrInvalidCircuitBreakerStatusCode(o.CircuitBreakerStatusCode, k)
+			}
+			o.CircuitBreaker = circuitbreaker.New(k, o.CircuitBreakerFailureThreshold,
+				o.CircuitBreakerWindow, o.CircuitBreakerCooldown)
+		}
+
+		if o.MaxConcurrentUpstream < 0 {
+			return NewErrInvalidMaxConcurrentUpstream(o.MaxConcurrentUpstream, k)
+		}
+		if o.UpstreamQueueTimeoutMS < 0 {
+			return NewErrInvalidUpstreamQueueTimeout(o.UpstreamQueueTimeoutMS, k)
+		}
+		o.UpstreamQueueTimeout = time.Duration(o.UpstreamQueueTimeoutMS) * time.Millisecond
+		if o.MaxConcurrentUpstream > 0 {
+			o.UpstreamLimiter = upstreamlimit.New(k, o.MaxConcurrentUpstream,
+				o.UpstreamQueueTimeout)
+		}
+
+		if o.MaxRangesPerRequest < 0 {
+			return NewErrInvalidMaxRangesPerRequest(o.MaxRangesPerRequest, k)
+		}
+		if o.MaxRangesPerRequest > 0 && (o.MaxRangesStatusCode < 100 || o.MaxRangesStatusCode > 599) {
+			return NewErrInvalidMaxRangesStatusCode(o.MaxRangesStatusCode, k)
+		}
+
+		if o.MaxResponseBytes < 0 {
+			return NewErrInvalidMaxResponseBytes(o.MaxResponseBytes, k)
+		}
+		if o.MaxResponseBytes > 0 && (o.MaxResponseBytesStatusCode < 100 ||
+			o.MaxResponseBytesStatusCode > 599) {
+			return NewErrInvalidMaxResponseBytesStatusCode(o.MaxResponseBytesStatusCode, k)
+		}
+
+		if o.FollowRedirects {
+			if o.MaxRedirects < 1 {
+				return NewErrInvalidMaxRedirects(o.MaxRedirects, k)
+			}
+			if len(o.RedirectAllowedHosts) == 0 {
+				return NewErrMissingRedirectAllowedHosts(k)
+			}
+		}
+
+		o.CollapsedForwardingTimeout = time.Duration(o.CollapsedForwardingTimeoutMS) * time.Millisecond
+		if o.CollapsedForwardingTimeoutActionName != "" {
+			a, ok := forwarding.CollapsedForwardingTimeoutActionNames[o.CollapsedForwardingTimeoutActionName]
+			if !ok {
+				return NewErrInvalidCollapsedForwardingTimeoutAction(o.CollapsedForwardingTimeoutActionName, k)
+			}
+			o.CollapsedForwardingTimeoutAction = a
+		}
+
+		if o.FillPolicyName != "" {
+			fp, ok := timeseries.FillPolicyNames[o.FillPolicyName]
+			if !ok {
+				return NewErrInvalidFillPolicy(o.FillPolicyName, k)
+			}
+			o.FillPolicy = fp
+		}
+
+		o.ShadowTimeout = time.Duration(o.ShadowTimeoutMS) * time.Millisecond
+		if o.ShadowOrigin != "" {
+			su, err := url.Parse(o.ShadowOrigin)
+			if err != nil || su.Scheme == "" || su.Host == "" {
+				return NewErrInvalidShadowOrigin(o.ShadowOrigin, k)
+			}
+			if o.ShadowSampleRate < 0 || o.ShadowSampleRate > 1 {
+				return NewErrInvalidShadowSampleRate(o.ShadowSampleRate, k)
+			}
+			su.Path = strings.TrimSuffix(su.Path, "/")
+			o.Shadow = shadow.New(k, su, o.ShadowSampleRate, o.ShadowTimeout)
+		}
+
+		for _, u := range o.UpstreamHosts {
+			if u == nil || u.Host == "" || u.Weight < 0 {
+				var h string
+				if u != nil {
+					h = u.Host
+				}
+				return NewErrInvalidUpstreamHost(h, k)
+			}
+			if u.Weight == 0 {
+				u.Weight = 1
+			}
+		}
+
+		if o.SigV4 != nil {
+			if err := o.SigV4.Validate(); err != nil {
+				return NewErrInvalidSigV4(k, err)
+			}
+		}
+		if o.OAuth2 != nil {
+			if o.SigV4 != nil {
+				return NewErrInvalidOAuth2(k, errOAuth2WithSigV4)
+			}
+			if err := o.OAuth2.Validate(); err != nil {
+				return NewErrInvalidOAuth2(k, err)
+			}
+		}
+
+		o.CacheEventNotifier = nil
+		if o.CacheEvents != nil {
+			if err := o.CacheEvents.Validate(); err != nil {
+				return NewErrInvalidCacheEvents(k, err)
+			}
+			o.CacheEventNotifier = cacheevents.New(k, o.CacheEvents)
+		}
+
+		tp, bad, err := headers.ParseTrustedProxies(o.TrustedProxyCIDRs)
+		if err != nil {
+			return NewErrInvalidTrustedProxyCIDR(bad, k, err)
+		}
+		o.TrustedProxies = tp
+
+		if o.UpstreamHostHeader != "" && !httpguts.ValidHostHeader(o.UpstreamHostHeader) {
+			return NewErrInvalidUpstreamHostHeader(o.UpstreamHostHeader, k)
+		}
+
+		o.PathRewriter = nil
+		if len(o.PathRewriteRules) > 0 {
+			o.PathRewriter = urls.NewPathRewriter(o.PathRewriteStopOnMatch)
+			for _, pr := range o.PathRewriteRules {
+				if pr == nil {
+					return NewErrInvalidPathRewriteRule("", k, errEmptyPathRewriteRule)
+				}
+				if err := o.PathRewriter.AddRule(pr.Match, pr.Replacement); err != nil {
+					return NewErrInvalidPathRewriteRule(pr.Match, k, err)
+				}
+			}
+		}
+
+		if o.CacheablePathPatterns, err = compilePathPatterns(o.CacheablePaths,
+			"cacheable_paths", k); err != nil {
+			return err
+		}
+		if o.NonCacheablePathPatterns, err = compilePathPatterns(o.NonCacheablePaths,
+			"non_cacheable_paths", k); err != nil {
+			return err
+		}
+
 		if o.CompressibleTypeList != nil {
 			o.CompressibleTypes = make(map[string]interface{})
 			for _, v := range o.CompressibleTypeList {
@@ -522,6 +1024,10 @@ func SetDefaults(
 		no.Provider = o.Provider
 	}
 
+	if metadata.IsDefined("backends", name, "request_header_injections") {
+		no.RequestHeaderInjections = o.RequestHeaderInjections
+	}
+
 	if metadata.IsDefined("backends", name, "rule_name") {
 		no.RuleName = o.RuleName
 	}
@@ -546,6 +1052,10 @@ func SetDefaults(
 		no.ForwardedHeaders = o.ForwardedHeaders
 	}
 
+	if metadata.IsDefined("backends", name, "trusted_proxy_cidrs") {
+		no.TrustedProxyCIDRs = o.TrustedProxyCIDRs
+	}
+
 	if metadata.IsDefined("backends", name, "require_tls") {
 		no.RequireTLS = o.RequireTLS
 	}
@@ -559,10 +1069,114 @@ func SetDefaults(
 		no.CacheKeyPrefix = o.CacheKeyPrefix
 	}
 
+	if metadata.IsDefined("backends", name, "cache_key_include_path") {
+		no.CacheKeyIncludePath = o.CacheKeyIncludePath
+	}
+
+	if metadata.IsDefined("backends", name, "allow_cache_bypass_header") {
+		no.AllowCacheBypassHeader = o.AllowCacheBypassHeader
+	}
+
+	if metadata.IsDefined("backends", name, "cache_bypass_header") {
+		no.CacheBypassHeader = o.CacheBypassHeader
+	}
+
+	if metadata.IsDefined("backends", name, "refresh_on_client_no_cache") {
+		no.RefreshOnClientNoCache = o.RefreshOnClientNoCache
+	}
+
 	if metadata.IsDefined("backends", name, "origin_url") {
 		no.OriginURL = o.OriginURL
 	}
 
+	if metadata.IsDefined("backends", name, "upstream_hosts") {
+		no.UpstreamHosts = o.UpstreamHosts
+	}
+
+	if metadata.IsDefined("backends", name, "upstream_host_header") {
+		no.UpstreamHostHeader = o.UpstreamHostHeader
+	}
+
+	if metadata.IsDefined("backends", name, "upstream_user_agent") {
+		no.UpstreamUserAgent = o.UpstreamUserAgent
+	}
+
+	if metadata.IsDefined("backends", name, "allow_websocket_upgrade") {
+		no.AllowWebSocketUpgrade = o.AllowWebSocketUpgrade
+	}
+
+	if metadata.IsDefined("backends", name, "path_rewrite_rules") {
+		no.PathRewriteRules = o.PathRewriteRules
+	}
+
+	if metadata.IsDefined("backends", name, "path_rewrite_stop_on_match") {
+		no.PathRewriteStopOnMatch = o.PathRewriteStopOnMatch
+	}
+
+	if metadata.IsDefined("backends", name, "path_rewrite_cache_key") {
+		no.PathRewriteCacheKey = o.PathRewriteCacheKey
+	}
+
+	if metadata.IsDefined("backends", name, "cacheable_paths") {
+		no.CacheablePaths = o.CacheablePaths
+	}
+
+	if metadata.IsDefined("backends", name, "non_cacheable_paths") {
+		no.NonCacheablePaths = o.NonCacheablePaths
+	}
+
+	if metadata.IsDefined("backends", name, "circuit_breaker_failure_threshold") {
+		no.CircuitBreakerFailureThreshold = o.CircuitBreakerFailureThreshold
+	}
+
+	if metadata.IsDefined("backends", name, "circuit_breaker_window_ms") {
+		no.CircuitBreakerWindowMS = o.CircuitBreakerWindowMS
+	}
+
+	if metadata.IsDefined("backends", name, "circuit_breaker_cooldown_ms") {
+		no.CircuitBreakerCooldownMS = o.CircuitBreakerCooldownMS
+	}
+
+	if metadata.IsDefined("backends", name, "circuit_breaker_status_code") {
+		no.CircuitBreakerStatusCode = o.CircuitBreakerStatusCode
+	}
+
+	if metadata.IsDefined("backends", name, "max_concurrent_upstream") {
+		no.MaxConcurrentUpstream = o.MaxConcurrentUpstream
+	}
+
+	if metadata.IsDefined("backends", name, "upstream_queue_timeout_ms") {
+		no.UpstreamQueueTimeoutMS = o.UpstreamQueueTimeoutMS
+	}
+
+	if metadata.IsDefined("backends", name, "emit_server_timing") {
+		no.EmitServerTiming = o.EmitServerTiming
+	}
+
+	if metadata.IsDefined("backends", name, "collapsed_forwarding_timeout_ms") {
+		no.CollapsedForwardingTimeoutMS = o.CollapsedForwardingTimeoutMS
+	}
+
+	if metadata.IsDefined("backends", name, "collapsed_forwarding_timeout_action") {
+		no.CollapsedForwardingTimeoutActionName = o.CollapsedForwardingTimeoutActionName
+	}
+
+	if metadata.IsDefined("backends", name, "fill_policy") {
+		no.FillPolicyName = strings.ToLower(o.FillPolicyName)
+	}
+
+	if metadata.IsDefined("backends", name, "shadow_origin") {
+		no.ShadowOrigin = o.ShadowOrigin
+	}
+
+	if metadata.IsDefined("backends", name, "shadow_sample_rate") {
+		no.ShadowSampleRate = o.ShadowSampleRate
+	}
+
+	if metadata.IsDefined("backends", name, "shadow_timeout_ms") {
+		no.ShadowTimeoutMS = o.ShadowTimeoutMS
+	}
+
 	if metadata.IsDefined("backends", name, "compressible_types") {
 		no.CompressibleTypeList = o.CompressibleTypeList
 	}
@@ -571,6 +1185,14 @@ func SetDefaults(
 		no.TimeoutMS = o.TimeoutMS
 	}
 
+	if metadata.IsDefined("backends", name, "origin_timeout_ms") {
+		no.OriginTimeoutMS = o.OriginTimeoutMS
+	}
+
+	if metadata.IsDefined("backends", name, "client_response_timeout_ms") {
+		no.ClientResponseTimeoutMS = o.ClientResponseTimeoutMS
+	}
+
 	if metadata.IsDefined("backends", name, "max_idle_conns") {
 		no.MaxIdleConns = o.MaxIdleConns
 	}
@@ -610,6 +1232,14 @@ func SetDefaults(
 		no.MaxTTLMS = o.MaxTTLMS
 	}
 
+	if metadata.IsDefined("backends", name, "min_ttl_ms") {
+		no.MinTTLMS = o.MinTTLMS
+	}
+
+	if metadata.IsDefined("backends", name, "non_cacheable_response_headers") {
+		no.NonCacheableResponseHeaders = o.NonCacheableResponseHeaders
+	}
+
 	if metadata.IsDefined("backends", name, "fastforward_ttl_ms") {
 		no.FastForwardTTLMS = o.FastForwardTTLMS
 	}
@@ -622,6 +1252,10 @@ func SetDefaults(
 		no.BackfillToleranceMS = o.BackfillToleranceMS
 	}
 
+	if metadata.IsDefined("backends", name, "backfill_tolerance") {
+		no.BackfillToleranceDuration = o.BackfillToleranceDuration
+	}
+
 	if metadata.IsDefined("backends", name, "backfill_tolerance_points") {
 		no.BackfillTolerancePoints = o.BackfillTolerancePoints
 	}
@@ -631,6 +1265,7 @@ func SetDefaults(
 		if err != nil {
 			return nil, err
 		}
+		no.Paths = o.Paths
 	}
 
 	if metadata.IsDefined("backends", name, "alb") {
@@ -658,10 +1293,22 @@ func SetDefaults(
 		}
 	}
 
+	if metadata.IsDefined("backends", name, "encode_for_client") {
+		no.EncodeForClient = o.EncodeForClient
+	}
+
+	if metadata.IsDefined("backends", name, "healthcheck_critical") {
+		no.HealthCheckCritical = o.HealthCheckCritical
+	}
+
 	if metadata.IsDefined("backends", name, "max_object_size_bytes") {
 		no.MaxObjectSizeBytes = o.MaxObjectSizeBytes
 	}
 
+	if metadata.IsDefined("backends", name, "max_cacheable_object_bytes") {
+		no.MaxCacheableObjectBytes = o.MaxCacheableObjectBytes
+	}
+
 	if metadata.IsDefined("backends", name, "revalidation_factor") {
 		no.RevalidationFactor = o.RevalidationFactor
 	}
@@ -670,6 +1317,34 @@ func SetDefaults(
 		no.MultipartRangesDisabled = o.MultipartRangesDisabled
 	}
 
+	if metadata.IsDefined("backends", name, "max_ranges_per_request") {
+		no.MaxRangesPerRequest = o.MaxRangesPerRequest
+	}
+
+	if metadata.IsDefined("backends", name, "max_ranges_status_code") {
+		no.MaxRangesStatusCode = o.MaxRangesStatusCode
+	}
+
+	if metadata.IsDefined("backends", name, "max_response_bytes") {
+		no.MaxResponseBytes = o.MaxResponseBytes
+	}
+
+	if metadata.IsDefined("backends", name, "max_response_bytes_status_code") {
+		no.MaxResponseBytesStatusCode = o.MaxResponseBytesStatusCode
+	}
+
+	if metadata.IsDefined("backends", name, "follow_redirects") {
+		no.FollowRedirects = o.FollowRedirects
+	}
+
+	if metadata.IsDefined("backends", name, "max_redirects") {
+		no.MaxRedirects = o.MaxRedirects
+	}
+
+	if metadata.IsDefined("backends", name, "redirect_allowed_hosts") {
+		no.RedirectAllowedHosts = o.RedirectAllowedHosts
+	}
+
 	if metadata.IsDefined("backends", name, "dearticulate_upstream_ranges") {
 		no.DearticulateUpstreamRanges = o.DearticulateUpstreamRanges
 	}
@@ -685,10 +1360,26 @@ func SetDefaults(
 		}
 	}
 
+	if metadata.IsDefined("backends", name, "sigv4") && o.SigV4 != nil {
+		no.SigV4 = o.SigV4.Clone()
+	}
+
+	if metadata.IsDefined("backends", name, "oauth2") && o.OAuth2 != nil {
+		no.OAuth2 = o.OAuth2.Clone()
+	}
+
+	if metadata.IsDefined("backends", name, "cache_events") && o.CacheEvents != nil {
+		no.CacheEvents = o.CacheEvents.Clone()
+	}
+
 	if metadata.IsDefined("backends", name, "prometheus") {
 		no.Prometheus = o.Prometheus.Clone()
 	}
 
+	if metadata.IsDefined("backends", name, "clickhouse") {
+		no.ClickHouse = o.ClickHouse.Clone()
+	}
+
 	if metadata.IsDefined("backends", name, "latency_min_ms") {
 		no.LatencyMinMS = o.LatencyMinMS
 	}
@@ -710,11 +1401,25 @@ func (o *Options) CloneYAMLSafe() *Options {
 		w.KeyHasher = nil
 		headers.HideAuthorizationCredentials(w.RequestHeaders)
 		headers.HideAuthorizationCredentials(w.ResponseHeaders)
+		if w.CacheKeyJWT != nil && w.CacheKeyJWT.Key != "" {
+			w.CacheKeyJWT.Key = "*****"
+		}
 	}
 	if co.HealthCheck != nil {
 		// also strip out potentially sensitive headers
 		headers.HideAuthorizationCredentials(co.HealthCheck.Headers)
 	}
+	if co.SigV4 != nil {
+		if co.SigV4.SecretAccessKey != "" {
+			co.SigV4.SecretAccessKey = "*****"
+		}
+		if co.SigV4.SessionToken != "" {
+			co.SigV4.SessionToken = "*****"
+		}
+	}
+	if co.OAuth2 != nil && co.OAuth2.ClientSecret != "" {
+		co.OAuth2.ClientSecret = "*****"
+	}
 	return co
 }
 
@@ -724,3 +1429,99 @@ func (o *Options) ToYAML() string {
 	b, _ := yaml.Marshal(co)
 	return string(b)
 }
+
+// CompileHeaderInjections compiles the request header injection templates for the
+// Backend and its Paths, and returns a loader warning for each template that is invalid
+func (o *Options) CompileHeaderInjections() []string {
+	var lw []string
+	var errs map[string]error
+	o.HeaderInjections, errs = headers.CompileInjections(o.RequestHeaderInjections)
+	for k, err := range errs {
+		lw = append(lw, fmt.Sprintf("request_header_injections header %s in backend options %s "+
+			"is invalid and will not be injected: %s", k, o.Name, err.Error()))
+	}
+	sort.Strings(lw)
+	return append(lw, po.Lookup(o.Paths).CompileHeaderInjections(o.Name)...)
+}
+
+// cacheKeyPrefixData provides the fields that are available to cache_key_prefix templates
+type cacheKeyPrefixData struct {
+	Name       string
+	Provider   string
+	Host       string
+	InstanceID int
+}
+
+// ResolveCacheKeyPrefix evaluates the CacheKeyPrefix as a template, which may reference
+// the backend's {{ .Name }}, {{ .Provider }} and {{ .Host }}, and the Trickster
+// {{ .InstanceID }}, so that backends sharing a cache write to distinct key namespaces.
+// Prefixes without template actions are unchanged. This must be called after Validate
+func (o *Options) ResolveCacheKeyPrefix(instanceID int) error {
+	if !strings.Contains(o.CacheKeyPrefix, "{{") {
+		return nil
+	}
+	t, err := template.New("cache_key_prefix").Option("missingkey=error").Parse(o.CacheKeyPrefix)
+	if err != nil {
+		return NewErrInvalidCacheKeyPrefix(o.CacheKeyPrefix, o.Name, err)
+	}
+	var sb strings.Builder
+	err = t.Execute(&sb, &cacheKeyPrefixData{Name: o.Name, Provider: o.Provider,
+		Host: o.Host, InstanceID: instanceID})
+	if err != nil {
+		return NewErrInvalidCacheKeyPrefix(o.CacheKeyPrefix, o.Name, err)
+	}
+	o.CacheKeyPrefix = sb.String()
+	if o.CacheKeyPrefix == "" {
+		o.CacheKeyPrefix = o.Host
+	}
+	return nil
+}
+
+// RewriteUpstreamPath returns the upstream request path as rewritten by the backend's
+// PathRewriteRules. Rules are matched against the portion of the path following the
+// OriginURL's path prefix, which is preserved
+func (o *Options) RewriteUpstreamPath(path string) string {
+	if o.PathRewriter == nil || !strings.HasPrefix(path, o.PathPrefix) {
+		return path
+	}
+	return o.PathPrefix + o.PathRewriter.Rewrite(path[len(o.PathPrefix):])
+}
+
+// IsCacheablePath returns true if requests for the upstream path may be served from and
+// written to the cache, based on the backend's CacheablePaths and NonCacheablePaths. Both
+// are matched against the portion of the path following the OriginURL's path prefix
+func (o *Options) IsCacheablePath(path string) bool {
+	if len(o.CacheablePathPatterns) == 0 && len(o.NonCacheablePathPatterns) == 0 {
+		return true
+	}
+	path = strings.TrimPrefix(path, o.PathPrefix)
+	for _, re := range o.NonCacheablePathPatterns {
+		if re.MatchString(path) {
+			return false
+		}
+	}
+	if len(o.CacheablePathPatterns) == 0 {
+		return true
+	}
+	for _, re := range o.CacheablePathPatterns {
+		if re.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+func compilePathPatterns(patterns []string, key, backendName string) ([]*regexp.Regexp, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	out := make([]*regexp.Regexp, len(patterns))
+	for i, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, NewErrInvalidPathPattern(p, key, backendName, err)
+		}
+		out[i] = re
+	}
+	return out, nil
+}
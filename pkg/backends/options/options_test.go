@@ -28,9 +28,14 @@ import (
 	ro "github.com/trickstercache/trickster/v2/pkg/backends/rule/options"
 	"github.com/trickstercache/trickster/v2/pkg/cache/negative"
 	co "github.com/trickstercache/trickster/v2/pkg/cache/options"
+	ceo "github.com/trickstercache/trickster/v2/pkg/proxy/cacheevents/options"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+	oao "github.com/trickstercache/trickster/v2/pkg/proxy/oauth2/options"
 	po "github.com/trickstercache/trickster/v2/pkg/proxy/paths/options"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/request/rewriter"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/shadow"
+	svo "github.com/trickstercache/trickster/v2/pkg/proxy/sigv4/options"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/upstreamlimit"
 	tlstest "github.com/trickstercache/trickster/v2/pkg/testutil/tls"
 	"github.com/trickstercache/trickster/v2/pkg/util/yamlx"
 
@@ -81,10 +86,23 @@ func TestClone(t *testing.T) {
 	o.HealthCheck = &ho.Options{}
 	o.FastForwardPath = p
 	o.RuleOptions = &ro.Options{}
+	o.NonCacheableResponseHeaders = []string{headers.NameSetCookie}
+	o.Shadow = &shadow.Mirror{}
+	o.UpstreamLimiter = upstreamlimit.New("test", 1, time.Second)
 	o2 := o.Clone()
 	if o2.CacheName != "test" {
 		t.Error("clone failed")
 	}
+	if o2.Shadow != o.Shadow {
+		t.Error("expected cloned shadow mirror")
+	}
+	if o2.UpstreamLimiter != o.UpstreamLimiter {
+		t.Error("expected cloned upstream limiter")
+	}
+	o.NonCacheableResponseHeaders[0] = "X-Request-ID"
+	if o2.NonCacheableResponseHeaders[0] != headers.NameSetCookie {
+		t.Errorf("expected %s got %s", headers.NameSetCookie, o2.NonCacheableResponseHeaders[0])
+	}
 
 }
 
@@ -204,6 +222,10 @@ func TestValidate(t *testing.T) {
 	var errType01 = NewErrInvalidNegativeCacheName("invalid").(*ErrInvalidNegativeCacheName)
 	var errType02 = NewErrMissingOriginURL("test").(*ErrMissingOriginURL)
 	var errType03 = NewErrMissingProvider("test").(*ErrMissingProvider)
+	var errType04 = NewErrInvalidBackfillTolerance("x", "test").(*ErrInvalidBackfillTolerance)
+	var errType05 = NewErrInvalidCollapsedForwardingTimeoutAction("x",
+		"test").(*ErrInvalidCollapsedForwardingTimeoutAction)
+	var errType06 = NewErrInvalidFillPolicy("x", "test").(*ErrInvalidFillPolicy)
 
 	// string value tests
 	tests := []struct {
@@ -254,6 +276,42 @@ func TestValidate(t *testing.T) {
 			val:      "",
 			expected: nil,
 		},
+		{ // 6 - invalid backfill tolerance
+			to:       to,
+			loc:      &o.BackfillToleranceDuration,
+			val:      "x",
+			expected: errType04,
+		},
+		{ // 7 - valid backfill tolerance
+			to:       to,
+			loc:      &o.BackfillToleranceDuration,
+			val:      "250ms",
+			expected: nil,
+		},
+		{ // 8 - invalid collapsed forwarding timeout action
+			to:       to,
+			loc:      &o.CollapsedForwardingTimeoutActionName,
+			val:      "x",
+			expected: errType05,
+		},
+		{ // 9 - valid collapsed forwarding timeout action
+			to:       to,
+			loc:      &o.CollapsedForwardingTimeoutActionName,
+			val:      "fail",
+			expected: nil,
+		},
+		{ // 10 - invalid fill policy
+			to:       to,
+			loc:      &o.FillPolicyName,
+			val:      "x",
+			expected: errType06,
+		},
+		{ // 11 - valid fill policy
+			to:       to,
+			loc:      &o.FillPolicyName,
+			val:      "previous",
+			expected: nil,
+		},
 	}
 
 	for i, test := range tests {
@@ -347,6 +405,581 @@ func TestValidate(t *testing.T) {
 
 }
 
+func TestValidateBackfillTolerance(t *testing.T) {
+
+	o, err := fromTestYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := Lookup{o.Name: o}
+
+	// the ms form is used when no duration is provided
+	o.BackfillToleranceMS = 1500
+	err = l.Validate(testNegativeCaches())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.BackfillTolerance != 1500*time.Millisecond {
+		t.Errorf("expected %s got %s", 1500*time.Millisecond, o.BackfillTolerance)
+	}
+
+	// the duration form overrides the ms form
+	o.BackfillToleranceDuration = "250us"
+	err = l.Validate(testNegativeCaches())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.BackfillTolerance != 250*time.Microsecond {
+		t.Errorf("expected %s got %s", 250*time.Microsecond, o.BackfillTolerance)
+	}
+}
+
+func TestValidateUpstreamHosts(t *testing.T) {
+
+	o, err := fromTestYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := Lookup{o.Name: o}
+
+	o.UpstreamHosts = []*UpstreamHost{{Host: "a:9090"}, {Host: "b:9090", Weight: 3}}
+	err = l.Validate(testNegativeCaches())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.UpstreamHosts[0].Weight != 1 {
+		t.Errorf("expected default weight %d got %d", 1, o.UpstreamHosts[0].Weight)
+	}
+	if o2 := o.Clone(); len(o2.UpstreamHosts) != 2 || o2.UpstreamHosts[1].Weight != 3 {
+		t.Error("expected upstream hosts to be cloned")
+	}
+
+	var expected *ErrInvalidUpstreamHost
+	for _, u := range []*UpstreamHost{{Host: ""}, {Host: "c:9090", Weight: -1}, nil} {
+		o.UpstreamHosts = []*UpstreamHost{u}
+		err = l.Validate(testNegativeCaches())
+		if !errors.As(err, &expected) {
+			t.Errorf("expected ErrInvalidUpstreamHost got %v", err)
+		}
+	}
+}
+
+func TestValidateUpstreamHostHeader(t *testing.T) {
+
+	o, err := fromTestYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := Lookup{o.Name: o}
+
+	o.UpstreamHostHeader = "origin.example.com:8080"
+	err = l.Validate(testNegativeCaches())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o2 := o.Clone(); o2.UpstreamHostHeader != o.UpstreamHostHeader {
+		t.Errorf("expected %s got %s", o.UpstreamHostHeader, o2.UpstreamHostHeader)
+	}
+
+	var expected *ErrInvalidUpstreamHostHeader
+	o.UpstreamHostHeader = "origin example.com"
+	err = l.Validate(testNegativeCaches())
+	if !errors.As(err, &expected) {
+		t.Errorf("expected ErrInvalidUpstreamHostHeader got %v", err)
+	}
+}
+
+func TestValidateSigV4(t *testing.T) {
+
+	o, err := fromTestYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := Lookup{o.Name: o}
+
+	o.SigV4 = &svo.Options{Region: "us-east-1", Service: "aps",
+		CredentialSource: svo.CredentialSourceStatic, AccessKeyID: "AKID", SecretAccessKey: "SECRET",
+		SessionToken: "TOKEN"}
+	err = l.Validate(testNegativeCaches())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o2 := o.Clone(); *o2.SigV4 != *o.SigV4 {
+		t.Errorf("expected %v got %v", o.SigV4, o2.SigV4)
+	}
+	if co := o.CloneYAMLSafe(); co.SigV4.SecretAccessKey != "*****" ||
+		o.SigV4.SecretAccessKey != "SECRET" {
+		t.Errorf("expected masked secret access key got %s", co.SigV4.SecretAccessKey)
+	}
+	if co := o.CloneYAMLSafe(); co.SigV4.SessionToken != "*****" ||
+		o.SigV4.SessionToken != "TOKEN" {
+		t.Errorf("expected masked session token got %s", co.SigV4.SessionToken)
+	}
+
+	var expected *ErrInvalidSigV4
+	o.SigV4.Region = ""
+	err = l.Validate(testNegativeCaches())
+	if !errors.As(err, &expected) {
+		t.Errorf("expected ErrInvalidSigV4 got %v", err)
+	}
+}
+
+func TestValidateOAuth2(t *testing.T) {
+
+	o, err := fromTestYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := Lookup{o.Name: o}
+
+	o.OAuth2 = &oao.Options{TokenURL: "https://auth.example.com/oauth2/token",
+		ClientID: "trickster", ClientSecret: "SECRET", Scopes: []string{"metrics.read"}}
+	err = l.Validate(testNegativeCaches())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o2 := o.Clone(); o2.OAuth2.ClientID != o.OAuth2.ClientID ||
+		o2.OAuth2.Scopes[0] != o.OAuth2.Scopes[0] {
+		t.Errorf("expected %v got %v", o.OAuth2, o2.OAuth2)
+	}
+	if co := o.CloneYAMLSafe(); co.OAuth2.ClientSecret != "*****" ||
+		o.OAuth2.ClientSecret != "SECRET" {
+		t.Errorf("expected masked client secret got %s", co.OAuth2.ClientSecret)
+	}
+
+	var expected *ErrInvalidOAuth2
+	o.SigV4 = &svo.Options{Region: "us-east-1", Service: "aps"}
+	err = l.Validate(testNegativeCaches())
+	if !errors.As(err, &expected) {
+		t.Errorf("expected ErrInvalidOAuth2 got %v", err)
+	}
+
+	o.SigV4 = nil
+	o.OAuth2.TokenURL = ""
+	err = l.Validate(testNegativeCaches())
+	if !errors.As(err, &expected) {
+		t.Errorf("expected ErrInvalidOAuth2 got %v", err)
+	}
+}
+
+func TestValidateMaxResponseBytes(t *testing.T) {
+
+	o, err := fromTestYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := Lookup{o.Name: o}
+
+	if n := New(); n.MaxResponseBytes != 0 ||
+		n.MaxResponseBytesStatusCode != DefaultMaxResponseBytesStatusCode {
+		t.Errorf("expected %d/%d got %d/%d", 0, DefaultMaxResponseBytesStatusCode,
+			n.MaxResponseBytes, n.MaxResponseBytesStatusCode)
+	}
+
+	o.MaxResponseBytes = 1024
+	o.MaxResponseBytesStatusCode = DefaultMaxResponseBytesStatusCode
+	err = l.Validate(testNegativeCaches())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o2 := o.Clone(); o2.MaxResponseBytes != 1024 ||
+		o2.MaxResponseBytesStatusCode != DefaultMaxResponseBytesStatusCode {
+		t.Errorf("expected %d/%d got %d/%d", 1024, DefaultMaxResponseBytesStatusCode,
+			o2.MaxResponseBytes, o2.MaxResponseBytesStatusCode)
+	}
+
+	o.MaxResponseBytesStatusCode = 0
+	var expectedCode *ErrInvalidMaxResponseBytesStatusCode
+	err = l.Validate(testNegativeCaches())
+	if !errors.As(err, &expectedCode) {
+		t.Errorf("expected ErrInvalidMaxResponseBytesStatusCode got %v", err)
+	}
+
+	o.MaxResponseBytes = -1
+	var expected *ErrInvalidMaxResponseBytes
+	err = l.Validate(testNegativeCaches())
+	if !errors.As(err, &expected) {
+		t.Errorf("expected ErrInvalidMaxResponseBytes got %v", err)
+	}
+}
+
+func TestValidateMaxRanges(t *testing.T) {
+
+	o, err := fromTestYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := Lookup{o.Name: o}
+
+	if n := New(); n.MaxRangesPerRequest != DefaultMaxRangesPerRequest ||
+		n.MaxRangesStatusCode != DefaultMaxRangesStatusCode {
+		t.Errorf("expected %d/%d got %d/%d", DefaultMaxRangesPerRequest,
+			DefaultMaxRangesStatusCode, n.MaxRangesPerRequest, n.MaxRangesStatusCode)
+	}
+
+	o.MaxRangesPerRequest = 5
+	o.MaxRangesStatusCode = DefaultMaxRangesStatusCode
+	err = l.Validate(testNegativeCaches())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o2 := o.Clone(); o2.MaxRangesPerRequest != 5 ||
+		o2.MaxRangesStatusCode != DefaultMaxRangesStatusCode {
+		t.Errorf("expected %d/%d got %d/%d", 5, DefaultMaxRangesStatusCode,
+			o2.MaxRangesPerRequest, o2.MaxRangesStatusCode)
+	}
+
+	o.MaxRangesStatusCode = 600
+	var expectedCode *ErrInvalidMaxRangesStatusCode
+	err = l.Validate(testNegativeCaches())
+	if !errors.As(err, &expectedCode) {
+		t.Errorf("expected ErrInvalidMaxRangesStatusCode got %v", err)
+	}
+
+	o.MaxRangesPerRequest = 0
+	err = l.Validate(testNegativeCaches())
+	if err != nil {
+		t.Error(err)
+	}
+
+	o.MaxRangesPerRequest = -1
+	var expected *ErrInvalidMaxRangesPerRequest
+	err = l.Validate(testNegativeCaches())
+	if !errors.As(err, &expected) {
+		t.Errorf("expected ErrInvalidMaxRangesPerRequest got %v", err)
+	}
+}
+
+func TestValidateCircuitBreaker(t *testing.T) {
+
+	o, err := fromTestYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := Lookup{o.Name: o}
+
+	err = l.Validate(testNegativeCaches())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.CircuitBreaker != nil {
+		t.Error("expected nil circuit breaker when no threshold is set")
+	}
+
+	o.CircuitBreakerFailureThreshold = 3
+	o.CircuitBreakerCooldownMS = 5000
+	o.CircuitBreakerStatusCode = DefaultCircuitBreakerStatusCode
+	err = l.Validate(testNegativeCaches())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.CircuitBreaker == nil {
+		t.Error("expected non-nil circuit breaker")
+	}
+	if o.CircuitBreakerCooldown != 5*time.Second {
+		t.Errorf("expected %s got %s", 5*time.Second, o.CircuitBreakerCooldown)
+	}
+
+	o.CircuitBreakerStatusCode = 600
+	var expected *ErrInvalidCircuitBreakerStatusCode
+	err = l.Validate(testNegativeCaches())
+	if !errors.As(err, &expected) {
+		t.Errorf("expected ErrInvalidCircuitBreakerStatusCode got %v", err)
+	}
+}
+
+func TestValidateUpstreamLimiter(t *testing.T) {
+
+	o, err := fromTestYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := Lookup{o.Name: o}
+
+	err = l.Validate(testNegativeCaches())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.UpstreamLimiter != nil {
+		t.Error("expected nil upstream limiter when no limit is set")
+	}
+
+	o.MaxConcurrentUpstream = 2
+	o.UpstreamQueueTimeoutMS = 250
+	err = l.Validate(testNegativeCaches())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.UpstreamLimiter == nil {
+		t.Error("expected non-nil upstream limiter")
+	}
+	if o.UpstreamQueueTimeout != 250*time.Millisecond {
+		t.Errorf("expected %s got %s", 250*time.Millisecond, o.UpstreamQueueTimeout)
+	}
+
+	o.UpstreamQueueTimeoutMS = -1
+	var expectedTimeout *ErrInvalidUpstreamQueueTimeout
+	err = l.Validate(testNegativeCaches())
+	if !errors.As(err, &expectedTimeout) {
+		t.Errorf("expected ErrInvalidUpstreamQueueTimeout got %v", err)
+	}
+
+	o.MaxConcurrentUpstream = -1
+	var expected *ErrInvalidMaxConcurrentUpstream
+	err = l.Validate(testNegativeCaches())
+	if !errors.As(err, &expected) {
+		t.Errorf("expected ErrInvalidMaxConcurrentUpstream got %v", err)
+	}
+}
+
+func TestValidateTimeouts(t *testing.T) {
+
+	o, err := fromTestYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := Lookup{o.Name: o}
+
+	o.TimeoutMS = 5000
+	err = l.Validate(testNegativeCaches())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the origin timeout defaults to timeout_ms, and the client response time is unlimited
+	if o.OriginTimeout != 5*time.Second {
+		t.Errorf("expected %s got %s", 5*time.Second, o.OriginTimeout)
+	}
+	if o.ClientResponseTimeout != 0 {
+		t.Errorf("expected %d got %s", 0, o.ClientResponseTimeout)
+	}
+
+	o.OriginTimeoutMS = 1000
+	o.ClientResponseTimeoutMS = 10000
+	err = l.Validate(testNegativeCaches())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.OriginTimeout != time.Second {
+		t.Errorf("expected %s got %s", time.Second, o.OriginTimeout)
+	}
+	if o.ClientResponseTimeout != 10*time.Second {
+		t.Errorf("expected %s got %s", 10*time.Second, o.ClientResponseTimeout)
+	}
+
+	o.OriginTimeoutMS = -1
+	var expectedOrigin *ErrInvalidOriginTimeout
+	err = l.Validate(testNegativeCaches())
+	if !errors.As(err, &expectedOrigin) {
+		t.Errorf("expected ErrInvalidOriginTimeout got %v", err)
+	}
+
+	o.OriginTimeoutMS = 0
+	o.ClientResponseTimeoutMS = -1
+	var expectedClient *ErrInvalidClientResponseTimeout
+	err = l.Validate(testNegativeCaches())
+	if !errors.As(err, &expectedClient) {
+		t.Errorf("expected ErrInvalidClientResponseTimeout got %v", err)
+	}
+}
+
+func TestResolveCacheKeyPrefix(t *testing.T) {
+
+	const tmpl = "{{ .Provider }}.{{ .Name }}.{{ .InstanceID }}"
+	l := Lookup{}
+	for _, name := range []string{"a", "b"} {
+		o := New()
+		o.Provider = "prometheus"
+		o.OriginURL = "http://prometheus:9090"
+		o.CacheKeyPrefix = tmpl
+		o.NegativeCacheName = "test"
+		l[name] = o
+	}
+	err := l.Validate(testNegativeCaches())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range l {
+		if err = o.ResolveCacheKeyPrefix(2); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if v := l["a"].CacheKeyPrefix; v != "prometheus.a.2" {
+		t.Errorf("expected %s got %s", "prometheus.a.2", v)
+	}
+	// the same request to each backend must produce disjoint cache keys
+	const hash = ".opc.0123456789abcdef"
+	ka, kb := l["a"].CacheKeyPrefix+hash, l["b"].CacheKeyPrefix+hash
+	if ka == kb || strings.HasPrefix(ka, l["b"].CacheKeyPrefix+".") ||
+		strings.HasPrefix(kb, l["a"].CacheKeyPrefix+".") {
+		t.Errorf("expected disjoint keys got %s and %s", ka, kb)
+	}
+
+	// prefixes without templates, and empty prefixes, are unchanged
+	o := l["a"]
+	for _, test := range []struct{ prefix, expected string }{
+		{"example", "example"},
+		{"", "prometheus:9090"},
+	} {
+		o.CacheKeyPrefix = test.prefix
+		if err = l.Validate(testNegativeCaches()); err != nil {
+			t.Fatal(err)
+		}
+		if err = o.ResolveCacheKeyPrefix(2); err != nil {
+			t.Fatal(err)
+		}
+		if o.CacheKeyPrefix != test.expected {
+			t.Errorf("expected %s got %s", test.expected, o.CacheKeyPrefix)
+		}
+	}
+
+	var expected *ErrInvalidCacheKeyPrefix
+	for _, prefix := range []string{"{{ .Name ", "{{ .Unknown }}"} {
+		o.CacheKeyPrefix = prefix
+		err = o.ResolveCacheKeyPrefix(2)
+		if !errors.As(err, &expected) {
+			t.Errorf("expected ErrInvalidCacheKeyPrefix got %v", err)
+		}
+	}
+}
+
+func TestValidatePathRewriteRules(t *testing.T) {
+
+	o, err := fromTestYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := Lookup{o.Name: o}
+
+	o.PathRewriteRules = []*PathRewriteRule{{Match: `^/v1/metrics/(.*)$`, Replacement: "/api/$1"}}
+	err = l.Validate(testNegativeCaches())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.PathRewriter == nil || o.PathRewriter.Len() != 1 {
+		t.Fatal("expected path rewriter with 1 rule")
+	}
+	o.PathPrefix = "/base"
+	if v := o.RewriteUpstreamPath("/base/v1/metrics/cpu"); v != "/base/api/cpu" {
+		t.Errorf("expected %s got %s", "/base/api/cpu", v)
+	}
+	if o2 := o.Clone(); len(o2.PathRewriteRules) != 1 || o2.PathRewriteRules[0] == o.PathRewriteRules[0] {
+		t.Error("expected path rewrite rules to be cloned")
+	}
+
+	var expected *ErrInvalidPathRewriteRule
+	for _, pr := range []*PathRewriteRule{{Match: `^/v1/(.*`}, nil} {
+		o.PathRewriteRules = []*PathRewriteRule{pr}
+		err = l.Validate(testNegativeCaches())
+		if !errors.As(err, &expected) {
+			t.Errorf("expected ErrInvalidPathRewriteRule got %v", err)
+		}
+	}
+}
+
+func TestIsCacheablePath(t *testing.T) {
+
+	o, err := fromTestYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := Lookup{o.Name: o}
+
+	tests := []struct {
+		cacheable, nonCacheable []string
+		path                    string
+		expected                bool
+	}{
+		// no lists
+		{nil, nil, "/base/anything", true},
+		// allowlist only
+		{[]string{`^/static/`, `^/api/v1/query$`}, nil, "/base/static/app.js", true},
+		{[]string{`^/static/`, `^/api/v1/query$`}, nil, "/base/api/v1/query", true},
+		{[]string{`^/static/`, `^/api/v1/query$`}, nil, "/base/api/v1/labels", false},
+		// denylist only
+		{nil, []string{`^/admin/`}, "/base/admin/users", false},
+		{nil, []string{`^/admin/`}, "/base/static/app.js", true},
+		// overlapping, where the denylist wins
+		{[]string{`^/static/`}, []string{`\.json$`}, "/base/static/manifest.json", false},
+		{[]string{`^/static/`}, []string{`\.json$`}, "/base/static/app.js", true},
+		{[]string{`^/static/`}, []string{`\.json$`}, "/base/index.html", false},
+	}
+
+	for i, test := range tests {
+		o.CacheablePaths = test.cacheable
+		o.NonCacheablePaths = test.nonCacheable
+		if err := l.Validate(testNegativeCaches()); err != nil {
+			t.Fatal(err)
+		}
+		o.PathPrefix = "/base"
+		if v := o.IsCacheablePath(test.path); v != test.expected {
+			t.Errorf("test %d: expected %t got %t for %s", i, test.expected, v, test.path)
+		}
+	}
+
+	o.CacheablePaths = []string{`^/static/`}
+	o.NonCacheablePaths = nil
+	if err := l.Validate(testNegativeCaches()); err != nil {
+		t.Fatal(err)
+	}
+	if o2 := o.Clone(); len(o2.CacheablePaths) != 1 || len(o2.CacheablePathPatterns) != 1 {
+		t.Error("expected cacheable paths to be cloned")
+	}
+
+	var expected *ErrInvalidPathPattern
+	o.CacheablePaths = nil
+	o.NonCacheablePaths = []string{`^/admin/(.*`}
+	err = l.Validate(testNegativeCaches())
+	if !errors.As(err, &expected) {
+		t.Errorf("expected ErrInvalidPathPattern got %v", err)
+	}
+}
+
+func TestValidateShadow(t *testing.T) {
+
+	o, err := fromTestYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := Lookup{o.Name: o}
+
+	err = l.Validate(testNegativeCaches())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Shadow != nil {
+		t.Error("expected nil shadow mirror when no shadow origin is set")
+	}
+
+	o.ShadowOrigin = "http://shadow:9090/"
+	o.ShadowSampleRate = 0.25
+	o.ShadowTimeoutMS = 2000
+	err = l.Validate(testNegativeCaches())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Shadow == nil {
+		t.Error("expected non-nil shadow mirror")
+	}
+	if o.ShadowTimeout != 2*time.Second {
+		t.Errorf("expected %s got %s", 2*time.Second, o.ShadowTimeout)
+	}
+
+	o.ShadowSampleRate = 1.5
+	var expectedRate *ErrInvalidShadowSampleRate
+	err = l.Validate(testNegativeCaches())
+	if !errors.As(err, &expectedRate) {
+		t.Errorf("expected ErrInvalidShadowSampleRate got %v", err)
+	}
+
+	o.ShadowSampleRate = 1
+	o.ShadowOrigin = "shadow:9090"
+	var expectedOrigin *ErrInvalidShadowOrigin
+	err = l.Validate(testNegativeCaches())
+	if !errors.As(err, &expectedOrigin) {
+		t.Errorf("expected ErrInvalidShadowOrigin got %v", err)
+	}
+}
+
 func TestSetDefaults(t *testing.T) {
 
 	o, err := fromTestYAML()
@@ -495,3 +1128,127 @@ func TestToYAML(t *testing.T) {
 		t.Error("ToYAML mismatch", s)
 	}
 }
+
+func TestValidateTrustedProxyCIDRs(t *testing.T) {
+
+	o, err := fromTestYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := Lookup{o.Name: o}
+
+	o.TrustedProxyCIDRs = []string{"10.0.0.0/8", "192.168.1.1"}
+	err = l.Validate(testNegativeCaches())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !o.TrustedProxies.Contains("10.1.2.3") || !o.TrustedProxies.Contains("192.168.1.1") {
+		t.Errorf("expected trusted proxies got %v", o.TrustedProxies)
+	}
+	if o2 := o.Clone(); len(o2.TrustedProxyCIDRs) != 2 || len(o2.TrustedProxies) != 2 {
+		t.Errorf("expected %v got %v", o.TrustedProxyCIDRs, o2.TrustedProxyCIDRs)
+	}
+
+	var expected *ErrInvalidTrustedProxyCIDR
+	o.TrustedProxyCIDRs = []string{"10.0.0.0/33"}
+	err = l.Validate(testNegativeCaches())
+	if !errors.As(err, &expected) {
+		t.Errorf("expected ErrInvalidTrustedProxyCIDR got %v", err)
+	}
+}
+
+func TestValidateFollowRedirects(t *testing.T) {
+
+	o, err := fromTestYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := Lookup{o.Name: o}
+
+	o.FollowRedirects = true
+	o.MaxRedirects = DefaultMaxRedirects
+	o.RedirectAllowedHosts = []string{"us-east.example.com"}
+	err = l.Validate(testNegativeCaches())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o2 := o.Clone(); !o2.FollowRedirects || o2.MaxRedirects != DefaultMaxRedirects ||
+		len(o2.RedirectAllowedHosts) != 1 {
+		t.Errorf("unexpected clone %v %d %v", o2.FollowRedirects, o2.MaxRedirects,
+			o2.RedirectAllowedHosts)
+	}
+
+	var expected1 *ErrInvalidMaxRedirects
+	o.MaxRedirects = 0
+	err = l.Validate(testNegativeCaches())
+	if !errors.As(err, &expected1) {
+		t.Errorf("expected ErrInvalidMaxRedirects got %v", err)
+	}
+
+	var expected2 *ErrMissingRedirectAllowedHosts
+	o.MaxRedirects = DefaultMaxRedirects
+	o.RedirectAllowedHosts = nil
+	err = l.Validate(testNegativeCaches())
+	if !errors.As(err, &expected2) {
+		t.Errorf("expected ErrMissingRedirectAllowedHosts got %v", err)
+	}
+}
+
+func TestValidateCacheEvents(t *testing.T) {
+
+	o, err := fromTestYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := Lookup{o.Name: o}
+
+	o.CacheEvents = &ceo.Options{WebhookURL: "https://events.example.com/trickster"}
+	err = l.Validate(testNegativeCaches())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.CacheEventNotifier == nil {
+		t.Error("expected non-nil notifier")
+	}
+	if o2 := o.Clone(); o2.CacheEvents.WebhookURL != o.CacheEvents.WebhookURL ||
+		o2.CacheEventNotifier != o.CacheEventNotifier {
+		t.Errorf("expected %v got %v", o.CacheEvents, o2.CacheEvents)
+	}
+
+	var expected *ErrInvalidCacheEvents
+	o.CacheEvents.WebhookURL = "events"
+	err = l.Validate(testNegativeCaches())
+	if !errors.As(err, &expected) {
+		t.Errorf("expected ErrInvalidCacheEvents got %v", err)
+	}
+}
+
+func TestValidateMinTTL(t *testing.T) {
+
+	o, err := fromTestYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := Lookup{o.Name: o}
+
+	o.MinTTLMS = 60000
+	err = l.Validate(testNegativeCaches())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.MinTTL != time.Minute {
+		t.Errorf("expected %s got %s", time.Minute, o.MinTTL)
+	}
+	if o2 := o.Clone(); o2.MinTTLMS != 60000 || o2.MinTTL != time.Minute {
+		t.Errorf("unexpected clone %d %s", o2.MinTTLMS, o2.MinTTL)
+	}
+
+	var expected *ErrInvalidMinTTL
+	for _, v := range []int{-1, o.MaxTTLMS + 1} {
+		o.MinTTLMS = v
+		err = l.Validate(testNegativeCaches())
+		if !errors.As(err, &expected) {
+			t.Errorf("expected ErrInvalidMinTTL for %d got %v", v, err)
+		}
+	}
+}
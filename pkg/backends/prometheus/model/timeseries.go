@@ -231,6 +231,11 @@ func MarshalTSOrVectorWriter(ts timeseries.Timeseries, rlo *timeseries.RequestOp
 			sep = ""
 			sort.Sort(s.Points)
 			for _, p := range s.Points {
+				// the range format has no null values, so points with nil values
+				// (e.g., those inserted by a null fill policy) are omitted
+				if len(p.Values) == 0 || p.Values[0] == nil {
+					continue
+				}
 				w.Write([]byte(fmt.Sprintf(`%s[%s,"%s"]`,
 					sep,
 					strconv.FormatFloat(float64(p.Epoch)/1000000000, 'f', -1, 64),
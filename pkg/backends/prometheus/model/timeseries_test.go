@@ -141,3 +141,24 @@ func TestMarshalTSOrVectorWriter(t *testing.T) {
 	}
 
 }
+
+func TestMarshalTSOrVectorWriterNilValues(t *testing.T) {
+	w := httptest.NewRecorder()
+	s := &dataset.Series{
+		Points: []dataset.Point{
+			{Epoch: epoch.Epoch(5 * timeseries.Second), Values: []interface{}{"1"}},
+			{Epoch: epoch.Epoch(10 * timeseries.Second), Values: []interface{}{nil}},
+			{Epoch: epoch.Epoch(15 * timeseries.Second), Values: []interface{}{"3"}},
+		},
+	}
+	err := MarshalTSOrVectorWriter(&dataset.DataSet{
+		Results: []*dataset.Result{{SeriesList: []*dataset.Series{s}}},
+	}, nil, 0, w, false)
+	if err != nil {
+		t.Error(err)
+	}
+	const expected = `"values":[[5,"1"],[15,"3"]]`
+	if !bytes.Contains(w.Body.Bytes(), []byte(expected)) {
+		t.Errorf("expected %s in %s", expected, w.Body.String())
+	}
+}
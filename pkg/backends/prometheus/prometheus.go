@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"math"
 	"net/http"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -101,6 +102,34 @@ func NewClient(name string, o *bo.Options, router http.Handler,
 	return c, err
 }
 
+// reOffset matches an offset modifier, including one that directly follows the
+// closing bracket of a range selector or subquery, like rate(x[5m:1m])offset 1h
+var reOffset = regexp.MustCompile(`(^|[\s)\]}])offset\s`)
+
+// reAtStartEnd matches @ start() and @ end() modifiers
+var reAtStartEnd = regexp.MustCompile(`@\s*(start|end)\s*\(\s*\)`)
+
+// resolveAtModifiers replaces any @ start() and @ end() modifiers in the statement
+// with the literal start and end times of the provided extent, which is how
+// Prometheus resolves them. This keeps the results stable when Trickster requests
+// only a portion of the extent from the origin. The bool is true if any modifiers
+// were replaced.
+func resolveAtModifiers(stmt string, e timeseries.Extent) (string, bool) {
+	if !strings.Contains(stmt, "@") {
+		return stmt, false
+	}
+	var found bool
+	out := reAtStartEnd.ReplaceAllStringFunc(stmt, func(m string) string {
+		found = true
+		t := e.End
+		if strings.Contains(m, upStart) {
+			t = e.Start
+		}
+		return "@ " + strconv.FormatFloat(float64(t.UnixMilli())/1000, 'f', -1, 64)
+	})
+	return out, found
+}
+
 // parseTime converts a query time URL parameter to time.Time.
 // Copied from https://github.com/prometheus/prometheus/blob/master/web/api/v1/api.go
 func parseTime(s string) (time.Time, error) {
@@ -169,12 +198,22 @@ func (c *Client) ParseTimeRangeQuery(r *http.Request) (*timeseries.TimeRangeQuer
 		return nil, nil, false, errors.MissingURLParam(upStep)
 	}
 
-	if strings.Contains(trq.Statement, " offset ") {
+	rlo.ExtractFastForwardDisabled(trq.Statement)
+
+	// the outer start and end are authoritative for the extent, regardless of any
+	// @ modifiers or subqueries in the statement
+	if stmt, ok := resolveAtModifiers(trq.Statement, trq.Extent); ok {
+		trq.Statement = stmt
+		qp.Set(upQuery, stmt)
+		params.SetRequestValues(r, qp)
+		rlo.FastForwardDisable = true
+	}
+
+	if reOffset.MatchString(trq.Statement) {
 		trq.IsOffset = true
 		rlo.FastForwardDisable = true
 	}
 
-	rlo.ExtractFastForwardDisabled(trq.Statement)
 	trq.ExtractBackfillTolerance(trq.Statement)
 
 	if x := strings.Index(trq.Statement, timeseries.BackfillToleranceFlag); x > 1 {
@@ -215,7 +254,7 @@ func parseVectorQuery(r *http.Request, rounder time.Duration) (*timeseries.TimeR
 		trq.Extent.Start = time.Now().Truncate(rounder)
 	}
 
-	if strings.Contains(trq.Statement, " offset ") {
+	if reOffset.MatchString(trq.Statement) {
 		trq.IsOffset = true
 	}
 
@@ -364,6 +364,78 @@ func TestParseTimeRangeQueryWithOffset(t *testing.T) {
 
 }
 
+func TestParseTimeRangeQueryAtModifiersAndSubqueries(t *testing.T) {
+
+	const start, end = 1700000000, 1700021600
+
+	tests := []struct {
+		query, expected string
+		ffDisabled      bool
+		isOffset        bool
+	}{
+		{
+			query:    `rate(x[5m:1m])`,
+			expected: `rate(x[5m:1m])`,
+		},
+		{
+			query:    `max_over_time(rate(x[5m])[30m:1m])`,
+			expected: `max_over_time(rate(x[5m])[30m:1m])`,
+		},
+		{
+			query:    `rate(x[5m:1m])offset 1h`,
+			expected: `rate(x[5m:1m])offset 1h`, ffDisabled: true, isOffset: true,
+		},
+		{
+			query:    `x @ 1699990000`,
+			expected: `x @ 1699990000`,
+		},
+		{
+			query:      `topk(5, rate(x[5m] @ end())) and rate(x[5m:1m] @start())`,
+			expected:   `topk(5, rate(x[5m] @ 1700021600)) and rate(x[5m:1m] @ 1700000000)`,
+			ffDisabled: true,
+		},
+	}
+
+	client := &Client{}
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			req := &http.Request{URL: &url.URL{
+				Scheme: "https",
+				Host:   "blah.com",
+				Path:   "/",
+				RawQuery: url.Values(map[string][]string{
+					"query": {test.query},
+					"start": {strconv.Itoa(start)},
+					"end":   {strconv.Itoa(end)},
+					"step":  {"15"},
+				}).Encode(),
+			}}
+			trq, rlo, _, err := client.ParseTimeRangeQuery(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if trq.Extent.Start.Unix() != start || trq.Extent.End.Unix() != end {
+				t.Errorf("unexpected extent %s", trq.Extent.String())
+			}
+			if trq.Step != 15*time.Second {
+				t.Errorf("expected %s got %s", 15*time.Second, trq.Step)
+			}
+			if trq.Statement != test.expected {
+				t.Errorf("expected %s got %s", test.expected, trq.Statement)
+			}
+			if v := req.URL.Query().Get(upQuery); v != test.expected {
+				t.Errorf("expected %s got %s", test.expected, v)
+			}
+			if rlo.FastForwardDisable != test.ffDisabled {
+				t.Errorf("expected %t got %t", test.ffDisabled, rlo.FastForwardDisable)
+			}
+			if trq.IsOffset != test.isOffset {
+				t.Errorf("expected %t got %t", test.isOffset, trq.IsOffset)
+			}
+		})
+	}
+}
+
 func TestParseVectorQuery(t *testing.T) {
 
 	req := &http.Request{URL: &url.URL{
@@ -17,8 +17,16 @@
 package reverseproxycache
 
 import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
 	"testing"
 
+	"github.com/trickstercache/trickster/v2/pkg/backends"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/request"
 	tu "github.com/trickstercache/trickster/v2/pkg/testutil"
 )
@@ -50,3 +58,83 @@ func TestProxyCacheHandler(t *testing.T) {
 		t.Errorf("Expected status: 200 got %d.", resp.StatusCode)
 	}
 }
+
+func TestProxyCacheHandlerCacheAndRevalidate(t *testing.T) {
+
+	const body = `{"status":"ok"}`
+	var requests, revalidations int32
+	// a plain JSON API that is fresh for a minute under /fresh, and must
+	// be revalidated on every request under /revalidate
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set(headers.NameContentType, headers.ValueApplicationJSON)
+		w.Header().Set(headers.NameETag, `"v1"`)
+		if strings.HasPrefix(r.URL.Path, "/revalidate") {
+			w.Header().Set(headers.NameCacheControl, "max-age=0")
+			if r.Header.Get(headers.NameIfNoneMatch) == `"v1"` {
+				atomic.AddInt32(&revalidations, 1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		} else {
+			w.Header().Set(headers.NameCacheControl, "max-age=60")
+		}
+		w.Write([]byte(body))
+	}))
+	defer origin.Close()
+
+	ts, _, r, _, err := tu.NewTestInstance("", nil, 200, "{}", nil, "rpc", "/", "debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+	rsc := request.GetResources(r)
+	u, _ := url.Parse(origin.URL)
+	rsc.BackendOptions.Host = u.Host
+	backendClient, err := NewClient("test", rsc.BackendOptions, nil, rsc.CacheClient, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := backendClient.(backends.TimeseriesBackend); ok {
+		t.Error("expected rpc client to not be a timeseries backend")
+	}
+	client := backendClient.(*Client)
+	rsc.BackendClient = client
+	rsc.BackendOptions.HTTPClient = backendClient.HTTPClient()
+
+	tests := []struct {
+		path           string
+		expectedStatus string
+		expectedReqs   int32
+	}{
+		{"/fresh", "kmiss", 1},
+		{"/fresh", "hit", 1},
+		{"/revalidate", "kmiss", 2},
+		{"/revalidate", "rhit", 3},
+	}
+
+	for i, test := range tests {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "http://trickster"+test.path, nil).
+			WithContext(r.Context())
+		client.ProxyCacheHandler(w, req)
+		resp := w.Result()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("(%d) expected %d got %d", i, http.StatusOK, resp.StatusCode)
+		}
+		b, _ := io.ReadAll(resp.Body)
+		if string(b) != body {
+			t.Errorf("(%d) expected %s got %s", i, body, string(b))
+		}
+		if rh := resp.Header.Get(headers.NameTricksterResult); !strings.Contains(rh,
+			"status="+test.expectedStatus+";") && !strings.HasSuffix(rh, "status="+test.expectedStatus) {
+			t.Errorf("(%d) expected status %s got %s", i, test.expectedStatus, rh)
+		}
+		if n := atomic.LoadInt32(&requests); n != test.expectedReqs {
+			t.Errorf("(%d) expected %d origin requests got %d", i, test.expectedReqs, n)
+		}
+	}
+	if revalidations != 1 {
+		t.Errorf("expected %d revalidations got %d", 1, revalidations)
+	}
+}
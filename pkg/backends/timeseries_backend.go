@@ -58,6 +58,9 @@ type TimeseriesBackend interface {
 	Cache() cache.Cache
 	// Router returns a Router that handles HTTP Requests for this Backend
 	Router() http.Handler
+	// SetRouter replaces the Router that handles HTTP Requests for this Backend, so that
+	// its routes can be registered anew when the Backend is kept across a config reload
+	SetRouter(http.Handler)
 	// BaseUpstreamURL returns the base URL for upstream requests
 	BaseUpstreamURL() *url.URL
 	// Modeler returns the Modeler for converting between Datasets and wire documents
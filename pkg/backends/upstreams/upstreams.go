@@ -0,0 +1,148 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package upstreams provides weighted round-robin distribution of a backend's
+// proxied requests across multiple upstream hosts
+package upstreams
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/trickstercache/trickster/v2/pkg/backends/healthcheck"
+)
+
+// Target describes an upstream host and its relative weight
+type Target struct {
+	// Host is the host[:port] of the upstream
+	Host string
+	// Weight is the relative share of requests that are sent to the upstream
+	Weight int
+}
+
+type upstream struct {
+	host     string
+	weight   int
+	current  int
+	hcStatus *healthcheck.Status
+}
+
+// healthy returns false only when the upstream's health check has marked it
+// as failing. Upstreams without a health check are always considered healthy
+func (u *upstream) healthy() bool {
+	return u.hcStatus == nil || u.hcStatus.Get() >= 0
+}
+
+// Balancer is an http.RoundTripper that rewrites the host of requests bound for
+// the backend's origin host to one of its upstream hosts, selected by smooth
+// weighted round-robin, before passing them to the wrapped RoundTripper
+type Balancer struct {
+	originHost string
+	upstreams  []*upstream
+	transport  http.RoundTripper
+	mtx        sync.Mutex
+}
+
+// New returns a new Balancer for requests to originHost that are distributed
+// across the provided targets. Targets with a Weight < 1 receive a weight of 1
+func New(originHost string, next http.RoundTripper, targets ...Target) *Balancer {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	b := &Balancer{
+		originHost: originHost,
+		upstreams:  make([]*upstream, 0, len(targets)),
+		transport:  next,
+	}
+	for _, t := range targets {
+		w := t.Weight
+		if w < 1 {
+			w = 1
+		}
+		b.upstreams = append(b.upstreams, &upstream{host: t.Host, weight: w})
+	}
+	return b
+}
+
+// Hosts returns the list of upstream hosts in the Balancer
+func (b *Balancer) Hosts() []string {
+	out := make([]string, len(b.upstreams))
+	for i, u := range b.upstreams {
+		out[i] = u.host
+	}
+	return out
+}
+
+// SetHealthStatus associates the provided health check status with the named
+// upstream host, so that it is skipped while the status is failing
+func (b *Balancer) SetHealthStatus(host string, st *healthcheck.Status) {
+	b.mtx.Lock()
+	for _, u := range b.upstreams {
+		if u.host == host {
+			u.hcStatus = st
+		}
+	}
+	b.mtx.Unlock()
+}
+
+// Next returns the next upstream host. Unhealthy upstreams are skipped, unless
+// all upstreams are unhealthy, in which case all are eligible
+func (b *Balancer) Next() string {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if h := b.selectUpstream(true); h != "" {
+		return h
+	}
+	return b.selectUpstream(false)
+}
+
+// selectUpstream performs one round of smooth weighted round-robin selection
+func (b *Balancer) selectUpstream(healthyOnly bool) string {
+	var best *upstream
+	var total int
+	for _, u := range b.upstreams {
+		if healthyOnly && !u.healthy() {
+			continue
+		}
+		u.current += u.weight
+		total += u.weight
+		if best == nil || u.current > best.current {
+			best = u
+		}
+	}
+	if best == nil {
+		return ""
+	}
+	best.current -= total
+	return best.host
+}
+
+// RoundTrip implements http.RoundTripper
+func (b *Balancer) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.URL == nil || r.URL.Host != b.originHost || len(b.upstreams) == 0 {
+		return b.transport.RoundTrip(r)
+	}
+	// per the http.RoundTripper contract, the inbound request is not modified
+	r2 := new(http.Request)
+	*r2 = *r
+	u := *r.URL
+	u.Host = b.Next()
+	r2.URL = &u
+	if r2.Host == b.originHost {
+		r2.Host = ""
+	}
+	return b.transport.RoundTrip(r2)
+}
@@ -0,0 +1,141 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package upstreams
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/trickstercache/trickster/v2/pkg/backends/healthcheck"
+)
+
+type recordingTransport struct {
+	hosts map[string]int
+}
+
+func (rt *recordingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	rt.hosts[r.URL.Host]++
+	return &http.Response{StatusCode: http.StatusOK, Request: r}, nil
+}
+
+func TestWeightedDistribution(t *testing.T) {
+	const requests = 6000
+	rt := &recordingTransport{hosts: make(map[string]int)}
+	b := New("origin:9090", rt,
+		Target{Host: "a:9090", Weight: 1},
+		Target{Host: "b:9090", Weight: 2},
+		Target{Host: "c:9090", Weight: 3},
+	)
+
+	for i := 0; i < requests; i++ {
+		r, _ := http.NewRequest(http.MethodGet, "http://origin:9090/api/v1/query", nil)
+		if _, err := b.RoundTrip(r); err != nil {
+			t.Fatal(err)
+		}
+		if r.URL.Host != "origin:9090" {
+			t.Fatalf("inbound request should not be modified, got host %s", r.URL.Host)
+		}
+	}
+
+	for host, weight := range map[string]int{"a:9090": 1, "b:9090": 2, "c:9090": 3} {
+		expected := float64(requests) * float64(weight) / 6
+		if got := float64(rt.hosts[host]); math.Abs(got-expected) > expected*0.05 {
+			t.Errorf("host %s: expected ~%.0f requests got %.0f", host, expected, got)
+		}
+	}
+	if rt.hosts["origin:9090"] != 0 {
+		t.Errorf("expected no requests to the origin host, got %d", rt.hosts["origin:9090"])
+	}
+}
+
+func TestUnhealthyHostsSkipped(t *testing.T) {
+	b := New("origin", nil,
+		Target{Host: "a", Weight: 1},
+		Target{Host: "b", Weight: 5},
+	)
+	st := &healthcheck.Status{}
+	b.SetHealthStatus("b", st)
+
+	st.Set(-1)
+	for i := 0; i < 10; i++ {
+		if h := b.Next(); h != "a" {
+			t.Fatalf("expected %s got %s", "a", h)
+		}
+	}
+
+	// when every host is failing, all hosts are eligible rather than none
+	b.SetHealthStatus("a", st)
+	seen := make(map[string]bool)
+	for i := 0; i < 12; i++ {
+		seen[b.Next()] = true
+	}
+	if !seen["a"] || !seen["b"] {
+		t.Errorf("expected both hosts to be selected, got %v", seen)
+	}
+
+	st.Set(1)
+	counts := make(map[string]int)
+	for i := 0; i < 60; i++ {
+		counts[b.Next()]++
+	}
+	if counts["a"] != 10 || counts["b"] != 50 {
+		t.Errorf("expected 10/50 got %d/%d", counts["a"], counts["b"])
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	var hitsA, hitsB int
+	sa := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hitsA++
+	}))
+	defer sa.Close()
+	sb := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hitsB++
+	}))
+	defer sb.Close()
+	ua, _ := url.Parse(sa.URL)
+	ub, _ := url.Parse(sb.URL)
+
+	b := New("origin.example.com", nil, Target{Host: ua.Host}, Target{Host: ub.Host})
+	if len(b.Hosts()) != 2 {
+		t.Fatalf("expected %d got %d", 2, len(b.Hosts()))
+	}
+	c := &http.Client{Transport: b}
+	for i := 0; i < 4; i++ {
+		resp, err := c.Get("http://origin.example.com/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if hitsA != 2 || hitsB != 2 {
+		t.Errorf("expected 2/2 got %d/%d", hitsA, hitsB)
+	}
+
+	// requests to other hosts pass through unmodified
+	resp, err := c.Get(sa.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if hitsA != 3 {
+		t.Errorf("expected %d got %d", 3, hitsA)
+	}
+}
@@ -18,6 +18,8 @@
 package badger
 
 import (
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/trickstercache/trickster/v2/pkg/cache"
@@ -38,8 +40,16 @@ type Cache struct {
 	locker locks.NamedLocker
 
 	dbh *badger.DB
+
+	gcFunc func(float64) error
+	gcStop chan struct{}
+	gcDone chan struct{}
 }
 
+// gcDiscardRatio is the minimum fraction of a value log file that must be discardable
+// for the file to be rewritten during garbage collection
+const gcDiscardRatio = 0.5
+
 // Locker returns the cache's locker
 func (c *Cache) Locker() locks.NamedLocker {
 	return c.locker
@@ -68,9 +78,79 @@ func (c *Cache) Connect() error {
 		return err
 	}
 
+	if c.Config.Badger.GCIntervalSecs > 0 {
+		c.startGC(time.Duration(c.Config.Badger.GCIntervalSecs) * time.Second)
+	}
+
 	return nil
 }
 
+// startGC starts a goroutine that garbage collects the value log on the provided interval
+func (c *Cache) startGC(interval time.Duration) {
+	if c.gcFunc == nil {
+		c.gcFunc = c.dbh.RunValueLogGC
+	}
+	c.gcStop = make(chan struct{})
+	c.gcDone = make(chan struct{})
+	go c.gcScheduler(interval)
+}
+
+// gcScheduler runs a value log garbage collection cycle on each tick until the cache is closed
+func (c *Cache) gcScheduler(interval time.Duration) {
+	defer close(c.gcDone)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-c.gcStop:
+			return
+		case <-t.C:
+			c.runGC()
+		}
+	}
+}
+
+// runGC rewrites value log files until none are left with enough discardable data,
+// stopping early if the cache is closing
+func (c *Cache) runGC() {
+	before := c.valueLogSize()
+	var rewrites int
+	for {
+		select {
+		case <-c.gcStop:
+			return
+		default:
+		}
+		err := c.gcFunc(gcDiscardRatio)
+		if err == nil {
+			rewrites++
+			continue
+		}
+		if err != badger.ErrNoRewrite && err != badger.ErrRejected {
+			tl.Warn(c.Logger, "badger value log gc failed",
+				tl.Pairs{"cacheName": c.Name, "detail": err.Error()})
+		}
+		break
+	}
+	if rewrites == 0 {
+		return
+	}
+	tl.Info(c.Logger, "badger value log gc complete", tl.Pairs{"cacheName": c.Name,
+		"filesRewritten": rewrites, "bytesReclaimed": before - c.valueLogSize()})
+}
+
+// valueLogSize returns the total size in bytes of the value log files on disk
+func (c *Cache) valueLogSize() int64 {
+	files, _ := filepath.Glob(filepath.Join(c.Config.Badger.ValueDirectory, "*.vlog"))
+	var size int64
+	for _, f := range files {
+		if fi, err := os.Stat(f); err == nil {
+			size += fi.Size()
+		}
+	}
+	return size
+}
+
 // Store places the the data into the Badger Cache using the provided Key and TTL
 func (c *Cache) Store(cacheKey string, data []byte, ttl time.Duration) error {
 	metrics.ObserveCacheOperation(c.Name, c.Config.Provider, "set", "none", float64(len(data)))
@@ -136,8 +216,13 @@ func (c *Cache) BulkRemove(cacheKeys []string) {
 	})
 }
 
-// Close closes the Badger Cache
+// Close stops any value log garbage collection and closes the Badger Cache
 func (c *Cache) Close() error {
+	if c.gcStop != nil {
+		close(c.gcStop)
+		<-c.gcDone
+		c.gcStop = nil
+	}
 	return c.dbh.Close()
 }
 
@@ -158,6 +243,29 @@ func (c *Cache) SetTTL(cacheKey string, ttl time.Duration) {
 	}
 }
 
+// Inspect returns the expiration and value size for the provided cache object
+func (c *Cache) Inspect(cacheKey string) (*cache.ObjectInfo, error) {
+	oi := &cache.ObjectInfo{Key: cacheKey}
+	err := c.dbh.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(cacheKey))
+		if err != nil {
+			return err
+		}
+		if e := item.ExpiresAt(); e > 0 {
+			oi.Expiration = time.Unix(int64(e), 0)
+		}
+		oi.Size = item.ValueSize()
+		return nil
+	})
+	if err == badger.ErrKeyNotFound {
+		return nil, cache.ErrKNF
+	}
+	if err != nil {
+		return nil, err
+	}
+	return oi, nil
+}
+
 func (c *Cache) getExpires(cacheKey string) (int, error) {
 	var expires int
 	err := c.dbh.View(func(txn *badger.Txn) error {
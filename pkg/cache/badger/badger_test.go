@@ -17,14 +17,18 @@
 package badger
 
 import (
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/trickstercache/trickster/v2/pkg/cache"
 	bo "github.com/trickstercache/trickster/v2/pkg/cache/badger/options"
 	co "github.com/trickstercache/trickster/v2/pkg/cache/options"
 	"github.com/trickstercache/trickster/v2/pkg/cache/status"
 	"github.com/trickstercache/trickster/v2/pkg/locks"
 	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
+
+	"github.com/dgraph-io/badger"
 )
 
 const provider = "badger"
@@ -87,6 +91,36 @@ func TestBadgerCache_Store(t *testing.T) {
 	}
 }
 
+func TestBadgerCache_Inspect(t *testing.T) {
+	testDbPath := t.TempDir() + "/test.db"
+	cacheConfig := newCacheConfig(testDbPath)
+	bc := Cache{Config: cacheConfig, Logger: tl.ConsoleLogger("error")}
+
+	if err := bc.Connect(); err != nil {
+		t.Error(err)
+	}
+	defer bc.Close()
+
+	if _, err := bc.Inspect(cacheKey); err != cache.ErrKNF {
+		t.Errorf("expected %v got %v", cache.ErrKNF, err)
+	}
+
+	err := bc.Store(cacheKey, []byte("data"), time.Duration(60)*time.Second)
+	if err != nil {
+		t.Error(err)
+	}
+	oi, err := bc.Inspect(cacheKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if oi.Size != 4 {
+		t.Errorf("expected %d got %d", 4, oi.Size)
+	}
+	if ttl := oi.TTL(time.Now()); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("unexpected ttl %s", ttl)
+	}
+}
+
 func TestBadgerCache_Remove(t *testing.T) {
 	testDbPath := t.TempDir() + "/test.db"
 	cacheConfig := newCacheConfig(testDbPath)
@@ -264,6 +298,66 @@ func TestBadgerCache_Close(t *testing.T) {
 	}
 }
 
+func TestBadgerCache_GC(t *testing.T) {
+	testDbPath := t.TempDir() + "/test.db"
+	cacheConfig := newCacheConfig(testDbPath)
+	bc := Cache{Config: cacheConfig, Logger: tl.ConsoleLogger("error")}
+
+	if err := bc.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if bc.gcStop != nil {
+		t.Error("expected gc to be disabled when gc_interval_secs is 0")
+	}
+
+	// each cycle rewrites one file, then finds nothing more to rewrite
+	var calls int32
+	bc.gcFunc = func(ratio float64) error {
+		if ratio != gcDiscardRatio {
+			t.Errorf("expected discard ratio %f got %f", gcDiscardRatio, ratio)
+		}
+		if atomic.AddInt32(&calls, 1)%2 == 1 {
+			return nil
+		}
+		return badger.ErrNoRewrite
+	}
+	bc.startGC(10 * time.Millisecond)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&calls) < 4 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := atomic.LoadInt32(&calls); n < 4 {
+		t.Fatalf("expected at least 2 gc cycles, got %d gc calls", n)
+	}
+
+	if err := bc.Close(); err != nil {
+		t.Error(err)
+	}
+	n := atomic.LoadInt32(&calls)
+	time.Sleep(30 * time.Millisecond)
+	if m := atomic.LoadInt32(&calls); m != n {
+		t.Errorf("expected no gc after close, got %d more calls", m-n)
+	}
+}
+
+func TestBadgerCache_ConnectStartsGC(t *testing.T) {
+	testDbPath := t.TempDir() + "/test.db"
+	cacheConfig := newCacheConfig(testDbPath)
+	cacheConfig.Badger.GCIntervalSecs = 600
+	bc := Cache{Config: cacheConfig, Logger: tl.ConsoleLogger("error")}
+
+	if err := bc.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if bc.gcStop == nil {
+		t.Error("expected gc to be scheduled")
+	}
+	if err := bc.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
 func TestLocker(t *testing.T) {
 	cache := Cache{locker: locks.NewNamedLocker()}
 	l := cache.Locker()
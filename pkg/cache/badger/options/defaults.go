@@ -15,3 +15,7 @@
  */
 
 package options
+
+// DefaultGCIntervalSecs is the default interval (in seconds) between Badger value log
+// garbage collection cycles
+const DefaultGCIntervalSecs = 600
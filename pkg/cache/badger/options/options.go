@@ -26,9 +26,13 @@ type Options struct {
 	Directory string `yaml:"directory,omitempty"`
 	// ValueDirectory represents the path on disk where the Badger database will store its value log.
 	ValueDirectory string `yaml:"value_directory,omitempty"`
+	// GCIntervalSecs defines how often the Badger value log is garbage collected.
+	// A value of 0 or less disables value log garbage collection
+	GCIntervalSecs int `yaml:"gc_interval_secs,omitempty"`
 }
 
 // New returns a reference to a new Badger Options
 func New() *Options {
-	return &Options{Directory: d.DefaultCachePath, ValueDirectory: d.DefaultCachePath}
+	return &Options{Directory: d.DefaultCachePath, ValueDirectory: d.DefaultCachePath,
+		GCIntervalSecs: DefaultGCIntervalSecs}
 }
@@ -23,4 +23,7 @@ func TestNew(t *testing.T) {
 	if o == nil {
 		t.Error("expected non-nil options")
 	}
+	if o.GCIntervalSecs != DefaultGCIntervalSecs {
+		t.Errorf("expected %d got %d", DefaultGCIntervalSecs, o.GCIntervalSecs)
+	}
 }
@@ -18,6 +18,7 @@
 package bbolt
 
 import (
+	"bytes"
 	"fmt"
 	"sync"
 	"time"
@@ -209,6 +210,11 @@ func (c *Cache) retrieve(cacheKey string, allowExpired bool,
 	return nil, status.LookupStatusKeyMiss, cache.ErrKNF
 }
 
+// Inspect returns the metadata for the provided cache object from the cache index
+func (c *Cache) Inspect(cacheKey string) (*cache.ObjectInfo, error) {
+	return c.Index.Inspect(cacheKey)
+}
+
 // SetTTL updates the TTL for the provided cache object
 func (c *Cache) SetTTL(cacheKey string, ttl time.Duration) {
 	go c.Index.UpdateObjectTTL(cacheKey, ttl)
@@ -252,6 +258,41 @@ func (c *Cache) BulkRemove(cacheKeys []string) {
 	wg.Wait()
 }
 
+// PurgeByPrefix removes all objects whose keys begin with prefix, using a cursor
+// range scan of the bucket, and returns the number of objects removed
+func (c *Cache) PurgeByPrefix(prefix string) (int, error) {
+	var keys []string
+	err := c.dbh.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(c.Config.BBolt.Bucket))
+		p := []byte(prefix)
+		cur := b.Cursor()
+		for k, _ := cur.Seek(p); k != nil && bytes.HasPrefix(k, p); k, _ = cur.Next() {
+			if key := string(k); key != index.IndexKey {
+				keys = append(keys, key)
+			}
+		}
+		// keys are deleted after the scan, since deleting while iterating
+		// causes the cursor to skip keys
+		for _, key := range keys {
+			if err := b.Delete([]byte(key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		tl.Error(c.Logger, "bbolt cache prefix purge failure",
+			tl.Pairs{"prefix": prefix, "reason": err.Error()})
+		return 0, err
+	}
+	c.Index.RemoveObjects(keys, false)
+	for range keys {
+		metrics.ObserveCacheDel(c.Name, c.Config.Provider, 0)
+	}
+	tl.Debug(c.Logger, "bbolt cache prefix purge", tl.Pairs{"prefix": prefix, "count": len(keys)})
+	return len(keys), nil
+}
+
 // Close closes the Cache
 func (c *Cache) Close() error {
 	if c.Index != nil {
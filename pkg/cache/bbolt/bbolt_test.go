@@ -617,3 +617,42 @@ func TestClose(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestBboltCache_PurgeByPrefix(t *testing.T) {
+
+	testDbPath := t.TempDir() + "/test.db"
+	cacheConfig := newCacheConfig(testDbPath)
+	bc := Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: locks.NewNamedLocker()}
+
+	err := bc.Connect()
+	if err != nil {
+		t.Error(err)
+	}
+	defer bc.Close()
+
+	keys := []string{"a.opc.1", "a.dpc.2", "b.opc.3"}
+	for _, key := range keys {
+		if err = bc.Store(key, []byte("data"), time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := bc.PurgeByPrefix("a.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("expected %d got %d", 2, n)
+	}
+	for i, key := range keys {
+		_, _, err := bc.Retrieve(key, false)
+		if i < 2 && err == nil {
+			t.Errorf("expected key not found error for %s", key)
+		} else if i == 2 && err != nil {
+			t.Error(err)
+		}
+	}
+	if bc.Index.ObjectCount != 1 {
+		t.Errorf("expected %d got %d", 1, bc.Index.ObjectCount)
+	}
+}
@@ -19,7 +19,10 @@
 package cache
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/trickstercache/trickster/v2/pkg/cache/options"
@@ -30,6 +33,12 @@ import (
 // ErrKNF represents the error "key not found in cache"
 var ErrKNF = errors.New("key not found in cache")
 
+// ErrPrefixPurgeUnsupported represents the error "cache provider does not support purging by prefix"
+var ErrPrefixPurgeUnsupported = errors.New("cache provider does not support purging by prefix")
+
+// ErrInspectUnsupported represents the error "cache provider does not support inspecting objects"
+var ErrInspectUnsupported = errors.New("cache provider does not support inspecting objects")
+
 // Cache is the interface for the supported caching fabrics
 // When making new cache providers, Retrieve() must return an error on cache miss
 type Cache interface {
@@ -62,6 +71,88 @@ type MemoryCache interface {
 	SetLocker(locks.NamedLocker)
 }
 
+// checkKey is the key of the object written by Check
+const checkKey = "trickster.check"
+
+// Check verifies that the cache is usable, by storing, retrieving and removing a
+// short-lived object. It returns the first error encountered
+func Check(c Cache) error {
+	key := checkKey + "." + strconv.FormatInt(time.Now().UnixNano(), 36)
+	value := []byte(key)
+	if err := c.Store(key, value, time.Minute); err != nil {
+		return fmt.Errorf("store failed: %w", err)
+	}
+	defer c.Remove(key)
+	b, _, err := c.Retrieve(key, false)
+	if err != nil {
+		return fmt.Errorf("retrieve failed: %w", err)
+	}
+	if !bytes.Equal(b, value) {
+		return errors.New("retrieve failed: value mismatch")
+	}
+	return nil
+}
+
+// PrefixPurger is the interface for a cache provider that can efficiently enumerate
+// its keys, offering the removal of all objects whose keys share a prefix
+type PrefixPurger interface {
+	PurgeByPrefix(prefix string) (int, error)
+}
+
+// PurgeByPrefix removes all objects whose keys begin with prefix from the cache, and
+// returns the number of objects removed. ErrPrefixPurgeUnsupported is returned if the
+// cache provider does not implement PrefixPurger
+func PurgeByPrefix(c Cache, prefix string) (int, error) {
+	pp, ok := c.(PrefixPurger)
+	if !ok {
+		return 0, ErrPrefixPurgeUnsupported
+	}
+	return pp.PurgeByPrefix(prefix)
+}
+
+// ObjectInfo describes an object in the cache, without its value
+type ObjectInfo struct {
+	// Key is the cache key of the object
+	Key string
+	// Expiration is the time the object expires from the cache; zero when it does not expire
+	Expiration time.Time
+	// Size is the size of the object's value in bytes
+	Size int64
+	// LastWrite is the time the object was last written, when known by the cache provider
+	LastWrite time.Time
+	// LastAccess is the time the object was last accessed, when known by the cache provider
+	LastAccess time.Time
+}
+
+// TTL returns the remaining time until the object expires, relative to now, or -1
+// when the object does not expire
+func (oi *ObjectInfo) TTL(now time.Time) time.Duration {
+	if oi.Expiration.IsZero() {
+		return -1
+	}
+	if d := oi.Expiration.Sub(now); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// Inspector is the interface for a cache provider that can report an object's metadata
+// without retrieving its value
+type Inspector interface {
+	Inspect(cacheKey string) (*ObjectInfo, error)
+}
+
+// Inspect returns the metadata for the object with the provided key, or ErrKNF when it
+// is not in the cache. ErrInspectUnsupported is returned if the cache provider does not
+// implement Inspector
+func Inspect(c Cache, cacheKey string) (*ObjectInfo, error) {
+	i, ok := c.(Inspector)
+	if !ok {
+		return nil, ErrInspectUnsupported
+	}
+	return i.Inspect(cacheKey)
+}
+
 // ReferenceObject defines an interface for a cache object possessing the ability to report
 // the approximate comprehensive byte size of its members, to assist with cache size management
 type ReferenceObject interface {
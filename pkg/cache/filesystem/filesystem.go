@@ -20,6 +20,7 @@ package filesystem
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
@@ -30,6 +31,7 @@ import (
 	"github.com/trickstercache/trickster/v2/pkg/cache/metrics"
 	"github.com/trickstercache/trickster/v2/pkg/cache/options"
 	"github.com/trickstercache/trickster/v2/pkg/cache/status"
+	"github.com/trickstercache/trickster/v2/pkg/checksum/md5"
 	"github.com/trickstercache/trickster/v2/pkg/locks"
 	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
 )
@@ -106,7 +108,7 @@ func (c *Cache) store(cacheKey string, data []byte, ttl time.Duration, updateInd
 	nl, _ := c.locker.Acquire(c.lockPrefix + cacheKey)
 
 	o := &index.Object{Key: cacheKey, Value: data, Expiration: time.Now().Add(ttl)}
-	err := os.WriteFile(dataFile, o.ToBytes(), os.FileMode(0777))
+	err := c.writeFile(dataFile, o.ToBytes())
 	if err != nil {
 		nl.Release()
 		return err
@@ -168,6 +170,11 @@ func (c *Cache) retrieve(cacheKey string, allowExpired bool, atime bool) ([]byte
 	return nil, status.LookupStatusKeyMiss, cache.ErrKNF
 }
 
+// Inspect returns the metadata for the provided cache object from the cache index
+func (c *Cache) Inspect(cacheKey string) (*cache.ObjectInfo, error) {
+	return c.Index.Inspect(cacheKey)
+}
+
 // SetTTL updates the TTL for the provided cache object
 func (c *Cache) SetTTL(cacheKey string, ttl time.Duration) {
 	go c.Index.UpdateObjectTTL(cacheKey, ttl)
@@ -178,14 +185,19 @@ func (c *Cache) Remove(cacheKey string) {
 	c.remove(cacheKey, false)
 }
 
-func (c *Cache) remove(cacheKey string, isBulk bool) {
+func (c *Cache) remove(cacheKey string, isBulk bool) error {
 	nl, _ := c.locker.Acquire(c.lockPrefix + cacheKey)
-	err := os.Remove(c.getFileName(cacheKey))
+	dataFile := c.getFileName(cacheKey)
+	err := os.Remove(dataFile)
+	if err == nil {
+		c.pruneShardDirs(filepath.Dir(dataFile))
+	}
 	nl.Release()
 	if err == nil && !isBulk {
 		go c.Index.RemoveObject(cacheKey)
 	}
 	metrics.ObserveCacheDel(c.Name, c.Config.Provider, 0)
+	return err
 }
 
 // BulkRemove removes a list of objects from the cache
@@ -202,6 +214,45 @@ func (c *Cache) BulkRemove(cacheKeys []string) {
 	wg.Wait()
 }
 
+// PurgeByPrefix removes all objects whose keys begin with prefix, by walking the
+// cache directory, and returns the number of objects removed
+func (c *Cache) PurgeByPrefix(prefix string) (int, error) {
+	var keys []string
+	err := filepath.WalkDir(c.Config.Filesystem.CachePath,
+		func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			name := d.Name()
+			if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ".data") {
+				return nil
+			}
+			if key := strings.TrimSuffix(name, ".data"); key != index.IndexKey {
+				keys = append(keys, key)
+			}
+			return nil
+		})
+	if err != nil {
+		tl.Error(c.Logger, "filesystem cache prefix purge failure",
+			tl.Pairs{"prefix": prefix, "reason": err.Error()})
+		return 0, err
+	}
+	// keys are removed after the walk, since removals may prune shard directories
+	removed := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if c.remove(key, true) == nil {
+			removed = append(removed, key)
+		}
+	}
+	c.Index.RemoveObjects(removed, false)
+	tl.Debug(c.Logger, "filesystem cache prefix purge",
+		tl.Pairs{"prefix": prefix, "count": len(removed)})
+	return len(removed), nil
+}
+
 // Close is not used for Cache
 func (c *Cache) Close() error {
 	if c.Index != nil {
@@ -211,8 +262,63 @@ func (c *Cache) Close() error {
 }
 
 func (c *Cache) getFileName(cacheKey string) string {
-	prefix := strings.Replace(c.Config.Filesystem.CachePath+"/"+cacheKey+".", "//", "/", 1)
-	return prefix + "data"
+	return strings.Replace(c.shardPath(cacheKey)+"/"+cacheKey+".", "//", "/", 1) + "data"
+}
+
+// shardPath returns the directory in which the object for cacheKey is stored.
+// When ShardDepth is set, this is a subdirectory of the cache path named for
+// successive 2-character segments of the hex-encoded key hash (e.g., ab/cd)
+func (c *Cache) shardPath(cacheKey string) string {
+	depth := c.Config.Filesystem.ShardDepth
+	if depth <= 0 {
+		return c.Config.Filesystem.CachePath
+	}
+	h := md5.Checksum(cacheKey)
+	parts := make([]string, depth+1)
+	parts[0] = c.Config.Filesystem.CachePath
+	for i := 1; i <= depth; i++ {
+		parts[i] = h[(i-1)*2 : i*2]
+	}
+	return filepath.Join(parts...)
+}
+
+// writeFile writes the data to the provided dataFile, creating its shard
+// directory as needed
+func (c *Cache) writeFile(dataFile string, data []byte) error {
+	if c.Config.Filesystem.ShardDepth <= 0 {
+		return os.WriteFile(dataFile, data, os.FileMode(0777))
+	}
+	dir := filepath.Dir(dataFile)
+	// the shard directory may be pruned by a concurrent remove of another key
+	// between MkdirAll and WriteFile, so retry once in that event
+	for i := 0; ; i++ {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		err := os.WriteFile(dataFile, data, os.FileMode(0777))
+		if err == nil || i > 0 || !os.IsNotExist(err) {
+			return err
+		}
+	}
+}
+
+// pruneShardDirs removes dir and its parents, up to but excluding the cache
+// path, for as long as they are empty
+func (c *Cache) pruneShardDirs(dir string) {
+	if c.Config.Filesystem.ShardDepth <= 0 {
+		return
+	}
+	root := filepath.Clean(c.Config.Filesystem.CachePath)
+	for i := 0; i < c.Config.Filesystem.ShardDepth; i++ {
+		if dir == root || len(dir) <= len(root) {
+			return
+		}
+		// os.Remove will not remove a non-empty directory
+		if os.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
 }
 
 // makeDirectory creates a directory on the filesystem and returns the error in the event of a failure.
@@ -17,7 +17,9 @@
 package filesystem
 
 import (
+	"io/fs"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"testing"
@@ -25,9 +27,11 @@ import (
 
 	"github.com/trickstercache/trickster/v2/pkg/cache"
 	flo "github.com/trickstercache/trickster/v2/pkg/cache/filesystem/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache/index"
 	io "github.com/trickstercache/trickster/v2/pkg/cache/index/options"
 	co "github.com/trickstercache/trickster/v2/pkg/cache/options"
 	"github.com/trickstercache/trickster/v2/pkg/cache/status"
+	"github.com/trickstercache/trickster/v2/pkg/checksum/md5"
 	"github.com/trickstercache/trickster/v2/pkg/locks"
 	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
 )
@@ -617,3 +621,147 @@ func TestLocker(t *testing.T) {
 		t.Errorf("error setting locker")
 	}
 }
+
+func newShardedCache(t *testing.T, cachePath string) *Cache {
+	cacheConfig := newCacheConfig(t)
+	cacheConfig.Filesystem.CachePath = cachePath
+	cacheConfig.Filesystem.ShardDepth = flo.MaxShardDepth
+	cacheConfig.Index.ReapInterval = 10 * time.Millisecond
+	cacheConfig.Index.FlushInterval = 10 * time.Millisecond
+	fc := &Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"),
+		locker: locks.NewNamedLocker()}
+	if err := fc.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	return fc
+}
+
+// listFiles returns the paths of all regular files beneath root, relative to root
+func listFiles(t *testing.T, root string) []string {
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			rel, _ := filepath.Rel(root, path)
+			files = append(files, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestFilesystemCache_Sharded(t *testing.T) {
+
+	const keyCount = 200
+	cachePath := t.TempDir() + "/cache"
+
+	fc := newShardedCache(t, cachePath)
+	for n := 0; n < keyCount; n++ {
+		err := fc.Store(cacheKey+strconv.Itoa(n), []byte("data"+strconv.Itoa(n)),
+			time.Duration(60)*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// every object should be stored 2 levels deep, in the shard named for its key hash
+	for n := 0; n < keyCount; n++ {
+		key := cacheKey + strconv.Itoa(n)
+		h := md5.Checksum(key)
+		expected := filepath.Join(cachePath, h[0:2], h[2:4], key+".data")
+		if fn := fc.getFileName(key); fn != expected {
+			t.Fatalf("expected %s got %s", expected, fn)
+		}
+		if _, err := os.Stat(expected); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := os.ReadDir(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if !e.IsDir() || len(e.Name()) != 2 {
+			t.Errorf("unexpected entry in cache path: %s", e.Name())
+		}
+	}
+
+	// allow the index to flush, then simulate a restart
+	time.Sleep(100 * time.Millisecond)
+	fc.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	fc = newShardedCache(t, cachePath)
+	if fc.Index.ObjectCount != keyCount {
+		t.Errorf("expected %d got %d", keyCount, fc.Index.ObjectCount)
+	}
+	for n := 0; n < keyCount; n++ {
+		data, ls, err := fc.Retrieve(cacheKey+strconv.Itoa(n), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != "data"+strconv.Itoa(n) {
+			t.Errorf("wanted \"%s\". got \"%s\".", "data"+strconv.Itoa(n), data)
+		}
+		if ls != status.LookupStatusHit {
+			t.Errorf("expected %s got %s", status.LookupStatusHit, ls)
+		}
+	}
+
+	// expire everything and let the reaper remove the objects and prune their shards
+	for n := 0; n < keyCount; n++ {
+		fc.Index.UpdateObjectTTL(cacheKey+strconv.Itoa(n), time.Millisecond)
+	}
+	time.Sleep(200 * time.Millisecond)
+	fc.Close()
+
+	expected := []string{strings.TrimPrefix(fc.getFileName(index.IndexKey), cachePath+"/")}
+	files := listFiles(t, cachePath)
+	if len(files) != 1 || files[0] != expected[0] {
+		t.Errorf("expected %v got %v", expected, files)
+	}
+	entries, err = os.ReadDir(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected empty shards to be pruned, got %d top-level entries", len(entries))
+	}
+}
+
+func TestFilesystemCache_PurgeByPrefix(t *testing.T) {
+
+	fc := newShardedCache(t, t.TempDir()+"/cache")
+	defer fc.Close()
+
+	keys := []string{"a.opc.1", "a.dpc.2", "b.opc.3"}
+	for _, key := range keys {
+		if err := fc.Store(key, []byte("data"), time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := fc.PurgeByPrefix("a.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("expected %d got %d", 2, n)
+	}
+	for i, key := range keys {
+		_, _, err := fc.Retrieve(key, false)
+		if i < 2 && err == nil {
+			t.Errorf("expected key not found error for %s", key)
+		} else if i == 2 && err != nil {
+			t.Error(err)
+		}
+	}
+	if fc.Index.ObjectCount != 1 {
+		t.Errorf("expected %d got %d", 1, fc.Index.ObjectCount)
+	}
+}
@@ -24,8 +24,15 @@ import (
 type Options struct {
 	// CachePath represents the path on disk where our cache will live
 	CachePath string `yaml:"cache_path,omitempty"`
+	// ShardDepth is the number of levels of hex-named subdirectories, derived
+	// from a hash of the cache key, that objects are fanned out into beneath
+	// CachePath. 0 stores all objects directly in CachePath.
+	ShardDepth int `yaml:"shard_depth,omitempty"`
 }
 
+// MaxShardDepth is the maximum supported value for ShardDepth
+const MaxShardDepth = 2
+
 // New returns a new Filesystem Options Reference with default values set
 func New() *Options {
 	return &Options{CachePath: d.DefaultCachePath}
@@ -18,6 +18,7 @@
 package index
 
 import (
+	"math/rand"
 	"sort"
 	"sync"
 	"sync/atomic"
@@ -51,6 +52,7 @@ type Index struct {
 	bulkRemoveFunc func([]string)                     `msg:"-"`
 	flushFunc      func(cacheKey string, data []byte) `msg:"-"`
 	lastWrite      time.Time                          `msg:"-"`
+	logger         interface{}                        `msg:"-"`
 
 	isClosing     bool
 	flusherExited bool
@@ -59,9 +61,17 @@ type Index struct {
 	mtx sync.Mutex
 }
 
-// Close is called to signal the index to shut down any subroutines
+// Close is called to signal the index to shut down any subroutines. Indexes that are
+// flushed to their cache (e.g., filesystem and bbolt) are flushed a final time, so that
+// writes since the last periodic flush are not lost
 func (idx *Index) Close() {
+	if idx.isClosing {
+		return
+	}
 	idx.isClosing = true
+	if idx.flushFunc != nil {
+		idx.flushOnce(idx.logger)
+	}
 }
 
 // ToBytes returns a serialized byte slice representing the Index
@@ -121,6 +131,7 @@ func NewIndex(cacheName, cacheProvider string, indexData []byte, o *options.Opti
 	i.flushFunc = flushFunc
 	i.bulkRemoveFunc = bulkRemoveFunc
 	i.options = o
+	i.logger = logger
 
 	if flushFunc != nil {
 		if o.FlushInterval > 0 {
@@ -251,11 +262,29 @@ func (idx *Index) GetExpiration(cacheKey string) time.Time {
 	return time.Time{}
 }
 
+// Inspect returns the cache index's metadata for the object of the given key, or
+// cache.ErrKNF when the object is not in the index or has expired
+func (idx *Index) Inspect(cacheKey string) (*cache.ObjectInfo, error) {
+	idx.mtx.Lock()
+	o, ok := idx.Objects[cacheKey]
+	if !ok {
+		idx.mtx.Unlock()
+		return nil, cache.ErrKNF
+	}
+	oi := &cache.ObjectInfo{Key: cacheKey, Expiration: o.Expiration, Size: o.Size,
+		LastWrite: o.LastWrite, LastAccess: o.LastAccess}
+	idx.mtx.Unlock()
+	if !oi.Expiration.IsZero() && !oi.Expiration.After(time.Now()) {
+		return nil, cache.ErrKNF
+	}
+	return oi, nil
+}
+
 // flusher periodically calls the cache's index flush func that writes the cache index to disk
 func (idx *Index) flusher(logger interface{}) {
 	var lastFlush time.Time
 	for !idx.isClosing {
-		time.Sleep(idx.options.FlushInterval)
+		time.Sleep(idx.splay(idx.options.FlushInterval))
 		if idx.lastWrite.Before(lastFlush) {
 			continue
 		}
@@ -277,11 +306,26 @@ func (idx *Index) flushOnce(logger interface{}) {
 	idx.flushFunc(IndexKey, bytes)
 }
 
+// splay returns the interval shortened by a random amount of up to the Index's SplayPercent.
+// It is called for each tick, so that the reap and flush cycles of many Trickster instances
+// drift apart, rather than running in lockstep. Shortening, rather than extending, keeps the
+// configured interval as the upper bound on how long expired objects go unreaped
+func (idx *Index) splay(interval time.Duration) time.Duration {
+	if idx.options.SplayPercent <= 0 || interval <= 0 {
+		return interval
+	}
+	max := int64(interval) * int64(idx.options.SplayPercent) / 100
+	if max <= 0 {
+		return interval
+	}
+	return interval - time.Duration(rand.Int63n(max))
+}
+
 // reaper continually iterates through the cache to find expired elements and removes them
 func (idx *Index) reaper(logger interface{}) {
 	for !idx.isClosing {
 		idx.reap(logger)
-		time.Sleep(idx.options.ReapInterval)
+		time.Sleep(idx.splay(idx.options.ReapInterval))
 	}
 	idx.reaperExited = true
 }
@@ -320,9 +364,49 @@ func (idx *Index) reap(logger interface{}) {
 		cacheChanged = true
 	}
 
-	if ((idx.options.MaxSizeBytes > 0 && idx.CacheSize > idx.options.MaxSizeBytes) ||
-		(idx.options.MaxSizeObjects > 0 && idx.ObjectCount > idx.options.MaxSizeObjects)) &&
-		len(remainders) > 0 {
+	if idx.evictLRU(remainders, logger) {
+		cacheChanged = true
+	}
+
+	if cacheChanged {
+		idx.lastWrite = time.Now()
+	}
+}
+
+// EnforceMaxSize evicts least-recently-accessed elements when the Index exceeds
+// its Maximum allowed Cache Size, without waiting for the next reap. Caches that
+// can grow quickly between reaps, like the memory cache, call it after writes.
+func (idx *Index) EnforceMaxSize() {
+	idx.mtx.Lock()
+	defer idx.mtx.Unlock()
+	if !idx.exceedsMaxSize() {
+		return
+	}
+	remainders := make(objectsAtime, 0, len(idx.Objects))
+	for _, o := range idx.Objects {
+		if o.Key == IndexKey {
+			continue
+		}
+		remainders = append(remainders, o)
+	}
+	if idx.evictLRU(remainders, idx.logger) {
+		idx.lastWrite = time.Now()
+	}
+}
+
+// exceedsMaxSize returns true if the Index is larger than the Maximum allowed
+// Cache Size, by either bytes or objects. The caller must hold the Index lock.
+func (idx *Index) exceedsMaxSize() bool {
+	return (idx.options.MaxSizeBytes > 0 && idx.CacheSize > idx.options.MaxSizeBytes) ||
+		(idx.options.MaxSizeObjects > 0 && idx.ObjectCount > idx.options.MaxSizeObjects)
+}
+
+// evictLRU removes the least-recently-accessed of the provided elements until the
+// Index is within its Maximum allowed Cache Size, less the configured backoff.
+// It returns true if any elements were evicted. The caller must hold the Index lock.
+func (idx *Index) evictLRU(remainders objectsAtime, logger interface{}) bool {
+
+	if idx.exceedsMaxSize() && len(remainders) > 0 {
 
 		var evictionType string
 		if idx.options.MaxSizeBytes > 0 && idx.CacheSize > idx.options.MaxSizeBytes {
@@ -330,7 +414,7 @@ func (idx *Index) reap(logger interface{}) {
 		} else if idx.options.MaxSizeObjects > 0 && idx.ObjectCount > idx.options.MaxSizeObjects {
 			evictionType = "size_objects"
 		} else {
-			return
+			return false
 		}
 
 		tl.Debug(logger,
@@ -342,7 +426,7 @@ func (idx *Index) reap(logger interface{}) {
 			},
 		)
 
-		removals = make([]string, 0)
+		removals := make([]string, 0)
 
 		sort.Sort(remainders)
 
@@ -377,7 +461,6 @@ func (idx *Index) reap(logger interface{}) {
 			metrics.ObserveCacheEvent(idx.name, idx.cacheProvider, "eviction", evictionType)
 			go idx.bulkRemoveFunc(removals)
 			idx.RemoveObjects(removals, true)
-			cacheChanged = true
 		}
 
 		tl.Debug(logger, "size-based cache eviction exercise completed",
@@ -387,10 +470,9 @@ func (idx *Index) reap(logger interface{}) {
 				"cacheSizeObjects": idx.ObjectCount, "maxSizeObjects": idx.options.MaxSizeObjects,
 			})
 
+		return len(removals) > 0
 	}
-	if cacheChanged {
-		idx.lastWrite = time.Now()
-	}
+	return false
 }
 
 // Len returns the number of elements in the subject slice
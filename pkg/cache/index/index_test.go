@@ -21,6 +21,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/trickstercache/trickster/v2/pkg/cache"
 	io "github.com/trickstercache/trickster/v2/pkg/cache/index/options"
 	co "github.com/trickstercache/trickster/v2/pkg/cache/options"
 	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
@@ -79,6 +80,33 @@ func TestNewIndex(t *testing.T) {
 
 }
 
+func TestCloseFlushes(t *testing.T) {
+	var flushes int
+	var flushed []byte
+	flushFunc := func(cacheKey string, data []byte) {
+		flushes++
+		flushed = data
+	}
+	// no periodic flusher or reaper, so any flush is from Close
+	idx := NewIndex("test", "test", nil, &io.Options{}, testBulkRemoveFunc, flushFunc, testLogger)
+	idx.UpdateObject(&Object{Key: "test", Value: []byte("value")})
+
+	idx.Close()
+	if flushes != 1 {
+		t.Fatalf("expected %d got %d", 1, flushes)
+	}
+	idx2 := NewIndex("test", "test", flushed, &io.Options{}, testBulkRemoveFunc, nil, testLogger)
+	if _, ok := idx2.Objects["test"]; !ok {
+		t.Error("expected flushed index to include object")
+	}
+
+	// subsequent calls do not flush again
+	idx.Close()
+	if flushes != 1 {
+		t.Errorf("expected %d got %d", 1, flushes)
+	}
+}
+
 func TestReap(t *testing.T) {
 
 	cacheConfig := &co.Options{Provider: "test",
@@ -305,6 +333,41 @@ func TestUpdateObjectTTL(t *testing.T) {
 
 }
 
+func TestInspect(t *testing.T) {
+
+	cacheConfig := &co.Options{Provider: "test",
+		Index: &io.Options{ReapInterval: time.Second * time.Duration(10),
+			FlushInterval: time.Second * time.Duration(10)}}
+	idx := NewIndex("test", "test", nil, cacheConfig.Index, testBulkRemoveFunc, fakeFlusherFunc, testLogger)
+
+	if _, err := idx.Inspect("test-inspect-key"); err != cache.ErrKNF {
+		t.Errorf("expected %v got %v", cache.ErrKNF, err)
+	}
+
+	idx.UpdateObject(&Object{Key: "test-inspect-key", Value: []byte("test_value"),
+		Expiration: time.Now().Add(time.Hour)})
+	oi, err := idx.Inspect("test-inspect-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if oi.Size != 10 {
+		t.Errorf("expected %d got %d", 10, oi.Size)
+	}
+	if ttl := oi.TTL(time.Now()); ttl <= 59*time.Minute || ttl > time.Hour {
+		t.Errorf("unexpected ttl %s", ttl)
+	}
+	if oi.LastWrite.IsZero() {
+		t.Error("expected non-zero last write time")
+	}
+
+	// expired objects that have not yet been reaped are reported as missing
+	idx.UpdateObject(&Object{Key: "test-expired-key", Value: []byte("test_value"),
+		Expiration: time.Now().Add(-time.Second)})
+	if _, err := idx.Inspect("test-expired-key"); err != cache.ErrKNF {
+		t.Errorf("expected %v got %v", cache.ErrKNF, err)
+	}
+}
+
 func TestUpdateOptions(t *testing.T) {
 
 	cacheConfig := &co.Options{Provider: "test",
@@ -321,6 +384,30 @@ func TestUpdateOptions(t *testing.T) {
 	}
 }
 
+func TestSplay(t *testing.T) {
+
+	const interval = time.Second
+	idx := &Index{options: &io.Options{SplayPercent: 10}}
+
+	seen := make(map[time.Duration]bool)
+	for i := 0; i < 100; i++ {
+		d := idx.splay(interval)
+		if d > interval || d <= interval-interval/10 {
+			t.Errorf("expected interval between %s and %s got %s",
+				interval-interval/10, interval, d)
+		}
+		seen[d] = true
+	}
+	if len(seen) < 2 {
+		t.Error("expected successive intervals to vary")
+	}
+
+	idx.options.SplayPercent = 0
+	if d := idx.splay(interval); d != interval {
+		t.Errorf("expected %s got %s", interval, d)
+	}
+}
+
 func TestRemoveObjects(t *testing.T) {
 	cacheConfig := &co.Options{Provider: "test",
 		Index: &io.Options{ReapInterval: time.Second * time.Duration(10),
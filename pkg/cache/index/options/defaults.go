@@ -21,6 +21,9 @@ const (
 	DefaultCacheIndexReap = 3000
 	// DefaultCacheIndexFlush is the default Cache Index Flush interval (in milliseconds)
 	DefaultCacheIndexFlush = 5000
+	// DefaultCacheIndexSplayPercent is the default maximum percentage by which each Cache
+	// Index Reap and Flush interval is randomly shortened
+	DefaultCacheIndexSplayPercent = 10
 	// DefaultCacheMaxSizeBytes is the default Max Cache Size in Bytes
 	DefaultCacheMaxSizeBytes = 536870912
 	// DefaultMaxSizeBackoffBytes is the default Max Cache Backoff Size in Bytes
@@ -38,6 +38,9 @@ type Options struct {
 	// MaxSizeBackoffObjects indicates how far under max_size_objects the cache size must
 	// be to complete object-size-based eviction exercise.
 	MaxSizeBackoffObjects int64 `yaml:"max_size_backoff_objects,omitempty"`
+	// SplayPercent shortens each reap and flush interval by a random amount of up to this
+	// percentage, so that many Trickster instances do not reap or flush in lockstep
+	SplayPercent int `yaml:"splay_percent,omitempty"`
 
 	ReapInterval  time.Duration `yaml:"-"`
 	FlushInterval time.Duration `yaml:"-"`
@@ -54,6 +57,7 @@ func New() *Options {
 		MaxSizeBackoffBytes:   DefaultMaxSizeBackoffBytes,
 		MaxSizeObjects:        DefaultMaxSizeObjects,
 		MaxSizeBackoffObjects: DefaultMaxSizeBackoffObjects,
+		SplayPercent:          DefaultCacheIndexSplayPercent,
 	}
 }
 
@@ -70,5 +74,6 @@ func (o *Options) Equal(o2 *Options) bool {
 		o.MaxSizeBytes == o2.MaxSizeBytes &&
 		o.MaxSizeBackoffBytes == o2.MaxSizeBackoffBytes &&
 		o.MaxSizeObjects == o2.MaxSizeObjects &&
-		o.MaxSizeBackoffObjects == o2.MaxSizeBackoffObjects
+		o.MaxSizeBackoffObjects == o2.MaxSizeBackoffObjects &&
+		o.SplayPercent == o2.SplayPercent
 }
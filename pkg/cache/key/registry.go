@@ -0,0 +1,101 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package key
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/trickstercache/trickster/v2/pkg/checksum/md5"
+)
+
+const (
+	// HasherPath is the name of the built-in HasherFunc that keys only on the
+	// request path
+	HasherPath = "path"
+	// HasherJSONBody is the name of the built-in HasherFunc that keys on the
+	// request path and a normalized representation of a JSON request body
+	HasherJSONBody = "json_body"
+)
+
+var hashers = map[string]HasherFunc{
+	HasherPath:     pathHasher,
+	HasherJSONBody: jsonBodyHasher,
+}
+var hashersMtx sync.RWMutex
+
+// RegisterHasher registers the HasherFunc under the provided name, making it
+// selectable by paths via the key_hasher_name config. Registering an existing
+// name replaces its HasherFunc
+func RegisterHasher(name string, f HasherFunc) {
+	hashersMtx.Lock()
+	hashers[name] = f
+	hashersMtx.Unlock()
+}
+
+// GetHasher returns the HasherFunc registered under the provided name
+func GetHasher(name string) (HasherFunc, bool) {
+	hashersMtx.RLock()
+	f, ok := hashers[name]
+	hashersMtx.RUnlock()
+	return f, ok
+}
+
+// HasherNames returns the sorted names of all registered HasherFuncs
+func HasherNames() []string {
+	hashersMtx.RLock()
+	names := make([]string, 0, len(hashers))
+	for k := range hashers {
+		names = append(names, k)
+	}
+	hashersMtx.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+func pathHasher(path string, params url.Values,
+	headers http.Header, body io.ReadCloser, extra string) (string, io.ReadCloser) {
+	return md5.Checksum(path + extra), body
+}
+
+// jsonBodyHasher keys on the request path and the JSON request body, re-encoded
+// so that requests differing only in whitespace or field order share a cache key
+func jsonBodyHasher(path string, params url.Values,
+	headers http.Header, body io.ReadCloser, extra string) (string, io.ReadCloser) {
+	var sb strings.Builder
+	sb.WriteString(path)
+	if body != nil {
+		if b, err := io.ReadAll(body); err == nil {
+			body = io.NopCloser(bytes.NewReader(b))
+			var v interface{}
+			if err = json.Unmarshal(b, &v); err == nil {
+				if nb, err := json.Marshal(v); err == nil {
+					b = nb
+				}
+			}
+			sb.Write(b)
+		}
+	}
+	sb.WriteString(extra)
+	return md5.Checksum(sb.String()), body
+}
@@ -0,0 +1,80 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package key
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRegisterHasher(t *testing.T) {
+
+	if _, ok := GetHasher("test"); ok {
+		t.Error("expected unregistered hasher")
+	}
+
+	RegisterHasher("test", func(path string, params url.Values,
+		headers http.Header, body io.ReadCloser, extra string) (string, io.ReadCloser) {
+		return "test", body
+	})
+
+	f, ok := GetHasher("test")
+	if !ok {
+		t.Fatal("expected registered hasher")
+	}
+	if k, _ := f("/", nil, nil, nil, ""); k != "test" {
+		t.Errorf("expected %s got %s", "test", k)
+	}
+
+	names := HasherNames()
+	if len(names) != 3 || names[0] != HasherJSONBody || names[2] != "test" {
+		t.Errorf("unexpected hasher names %v", names)
+	}
+}
+
+func TestJSONBodyHasher(t *testing.T) {
+
+	f, ok := GetHasher(HasherJSONBody)
+	if !ok {
+		t.Fatal("expected built-in hasher")
+	}
+
+	k1, b := f("/", nil, nil, io.NopCloser(strings.NewReader(`{"a":1,"b":2}`)), "")
+	k2, _ := f("/", nil, nil, io.NopCloser(strings.NewReader(`{ "b": 2, "a": 1 }`)), "")
+	if k1 != k2 {
+		t.Errorf("expected equal keys, got %s and %s", k1, k2)
+	}
+
+	rb, _ := io.ReadAll(b)
+	if string(rb) != `{"a":1,"b":2}` {
+		t.Errorf("expected original body to be preserved, got %s", string(rb))
+	}
+
+	k3, _ := f("/", nil, nil, io.NopCloser(strings.NewReader(`{"a":2}`)), "")
+	if k1 == k3 {
+		t.Error("expected different keys")
+	}
+
+	k4, _ := f("/", nil, nil, nil, "")
+	ph, _ := GetHasher(HasherPath)
+	if k5, _ := ph("/", nil, nil, nil, ""); k4 != k5 {
+		t.Errorf("expected equal keys, got %s and %s", k4, k5)
+	}
+}
@@ -120,6 +120,11 @@ func (c *Cache) store(cacheKey string, byteData []byte, refData cache.ReferenceO
 			c.Index.UpdateObject(o2)
 		}
 		nl.Release()
+		// reference objects are never serialized, so the memory cache can grow quickly
+		// between reaps; enforce the size limits on write rather than waiting for the reaper
+		if updateIndex {
+			c.Index.EnforceMaxSize()
+		}
 	}
 
 	return nil
@@ -176,6 +181,11 @@ func (c *Cache) retrieve(cacheKey string, allowExpired bool, atime bool) (*index
 	return nil, status.LookupStatusKeyMiss, cache.ErrKNF
 }
 
+// Inspect returns the metadata for the provided cache object from the cache index
+func (c *Cache) Inspect(cacheKey string) (*cache.ObjectInfo, error) {
+	return c.Index.Inspect(cacheKey)
+}
+
 // SetTTL updates the TTL for the provided cache object
 func (c *Cache) SetTTL(cacheKey string, ttl time.Duration) {
 	go c.Index.UpdateObjectTTL(cacheKey, ttl)
@@ -41,6 +41,12 @@ func (r *testReferenceObject) Size() int {
 	return 1
 }
 
+type sizedReferenceObject int
+
+func (r sizedReferenceObject) Size() int {
+	return int(r)
+}
+
 func storeBenchmark(b *testing.B) *Cache {
 	cacheConfig := co.Options{Provider: provider, Index: &io.Options{ReapInterval: 0}}
 	mc := &Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: testLocker}
@@ -429,6 +435,59 @@ func TestMemoryCache_SetTTL(t *testing.T) {
 
 }
 
+func TestCache_StoreReferenceEviction(t *testing.T) {
+
+	cacheConfig := newCacheConfig(t)
+	cacheConfig.Index.MaxSizeBytes = 100
+	cacheConfig.Index.MaxSizeBackoffBytes = 30
+	mc := Cache{Config: &cacheConfig, Logger: tl.ConsoleLogger("error"), locker: testLocker}
+
+	err := mc.Connect()
+	if err != nil {
+		t.Error(err)
+	}
+	defer mc.Close()
+
+	// fill the cache exactly to its limit, which should not evict anything
+	for i := 0; i < 5; i++ {
+		err = mc.StoreReference(cacheKey+strconv.Itoa(i), sizedReferenceObject(20), time.Minute)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if mc.Index.CacheSize != 100 {
+		t.Errorf("expected %d got %d", 100, mc.Index.CacheSize)
+	}
+
+	// access the oldest key so that it is no longer the least-recently-accessed
+	if _, _, err = mc.RetrieveReference(cacheKey+"0", false); err != nil {
+		t.Error(err)
+	}
+	time.Sleep(time.Millisecond * 10)
+
+	// exceed the limit by 20 bytes, which, with the 30 byte backoff, should
+	// evict the 3 least-recently-accessed keys
+	err = mc.StoreReference(cacheKey+"5", sizedReferenceObject(20), time.Minute)
+	if err != nil {
+		t.Error(err)
+	}
+	if mc.Index.CacheSize != 60 {
+		t.Errorf("expected %d got %d", 60, mc.Index.CacheSize)
+	}
+	time.Sleep(time.Millisecond * 10)
+
+	for _, i := range []int{1, 2, 3} {
+		if _, _, err = mc.RetrieveReference(cacheKey+strconv.Itoa(i), false); err != cache.ErrKNF {
+			t.Errorf("expected %s to be evicted", cacheKey+strconv.Itoa(i))
+		}
+	}
+	for _, i := range []int{0, 4, 5} {
+		if _, _, err = mc.RetrieveReference(cacheKey+strconv.Itoa(i), false); err != nil {
+			t.Errorf("expected %s to survive eviction: %v", cacheKey+strconv.Itoa(i), err)
+		}
+	}
+}
+
 func BenchmarkCache_SetTTL(b *testing.B) {
 	mc := storeBenchmark(b)
 
@@ -20,7 +20,10 @@ package negative
 
 import (
 	"fmt"
+	"net/http"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -32,7 +35,10 @@ func New() Config {
 	return Config{}
 }
 
-// Config is a collection of response codes and their TTLs in milliseconds
+// Config is a collection of response codes and their TTLs in milliseconds.
+// A response code may be followed by a header predicate, as in
+// "200;X-Upstream-Error=true", to only match responses carrying that header
+// value, or "200;X-Upstream-Error" to match any value of that header.
 type Config map[string]int
 
 // Lookup is a collection of response codes and their TTLs as Durations
@@ -41,6 +47,114 @@ type Lookup map[int]time.Duration
 // Lookups is a collection of Lookup maps
 type Lookups map[string]Lookup
 
+// HeaderRule is a negative cache entry for a response code that only matches
+// responses carrying the named header (and value, if provided)
+type HeaderRule struct {
+	Code   int
+	Header string
+	Value  string
+	TTL    time.Duration
+}
+
+// HeaderRules is a collection of HeaderRules
+type HeaderRules []HeaderRule
+
+// HeaderLookups is a collection of named HeaderRules
+type HeaderLookups map[string]HeaderRules
+
+// Match returns the TTL of the first rule matching the response code and headers
+func (hr HeaderRules) Match(code int, h http.Header) (time.Duration, bool) {
+	if h == nil {
+		return 0, false
+	}
+	for _, r := range hr {
+		if r.Code != code {
+			continue
+		}
+		v, ok := h[http.CanonicalHeaderKey(r.Header)]
+		if !ok {
+			continue
+		}
+		if r.Value == "" || (len(v) > 0 && v[0] == r.Value) {
+			return r.TTL, true
+		}
+	}
+	return 0, false
+}
+
+// Response is a replacement response body and headers that is served in place of
+// a negative-cached upstream response
+type Response struct {
+	// Body is the replacement response body
+	Body string `yaml:"body,omitempty"`
+	// ContentType is the Content-Type of the replacement response body
+	ContentType string `yaml:"content_type,omitempty"`
+	// Headers are additional headers set on the replacement response
+	Headers map[string]string `yaml:"headers,omitempty"`
+}
+
+// ResponseConfig is a collection of response codes and their replacement Responses
+type ResponseConfig map[string]*Response
+
+// ResponseConfigLookup defines a Lookup map for a collection of named ResponseConfigs,
+// each named for the Negative Cache Config it applies to
+type ResponseConfigLookup map[string]ResponseConfig
+
+// ResponseLookup is a collection of response codes and their replacement Responses
+type ResponseLookup map[int]*Response
+
+// ResponseLookups is a collection of ResponseLookup maps
+type ResponseLookups map[string]ResponseLookup
+
+// Clone returns an exact copy of the Response
+func (r *Response) Clone() *Response {
+	r2 := &Response{Body: r.Body, ContentType: r.ContentType}
+	if r.Headers != nil {
+		r2.Headers = make(map[string]string, len(r.Headers))
+		for k, v := range r.Headers {
+			r2.Headers[k] = v
+		}
+	}
+	return r2
+}
+
+// Clone returns an exact copy of a ResponseConfig
+func (rc ResponseConfig) Clone() ResponseConfig {
+	rc2 := make(ResponseConfig, len(rc))
+	for k, v := range rc {
+		if v != nil {
+			rc2[k] = v.Clone()
+		}
+	}
+	return rc2
+}
+
+// Validate verifies the Negative Cache Response Configs against the provided
+// Negative Cache Configs, and returns them keyed by status code
+func (l ResponseConfigLookup) Validate(ncl ConfigLookup) (ResponseLookups, error) {
+	rl := make(ResponseLookups)
+	for k, rc := range l {
+		if _, ok := ncl[k]; !ok {
+			return nil, fmt.Errorf(`invalid negative cache response config %s: no negative cache config named %s`, k, k)
+		}
+		lk := make(ResponseLookup)
+		for c, r := range rc {
+			ci, err := strconv.Atoi(c)
+			if err != nil {
+				return nil, fmt.Errorf(`invalid negative cache response config in %s: %s is not a valid status code`, k, c)
+			}
+			if ci < 100 || ci >= 600 {
+				return nil, fmt.Errorf(`invalid negative cache response config in %s: %s is not >= 100 and < 600`, k, c)
+			}
+			if r != nil {
+				lk[ci] = r
+			}
+		}
+		rl[k] = lk
+	}
+	return rl, nil
+}
+
 // Clone returns an exact copy of a Config
 func (nc Config) Clone() Config {
 	nc2 := make(Config)
@@ -67,6 +181,9 @@ func (l ConfigLookup) Validate() (Lookups, error) {
 	for k, n := range l {
 		lk := make(Lookup)
 		for c, t := range n {
+			if strings.Contains(c, ";") {
+				continue
+			}
 			ci, err := strconv.Atoi(c)
 			if err != nil {
 				return nil, fmt.Errorf(`invalid negative cache config in %s: %s is not a valid status code`, k, c)
@@ -80,3 +197,46 @@ func (l ConfigLookup) Validate() (Lookups, error) {
 	}
 	return ml, nil
 }
+
+// ValidateHeaderRules verifies and returns the header-predicated entries of
+// the Negative Cache Config
+func (l ConfigLookup) ValidateHeaderRules() (HeaderLookups, error) {
+	hl := make(HeaderLookups)
+	for k, n := range l {
+		var hr HeaderRules
+		for c, t := range n {
+			i := strings.Index(c, ";")
+			if i == -1 {
+				continue
+			}
+			ci, err := strconv.Atoi(strings.TrimSpace(c[:i]))
+			if err != nil {
+				return nil, fmt.Errorf(`invalid negative cache config in %s: %s is not a valid status code`, k, c[:i])
+			}
+			if ci < 100 || ci >= 600 {
+				return nil, fmt.Errorf(`invalid negative cache config in %s: %s is not >= 100 and < 600`, k, c[:i])
+			}
+			name, value, _ := strings.Cut(c[i+1:], "=")
+			name = strings.TrimSpace(name)
+			if name == "" {
+				return nil, fmt.Errorf(`invalid negative cache config in %s: %s is missing a header name`, k, c)
+			}
+			hr = append(hr, HeaderRule{Code: ci, Header: name,
+				Value: strings.TrimSpace(value), TTL: time.Duration(t) * time.Millisecond})
+		}
+		if len(hr) > 0 {
+			// sort for deterministic matching when several rules share a code
+			sort.Slice(hr, func(i, j int) bool {
+				if hr[i].Code != hr[j].Code {
+					return hr[i].Code < hr[j].Code
+				}
+				if hr[i].Header != hr[j].Header {
+					return hr[i].Header < hr[j].Header
+				}
+				return hr[i].Value > hr[j].Value
+			})
+			hl[k] = hr
+		}
+	}
+	return hl, nil
+}
@@ -0,0 +1,83 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package negative
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+
+	l := ConfigLookup{"test": Config{"404": 1000, "200;X-Upstream-Error=true": 2000}}
+	ml, err := l.Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ml["test"]) != 1 || ml["test"][404] != time.Second {
+		t.Errorf("unexpected lookup %v", ml["test"])
+	}
+
+	_, err = ConfigLookup{"test": Config{"200": 1000}}.Validate()
+	if err == nil {
+		t.Error("expected error for status code < 400")
+	}
+}
+
+func TestValidateHeaderRules(t *testing.T) {
+
+	l := ConfigLookup{
+		"test": Config{"404": 1000, "200;X-Upstream-Error=true": 2000,
+			"200;X-Upstream-Error": 3000},
+		"empty": Config{"404": 1000},
+	}
+	hl, err := l.ValidateHeaderRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := hl["empty"]; ok {
+		t.Error("expected no header rules for empty")
+	}
+	hr := hl["test"]
+	if len(hr) != 2 {
+		t.Fatalf("expected %d got %d", 2, len(hr))
+	}
+
+	h := http.Header{}
+	if _, ok := hr.Match(200, h); ok {
+		t.Error("expected no match without header")
+	}
+	h.Set("X-Upstream-Error", "true")
+	if d, ok := hr.Match(200, h); !ok || d != 2*time.Second {
+		t.Errorf("expected %s got %s", 2*time.Second, d)
+	}
+	h.Set("X-Upstream-Error", "maybe")
+	if d, ok := hr.Match(200, h); !ok || d != 3*time.Second {
+		t.Errorf("expected %s got %s", 3*time.Second, d)
+	}
+	if _, ok := hr.Match(201, h); ok {
+		t.Error("expected no match for different status code")
+	}
+
+	for _, c := range []string{"abc;X-Test=1", "99;X-Test=1", "200;=1"} {
+		_, err = ConfigLookup{"test": Config{c: 1000}}.ValidateHeaderRules()
+		if err == nil {
+			t.Errorf("expected error for %s", c)
+		}
+	}
+}
@@ -27,4 +27,7 @@ const (
 	DefaultUseCacheChunking      = false
 	DefaultTimeseriesChunkFactor = int64(420)
 	DefaultByterangeChunkSize    = int64(4096)
+	// DefaultCompressionAlgorithm is the default algorithm used to compress
+	// cacheable objects before they are written to a non-memory cache
+	DefaultCompressionAlgorithm = "brotli"
 )
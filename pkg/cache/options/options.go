@@ -20,6 +20,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	badger "github.com/trickstercache/trickster/v2/pkg/cache/badger/options"
 	bbolt "github.com/trickstercache/trickster/v2/pkg/cache/bbolt/options"
@@ -28,6 +29,7 @@ import (
 	"github.com/trickstercache/trickster/v2/pkg/cache/options/defaults"
 	"github.com/trickstercache/trickster/v2/pkg/cache/providers"
 	redis "github.com/trickstercache/trickster/v2/pkg/cache/redis/options"
+	tiered "github.com/trickstercache/trickster/v2/pkg/cache/tiered/options"
 	strutil "github.com/trickstercache/trickster/v2/pkg/util/strings"
 	"github.com/trickstercache/trickster/v2/pkg/util/yamlx"
 )
@@ -51,6 +53,8 @@ type Options struct {
 	BBolt *bbolt.Options `yaml:"bbolt,omitempty"`
 	// Badger provides options for BadgerDB caching
 	Badger *badger.Options `yaml:"badger,omitempty"`
+	// Tiered provides options for Tiered caching
+	Tiered *tiered.Options `yaml:"tiered,omitempty"`
 
 	// Defines if the cache should use cache chunking. Splits cache objects into smaller, reliably-sized parts.
 	UseCacheChunking bool `yaml:"use_cache_chunking,omitempty"`
@@ -58,6 +62,16 @@ type Options struct {
 	TimeseriesChunkFactor int64 `yaml:"timeseries_chunk_factor"`
 	// Determines chunk size (bytes) for byterange objects
 	ByterangeChunkSize int64 `yaml:"byterange_chunk_size"`
+	// CompressionAlgorithm selects the algorithm used to compress compressible objects
+	// before they are stored: "none", "brotli", "zstd" or "snappy"
+	CompressionAlgorithm string `yaml:"compression_algorithm,omitempty"`
+	// TTLJitterPercent extends the TTL of each stored object by up to this percentage,
+	// varying by key, so objects written together do not all expire together
+	TTLJitterPercent int `yaml:"ttl_jitter_percent,omitempty"`
+	// SeparateMetadata, when true, prefixes each stored object with an uncompressed copy of
+	// its metadata (status, headers, caching policy and content length), so it can be read
+	// for revalidation without decompressing the object's body
+	SeparateMetadata bool `yaml:"separate_metadata,omitempty"`
 
 	//  Synthetic Values
 
@@ -75,10 +89,12 @@ func New() *Options {
 		Filesystem:            filesystem.New(),
 		BBolt:                 bbolt.New(),
 		Badger:                badger.New(),
+		Tiered:                tiered.New(),
 		Index:                 index.New(),
 		UseCacheChunking:      defaults.DefaultUseCacheChunking,
 		TimeseriesChunkFactor: defaults.DefaultTimeseriesChunkFactor,
 		ByterangeChunkSize:    defaults.DefaultByterangeChunkSize,
+		CompressionAlgorithm:  defaults.DefaultCompressionAlgorithm,
 	}
 }
 
@@ -98,15 +114,23 @@ func (cc *Options) Clone() *Options {
 	c.Index.MaxSizeObjects = cc.Index.MaxSizeObjects
 	c.Index.ReapInterval = cc.Index.ReapInterval
 	c.Index.ReapIntervalMS = cc.Index.ReapIntervalMS
+	c.Index.SplayPercent = cc.Index.SplayPercent
 
 	c.Badger.Directory = cc.Badger.Directory
 	c.Badger.ValueDirectory = cc.Badger.ValueDirectory
+	c.Badger.GCIntervalSecs = cc.Badger.GCIntervalSecs
 
 	c.Filesystem.CachePath = cc.Filesystem.CachePath
+	c.Filesystem.ShardDepth = cc.Filesystem.ShardDepth
 
 	c.BBolt.Bucket = cc.BBolt.Bucket
 	c.BBolt.Filename = cc.BBolt.Filename
 
+	c.Tiered.L1CacheName = cc.Tiered.L1CacheName
+	c.Tiered.L2CacheName = cc.Tiered.L2CacheName
+	c.Tiered.L1TTLMS = cc.Tiered.L1TTLMS
+	c.Tiered.L1TTL = cc.Tiered.L1TTL
+
 	c.Redis.ClientType = cc.Redis.ClientType
 	c.Redis.DB = cc.Redis.DB
 	c.Redis.DialTimeoutMS = cc.Redis.DialTimeoutMS
@@ -130,6 +154,9 @@ func (cc *Options) Clone() *Options {
 	c.UseCacheChunking = cc.UseCacheChunking
 	c.TimeseriesChunkFactor = cc.TimeseriesChunkFactor
 	c.ByterangeChunkSize = cc.ByterangeChunkSize
+	c.CompressionAlgorithm = cc.CompressionAlgorithm
+	c.TTLJitterPercent = cc.TTLJitterPercent
+	c.SeparateMetadata = cc.SeparateMetadata
 
 	return c
 
@@ -152,6 +179,14 @@ func (cc *Options) Equal(cc2 *Options) bool {
 var errMaxSizeBackoffBytesTooBig = errors.New("MaxSizeBackoffBytes can't be larger than MaxSizeBytes")
 var errMaxSizeBackoffObjectsTooBig = errors.New("MaxSizeBackoffObjects can't be larger than MaxSizeObjects")
 
+// CompressionAlgorithms is the list of supported values for CompressionAlgorithm
+var CompressionAlgorithms = strutil.Lookup{
+	"none":   nil,
+	"brotli": nil,
+	"zstd":   nil,
+	"snappy": nil,
+}
+
 // SetDefaults iterates the provided Options, and overlays user-set values onto the default Options
 func (l Lookup) SetDefaults(metadata yamlx.KeyLookup, activeCaches strutil.Lookup) ([]string, error) {
 
@@ -159,6 +194,19 @@ func (l Lookup) SetDefaults(metadata yamlx.KeyLookup, activeCaches strutil.Looku
 
 	lw := make([]string, 0)
 
+	// the tiers of an active tiered cache are also active
+	for k, v := range l {
+		if _, ok := activeCaches[k]; !ok || strings.ToLower(v.Provider) != "tiered" ||
+			v.Tiered == nil {
+			continue
+		}
+		for _, n := range []string{v.Tiered.L1CacheName, v.Tiered.L2CacheName} {
+			if n != "" {
+				activeCaches[n] = true
+			}
+		}
+	}
+
 	for k, v := range l {
 
 		if _, ok := activeCaches[k]; !ok {
@@ -185,6 +233,14 @@ func (l Lookup) SetDefaults(metadata yamlx.KeyLookup, activeCaches strutil.Looku
 			cc.Index.FlushIntervalMS = v.Index.FlushIntervalMS
 		}
 
+		if metadata.IsDefined("caches", k, "index", "splay_percent") {
+			if v.Index.SplayPercent < 0 || v.Index.SplayPercent > 100 {
+				return nil, fmt.Errorf("invalid index splay_percent for cache %s: %d is not >= 0 and <= 100",
+					k, v.Index.SplayPercent)
+			}
+			cc.Index.SplayPercent = v.Index.SplayPercent
+		}
+
 		if metadata.IsDefined("caches", k, "index", "max_size_bytes") {
 			cc.Index.MaxSizeBytes = v.Index.MaxSizeBytes
 		}
@@ -209,6 +265,46 @@ func (l Lookup) SetDefaults(metadata yamlx.KeyLookup, activeCaches strutil.Looku
 			return nil, errMaxSizeBackoffObjectsTooBig
 		}
 
+		if metadata.IsDefined("caches", k, "compression_algorithm") {
+			cc.CompressionAlgorithm = strings.ToLower(v.CompressionAlgorithm)
+			if _, ok := CompressionAlgorithms[cc.CompressionAlgorithm]; !ok {
+				return nil, fmt.Errorf("invalid compression_algorithm for cache %s: %s",
+					k, v.CompressionAlgorithm)
+			}
+		}
+
+		if metadata.IsDefined("caches", k, "ttl_jitter_percent") {
+			if v.TTLJitterPercent < 0 || v.TTLJitterPercent > 100 {
+				return nil, fmt.Errorf("invalid ttl_jitter_percent for cache %s: %d is not >= 0 and <= 100",
+					k, v.TTLJitterPercent)
+			}
+			cc.TTLJitterPercent = v.TTLJitterPercent
+		}
+
+		if metadata.IsDefined("caches", k, "separate_metadata") {
+			cc.SeparateMetadata = v.SeparateMetadata
+		}
+
+		if metadata.IsDefined("caches", k, "use_cache_chunking") {
+			cc.UseCacheChunking = v.UseCacheChunking
+		}
+
+		if metadata.IsDefined("caches", k, "timeseries_chunk_factor") {
+			if v.TimeseriesChunkFactor < 1 {
+				return nil, fmt.Errorf("invalid timeseries_chunk_factor for cache %s: %d is not > 0",
+					k, v.TimeseriesChunkFactor)
+			}
+			cc.TimeseriesChunkFactor = v.TimeseriesChunkFactor
+		}
+
+		if metadata.IsDefined("caches", k, "byterange_chunk_size") {
+			if v.ByterangeChunkSize < 1 {
+				return nil, fmt.Errorf("invalid byterange_chunk_size for cache %s: %d is not > 0",
+					k, v.ByterangeChunkSize)
+			}
+			cc.ByterangeChunkSize = v.ByterangeChunkSize
+		}
+
 		if cc.ProviderID == providers.Redis {
 
 			var hasEndpoint, hasEndpoints bool
@@ -228,7 +324,7 @@ func (l Lookup) SetDefaults(metadata yamlx.KeyLookup, activeCaches strutil.Looku
 			}
 
 			if metadata.IsDefined("caches", k, "redis", "endpoints") {
-				cc.Redis.Endpoints = v.Redis.Endpoints
+				cc.Redis.Endpoints = splitEndpoints(v.Redis.Endpoints)
 				hasEndpoints = true
 			}
 
@@ -245,6 +341,13 @@ func (l Lookup) SetDefaults(metadata yamlx.KeyLookup, activeCaches strutil.Looku
 				}
 			}
 
+			if (cc.Redis.ClientType == "cluster" || cc.Redis.ClientType == "sentinel") &&
+				len(cc.Redis.Endpoints) == 0 {
+				return nil, fmt.Errorf("invalid redis config for cache %s: "+
+					"'%s' client_type requires at least one 'endpoints' value",
+					k, cc.Redis.ClientType)
+			}
+
 			if metadata.IsDefined("caches", k, "redis", "sentinel_master") {
 				cc.Redis.SentinelMaster = v.Redis.SentinelMaster
 			}
@@ -310,6 +413,14 @@ func (l Lookup) SetDefaults(metadata yamlx.KeyLookup, activeCaches strutil.Looku
 			cc.Filesystem.CachePath = v.Filesystem.CachePath
 		}
 
+		if metadata.IsDefined("caches", k, "filesystem", "shard_depth") {
+			if v.Filesystem.ShardDepth < 0 || v.Filesystem.ShardDepth > filesystem.MaxShardDepth {
+				return nil, fmt.Errorf("invalid shard_depth for cache %s: %d is not >= 0 and <= %d",
+					k, v.Filesystem.ShardDepth, filesystem.MaxShardDepth)
+			}
+			cc.Filesystem.ShardDepth = v.Filesystem.ShardDepth
+		}
+
 		if metadata.IsDefined("caches", k, "bbolt", "filename") {
 			cc.BBolt.Filename = v.BBolt.Filename
 		}
@@ -326,7 +437,66 @@ func (l Lookup) SetDefaults(metadata yamlx.KeyLookup, activeCaches strutil.Looku
 			cc.Badger.ValueDirectory = v.Badger.ValueDirectory
 		}
 
+		if metadata.IsDefined("caches", k, "badger", "gc_interval_secs") {
+			cc.Badger.GCIntervalSecs = v.Badger.GCIntervalSecs
+		}
+
+		if cc.ProviderID == providers.Tiered {
+			if err := validateTiers(k, v.Tiered, l); err != nil {
+				return nil, err
+			}
+			cc.Tiered.L1CacheName = v.Tiered.L1CacheName
+			cc.Tiered.L2CacheName = v.Tiered.L2CacheName
+			if metadata.IsDefined("caches", k, "tiered", "l1_ttl_ms") {
+				if v.Tiered.L1TTLMS < 1 {
+					return nil, fmt.Errorf("invalid tiered l1_ttl_ms for cache %s: %d is not > 0",
+						k, v.Tiered.L1TTLMS)
+				}
+				cc.Tiered.L1TTLMS = v.Tiered.L1TTLMS
+			}
+			cc.Tiered.L1TTL = time.Duration(cc.Tiered.L1TTLMS) * time.Millisecond
+		}
+
 		l[k] = cc
 	}
 	return lw, nil
 }
+
+// validateTiers returns an error if the tiers of the named tiered cache are not two
+// different, configured caches that are not themselves tiered
+func validateTiers(name string, o *tiered.Options, l Lookup) error {
+	if o == nil || o.L1CacheName == "" || o.L2CacheName == "" {
+		return fmt.Errorf("invalid tiered config for cache %s: "+
+			"l1_cache_name and l2_cache_name are required", name)
+	}
+	if o.L1CacheName == o.L2CacheName {
+		return fmt.Errorf("invalid tiered config for cache %s: "+
+			"l1_cache_name and l2_cache_name must be different caches", name)
+	}
+	for _, n := range []string{o.L1CacheName, o.L2CacheName} {
+		t, ok := l[n]
+		if !ok {
+			return fmt.Errorf("invalid tiered config for cache %s: unknown cache name %s",
+				name, n)
+		}
+		if strings.ToLower(t.Provider) == "tiered" {
+			return fmt.Errorf("invalid tiered config for cache %s: "+
+				"cache %s is tiered and can't be a tier", name, n)
+		}
+	}
+	return nil
+}
+
+// splitEndpoints flattens any comma-separated entries in the endpoints list
+// and drops empty ones
+func splitEndpoints(in []string) []string {
+	out := make([]string, 0, len(in))
+	for _, e := range in {
+		for _, p := range strings.Split(e, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				out = append(out, p)
+			}
+		}
+	}
+	return out
+}
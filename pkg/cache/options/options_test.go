@@ -19,6 +19,7 @@ package options
 import (
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/trickstercache/trickster/v2/pkg/cache/providers"
 	strutil "github.com/trickstercache/trickster/v2/pkg/util/strings"
@@ -119,8 +120,273 @@ func TestSetDefaults(t *testing.T) {
 		t.Error(err)
 	}
 
+	kl, err = yamlx.GetKeyList(testYAMLCluster)
+	if err != nil {
+		t.Error(err)
+	}
+
+	o = New()
+	o.Provider = "redis"
+	o.ProviderID = providers.Redis
+	o.Redis.ClientType = "cluster"
+	o.Redis.Endpoints = []string{"redis-1:6379, redis-2:6379", "redis-3:6379"}
+	l = Lookup{"default": o}
+	_, err = l.SetDefaults(kl, ac)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(l["default"].Redis.Endpoints) != 3 {
+		t.Errorf("expected %d got %d", 3, len(l["default"].Redis.Endpoints))
+	}
+
+	o = New()
+	o.Provider = "redis"
+	o.ProviderID = providers.Redis
+	o.Redis.ClientType = "cluster"
+	o.Redis.Endpoints = []string{}
+	l = Lookup{"default": o}
+	_, err = l.SetDefaults(kl, ac)
+	if err == nil {
+		t.Error("expected error for empty cluster endpoints")
+	}
+
+	kl, err = yamlx.GetKeyList(testYAMLJitter)
+	if err != nil {
+		t.Error(err)
+	}
+
+	o = New()
+	o.TTLJitterPercent = 150
+	l = Lookup{"default": o}
+	_, err = l.SetDefaults(kl, ac)
+	if err == nil {
+		t.Error("expected error for invalid ttl_jitter_percent")
+	}
+
+	kl, err = yamlx.GetKeyList(testYAMLSplay)
+	if err != nil {
+		t.Error(err)
+	}
+
+	o = New()
+	o.Index.SplayPercent = -1
+	l = Lookup{"default": o}
+	_, err = l.SetDefaults(kl, ac)
+	if err == nil {
+		t.Error("expected error for invalid index splay_percent")
+	}
+
+	kl, err = yamlx.GetKeyList(testYAMLChunking)
+	if err != nil {
+		t.Error(err)
+	}
+
+	o = New()
+	o.UseCacheChunking = true
+	o.ByterangeChunkSize = 65536
+	l = Lookup{"default": o}
+	_, err = l.SetDefaults(kl, ac)
+	if err != nil {
+		t.Error(err)
+	}
+	if !l["default"].UseCacheChunking {
+		t.Error("expected cache chunking to be enabled")
+	}
+	if l["default"].ByterangeChunkSize != 65536 {
+		t.Errorf("expected %d got %d", 65536, l["default"].ByterangeChunkSize)
+	}
+
+	o = New()
+	o.ByterangeChunkSize = 0
+	l = Lookup{"default": o}
+	_, err = l.SetDefaults(kl, ac)
+	if err == nil {
+		t.Error("expected error for invalid byterange_chunk_size")
+	}
+
+	kl, err = yamlx.GetKeyList(testYAMLCompression)
+	if err != nil {
+		t.Error(err)
+	}
+
+	o = New()
+	o.CompressionAlgorithm = "ZSTD"
+	l = Lookup{"default": o}
+	_, err = l.SetDefaults(kl, ac)
+	if err != nil {
+		t.Error(err)
+	}
+	if l["default"].CompressionAlgorithm != "zstd" {
+		t.Errorf("expected %s got %s", "zstd", l["default"].CompressionAlgorithm)
+	}
+
+	o = New()
+	o.CompressionAlgorithm = "lz4"
+	l = Lookup{"default": o}
+	_, err = l.SetDefaults(kl, ac)
+	if err == nil {
+		t.Error("expected error for invalid compression algorithm")
+	}
+
+	kl, err = yamlx.GetKeyList(testYAMLShardDepth)
+	if err != nil {
+		t.Error(err)
+	}
+
+	o = New()
+	o.Provider = "filesystem"
+	o.Filesystem.ShardDepth = 2
+	l = Lookup{"default": o}
+	_, err = l.SetDefaults(kl, ac)
+	if err != nil {
+		t.Error(err)
+	}
+	if l["default"].Filesystem.ShardDepth != 2 {
+		t.Errorf("expected %d got %d", 2, l["default"].Filesystem.ShardDepth)
+	}
+
+	o.Filesystem.ShardDepth = 3
+	l = Lookup{"default": o}
+	_, err = l.SetDefaults(kl, ac)
+	if err == nil {
+		t.Error("expected error for invalid shard_depth")
+	}
+
+	kl, err = yamlx.GetKeyList(testYAMLSeparateMetadata)
+	if err != nil {
+		t.Error(err)
+	}
+
+	o = New()
+	o.SeparateMetadata = true
+	l = Lookup{"default": o}
+	_, err = l.SetDefaults(kl, ac)
+	if err != nil {
+		t.Error(err)
+	}
+	if !l["default"].SeparateMetadata || !l["default"].Clone().SeparateMetadata {
+		t.Error("expected true")
+	}
+
+	kl, err = yamlx.GetKeyList(testYAMLTiered)
+	if err != nil {
+		t.Error(err)
+	}
+
+	newTieredLookup := func(l1, l2 string, l1TTLMS int) Lookup {
+		o := New()
+		o.Provider = "tiered"
+		o.Tiered.L1CacheName = l1
+		o.Tiered.L2CacheName = l2
+		o.Tiered.L1TTLMS = l1TTLMS
+		o1 := New()
+		o1.Provider = "memory"
+		o2 := New()
+		o2.Provider = "redis"
+		return Lookup{"default": o, "l1": o1, "l2": o2}
+	}
+
+	l = newTieredLookup("l1", "l2", 5000)
+	_, err = l.SetDefaults(kl, strutil.Lookup{"default": nil})
+	if err != nil {
+		t.Error(err)
+	}
+	// the tiers are retained, since they are used by the active tiered cache
+	if _, ok := l["l2"]; !ok {
+		t.Error("expected tier l2 to be retained")
+	}
+	if v := l["default"].Clone().Tiered; v.L1CacheName != "l1" || v.L2CacheName != "l2" ||
+		v.L1TTL != 5*time.Second {
+		t.Errorf("unexpected tiered options: %v", v)
+	}
+
+	for _, test := range []struct {
+		l1, l2  string
+		l1TTLMS int
+	}{
+		{"", "l2", 5000},        // missing l1_cache_name
+		{"l1", "l1", 5000},      // same cache for both tiers
+		{"l1", "l3", 5000},      // unknown cache
+		{"l1", "default", 5000}, // tiered cache as a tier
+		{"l1", "l2", 0},         // invalid l1_ttl_ms
+	} {
+		l = newTieredLookup(test.l1, test.l2, test.l1TTLMS)
+		_, err = l.SetDefaults(kl, strutil.Lookup{"default": nil})
+		if err == nil {
+			t.Errorf("expected error for tiered config %v", test)
+		}
+	}
+
 }
 
+const testYAMLTiered = `
+caches:
+  default:
+    provider: tiered
+    tiered:
+      l1_cache_name: l1
+      l2_cache_name: l2
+      l1_ttl_ms: 5000
+  l1:
+    provider: memory
+  l2:
+    provider: redis
+`
+
+const testYAMLCluster = `
+caches:
+  default:
+    provider: redis
+    redis:
+      client_type: cluster
+      endpoints: [ 'redis-1:6379, redis-2:6379', redis-3:6379 ]
+`
+
+const testYAMLSeparateMetadata = `
+caches:
+  default:
+    provider: memory
+    separate_metadata: true
+`
+
+const testYAMLJitter = `
+caches:
+  default:
+    provider: memory
+    ttl_jitter_percent: 150
+`
+
+const testYAMLSplay = `
+caches:
+  default:
+    provider: memory
+    index:
+      splay_percent: -1
+`
+
+const testYAMLShardDepth = `
+caches:
+  default:
+    provider: filesystem
+    filesystem:
+      shard_depth: 2
+`
+
+const testYAMLChunking = `
+caches:
+  default:
+    provider: memory
+    use_cache_chunking: true
+    byterange_chunk_size: 65536
+`
+
+const testYAMLCompression = `
+caches:
+  default:
+    provider: memory
+    compression_algorithm: zstd
+`
+
 const testYAML = `
 caches:
   default:
@@ -136,10 +136,40 @@ func (c *Cache) SetTTL(cacheKey string, ttl time.Duration) {
 	c.client.Expire(cacheKey, ttl)
 }
 
+// Inspect returns the remaining TTL and value size for the provided cache object,
+// using the PTTL and STRLEN commands
+func (c *Cache) Inspect(cacheKey string) (*cache.ObjectInfo, error) {
+	ttl, err := c.client.PTTL(cacheKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	// PTTL reports -2 when the key does not exist, and -1 when it has no expiration
+	if ttl == -2*time.Millisecond {
+		return nil, cache.ErrKNF
+	}
+	size, err := c.client.StrLen(cacheKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	oi := &cache.ObjectInfo{Key: cacheKey, Size: size}
+	if ttl > 0 {
+		oi.Expiration = time.Now().Add(ttl)
+	}
+	return oi, nil
+}
+
 // BulkRemove removes a list of objects from the cache. noLock is not used for Redis
 func (c *Cache) BulkRemove(cacheKeys []string) {
 	tl.Debug(c.Logger, "redis cache bulk remove", tl.Pairs{})
-	c.client.Del(cacheKeys...)
+	if c.Config.Redis.ClientType == "cluster" {
+		// a multi-key DEL fails in a cluster when the keys hash to different
+		// slots, so each key is removed individually
+		for _, k := range cacheKeys {
+			c.client.Del(k)
+		}
+	} else {
+		c.client.Del(cacheKeys...)
+	}
 	metrics.ObserveCacheDel(c.Name, c.Config.Provider, float64(len(cacheKeys)))
 }
 
@@ -22,6 +22,7 @@ import (
 	"time"
 
 	"github.com/trickstercache/trickster/v2/cmd/trickster/config"
+	tc "github.com/trickstercache/trickster/v2/pkg/cache"
 	co "github.com/trickstercache/trickster/v2/pkg/cache/options"
 	ro "github.com/trickstercache/trickster/v2/pkg/cache/redis/options"
 	"github.com/trickstercache/trickster/v2/pkg/cache/status"
@@ -257,6 +258,37 @@ func TestRedisCache_SetTTL(t *testing.T) {
 
 }
 
+func TestRedisCache_Inspect(t *testing.T) {
+
+	cache, closer := setupRedisCache(clientTypeStandard)
+	defer closer()
+
+	err := cache.Connect()
+	if err != nil {
+		t.Error(err)
+	}
+	defer cache.Close()
+
+	if _, err = cache.Inspect(cacheKey); err != tc.ErrKNF {
+		t.Errorf("expected %v got %v", tc.ErrKNF, err)
+	}
+
+	err = cache.Store(cacheKey, []byte("data"), time.Duration(60)*time.Second)
+	if err != nil {
+		t.Error(err)
+	}
+	oi, err := cache.Inspect(cacheKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if oi.Size != 4 {
+		t.Errorf("expected %d got %d", 4, oi.Size)
+	}
+	if ttl := oi.TTL(time.Now()); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("unexpected ttl %s", ttl)
+	}
+}
+
 func BenchmarkCache_SetTTL(b *testing.B) {
 	rc, close := storeBenchmark(b)
 	defer close()
@@ -27,7 +27,9 @@ import (
 	"github.com/trickstercache/trickster/v2/pkg/cache/memory"
 	"github.com/trickstercache/trickster/v2/pkg/cache/options"
 	"github.com/trickstercache/trickster/v2/pkg/cache/redis"
+	"github.com/trickstercache/trickster/v2/pkg/cache/tiered"
 	"github.com/trickstercache/trickster/v2/pkg/locks"
+	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
 )
 
 // Cache Interface Types
@@ -36,6 +38,7 @@ const (
 	ctRedis      = "redis"
 	ctBBolt      = "bbolt"
 	ctBadger     = "badger"
+	ctTiered     = "tiered"
 )
 
 // Caches maintains a list of active caches
@@ -53,12 +56,43 @@ const (
 func LoadCachesFromConfig(conf *config.Config, logger interface{}) map[string]cache.Cache {
 	caches := make(map[string]cache.Cache)
 	for k, v := range conf.Caches {
+		if v.Provider == ctTiered {
+			continue
+		}
 		c := NewCache(k, v, logger)
 		caches[k] = c
 	}
+	AddTieredCaches(conf.Caches, caches, logger)
 	return caches
 }
 
+// AddTieredCaches creates each tiered cache in the provided config from its tiers, which
+// must already be in caches, and adds it to caches. Since a tiered cache holds no state
+// of its own, it is always created anew, so that it references the current tiers
+func AddTieredCaches(cfgs map[string]*options.Options, caches map[string]cache.Cache,
+	logger interface{}) {
+	for k, v := range cfgs {
+		if v.Provider != ctTiered {
+			continue
+		}
+		if v.Tiered == nil {
+			tl.Error(logger, "tiered cache has no tiered config", tl.Pairs{"cacheName": k})
+			continue
+		}
+		l1, ok1 := caches[v.Tiered.L1CacheName]
+		l2, ok2 := caches[v.Tiered.L2CacheName]
+		if !ok1 || !ok2 {
+			tl.Error(logger, "tiered cache is missing a tier", tl.Pairs{"cacheName": k,
+				"l1CacheName": v.Tiered.L1CacheName, "l2CacheName": v.Tiered.L2CacheName})
+			continue
+		}
+		c := tiered.New(k, v, l1, l2, logger)
+		c.SetLocker(locks.NewNamedLocker())
+		c.Connect()
+		caches[k] = c
+	}
+}
+
 // CloseCaches iterates the set of caches and closes each
 func CloseCaches(caches map[string]cache.Cache) error {
 	for _, c := range caches {
@@ -71,6 +105,13 @@ func CloseCaches(caches map[string]cache.Cache) error {
 
 // NewCache returns a Cache object based on the provided config.CachingConfig
 func NewCache(cacheName string, cfg *options.Options, logger interface{}) cache.Cache {
+	c, _ := ConnectCache(cacheName, cfg, logger)
+	return c
+}
+
+// ConnectCache returns a Cache object based on the provided config.CachingConfig,
+// along with any error from connecting to it
+func ConnectCache(cacheName string, cfg *options.Options, logger interface{}) (cache.Cache, error) {
 
 	var c cache.Cache
 
@@ -89,6 +130,6 @@ func NewCache(cacheName string, cfg *options.Options, logger interface{}) cache.
 	}
 
 	c.SetLocker(locks.NewNamedLocker())
-	c.Connect()
-	return c
+	err := c.Connect()
+	return c, err
 }
@@ -18,6 +18,7 @@ package registration
 
 import (
 	"testing"
+	"time"
 
 	"github.com/trickstercache/trickster/v2/cmd/trickster/config"
 	bao "github.com/trickstercache/trickster/v2/pkg/cache/badger/options"
@@ -27,6 +28,8 @@ import (
 	co "github.com/trickstercache/trickster/v2/pkg/cache/options"
 	"github.com/trickstercache/trickster/v2/pkg/cache/providers"
 	ro "github.com/trickstercache/trickster/v2/pkg/cache/redis/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache/tiered"
+	tio "github.com/trickstercache/trickster/v2/pkg/cache/tiered/options"
 	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
 )
 
@@ -48,6 +51,9 @@ func TestLoadCachesFromConfig(t *testing.T) {
 			cfg.BBolt.Filename = t.TempDir() + "/" + key + "-testcache"
 		case providers.BadgerDB:
 			cfg.BBolt.Filename = t.TempDir() + "/" + key + "-testcache"
+		case providers.Tiered:
+			cfg.Tiered = &tio.Options{L1CacheName: "memory", L2CacheName: "default",
+				L1TTL: time.Second}
 		}
 	}
 
@@ -65,6 +71,11 @@ func TestLoadCachesFromConfig(t *testing.T) {
 		}
 	}
 
+	if c, ok := caches["tiered"].(*tiered.Cache); !ok || c.L1 != caches["memory"] ||
+		c.L2 != caches["default"] {
+		t.Errorf("expected tiered cache of %q and %q", "memory", "default")
+	}
+
 	_, ok = caches["foo"]
 	if ok {
 		t.Errorf("expected error")
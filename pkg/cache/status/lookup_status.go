@@ -49,6 +49,12 @@ const (
 	LookupStatusError
 	// LookupStatusProxyHit indicates that the request joined an existing proxy download of the same object
 	LookupStatusProxyHit
+	// LookupStatusStaleWhileRevalidate indicates that a stale cached object was served
+	// while it is revalidated against the upstream server in the background
+	LookupStatusStaleWhileRevalidate
+	// LookupStatusStaleIfError indicates that a stale cached object was served in place
+	// of an error response from the upstream server
+	LookupStatusStaleIfError
 )
 
 var cacheLookupStatusNames = map[string]LookupStatus{
@@ -63,20 +69,24 @@ var cacheLookupStatusNames = map[string]LookupStatus{
 	"nchit":       LookupStatusNegativeCacheHit,
 	"proxy-hit":   LookupStatusProxyHit,
 	"error":       LookupStatusError,
+	"swr":         LookupStatusStaleWhileRevalidate,
+	"sie":         LookupStatusStaleIfError,
 }
 
 var cacheLookupStatusValues = map[LookupStatus]string{
-	LookupStatusHit:              "hit",
-	LookupStatusPartialHit:       "phit",
-	LookupStatusRevalidated:      "rhit",
-	LookupStatusRangeMiss:        "rmiss",
-	LookupStatusKeyMiss:          "kmiss",
-	LookupStatusPurge:            "purge",
-	LookupStatusProxyError:       "proxy-error",
-	LookupStatusProxyOnly:        "proxy-only",
-	LookupStatusNegativeCacheHit: "nchit",
-	LookupStatusProxyHit:         "proxy-hit",
-	LookupStatusError:            "error",
+	LookupStatusHit:                  "hit",
+	LookupStatusPartialHit:           "phit",
+	LookupStatusRevalidated:          "rhit",
+	LookupStatusRangeMiss:            "rmiss",
+	LookupStatusKeyMiss:              "kmiss",
+	LookupStatusPurge:                "purge",
+	LookupStatusProxyError:           "proxy-error",
+	LookupStatusProxyOnly:            "proxy-only",
+	LookupStatusNegativeCacheHit:     "nchit",
+	LookupStatusProxyHit:             "proxy-hit",
+	LookupStatusError:                "error",
+	LookupStatusStaleWhileRevalidate: "swr",
+	LookupStatusStaleIfError:         "sie",
 }
 
 func (s LookupStatus) String() string {
@@ -0,0 +1,43 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package options provides the configuration of a tiered cache
+package options
+
+import "time"
+
+// DefaultL1TTLMS is the default maximum TTL of objects in the L1 cache
+const DefaultL1TTLMS = 10000
+
+// Options is a collection of Configurations for a tiered cache, which reads through
+// a fast L1 cache (such as memory) to a larger, shared L2 cache (such as redis)
+type Options struct {
+	// L1CacheName is the name of the cache that is read first, and populated from L2
+	L1CacheName string `yaml:"l1_cache_name,omitempty"`
+	// L2CacheName is the name of the cache that is read when an object is not in L1
+	L2CacheName string `yaml:"l2_cache_name,omitempty"`
+	// L1TTLMS is the maximum TTL of objects in L1. Objects are never kept in L1 longer
+	// than their TTL in L2
+	L1TTLMS int `yaml:"l1_ttl_ms,omitempty"`
+
+	// L1TTL is the time.Duration representation of L1TTLMS
+	L1TTL time.Duration `yaml:"-"`
+}
+
+// New returns a new Tiered Options Reference with default values set
+func New() *Options {
+	return &Options{L1TTLMS: DefaultL1TTLMS, L1TTL: DefaultL1TTLMS * time.Millisecond}
+}
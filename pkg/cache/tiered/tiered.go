@@ -0,0 +1,155 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package tiered is the tiered implementation of the Trickster Cache, which reads
+// through a fast L1 cache to a larger, shared L2 cache
+package tiered
+
+import (
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/cache"
+	"github.com/trickstercache/trickster/v2/pkg/cache/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache/status"
+	"github.com/trickstercache/trickster/v2/pkg/locks"
+	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
+)
+
+// Cache defines a Tiered Cache client that conforms to the Cache interface. Reads are
+// served from L1 when possible, falling back to L2 and promoting L2 hits into L1.
+// Writes and removals are applied to both tiers. The tiers are themselves configured
+// caches, which are connected and closed independently of the Tiered Cache
+type Cache struct {
+	Name   string
+	Config *options.Options
+	Logger interface{}
+	L1     cache.Cache
+	L2     cache.Cache
+	locker locks.NamedLocker
+}
+
+// New returns a new Tiered Cache for the provided tiers
+func New(name string, cfg *options.Options, l1, l2 cache.Cache, logger interface{}) *Cache {
+	return &Cache{Name: name, Config: cfg, L1: l1, L2: l2, Logger: logger}
+}
+
+// Locker returns the cache's locker
+func (c *Cache) Locker() locks.NamedLocker {
+	return c.locker
+}
+
+// SetLocker sets the cache's locker
+func (c *Cache) SetLocker(l locks.NamedLocker) {
+	c.locker = l
+}
+
+// Configuration returns the Configuration for the Cache object
+func (c *Cache) Configuration() *options.Options {
+	return c.Config
+}
+
+// Connect initializes the Cache. The tiers are connected when they are created
+func (c *Cache) Connect() error {
+	tl.Info(c.Logger, "tiered cache setup", tl.Pairs{"name": c.Name,
+		"l1CacheName": c.Config.Tiered.L1CacheName, "l2CacheName": c.Config.Tiered.L2CacheName,
+		"l1TTL": c.Config.Tiered.L1TTL})
+	return nil
+}
+
+// Store places an object in L2 and then L1 using the specified key and ttl. The
+// object's TTL in L1 is limited to the configured L1 TTL
+func (c *Cache) Store(cacheKey string, data []byte, ttl time.Duration) error {
+	if err := c.L2.Store(cacheKey, data, ttl); err != nil {
+		// any copy in L1 is now older than the object the caller intended to store
+		c.L1.Remove(cacheKey)
+		return err
+	}
+	return c.L1.Store(cacheKey, data, c.l1TTL(ttl))
+}
+
+// Retrieve looks for an object in L1, and then in L2 (or returns an error if not
+// found in either). Objects found in L2 are promoted into L1 for the lesser of the
+// L1 TTL and their remaining TTL in L2. Expired objects are never served from L1
+func (c *Cache) Retrieve(cacheKey string, allowExpired bool) ([]byte, status.LookupStatus, error) {
+	if b, s, err := c.L1.Retrieve(cacheKey, false); err == nil {
+		tl.Debug(c.Logger, "tiered cache l1 hit", tl.Pairs{"cacheName": c.Name,
+			"cacheKey": cacheKey})
+		return b, s, nil
+	}
+	b, s, err := c.L2.Retrieve(cacheKey, allowExpired)
+	if err != nil || s != status.LookupStatusHit {
+		return b, s, err
+	}
+	if ttl := c.promotionTTL(cacheKey); ttl > 0 {
+		tl.Debug(c.Logger, "tiered cache l2 promotion", tl.Pairs{"cacheName": c.Name,
+			"cacheKey": cacheKey, "ttl": ttl})
+		c.L1.Store(cacheKey, b, ttl)
+	}
+	return b, s, nil
+}
+
+// promotionTTL returns the TTL of an object promoted from L2 into L1, which is the
+// lesser of the L1 TTL and the object's remaining TTL in L2, when it is known
+func (c *Cache) promotionTTL(cacheKey string) time.Duration {
+	oi, err := cache.Inspect(c.L2, cacheKey)
+	if err != nil {
+		return c.Config.Tiered.L1TTL
+	}
+	ttl := oi.TTL(time.Now())
+	if ttl == 0 {
+		// the object has expired in L2
+		return 0
+	}
+	return c.l1TTL(ttl)
+}
+
+// l1TTL returns the provided ttl, limited to the configured L1 TTL. A ttl <= 0
+// (one that does not expire) returns the L1 TTL
+func (c *Cache) l1TTL(ttl time.Duration) time.Duration {
+	if ttl <= 0 || ttl > c.Config.Tiered.L1TTL {
+		return c.Config.Tiered.L1TTL
+	}
+	return ttl
+}
+
+// Inspect returns the metadata for the provided cache object from L2, which is the
+// authoritative tier. ErrInspectUnsupported is returned if L2 is not an Inspector
+func (c *Cache) Inspect(cacheKey string) (*cache.ObjectInfo, error) {
+	return cache.Inspect(c.L2, cacheKey)
+}
+
+// SetTTL updates the TTL for the provided cache object in both tiers
+func (c *Cache) SetTTL(cacheKey string, ttl time.Duration) {
+	c.L2.SetTTL(cacheKey, ttl)
+	c.L1.SetTTL(cacheKey, c.l1TTL(ttl))
+}
+
+// Remove removes an object from both tiers
+func (c *Cache) Remove(cacheKey string) {
+	c.L1.Remove(cacheKey)
+	c.L2.Remove(cacheKey)
+}
+
+// BulkRemove removes a list of objects from both tiers
+func (c *Cache) BulkRemove(cacheKeys []string) {
+	c.L1.BulkRemove(cacheKeys)
+	c.L2.BulkRemove(cacheKeys)
+}
+
+// Close is a no-op, since the tiers are closed independently of the Tiered Cache
+func (c *Cache) Close() error {
+	return nil
+}
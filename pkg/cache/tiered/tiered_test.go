@@ -0,0 +1,195 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tiered
+
+import (
+	"testing"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/cache"
+	io "github.com/trickstercache/trickster/v2/pkg/cache/index/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache/memory"
+	co "github.com/trickstercache/trickster/v2/pkg/cache/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache/status"
+	tio "github.com/trickstercache/trickster/v2/pkg/cache/tiered/options"
+	"github.com/trickstercache/trickster/v2/pkg/locks"
+	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
+)
+
+const cacheKey = "cacheKey"
+
+func newMemoryCache(t *testing.T, name string) *memory.Cache {
+	mc := &memory.Cache{Name: name, Config: &co.Options{Provider: "memory",
+		Index: &io.Options{ReapInterval: 0}}, Logger: tl.ConsoleLogger("error")}
+	mc.SetLocker(locks.NewNamedLocker())
+	if err := mc.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	return mc
+}
+
+func newTestCache(t *testing.T) (*Cache, *memory.Cache, *memory.Cache) {
+	l1 := newMemoryCache(t, "l1")
+	l2 := newMemoryCache(t, "l2")
+	cfg := &co.Options{Provider: "tiered", Tiered: &tio.Options{L1CacheName: "l1",
+		L2CacheName: "l2", L1TTLMS: 10000, L1TTL: 10 * time.Second}}
+	c := New("test", cfg, l1, l2, tl.ConsoleLogger("error"))
+	c.SetLocker(locks.NewNamedLocker())
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	return c, l1, l2
+}
+
+func TestConfiguration(t *testing.T) {
+	c, _, _ := newTestCache(t)
+	if cfg := c.Configuration(); cfg.Tiered.L1CacheName != "l1" {
+		t.Errorf("expected %s got %s", "l1", cfg.Tiered.L1CacheName)
+	}
+	if c.Locker() == nil {
+		t.Error("expected non-nil locker")
+	}
+	if err := c.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRetrieveL1Hit(t *testing.T) {
+	c, l1, _ := newTestCache(t)
+	l1.Store(cacheKey, []byte("l1-data"), time.Minute)
+
+	b, s, err := c.Retrieve(cacheKey, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != status.LookupStatusHit || string(b) != "l1-data" {
+		t.Errorf("expected %s %s got %s %s", status.LookupStatusHit, "l1-data", s, string(b))
+	}
+
+	_, s, err = c.Retrieve("missing", false)
+	if err != cache.ErrKNF || s != status.LookupStatusKeyMiss {
+		t.Errorf("expected %v %s got %v %s", cache.ErrKNF, status.LookupStatusKeyMiss, err, s)
+	}
+}
+
+func TestRetrieveL2Promotion(t *testing.T) {
+	c, l1, l2 := newTestCache(t)
+	l2.Store(cacheKey, []byte("l2-data"), time.Hour)
+
+	b, s, err := c.Retrieve(cacheKey, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != status.LookupStatusHit || string(b) != "l2-data" {
+		t.Errorf("expected %s %s got %s %s", status.LookupStatusHit, "l2-data", s, string(b))
+	}
+
+	// the object is promoted into L1 for no longer than the L1 TTL
+	b, _, err = l1.Retrieve(cacheKey, false)
+	if err != nil || string(b) != "l2-data" {
+		t.Errorf("expected %s got %s %v", "l2-data", string(b), err)
+	}
+	oi, err := l1.Inspect(cacheKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ttl := oi.TTL(time.Now()); ttl <= 0 || ttl > c.Config.Tiered.L1TTL {
+		t.Errorf("expected ttl <= %s got %s", c.Config.Tiered.L1TTL, ttl)
+	}
+
+	// an object expiring sooner in L2 is promoted with its remaining L2 TTL
+	l2.Store("short", []byte("short-data"), 2*time.Second)
+	c.Retrieve("short", false)
+	oi, err = l1.Inspect("short")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ttl := oi.TTL(time.Now()); ttl <= 0 || ttl > 2*time.Second {
+		t.Errorf("expected ttl <= %s got %s", 2*time.Second, ttl)
+	}
+}
+
+func TestStoreWriteThrough(t *testing.T) {
+	c, l1, l2 := newTestCache(t)
+	if err := c.Store(cacheKey, []byte("data"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	for _, mc := range []*memory.Cache{l1, l2} {
+		b, _, err := mc.Retrieve(cacheKey, false)
+		if err != nil || string(b) != "data" {
+			t.Errorf("%s expected %s got %s %v", mc.Name, "data", string(b), err)
+		}
+	}
+
+	oi, err := l1.Inspect(cacheKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ttl := oi.TTL(time.Now()); ttl > c.Config.Tiered.L1TTL {
+		t.Errorf("expected ttl <= %s got %s", c.Config.Tiered.L1TTL, ttl)
+	}
+
+	// Inspect reports the object's metadata from L2
+	oi, err = c.Inspect(cacheKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ttl := oi.TTL(time.Now()); ttl <= c.Config.Tiered.L1TTL {
+		t.Errorf("expected ttl > %s got %s", c.Config.Tiered.L1TTL, ttl)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	c, l1, l2 := newTestCache(t)
+	c.Store(cacheKey, []byte("data"), time.Hour)
+	c.Remove(cacheKey)
+	for _, mc := range []*memory.Cache{l1, l2} {
+		if _, _, err := mc.Retrieve(cacheKey, false); err != cache.ErrKNF {
+			t.Errorf("%s expected %v got %v", mc.Name, cache.ErrKNF, err)
+		}
+	}
+
+	keys := []string{"key1", "key2"}
+	for _, k := range keys {
+		c.Store(k, []byte("data"), time.Hour)
+	}
+	c.BulkRemove(keys)
+	for _, k := range keys {
+		for _, mc := range []*memory.Cache{l1, l2} {
+			if _, _, err := mc.Retrieve(k, false); err != cache.ErrKNF {
+				t.Errorf("%s %s expected %v got %v", mc.Name, k, cache.ErrKNF, err)
+			}
+		}
+	}
+}
+
+func TestL1TTL(t *testing.T) {
+	c, _, _ := newTestCache(t)
+	tests := []struct {
+		ttl, expected time.Duration
+	}{
+		{0, 10 * time.Second},
+		{-1, 10 * time.Second},
+		{time.Hour, 10 * time.Second},
+		{time.Second, time.Second},
+	}
+	for i, test := range tests {
+		if v := c.l1TTL(test.ttl); v != test.expected {
+			t.Errorf("test %d expected %s got %s", i, test.expected, v)
+		}
+	}
+}
@@ -28,4 +28,14 @@ const (
 	DefaultTLSProxyListenPort = 8483
 	// DefaultTLSProxyListenAddress is the default address that the TLS frontend endpoint will listen on
 	DefaultTLSProxyListenAddress = ""
+
+	// DefaultReadHeaderTimeoutMS is the default time allowed for reading request headers
+	DefaultReadHeaderTimeoutMS = 10000
+	// DefaultReadTimeoutMS is the default time allowed for reading an entire request
+	DefaultReadTimeoutMS = 60000
+	// DefaultWriteTimeoutMS is the default time allowed for writing a response. 0 disables the
+	// timeout, so that long streaming responses are not interrupted
+	DefaultWriteTimeoutMS = 0
+	// DefaultIdleTimeoutMS is the default time an idle keep-alive connection is kept open
+	DefaultIdleTimeoutMS = 120000
 )
@@ -16,6 +16,25 @@
 
 package options
 
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrInvalidTLSCertificate is returned when a frontend TLS certificate is
+// incompletely configured or can't be loaded
+var ErrInvalidTLSCertificate = errors.New("invalid frontend tls certificate")
+
+// ErrInvalidClientCA is returned when the frontend client certificate authority is
+// required but not configured, or can't be loaded
+var ErrInvalidClientCA = errors.New("invalid frontend client ca")
+
+// ErrInvalidTimeout is returned when a frontend listener timeout is negative
+var ErrInvalidTimeout = errors.New("invalid frontend timeout")
+
 // FrontendConfig is a collection of configurations for the main http frontend for the application
 type Options struct {
 	// ListenAddress is IP address for the main http listener for the application
@@ -28,35 +47,186 @@ type Options struct {
 	TLSListenPort int `yaml:"tls_listen_port,omitempty"`
 	// ConnectionsLimit indicates how many concurrent front end connections trickster will handle at any time
 	ConnectionsLimit int `yaml:"connections_limit,omitempty"`
+	// EnableH2C indicates whether the main http listener also serves prior-knowledge
+	// HTTP/2 cleartext (h2c) requests, alongside HTTP/1.1 on the same port
+	EnableH2C bool `yaml:"enable_h2c,omitempty"`
+	// ReadHeaderTimeoutMS is the maximum time the http and tls listeners allow for reading a
+	// request's headers, which protects against clients that send headers very slowly
+	ReadHeaderTimeoutMS int `yaml:"read_header_timeout_ms,omitempty"`
+	// ReadTimeoutMS is the maximum time the listeners allow for reading an entire request
+	ReadTimeoutMS int `yaml:"read_timeout_ms,omitempty"`
+	// WriteTimeoutMS is the maximum time the listeners allow for writing a response.
+	// 0 disables the timeout, so that long streaming responses are not interrupted
+	WriteTimeoutMS int `yaml:"write_timeout_ms,omitempty"`
+	// IdleTimeoutMS is the maximum time the listeners keep an idle keep-alive connection open
+	IdleTimeoutMS int `yaml:"idle_timeout_ms,omitempty"`
+	// TLSCertificates is a list of certificates served by the tls listener, in addition
+	// to any configured on backends. The certificate is chosen by matching the client's
+	// SNI server name, with the first certificate in the list used when none match
+	TLSCertificates []*TLSCertificate `yaml:"tls_certificates,omitempty"`
+	// ClientCAPath specifies the path of a file containing the certificate authorities
+	// used to verify client certificates presented to the tls listener
+	ClientCAPath string `yaml:"client_ca_path,omitempty"`
+	// RequireClientCert indicates the tls listener rejects connections that do not present
+	// a client certificate signed by an authority in ClientCAPath (mutual TLS)
+	RequireClientCert bool `yaml:"require_client_cert,omitempty"`
 
 	// ServeTLS indicates whether to listen and serve on the TLS port, meaning
-	// at least one backend options has a valid certificate and key file configured.
+	// at least one frontend or backend certificate and key file is configured.
 	ServeTLS bool `yaml:"-"`
 }
 
+// TLSCertificate is a certificate and key pair served by the frontend tls listener
+type TLSCertificate struct {
+	// FullChainCertPath specifies the path of the file containing the concatenated
+	// server certificate and the intermediate certificates
+	FullChainCertPath string `yaml:"full_chain_cert_path,omitempty"`
+	// PrivateKeyPath specifies the path of the private key file
+	PrivateKeyPath string `yaml:"private_key_path,omitempty"`
+}
+
 // New returns a new Frontend Options with default values
 func New() *Options {
 	return &Options{
-		ListenPort:       DefaultProxyListenPort,
-		ListenAddress:    DefaultProxyListenAddress,
-		TLSListenPort:    DefaultTLSProxyListenPort,
-		TLSListenAddress: DefaultTLSProxyListenAddress,
+		ListenPort:          DefaultProxyListenPort,
+		ListenAddress:       DefaultProxyListenAddress,
+		TLSListenPort:       DefaultTLSProxyListenPort,
+		TLSListenAddress:    DefaultTLSProxyListenAddress,
+		ReadHeaderTimeoutMS: DefaultReadHeaderTimeoutMS,
+		ReadTimeoutMS:       DefaultReadTimeoutMS,
+		WriteTimeoutMS:      DefaultWriteTimeoutMS,
+		IdleTimeoutMS:       DefaultIdleTimeoutMS,
 	}
 }
 
 // Equal returns true if the FrontendConfigs are identical in value.
 func (o *Options) Equal(o2 *Options) bool {
-	return *o == *o2
+	return o.TLSCertificatesEqual(o2) &&
+		o.ListenAddress == o2.ListenAddress &&
+		o.ListenPort == o2.ListenPort &&
+		o.TLSListenAddress == o2.TLSListenAddress &&
+		o.TLSListenPort == o2.TLSListenPort &&
+		o.ConnectionsLimit == o2.ConnectionsLimit &&
+		o.EnableH2C == o2.EnableH2C &&
+		o.TimeoutsEqual(o2) &&
+		o.ClientCAPath == o2.ClientCAPath &&
+		o.RequireClientCert == o2.RequireClientCert &&
+		o.ServeTLS == o2.ServeTLS
+}
+
+// TimeoutsEqual returns true if the Options' listener timeouts are identical in value
+func (o *Options) TimeoutsEqual(o2 *Options) bool {
+	return o.ReadHeaderTimeoutMS == o2.ReadHeaderTimeoutMS &&
+		o.ReadTimeoutMS == o2.ReadTimeoutMS &&
+		o.WriteTimeoutMS == o2.WriteTimeoutMS &&
+		o.IdleTimeoutMS == o2.IdleTimeoutMS
+}
+
+// ValidateTimeouts returns an error if any of the listener timeouts are negative
+func (o *Options) ValidateTimeouts() error {
+	for name, v := range map[string]int{
+		"read_header_timeout_ms": o.ReadHeaderTimeoutMS,
+		"read_timeout_ms":        o.ReadTimeoutMS,
+		"write_timeout_ms":       o.WriteTimeoutMS,
+		"idle_timeout_ms":        o.IdleTimeoutMS,
+	} {
+		if v < 0 {
+			return fmt.Errorf("%w: %s must be >= 0, got %d", ErrInvalidTimeout, name, v)
+		}
+	}
+	return nil
+}
+
+// TLSCertificatesEqual returns true if the Options' TLSCertificates are identical in value
+func (o *Options) TLSCertificatesEqual(o2 *Options) bool {
+	if len(o.TLSCertificates) != len(o2.TLSCertificates) {
+		return false
+	}
+	for i, c := range o.TLSCertificates {
+		if *c != *o2.TLSCertificates[i] {
+			return false
+		}
+	}
+	return true
 }
 
 // Clone returns a clone of the Options
 func (o *Options) Clone() *Options {
+	var tc []*TLSCertificate
+	if o.TLSCertificates != nil {
+		tc = make([]*TLSCertificate, len(o.TLSCertificates))
+		for i, c := range o.TLSCertificates {
+			c2 := *c
+			tc[i] = &c2
+		}
+	}
 	return &Options{
-		ListenAddress:    o.ListenAddress,
-		ListenPort:       o.ListenPort,
-		TLSListenAddress: o.TLSListenAddress,
-		TLSListenPort:    o.TLSListenPort,
-		ConnectionsLimit: o.ConnectionsLimit,
-		ServeTLS:         o.ServeTLS,
+		ListenAddress:       o.ListenAddress,
+		ListenPort:          o.ListenPort,
+		TLSListenAddress:    o.TLSListenAddress,
+		TLSListenPort:       o.TLSListenPort,
+		ConnectionsLimit:    o.ConnectionsLimit,
+		EnableH2C:           o.EnableH2C,
+		ReadHeaderTimeoutMS: o.ReadHeaderTimeoutMS,
+		ReadTimeoutMS:       o.ReadTimeoutMS,
+		WriteTimeoutMS:      o.WriteTimeoutMS,
+		IdleTimeoutMS:       o.IdleTimeoutMS,
+		TLSCertificates:     tc,
+		ClientCAPath:        o.ClientCAPath,
+		RequireClientCert:   o.RequireClientCert,
+		ServeTLS:            o.ServeTLS,
+	}
+}
+
+// LoadTLSCertificates loads the configured frontend TLS certificates, in order
+func (o *Options) LoadTLSCertificates() ([]tls.Certificate, error) {
+	certs := make([]tls.Certificate, 0, len(o.TLSCertificates))
+	for i, c := range o.TLSCertificates {
+		if c == nil || c.FullChainCertPath == "" || c.PrivateKeyPath == "" {
+			return nil, fmt.Errorf("%w %d: full_chain_cert_path and private_key_path are required",
+				ErrInvalidTLSCertificate, i)
+		}
+		cert, err := tls.LoadX509KeyPair(c.FullChainCertPath, c.PrivateKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("%w %d (%s): %v", ErrInvalidTLSCertificate, i,
+				c.FullChainCertPath, err)
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
+
+// ClientAuthType returns the tls listener's policy for client certificates. Client
+// certificates are required when RequireClientCert is set, and otherwise verified
+// only if presented when a ClientCAPath is configured
+func (o *Options) ClientAuthType() tls.ClientAuthType {
+	switch {
+	case o.RequireClientCert:
+		return tls.RequireAndVerifyClientCert
+	case o.ClientCAPath != "":
+		return tls.VerifyClientCertIfGiven
+	}
+	return tls.NoClientCert
+}
+
+// LoadClientCAs loads the certificate authorities used to verify client certificates.
+// It returns a nil pool when client certificates are not verified
+func (o *Options) LoadClientCAs() (*x509.CertPool, error) {
+	if o.ClientCAPath == "" {
+		if o.RequireClientCert {
+			return nil, fmt.Errorf("%w: client_ca_path is required when require_client_cert is true",
+				ErrInvalidClientCA)
+		}
+		return nil, nil
+	}
+	b, err := os.ReadFile(o.ClientCAPath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidClientCA, err)
+	}
+	pool := x509.NewCertPool()
+	if ok := pool.AppendCertsFromPEM(b); !ok {
+		return nil, fmt.Errorf("%w: unable to append CA certs from file %s",
+			ErrInvalidClientCA, o.ClientCAPath)
 	}
+	return pool, nil
 }
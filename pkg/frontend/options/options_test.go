@@ -16,7 +16,10 @@
 
 package options
 
-import "testing"
+import (
+	"errors"
+	"testing"
+)
 
 func TestFrontendOptions(t *testing.T) {
 
@@ -33,4 +36,55 @@ func TestFrontendOptions(t *testing.T) {
 	if !b {
 		t.Errorf("expected %t got %t", true, b)
 	}
+
+	f1.TLSCertificates = []*TLSCertificate{{FullChainCertPath: "cert.pem", PrivateKeyPath: "key.pem"}}
+	f2 = f1.Clone()
+	if !f1.Equal(f2) {
+		t.Errorf("expected %t got %t", true, false)
+	}
+	f2.TLSCertificates[0].PrivateKeyPath = "other.pem"
+	if f1.Equal(f2) {
+		t.Errorf("expected %t got %t", false, true)
+	}
+}
+
+func TestLoadTLSCertificates(t *testing.T) {
+
+	o := New()
+	certs, err := o.LoadTLSCertificates()
+	if err != nil || len(certs) != 0 {
+		t.Errorf("expected no certificates, got %d (%v)", len(certs), err)
+	}
+
+	o.TLSCertificates = []*TLSCertificate{{FullChainCertPath: "cert.pem"}}
+	_, err = o.LoadTLSCertificates()
+	if !errors.Is(err, ErrInvalidTLSCertificate) {
+		t.Errorf("expected %v got %v", ErrInvalidTLSCertificate, err)
+	}
+
+	o.TLSCertificates[0].PrivateKeyPath = "key.pem"
+	_, err = o.LoadTLSCertificates()
+	if !errors.Is(err, ErrInvalidTLSCertificate) {
+		t.Errorf("expected %v got %v", ErrInvalidTLSCertificate, err)
+	}
+}
+
+func TestValidateTimeouts(t *testing.T) {
+
+	o := New()
+	if err := o.ValidateTimeouts(); err != nil {
+		t.Error(err)
+	}
+	if o.WriteTimeoutMS != 0 {
+		t.Errorf("expected %d got %d", 0, o.WriteTimeoutMS)
+	}
+
+	o2 := o.Clone()
+	o2.ReadTimeoutMS = -1
+	if o.Equal(o2) {
+		t.Errorf("expected %t got %t", false, true)
+	}
+	if err := o2.ValidateTimeouts(); !errors.Is(err, ErrInvalidTimeout) {
+		t.Errorf("expected %v got %v", ErrInvalidTimeout, err)
+	}
 }
@@ -16,15 +16,21 @@
 package locks
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
+// ErrLockTimeout is returned when a Named Lock is not acquired within the allowed time
+var ErrLockTimeout = errors.New("timed out waiting for lock")
+
 // NamedLocker provides a locker for handling Named Locks
 type NamedLocker interface {
 	Acquire(string) (NamedLock, error)
 	RAcquire(string) (NamedLock, error)
+	RAcquireWithTimeout(string, time.Duration) (NamedLock, error)
 }
 
 type namedLocker struct {
@@ -105,6 +111,21 @@ func (nl *namedLock) Upgrade() bool {
 }
 
 func (lk *namedLocker) acquire(lockName string, isWrite bool) (NamedLock, error) {
+	nl, err := lk.enqueue(lockName)
+	if err != nil {
+		return nil, err
+	}
+	if isWrite {
+		nl.Lock()
+	} else {
+		nl.rLock()
+	}
+	return nl, nil
+}
+
+// enqueue returns the Named Lock for the provided name, creating it if necessary,
+// and counts the caller in its queue
+func (lk *namedLocker) enqueue(lockName string) (*namedLock, error) {
 	if lockName == "" {
 		return nil, errInvalidLockName(lockName)
 	}
@@ -123,18 +144,16 @@ func (lk *namedLocker) acquire(lockName string, isWrite bool) (NamedLock, error)
 	}
 	nl.queueSize.Add(1)
 	mapUnlockFunc()
-
-	if isWrite {
-		nl.Lock()
-	} else {
-		nl.RLock()
-		// if the Named Lock was previously a Write lock but is now a Read lock again,
-		// meaning RAcquires queued up while it was write-locked, this goes back to false
-		nl.subsequentWriter = false
-	}
 	return nl, nil
 }
 
+func (nl *namedLock) rLock() {
+	nl.RLock()
+	// if the Named Lock was previously a Write lock but is now a Read lock again,
+	// meaning RAcquires queued up while it was write-locked, this goes back to false
+	nl.subsequentWriter = false
+}
+
 // Acquire locks the named lock for writing, and blocks until the wlock is acquired
 func (lk *namedLocker) Acquire(lockName string) (NamedLock, error) {
 	return lk.acquire(lockName, true)
@@ -145,6 +164,39 @@ func (lk *namedLocker) RAcquire(lockName string) (NamedLock, error) {
 	return lk.acquire(lockName, false)
 }
 
+// RAcquireWithTimeout locks the named lock for reading, and blocks until the rlock is
+// acquired or the timeout elapses, in which case ErrLockTimeout is returned. A timeout
+// <= 0 waits indefinitely, like RAcquire.
+func (lk *namedLocker) RAcquireWithTimeout(lockName string, timeout time.Duration) (NamedLock, error) {
+	if timeout <= 0 {
+		return lk.acquire(lockName, false)
+	}
+	nl, err := lk.enqueue(lockName)
+	if err != nil {
+		return nil, err
+	}
+	acquired := make(chan struct{})
+	abandoned := make(chan struct{})
+	go func() {
+		nl.rLock()
+		select {
+		case acquired <- struct{}{}:
+		case <-abandoned:
+			// the caller stopped waiting, so give the lock right back
+			nl.RRelease()
+		}
+	}()
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case <-acquired:
+		return nl, nil
+	case <-t.C:
+		close(abandoned)
+		return nil, ErrLockTimeout
+	}
+}
+
 func errInvalidLockName(name string) error {
 	return fmt.Errorf("invalid lock name: %s", name)
 }
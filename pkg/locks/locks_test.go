@@ -276,3 +276,42 @@ func TestUpgrade(t *testing.T) {
 	}
 
 }
+
+func TestRAcquireWithTimeout(t *testing.T) {
+
+	lk := NewNamedLocker()
+
+	// with no writer, the read lock is acquired immediately
+	nl, err := lk.RAcquireWithTimeout(testKey, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nl.RRelease()
+
+	wl, _ := lk.Acquire(testKey)
+	_, err = lk.RAcquireWithTimeout(testKey, 10*time.Millisecond)
+	if err != ErrLockTimeout {
+		t.Errorf("expected %v got %v", ErrLockTimeout, err)
+	}
+
+	// a timeout of 0 waits until the writer releases
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		wl.Release()
+	}()
+	nl, err = lk.RAcquireWithTimeout(testKey, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nl.RRelease()
+
+	// the abandoned read lock must be released, so a writer can still acquire
+	time.Sleep(10 * time.Millisecond)
+	wl, _ = lk.Acquire(testKey)
+	wl.Release()
+
+	_, err = lk.RAcquireWithTimeout("", time.Millisecond)
+	if err == nil {
+		t.Error("expected error for invalid lock name")
+	}
+}
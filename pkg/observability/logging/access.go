@@ -0,0 +1,62 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logging
+
+import (
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// accessSampler decides which requests have their access logs written. Errors and
+// slow requests are always written, while other requests are sampled at rate
+type accessSampler struct {
+	rate          float64
+	slowThreshold time.Duration
+	random        func() float64
+}
+
+// newAccessSampler returns an accessSampler for the provided sample rate (0 to 1) and
+// slow request threshold, or nil if every request's access log would be written
+func newAccessSampler(rate float64, slowThreshold time.Duration) *accessSampler {
+	if rate <= 0 || rate >= 1 {
+		return nil
+	}
+	return &accessSampler{rate: rate, slowThreshold: slowThreshold, random: rand.Float64}
+}
+
+// sample returns true if the access log for a request with the provided response code
+// and duration should be written
+func (s *accessSampler) sample(code int, d time.Duration) bool {
+	if s == nil || code >= http.StatusBadRequest || code == 0 ||
+		(s.slowThreshold > 0 && d >= s.slowThreshold) {
+		return true
+	}
+	return s.random() < s.rate
+}
+
+// SampleAccessLog returns true if the logger should write the access log for a request
+// with the provided response code and duration, per its access log sampling options
+func SampleAccessLog(logger interface{}, code int, d time.Duration) bool {
+	switch l := logger.(type) {
+	case *Logger:
+		return l == nil || l.sampler.sample(code, d)
+	case *SyncLogger:
+		return l == nil || l.Logger == nil || l.sampler.sample(code, d)
+	}
+	return true
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logging
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/cmd/trickster/config"
+	"github.com/trickstercache/trickster/v2/pkg/observability/logging/options"
+)
+
+func TestSampleAccessLog(t *testing.T) {
+
+	conf := config.NewConfig()
+	conf.Main = &config.MainConfig{InstanceID: 0}
+	conf.Logging = &options.Options{LogLevel: "debug", AccessLogSampleRate: 0.25,
+		AccessLogSlowThresholdMS: 500}
+	logger := New(conf)
+	defer logger.Close()
+
+	const n = 20000
+	var ok, errs, slow int
+	for i := 0; i < n; i++ {
+		if SampleAccessLog(logger, http.StatusOK, 10*time.Millisecond) {
+			ok++
+		}
+		if SampleAccessLog(logger, http.StatusBadGateway, 10*time.Millisecond) {
+			errs++
+		}
+		if SampleAccessLog(&SyncLogger{Logger: logger}, http.StatusOK, time.Second) {
+			slow++
+		}
+	}
+
+	// roughly a quarter of successful requests are logged
+	if f := float64(ok) / n; f < 0.22 || f > 0.28 {
+		t.Errorf("expected a sampled fraction near %f got %f", 0.25, f)
+	}
+	if errs != n {
+		t.Errorf("expected all %d errors to be logged got %d", n, errs)
+	}
+	if slow != n {
+		t.Errorf("expected all %d slow requests to be logged got %d", n, slow)
+	}
+}
+
+func TestSampleAccessLogUnsampled(t *testing.T) {
+
+	tests := []interface{}{
+		DefaultLogger(),
+		&SyncLogger{Logger: DefaultLogger()},
+		(*Logger)(nil),
+		"not a logger",
+	}
+	for _, logger := range tests {
+		if !SampleAccessLog(logger, http.StatusOK, 0) {
+			t.Errorf("expected access log to be written for %T", logger)
+		}
+	}
+
+	if s := newAccessSampler(1, 0); s != nil {
+		t.Error("expected nil sampler for a sample rate of 1")
+	}
+	s := newAccessSampler(0.5, 0)
+	s.random = func() float64 { return 0.5 }
+	if s.sample(http.StatusOK, time.Hour) {
+		t.Error("expected request to not be sampled without a slow threshold")
+	}
+	s.random = func() float64 { return 0.49 }
+	if !s.sample(http.StatusOK, 0) {
+		t.Error("expected request to be sampled")
+	}
+}
@@ -25,8 +25,10 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/trickstercache/trickster/v2/cmd/trickster/config"
+	"github.com/trickstercache/trickster/v2/pkg/observability/logging/options"
 
 	gkl "github.com/go-kit/log"
 	"github.com/go-kit/log/level"
@@ -40,6 +42,7 @@ type Logger struct {
 	logger     gkl.Logger // the logger after leveling, which is used by importing packages
 	closer     io.Closer
 	level      string
+	sampler    *accessSampler
 
 	onceMutex      *sync.Mutex
 	mtx            sync.Mutex
@@ -68,6 +71,20 @@ func Debug(logger interface{}, event string, detail Pairs) {
 	}
 }
 
+// IsDebugEnabled returns true if the logger will emit debug-level events, so that
+// callers can skip building the details of events that would be discarded
+func IsDebugEnabled(logger interface{}) bool {
+	switch l := logger.(type) {
+	case *Logger:
+		return l != nil && l.IsDebugEnabled()
+	case *SyncLogger:
+		return l != nil && l.Logger != nil && l.IsDebugEnabled()
+	case gkl.Logger:
+		return true
+	}
+	return false
+}
+
 func Info(logger interface{}, event string, detail Pairs) {
 	if logger == nil {
 		return
@@ -211,11 +228,7 @@ func noopLogger() *Logger {
 
 func StreamLogger(w io.Writer, logLevel string) *Logger {
 	l := noopLogger()
-	l.baseLogger = gkl.NewLogfmtLogger(gkl.NewSyncWriter(w))
-	l.baseLogger = gkl.With(l.baseLogger,
-		"time", gkl.DefaultTimestampUTC,
-		"app", "trickster",
-	)
+	l.baseLogger = newBaseLogger(w, options.LogFormatText)
 	l.SetLogLevel(logLevel)
 	return l
 }
@@ -224,12 +237,7 @@ func StreamLogger(w io.Writer, logLevel string) *Logger {
 func ConsoleLogger(logLevel string) *Logger {
 
 	l := noopLogger()
-	wr := os.Stdout
-	l.baseLogger = gkl.NewLogfmtLogger(gkl.NewSyncWriter(wr))
-	l.baseLogger = gkl.With(l.baseLogger,
-		"time", gkl.DefaultTimestampUTC,
-		"app", "trickster",
-	)
+	l.baseLogger = newBaseLogger(os.Stdout, options.LogFormatText)
 	l.SetLogLevel(logLevel)
 	return l
 }
@@ -281,13 +289,10 @@ func New(conf *config.Config) *Logger {
 		}
 	}
 
-	l.baseLogger = gkl.NewLogfmtLogger(gkl.NewSyncWriter(wr))
-	l.baseLogger = gkl.With(l.baseLogger,
-		"time", gkl.DefaultTimestampUTC,
-		"app", "trickster",
-	)
-
+	l.baseLogger = newBaseLogger(wr, conf.Logging.LogFormat)
 	l.SetLogLevel(conf.Logging.LogLevel)
+	l.sampler = newAccessSampler(conf.Logging.AccessLogSampleRate,
+		time.Duration(conf.Logging.AccessLogSlowThresholdMS)*time.Millisecond)
 
 	if c, ok := wr.(io.Closer); ok && c != nil {
 		l.closer = c
@@ -296,6 +301,21 @@ func New(conf *config.Config) *Logger {
 	return l
 }
 
+// newBaseLogger returns a timestamped logger writing to w in the provided format,
+// which is logfmt unless the format is json
+func newBaseLogger(w io.Writer, format string) gkl.Logger {
+	var l gkl.Logger
+	if strings.ToLower(format) == options.LogFormatJSON {
+		l = gkl.NewJSONLogger(gkl.NewSyncWriter(w))
+	} else {
+		l = gkl.NewLogfmtLogger(gkl.NewSyncWriter(w))
+	}
+	return gkl.With(l,
+		"time", gkl.DefaultTimestampUTC,
+		"app", "trickster",
+	)
+}
+
 // Pairs represents a key=value pair that helps to describe a log event
 type Pairs map[string]interface{}
 
@@ -371,6 +391,11 @@ func (tl *Logger) ErrorOnce(key string, event string, detail Pairs) bool {
 	return false
 }
 
+// IsDebugEnabled returns true if the Logger's level permits debug-level events
+func (tl *Logger) IsDebugEnabled() bool {
+	return tl.level == "debug" || tl.level == "trace"
+}
+
 // Debug sends an "DEBUG" event to the Logger
 func (tl *Logger) Debug(event string, detail Pairs) {
 	tl.mtx.Lock()
@@ -17,6 +17,8 @@
 package logging
 
 import (
+	"encoding/json"
+	"io"
 	"log"
 	"net/http/httptest"
 	"os"
@@ -77,6 +79,72 @@ func TestNewLogger_LogFile(t *testing.T) {
 	logger.Close()
 }
 
+func TestNewLoggerJSON_LogFile(t *testing.T) {
+	td := t.TempDir()
+	fileName := td + "/out.log"
+	conf := config.NewConfig()
+	conf.Main = &config.MainConfig{InstanceID: 0}
+	conf.Logging = &options.Options{LogFile: fileName, LogLevel: "info",
+		LogFormat: options.LogFormatJSON}
+	logger := New(conf)
+	logger.Warn("test entry", Pairs{"testKey": "testVal", "count": 3})
+	logger.Close()
+
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := make(map[string]interface{})
+	if err = json.Unmarshal(b, &entry); err != nil {
+		t.Fatalf("expected a valid JSON log line got %s: %v", string(b), err)
+	}
+	expected := map[string]interface{}{"level": "warn", "event": "test entry",
+		"app": "trickster", "testKey": "testVal", "count": float64(3)}
+	for k, v := range expected {
+		if entry[k] != v {
+			t.Errorf("expected %v for %s got %v", v, k, entry[k])
+		}
+	}
+	if ts, ok := entry["time"].(string); !ok {
+		t.Errorf("expected a time string got %v", entry["time"])
+	} else if _, err = time.Parse(time.RFC3339Nano, ts); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	o := options.New()
+	o.LogFormat = ""
+	if err := o.Validate(); err != nil {
+		t.Error(err)
+	}
+	if o.LogFormat != options.DefaultLogFormat {
+		t.Errorf("expected %s got %s", options.DefaultLogFormat, o.LogFormat)
+	}
+	o.LogFormat = "JSON"
+	if err := o.Validate(); err != nil {
+		t.Error(err)
+	}
+	if o.LogFormat != options.LogFormatJSON {
+		t.Errorf("expected %s got %s", options.LogFormatJSON, o.LogFormat)
+	}
+	o.LogFormat = "xml"
+	if err := o.Validate(); err != options.ErrInvalidLogFormat {
+		t.Errorf("expected %v got %v", options.ErrInvalidLogFormat, err)
+	}
+	o.LogFormat = options.LogFormatText
+
+	o.AccessLogSampleRate = 0
+	if err := o.Validate(); err != options.ErrInvalidAccessLogSampleRate {
+		t.Errorf("expected %v got %v", options.ErrInvalidAccessLogSampleRate, err)
+	}
+	o.AccessLogSampleRate = 0.1
+	o.AccessLogSlowThresholdMS = -1
+	if err := o.Validate(); err != options.ErrInvalidAccessLogSlowThreshold {
+		t.Errorf("expected %v got %v", options.ErrInvalidAccessLogSlowThreshold, err)
+	}
+}
+
 func TestNewLoggerDebug_LogFile(t *testing.T) {
 	fileName := t.TempDir() + "/out.debug.log"
 	// it should create a logger that outputs to a log file ("out.test.log")
@@ -348,3 +416,27 @@ func TestStreamLogger(t *testing.T) {
 	}
 
 }
+
+func TestIsDebugEnabled(t *testing.T) {
+
+	tests := []struct {
+		logger   interface{}
+		expected bool
+	}{
+		{nil, false},
+		{StreamLogger(io.Discard, "debug"), true},
+		{StreamLogger(io.Discard, "TRACE"), true},
+		{StreamLogger(io.Discard, "info"), false},
+		{&SyncLogger{Logger: StreamLogger(io.Discard, "debug")}, true},
+		{&SyncLogger{Logger: StreamLogger(io.Discard, "warn")}, false},
+		{gkl.NewNopLogger(), true},
+		{"not a logger", false},
+	}
+
+	for i, test := range tests {
+		if v := IsDebugEnabled(test.logger); v != test.expected {
+			t.Errorf("test %d: expected %t got %t", i, test.expected, v)
+		}
+	}
+
+}
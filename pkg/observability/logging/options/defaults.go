@@ -22,4 +22,9 @@ const (
 	DefaultLogFile = ""
 	// DefaultLogLevel is the default level for logging
 	DefaultLogLevel = "INFO"
+	// DefaultLogFormat is the default format of log lines
+	DefaultLogFormat = LogFormatText
+	// DefaultAccessLogSampleRate is the default fraction of successful requests whose
+	// access logs are written
+	DefaultAccessLogSampleRate = 1
 )
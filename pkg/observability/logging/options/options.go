@@ -16,20 +16,72 @@
 
 package options
 
+import (
+	"errors"
+	"strings"
+)
+
+const (
+	// LogFormatText writes each log line as human-readable key=value pairs
+	LogFormatText = "text"
+	// LogFormatJSON writes each log line as a JSON object
+	LogFormatJSON = "json"
+)
+
+// ErrInvalidLogFormat is an error for an unsupported log_format
+var ErrInvalidLogFormat = errors.New("invalid log_format, must be text or json")
+
+// ErrInvalidAccessLogSampleRate is an error for an access_log_sample_rate outside of (0, 1]
+var ErrInvalidAccessLogSampleRate = errors.New(
+	"invalid access_log_sample_rate, must be greater than 0 and no more than 1")
+
+// ErrInvalidAccessLogSlowThreshold is an error for a negative access_log_slow_threshold_ms
+var ErrInvalidAccessLogSlowThreshold = errors.New(
+	"invalid access_log_slow_threshold_ms, must not be negative")
+
 // Options is a collection of Logging options
 type Options struct {
 	// LogFile provides the filepath to the instances's logfile. Set as empty string to Log to Console
 	LogFile string `yaml:"log_file,omitempty"`
 	// LogLevel provides the most granular level (e.g., DEBUG, INFO, ERROR) to log
 	LogLevel string `yaml:"log_level,omitempty"`
+	// LogFormat provides the format of each log line (text or json)
+	LogFormat string `yaml:"log_format,omitempty"`
+	// AccessLogSampleRate is the fraction (0 to 1) of successful requests whose access logs
+	// are written. Access logs for errors and slow requests are always written
+	AccessLogSampleRate float64 `yaml:"access_log_sample_rate,omitempty"`
+	// AccessLogSlowThresholdMS is the request duration at or above which access logs are
+	// always written, regardless of the AccessLogSampleRate. 0 disables the threshold
+	AccessLogSlowThresholdMS int `yaml:"access_log_slow_threshold_ms,omitempty"`
 }
 
 // New returns a new Options with default values
 func New() *Options {
-	return &Options{LogLevel: DefaultLogLevel, LogFile: DefaultLogFile}
+	return &Options{LogLevel: DefaultLogLevel, LogFile: DefaultLogFile,
+		LogFormat: DefaultLogFormat, AccessLogSampleRate: DefaultAccessLogSampleRate}
 }
 
 // Clone returns a clone of the Options
 func (o *Options) Clone() *Options {
-	return &Options{LogLevel: o.LogLevel, LogFile: o.LogFile}
+	return &Options{LogLevel: o.LogLevel, LogFile: o.LogFile, LogFormat: o.LogFormat,
+		AccessLogSampleRate: o.AccessLogSampleRate, AccessLogSlowThresholdMS: o.AccessLogSlowThresholdMS}
+}
+
+// Validate normalizes the LogFormat, returning an error if it or the access log
+// sampling options are not supported
+func (o *Options) Validate() error {
+	switch o.LogFormat = strings.ToLower(o.LogFormat); o.LogFormat {
+	case LogFormatText, LogFormatJSON:
+	case "":
+		o.LogFormat = DefaultLogFormat
+	default:
+		return ErrInvalidLogFormat
+	}
+	if o.AccessLogSampleRate <= 0 || o.AccessLogSampleRate > 1 {
+		return ErrInvalidAccessLogSampleRate
+	}
+	if o.AccessLogSlowThresholdMS < 0 {
+		return ErrInvalidAccessLogSlowThreshold
+	}
+	return nil
 }
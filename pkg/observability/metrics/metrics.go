@@ -44,6 +44,9 @@ var BuildInfo *prometheus.GaugeVec
 // LastReloadSuccessful gauge will be set to 1 if Trickster's last config reload succeeded else 0
 var LastReloadSuccessful prometheus.Gauge
 
+// ReloadFailures is a counter of the total number of failed configuration loads
+var ReloadFailures prometheus.Counter
+
 // LastReloadSuccessfulTimestamp gauge is the epoch time of the most recent successful config load
 var LastReloadSuccessfulTimestamp prometheus.Gauge
 
@@ -65,6 +68,37 @@ var ProxyRequestElements *prometheus.CounterVec
 // ProxyRequestDuration is a Histogram of time required in seconds to proxy a given Prometheus query
 var ProxyRequestDuration *prometheus.HistogramVec
 
+// ProxyOriginRequestDuration is a Histogram of time required in seconds for a backend's origin
+// to respond to an upstream request. Requests served from cache are not observed
+var ProxyOriginRequestDuration *prometheus.HistogramVec
+
+// ProxyCircuitBreakerState is a Gauge of the current circuit breaker state for each backend
+// (0 = closed, 1 = open, 2 = half-open)
+var ProxyCircuitBreakerState *prometheus.GaugeVec
+
+// ProxyCircuitBreakerTransitions is a Counter of circuit breaker state transitions for each backend
+var ProxyCircuitBreakerTransitions *prometheus.CounterVec
+
+// ProxyUpstreamInFlight is a Gauge of the current number of in-flight upstream requests
+// for each backend that limits its concurrent upstream requests
+var ProxyUpstreamInFlight *prometheus.GaugeVec
+
+// ProxyShadowRequestDuration is a Histogram of time required in seconds for shadow origins
+// to respond to mirrored requests
+var ProxyShadowRequestDuration *prometheus.HistogramVec
+
+// ProxyShadowSizeDivergence is a Histogram of the absolute difference in bytes between
+// the primary and shadow origins' response bodies for mirrored requests
+var ProxyShadowSizeDivergence *prometheus.HistogramVec
+
+// ProxyShadowResults is a Counter of mirrored request outcomes, by whether the shadow
+// origin's response matched the primary origin's
+var ProxyShadowResults *prometheus.CounterVec
+
+// ProxyCacheEventNotifications is a Counter of cache event notifications, by whether
+// they were sent to the webhook, failed, or were dropped because the buffer was full
+var ProxyCacheEventNotifications *prometheus.CounterVec
+
 // CacheObjectOperations is a Counter of operations (in # of objects) performed on a Trickster cache
 var CacheObjectOperations *prometheus.CounterVec
 
@@ -74,6 +108,12 @@ var CacheByteOperations *prometheus.CounterVec
 // CacheEvents is a Counter of events performed on a Trickster cache
 var CacheEvents *prometheus.CounterVec
 
+// CacheLookups is a Counter of cache lookup outcomes, by backend, cache and lookup status
+var CacheLookups *prometheus.CounterVec
+
+// CacheCompressionRatio is a Summary of the compressed-to-uncompressed size ratio of objects written to a cache
+var CacheCompressionRatio *prometheus.SummaryVec
+
 // CacheObjects is a Gauge representing the number of objects in a Trickster cache
 var CacheObjects *prometheus.GaugeVec
 
@@ -135,6 +175,15 @@ func init() {
 		},
 	)
 
+	ReloadFailures = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: configSubsystem,
+			Name:      "reload_failures_total",
+			Help:      "Count of configuration load attempts that failed.",
+		},
+	)
+
 	FrontendRequestStatus = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace: metricNamespace,
@@ -196,6 +245,17 @@ func init() {
 		[]string{"backend_name", "provider", "method", "status", "http_status", "path"},
 	)
 
+	ProxyOriginRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "origin_request_duration_seconds",
+			Help:      "Time required in seconds for an origin to respond to an upstream request.",
+			Buckets:   defaultBuckets,
+		},
+		[]string{"backend_name"},
+	)
+
 	ProxyMaxConnections = prometheus.NewGauge(
 		prometheus.GaugeOpts{
 			Namespace: metricNamespace,
@@ -249,6 +309,78 @@ func init() {
 		},
 	)
 
+	ProxyCircuitBreakerState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "circuit_breaker_state",
+			Help:      "Current circuit breaker state for a backend (0 = closed, 1 = open, 2 = half-open).",
+		},
+		[]string{"backend_name"},
+	)
+
+	ProxyCircuitBreakerTransitions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "circuit_breaker_transitions_total",
+			Help:      "Count of circuit breaker state transitions for a backend, by the state transitioned to.",
+		},
+		[]string{"backend_name", "state"},
+	)
+
+	ProxyUpstreamInFlight = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "upstream_requests_in_flight",
+			Help:      "Current number of in-flight upstream requests for a backend with a concurrency limit.",
+		},
+		[]string{"backend_name"},
+	)
+
+	ProxyShadowRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "shadow_request_duration_seconds",
+			Help:      "Time required in seconds for a shadow origin to respond to a mirrored request.",
+			Buckets:   defaultBuckets,
+		},
+		[]string{"backend_name"},
+	)
+
+	ProxyShadowSizeDivergence = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "shadow_size_divergence_bytes",
+			Help:      "Absolute difference in bytes between the primary and shadow origin response bodies.",
+			Buckets:   prometheus.ExponentialBuckets(1, 10, 8),
+		},
+		[]string{"backend_name"},
+	)
+
+	ProxyShadowResults = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "shadow_results_total",
+			Help:      "Count of mirrored requests, by whether the shadow origin's response matched (match, mismatch, error).",
+		},
+		[]string{"backend_name", "result"},
+	)
+
+	ProxyCacheEventNotifications = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "cache_event_notifications_total",
+			Help:      "Count of cache event notifications, by outcome (sent, failed, dropped).",
+		},
+		[]string{"backend_name", "result"},
+	)
+
 	CacheObjectOperations = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace: metricNamespace,
@@ -279,6 +411,27 @@ func init() {
 		[]string{"cache_name", "provider", "event", "reason"},
 	)
 
+	CacheLookups = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: cacheSubsystem,
+			Name:      "lookups_total",
+			Help:      "Count of Trickster cache lookups by lookup status.",
+		},
+		[]string{"backend_name", "cache_name", "status"},
+	)
+
+	CacheCompressionRatio = prometheus.NewSummaryVec(
+		prometheus.SummaryOpts{
+			Namespace:  metricNamespace,
+			Subsystem:  cacheSubsystem,
+			Name:       "compression_ratio",
+			Help:       "Ratio of compressed to uncompressed size of objects written to a Trickster cache.",
+			Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+		},
+		[]string{"cache_name", "provider", "algorithm"},
+	)
+
 	CacheObjects = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace: metricNamespace,
@@ -326,15 +479,25 @@ func init() {
 	prometheus.MustRegister(ProxyRequestStatus)
 	prometheus.MustRegister(ProxyRequestElements)
 	prometheus.MustRegister(ProxyRequestDuration)
+	prometheus.MustRegister(ProxyOriginRequestDuration)
 	prometheus.MustRegister(ProxyMaxConnections)
 	prometheus.MustRegister(ProxyActiveConnections)
 	prometheus.MustRegister(ProxyConnectionRequested)
 	prometheus.MustRegister(ProxyConnectionAccepted)
 	prometheus.MustRegister(ProxyConnectionClosed)
 	prometheus.MustRegister(ProxyConnectionFailed)
+	prometheus.MustRegister(ProxyCircuitBreakerState)
+	prometheus.MustRegister(ProxyCircuitBreakerTransitions)
+	prometheus.MustRegister(ProxyUpstreamInFlight)
+	prometheus.MustRegister(ProxyShadowRequestDuration)
+	prometheus.MustRegister(ProxyShadowSizeDivergence)
+	prometheus.MustRegister(ProxyShadowResults)
+	prometheus.MustRegister(ProxyCacheEventNotifications)
 	prometheus.MustRegister(CacheObjectOperations)
 	prometheus.MustRegister(CacheByteOperations)
 	prometheus.MustRegister(CacheEvents)
+	prometheus.MustRegister(CacheLookups)
+	prometheus.MustRegister(CacheCompressionRatio)
 	prometheus.MustRegister(CacheObjects)
 	prometheus.MustRegister(CacheBytes)
 	prometheus.MustRegister(CacheMaxObjects)
@@ -342,9 +505,13 @@ func init() {
 	prometheus.MustRegister(BuildInfo)
 	prometheus.MustRegister(LastReloadSuccessful)
 	prometheus.MustRegister(LastReloadSuccessfulTimestamp)
+	prometheus.MustRegister(ReloadFailures)
 }
 
-// Handler returns the http handler for the listener
+// Handler returns the http handler for the listener. The OpenMetrics format is served
+// when requested by the Accept header; otherwise the Prometheus text format is served
 func Handler() http.Handler {
-	return promhttp.Handler()
+	return promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.DefaultGatherer,
+			promhttp.HandlerOpts{EnableOpenMetrics: true}))
 }
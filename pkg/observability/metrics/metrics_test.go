@@ -0,0 +1,66 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		accept      string
+		contentType string
+		eof         bool
+	}{
+		{"", "text/plain", false},
+		{"text/plain;version=0.0.4", "text/plain", false},
+		{"application/openmetrics-text", "application/openmetrics-text", true},
+		{"application/openmetrics-text;version=0.0.1", "application/openmetrics-text", true},
+		{"application/openmetrics-text;version=0.0.1,text/plain;version=0.0.4;q=0.5",
+			"application/openmetrics-text", true},
+	}
+
+	h := Handler()
+	for _, test := range tests {
+		t.Run(test.accept, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "http://0/metrics", nil)
+			if test.accept != "" {
+				r.Header.Set("Accept", test.accept)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			resp := w.Result()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("expected %d got %d", http.StatusOK, resp.StatusCode)
+			}
+			if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, test.contentType) {
+				t.Errorf("expected content type %s got %s", test.contentType, ct)
+			}
+			b, _ := io.ReadAll(resp.Body)
+			if eof := strings.HasSuffix(string(b), "# EOF\n"); eof != test.eof {
+				t.Errorf("expected EOF terminator %t got %t", test.eof, eof)
+			}
+			if !strings.Contains(string(b), "promhttp_metric_handler_requests_total") {
+				t.Error("expected promhttp_metric_handler_requests_total in response")
+			}
+		})
+	}
+}
@@ -87,7 +87,7 @@ func New(options *options.Options) (*tracing.Tracer, error) {
 	}
 
 	tp = sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exporter),
+		sdktrace.WithBatcher(exporter, options.BatchSpanProcessorOptions()...),
 		sdktrace.WithSampler(sampler),
 	)
 
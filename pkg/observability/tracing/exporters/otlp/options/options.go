@@ -0,0 +1,48 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import "github.com/trickstercache/trickster/v2/pkg/util/copiers"
+
+// Options is a collection of OTLP-specific options
+type Options struct {
+	// Insecure sends spans to the collector without TLS, for local collectors
+	Insecure bool `yaml:"insecure,omitempty"`
+	// InsecureSkipVerify bypasses verification of the collector's certificate
+	InsecureSkipVerify bool `yaml:"insecure_skip_verify,omitempty"`
+	// CertificateAuthorityPaths provides a list of custom Certificate Authorities for the
+	// collector, which are considered in addition to any system CA's
+	CertificateAuthorityPaths []string `yaml:"certificate_authority_paths,omitempty"`
+	// ClientCertPath provides the path to the Client Certificate when using Mutual Authorization
+	ClientCertPath string `yaml:"client_cert_path,omitempty"`
+	// ClientKeyPath provides the path to the Client Key when using Mutual Authorization
+	ClientKeyPath string `yaml:"client_key_path,omitempty"`
+	// Headers provides any headers to include in each export request (e.g., auth tokens)
+	Headers map[string]string `yaml:"headers,omitempty"`
+}
+
+// Clone returns a perfect copy of the subject *Options
+func (o *Options) Clone() *Options {
+	return &Options{
+		Insecure:                  o.Insecure,
+		InsecureSkipVerify:        o.InsecureSkipVerify,
+		CertificateAuthorityPaths: copiers.CopyStrings(o.CertificateAuthorityPaths),
+		ClientCertPath:            o.ClientCertPath,
+		ClientKeyPath:             o.ClientKeyPath,
+		Headers:                   copiers.CopyStringLookup(o.Headers),
+	}
+}
@@ -0,0 +1,184 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package otlp provides an OpenTelemetry Protocol (OTLP) Tracer
+package otlp
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/trickstercache/trickster/v2/pkg/observability/tracing"
+	errs "github.com/trickstercache/trickster/v2/pkg/observability/tracing/errors"
+	"github.com/trickstercache/trickster/v2/pkg/observability/tracing/exporters/otlp/options"
+	topts "github.com/trickstercache/trickster/v2/pkg/observability/tracing/options"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc/credentials"
+)
+
+// New returns a new OTLP Tracer based on the provided options
+func New(opts *topts.Options) (*tracing.Tracer, error) {
+
+	if opts == nil {
+		return nil, errs.ErrNoTracerOptions
+	}
+
+	oo := opts.OTLPOptions
+	if oo == nil {
+		oo = &options.Options{}
+	}
+
+	eo, err := exporterOptions(opts, oo)
+	if err != nil {
+		return nil, err
+	}
+
+	exporter, err := otlptracegrpc.New(context.Background(), eo...)
+	if err != nil {
+		return nil, err
+	}
+
+	var sampler sdktrace.Sampler
+	switch opts.SampleRate {
+	case 0:
+		sampler = sdktrace.NeverSample()
+	case 1:
+		sampler = sdktrace.AlwaysSample()
+	default:
+		sampler = sdktrace.TraceIDRatioBased(opts.SampleRate)
+	}
+
+	tags := make([]attribute.KeyValue, 1, len(opts.Tags)+1)
+	tags[0] = attribute.String("service.name", opts.ServiceName)
+	for k, v := range opts.Tags {
+		tags = append(tags, attribute.String(k, v))
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter, opts.BatchSpanProcessorOptions()...),
+		sdktrace.WithSampler(sampler),
+		sdktrace.WithResource(resource.NewWithAttributes("", tags...)),
+	)
+
+	tracer := tp.Tracer(opts.Name)
+
+	return &tracing.Tracer{
+		Name:         opts.Name,
+		Tracer:       tracer,
+		Options:      opts,
+		ShutdownFunc: tp.Shutdown,
+	}, nil
+
+}
+
+// exporterOptions returns the OTLP/gRPC exporter options for the provided tracer options
+func exporterOptions(opts *topts.Options, oo *options.Options) ([]otlptracegrpc.Option, error) {
+
+	endpoint, insecure, err := collectorEndpoint(opts.CollectorURL, oo.Insecure)
+	if err != nil {
+		return nil, err
+	}
+	eo := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(endpoint)}
+
+	if insecure {
+		eo = append(eo, otlptracegrpc.WithInsecure())
+	} else {
+		tc, err := tlsConfig(oo)
+		if err != nil {
+			return nil, err
+		}
+		eo = append(eo, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(tc)))
+	}
+
+	h := make(map[string]string, len(oo.Headers)+1)
+	for k, v := range oo.Headers {
+		h[k] = v
+	}
+	if opts.CollectorUser != "" {
+		h["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString(
+			[]byte(opts.CollectorUser+":"+opts.CollectorPass))
+	}
+	if len(h) > 0 {
+		eo = append(eo, otlptracegrpc.WithHeaders(h))
+	}
+
+	return eo, nil
+}
+
+// collectorEndpoint returns the host:port of the configured collector, and whether
+// it is to be reached without TLS. host:port values use TLS unless insecure is set,
+// while the scheme of a URL selects whether TLS is used
+func collectorEndpoint(in string, insecure bool) (string, bool, error) {
+	if in == "" {
+		return "", false, errs.ErrInvalidEndpointURL
+	}
+	if !strings.Contains(in, "://") {
+		return in, insecure, nil
+	}
+	u, err := url.Parse(in)
+	if err != nil || u.Host == "" {
+		return "", false, errs.ErrInvalidEndpointURL
+	}
+	switch u.Scheme {
+	case "http":
+		return u.Host, true, nil
+	case "https":
+		return u.Host, false, nil
+	}
+	return "", false, errs.ErrInvalidEndpointURL
+}
+
+func tlsConfig(o *options.Options) (*tls.Config, error) {
+
+	tc := &tls.Config{InsecureSkipVerify: o.InsecureSkipVerify}
+
+	if o.ClientCertPath != "" && o.ClientKeyPath != "" {
+		cert, err := tls.LoadX509KeyPair(o.ClientCertPath, o.ClientKeyPath)
+		if err != nil {
+			return nil, err
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+
+	if len(o.CertificateAuthorityPaths) > 0 {
+		rootCAs, _ := x509.SystemCertPool()
+		if rootCAs == nil {
+			rootCAs = x509.NewCertPool()
+		}
+		for _, path := range o.CertificateAuthorityPaths {
+			certs, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			if ok := rootCAs.AppendCertsFromPEM(certs); !ok {
+				return nil, fmt.Errorf("unable to append to CA Certs from file %s", path)
+			}
+		}
+		tc.RootCAs = rootCAs
+	}
+
+	return tc, nil
+}
@@ -0,0 +1,173 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package otlp
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+
+	errs "github.com/trickstercache/trickster/v2/pkg/observability/tracing/errors"
+	"github.com/trickstercache/trickster/v2/pkg/observability/tracing/exporters/otlp/options"
+	topts "github.com/trickstercache/trickster/v2/pkg/observability/tracing/options"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// traceServer is an OTLP/gRPC trace collector that records the requests it receives
+type traceServer struct {
+	coltracepb.UnimplementedTraceServiceServer
+	mtx      sync.Mutex
+	requests []*coltracepb.ExportTraceServiceRequest
+	md       metadata.MD
+}
+
+func (s *traceServer) Export(ctx context.Context,
+	req *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.requests = append(s.requests, req)
+	s.md, _ = metadata.FromIncomingContext(ctx)
+	return &coltracepb.ExportTraceServiceResponse{}, nil
+}
+
+func TestNew(t *testing.T) {
+
+	_, err := New(nil)
+	if err != errs.ErrNoTracerOptions {
+		t.Errorf("expected %v got %v", errs.ErrNoTracerOptions, err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := &traceServer{}
+	srv := grpc.NewServer()
+	coltracepb.RegisterTraceServiceServer(srv, ts)
+	go srv.Serve(l)
+	defer srv.Stop()
+
+	opts := topts.New()
+	opts.Name = "test"
+	opts.Provider = "otlp"
+	opts.SampleRate = 1
+	opts.CollectorURL = l.Addr().String()
+	opts.OTLPOptions = &options.Options{Insecure: true,
+		Headers: map[string]string{"Authorization": "Bearer test"}}
+
+	tr, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, s := tr.Tracer.Start(context.Background(), "test-span")
+	s.SetAttributes(attribute.Int64("int", 1))
+	s.AddEvent("test-event")
+	s.SetStatus(codes.Error, "failed")
+	s.End()
+
+	if err = tr.ShutdownFunc(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ts.mtx.Lock()
+	defer ts.mtx.Unlock()
+	if v := ts.md.Get("authorization"); len(v) != 1 || v[0] != "Bearer test" {
+		t.Errorf("expected %s got %v", "Bearer test", v)
+	}
+	if len(ts.requests) != 1 || len(ts.requests[0].ResourceSpans) != 1 ||
+		len(ts.requests[0].ResourceSpans[0].ScopeSpans) != 1 ||
+		len(ts.requests[0].ResourceSpans[0].ScopeSpans[0].Spans) != 1 {
+		t.Fatalf("unexpected export requests %v", ts.requests)
+	}
+	rs := ts.requests[0].ResourceSpans[0]
+	sp := rs.ScopeSpans[0].Spans[0]
+	if sp.Name != "test-span" || sp.Status.Code != tracepb.Status_STATUS_CODE_ERROR ||
+		len(sp.Events) != 1 || len(sp.Attributes) != 1 {
+		t.Errorf("unexpected span %v", sp)
+	}
+	var foundService bool
+	for _, kv := range rs.Resource.Attributes {
+		if kv.Key == "service.name" &&
+			kv.Value.GetStringValue() == topts.DefaultTracerServiceName {
+			foundService = true
+		}
+	}
+	if !foundService {
+		t.Error("expected service.name resource attribute")
+	}
+}
+
+func TestCollectorEndpoint(t *testing.T) {
+
+	tests := []struct {
+		in               string
+		insecure         bool
+		expected         string
+		expectedInsecure bool
+		err              error
+	}{
+		{"collector:4317", true, "collector:4317", true, nil},
+		{"collector:4317", false, "collector:4317", false, nil},
+		{"http://collector:4317", false, "collector:4317", true, nil},
+		{"https://collector:4317", false, "collector:4317", false, nil},
+		{"https://collector:4317", true, "collector:4317", false, nil},
+		{"", false, "", false, errs.ErrInvalidEndpointURL},
+		{"ftp://collector:4317", false, "", false, errs.ErrInvalidEndpointURL},
+	}
+
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			ep, insecure, err := collectorEndpoint(test.in, test.insecure)
+			if err != test.err {
+				t.Errorf("expected %v got %v", test.err, err)
+			}
+			if ep != test.expected {
+				t.Errorf("expected %s got %s", test.expected, ep)
+			}
+			if insecure != test.expectedInsecure {
+				t.Errorf("expected %t got %t", test.expectedInsecure, insecure)
+			}
+		})
+	}
+}
+
+func TestExporterOptions(t *testing.T) {
+	opts := topts.New()
+	opts.CollectorURL = "collector:4317"
+	opts.CollectorUser = "user"
+	opts.CollectorPass = "pass"
+	eo, err := exporterOptions(opts, &options.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(eo) != 3 {
+		t.Errorf("expected %d got %d", 3, len(eo))
+	}
+	_, err = exporterOptions(opts,
+		&options.Options{CertificateAuthorityPaths: []string{"/nonexistent/ca.pem"}})
+	if err == nil {
+		t.Error("expected error for missing ca file")
+	}
+}
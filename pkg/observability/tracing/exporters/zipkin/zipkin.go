@@ -54,10 +54,7 @@ func New(options *options.Options) (*tracing.Tracer, error) {
 	}
 
 	tp = sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exporter,
-			sdktrace.WithBatchTimeout(5),
-			sdktrace.WithMaxExportBatchSize(10),
-		),
+		sdktrace.WithBatcher(exporter, options.BatchSpanProcessorOptions()...),
 		sdktrace.WithSampler(sampler),
 	)
 
@@ -21,4 +21,23 @@ const (
 	DefaultTracerProvider = "none"
 	// DefaultTracerServiceName is the default service name under which traces are registered
 	DefaultTracerServiceName = "trickster"
+	// DefaultBatchTimeoutMS is the default maximum delay, in milliseconds, before a
+	// batch of spans is exported by SDK-based tracers
+	DefaultBatchTimeoutMS = 5000
+	// DefaultMaxExportBatchSize is the default maximum number of spans per export
+	DefaultMaxExportBatchSize = 10
+	// DefaultCacheKeyMode is the default mode for recording cache keys on cache spans
+	DefaultCacheKeyMode = CacheKeyModeFull
+	// DefaultCacheKeyMaxLength is the default number of leading characters of a cache key
+	// recorded on cache spans in the truncate mode
+	DefaultCacheKeyMaxLength = 16
+)
+
+const (
+	// CacheKeyModeFull records the full cache key on cache spans
+	CacheKeyModeFull = "full"
+	// CacheKeyModeHash records an MD5 hash of the cache key on cache spans
+	CacheKeyModeHash = "hash"
+	// CacheKeyModeTruncate records the leading characters of the cache key on cache spans
+	CacheKeyModeTruncate = "truncate"
 )
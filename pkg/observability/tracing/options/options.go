@@ -17,10 +17,16 @@
 package options
 
 import (
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/checksum/md5"
 	jaegeropts "github.com/trickstercache/trickster/v2/pkg/observability/tracing/exporters/jaeger/options"
+	otlpopts "github.com/trickstercache/trickster/v2/pkg/observability/tracing/exporters/otlp/options"
 	stdoutopts "github.com/trickstercache/trickster/v2/pkg/observability/tracing/exporters/stdout/options"
 	"github.com/trickstercache/trickster/v2/pkg/util/copiers"
 	"github.com/trickstercache/trickster/v2/pkg/util/yamlx"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 // Options is a Tracing Options collection
@@ -34,11 +40,26 @@ type Options struct {
 	SampleRate    float64           `yaml:"sample_rate,omitempty"`
 	Tags          map[string]string `yaml:"tags,omitempty"`
 	OmitTagsList  []string          `yaml:"omit_tags,omitempty"`
+	// OmitCacheKeys, when true, omits the cache.key attribute from cache spans,
+	// for deployments where cache keys should not be exported to the trace backend
+	OmitCacheKeys bool `yaml:"omit_cache_keys,omitempty"`
+	// CacheKeyMode determines how the cache.key attribute is recorded on cache spans:
+	// full (the default), hash or truncate
+	CacheKeyMode string `yaml:"cache_key_mode,omitempty"`
+	// CacheKeyMaxLength is the number of leading characters of the cache key recorded
+	// when CacheKeyMode is truncate
+	CacheKeyMaxLength int `yaml:"cache_key_max_length,omitempty"`
+	// BatchTimeoutMS is the maximum delay in milliseconds before a batch of spans is exported
+	BatchTimeoutMS int `yaml:"batch_timeout_ms,omitempty"`
+	// MaxExportBatchSize is the maximum number of spans included in a single export
+	MaxExportBatchSize int `yaml:"max_export_batch_size,omitempty"`
 
 	StdOutOptions *stdoutopts.Options `yaml:"stdout,omitempty"`
 	JaegerOptions *jaegeropts.Options `yaml:"jaeger,omitempty"`
+	OTLPOptions   *otlpopts.Options   `yaml:"otlp,omitempty"`
 
-	OmitTags map[string]interface{} `yaml:"-"`
+	OmitTags     map[string]interface{} `yaml:"-"`
+	BatchTimeout time.Duration          `yaml:"-"`
 	// for tracers that don't support WithProcess (e.g., Zipkin)
 	attachTagsToSpan bool
 }
@@ -46,10 +67,16 @@ type Options struct {
 // New returns a new *Options with the default values
 func New() *Options {
 	return &Options{
-		Provider:      DefaultTracerProvider,
-		ServiceName:   DefaultTracerServiceName,
-		StdOutOptions: &stdoutopts.Options{},
-		JaegerOptions: &jaegeropts.Options{},
+		Provider:           DefaultTracerProvider,
+		ServiceName:        DefaultTracerServiceName,
+		BatchTimeoutMS:     DefaultBatchTimeoutMS,
+		BatchTimeout:       time.Duration(DefaultBatchTimeoutMS) * time.Millisecond,
+		MaxExportBatchSize: DefaultMaxExportBatchSize,
+		CacheKeyMode:       DefaultCacheKeyMode,
+		CacheKeyMaxLength:  DefaultCacheKeyMaxLength,
+		StdOutOptions:      &stdoutopts.Options{},
+		JaegerOptions:      &jaegeropts.Options{},
+		OTLPOptions:        &otlpopts.Options{},
 	}
 }
 
@@ -63,20 +90,31 @@ func (o *Options) Clone() *Options {
 	if o.JaegerOptions != nil {
 		jo = o.JaegerOptions.Clone()
 	}
+	var oo *otlpopts.Options
+	if o.OTLPOptions != nil {
+		oo = o.OTLPOptions.Clone()
+	}
 	return &Options{
-		Name:             o.Name,
-		Provider:         o.Provider,
-		ServiceName:      o.ServiceName,
-		CollectorURL:     o.CollectorURL,
-		CollectorUser:    o.CollectorUser,
-		CollectorPass:    o.CollectorPass,
-		SampleRate:       o.SampleRate,
-		Tags:             copiers.CopyStringLookup(o.Tags),
-		OmitTags:         copiers.CopyLookup(o.OmitTags),
-		OmitTagsList:     copiers.CopyStrings(o.OmitTagsList),
-		StdOutOptions:    so,
-		JaegerOptions:    jo,
-		attachTagsToSpan: o.attachTagsToSpan,
+		Name:               o.Name,
+		Provider:           o.Provider,
+		ServiceName:        o.ServiceName,
+		CollectorURL:       o.CollectorURL,
+		CollectorUser:      o.CollectorUser,
+		CollectorPass:      o.CollectorPass,
+		SampleRate:         o.SampleRate,
+		Tags:               copiers.CopyStringLookup(o.Tags),
+		OmitTags:           copiers.CopyLookup(o.OmitTags),
+		OmitTagsList:       copiers.CopyStrings(o.OmitTagsList),
+		OmitCacheKeys:      o.OmitCacheKeys,
+		CacheKeyMode:       o.CacheKeyMode,
+		CacheKeyMaxLength:  o.CacheKeyMaxLength,
+		BatchTimeoutMS:     o.BatchTimeoutMS,
+		BatchTimeout:       o.BatchTimeout,
+		MaxExportBatchSize: o.MaxExportBatchSize,
+		StdOutOptions:      so,
+		JaegerOptions:      jo,
+		OTLPOptions:        oo,
+		attachTagsToSpan:   o.attachTagsToSpan,
 	}
 }
 
@@ -96,12 +134,62 @@ func ProcessTracingOptions(mo map[string]*Options, metadata yamlx.KeyLookup) {
 			if !metadata.IsDefined("tracing", k, "provider") {
 				v.Provider = DefaultTracerProvider
 			}
+			if !metadata.IsDefined("tracing", k, "batch_timeout_ms") {
+				v.BatchTimeoutMS = DefaultBatchTimeoutMS
+			}
+			if !metadata.IsDefined("tracing", k, "max_export_batch_size") {
+				v.MaxExportBatchSize = DefaultMaxExportBatchSize
+			}
+			if !metadata.IsDefined("tracing", k, "cache_key_mode") {
+				v.CacheKeyMode = DefaultCacheKeyMode
+			}
+			if !metadata.IsDefined("tracing", k, "cache_key_max_length") {
+				v.CacheKeyMaxLength = DefaultCacheKeyMaxLength
+			}
 		}
+		v.BatchTimeout = time.Duration(v.BatchTimeoutMS) * time.Millisecond
 		v.generateOmitTags()
 		v.setAttachTags()
 	}
 }
 
+// BatchSpanProcessorOptions returns the batching options to use when registering
+// an exporter with an SDK TracerProvider. Unset values fall back to the defaults.
+func (o *Options) BatchSpanProcessorOptions() []sdktrace.BatchSpanProcessorOption {
+	bt := o.BatchTimeout
+	if bt <= 0 {
+		bt = time.Duration(DefaultBatchTimeoutMS) * time.Millisecond
+	}
+	bs := o.MaxExportBatchSize
+	if bs <= 0 {
+		bs = DefaultMaxExportBatchSize
+	}
+	return []sdktrace.BatchSpanProcessorOption{
+		sdktrace.WithBatchTimeout(bt),
+		sdktrace.WithMaxExportBatchSize(bs),
+	}
+}
+
+// CacheKeyAttribute returns the value of the cache.key attribute recorded on cache spans
+// for the provided key. Unrecognized modes hash the key, so that a misspelled mode never
+// exports the full key
+func (o *Options) CacheKeyAttribute(key string) string {
+	switch o.CacheKeyMode {
+	case "", CacheKeyModeFull:
+		return key
+	case CacheKeyModeTruncate:
+		n := o.CacheKeyMaxLength
+		if n <= 0 {
+			n = DefaultCacheKeyMaxLength
+		}
+		if len(key) > n {
+			return key[:n]
+		}
+		return key
+	}
+	return md5.Checksum(key)
+}
+
 func (o *Options) generateOmitTags() {
 	o.OmitTags = copiers.LookupFromStrings(o.OmitTagsList)
 }
@@ -18,7 +18,9 @@ package options
 
 import (
 	"testing"
+	"time"
 
+	"github.com/trickstercache/trickster/v2/pkg/checksum/md5"
 	"github.com/trickstercache/trickster/v2/pkg/util/yamlx"
 )
 
@@ -48,6 +50,32 @@ func TestProcessTracingConfigs(t *testing.T) {
 		t.Errorf("expected 1 got %d", int(o.SampleRate))
 	}
 
+	if o.BatchTimeout != 5*time.Second {
+		t.Errorf("expected %s got %s", 5*time.Second, o.BatchTimeout)
+	}
+
+	if o.MaxExportBatchSize != DefaultMaxExportBatchSize {
+		t.Errorf("expected %d got %d", DefaultMaxExportBatchSize, o.MaxExportBatchSize)
+	}
+
+	if o.CacheKeyMode != DefaultCacheKeyMode || o.CacheKeyMaxLength != DefaultCacheKeyMaxLength {
+		t.Errorf("expected %s/%d got %s/%d", DefaultCacheKeyMode, DefaultCacheKeyMaxLength,
+			o.CacheKeyMode, o.CacheKeyMaxLength)
+	}
+
+	o.BatchTimeoutMS = 250
+	o.MaxExportBatchSize = 512
+	ProcessTracingOptions(mo, yamlx.KeyLookup{
+		"tracing.test.batch_timeout_ms":      nil,
+		"tracing.test.max_export_batch_size": nil,
+	})
+	if o.BatchTimeout != 250*time.Millisecond {
+		t.Errorf("expected %s got %s", 250*time.Millisecond, o.BatchTimeout)
+	}
+	if o.MaxExportBatchSize != 512 {
+		t.Errorf("expected %d got %d", 512, o.MaxExportBatchSize)
+	}
+
 }
 
 func TestGenerateOmitTags(t *testing.T) {
@@ -71,3 +99,39 @@ func TestAttachTagsToSpan(t *testing.T) {
 	}
 
 }
+
+func TestBatchSpanProcessorOptions(t *testing.T) {
+	o := &Options{}
+	if len(o.BatchSpanProcessorOptions()) != 2 {
+		t.Error("expected 2 options")
+	}
+}
+
+func TestCacheKeyAttribute(t *testing.T) {
+
+	const key = "0123456789abcdefghij"
+	o := New()
+	if v := o.CacheKeyAttribute(key); v != key {
+		t.Errorf("expected %s got %s", key, v)
+	}
+
+	o.CacheKeyMode = CacheKeyModeTruncate
+	if v := o.CacheKeyAttribute(key); v != key[:DefaultCacheKeyMaxLength] {
+		t.Errorf("expected %s got %s", key[:DefaultCacheKeyMaxLength], v)
+	}
+	o.CacheKeyMaxLength = 4
+	if v := o.CacheKeyAttribute(key); v != "0123" {
+		t.Errorf("expected %s got %s", "0123", v)
+	}
+	if v := o.CacheKeyAttribute("012"); v != "012" {
+		t.Errorf("expected %s got %s", "012", v)
+	}
+
+	expected := md5.Checksum(key)
+	for _, mode := range []string{CacheKeyModeHash, "hsah"} {
+		o.CacheKeyMode = mode
+		if v := o.CacheKeyAttribute(key); v != expected {
+			t.Errorf("expected %s got %s", expected, v)
+		}
+	}
+}
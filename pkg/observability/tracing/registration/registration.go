@@ -26,6 +26,7 @@ import (
 	"github.com/trickstercache/trickster/v2/pkg/observability/tracing"
 	"github.com/trickstercache/trickster/v2/pkg/observability/tracing/exporters/jaeger"
 	"github.com/trickstercache/trickster/v2/pkg/observability/tracing/exporters/noop"
+	"github.com/trickstercache/trickster/v2/pkg/observability/tracing/exporters/otlp"
 	"github.com/trickstercache/trickster/v2/pkg/observability/tracing/exporters/stdout"
 	"github.com/trickstercache/trickster/v2/pkg/observability/tracing/exporters/zipkin"
 	"github.com/trickstercache/trickster/v2/pkg/observability/tracing/options"
@@ -117,6 +118,9 @@ func GetTracer(options *options.Options, logger interface{}, isDryRun bool) (*tr
 	case providers.Zipkin.String():
 		logTracerRegistration()
 		return zipkin.New(options)
+	case providers.OTLP.String():
+		logTracerRegistration()
+		return otlp.New(options)
 	}
 
 	return nil, nil
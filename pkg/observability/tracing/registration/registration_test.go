@@ -77,6 +77,12 @@ func TestRegisterAll(t *testing.T) {
 		t.Error(err)
 	}
 
+	tc.Provider = "otlp"
+	_, err = RegisterAll(cfg, tl.ConsoleLogger("error"), true)
+	if err != nil {
+		t.Error(err)
+	}
+
 	tc.Provider = "foo"
 
 	_, err = RegisterAll(cfg, tl.ConsoleLogger("error"), true)
@@ -0,0 +1,146 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package cacheevents provides a Notifier that reports a backend's cache writes and
+// purges to a webhook, so that other systems (e.g., Trickster instances in other
+// regions) can coordinate the invalidation of their own caches
+package cacheevents
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/observability/metrics"
+	ceo "github.com/trickstercache/trickster/v2/pkg/proxy/cacheevents/options"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+)
+
+// Cache event operations
+const (
+	// OpStore is the operation of an event for an object written to the cache
+	OpStore = "store"
+	// OpPurge is the operation of an event for an object removed from the cache
+	OpPurge = "purge"
+	// OpPurgePrefix is the operation of an event for all objects removed from the
+	// cache under a key prefix, which is provided as the event's key
+	OpPurgePrefix = "purge_prefix"
+)
+
+// Outcome names of a notification, as recorded in metrics
+const (
+	OutcomeSent    = "sent"
+	OutcomeFailed  = "failed"
+	OutcomeDropped = "dropped"
+)
+
+// Event describes a cache write or purge
+type Event struct {
+	Time    time.Time `json:"time"`
+	Backend string    `json:"backend"`
+	Op      string    `json:"op"`
+	Key     string    `json:"key"`
+	TTLMS   int64     `json:"ttl_ms,omitempty"`
+	Size    int64     `json:"size,omitempty"`
+}
+
+// Notifier asynchronously POSTs cache events to a webhook. Events are queued in a
+// bounded buffer, so that notifications never block the request path; events raised
+// while the buffer is full are dropped. Notifications are best-effort and are not
+// retried, since they are informational rather than required for correctness
+type Notifier struct {
+	backend string
+	url     string
+	client  *http.Client
+	queue   chan *Event
+	running atomic.Bool
+}
+
+// New returns a new Notifier for the named backend's cache events
+func New(backend string, o *ceo.Options) *Notifier {
+	return &Notifier{
+		backend: backend,
+		url:     o.WebhookURL,
+		client:  &http.Client{Timeout: o.Timeout},
+		queue:   make(chan *Event, o.BufferSize),
+	}
+}
+
+// Notify queues an event for delivery to the webhook without blocking. When the
+// buffer is full, the event is dropped. Notify is a no-op for a nil Notifier
+func (n *Notifier) Notify(op, key string, ttl time.Duration, size int64) {
+	if n == nil {
+		return
+	}
+	e := &Event{Time: time.Now(), Backend: n.backend, Op: op, Key: key,
+		TTLMS: ttl.Milliseconds(), Size: size}
+	select {
+	case n.queue <- e:
+	default:
+		metrics.ProxyCacheEventNotifications.WithLabelValues(n.backend, OutcomeDropped).Inc()
+		return
+	}
+	// events are delivered by a single worker, which exits once the queue is drained
+	if n.running.CompareAndSwap(false, true) {
+		go n.run()
+	}
+}
+
+// run delivers queued events until the queue is empty
+func (n *Notifier) run() {
+	for {
+		select {
+		case e := <-n.queue:
+			n.send(e)
+		default:
+			n.running.Store(false)
+			// an event queued after the queue was found empty, but before running was
+			// cleared, would otherwise wait in the queue for the next call to Notify
+			if len(n.queue) == 0 || !n.running.CompareAndSwap(false, true) {
+				return
+			}
+		}
+	}
+}
+
+// send POSTs the event to the webhook
+func (n *Notifier) send(e *Event) {
+	outcome := OutcomeFailed
+	defer func() {
+		metrics.ProxyCacheEventNotifications.WithLabelValues(n.backend, outcome).Inc()
+	}()
+	b, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	r, err := http.NewRequest(http.MethodPost, n.url, bytes.NewReader(b))
+	if err != nil {
+		return
+	}
+	r.Header.Set(headers.NameContentType, headers.ValueApplicationJSON)
+	resp, err := n.client.Do(r)
+	if err != nil {
+		return
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		outcome = OutcomeSent
+	}
+}
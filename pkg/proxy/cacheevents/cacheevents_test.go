@@ -0,0 +1,134 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacheevents
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/observability/metrics"
+	ceo "github.com/trickstercache/trickster/v2/pkg/proxy/cacheevents/options"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// counterValue returns the current value of the counter
+func counterValue(c prometheus.Counter) float64 {
+	m := &dto.Metric{}
+	c.Write(m)
+	return m.GetCounter().GetValue()
+}
+
+func TestNotify(t *testing.T) {
+
+	events := make(chan Event, 2)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost ||
+			r.Header.Get(headers.NameContentType) != headers.ValueApplicationJSON {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var e Event
+		json.NewDecoder(r.Body).Decode(&e)
+		events <- e
+	}))
+	defer ts.Close()
+
+	n := New("notify-test", &ceo.Options{WebhookURL: ts.URL, BufferSize: 2, Timeout: time.Second})
+	n.Notify(OpStore, "key1", time.Minute, 1024)
+	n.Notify(OpPurge, "key1", 0, 0)
+
+	expected := []Event{
+		{Backend: "notify-test", Op: OpStore, Key: "key1", TTLMS: 60000, Size: 1024},
+		{Backend: "notify-test", Op: OpPurge, Key: "key1"},
+	}
+	for i, ex := range expected {
+		select {
+		case e := <-events:
+			if e.Time.IsZero() {
+				t.Errorf("test %d expected non-zero time", i)
+			}
+			e.Time = time.Time{}
+			if e != ex {
+				t.Errorf("test %d expected %v got %v", i, ex, e)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("test %d timed out waiting for event", i)
+		}
+	}
+
+	// the worker exits once the queue is drained, and is restarted by the next event
+	time.Sleep(10 * time.Millisecond)
+	n.Notify(OpStore, "key2", time.Second, 1)
+	select {
+	case e := <-events:
+		if e.Key != "key2" {
+			t.Errorf("expected %s got %s", "key2", e.Key)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+
+	var nilNotifier *Notifier
+	nilNotifier.Notify(OpStore, "key3", 0, 0)
+}
+
+func TestNotifyOverflow(t *testing.T) {
+
+	// the webhook doesn't respond until released, so the buffer fills
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	const backend = "overflow-test"
+	dropped := metrics.ProxyCacheEventNotifications.WithLabelValues(backend, OutcomeDropped)
+	n := New(backend, &ceo.Options{WebhookURL: ts.URL, BufferSize: 2, Timeout: 10 * time.Second})
+
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		n.Notify(OpStore, "key", time.Minute, 1)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("expected notify not to block, took %s", d)
+	}
+	// at most one event is in flight and two are buffered; the rest are dropped
+	if v := counterValue(dropped); v < 97 {
+		t.Errorf("expected at least %d dropped events got %v", 97, v)
+	}
+}
+
+func TestSendFailed(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+	const backend = "failed-test"
+	n := New(backend, &ceo.Options{WebhookURL: ts.URL, BufferSize: 1, Timeout: time.Second})
+	n.send(&Event{Backend: backend, Op: OpPurge, Key: "key"})
+	failed := metrics.ProxyCacheEventNotifications.WithLabelValues(backend, OutcomeFailed)
+	if v := counterValue(failed); v != 1 {
+		t.Errorf("expected %d got %v", 1, v)
+	}
+}
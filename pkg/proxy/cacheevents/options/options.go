@@ -0,0 +1,89 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package options provides the configuration of the notifications sent for a
+// backend's cache writes and purges
+package options
+
+import (
+	"errors"
+	"net/url"
+	"time"
+)
+
+const (
+	// DefaultBufferSize is the default number of events held for delivery before
+	// further events are dropped
+	DefaultBufferSize = 1000
+	// DefaultTimeoutMS is the default timeout for each request to the webhook
+	DefaultTimeoutMS = 5000
+)
+
+// ErrInvalidWebhookURL is an error for a missing or invalid 'webhook_url'
+var ErrInvalidWebhookURL = errors.New("cache_events 'webhook_url' must be an absolute http or https url")
+
+// ErrInvalidBufferSize is an error for a negative 'buffer_size'
+var ErrInvalidBufferSize = errors.New("cache_events 'buffer_size' must be >= 0")
+
+// ErrInvalidTimeout is an error for a negative 'timeout_ms'
+var ErrInvalidTimeout = errors.New("cache_events 'timeout_ms' must be >= 0")
+
+// Options stores the configuration of the webhook that is notified of cache events
+type Options struct {
+	// WebhookURL is the URL to which each event is POSTed as JSON
+	WebhookURL string `yaml:"webhook_url,omitempty"`
+	// BufferSize is the number of events held for delivery. Events raised while the
+	// buffer is full are dropped
+	BufferSize int `yaml:"buffer_size,omitempty"`
+	// TimeoutMS is the timeout for each request to the webhook
+	TimeoutMS int `yaml:"timeout_ms,omitempty"`
+
+	// Timeout is the time.Duration representation of TimeoutMS
+	Timeout time.Duration `yaml:"-"`
+}
+
+// Clone returns an exact copy of the subject Options
+func (o *Options) Clone() *Options {
+	return &Options{
+		WebhookURL: o.WebhookURL,
+		BufferSize: o.BufferSize,
+		TimeoutMS:  o.TimeoutMS,
+		Timeout:    o.Timeout,
+	}
+}
+
+// Validate returns an error if the Options are invalid, and sets the defaults for
+// any unset values
+func (o *Options) Validate() error {
+	u, err := url.Parse(o.WebhookURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidWebhookURL
+	}
+	if o.BufferSize < 0 {
+		return ErrInvalidBufferSize
+	}
+	if o.BufferSize == 0 {
+		o.BufferSize = DefaultBufferSize
+	}
+	if o.TimeoutMS < 0 {
+		return ErrInvalidTimeout
+	}
+	if o.TimeoutMS == 0 {
+		o.TimeoutMS = DefaultTimeoutMS
+	}
+	o.Timeout = time.Duration(o.TimeoutMS) * time.Millisecond
+	return nil
+}
@@ -0,0 +1,58 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+
+	tests := []struct {
+		o        *Options
+		expected error
+	}{
+		{&Options{}, ErrInvalidWebhookURL},
+		{&Options{WebhookURL: "/events"}, ErrInvalidWebhookURL},
+		{&Options{WebhookURL: "ftp://events.example.com/"}, ErrInvalidWebhookURL},
+		{&Options{WebhookURL: "https://events.example.com/", BufferSize: -1}, ErrInvalidBufferSize},
+		{&Options{WebhookURL: "https://events.example.com/", TimeoutMS: -1}, ErrInvalidTimeout},
+		{&Options{WebhookURL: "https://events.example.com/"}, nil},
+	}
+
+	for i, test := range tests {
+		if err := test.o.Validate(); err != test.expected {
+			t.Errorf("test %d expected %v got %v", i, test.expected, err)
+		}
+	}
+
+	o := &Options{WebhookURL: "https://events.example.com/"}
+	o.Validate()
+	if o.BufferSize != DefaultBufferSize || o.Timeout != DefaultTimeoutMS*time.Millisecond {
+		t.Errorf("expected %d %d got %d %d", DefaultBufferSize, DefaultTimeoutMS*time.Millisecond,
+			o.BufferSize, o.Timeout)
+	}
+}
+
+func TestClone(t *testing.T) {
+	o := &Options{WebhookURL: "https://events.example.com/", BufferSize: 10, TimeoutMS: 500,
+		Timeout: 500 * time.Millisecond}
+	if o2 := o.Clone(); *o2 != *o {
+		t.Errorf("expected %v got %v", o, o2)
+	}
+}
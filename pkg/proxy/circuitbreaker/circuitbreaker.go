@@ -0,0 +1,181 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package circuitbreaker provides a circuit breaker that fast-fails requests to
+// an upstream origin that is consistently failing
+package circuitbreaker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/observability/metrics"
+)
+
+// State is the state of a Breaker
+type State int32
+
+const (
+	// StateClosed indicates requests are sent upstream as normal
+	StateClosed State = iota
+	// StateOpen indicates requests are fast-failed without being sent upstream
+	StateOpen
+	// StateHalfOpen indicates a single trial request is permitted upstream to test
+	// whether the origin has recovered
+	StateHalfOpen
+)
+
+var stateNames = map[State]string{
+	StateClosed:   "closed",
+	StateOpen:     "open",
+	StateHalfOpen: "half-open",
+}
+
+func (s State) String() string {
+	if v, ok := stateNames[s]; ok {
+		return v
+	}
+	return "unknown"
+}
+
+// Transition describes a change in a Breaker's State
+type Transition struct {
+	From State
+	To   State
+}
+
+// Changed returns true if the Transition represents a change in State
+func (t Transition) Changed() bool {
+	return t.From != t.To
+}
+
+// Breaker is a per-backend circuit breaker. After Threshold consecutive upstream
+// failures occurring within Window, it opens and fast-fails requests for Cooldown,
+// after which it half-opens to permit a single trial request. A successful trial
+// closes the breaker, while a failed one reopens it for another Cooldown.
+type Breaker struct {
+	name      string
+	threshold int
+	window    time.Duration
+	cooldown  time.Duration
+
+	mtx          sync.Mutex
+	state        State
+	failures     int
+	firstFailure time.Time
+	openedAt     time.Time
+	trialPending bool
+
+	now func() time.Time
+}
+
+// New returns a new closed Breaker for the named backend
+func New(name string, threshold int, window, cooldown time.Duration) *Breaker {
+	b := &Breaker{
+		name:      name,
+		threshold: threshold,
+		window:    window,
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+	metrics.ProxyCircuitBreakerState.WithLabelValues(name).Set(float64(StateClosed))
+	return b
+}
+
+// State returns the current State of the Breaker
+func (b *Breaker) State() State {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.state
+}
+
+// IsOpen returns true if the Breaker is open and will not permit a trial request
+func (b *Breaker) IsOpen() bool {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	switch b.state {
+	case StateOpen:
+		return b.now().Sub(b.openedAt) < b.cooldown
+	case StateHalfOpen:
+		return b.trialPending
+	}
+	return false
+}
+
+// Allow returns true if a request may be sent upstream. If the Breaker is open
+// and its cooldown has elapsed, it transitions to half-open and permits the
+// request as its trial.
+func (b *Breaker) Allow() (bool, Transition) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	switch b.state {
+	case StateOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return false, Transition{From: b.state, To: b.state}
+		}
+		b.trialPending = true
+		return true, b.setState(StateHalfOpen)
+	case StateHalfOpen:
+		if b.trialPending {
+			return false, Transition{From: b.state, To: b.state}
+		}
+		b.trialPending = true
+	}
+	return true, Transition{From: b.state, To: b.state}
+}
+
+// Record records the outcome of an upstream request that was permitted by Allow
+func (b *Breaker) Record(success bool) Transition {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	now := b.now()
+	switch b.state {
+	case StateHalfOpen:
+		b.trialPending = false
+		if success {
+			b.failures = 0
+			return b.setState(StateClosed)
+		}
+		b.openedAt = now
+		return b.setState(StateOpen)
+	case StateClosed:
+		if success {
+			b.failures = 0
+			break
+		}
+		if b.failures == 0 || (b.window > 0 && now.Sub(b.firstFailure) > b.window) {
+			b.failures = 0
+			b.firstFailure = now
+		}
+		b.failures++
+		if b.failures >= b.threshold {
+			b.openedAt = now
+			return b.setState(StateOpen)
+		}
+	}
+	return Transition{From: b.state, To: b.state}
+}
+
+// setState must be called while holding the Breaker's lock
+func (b *Breaker) setState(s State) Transition {
+	t := Transition{From: b.state, To: s}
+	b.state = s
+	if t.Changed() {
+		metrics.ProxyCircuitBreakerState.WithLabelValues(b.name).Set(float64(s))
+		metrics.ProxyCircuitBreakerTransitions.WithLabelValues(b.name, s.String()).Inc()
+	}
+	return t
+}
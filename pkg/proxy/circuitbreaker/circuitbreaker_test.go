@@ -0,0 +1,163 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package circuitbreaker
+
+import (
+	"testing"
+	"time"
+)
+
+type testClock struct {
+	t time.Time
+}
+
+func (c *testClock) now() time.Time {
+	return c.t
+}
+
+func (c *testClock) advance(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+func newTestBreaker(threshold int) (*Breaker, *testClock) {
+	c := &testClock{t: time.Unix(1700000000, 0)}
+	b := New("test", threshold, 10*time.Second, 30*time.Second)
+	b.now = c.now
+	return b, c
+}
+
+func TestStateString(t *testing.T) {
+	tests := []struct {
+		s        State
+		expected string
+	}{
+		{StateClosed, "closed"},
+		{StateOpen, "open"},
+		{StateHalfOpen, "half-open"},
+		{State(99), "unknown"},
+	}
+	for _, test := range tests {
+		if v := test.s.String(); v != test.expected {
+			t.Errorf("expected %s got %s", test.expected, v)
+		}
+	}
+}
+
+func TestBreakerOpensAtThreshold(t *testing.T) {
+	b, _ := newTestBreaker(3)
+	for i := 0; i < 2; i++ {
+		if ok, _ := b.Allow(); !ok {
+			t.Fatal("expected request to be allowed")
+		}
+		if tr := b.Record(false); tr.Changed() {
+			t.Errorf("unexpected transition on failure %d", i+1)
+		}
+	}
+	if b.IsOpen() {
+		t.Error("expected breaker to be closed")
+	}
+	b.Allow()
+	tr := b.Record(false)
+	if !tr.Changed() || tr.From != StateClosed || tr.To != StateOpen {
+		t.Errorf("expected closed->open transition, got %s->%s", tr.From, tr.To)
+	}
+	if !b.IsOpen() {
+		t.Error("expected breaker to be open")
+	}
+	if ok, _ := b.Allow(); ok {
+		t.Error("expected request to be fast-failed")
+	}
+}
+
+func TestBreakerSuccessResetsFailures(t *testing.T) {
+	b, _ := newTestBreaker(2)
+	b.Record(false)
+	b.Record(true)
+	b.Record(false)
+	if b.State() != StateClosed {
+		t.Errorf("expected %s got %s", StateClosed, b.State())
+	}
+}
+
+func TestBreakerWindowExpiry(t *testing.T) {
+	b, c := newTestBreaker(2)
+	b.Record(false)
+	c.advance(11 * time.Second)
+	b.Record(false)
+	if b.State() != StateClosed {
+		t.Errorf("expected %s got %s", StateClosed, b.State())
+	}
+	c.advance(time.Second)
+	b.Record(false)
+	if b.State() != StateOpen {
+		t.Errorf("expected %s got %s", StateOpen, b.State())
+	}
+}
+
+func TestBreakerHalfOpenTrial(t *testing.T) {
+	b, c := newTestBreaker(1)
+	b.Record(false)
+	if b.State() != StateOpen {
+		t.Fatalf("expected %s got %s", StateOpen, b.State())
+	}
+
+	c.advance(29 * time.Second)
+	if ok, _ := b.Allow(); ok {
+		t.Error("expected request to be fast-failed during cooldown")
+	}
+
+	c.advance(time.Second)
+	if b.IsOpen() {
+		t.Error("expected breaker to permit a trial after cooldown")
+	}
+	ok, tr := b.Allow()
+	if !ok {
+		t.Fatal("expected trial request to be allowed")
+	}
+	if tr.To != StateHalfOpen {
+		t.Errorf("expected %s got %s", StateHalfOpen, tr.To)
+	}
+	// only a single trial is permitted while it is pending
+	if ok, _ := b.Allow(); ok {
+		t.Error("expected concurrent request to be fast-failed")
+	}
+	if !b.IsOpen() {
+		t.Error("expected breaker to report open while trial is pending")
+	}
+
+	// a failed trial reopens the breaker for another cooldown
+	tr = b.Record(false)
+	if tr.From != StateHalfOpen || tr.To != StateOpen {
+		t.Errorf("expected half-open->open transition, got %s->%s", tr.From, tr.To)
+	}
+	if ok, _ := b.Allow(); ok {
+		t.Error("expected request to be fast-failed after failed trial")
+	}
+
+	// a successful trial closes the breaker
+	c.advance(30 * time.Second)
+	if ok, _ := b.Allow(); !ok {
+		t.Fatal("expected trial request to be allowed")
+	}
+	tr = b.Record(true)
+	if tr.From != StateHalfOpen || tr.To != StateClosed {
+		t.Errorf("expected half-open->closed transition, got %s->%s", tr.From, tr.To)
+	}
+	if ok, _ := b.Allow(); !ok {
+		t.Error("expected request to be allowed after breaker closed")
+	}
+}
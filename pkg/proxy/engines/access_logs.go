@@ -18,12 +18,17 @@ package engines
 
 import (
 	"net/http"
+	"time"
 
 	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
 )
 
 func logUpstreamRequest(logger interface{}, backendName, backendProvider, handlerName, method,
 	path, userAgent string, responseCode, size int, requestDuration float64) {
+	if !tl.SampleAccessLog(logger, responseCode,
+		time.Duration(requestDuration*float64(time.Second))) {
+		return
+	}
 	tl.Debug(logger, "upstream request",
 		tl.Pairs{
 			"backendName":     backendName,
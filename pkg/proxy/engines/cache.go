@@ -19,17 +19,25 @@ package engines
 import (
 	"bytes"
 	"context"
+	"encoding/binary"
 	"errors"
 	"io"
 	"mime"
 	"net/http"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/trickstercache/trickster/v2/pkg/cache"
 	"github.com/trickstercache/trickster/v2/pkg/cache/status"
+	"github.com/trickstercache/trickster/v2/pkg/checksum/fnv"
+	"github.com/trickstercache/trickster/v2/pkg/encoding/snappy"
+	"github.com/trickstercache/trickster/v2/pkg/encoding/zstd"
+	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
+	"github.com/trickstercache/trickster/v2/pkg/observability/metrics"
 	tspan "github.com/trickstercache/trickster/v2/pkg/observability/tracing/span"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/cacheevents"
 	tc "github.com/trickstercache/trickster/v2/pkg/proxy/context"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/ranges/byterange"
@@ -85,26 +93,22 @@ func queryConcurrent(ctx context.Context, c cache.Cache, key string, cr chan<- *
 			return qr
 		}
 
-		var inflate bool
-		// check and remove compression bit
-		if len(b) > 0 {
-			if b[0] == 1 {
-				inflate = true
+		// skip any metadata record, then check and remove compression byte
+		_, b, qr.err = splitMetadata(b)
+		if qr.err != nil {
+			if cr != nil {
+				cr <- qr
 			}
-			b = b[1:]
+			return qr
 		}
-
-		if inflate {
-			// tl.Debug(rsc.Logger, "decompressing cached data", tl.Pairs{"cacheKey": key})
-			decoder := brotli.NewReader(bytes.NewReader(b))
-			b, qr.err = io.ReadAll(decoder)
+		if len(b) > 0 {
+			b, qr.err = decompress(b[0], b[1:])
 			if qr.err != nil {
 				if cr != nil {
 					cr <- qr
 				}
 				return qr
 			}
-
 		}
 		_, qr.err = qr.d.UnmarshalMsg(b)
 		if qr.err != nil {
@@ -113,6 +117,7 @@ func queryConcurrent(ctx context.Context, c cache.Cache, key string, cr chan<- *
 			}
 			return qr
 		}
+		qr.d.setContentTypeFlags()
 	}
 	if cr != nil {
 		cr <- qr
@@ -129,6 +134,7 @@ func QueryCache(ctx context.Context, c cache.Cache, key string,
 	ctx, span := tspan.NewChildSpan(ctx, rsc.Tracer, "QueryCache")
 	if span != nil {
 		defer span.End()
+		tspan.SetAttributes(rsc.Tracer, span, cacheSpanAttributes(rsc, key)...)
 	}
 
 	var d *HTTPDocument
@@ -137,6 +143,7 @@ func QueryCache(ctx context.Context, c cache.Cache, key string,
 	// Query document
 	qr := queryConcurrent(ctx, c, key, nil, nil)
 	if qr.err != nil {
+		observeCacheLookup(rsc, c, qr.lookupStatus)
 		return qr.d, qr.lookupStatus, ranges, qr.err
 	} else {
 		if unmarshal != nil {
@@ -149,74 +156,52 @@ func QueryCache(ctx context.Context, c cache.Cache, key string,
 	// If we got a meta document and want to use cache chunking, do so
 	if c.Configuration().UseCacheChunking {
 		if trq := rsc.TimeRangeQuery; trq != nil {
-			// Do timeseries chunk retrieval
-			// Determine chunk extent and number of chunks
-			var cext timeseries.Extent
-			var csize = trq.Step * time.Duration(c.Configuration().TimeseriesChunkFactor)
-			var cct int
-			cext.Start, cext.End = trq.Extent.Start.Truncate(csize), trq.Extent.End.Truncate(csize).Add(csize)
-			cct = int(cext.End.Sub(cext.Start) / csize)
-			// Prepare buffered results and waitgroup
+			// Do timeseries chunk retrieval of each chunk overlapping the requested extent
+			cel := timeseriesChunkExtents(trq.Extent, trq.Step, c.Configuration().TimeseriesChunkFactor)
 			wg := &sync.WaitGroup{}
-			// Result slice of timeseries
-			ress := make([]timeseries.Timeseries, cct)
-			resi := 0
-			for chunkStart := cext.Start; chunkStart.Before(cext.End); chunkStart = chunkStart.Add(csize) {
-				// Chunk range (inclusive, on-step)
-				chunkExtent := timeseries.Extent{
-					Start: chunkStart,
-					End:   chunkStart.Add(csize - trq.Step),
-				}
-				// Derive subkey
-				subkey := key + chunkExtent.String()
-				// Query
+			// Result slice of timeseries, indexed by chunk; missing chunks are left nil
+			ress := make([]timeseries.Timeseries, len(cel))
+			for i, ce := range cel {
 				wg.Add(1)
-				go func(outIdx int) {
+				go func(outIdx int, subkey string) {
 					defer wg.Done()
 					qr := queryConcurrent(ctx, c, subkey, nil, nil)
-					if c.Configuration().Provider != "memory" {
-						qr.d.timeseries, qr.err = unmarshal(qr.d.Body, nil)
+					if qr.err != nil || qr.d == nil {
+						return
 					}
-					if qr.err == nil {
+					if c.Configuration().Provider == "memory" {
 						ress[outIdx] = qr.d.timeseries
+					} else if unmarshal != nil {
+						if ts, err := unmarshal(qr.d.Body, nil); err == nil {
+							ress[outIdx] = ts
+						}
 					}
-				}(resi)
-				resi++
+				}(i, key+ce.String())
 			}
 			// Wait on queries
 			wg.Wait()
-			d.timeseries = ress[0]
-			d.timeseries.Merge(true, ress[1:]...)
-			if d.timeseries != nil {
-				d.timeseries.SetExtents(d.timeseries.Extents().Compress(trq.Step))
+			d.timeseries = mergeTimeseriesChunks(ress, trq.Step)
+			// with no chunks in the cache, the meta document alone is not a usable hit
+			if d.timeseries == nil {
+				observeCacheLookup(rsc, c, status.LookupStatusKeyMiss)
+				return d, status.LookupStatusKeyMiss, ranges, cache.ErrKNF
 			}
 		} else {
-			// Do byterange chunking
-			// Determine chunk start/end and number of chunks
-			var crs, cre, cct int64
+			// Do byterange chunk retrieval of only the chunks overlapping the requested ranges
 			if len(ranges) == 0 {
 				ranges = byterange.Ranges{byterange.Range{Start: 0, End: d.ContentLength - 1}}
 			}
 			size := c.Configuration().ByterangeChunkSize
-			crs, cre = ranges[0].Start, ranges[len(ranges)-1].End
-			crs = (crs / size) * size
-			cre = (cre/size + 1) * size
-			cct = (cre - crs) / size
+			crl := byterangeChunkRanges(ranges, size)
 			// Allocate body in meta document
 			d.Body = make([]byte, d.ContentLength)
 			// Prepare buffered results and waitgroup
-			cr := make(chan *queryResult, cct)
+			cr := make(chan *queryResult, len(crl))
 			wg := &sync.WaitGroup{}
-			// Iterate chunks
-			for chunkStart := crs; chunkStart < cre; chunkStart += size {
-				// Determine chunk range (inclusive)
-				chunkRange := byterange.Range{
-					Start: chunkStart,
-					End:   chunkStart + size - 1,
-				}
-				// Determine subkey
+			subkeys := make(map[string]byterange.Range, len(crl))
+			for _, chunkRange := range crl {
 				subkey := key + chunkRange.String()
-				// Query subdocument
+				subkeys[subkey] = chunkRange
 				wg.Add(1)
 				go queryConcurrent(ctx, c, subkey, cr, wg.Done)
 			}
@@ -226,34 +211,53 @@ func QueryCache(ctx context.Context, c cache.Cache, key string,
 			// Handle results
 			dbl_lock := &sync.Mutex{}
 			var dbl int64
+			var missing byterange.Ranges
 			for qr := range cr {
 				// Return on error
 				if qr.err != nil && !errors.Is(qr.err, cache.ErrKNF) {
+					observeCacheLookup(rsc, c, qr.lookupStatus)
 					return qr.d, qr.lookupStatus, ranges, qr.err
 				}
+				if qr.lookupStatus != status.LookupStatusHit {
+					missing = append(missing, subkeys[qr.queryKey])
+					continue
+				}
 				// Merge with meta document on success
 				// We can do this concurrently since chunk ranges don't overlap
-
 				wg.Add(1)
 				go func(qrc *queryResult) {
 					defer wg.Done()
 					if qrc.d.IsMeta {
 						return
 					}
-					if qrc.lookupStatus == status.LookupStatusHit {
-						for _, r := range qrc.d.Ranges {
-							content := qrc.d.Body[r.Start%size : r.End%size+1]
-							r.Copy(d.Body, content)
-							dbl_lock.Lock()
-							if r.End+1 > dbl {
-								dbl = r.End + 1
-							}
-							dbl_lock.Unlock()
+					for _, r := range qrc.d.Ranges {
+						content := qrc.d.Body[r.Start%size : r.End%size+1]
+						r.Copy(d.Body, content)
+						dbl_lock.Lock()
+						if r.End+1 > dbl {
+							dbl = r.End + 1
 						}
+						dbl_lock.Unlock()
 					}
 				}(qr)
 			}
 			wg.Wait()
+			// only the chunks overlapping the requested ranges were read, so a subsequent
+			// write must only rewrite the chunks holding ranges that are later fetched
+			d.dirtyRanges = byterange.Ranges{}
+			// chunks missing from the cache (e.g., evicted) are no longer cached ranges,
+			// so they are fetched from the origin as with any partial hit
+			if len(missing) > 0 {
+				if len(d.Ranges) == 0 {
+					d.Ranges = byterange.Ranges{byterange.Range{Start: 0, End: d.ContentLength - 1}}
+				}
+				d.Ranges = removeByteRanges(d.Ranges, missing)
+				if len(d.Ranges) == 0 {
+					d.Body = nil
+					observeCacheLookup(rsc, c, status.LookupStatusKeyMiss)
+					return d, status.LookupStatusKeyMiss, ranges, cache.ErrKNF
+				}
+			}
 			if len(d.Ranges) > 1 {
 				d.StoredRangeParts = make(map[string]*byterange.MultipartByteRange)
 				for _, r := range d.Ranges {
@@ -263,17 +267,121 @@ func QueryCache(ctx context.Context, c cache.Cache, key string,
 					}
 				}
 				d.Body = nil
+			} else if len(d.Ranges) == 1 {
+				d.Body = d.Body[:d.Ranges[0].End+1]
 			} else {
 				d.Body = d.Body[:dbl]
 			}
 		}
 	}
 
+	lookupStatus, delta := resolveRanges(d, ranges, lookupStatus, span)
+	d.IsMeta = false
+	d.IsChunk = false
+
+	tspan.SetAttributes(rsc.Tracer, span, attribute.String("cache.status", lookupStatus.String()))
+	observeCacheLookup(rsc, c, lookupStatus)
+	return d, lookupStatus, delta, nil
+}
+
+// QueryCacheMetadata queries the cache for an HTTPDocument's metadata (its status, headers,
+// caching policy, content length and ranges) without decoding its body. This is suitable
+// for callers that may only need to revalidate the object. When the object was stored with
+// a separate metadata record, its compressed body is retained undecoded until loadBody is
+// called; otherwise the full document is returned. Objects holding partial content are
+// always fully decoded, since the ranges they hold determine the lookup status
+func QueryCacheMetadata(ctx context.Context, c cache.Cache,
+	key string) (*HTTPDocument, status.LookupStatus, error) {
+	d, lookupStatus, _, err := queryCacheMetadata(ctx, c, key, nil)
+	return d, lookupStatus, err
+}
+
+// queryCacheMetadata queries the cache for an HTTPDocument's metadata as QueryCacheMetadata,
+// and returns the delta between the requested ranges and those held by the document. The
+// object is read from the cache and counted in the cache lookups metric only once
+func queryCacheMetadata(ctx context.Context, c cache.Cache, key string,
+	ranges byterange.Ranges) (*HTTPDocument, status.LookupStatus, byterange.Ranges, error) {
+
+	rsc := tc.Resources(ctx).(*request.Resources)
+
+	ctx, span := tspan.NewChildSpan(ctx, rsc.Tracer, "QueryCacheMetadata")
+	if span != nil {
+		defer span.End()
+		tspan.SetAttributes(rsc.Tracer, span, cacheSpanAttributes(rsc, key)...)
+	}
+
+	var d *HTTPDocument
+	var lookupStatus status.LookupStatus
+	if c.Configuration().Provider == "memory" {
+		qr := queryConcurrent(ctx, c, key, nil, nil)
+		if qr.err != nil {
+			observeCacheLookup(rsc, c, qr.lookupStatus)
+			return qr.d, qr.lookupStatus, ranges, qr.err
+		}
+		d, lookupStatus = qr.d, qr.lookupStatus
+	} else {
+		b, ls, err := c.Retrieve(key, true)
+		if err != nil || ls != status.LookupStatusHit {
+			observeCacheLookup(rsc, c, ls)
+			return &HTTPDocument{}, ls, ranges, err
+		}
+		lookupStatus = ls
+		d = &HTTPDocument{}
+		if err = decodeMetadata(d, b); err != nil {
+			observeCacheLookup(rsc, c, lookupStatus)
+			return d, lookupStatus, ranges, err
+		}
+	}
+
 	var delta byterange.Ranges
+	if len(d.Ranges) > 0 {
+		// the body is needed to serve or complete the ranges held by the document
+		if err := d.loadBody(); err != nil {
+			observeCacheLookup(rsc, c, lookupStatus)
+			return d, lookupStatus, ranges, err
+		}
+		lookupStatus, delta = resolveRanges(d, ranges, lookupStatus, span)
+	}
+
+	tspan.SetAttributes(rsc.Tracer, span, attribute.String("cache.status", lookupStatus.String()))
+	observeCacheLookup(rsc, c, lookupStatus)
+	return d, lookupStatus, delta, nil
+}
+
+// decodeMetadata decodes the metadata record of a retrieved cache object into d. When the
+// object was stored with a separate metadata record, its compressed body is retained
+// undecoded; otherwise the full document is decoded
+func decodeMetadata(d *HTTPDocument, b []byte) error {
+	meta, b, err := splitMetadata(b)
+	if err != nil {
+		return err
+	}
+	if meta != nil {
+		d.encodedBody = b
+	} else if len(b) > 0 {
+		// objects stored without a metadata record are fully decoded instead
+		meta, err = decompress(b[0], b[1:])
+		if err != nil {
+			return err
+		}
+	}
+	if _, err = d.UnmarshalMsg(meta); err != nil {
+		return err
+	}
+	d.setContentTypeFlags()
+	return nil
+}
 
+// resolveRanges determines the delta between the requested ranges and the ranges held by
+// the cached document, and the resulting lookup status. A request for the whole body of a
+// document holding partial content is treated as a fulfillment of the entire object
+func resolveRanges(d *HTTPDocument, ranges byterange.Ranges, lookupStatus status.LookupStatus,
+	span trace.Span) (status.LookupStatus, byterange.Ranges) {
+
+	var delta byterange.Ranges
 	// Fulfillment is when we have a range stored, but a subsequent user wants the whole body, so
 	// we must inflate the requested range to be the entire object in order to get the correct delta.
-	d.isFulfillment = (len(d.Ranges) > 0) && (len(ranges) == 0)
+	d.isFulfillment = !d.nonRangeable && (len(d.Ranges) > 0) && (len(ranges) == 0)
 
 	if d.isFulfillment {
 		if span != nil {
@@ -282,7 +390,10 @@ func QueryCache(ctx context.Context, c cache.Cache, key string,
 		ranges = byterange.Ranges{byterange.Range{Start: 0, End: d.ContentLength - 1}}
 	}
 
-	if len(ranges) > 0 && len(d.Ranges) > 0 {
+	if !d.nonRangeable && len(ranges) > 0 && len(d.Ranges) > 0 {
+		// merge overlapping and adjacent ranges so each needed byte is only fetched once;
+		// the response is still assembled from the client's original ranges
+		ranges = ranges.Coalesce()
 		delta = ranges.CalculateDelta(d.Ranges, d.ContentLength)
 		if len(delta) > 0 {
 			if len(d.Body) > 0 {
@@ -307,11 +418,33 @@ func QueryCache(ctx context.Context, c cache.Cache, key string,
 			}
 		}
 	}
-	d.IsMeta = false
-	d.IsChunk = false
+	return lookupStatus, delta
+}
 
-	tspan.SetAttributes(rsc.Tracer, span, attribute.String("cache.status", lookupStatus.String()))
-	return d, lookupStatus, delta, nil
+// cacheSpanAttributes returns the span attributes identifying the backend and cache key
+// of a cache operation. The key is omitted, hashed or truncated according to the tracer's
+// configuration
+func cacheSpanAttributes(rsc *request.Resources, key string) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, 2)
+	if rsc.BackendOptions != nil {
+		attrs = append(attrs, attribute.String("backend.name", rsc.BackendOptions.Name))
+	}
+	if rsc.Tracer == nil || rsc.Tracer.Options == nil {
+		attrs = append(attrs, attribute.String("cache.key", key))
+	} else if !rsc.Tracer.Options.OmitCacheKeys {
+		attrs = append(attrs, attribute.String("cache.key",
+			rsc.Tracer.Options.CacheKeyAttribute(key)))
+	}
+	return attrs
+}
+
+// observeCacheLookup records the outcome of a cache lookup in the cache lookups metric
+func observeCacheLookup(rsc *request.Resources, c cache.Cache, ls status.LookupStatus) {
+	var backendName string
+	if rsc.BackendOptions != nil {
+		backendName = rsc.BackendOptions.Name
+	}
+	metrics.CacheLookups.WithLabelValues(backendName, c.Configuration().Name, ls.String()).Inc()
 }
 
 func stripConditionalHeaders(h http.Header) {
@@ -335,6 +468,11 @@ func writeConcurrent(ctx context.Context, c cache.Cache, key string, d *HTTPDocu
 		mc := c.(cache.MemoryCache)
 
 		if d != nil {
+			// the document is not serialized, so its estimated size is checked instead
+			if exceedsMaxCacheableSize(ctx, key, d.Size()) {
+				cr <- nil
+				return
+			}
 			// during unmarshal, these would come back as false, so lets set them as such even for direct access
 			d.rangePartsLoaded = false
 			d.isFulfillment = false
@@ -355,19 +493,175 @@ func writeConcurrent(ctx context.Context, c cache.Cache, key string, d *HTTPDocu
 		cr <- err
 		return
 	}
+	rawSize := len(b)
+	if exceedsMaxCacheableSize(ctx, key, rawSize) {
+		cr <- nil
+		return
+	}
 
+	ca := compressionNone
 	if compress {
-		// tl.Debug(rsc.Logger, "compressing cache data", tl.Pairs{"cacheKey": key})
-		buf := bytes.NewBuffer([]byte{1})
+		var ok bool
+		if ca, ok = compressionAlgorithms[c.Configuration().CompressionAlgorithm]; !ok {
+			ca = compressionBrotli
+		}
+	}
+	b, err = compressWith(ca, b)
+	if err != nil {
+		cr <- err
+		return
+	}
+	if ca != compressionNone && rawSize > 0 {
+		metrics.CacheCompressionRatio.WithLabelValues(c.Configuration().Name,
+			c.Configuration().Provider, compressionNames[ca]).
+			Observe(float64(len(b)) / float64(rawSize))
+	}
+
+	// chunks have no metadata of their own, since it is held by their meta document
+	if c.Configuration().SeparateMetadata && !d.IsChunk {
+		meta := d.GetMeta()
+		meta.IsMeta = false
+		var mb []byte
+		mb, err = meta.MarshalMsg(nil)
+		if err != nil {
+			cr <- err
+			return
+		}
+		b = withMetadata(mb, b)
+	}
+
+	cr <- c.Store(key, b, ttl)
+}
+
+// exceedsMaxCacheableSize returns true if size exceeds the Backend's MaxCacheableObjectBytes,
+// in which case the document should be served to the client without being cached
+func exceedsMaxCacheableSize(ctx context.Context, key string, size int) bool {
+	rsc, ok := tc.Resources(ctx).(*request.Resources)
+	if !ok || rsc == nil || rsc.BackendOptions == nil ||
+		rsc.BackendOptions.MaxCacheableObjectBytes <= 0 ||
+		int64(size) <= rsc.BackendOptions.MaxCacheableObjectBytes {
+		return false
+	}
+	tl.Debug(rsc.Logger, "document exceeds max cacheable size and will not be cached",
+		tl.Pairs{"cacheKey": key, "size": size,
+			"maxCacheableObjectBytes": rsc.BackendOptions.MaxCacheableObjectBytes})
+	return true
+}
+
+// the leading byte of each serialized cache object identifies its compression algorithm.
+// these values are persisted and must never be renumbered
+const (
+	compressionNone byte = iota
+	compressionBrotli
+	compressionZstd
+	compressionSnappy
+)
+
+var compressionAlgorithms = map[string]byte{
+	"none":   compressionNone,
+	"brotli": compressionBrotli,
+	"zstd":   compressionZstd,
+	"snappy": compressionSnappy,
+}
+
+var compressionNames = map[byte]string{
+	compressionNone:   "none",
+	compressionBrotli: "brotli",
+	compressionZstd:   "zstd",
+	compressionSnappy: "snappy",
+}
+
+var errUnknownCompression = errors.New("unknown cache object compression algorithm")
+
+// layoutSeparateMetadata is the leading byte of a serialized cache object that begins with
+// an uncompressed metadata record, followed by the compressed document. Objects in the
+// original layout begin with their compression byte, which is always less than this value.
+// The full layout is: layoutSeparateMetadata, the 4-byte big-endian length of the metadata
+// record, the metadata record, the compression byte and the compressed document
+const layoutSeparateMetadata byte = 0x80
+
+var errMalformedMetadata = errors.New("malformed cache object metadata record")
+
+// withMetadata prefixes a serialized cache object with an uncompressed metadata record
+func withMetadata(meta, b []byte) []byte {
+	out := make([]byte, 5, 5+len(meta)+len(b))
+	out[0] = layoutSeparateMetadata
+	binary.BigEndian.PutUint32(out[1:5], uint32(len(meta)))
+	out = append(out, meta...)
+	return append(out, b...)
+}
+
+// splitMetadata separates the uncompressed metadata record from a serialized cache object.
+// For objects in the original layout, meta is nil and b is returned unchanged
+func splitMetadata(b []byte) (meta, obj []byte, err error) {
+	if len(b) == 0 || b[0] != layoutSeparateMetadata {
+		return nil, b, nil
+	}
+	if len(b) < 5 {
+		return nil, nil, errMalformedMetadata
+	}
+	l := binary.BigEndian.Uint32(b[1:5])
+	if uint64(len(b)-5) < uint64(l) {
+		return nil, nil, errMalformedMetadata
+	}
+	return b[5 : 5+l], b[5+l:], nil
+}
+
+// compressWith compresses b with the provided algorithm and prefixes the result with
+// the algorithm's identifying byte
+func compressWith(ca byte, b []byte) ([]byte, error) {
+	switch ca {
+	case compressionNone:
+		return append([]byte{compressionNone}, b...), nil
+	case compressionBrotli:
+		buf := bytes.NewBuffer([]byte{compressionBrotli})
 		encoder := brotli.NewWriter(buf)
 		encoder.Write(b)
 		encoder.Close()
-		b = buf.Bytes()
-	} else {
-		b = append([]byte{0}, b...)
+		return buf.Bytes(), nil
+	case compressionZstd:
+		out, err := zstd.Encode(b)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte{compressionZstd}, out...), nil
+	case compressionSnappy:
+		out, err := snappy.Encode(b)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte{compressionSnappy}, out...), nil
 	}
+	return nil, errUnknownCompression
+}
 
-	cr <- c.Store(key, b, ttl)
+// decompress inflates b based on the compression byte that was stripped from its head
+func decompress(ca byte, b []byte) ([]byte, error) {
+	switch ca {
+	case compressionNone:
+		return b, nil
+	case compressionBrotli:
+		return io.ReadAll(brotli.NewReader(bytes.NewReader(b)))
+	case compressionZstd:
+		return zstd.Decode(b)
+	case compressionSnappy:
+		return snappy.Decode(b)
+	}
+	return nil, errUnknownCompression
+}
+
+// jitterTTL extends ttl by up to pct percent. The extension is derived from
+// the key, so an object is always stored with the same TTL while objects
+// under different keys are spread across the band.
+func jitterTTL(key string, ttl time.Duration, pct int) time.Duration {
+	if pct <= 0 || ttl <= 0 {
+		return ttl
+	}
+	h := fnv.NewInlineFNV64a()
+	h.Write([]byte(key))
+	const buckets = 10000
+	f := float64(h.Sum64()%buckets) / buckets
+	return ttl + time.Duration(float64(ttl)*float64(pct)/100*f)
 }
 
 // WriteCache writes an HTTPDocument to the cache
@@ -376,9 +670,15 @@ func WriteCache(ctx context.Context, c cache.Cache, key string, d *HTTPDocument,
 
 	rsc := tc.Resources(ctx).(*request.Resources)
 
+	// a response body truncated for exceeding max_response_bytes is never cached
+	if rsc.ResponseTooLarge {
+		return errResponseTooLarge
+	}
+
 	ctx, span := tspan.NewChildSpan(ctx, rsc.Tracer, "WriteCache")
 	if span != nil {
 		defer span.End()
+		tspan.SetAttributes(rsc.Tracer, span, cacheSpanAttributes(rsc, key)...)
 	}
 
 	d.headerLock.Lock()
@@ -387,6 +687,12 @@ func WriteCache(ctx context.Context, c cache.Cache, key string, d *HTTPDocument,
 	h.Del(headers.NameTransferEncoding)
 	h.Del(headers.NameContentRange)
 	h.Del(headers.NameTricksterResult)
+	h.Del(headers.NameServerTiming)
+	if rsc.BackendOptions != nil {
+		for _, n := range rsc.BackendOptions.NonCacheableResponseHeaders {
+			h.Del(n)
+		}
+	}
 	ce := h.Get(headers.NameContentEncoding)
 	d.headerLock.Unlock()
 
@@ -394,7 +700,9 @@ func WriteCache(ctx context.Context, c cache.Cache, key string, d *HTTPDocument,
 	var err error
 	var compress bool
 
-	if (ce == "" || ce == "identity") &&
+	ttl = jitterTTL(key, ttl, c.Configuration().TTLJitterPercent)
+
+	if !isEncoded(ce) && !d.nonCompressible &&
 		(d.CachingPolicy == nil || !d.CachingPolicy.NoTransform) {
 		if mt, _, err := mime.ParseMediaType(d.ContentType); err == nil {
 			if _, ok := compressTypes[mt]; ok {
@@ -407,32 +715,31 @@ func WriteCache(ctx context.Context, c cache.Cache, key string, d *HTTPDocument,
 		if trq := rsc.TimeRangeQuery; trq != nil {
 			// Do timeseries chunking
 			meta := d.GetMeta()
-			// Determine chunk extent and number of chunks
-			var cext timeseries.Extent
-			var csize = trq.Step * time.Duration(c.Configuration().TimeseriesChunkFactor)
-			var cct int
-			cext.Start, cext.End = trq.Extent.Start.Truncate(csize), trq.Extent.End.Truncate(csize).Add(csize)
-			cct = int(cext.End.Sub(cext.Start) / csize)
+			// chunk the full extent of the timeseries, which can be wider than the request's
+			// when the document was read from cache
+			ext := trq.Extent
+			if d.timeseries != nil {
+				if el := d.timeseries.Extents(); len(el) > 0 {
+					ext = timeseries.Extent{Start: el[0].Start, End: el[len(el)-1].End}
+				}
+			}
+			cel := timeseriesChunkExtents(ext, trq.Step, c.Configuration().TimeseriesChunkFactor)
+			// only rewrite the chunks holding changed data, when the changes are known
+			if d.dirtyExtents != nil {
+				cel = overlappingExtents(cel, d.dirtyExtents)
+			}
 			// Prepare buffered results and waitgroup
-			cr := make(chan error, cct+1)
+			cr := make(chan error, len(cel)+1)
 			wg := &sync.WaitGroup{}
-			for chunkStart := cext.Start; chunkStart.Before(cext.End); chunkStart = chunkStart.Add(csize) {
-				// Chunk range (inclusive, on-step)
-				chunkExtent := timeseries.Extent{
-					Start: chunkStart,
-					End:   chunkStart.Add(csize - trq.Step),
-				}
-				// Derive subkey
-				subkey := key + chunkExtent.String()
-				// Query
+			for _, ce := range cel {
 				wg.Add(1)
-				go func() {
+				go func(chunkExtent timeseries.Extent, subkey string) {
 					cd := d.GetTimeseriesChunk(chunkExtent)
 					if c.Configuration().Provider != "memory" {
 						cd.Body, _ = marshal(cd.timeseries, nil, 0)
 					}
 					writeConcurrent(ctx, c, subkey, cd, compress, ttl, cr, wg.Done)
-				}()
+				}(ce, key+ce.String())
 			}
 			// Store metadocument
 			wg.Add(1)
@@ -442,39 +749,30 @@ func WriteCache(ctx context.Context, c cache.Cache, key string, d *HTTPDocument,
 			close(cr)
 			// Handle results
 			for res := range cr {
-				if res != nil && err != nil {
+				if res != nil && err == nil {
 					err = res
 					break
 				}
 			}
 		} else {
-			// Do byterange chunking
-			// Determine chunk start/end and number of chunks
-			drs := d.getByteRanges()
+			// Do byterange chunking of the document's ranges, or of only its dirty ranges
+			// when they are known, since the document's other chunks are unchanged
 			size := c.Configuration().ByterangeChunkSize
-			crs, cre := drs[0].Start, drs[len(drs)-1].End
-			crs = (crs / size) * size
-			cre = (cre/size + 1) * size
-			cct := (cre - crs) / size
+			drs := d.getByteRanges()
+			if d.dirtyRanges != nil {
+				drs = d.dirtyRanges
+			}
+			crl := byterangeChunkRanges(drs, size)
 			// Create meta document
 			meta := d.GetMeta()
 			// Prepare buffered results and waitgroup
-			cr := make(chan error, cct+1)
+			cr := make(chan error, len(crl)+1)
 			wg := &sync.WaitGroup{}
-			// Iterate chunks
-			for chunkStart := crs; chunkStart < cre; chunkStart += size {
-				// Determine chunk range (inclusive)
-				chunkRange := byterange.Range{
-					Start: chunkStart,
-					End:   chunkStart + size - 1,
-				}
-				// Determine subkey
-				subkey := key + chunkRange.String()
-				// Get chunk
+			for _, chunkRange := range crl {
 				cd := d.GetByterangeChunk(chunkRange, size)
 				// Store subdocument
 				wg.Add(1)
-				go writeConcurrent(ctx, c, subkey, cd, compress, ttl, cr, wg.Done)
+				go writeConcurrent(ctx, c, key+chunkRange.String(), cd, compress, ttl, cr, wg.Done)
 			}
 			// Store metadocument
 			wg.Add(1)
@@ -484,7 +782,7 @@ func WriteCache(ctx context.Context, c cache.Cache, key string, d *HTTPDocument,
 			close(cr)
 			// Handle results
 			for res := range cr {
-				if res != nil && err != nil {
+				if res != nil && err == nil {
 					err = res
 					break
 				}
@@ -513,6 +811,11 @@ func WriteCache(ctx context.Context, c cache.Cache, key string, d *HTTPDocument,
 		}
 		return err
 	}
+	size := int64(len(d.Body))
+	if size == 0 {
+		size = d.ContentLength
+	}
+	notifyCacheEvent(rsc, cacheevents.OpStore, key, ttl, size)
 	if span != nil {
 		span.AddEvent(
 			"Cache Write",
@@ -525,6 +828,130 @@ func WriteCache(ctx context.Context, c cache.Cache, key string, d *HTTPDocument,
 
 }
 
+// notifyCacheEvent reports a write to or purge from the backend's cache, when the
+// backend is configured with cache_events
+func notifyCacheEvent(rsc *request.Resources, op, key string, ttl time.Duration,
+	size int64) {
+	if rsc == nil || rsc.BackendOptions == nil {
+		return
+	}
+	rsc.BackendOptions.CacheEventNotifier.Notify(op, key, ttl, size)
+}
+
+// timeseriesChunkExtents returns the extents of the epoch-aligned chunks, each spanning
+// step x factor, that together cover the provided extent. Chunk extents are inclusive
+// and on-step, so each can be used to derive its chunk's cache subkey
+func timeseriesChunkExtents(e timeseries.Extent, step time.Duration,
+	factor int64) timeseries.ExtentList {
+	csize := step * time.Duration(factor)
+	if csize <= 0 {
+		return nil
+	}
+	start, end := e.Start.Truncate(csize), e.End.Truncate(csize).Add(csize)
+	cel := make(timeseries.ExtentList, 0, int(end.Sub(start)/csize))
+	for cs := start; cs.Before(end); cs = cs.Add(csize) {
+		cel = append(cel, timeseries.Extent{Start: cs, End: cs.Add(csize - step)})
+	}
+	return cel
+}
+
+// overlappingExtents returns the extents in el that overlap any extent in with
+func overlappingExtents(el, with timeseries.ExtentList) timeseries.ExtentList {
+	out := make(timeseries.ExtentList, 0, len(el))
+	for _, e := range el {
+		for _, w := range with {
+			if !w.Start.After(e.End) && !w.End.Before(e.Start) {
+				out = append(out, e)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// mergeTimeseriesChunks merges the timeseries chunks read from the cache into a new
+// timeseries, skipping any missing chunks. nil is returned when all chunks are missing
+func mergeTimeseriesChunks(chunks []timeseries.Timeseries,
+	step time.Duration) timeseries.Timeseries {
+	var ts timeseries.Timeseries
+	rest := make([]timeseries.Timeseries, 0, len(chunks))
+	for _, c := range chunks {
+		if c == nil {
+			continue
+		}
+		if ts == nil {
+			// chunks read by reference from a memory cache must not be modified
+			ts = c.Clone()
+			continue
+		}
+		rest = append(rest, c)
+	}
+	if ts == nil {
+		return nil
+	}
+	if len(rest) > 0 {
+		ts.Merge(true, rest...)
+	}
+	ts.SetExtents(ts.Extents().Compress(step))
+	return ts
+}
+
+// byterangeChunkRanges returns the ranges of the size-aligned chunks that overlap any of
+// the provided ranges, in order. Chunk ranges are inclusive, so each can be used to derive
+// its chunk's cache subkey
+func byterangeChunkRanges(rs byterange.Ranges, size int64) byterange.Ranges {
+	if size <= 0 || len(rs) == 0 {
+		return nil
+	}
+	rs = rs.Clone()
+	sort.Sort(rs)
+	crl := make(byterange.Ranges, 0, len(rs))
+	next := int64(-1) // the start of the first chunk not yet included
+	for _, r := range rs {
+		if r.Start < 0 || r.End < r.Start {
+			continue
+		}
+		cs := (r.Start / size) * size
+		if cs < next {
+			cs = next
+		}
+		for ; cs <= r.End; cs += size {
+			crl = append(crl, byterange.Range{Start: cs, End: cs + size - 1})
+		}
+		if cs > next {
+			next = cs
+		}
+	}
+	return crl
+}
+
+// removeByteRanges returns the portions of rs that are not covered by any of remove
+func removeByteRanges(rs, remove byterange.Ranges) byterange.Ranges {
+	out := make(byterange.Ranges, 0, len(rs))
+	for _, r := range rs {
+		parts := byterange.Ranges{r}
+		for _, x := range remove {
+			next := make(byterange.Ranges, 0, len(parts)+1)
+			for _, p := range parts {
+				if x.End < p.Start || x.Start > p.End {
+					next = append(next, p)
+					continue
+				}
+				if x.Start > p.Start {
+					next = append(next, byterange.Range{Start: p.Start, End: x.Start - 1})
+				}
+				if x.End < p.End {
+					next = append(next, byterange.Range{Start: x.End + 1, End: p.End})
+				}
+			}
+			parts = next
+		}
+		out = append(out, parts...)
+	}
+	sort.Sort(out)
+	return out
+}
+
 // DocumentFromHTTPResponse returns an HTTPDocument from the provided
 // HTTP Response and Body
 func DocumentFromHTTPResponse(resp *http.Response, body []byte,
@@ -540,6 +967,7 @@ func DocumentFromHTTPResponse(resp *http.Response, body []byte,
 		d.Headers = resp.Header.Clone()
 		d.headerLock.Unlock()
 	}
+	d.vary = parseVary(resp.Header)
 
 	d.headerLock.Lock()
 	ct := http.Header(d.Headers).Get(headers.NameContentType)
@@ -547,8 +975,9 @@ func DocumentFromHTTPResponse(resp *http.Response, body []byte,
 	if !strings.HasPrefix(ct, headers.ValueMultipartByteRanges) {
 		d.ContentType = ct
 	}
+	d.setContentTypeFlags()
 
-	if d.StatusCode == http.StatusPartialContent && body != nil && len(body) > 0 {
+	if !d.nonRangeable && d.StatusCode == http.StatusPartialContent && body != nil && len(body) > 0 {
 		d.ParsePartialContentBody(resp, body, logger)
 		d.FulfillContentBody()
 	} else {
@@ -557,3 +986,19 @@ func DocumentFromHTTPResponse(resp *http.Response, body []byte,
 
 	return d
 }
+
+// isEncoded returns true if the provided Content Encoding is anything other
+// than identity (e.g., br or gzip)
+func isEncoded(contentEncoding string) bool {
+	return contentEncoding != "" && contentEncoding != "identity"
+}
+
+// isGRPCWeb returns true if the provided Content Type is any gRPC-Web variant
+// (e.g., application/grpc-web+proto or application/grpc-web-text)
+func isGRPCWeb(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mt, headers.ValueApplicationGRPCWeb)
+}
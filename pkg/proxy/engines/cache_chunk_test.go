@@ -22,11 +22,14 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/trickstercache/trickster/v2/cmd/trickster/config"
+	tcache "github.com/trickstercache/trickster/v2/pkg/cache"
 	cr "github.com/trickstercache/trickster/v2/pkg/cache/registration"
+	"github.com/trickstercache/trickster/v2/pkg/cache/status"
 	tc "github.com/trickstercache/trickster/v2/pkg/proxy/context"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/ranges/byterange"
@@ -420,3 +423,175 @@ func TestQueryCacheChunks(t *testing.T) {
 	}
 
 }
+
+func TestLargeObjectRangeRequestChunks(t *testing.T) {
+	conf, _, err := config.Load("trickster", "test", []string{"-origin-url", "http://1", "-provider", "test"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+
+	caches := cr.LoadCachesFromConfig(conf, testLogger)
+	defer cr.CloseCaches(caches)
+	cache, ok := caches["default"]
+	if !ok {
+		t.Fatal("could not load cache")
+	}
+	cache.Configuration().UseCacheChunking = true
+	cache.Configuration().ByterangeChunkSize = 16
+	mc := cache.(tcache.MemoryCache)
+
+	body := []byte(strings.Repeat(testRangeBody, 16))
+	cl := int64(len(body))
+	resp := &http.Response{}
+	resp.Header = make(http.Header)
+	resp.StatusCode = 200
+	resp.Header.Add(headers.NameContentLength, strconv.FormatInt(cl, 10))
+	d := DocumentFromHTTPResponse(resp, body, nil, testLogger)
+	d.ContentType = "text/plain"
+
+	ctx := context.Background()
+	ctx = tc.WithResources(ctx, &request.Resources{BackendOptions: conf.Backends["default"],
+		Tracer: tu.NewTestTracer(), Logger: testLogger})
+
+	err = WriteCache(ctx, cache, "testKey", d, time.Duration(60)*time.Second, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all := byterangeChunkRanges(byterange.Ranges{{Start: 0, End: cl - 1}}, 16)
+	chunks := make(map[string]interface{})
+	for _, r := range all {
+		c, _, err := mc.RetrieveReference("testKey"+r.String(), true)
+		if err != nil {
+			t.Fatalf("expected chunk %s to be cached: %v", r.String(), err)
+		}
+		chunks[r.String()] = c
+	}
+
+	// only the chunks holding the requested range are needed, so a read still
+	// hits with the rest of the object's chunks removed from the cache
+	want := byterange.Range{Start: 100, End: 120}
+	needed := byterangeChunkRanges(byterange.Ranges{want}, 16)
+	if len(needed) != 2 {
+		t.Fatalf("expected %d chunks got %d", 2, len(needed))
+	}
+	for _, r := range all {
+		if r != needed[0] && r != needed[1] {
+			cache.Remove("testKey" + r.String())
+		}
+	}
+
+	d2, ls, deltas, err := QueryCache(ctx, cache, "testKey", byterange.Ranges{want}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ls != status.LookupStatusHit {
+		t.Errorf("expected %s got %s", status.LookupStatusHit, ls)
+	}
+	if len(deltas) != 0 {
+		t.Errorf("expected no deltas got %s", deltas.String())
+	}
+	if v := string(d2.Body[want.Start : want.End+1]); v != string(body[want.Start:want.End+1]) {
+		t.Errorf("expected %s got %s", string(body[want.Start:want.End+1]), v)
+	}
+
+	// a write after the range is fetched only persists the chunks holding that range
+	d2.dirtyRanges = byterange.Ranges{want}
+	err = WriteCache(ctx, cache, "testKey", d2, time.Duration(60)*time.Second, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range all {
+		c, _, err := mc.RetrieveReference("testKey"+r.String(), true)
+		isNeeded := r == needed[0] || r == needed[1]
+		if !isNeeded {
+			if err == nil {
+				t.Errorf("expected chunk %s to not be written", r.String())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("expected chunk %s to be written: %v", r.String(), err)
+		} else if c == chunks[r.String()] {
+			t.Errorf("expected chunk %s to be rewritten", r.String())
+		}
+	}
+
+	// a chunk missing from the cache is a delta to be fetched
+	cache.Remove("testKey" + needed[1].String())
+	_, ls, deltas, err = QueryCache(ctx, cache, "testKey", byterange.Ranges{want}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ls != status.LookupStatusPartialHit {
+		t.Errorf("expected %s got %s", status.LookupStatusPartialHit, ls)
+	}
+	expected := byterange.Ranges{{Start: needed[1].Start, End: want.End}}
+	if !deltas.Equal(expected) {
+		t.Errorf("expected %s got %s", expected.String(), deltas.String())
+	}
+
+	// with none of the needed chunks, the requested range is a range miss
+	cache.Remove("testKey" + needed[0].String())
+	_, ls, deltas, err = QueryCache(ctx, cache, "testKey", byterange.Ranges{want}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ls != status.LookupStatusRangeMiss {
+		t.Errorf("expected %s got %s", status.LookupStatusRangeMiss, ls)
+	}
+	if expected = (byterange.Ranges{want}); !deltas.Equal(expected) {
+		t.Errorf("expected %s got %s", expected.String(), deltas.String())
+	}
+}
+
+func TestByterangeChunkRanges(t *testing.T) {
+
+	tests := []struct {
+		rs       byterange.Ranges
+		size     int64
+		expected byterange.Ranges
+	}{
+		{nil, 10, nil},
+		{byterange.Ranges{{Start: 0, End: 9}}, 0, nil},
+		{byterange.Ranges{{Start: 5, End: 12}}, 10,
+			byterange.Ranges{{Start: 0, End: 9}, {Start: 10, End: 19}}},
+		// chunks in the gaps between ranges are excluded, and shared chunks are not repeated
+		{byterange.Ranges{{Start: 45, End: 47}, {Start: 2, End: 3}, {Start: 8, End: 11}}, 10,
+			byterange.Ranges{{Start: 0, End: 9}, {Start: 10, End: 19}, {Start: 40, End: 49}}},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			crl := byterangeChunkRanges(test.rs, test.size)
+			if crl.String() != test.expected.String() {
+				t.Errorf("expected %s got %s", test.expected.String(), crl.String())
+			}
+		})
+	}
+}
+
+func TestRemoveByteRanges(t *testing.T) {
+
+	tests := []struct {
+		rs, remove, expected byterange.Ranges
+	}{
+		{byterange.Ranges{{Start: 0, End: 99}}, nil, byterange.Ranges{{Start: 0, End: 99}}},
+		{byterange.Ranges{{Start: 0, End: 99}}, byterange.Ranges{{Start: 10, End: 19}},
+			byterange.Ranges{{Start: 0, End: 9}, {Start: 20, End: 99}}},
+		{byterange.Ranges{{Start: 5, End: 14}, {Start: 30, End: 39}},
+			byterange.Ranges{{Start: 0, End: 9}, {Start: 30, End: 39}},
+			byterange.Ranges{{Start: 10, End: 14}}},
+		{byterange.Ranges{{Start: 0, End: 9}}, byterange.Ranges{{Start: 0, End: 19}},
+			byterange.Ranges{}},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			out := removeByteRanges(test.rs, test.remove)
+			if out.String() != test.expected.String() {
+				t.Errorf("expected %s got %s", test.expected.String(), out.String())
+			}
+		})
+	}
+}
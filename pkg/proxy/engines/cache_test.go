@@ -17,6 +17,7 @@
 package engines
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"io"
@@ -29,15 +30,22 @@ import (
 	"time"
 
 	"github.com/trickstercache/trickster/v2/cmd/trickster/config"
+	bo "github.com/trickstercache/trickster/v2/pkg/backends/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache"
 	co "github.com/trickstercache/trickster/v2/pkg/cache/options"
 	cr "github.com/trickstercache/trickster/v2/pkg/cache/registration"
 	"github.com/trickstercache/trickster/v2/pkg/cache/status"
 	"github.com/trickstercache/trickster/v2/pkg/locks"
+	"github.com/trickstercache/trickster/v2/pkg/observability/metrics"
 	tc "github.com/trickstercache/trickster/v2/pkg/proxy/context"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/ranges/byterange"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/request"
 	tu "github.com/trickstercache/trickster/v2/pkg/testutil"
+
+	"github.com/andybalholm/brotli"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 )
 
 const testRangeBody = "This is a test file, to see how the byte range requests work.\n"
@@ -89,6 +97,60 @@ func TestMultiPartByteRange(t *testing.T) {
 	}
 }
 
+func TestIsGRPCWeb(t *testing.T) {
+	tests := []struct {
+		contentType string
+		expected    bool
+	}{
+		{"application/grpc-web", true},
+		{"application/grpc-web+proto", true},
+		{"application/grpc-web-text; charset=utf-8", true},
+		{"application/grpc", false},
+		{"application/json", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		t.Run(test.contentType, func(t *testing.T) {
+			if v := isGRPCWeb(test.contentType); v != test.expected {
+				t.Errorf("expected %t got %t", test.expected, v)
+			}
+		})
+	}
+}
+
+func TestCacheSpanAttributes(t *testing.T) {
+	o := bo.New()
+	o.Name = "test-backend"
+	rsc := &request.Resources{BackendOptions: o, Tracer: tu.NewTestTracer()}
+
+	attrs := cacheSpanAttributes(rsc, "test-key")
+	if len(attrs) != 2 {
+		t.Fatalf("expected %d got %d", 2, len(attrs))
+	}
+	if attrs[0].Key != "backend.name" || attrs[0].Value.AsString() != "test-backend" {
+		t.Errorf("unexpected attribute %s=%s", attrs[0].Key, attrs[0].Value.AsString())
+	}
+	if attrs[1].Key != "cache.key" || attrs[1].Value.AsString() != "test-key" {
+		t.Errorf("unexpected attribute %s=%s", attrs[1].Key, attrs[1].Value.AsString())
+	}
+
+	rsc.Tracer.Options.CacheKeyMode = "truncate"
+	rsc.Tracer.Options.CacheKeyMaxLength = 4
+	attrs = cacheSpanAttributes(rsc, "test-key")
+	if len(attrs) != 2 || attrs[1].Value.AsString() != "test" {
+		t.Errorf("expected truncated cache key %s", "test")
+	}
+
+	rsc.Tracer.Options.OmitCacheKeys = true
+	attrs = cacheSpanAttributes(rsc, "test-key")
+	if len(attrs) != 1 {
+		t.Fatalf("expected %d got %d", 1, len(attrs))
+	}
+	if attrs[0].Key != "backend.name" {
+		t.Errorf("expected %s got %s", "backend.name", attrs[0].Key)
+	}
+}
+
 func TestCacheHitRangeRequest(t *testing.T) {
 	expected := "is a "
 	conf, _, err := config.Load("trickster", "test", []string{"-origin-url", "http://1", "-provider", "test"})
@@ -391,6 +453,10 @@ func TestQueryCache(t *testing.T) {
 		t.Error(err)
 	}
 
+	hits := metrics.CacheLookups.WithLabelValues("default", "default", status.LookupStatusHit.String())
+	misses := metrics.CacheLookups.WithLabelValues("default", "default", status.LookupStatusKeyMiss.String())
+	hitCount, missCount := counterValue(hits), counterValue(misses)
+
 	d2, _, _, err := QueryCache(ctx, cache, "testKey", nil, nil)
 	if err != nil {
 		t.Error(err)
@@ -409,6 +475,13 @@ func TestQueryCache(t *testing.T) {
 		t.Errorf("expected error")
 	}
 
+	if v := counterValue(hits); v != hitCount+1 {
+		t.Errorf("expected %f got %f", hitCount+1, v)
+	}
+	if v := counterValue(misses); v != missCount+1 {
+		t.Errorf("expected %f got %f", missCount+1, v)
+	}
+
 	// test marshaling route by making our cache not appear to be a memory cache
 	cache.Remove("testKey")
 	cache.Configuration().Provider = "test"
@@ -423,6 +496,13 @@ func TestQueryCache(t *testing.T) {
 		t.Error(err)
 	}
 
+	// serialized objects should have their compression ratio observed
+	ch := make(chan prometheus.Metric, 64)
+	metrics.CacheCompressionRatio.Collect(ch)
+	if len(ch) == 0 {
+		t.Error("expected compression ratio to be observed")
+	}
+
 	d2, _, _, err = QueryCache(ctx, cache, "testKey", nil, nil)
 	if err != nil {
 		t.Error(err)
@@ -438,6 +518,271 @@ func TestQueryCache(t *testing.T) {
 
 }
 
+func TestWriteCacheMaxCacheableObjectBytes(t *testing.T) {
+
+	conf, _, err := config.Load("trickster", "test", []string{"-origin-url", "http://1", "-provider", "test"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+
+	caches := cr.LoadCachesFromConfig(conf, testLogger)
+	defer cr.CloseCaches(caches)
+	cache, ok := caches["default"]
+	if !ok {
+		t.Errorf("Could not find default configuration")
+	}
+
+	resp := &http.Response{Header: make(http.Header), StatusCode: 200}
+	d := DocumentFromHTTPResponse(resp, []byte("1234"), nil, testLogger)
+	d.ContentType = "text/plain"
+
+	o := conf.Backends["default"]
+	ctx := tc.WithResources(context.Background(), &request.Resources{BackendOptions: o,
+		Tracer: tu.NewTestTracer(), Logger: testLogger})
+
+	// the memory cache checks the document's estimated size
+	o.MaxCacheableObjectBytes = int64(d.Size()) - 1
+	err = WriteCache(ctx, cache, "testKey", d, time.Second*60, nil, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	if _, _, _, err = QueryCache(ctx, cache, "testKey", nil, nil); err == nil {
+		t.Error("expected error for uncached document")
+	}
+
+	o.MaxCacheableObjectBytes = int64(d.Size())
+	err = WriteCache(ctx, cache, "testKey", d, time.Second*60, nil, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	if _, _, _, err = QueryCache(ctx, cache, "testKey", nil, nil); err != nil {
+		t.Error(err)
+	}
+
+	// other caches check the document's serialized size
+	cache.Remove("testKey")
+	cache.Configuration().Provider = "test"
+	b, _ := d.MarshalMsg(nil)
+	o.MaxCacheableObjectBytes = int64(len(b)) - 1
+	err = WriteCache(ctx, cache, "testKey", d, time.Second*60, nil, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	if _, _, _, err = QueryCache(ctx, cache, "testKey", nil, nil); err == nil {
+		t.Error("expected error for uncached document")
+	}
+
+	o.MaxCacheableObjectBytes = int64(len(b))
+	err = WriteCache(ctx, cache, "testKey", d, time.Second*60, nil, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	d2, _, _, err := QueryCache(ctx, cache, "testKey", nil, nil)
+	if err != nil {
+		t.Error(err)
+	} else if string(d2.Body) != "1234" {
+		t.Errorf("expected %s got %s", "1234", string(d2.Body))
+	}
+}
+
+// setupSeparateMetadataCache returns a cache and context for serialized cache
+// objects, with separate metadata records enabled
+func setupSeparateMetadataCache(tb testing.TB) (context.Context, cache.Cache, func()) {
+	conf, _, err := config.Load("trickster", "test", []string{"-origin-url", "http://1", "-provider", "test"})
+	if err != nil {
+		tb.Fatalf("Could not load configuration: %s", err.Error())
+	}
+	caches := cr.LoadCachesFromConfig(conf, testLogger)
+	c, ok := caches["default"]
+	if !ok {
+		tb.Fatal("Could not find default configuration")
+	}
+	// make the cache not appear to be a memory cache, so objects are serialized
+	c.Configuration().Provider = "test"
+	c.Configuration().SeparateMetadata = true
+	ctx := tc.WithResources(context.Background(), &request.Resources{BackendOptions: conf.Backends["default"],
+		Tracer: tu.NewTestTracer(), Logger: testLogger})
+	return ctx, c, func() { cr.CloseCaches(caches) }
+}
+
+func newSeparateMetadataTestDocument(body []byte) *HTTPDocument {
+	resp := &http.Response{Header: make(http.Header), StatusCode: 200}
+	resp.Header.Set(headers.NameETag, `"abc"`)
+	resp.Header.Set(headers.NameLastModified, "Mon, 02 Jan 2006 15:04:05 GMT")
+	resp.ContentLength = int64(len(body))
+	d := DocumentFromHTTPResponse(resp, body, &CachingPolicy{ETag: `"abc"`}, testLogger)
+	d.ContentType = "text/plain"
+	return d
+}
+
+func TestQueryCacheMetadata(t *testing.T) {
+
+	ctx, c, closer := setupSeparateMetadataCache(t)
+	defer closer()
+
+	const expected = "1234"
+	ct := map[string]interface{}{"text/plain": true}
+	d := newSeparateMetadataTestDocument([]byte(expected))
+	err := WriteCache(ctx, c, "testKey", d, time.Second*60, ct, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, _, _ := c.Retrieve("testKey", false)
+	if len(b) == 0 || b[0] != layoutSeparateMetadata {
+		t.Fatal("expected object stored with a metadata record")
+	}
+
+	d2, ls, err := QueryCacheMetadata(ctx, c, "testKey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ls != status.LookupStatusHit {
+		t.Errorf("expected %s got %s", status.LookupStatusHit, ls)
+	}
+	if d2.StatusCode != 200 || d2.ContentLength != 4 || len(d2.Body) != 0 {
+		t.Errorf("unexpected metadata document %d %d %d", d2.StatusCode, d2.ContentLength, len(d2.Body))
+	}
+	if v := http.Header(d2.Headers).Get(headers.NameETag); v != `"abc"` {
+		t.Errorf("expected %s got %s", `"abc"`, v)
+	}
+	if d2.CachingPolicy == nil || d2.CachingPolicy.ETag != `"abc"` {
+		t.Error("expected caching policy in metadata document")
+	}
+
+	// the full document is still readable
+	d2, _, _, err = QueryCache(ctx, c, "testKey", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(d2.Body) != expected {
+		t.Errorf("expected %s got %s", expected, string(d2.Body))
+	}
+
+	// objects stored in the original layout are fully decoded
+	c.Configuration().SeparateMetadata = false
+	err = WriteCache(ctx, c, "testKey", d, time.Second*60, ct, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d2, _, err = QueryCacheMetadata(ctx, c, "testKey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(d2.Body) != expected {
+		t.Errorf("expected %s got %s", expected, string(d2.Body))
+	}
+
+	_, ls, err = QueryCacheMetadata(ctx, c, "testKey2")
+	if err == nil || ls != status.LookupStatusKeyMiss {
+		t.Errorf("expected %s got %s", status.LookupStatusKeyMiss, ls)
+	}
+
+	c.Store("testKey", []byte{layoutSeparateMetadata, 0, 0, 0, 9, 1}, time.Second*60)
+	if _, _, err = QueryCacheMetadata(ctx, c, "testKey"); err != errMalformedMetadata {
+		t.Errorf("expected %v got %v", errMalformedMetadata, err)
+	}
+	if _, _, _, err = QueryCache(ctx, c, "testKey", nil, nil); err != errMalformedMetadata {
+		t.Errorf("expected %v got %v", errMalformedMetadata, err)
+	}
+}
+
+func TestQueryCacheMetadataPartialContent(t *testing.T) {
+
+	ctx, c, closer := setupSeparateMetadataCache(t)
+	defer closer()
+
+	ct := map[string]interface{}{"text/plain": true}
+	d := newSeparateMetadataTestDocument([]byte("1234"))
+	d.Ranges = byterange.Ranges{byterange.Range{Start: 0, End: 1}}
+	err := WriteCache(ctx, c, "testKey", d, time.Second*60, ct, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hits := metrics.CacheLookups.WithLabelValues("default", "default", status.LookupStatusHit.String())
+	partials := metrics.CacheLookups.WithLabelValues("default", "default",
+		status.LookupStatusPartialHit.String())
+	hitCount, partialCount := counterValue(hits), counterValue(partials)
+
+	d2, ls, delta, err := queryCacheMetadata(ctx, c, "testKey", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ls != status.LookupStatusPartialHit {
+		t.Errorf("expected %s got %s", status.LookupStatusPartialHit, ls)
+	}
+	expected := byterange.Ranges{byterange.Range{Start: 2, End: 3}}
+	if !delta.Equal(expected) {
+		t.Errorf("expected %s got %s", expected, delta)
+	}
+	if !d2.isFulfillment || d2.encodedBody != nil || len(d2.StoredRangeParts) != 1 {
+		t.Error("expected the body of the partial document to be decoded")
+	}
+
+	// the object is looked up only once
+	if v := counterValue(hits); v != hitCount {
+		t.Errorf("expected %f got %f", hitCount, v)
+	}
+	if v := counterValue(partials); v != partialCount+1 {
+		t.Errorf("expected %f got %f", partialCount+1, v)
+	}
+}
+
+func TestSplitMetadata(t *testing.T) {
+
+	b := withMetadata([]byte("meta"), []byte{compressionNone, 'x'})
+	meta, obj, err := splitMetadata(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(meta) != "meta" || !bytes.Equal(obj, []byte{compressionNone, 'x'}) {
+		t.Errorf("unexpected split %q %q", meta, obj)
+	}
+
+	// objects in the original layout are returned unchanged
+	in := []byte{compressionBrotli, 1, 2}
+	meta, obj, err = splitMetadata(in)
+	if err != nil || meta != nil || !bytes.Equal(obj, in) {
+		t.Errorf("unexpected split %q %q %v", meta, obj, err)
+	}
+
+	if _, _, err = splitMetadata([]byte{layoutSeparateMetadata, 0}); err != errMalformedMetadata {
+		t.Errorf("expected %v got %v", errMalformedMetadata, err)
+	}
+}
+
+func benchmarkQueryCacheLargeObject(b *testing.B, metadataOnly bool) {
+	ctx, c, closer := setupSeparateMetadataCache(b)
+	defer closer()
+	body := []byte(strings.Repeat("trickster metadata benchmark body ", 1<<15))
+	d := newSeparateMetadataTestDocument(body)
+	err := WriteCache(ctx, c, "benchKey", d, time.Second*60,
+		map[string]interface{}{"text/plain": true}, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if metadataOnly {
+			_, _, err = QueryCacheMetadata(ctx, c, "benchKey")
+		} else {
+			_, _, _, err = QueryCache(ctx, c, "benchKey", nil, nil)
+		}
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkQueryCacheFullDocument(b *testing.B) {
+	benchmarkQueryCacheLargeObject(b, false)
+}
+
+func BenchmarkQueryCacheMetadataOnly(b *testing.B) {
+	benchmarkQueryCacheLargeObject(b, true)
+}
+
 // Mock Cache for testing error conditions
 type testCache struct {
 	configuration *co.Options
@@ -465,3 +810,105 @@ func (tc *testCache) Close() error                              { return errTest
 func (tc *testCache) Configuration() *co.Options                { return tc.configuration }
 func (tc *testCache) Locker() locks.NamedLocker                 { return tc.locker }
 func (tc *testCache) SetLocker(l locks.NamedLocker)             { tc.locker = l }
+
+func TestCompressionRoundTrip(t *testing.T) {
+
+	in := []byte(strings.Repeat("trickster compression round trip test ", 100))
+
+	for _, ca := range []byte{compressionNone, compressionBrotli, compressionZstd, compressionSnappy} {
+		t.Run(strconv.Itoa(int(ca)), func(t *testing.T) {
+			b, err := compressWith(ca, in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b[0] != ca {
+				t.Errorf("expected %d got %d", ca, b[0])
+			}
+			out, err := decompress(b[0], b[1:])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(in, out) {
+				t.Error("round trip output does not match input")
+			}
+		})
+	}
+
+	_, err := compressWith(255, in)
+	if err != errUnknownCompression {
+		t.Errorf("expected %v got %v", errUnknownCompression, err)
+	}
+
+	_, err = decompress(255, in)
+	if err != errUnknownCompression {
+		t.Errorf("expected %v got %v", errUnknownCompression, err)
+	}
+}
+
+func TestDecompressLegacyBlob(t *testing.T) {
+
+	in := []byte("legacy cache object")
+
+	// objects written before the compression byte became an enum
+	// were prefixed with 1 and brotli-compressed
+	buf := bytes.NewBuffer([]byte{1})
+	encoder := brotli.NewWriter(buf)
+	encoder.Write(in)
+	encoder.Close()
+	b := buf.Bytes()
+
+	out, err := decompress(b[0], b[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(in, out) {
+		t.Errorf("expected %s got %s", string(in), string(out))
+	}
+}
+
+func TestJitterTTL(t *testing.T) {
+
+	const ttl = 100 * time.Second
+	const pct = 20
+	max := ttl + ttl*pct/100
+
+	if d := jitterTTL("key", ttl, 0); d != ttl {
+		t.Errorf("expected %s got %s", ttl, d)
+	}
+
+	lo, hi := max, ttl
+	seen := make(map[time.Duration]struct{})
+	for i := 0; i < 1000; i++ {
+		key := "key-" + strconv.Itoa(i)
+		d := jitterTTL(key, ttl, pct)
+		if d < ttl || d > max {
+			t.Fatalf("ttl %s for %s outside of [%s, %s]", d, key, ttl, max)
+		}
+		if d2 := jitterTTL(key, ttl, pct); d2 != d {
+			t.Errorf("expected consistent ttl for %s: %s != %s", key, d, d2)
+		}
+		if d < lo {
+			lo = d
+		}
+		if d > hi {
+			hi = d
+		}
+		seen[d] = struct{}{}
+	}
+
+	// the ttls should be spread across most of the band
+	band := max - ttl
+	if lo > ttl+band/10 || hi < max-band/10 {
+		t.Errorf("expected ttls to spread across [%s, %s], got [%s, %s]", ttl, max, lo, hi)
+	}
+	if len(seen) < 900 {
+		t.Errorf("expected at least %d distinct ttls got %d", 900, len(seen))
+	}
+}
+
+// counterValue returns the current value of the counter
+func counterValue(c prometheus.Counter) float64 {
+	m := &dto.Metric{}
+	c.Write(m)
+	return m.GetCounter().GetValue()
+}
@@ -23,6 +23,7 @@ import (
 	"strings"
 	"time"
 
+	bo "github.com/trickstercache/trickster/v2/pkg/backends/options"
 	"github.com/trickstercache/trickster/v2/pkg/cache/status"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
 )
@@ -44,7 +45,9 @@ type CachingPolicy struct {
 	HasIfNoneMatch       bool `msg:"-"`
 	IfNoneMatchResult    bool `msg:"-"`
 
-	FreshnessLifetime int `msg:"freshness_lifetime"`
+	FreshnessLifetime    int `msg:"freshness_lifetime"`
+	StaleWhileRevalidate int `msg:"stale_while_revalidate"`
+	StaleIfError         int `msg:"stale_if_error"`
 
 	LastModified time.Time `msg:"last_modified"`
 	Expires      time.Time `msg:"expires"`
@@ -65,6 +68,8 @@ func (cp *CachingPolicy) Clone() *CachingPolicy {
 		NoCache:               cp.NoCache,
 		NoTransform:           cp.NoTransform,
 		FreshnessLifetime:     cp.FreshnessLifetime,
+		StaleWhileRevalidate:  cp.StaleWhileRevalidate,
+		StaleIfError:          cp.StaleIfError,
 		CanRevalidate:         cp.CanRevalidate,
 		MustRevalidate:        cp.MustRevalidate,
 		LastModified:          cp.LastModified,
@@ -116,6 +121,8 @@ func (cp *CachingPolicy) Merge(src *CachingPolicy) {
 
 	cp.IsFresh = src.IsFresh
 	cp.FreshnessLifetime = src.FreshnessLifetime
+	cp.StaleWhileRevalidate = src.StaleWhileRevalidate
+	cp.StaleIfError = src.StaleIfError
 	cp.CanRevalidate = src.CanRevalidate
 	cp.MustRevalidate = src.MustRevalidate
 	cp.LastModified = src.LastModified
@@ -129,12 +136,29 @@ func (cp *CachingPolicy) Merge(src *CachingPolicy) {
 
 }
 
-// TTL returns a TTL based on the subject caching policy and the provided multiplier and max values
-func (cp *CachingPolicy) TTL(multiplier float64, max time.Duration) time.Duration {
+// TTL returns a TTL based on the subject caching policy and the provided multiplier and
+// min and max values. The min value is not applied to negative cache policies, whose TTL
+// is configured rather than derived from the origin's response
+func (cp *CachingPolicy) TTL(multiplier float64, min, max time.Duration) time.Duration {
 	var ttl time.Duration = time.Duration(cp.FreshnessLifetime) * time.Second
 	if cp.CanRevalidate {
 		ttl *= time.Duration(multiplier)
 	}
+	// keep the object around long enough to be served during its stale-while-revalidate window
+	if cp.StaleWhileRevalidate > 0 {
+		if swr := time.Duration(cp.FreshnessLifetime+cp.StaleWhileRevalidate) * time.Second; swr > ttl {
+			ttl = swr
+		}
+	}
+	// and its stale-if-error window
+	if cp.StaleIfError > 0 {
+		if sie := time.Duration(cp.FreshnessLifetime+cp.StaleIfError) * time.Second; sie > ttl {
+			ttl = sie
+		}
+	}
+	if ttl < min && !cp.IsNegativeCache {
+		ttl = min
+	}
 	if ttl > max {
 		ttl = max
 	}
@@ -151,6 +175,51 @@ func (cp *CachingPolicy) String() string {
 		cp.IfNoneMatchValue, cp.IfModifiedSinceTime.Unix(), cp.IfUnmodifiedSinceTime.Unix(), cp.IsNegativeCache)
 }
 
+// getBackendResponseCachingPolicy returns the caching policy for an upstream
+// response, checking the Backend's header-predicated negative cache entries
+// before its status code entries and the response's caching headers. The Backend's
+// non-cacheable response headers are removed before the document is cached, so they
+// do not affect its cacheability (e.g., a Set-Cookie header does not prevent caching)
+func getBackendResponseCachingPolicy(o *bo.Options, resp *http.Response) *CachingPolicy {
+	if d, ok := o.NegativeCacheHeaderRules.Match(resp.StatusCode, resp.Header); ok {
+		cp := &CachingPolicy{LocalDate: time.Now()}
+		cp.setNegativeCacheTTL(d)
+		return cp
+	}
+	h := resp.Header
+	if len(o.NonCacheableResponseHeaders) > 0 {
+		h = h.Clone()
+		for _, n := range o.NonCacheableResponseHeaders {
+			h.Del(n)
+		}
+	}
+	return GetResponseCachingPolicy(resp.StatusCode, o.NegativeCache, h)
+}
+
+// cacheBypassRequested returns true if the client request should skip the cache read,
+// either via the Backend's cache bypass header, or via a no-cache request caching policy
+// when the Backend is configured to refresh on client no-cache. The caller should clear
+// the policy's NoCache flag so the fresh response can still be written to the cache
+func cacheBypassRequested(o *bo.Options, h http.Header, cp *CachingPolicy) bool {
+	if o == nil {
+		return false
+	}
+	if o.RefreshOnClientNoCache && cp != nil && cp.NoCache {
+		return true
+	}
+	if !o.AllowCacheBypassHeader || o.CacheBypassHeader == "" {
+		return false
+	}
+	b, _ := strconv.ParseBool(h.Get(o.CacheBypassHeader))
+	return b
+}
+
+func (cp *CachingPolicy) setNegativeCacheTTL(d time.Duration) {
+	cp.FreshnessLifetime = int(d.Seconds())
+	cp.Expires = cp.LocalDate.Add(d)
+	cp.IsNegativeCache = true
+}
+
 // GetResponseCachingPolicy examines HTTP response headers for caching headers
 // a returns a CachingPolicy reference
 func GetResponseCachingPolicy(code int, negativeCache map[int]time.Duration, h http.Header) *CachingPolicy {
@@ -158,9 +227,7 @@ func GetResponseCachingPolicy(code int, negativeCache map[int]time.Duration, h h
 	cp := &CachingPolicy{LocalDate: time.Now()}
 
 	if d, ok := negativeCache[code]; ok {
-		cp.FreshnessLifetime = int(d.Seconds())
-		cp.Expires = cp.LocalDate.Add(d)
-		cp.IsNegativeCache = true
+		cp.setNegativeCacheTTL(d)
 		return cp
 	}
 
@@ -172,6 +239,13 @@ func GetResponseCachingPolicy(code int, negativeCache map[int]time.Duration, h h
 		return cp
 	}
 
+	// Do not cache content that varies on aspects of the request other than its headers
+	if isVaryWildcard(h) {
+		cp.NoCache = true
+		cp.FreshnessLifetime = -1
+		return cp
+	}
+
 	// Cache-Control has first precedence
 	if v := h.Get(headers.NameCacheControl); v != "" {
 		cp.parseCacheControlDirectives(v)
@@ -240,7 +314,7 @@ func GetResponseCachingPolicy(code int, negativeCache map[int]time.Duration, h h
 	}
 
 	if hasLastModified {
-		lm, err := time.Parse(time.RFC1123, lastModifiedHeader)
+		lm, err := parseHTTPDate(lastModifiedHeader)
 		if err != nil {
 			cp.CanRevalidate = false
 			cp.FreshnessLifetime = -1
@@ -310,6 +384,18 @@ func (cp *CachingPolicy) parseCacheControlDirectives(directives string) {
 			cp.MustRevalidate = true
 			cp.FreshnessLifetime = 0
 		}
+		if d == headers.ValueStaleWhileRevalidate && dsub != "" {
+			secs, err := strconv.Atoi(dsub)
+			if err == nil && secs > 0 {
+				cp.StaleWhileRevalidate = secs
+			}
+		}
+		if d == headers.ValueStaleIfError && dsub != "" {
+			secs, err := strconv.Atoi(dsub)
+			if err == nil && secs > 0 {
+				cp.StaleIfError = secs
+			}
+		}
 		if d == headers.ValueNoTransform {
 			cp.NoTransform = true
 		}
@@ -342,14 +428,15 @@ func GetRequestCachingPolicy(h http.Header) *CachingPolicy {
 		}
 	}
 
+	// malformed conditional dates are ignored, so the request is handled as unconditional
 	if v := h.Get(headers.NameIfModifiedSince); v != "" {
-		if date, err := time.Parse(time.RFC1123, v); err == nil {
+		if date, err := parseHTTPDate(v); err == nil {
 			cp.IfModifiedSinceTime = date
 		}
 	}
 
 	if v := h.Get(headers.NameIfUnmodifiedSince); v != "" {
-		if date, err := time.Parse(time.RFC1123, v); err == nil {
+		if date, err := parseHTTPDate(v); err == nil {
 			cp.IfUnmodifiedSinceTime = date
 		}
 	}
@@ -361,12 +448,23 @@ func GetRequestCachingPolicy(h http.Header) *CachingPolicy {
 	return cp
 }
 
+// parseHTTPDate parses an HTTP-date in any of the formats permitted by RFC 7231,
+// as well as RFC 1123 dates with a zone other than GMT
+func parseHTTPDate(v string) (time.Time, error) {
+	if t, err := http.ParseTime(v); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC1123, v)
+}
+
 // ResolveClientConditionals ensures any client conditionals are handled before
 // responding to the client request
 func (cp *CachingPolicy) ResolveClientConditionals(ls status.LookupStatus) {
 
 	cp.IsClientFresh = false
-	if !cp.IsClientConditional {
+	// conditionals only apply to successful responses, so a negative-cached error
+	// response is always served in full, rather than as a 304 Not Modified
+	if !cp.IsClientConditional || cp.IsNegativeCache {
 		return
 	}
 
@@ -376,7 +474,9 @@ func (cp *CachingPolicy) ResolveClientConditionals(ls status.LookupStatus) {
 		isClientFresh = isClientFresh && !cp.IfNoneMatchResult
 	}
 	if cp.HasIfModifiedSince {
-		isClientFresh = isClientFresh && !cp.LastModified.After(cp.IfModifiedSinceTime)
+		// a document without a Last-Modified value can't be shown to be unmodified
+		isClientFresh = isClientFresh && !cp.LastModified.IsZero() &&
+			!cp.LastModified.After(cp.IfModifiedSinceTime)
 	}
 	if cp.HasIfUnmodifiedSince {
 		isClientFresh = isClientFresh && cp.LastModified.After(cp.IfUnmodifiedSinceTime)
@@ -393,7 +493,9 @@ func (cp *CachingPolicy) ParseClientConditionals() {
 }
 
 // CheckIfNoneMatch determines if the provided match value satisfies an "If-None-Match"
-// condition against the cached object. As Trickster is a cache, matching is always weak.
+// condition against the cached object. As Trickster is a cache, matching is always weak,
+// meaning the weakness indicator and quotes are disregarded on both the cached ETag
+// and the client-provided values when comparing them.
 func CheckIfNoneMatch(etag string, headerValue string, ls status.LookupStatus) bool {
 
 	if etag == "" || headerValue == "" {
@@ -401,25 +503,33 @@ func CheckIfNoneMatch(etag string, headerValue string, ls status.LookupStatus) b
 	}
 
 	if headerValue == "*" {
-		if ls == status.LookupStatusHit || ls == status.LookupStatusRevalidated {
+		if ls == status.LookupStatusHit || ls == status.LookupStatusRevalidated ||
+			ls == status.LookupStatusStaleWhileRevalidate {
 			return false
 		}
 		return true
 	}
 
+	etag = opaqueETag(etag)
 	parts := strings.Split(headerValue, ",")
 	for _, p := range parts {
-		p = strings.Trim(p, " ")
-		if len(p) > 3 && p[1:2] == "/" {
-			p = p[2:]
-		}
-		if len(p) > 1 && strings.HasPrefix(p, `"`) && strings.HasSuffix(p, `"`) {
-			p = p[1 : len(p)-1]
-		}
-		if p == etag {
+		if opaqueETag(p) == etag {
 			return false
 		}
 	}
 
 	return true
 }
+
+// opaqueETag returns the opaque tag of the provided entity tag, without any
+// weakness indicator (W/) or surrounding quotes, for use in weak comparisons
+func opaqueETag(etag string) string {
+	etag = strings.Trim(etag, " ")
+	if len(etag) > 3 && etag[1:2] == "/" {
+		etag = etag[2:]
+	}
+	if len(etag) > 1 && strings.HasPrefix(etag, `"`) && strings.HasSuffix(etag, `"`) {
+		etag = etag[1 : len(etag)-1]
+	}
+	return etag
+}
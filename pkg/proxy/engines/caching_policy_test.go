@@ -22,6 +22,8 @@ import (
 	"testing"
 	"time"
 
+	bo "github.com/trickstercache/trickster/v2/pkg/backends/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache/negative"
 	"github.com/trickstercache/trickster/v2/pkg/cache/status"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
 )
@@ -197,6 +199,81 @@ func TestGetResponseCachingPolicy(t *testing.T) {
 	}
 }
 
+func TestGetResponseCachingPolicyStaleWhileRevalidate(t *testing.T) {
+	h := http.Header{
+		headers.NameCacheControl: []string{headers.ValueMaxAge + "=60, " +
+			headers.ValueStaleWhileRevalidate + "=30"},
+	}
+	p := GetResponseCachingPolicy(200, nil, h)
+	if p.FreshnessLifetime != 60 {
+		t.Errorf("expected %d got %d", 60, p.FreshnessLifetime)
+	}
+	if p.StaleWhileRevalidate != 30 {
+		t.Errorf("expected %d got %d", 30, p.StaleWhileRevalidate)
+	}
+	if ttl := p.TTL(1, 0, time.Hour); ttl != 90*time.Second {
+		t.Errorf("expected %s got %s", 90*time.Second, ttl)
+	}
+	if ttl := p.TTL(1, 0, time.Minute); ttl != time.Minute {
+		t.Errorf("expected %s got %s", time.Minute, ttl)
+	}
+}
+
+func TestCachingPolicyTTLBounds(t *testing.T) {
+
+	tests := []struct {
+		maxAge   int
+		expected time.Duration
+	}{
+		{31536000, 24 * time.Hour}, // above the ceiling
+		{3600, time.Hour},          // within the bounds
+		{5, time.Minute},           // below the minimum
+	}
+
+	for i, test := range tests {
+		h := http.Header{headers.NameCacheControl: []string{headers.ValueMaxAge + "=" +
+			strconv.Itoa(test.maxAge)}}
+		p := GetResponseCachingPolicy(200, nil, h)
+		if ttl := p.TTL(2, time.Minute, 24*time.Hour); ttl != test.expected {
+			t.Errorf("test %d expected %s got %s", i, test.expected, ttl)
+		}
+	}
+
+	// the minimum is not applied to negative cache policies
+	p := &CachingPolicy{FreshnessLifetime: 5, IsNegativeCache: true}
+	if ttl := p.TTL(1, time.Minute, 24*time.Hour); ttl != 5*time.Second {
+		t.Errorf("expected %s got %s", 5*time.Second, ttl)
+	}
+}
+
+func TestGetResponseCachingPolicyStaleIfError(t *testing.T) {
+	h := http.Header{
+		headers.NameCacheControl: []string{headers.ValueMaxAge + "=60, " +
+			headers.ValueStaleWhileRevalidate + "=30, " + headers.ValueStaleIfError + "=120"},
+	}
+	p := GetResponseCachingPolicy(200, nil, h)
+	if p.StaleIfError != 120 {
+		t.Errorf("expected %d got %d", 120, p.StaleIfError)
+	}
+	if ttl := p.TTL(1, 0, time.Hour); ttl != 180*time.Second {
+		t.Errorf("expected %s got %s", 180*time.Second, ttl)
+	}
+	if p2 := p.Clone(); p2.StaleIfError != 120 {
+		t.Errorf("expected %d got %d", 120, p2.StaleIfError)
+	}
+	b, err := p.MarshalMsg(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2 := &CachingPolicy{}
+	if _, err = p2.UnmarshalMsg(b); err != nil {
+		t.Fatal(err)
+	}
+	if p2.StaleIfError != 120 || p2.StaleWhileRevalidate != 30 {
+		t.Errorf("expected %d got %d", 120, p2.StaleIfError)
+	}
+}
+
 func TestResolveClientConditionalsIUS(t *testing.T) {
 
 	cp := &CachingPolicy{
@@ -213,6 +290,56 @@ func TestResolveClientConditionalsIUS(t *testing.T) {
 
 }
 
+func TestResolveClientConditionalsIMS(t *testing.T) {
+
+	lm := time.Unix(1560694744, 0).UTC()
+
+	tests := []struct {
+		ims          string
+		lastModified time.Time
+		isFresh      bool
+	}{
+		{ // 0 - modified since
+			ims:          lm.Add(-time.Hour).Format(http.TimeFormat),
+			lastModified: lm,
+		},
+		{ // 1 - not modified since
+			ims:          lm.Format(http.TimeFormat),
+			lastModified: lm,
+			isFresh:      true,
+		},
+		{ // 2 - not modified since, RFC 850 date
+			ims:          lm.Format(time.RFC850),
+			lastModified: lm,
+			isFresh:      true,
+		},
+		{ // 3 - not modified since, ANSI C date
+			ims:          lm.Format(time.ANSIC),
+			lastModified: lm,
+			isFresh:      true,
+		},
+		{ // 4 - malformed date
+			ims:          "not a date",
+			lastModified: lm,
+		},
+		{ // 5 - no Last-Modified
+			ims: lm.Format(http.TimeFormat),
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			cp := GetRequestCachingPolicy(http.Header{headers.NameIfModifiedSince: []string{test.ims}})
+			cp.ParseClientConditionals()
+			cp.LastModified = test.lastModified
+			cp.ResolveClientConditionals(status.LookupStatusHit)
+			if cp.IsClientFresh != test.isFresh {
+				t.Errorf("expected %t got %t", test.isFresh, cp.IsClientFresh)
+			}
+		})
+	}
+}
+
 func TestGetResponseCachingPolicyNegativeCache(t *testing.T) {
 	p := GetResponseCachingPolicy(400, map[int]time.Duration{400: 300 * time.Second}, nil)
 	if p.FreshnessLifetime != 300 {
@@ -220,6 +347,63 @@ func TestGetResponseCachingPolicyNegativeCache(t *testing.T) {
 	}
 }
 
+func TestGetBackendResponseCachingPolicy(t *testing.T) {
+	o := bo.New()
+	o.NegativeCache = map[int]time.Duration{404: 30 * time.Second}
+	o.NegativeCacheHeaderRules = negative.HeaderRules{
+		{Code: 200, Header: "X-Upstream-Error", Value: "true", TTL: 5 * time.Second},
+	}
+
+	resp := &http.Response{StatusCode: 200,
+		Header: http.Header{"X-Upstream-Error": []string{"true"}}}
+	p := getBackendResponseCachingPolicy(o, resp)
+	if !p.IsNegativeCache || p.FreshnessLifetime != 5 {
+		t.Errorf("expected negative ttl of %d got %d", 5, p.FreshnessLifetime)
+	}
+
+	resp.Header.Set("X-Upstream-Error", "false")
+	p = getBackendResponseCachingPolicy(o, resp)
+	if p.IsNegativeCache {
+		t.Error("expected non-negative caching policy")
+	}
+
+	resp = &http.Response{StatusCode: 404, Header: http.Header{}}
+	p = getBackendResponseCachingPolicy(o, resp)
+	if !p.IsNegativeCache || p.FreshnessLifetime != 30 {
+		t.Errorf("expected negative ttl of %d got %d", 30, p.FreshnessLifetime)
+	}
+}
+
+func TestCacheBypassRequested(t *testing.T) {
+
+	o := bo.New()
+	h := http.Header{}
+	h.Set(o.CacheBypassHeader, "true")
+	cp := &CachingPolicy{NoCache: true}
+
+	if cacheBypassRequested(nil, h, cp) {
+		t.Error("expected false")
+	}
+	// neither the header nor client no-cache are honored by default
+	if cacheBypassRequested(o, h, cp) {
+		t.Error("expected false")
+	}
+
+	o.AllowCacheBypassHeader = true
+	if !cacheBypassRequested(o, h, nil) {
+		t.Error("expected true")
+	}
+	h.Set(o.CacheBypassHeader, "false")
+	if cacheBypassRequested(o, h, nil) {
+		t.Error("expected false")
+	}
+
+	o.RefreshOnClientNoCache = true
+	if !cacheBypassRequested(o, h, cp) {
+		t.Error("expected true")
+	}
+}
+
 func TestGetRequestCacheability(t *testing.T) {
 
 	tests := []struct {
@@ -313,4 +497,25 @@ func TestCheckIfNoneMatch(t *testing.T) {
 		t.Errorf("expected %t got %t", false, res)
 	}
 
+	tests := []struct {
+		etag, inm string
+		expected  bool
+	}{
+		{`"test"`, `"test"`, false},
+		{`"test"`, `W/"test"`, false},
+		{`W/"test"`, `"test"`, false},
+		{`W/"test"`, `W/"test"`, false},
+		{`"test"`, `"other", W/"test"`, false},
+		{`"test"`, `"other"`, true},
+		{`"test"`, `W/"other", "test2"`, true},
+		{`W/"test"`, `"tes"`, true},
+	}
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			res := CheckIfNoneMatch(test.etag, test.inm, status.LookupStatusHit)
+			if res != test.expected {
+				t.Errorf("expected %t got %t", test.expected, res)
+			}
+		})
+	}
 }
@@ -0,0 +1,89 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/proxy/circuitbreaker"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+)
+
+func TestObjectProxyCacheCircuitBreakerOpen(t *testing.T) {
+
+	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusInternalServerError, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+
+	o := rsc.BackendOptions
+	o.CircuitBreaker = circuitbreaker.New(o.Name, 2, time.Minute, time.Minute)
+	o.CircuitBreakerStatusCode = http.StatusServiceUnavailable
+
+	for i := 0; i < 2; i++ {
+		_, e := testFetchOPC(r, http.StatusInternalServerError, "test", nil)
+		for _, err = range e {
+			t.Error(err)
+		}
+	}
+
+	if o.CircuitBreaker.State() != circuitbreaker.StateOpen {
+		t.Errorf("expected %s got %s", circuitbreaker.StateOpen, o.CircuitBreaker.State())
+	}
+
+	_, e := testFetchOPC(r, http.StatusServiceUnavailable, "", nil)
+	for _, err = range e {
+		t.Error(err)
+	}
+}
+
+func TestObjectProxyCacheCircuitBreakerServeStale(t *testing.T) {
+
+	hdrs := map[string]string{headers.NameCacheControl: headers.ValueMaxAge + "=1"}
+	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusOK, hdrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+
+	rsc.PathConfig.ResponseHeaders = hdrs
+	o := rsc.BackendOptions
+	o.CircuitBreaker = circuitbreaker.New(o.Name, 1, time.Minute, time.Minute)
+	o.CircuitBreakerStatusCode = http.StatusServiceUnavailable
+
+	_, e := testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	time.Sleep(1010 * time.Millisecond)
+
+	// simulate enough origin failures to open the breaker
+	o.CircuitBreaker.Record(false)
+	if !o.CircuitBreaker.IsOpen() {
+		t.Fatal("expected circuit breaker to be open")
+	}
+
+	// with the breaker open, the stale object is served rather than fast-failing
+	_, e = testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "hit"})
+	for _, err = range e {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,117 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"bytes"
+	"mime"
+	"strconv"
+
+	"github.com/trickstercache/trickster/v2/pkg/cache/status"
+	"github.com/trickstercache/trickster/v2/pkg/encoding/profile"
+	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/request"
+)
+
+// clientEncodedVariant returns a copy of the cached document whose body is encoded
+// to match the client's Accept-Encoding, when the Backend has EncodeForClient
+// enabled. Encoded variants are cached under a key derived from the encoding and
+// the cached document's local date, so a cache hit is only encoded once per
+// encoding, and a refreshed document gets a fresh variant. nil is returned when
+// the document should be served as-is.
+func (pr *proxyRequest) clientEncodedVariant(d *HTTPDocument) *HTTPDocument {
+
+	rsc := request.GetResources(pr.Request)
+	if rsc == nil || rsc.BackendOptions == nil || rsc.CacheClient == nil ||
+		!rsc.BackendOptions.EncodeForClient || pr.wantsRanges ||
+		d == nil || d.nonCompressible || len(d.Body) == 0 ||
+		d.CachingPolicy == nil || d.CachingPolicy.NoTransform {
+		return nil
+	}
+	o := rsc.BackendOptions
+
+	// the profile is only present when the client has not requested no-transform
+	ep := profile.FromContext(pr.Request.Context())
+	if ep == nil || ep.Supported == 0 {
+		return nil
+	}
+
+	h := d.SafeHeaderClone()
+	if isEncoded(h.Get(headers.NameContentEncoding)) {
+		return nil
+	}
+
+	// only compressible (text-based) types are encoded; binary types are served as-is
+	mt, _, err := mime.ParseMediaType(d.ContentType)
+	if err != nil {
+		return nil
+	}
+	if _, ok := o.CompressibleTypes[mt]; !ok {
+		return nil
+	}
+	p := ep.Clone()
+	p.ContentType = mt
+	p.CompressTypes = o.CompressibleTypes
+	ei, en := p.GetEncoderInitializer()
+	if ei == nil || en == "" {
+		return nil
+	}
+
+	key := pr.key + ".ce." + en + "." +
+		strconv.FormatInt(d.CachingPolicy.LocalDate.UnixNano(), 10)
+	ctx := pr.upstreamRequest.Context()
+
+	vd, ls, _, err := QueryCache(ctx, rsc.CacheClient, key, nil, nil)
+	if err == nil && ls == status.LookupStatusHit && vd != nil {
+		return vd
+	}
+
+	buf := &bytes.Buffer{}
+	enc := ei(buf, p.Level)
+	if _, err = enc.Write(d.Body); err == nil {
+		err = enc.Close()
+	}
+	if err != nil {
+		tl.Warn(pr.Logger, "could not encode cached document for client",
+			tl.Pairs{"cacheKey": pr.key, "encoding": en, "detail": err.Error()})
+		return nil
+	}
+
+	h.Set(headers.NameContentEncoding, en)
+	h.Add(headers.NameVary, headers.NameAcceptEncoding)
+	vd = &HTTPDocument{
+		StatusCode:    d.StatusCode,
+		Status:        d.Status,
+		Headers:       h,
+		ContentLength: -1,
+		ContentType:   d.ContentType,
+		CachingPolicy: d.CachingPolicy,
+	}
+	vd.SetBody(buf.Bytes())
+
+	rf, minTTL := o.RevalidationFactor, o.MinTTL
+	if rsc.AlternateCacheTTL > 0 {
+		rf, minTTL = 1, 0
+	}
+	if err = WriteCache(ctx, rsc.CacheClient, key, vd,
+		d.CachingPolicy.TTL(rf, minTTL, o.MaxTTL), o.CompressibleTypes, nil); err != nil {
+		tl.Warn(pr.Logger, "could not cache encoded document variant",
+			tl.Pairs{"cacheKey": key, "detail": err.Error()})
+	}
+	return vd
+}
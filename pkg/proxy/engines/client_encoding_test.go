@@ -0,0 +1,108 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/trickstercache/trickster/v2/pkg/encoding/brotli"
+	"github.com/trickstercache/trickster/v2/pkg/encoding/profile"
+	"github.com/trickstercache/trickster/v2/pkg/encoding/providers"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+)
+
+func TestObjectProxyCacheEncodeForClient(t *testing.T) {
+
+	hdrs := map[string]string{
+		headers.NameCacheControl: headers.ValueMaxAge + "=60",
+		headers.NameContentType:  headers.ValueTextPlain,
+	}
+	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusOK, hdrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+
+	rsc.PathConfig.ResponseHeaders = hdrs
+	o := rsc.BackendOptions
+	o.EncodeForClient = true
+	o.CompressibleTypes = map[string]interface{}{headers.ValueTextPlain: nil}
+
+	ep := &profile.Profile{
+		Supported:     providers.Brotli,
+		CompressTypes: o.CompressibleTypes,
+		Level:         -1,
+	}
+	r = r.WithContext(profile.ToContext(r.Context(), ep))
+
+	fetch := func(expectedStatus string) {
+		w := httptest.NewRecorder()
+		ObjectProxyCacheRequest(w, r.Clone(profile.ToContext(r.Context(), ep.Clone())))
+		resp := w.Result()
+		if err := testResultHeaderPartMatch(resp.Header,
+			map[string]string{"status": expectedStatus}); err != nil {
+			t.Error(err)
+		}
+		if expectedStatus != "hit" {
+			return
+		}
+		if ce := resp.Header.Get(headers.NameContentEncoding); ce != providers.BrotliValue {
+			t.Errorf("expected %s got %s", providers.BrotliValue, ce)
+		}
+		b, err := brotli.Decode(w.Body.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != "test" {
+			t.Errorf("expected %s got %s", "test", string(b))
+		}
+	}
+
+	fetch("kmiss")
+	fetch("hit")
+
+	// the encoded variant is now cached, keyed by its encoding
+	pr := newProxyRequest(r, nil)
+	key := o.CacheKeyPrefix + ".opc." + pr.DeriveCacheKey("")
+	d, _, _, err := QueryCache(r.Context(), rsc.CacheClient, key, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key += ".ce." + providers.BrotliValue + "." +
+		strconv.FormatInt(d.CachingPolicy.LocalDate.UnixNano(), 10)
+	vd, _, _, err := QueryCache(r.Context(), rsc.CacheClient, key, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vd.SafeHeaderClone().Get(headers.NameContentEncoding) != providers.BrotliValue {
+		t.Error("expected cached variant to be brotli-encoded")
+	}
+	fetch("hit")
+
+	// clients requesting no transformation get no profile, and are served as-is
+	w := httptest.NewRecorder()
+	ObjectProxyCacheRequest(w, r.Clone(profile.ToContext(r.Context(), nil)))
+	if ce := w.Result().Header.Get(headers.NameContentEncoding); ce != "" {
+		t.Errorf("expected no content encoding got %s", ce)
+	}
+	if w.Body.String() != "test" {
+		t.Errorf("expected %s got %s", "test", w.Body.String())
+	}
+}
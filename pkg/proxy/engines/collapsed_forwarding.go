@@ -0,0 +1,53 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"io"
+	"net/http"
+
+	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/forwarding"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/request"
+)
+
+// handleCollapsedForwardingTimeout handles a request that gave up waiting on the
+// cache lock held by an identical in-flight request, per the Backend's configured
+// collapsed forwarding timeout action. When the action is 'fail', a 503 is written
+// to w and returned. Otherwise nil is returned, and the caller should break away
+// by proxying the request to the origin on its own.
+func handleCollapsedForwardingTimeout(w io.Writer, r *http.Request) *http.Response {
+	rsc := request.GetResources(r)
+	o := rsc.BackendOptions
+	tl.Warn(rsc.Logger, "timed out waiting on collapsed request",
+		tl.Pairs{"backendName": o.Name, "timeout": o.CollapsedForwardingTimeout,
+			"action": o.CollapsedForwardingTimeoutAction.String()})
+	if o.CollapsedForwardingTimeoutAction != forwarding.CFTimeoutActionFail {
+		return nil
+	}
+	resp := &http.Response{StatusCode: http.StatusServiceUnavailable,
+		Request: r, Header: make(http.Header), Body: http.NoBody}
+	if rsc.PathConfig != nil {
+		headers.UpdateHeaders(resp.Header, rsc.PathConfig.ResponseHeaders)
+	}
+	if hw, ok := w.(http.ResponseWriter); ok {
+		headers.Merge(hw.Header(), resp.Header)
+		hw.WriteHeader(resp.StatusCode)
+	}
+	return resp
+}
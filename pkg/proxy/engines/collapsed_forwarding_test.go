@@ -0,0 +1,77 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/proxy/forwarding"
+)
+
+func TestCollapsedForwardingTimeout(t *testing.T) {
+
+	hdrs := map[string]string{"Cache-Control": "max-age=60"}
+	ts, w, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusOK, hdrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+
+	o := rsc.BackendOptions
+	o.CollapsedForwardingTimeout = 50 * time.Millisecond
+
+	// simulate a stalled leader by holding the write lock on the cache key
+	pr := newProxyRequest(r, w)
+	key := o.CacheKeyPrefix + ".opc." + pr.DeriveCacheKey("")
+	nl, err := rsc.CacheClient.Locker().Acquire(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nl.Release()
+
+	tests := []struct {
+		action forwarding.CollapsedForwardingTimeoutAction
+		code   int
+		body   string
+		match  map[string]string
+	}{
+		{forwarding.CFTimeoutActionFetch, http.StatusOK, "test",
+			map[string]string{"status": "proxy-only"}},
+		{forwarding.CFTimeoutActionFail, http.StatusServiceUnavailable, "", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.action.String(), func(t *testing.T) {
+			o.CollapsedForwardingTimeoutAction = test.action
+			done := make(chan []error, 1)
+			go func() {
+				_, e := testFetchOPC(r, test.code, test.body, test.match)
+				done <- e
+			}()
+			select {
+			case e := <-done:
+				for _, err := range e {
+					t.Error(err)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("follower did not break away from the stalled leader")
+			}
+		})
+	}
+}
@@ -35,6 +35,7 @@ import (
 	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
 	"github.com/trickstercache/trickster/v2/pkg/observability/metrics"
 	tspan "github.com/trickstercache/trickster/v2/pkg/observability/tracing/span"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/cacheevents"
 	tctx "github.com/trickstercache/trickster/v2/pkg/proxy/context"
 	tpe "github.com/trickstercache/trickster/v2/pkg/proxy/errors"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
@@ -57,6 +58,10 @@ func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request, modeler *tim
 		rsc.TSUnmarshaler = modeler.WireUnmarshaler
 	}
 	o := rsc.BackendOptions
+	if !rsc.KeyOnly && (rsc.ProxyOnly || !o.IsCacheablePath(r.URL.Path)) {
+		DoProxy(w, r, true)
+		return
+	}
 	ctx, span := tspan.NewChildSpan(r.Context(), rsc.Tracer, "DeltaProxyCacheRequest")
 	if span != nil {
 		defer span.End()
@@ -93,11 +98,11 @@ func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request, modeler *tim
 	now := time.Now()
 
 	bt := trq.GetBackfillTolerance(o.BackfillTolerance, o.BackfillTolerancePoints)
-	bfs := now.Add(-bt).Truncate(trq.Step) // start of the backfill tolerance window
+	bfs := trq.AlignTime(now.Add(-bt)) // start of the backfill tolerance window
 
 	OldestRetainedTimestamp := time.Time{}
 	if o.TimeseriesEvictionMethod == evictionmethods.EvictionMethodOldest {
-		OldestRetainedTimestamp = now.Truncate(trq.Step).Add(-(trq.Step * o.TimeseriesRetention))
+		OldestRetainedTimestamp = trq.AlignTime(now).Add(-(trq.Step * o.TimeseriesRetention))
 		if trq.Extent.End.Before(OldestRetainedTimestamp) {
 			tl.Debug(pr.Logger, "timerange end is too old to consider caching",
 				tl.Pairs{"oldestRetainedTimestamp": OldestRetainedTimestamp,
@@ -108,13 +113,24 @@ func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request, modeler *tim
 	}
 
 	client.SetExtent(pr.upstreamRequest, trq, &trq.Extent)
-	key := o.CacheKeyPrefix + ".dpc." + pr.DeriveCacheKey("")
-	pr.cacheLock, _ = locker.RAcquire(key)
+	key := pr.cacheKey(o, "dpc")
+	rsc.CacheKey = key
+	if rsc.KeyOnly {
+		return
+	}
+	pr.cacheLock, err = locker.RAcquireWithTimeout(key, o.CollapsedForwardingTimeout)
+	if err != nil {
+		if handleCollapsedForwardingTimeout(w, r) == nil {
+			DoProxy(w, r, true)
+		}
+		return
+	}
 
 	// this is used to determine if Fast Forward should be activated for this request
 	normalizedNow := &timeseries.TimeRangeQuery{
-		Extent: timeseries.Extent{Start: time.Unix(0, 0), End: now},
-		Step:   trq.Step,
+		Extent:     timeseries.Extent{Start: time.Unix(0, 0), End: now},
+		Step:       trq.Step,
+		StepOffset: trq.StepOffset,
 	}
 	normalizedNow.NormalizeExtent()
 
@@ -123,6 +139,12 @@ func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request, modeler *tim
 	var elapsed time.Duration
 
 	coReq := GetRequestCachingPolicy(r.Header)
+	// a cache bypass skips the cache read, but the fresh timeseries is still cached
+	bypass := cacheBypassRequested(o, r.Header, coReq)
+	if bypass {
+		coReq.NoCache = false
+		pr.upstreamRequest.Header.Del(o.CacheBypassHeader)
+	}
 checkCache:
 	if coReq.NoCache {
 		if span != nil {
@@ -130,6 +152,7 @@ checkCache:
 		}
 		cacheStatus = status.LookupStatusPurge
 		go cache.Remove(key)
+		notifyCacheEvent(rsc, cacheevents.OpPurge, key, 0, 0)
 		cts, doc, elapsed, err = fetchTimeseries(pr, trq, client, modeler)
 		if err != nil {
 			pr.cacheLock.RRelease()
@@ -140,7 +163,13 @@ checkCache:
 			return // fetchTimeseries logs the error
 		}
 	} else {
-		doc, cacheStatus, _, err = QueryCache(ctx, cache, key, nil, modeler.CacheUnmarshaler)
+		if bypass {
+			cacheStatus, err = status.LookupStatusKeyMiss, tc.ErrKNF
+		} else {
+			lookupStart := time.Now()
+			doc, cacheStatus, _, err = QueryCache(ctx, cache, key, nil, modeler.CacheUnmarshaler)
+			rsc.ServerTiming.AddCacheLookup(time.Since(lookupStart))
+		}
 		if cacheStatus == status.LookupStatusKeyMiss && err == tc.ErrKNF {
 			cts, doc, elapsed, err = fetchTimeseries(pr, trq, client, modeler)
 			if err != nil {
@@ -162,6 +191,7 @@ checkCache:
 				tl.Error(pr.Logger, "cache object unmarshaling failed",
 					tl.Pairs{"key": key, "backendName": client.Name(), "detail": err.Error()})
 				go cache.Remove(key)
+				notifyCacheEvent(rsc, cacheevents.OpPurge, key, 0, 0)
 				cts, doc, elapsed, err = fetchTimeseries(pr, trq, client, modeler)
 				if err != nil {
 					pr.cacheLock.RRelease()
@@ -335,6 +365,7 @@ checkCache:
 		dpStatus["extentsFetched"] = missRanges.String()
 		frsc := request.NewResources(o, pc, cc, cache, client, rsc.Tracer, pr.Logger)
 		frsc.TimeRangeQuery = trq
+		frsc.ServerTiming = rsc.ServerTiming
 		mts, uncachedValueCount, mresp, ferr = fetchExtents(missRanges, frsc, doc.Headers, client,
 			pr, modeler.WireUnmarshalerReader, span)
 	}
@@ -401,6 +432,15 @@ checkCache:
 		rts = cts.Clone()
 	}
 
+	// when the cached timeseries was only extended, just the fetched and volatile ranges
+	// have changed, so a chunked cache need only rewrite the chunks holding them
+	var dirty timeseries.ExtentList
+	if cacheStatus == status.LookupStatusPartialHit || cacheStatus == status.LookupStatusRangeMiss {
+		dirty = make(timeseries.ExtentList, 0, len(missRanges)+len(vr))
+		dirty = append(append(dirty, missRanges...), vr...)
+		dirty = append(dirty, cts.VolatileExtents()...)
+	}
+
 	if writeLock != nil {
 		// if the mutex is still locked, it means we need to write the time series to cache
 		go func() {
@@ -417,6 +457,7 @@ checkCache:
 			// (everything was cropped so there is nothing to cache)
 			if len(cts.Extents()) > 0 {
 				doc.timeseries = cts
+				doc.dirtyExtents = dirty
 				if err := WriteCache(ctx, cache, key, doc, o.TimeseriesTTL, o.CompressibleTypes, modeler.CacheMarshaler); err != nil {
 					tl.Error(pr.Logger, "error writing object to cache",
 						tl.Pairs{
@@ -443,6 +484,14 @@ checkCache:
 			o.Provider, "cached", r.URL.Path).Add(float64(cachedValueCount))
 	}
 
+	// fill any gaps in the response timeseries, after counting its elements so that filled
+	// points are not reported as cached. The cached timeseries is left unfilled
+	if o.FillPolicy != timeseries.FillPolicyNone {
+		if f, ok := rts.(timeseries.Filler); ok {
+			f.Fill(o.FillPolicy)
+		}
+	}
+
 	// Merge Fast Forward data if present. This must be done after the Downstream Crop since
 	// the cropped extent was normalized to stepboundaries and would remove fast forward data
 	// If the fast forward data point is older (e.g. cached) than the last datapoint in the
@@ -543,6 +592,9 @@ func fetchTimeseries(pr *proxyRequest, trq *timeseries.TimeRangeQuery,
 
 func recordDPCResult(r *http.Request, cacheStatus status.LookupStatus, httpStatus int, path,
 	ffStatus string, elapsed float64, needed []timeseries.Extent, header http.Header) {
+	if rsc := request.GetResources(r); rsc != nil {
+		rsc.ServerTiming.SetHeader(header)
+	}
 	recordResults(r, "DeltaProxyCache", cacheStatus, httpStatus, path, ffStatus, elapsed,
 		timeseries.ExtentList(needed), header)
 }
@@ -25,6 +25,7 @@ import (
 	"time"
 
 	mockprom "github.com/trickstercache/mockster/pkg/mocks/prometheus"
+	"github.com/trickstercache/trickster/v2/pkg/cache"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
 	"github.com/trickstercache/trickster/v2/pkg/timeseries"
 )
@@ -1595,3 +1596,111 @@ func TestDeltaProxyCacheRequestShardByPointsChunks(t *testing.T) {
 	}
 
 }
+
+func TestDeltaProxyCacheRequestChunksExtendRange(t *testing.T) {
+
+	ts, w, r, rsc, err := setupTestHarnessDPC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+
+	client := rsc.BackendClient.(*TestClient)
+	o := rsc.BackendOptions
+	o.FastForwardDisable = true
+
+	client.RangeCacheKey = "test-range-key-extend"
+	client.InstantCacheKey = "test-instant-key-extend"
+
+	cc := rsc.CacheClient.Configuration()
+	cc.UseCacheChunking = true
+	cc.TimeseriesChunkFactor = 12 // 1h chunks
+	mc := rsc.CacheClient.(cache.MemoryCache)
+
+	step := time.Duration(300) * time.Second
+	base := time.Now().Add(-time.Duration(12) * time.Hour).Truncate(time.Hour)
+
+	// waits for the asynchronous cache write, then returns each stored chunk by its subkey
+	storedChunks := func(e timeseries.Extent) map[string]interface{} {
+		if l, err := rsc.CacheClient.Locker().RAcquire(rsc.CacheKey); err == nil {
+			l.RRelease()
+		}
+		out := make(map[string]interface{})
+		for _, ce := range timeseriesChunkExtents(e, step, cc.TimeseriesChunkFactor) {
+			if d, _, err := mc.RetrieveReference(rsc.CacheKey+ce.String(), true); err == nil {
+				out[ce.String()] = d
+			}
+		}
+		return out
+	}
+
+	u := r.URL
+	u.Path = "/prometheus/api/v1/query_range"
+	u.RawQuery = fmt.Sprintf("step=%d&start=%d&end=%d&query=%s&rk=%s&ik=%s",
+		int(step.Seconds()), base.Add(-6*time.Hour).Unix(), base.Add(-step).Unix(),
+		queryReturnsOKNoLatency, client.RangeCacheKey, client.InstantCacheKey)
+
+	client.QueryRangeHandler(w, r)
+	err = testResultHeaderPartMatch(w.Result().Header, map[string]string{"status": "kmiss"})
+	if err != nil {
+		t.Error(err)
+	}
+
+	full := timeseries.Extent{Start: base.Add(-6 * time.Hour), End: base.Add(2*time.Hour - step)}
+	before := storedChunks(full)
+	if len(before) != 6 {
+		t.Fatalf("expected %d chunks got %d", 6, len(before))
+	}
+
+	// extend the range forward by 2 chunks
+	u.RawQuery = fmt.Sprintf("step=%d&start=%d&end=%d&query=%s&rk=%s&ik=%s",
+		int(step.Seconds()), full.Start.Unix(), full.End.Unix(), queryReturnsOKNoLatency,
+		client.RangeCacheKey, client.InstantCacheKey)
+	r.URL = u
+
+	w = httptest.NewRecorder()
+	client.QueryRangeHandler(w, r)
+	resp := w.Result()
+	err = testResultHeaderPartMatch(resp.Header, map[string]string{"status": "phit"})
+	if err != nil {
+		t.Error(err)
+	}
+
+	expected, _, _ := mockprom.GetTimeSeriesData(queryReturnsOKNoLatency, full.Start, full.End, step)
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Error(err)
+	}
+	err = testStringMatch(string(bodyBytes), expected)
+	if err != nil {
+		t.Error(err)
+	}
+
+	after := storedChunks(full)
+	if len(after) != 8 {
+		t.Fatalf("expected %d chunks got %d", 8, len(after))
+	}
+	// only the new chunks are written, so the original chunks are the same objects
+	for k, v := range before {
+		if after[k] != v {
+			t.Errorf("expected chunk %s to not be rewritten", k)
+		}
+	}
+
+	// a hit is stitched together from all of the chunks
+	w = httptest.NewRecorder()
+	client.QueryRangeHandler(w, r)
+	resp = w.Result()
+	err = testResultHeaderPartMatch(resp.Header, map[string]string{"status": "hit"})
+	if err != nil {
+		t.Error(err)
+	}
+	bodyBytes, err = io.ReadAll(resp.Body)
+	if err != nil {
+		t.Error(err)
+	}
+	err = testStringMatch(string(bodyBytes), expected)
+	if err != nil {
+		t.Error(err)
+	}
+}
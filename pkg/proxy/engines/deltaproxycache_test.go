@@ -21,15 +21,18 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
 	"testing"
 	"time"
 
 	mockprom "github.com/trickstercache/mockster/pkg/mocks/prometheus"
 	"github.com/trickstercache/trickster/v2/pkg/backends"
+	"github.com/trickstercache/trickster/v2/pkg/proxy"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/request"
 	"github.com/trickstercache/trickster/v2/pkg/timeseries"
 	tu "github.com/trickstercache/trickster/v2/pkg/testutil"
+	"github.com/trickstercache/trickster/v2/pkg/util/middleware"
 )
 
 // test queries
@@ -154,6 +157,41 @@ func TestDeltaProxyCacheRequestMissThenHit(t *testing.T) {
 	}
 }
 
+func TestDeltaProxyCacheRequestNonCacheablePath(t *testing.T) {
+
+	ts, w, r, rsc, err := setupTestHarnessDPC()
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	client := rsc.BackendClient.(*TestClient)
+	o := rsc.BackendOptions
+	o.NonCacheablePathPatterns = []*regexp.Regexp{regexp.MustCompile(`/query_range$`)}
+
+	step := time.Duration(300) * time.Second
+	end := time.Now().Add(-time.Duration(12) * time.Hour)
+	extr := timeseries.Extent{Start: end.Add(-time.Duration(18) * time.Hour), End: end}
+
+	u := r.URL
+	u.Path = "/prometheus/api/v1/query_range"
+	u.RawQuery = fmt.Sprintf("step=%d&start=%d&end=%d&query=%s",
+		int(step.Seconds()), extr.Start.Unix(), extr.End.Unix(), queryReturnsOKNoLatency)
+
+	client.QueryRangeHandler(w, r)
+	resp := w.Result()
+
+	err = testStatusCodeMatch(resp.StatusCode, http.StatusOK)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = testResultHeaderPartMatch(resp.Header, map[string]string{"status": "proxy-only"})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
 func TestDeltaProxyCacheRequestRemoveStale(t *testing.T) {
 
 	ts, w, r, rsc, err := setupTestHarnessDPC()
@@ -1627,3 +1665,78 @@ func TestDeltaProxyCacheRequestShardByPoints(t *testing.T) {
 	}
 
 }
+
+func TestDeltaProxyCacheRequestSlowOriginChunk(t *testing.T) {
+
+	ts, _, r, rsc, err := setupTestHarnessDPC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+
+	client := rsc.BackendClient.(*TestClient)
+	o := rsc.BackendOptions
+	o.FastForwardDisable = true
+
+	setOriginTimeout := func(d time.Duration) {
+		o.OriginTimeout = d
+		o.HTTPClient, err = proxy.NewHTTPClient(o)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	step := time.Duration(300) * time.Second
+	end := time.Now().Add(-time.Duration(12) * time.Hour)
+	start := end.Add(-time.Duration(6) * time.Hour)
+	const slowQuery = "some_query_here{latency_ms=300,range_latency_ms=0}"
+
+	request := func(clientTimeout time.Duration, query, instantKey string,
+		end time.Time) *http.Response {
+		u := r.URL
+		u.Path = "/prometheus/api/v1/query_range"
+		u.RawQuery = fmt.Sprintf("step=%d&start=%d&end=%d&query=%s&instantKey=%s",
+			int(step.Seconds()), start.Unix(), end.Unix(), query, instantKey)
+		r.URL = u
+		w := httptest.NewRecorder()
+		middleware.ClientResponseTimeout(clientTimeout,
+			http.HandlerFunc(client.QueryRangeHandler)).ServeHTTP(w, r)
+		return w.Result()
+	}
+
+	setOriginTimeout(time.Second)
+
+	resp := request(2*time.Second, queryReturnsOKNoLatency, "slow", end.Add(-time.Hour))
+	if err = testStatusCodeMatch(resp.StatusCode, http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if err = testResultHeaderPartMatch(resp.Header, map[string]string{"status": "kmiss"}); err != nil {
+		t.Error(err)
+	}
+
+	// a partial hit needing one slow origin chunk completes within the generous
+	// client response timeout, since the chunk is within the origin timeout
+	resp = request(2*time.Second, slowQuery, "slow", end)
+	if err = testStatusCodeMatch(resp.StatusCode, http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if err = testResultHeaderPartMatch(resp.Header, map[string]string{"status": "phit"}); err != nil {
+		t.Error(err)
+	}
+
+	// a fully-upstream request still respects the origin timeout, even with a
+	// generous client response timeout
+	setOriginTimeout(100 * time.Millisecond)
+	resp = request(2*time.Second, slowQuery, "upstream", end)
+	if resp.StatusCode == http.StatusOK {
+		t.Errorf("expected a failed response for a slow origin beyond the origin timeout")
+	}
+
+	// a client response timeout tighter than the slow chunk abandons the response,
+	// while the chunk's origin request continues in the background
+	setOriginTimeout(time.Second)
+	resp = request(100*time.Millisecond, slowQuery, "slow", end.Add(time.Hour))
+	if err = testStatusCodeMatch(resp.StatusCode, http.StatusServiceUnavailable); err != nil {
+		t.Error(err)
+	}
+}
@@ -55,8 +55,37 @@ type HTTPDocument struct {
 	rangePartsLoaded bool
 	isFulfillment    bool
 	isLoaded         bool
+	nonRangeable     bool
+	nonCompressible  bool
 	timeseries       timeseries.Timeseries
 	headerLock       sync.Mutex
+	// dirtyExtents, when set, limits a chunked timeseries write to the chunks overlapping
+	// these extents, since the document's other chunks are unchanged in the cache
+	dirtyExtents timeseries.ExtentList
+	// dirtyRanges, when set, limits a chunked byterange write to the chunks overlapping
+	// these ranges
+	dirtyRanges byterange.Ranges
+	// vary is the list of request header names in the upstream's Vary response header
+	vary []string
+	// encodedBody, when set, is the compressed document of an object whose metadata
+	// alone was decoded by QueryCacheMetadata. It is decoded by loadBody when needed
+	encodedBody []byte
+}
+
+// setContentTypeFlags marks the document as non-rangeable and non-compressible
+// when its Content Type uses a framing that must be passed through intact, and
+// as non-rangeable when its body has a non-identity Content Encoding
+func (d *HTTPDocument) setContentTypeFlags() {
+	if isGRPCWeb(d.ContentType) {
+		d.nonRangeable = true
+		d.nonCompressible = true
+	}
+	d.headerLock.Lock()
+	ce := http.Header(d.Headers).Get(headers.NameContentEncoding)
+	d.headerLock.Unlock()
+	if isEncoded(ce) {
+		d.nonRangeable = true
+	}
 }
 
 func (d *HTTPDocument) GetMeta() *HTTPDocument {
@@ -136,7 +165,7 @@ func (d *HTTPDocument) getByteRanges() byterange.Ranges {
 	} else if ranges := d.RangeParts.Ranges(); len(ranges) > 0 {
 		return ranges
 	} else {
-		return byterange.Ranges{byterange.Range{Start: 0, End: d.ContentLength}}
+		return byterange.Ranges{byterange.Range{Start: 0, End: d.ContentLength - 1}}
 	}
 }
 
@@ -188,6 +217,28 @@ func (d *HTTPDocument) SetBody(body []byte) {
 	d.headerLock.Unlock()
 }
 
+// loadBody decodes the body of a document whose metadata alone was queried from the
+// cache. It is a no-op for documents that are already fully decoded
+func (d *HTTPDocument) loadBody() error {
+	if d == nil || d.encodedBody == nil {
+		return nil
+	}
+	b := d.encodedBody
+	d.encodedBody = nil
+	if len(b) == 0 {
+		return errMalformedMetadata
+	}
+	b, err := decompress(b[0], b[1:])
+	if err != nil {
+		return err
+	}
+	if _, err = d.UnmarshalMsg(b); err != nil {
+		return err
+	}
+	d.setContentTypeFlags()
+	return nil
+}
+
 // LoadRangeParts convert a StoredRangeParts into a RangeParts
 func (d *HTTPDocument) LoadRangeParts() {
 
@@ -209,6 +260,16 @@ func (d *HTTPDocument) LoadRangeParts() {
 func (d *HTTPDocument) ParsePartialContentBody(resp *http.Response, body []byte, logger interface{}) {
 
 	ct := resp.Header.Get(headers.NameContentType)
+	if isEncoded(resp.Header.Get(headers.NameContentEncoding)) {
+		// the range is of the encoded representation, so it can't be parsed or
+		// merged with other ranges without corrupting the body; keep it opaque
+		d.nonRangeable = true
+		if !strings.HasPrefix(ct, headers.ValueMultipartByteRanges) {
+			d.ContentType = ct
+		}
+		d.SetBody(body)
+		return
+	}
 	if cr := resp.Header.Get(headers.NameContentRange); cr != "" {
 		if !strings.HasPrefix(ct, headers.ValueMultipartByteRanges) {
 			d.ContentType = ct
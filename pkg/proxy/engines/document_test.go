@@ -48,6 +48,59 @@ func TestDocumentFromHTTPResponse(t *testing.T) {
 
 }
 
+func TestDocumentFromHTTPResponseGRPCWeb(t *testing.T) {
+
+	resp := &http.Response{}
+	resp.Header = http.Header{
+		headers.NameContentRange: []string{"bytes 1-4/8"},
+		headers.NameContentType:  []string{"application/grpc-web+proto"},
+	}
+	resp.StatusCode = 206
+	d := DocumentFromHTTPResponse(resp, []byte("1234"), nil, testLogger)
+
+	if !d.nonRangeable || !d.nonCompressible {
+		t.Error("expected gRPC-Web document to be non-rangeable and non-compressible")
+	}
+
+	if len(d.Ranges) != 0 {
+		t.Errorf("expected 0 got %d", len(d.Ranges))
+	}
+
+	if string(d.Body) != "1234" {
+		t.Errorf("expected %s got %s", "1234", string(d.Body))
+	}
+
+}
+
+func TestDocumentFromHTTPResponseBrotli(t *testing.T) {
+
+	resp := &http.Response{}
+	resp.Header = http.Header{
+		headers.NameContentRange:    []string{"bytes 0-3/8"},
+		headers.NameContentType:     []string{"text/plain"},
+		headers.NameContentEncoding: []string{"br"},
+	}
+	resp.StatusCode = 206
+	d := DocumentFromHTTPResponse(resp, []byte("\x0b\x01\x80t"), nil, testLogger)
+
+	if !d.nonRangeable {
+		t.Error("expected encoded document to be non-rangeable")
+	}
+
+	if len(d.Ranges) != 0 {
+		t.Errorf("expected 0 got %d", len(d.Ranges))
+	}
+
+	if string(d.Body) != "\x0b\x01\x80t" {
+		t.Errorf("expected %q got %q", "\x0b\x01\x80t", string(d.Body))
+	}
+
+	if ce := http.Header(d.Headers).Get(headers.NameContentEncoding); ce != "br" {
+		t.Errorf("expected %s got %s", "br", ce)
+	}
+
+}
+
 func TestCachingPolicyString(t *testing.T) {
 
 	cp := &CachingPolicy{NoTransform: true}
@@ -111,6 +164,29 @@ func TestParsePartialContentBodyNoRanges(t *testing.T) {
 
 }
 
+func TestParsePartialContentBodyEncoded(t *testing.T) {
+
+	d := &HTTPDocument{}
+	resp := &http.Response{Header: http.Header{
+		headers.NameContentRange:    []string{"bytes 0-10/1222"},
+		headers.NameContentEncoding: []string{"br"},
+	}}
+	d.ParsePartialContentBody(resp, []byte("Lorem ipsum"), testLogger)
+
+	if !d.nonRangeable {
+		t.Error("expected encoded document to be non-rangeable")
+	}
+
+	if len(d.RangeParts) != 0 {
+		t.Errorf("expected %d got %d", 0, len(d.RangeParts))
+	}
+
+	if string(d.Body) != "Lorem ipsum" {
+		t.Errorf("expected %s got %s", "Lorem ipsum", string(d.Body))
+	}
+
+}
+
 func TestParsePartialContentBodySingleRange(t *testing.T) {
 	d := &HTTPDocument{}
 	d.Ranges = make(byterange.Ranges, 0)
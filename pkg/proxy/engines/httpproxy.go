@@ -18,12 +18,12 @@ package engines
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"math"
 	"net/http"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/trickstercache/trickster/v2/pkg/cache/status"
@@ -32,11 +32,15 @@ import (
 	"github.com/trickstercache/trickster/v2/pkg/observability/metrics"
 	"github.com/trickstercache/trickster/v2/pkg/observability/tracing"
 	tspan "github.com/trickstercache/trickster/v2/pkg/observability/tracing/span"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/circuitbreaker"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/forwarding"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/methods"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/params"
+	po "github.com/trickstercache/trickster/v2/pkg/proxy/paths/options"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/request"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/upstreamlimit"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/urls"
 	"github.com/trickstercache/trickster/v2/pkg/timeseries"
 
 	othttptrace "go.opentelemetry.io/contrib/instrumentation/net/http/httptrace/otelhttptrace"
@@ -44,8 +48,8 @@ import (
 	"go.opentelemetry.io/otel/trace"
 )
 
-// Reqs is for Progressive Collapsed Forwarding
-var reqs sync.Map
+// reqs tracks the sessions in progress for Progressive Collapsed Forwarding
+var reqs = newPCFSessions(maxPCFSessions)
 
 // HTTPBlockSize represents 32K of bytes
 const HTTPBlockSize = 32 * 1024
@@ -57,6 +61,10 @@ const ClockOffsetWarning = "clock offset between trickster host and origin is hi
 func DoProxy(w io.Writer, r *http.Request, closeResponse bool) *http.Response {
 
 	rsc := request.GetResources(r)
+	if rsc.KeyOnly {
+		// uncacheable requests have no key to derive
+		return nil
+	}
 	o := rsc.BackendOptions
 
 	start := time.Now()
@@ -77,6 +85,7 @@ func DoProxy(w io.Writer, r *http.Request, closeResponse bool) *http.Response {
 		!methods.HasBody(r.Method) {
 		reader, resp, _ = PrepareFetchReader(r)
 		cacheStatusCode = setStatusHeader(resp.StatusCode, resp.Header)
+		rsc.ServerTiming.SetHeader(resp.Header)
 		writer := PrepareResponseWriter(w, resp.StatusCode, resp.Header)
 		if writer != nil && reader != nil {
 			io.Copy(writer, reader)
@@ -84,17 +93,20 @@ func DoProxy(w io.Writer, r *http.Request, closeResponse bool) *http.Response {
 	} else {
 		pr := newProxyRequest(r, w)
 		key := o.CacheKeyPrefix + "." + pr.DeriveCacheKey("")
-		result, ok := reqs.Load(key)
+		pcf, ok := reqs.Load(key)
 		if !ok {
 			var contentLength int64
 			reader, resp, contentLength = PrepareFetchReader(r)
 			cacheStatusCode = setStatusHeader(resp.StatusCode, resp.Header)
+			rsc.ServerTiming.SetHeader(resp.Header)
 			pr.mapLock.Lock()
 			writer := PrepareResponseWriter(w, resp.StatusCode, resp.Header)
 			pr.mapLock.Unlock()
 			// Check if we know the content length and if it is less than our max object size.
-			if contentLength != 0 && contentLength < int64(o.MaxObjectSizeBytes) {
-				pcf := NewPCF(resp, contentLength)
+			if contentLength > 0 && contentLength < int64(o.MaxObjectSizeBytes) {
+				// the session gets its own copy of the response, since this request
+				// continues to update the headers of resp after clients join
+				pcf := NewPCF(copyResponse(resp), contentLength)
 				reqs.Store(key, pcf)
 				// Blocks until server completes
 				grClose := reader != nil && closeResponse
@@ -102,19 +114,20 @@ func DoProxy(w io.Writer, r *http.Request, closeResponse bool) *http.Response {
 				go func() {
 					io.Copy(pcf, reader)
 					pcf.Close()
-					reqs.Delete(key)
+					reqs.Delete(key, pcf)
 					if grClose {
 						reader.Close()
 					}
 				}()
 				pcf.AddClient(writer)
+			} else if writer != nil && reader != nil {
+				io.Copy(writer, reader)
 			}
 		} else {
-			pcf, _ := result.(ProgressiveCollapseForwarder)
-			resp = pcf.GetResp()
+			resp = copyResponse(pcf.GetResp())
 			pr.mapLock.Lock()
 			writer := PrepareResponseWriter(w, resp.StatusCode, resp.Header)
-			pr.mapLock.Lock()
+			pr.mapLock.Unlock()
 			pcf.AddClient(writer)
 		}
 	}
@@ -134,6 +147,13 @@ func DoProxy(w io.Writer, r *http.Request, closeResponse bool) *http.Response {
 	return resp
 }
 
+// copyResponse returns a shallow copy of the response with its own headers
+func copyResponse(resp *http.Response) *http.Response {
+	r2 := *resp
+	r2.Header = resp.Header.Clone()
+	return &r2
+}
+
 // PrepareResponseWriter prepares a response and returns a destination io.Writer for the payload
 // Used in Respond.
 func PrepareResponseWriter(w io.Writer, code int, header http.Header) io.Writer {
@@ -169,7 +189,7 @@ func PrepareFetchReader(r *http.Request) (io.ReadCloser, *http.Response, int64)
 
 	var rc io.ReadCloser
 
-	headers.AddForwardingHeaders(r, o.ForwardedHeaders)
+	headers.AddForwardingHeaders(r, o.ForwardedHeaders, o.TrustedProxies)
 
 	if pc != nil && len(pc.RequestParams) > 0 {
 		headers.UpdateHeaders(r.Header, pc.RequestHeaders)
@@ -178,6 +198,13 @@ func PrepareFetchReader(r *http.Request) (io.ReadCloser, *http.Response, int64)
 		params.SetRequestValues(r, qp)
 	}
 
+	// apply any path rewrites to a copy of the URL, so the cache key is not affected
+	if p := o.RewriteUpstreamPath(r.URL.Path); p != r.URL.Path {
+		u := urls.Clone(r.URL)
+		u.Path = p
+		r.URL = u
+	}
+
 	if ep := profile.FromContext(r.Context()); ep != nil && ep.SupportedHeaderVal != "" {
 		r.Header.Set(headers.NameAcceptEncoding, ep.SupportedHeaderVal)
 	}
@@ -197,11 +224,65 @@ func PrepareFetchReader(r *http.Request) (io.ReadCloser, *http.Response, int64)
 		defer doSpan.End()
 	}
 
-	// clear the Host header before proxying or it will be forwarded upstream
-	r.Host = ""
+	// replace the client's Host header before proxying or it will be forwarded upstream;
+	// when no override is configured, the host from the request URL is used
+	r.Host = o.UpstreamHostHeader
+	if o.UpstreamUserAgent != "" {
+		r.Header.Set(headers.NameUserAgent, o.UpstreamUserAgent)
+	}
 
+	// wait for an upstream slot before consulting the circuit breaker, so a request that
+	// times out in queue can't leave a half-open trial outstanding
+	release := func() {}
+	if ul := o.UpstreamLimiter; ul != nil {
+		rf, ok := ul.Acquire(ctx)
+		if !ok {
+			tl.Warn(rsc.Logger, "upstream concurrency limit reached",
+				tl.Pairs{"backendName": o.Name, "url": r.URL.String()})
+			resp := &http.Response{StatusCode: http.StatusServiceUnavailable,
+				Request: r, Header: make(http.Header)}
+			if pc != nil {
+				headers.UpdateHeaders(resp.Header, pc.ResponseHeaders)
+			}
+			if doSpan != nil {
+				doSpan.AddEvent("Upstream Queue Timeout")
+				doSpan.SetStatus(tracing.HTTPToCode(resp.StatusCode), "")
+			}
+			return nil, resp, 0
+		}
+		release = rf
+	}
+
+	cb := o.CircuitBreaker
+	if cb != nil {
+		allowed, t := cb.Allow()
+		logCircuitBreakerTransition(rsc.Logger, o.Name, t)
+		if !allowed {
+			resp := &http.Response{StatusCode: o.CircuitBreakerStatusCode,
+				Request: r, Header: make(http.Header)}
+			if pc != nil {
+				headers.UpdateHeaders(resp.Header, pc.ResponseHeaders)
+			}
+			if doSpan != nil {
+				doSpan.AddEvent("Circuit Breaker Open")
+				doSpan.SetStatus(tracing.HTTPToCode(resp.StatusCode), "")
+			}
+			release()
+			return nil, resp, 0
+		}
+	}
+
+	fetchStart := time.Now()
 	resp, err := o.HTTPClient.Do(r)
+	fetchDuration := time.Since(fetchStart)
+	metrics.ProxyOriginRequestDuration.WithLabelValues(o.Name).Observe(fetchDuration.Seconds())
+	rsc.ServerTiming.AddOriginFetch(fetchDuration)
+	if cb != nil {
+		t := cb.Record(err == nil && resp != nil && resp.StatusCode < http.StatusInternalServerError)
+		logCircuitBreakerTransition(rsc.Logger, o.Name, t)
+	}
 	if err != nil {
+		release()
 		tl.Error(rsc.Logger,
 			"error downloading url", tl.Pairs{"url": r.URL.String(), "detail": err.Error()})
 		// if there is an err and the response is nil, the server could not be reached
@@ -270,6 +351,26 @@ func PrepareFetchReader(r *http.Request) (io.ReadCloser, *http.Response, int64)
 		hasCustomResponseBody = pc.HasCustomResponseBody
 	}
 
+	// the upstream slot is held until the response body is consumed or closed
+	resp.Body = upstreamlimit.ReleaseOnDone(resp.Body, release)
+
+	// when the backend limits the response size, a response advertising a larger size is
+	// abandoned before any part of it is cached or written to the client. Otherwise, the body
+	// is counted as it is streamed, and fails with errResponseTooLarge once it exceeds the limit
+	if o.MaxResponseBytes > 0 && !hasCustomResponseBody {
+		if originalLen > o.MaxResponseBytes {
+			resp.Body.Close()
+			tl.Error(rsc.Logger, "error reading upstream response",
+				tl.Pairs{"url": r.URL.String(), "detail": errResponseTooLarge.Error()})
+			if doSpan != nil {
+				doSpan.AddEvent("Upstream Response Too Large")
+				doSpan.SetStatus(tracing.HTTPToCode(o.MaxResponseBytesStatusCode), "")
+			}
+			return nil, newResponseTooLarge(r, o.MaxResponseBytesStatusCode, pc), 0
+		}
+		resp.Body = &maxBytesBody{ReadCloser: resp.Body, remaining: o.MaxResponseBytes, rsc: rsc}
+	}
+
 	if hasCustomResponseBody {
 		// Since we are not responding with the actual upstream response body, close it here
 		resp.Body.Close()
@@ -281,6 +382,71 @@ func PrepareFetchReader(r *http.Request) (io.ReadCloser, *http.Response, int64)
 	return rc, resp, originalLen
 }
 
+// errResponseTooLarge is an error for an upstream response body exceeding the
+// backend's MaxResponseBytes
+var errResponseTooLarge = errors.New("upstream response exceeds max_response_bytes")
+
+// newResponseTooLarge returns the response sent in place of an upstream response whose
+// body exceeds the backend's MaxResponseBytes
+func newResponseTooLarge(r *http.Request, code int, pc *po.Options) *http.Response {
+	resp := &http.Response{StatusCode: code, Request: r, Header: make(http.Header)}
+	resp.Header.Set(headers.NameCacheControl, headers.ValueNoStore)
+	if pc != nil {
+		headers.UpdateHeaders(resp.Header, pc.ResponseHeaders)
+	}
+	return resp
+}
+
+// maxBytesBody is an upstream response body that fails with errResponseTooLarge once more
+// than its remaining bytes are read. The error is logged, and the request's resources are
+// marked as having received a response that is too large, so that the truncated body is
+// never cached
+type maxBytesBody struct {
+	io.ReadCloser
+	remaining int64
+	rsc       *request.Resources
+}
+
+func (b *maxBytesBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, errResponseTooLarge
+	}
+	// read one byte beyond the limit, in order to tell a body ending at the limit from a
+	// body exceeding it
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) > b.remaining {
+		n = int(b.remaining)
+		b.remaining = -1
+		if b.rsc != nil {
+			b.rsc.ResponseTooLarge = true
+			tl.Error(b.rsc.Logger, "upstream response truncated",
+				tl.Pairs{"backendName": b.rsc.BackendOptions.Name,
+					"detail": errResponseTooLarge.Error()})
+		}
+		return n, errResponseTooLarge
+	}
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// logCircuitBreakerTransition logs a change in the backend's circuit breaker state
+func logCircuitBreakerTransition(logger interface{}, backendName string,
+	t circuitbreaker.Transition) {
+	if !t.Changed() {
+		return
+	}
+	pairs := tl.Pairs{"backendName": backendName, "from": t.From.String(),
+		"to": t.To.String()}
+	if t.To == circuitbreaker.StateOpen {
+		tl.Warn(logger, "circuit breaker opened", pairs)
+		return
+	}
+	tl.Info(logger, "circuit breaker state changed", pairs)
+}
+
 // Respond sends an HTTP Response down to the requesting client
 func Respond(w io.Writer, code int, header http.Header, body io.Reader) {
 	PrepareResponseWriter(w, code, header)
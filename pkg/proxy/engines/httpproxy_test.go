@@ -21,16 +21,21 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/trickstercache/trickster/v2/cmd/trickster/config"
+	bo "github.com/trickstercache/trickster/v2/pkg/backends/options"
 	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
 	tc "github.com/trickstercache/trickster/v2/pkg/proxy/context"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/forwarding"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
 	po "github.com/trickstercache/trickster/v2/pkg/proxy/paths/options"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/request"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/urls"
 	tu "github.com/trickstercache/trickster/v2/pkg/testutil"
 )
 
@@ -88,6 +93,149 @@ func TestDoProxy(t *testing.T) {
 	}
 }
 
+func TestDoProxyPathRewrite(t *testing.T) {
+
+	// the origin responds with the path it was requested with
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer es.Close()
+
+	conf, _, err := config.Load("trickster", "test",
+		[]string{"-origin-url", es.URL + "/base", "-provider", "test", "-log-level", "debug"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+
+	o := conf.Backends["default"]
+	o.HTTPClient = http.DefaultClient
+	o.PathRewriter = urls.NewPathRewriter(true)
+	if err = o.PathRewriter.AddRule(`^/v1/metrics/(.*)$`, "/api/$1"); err != nil {
+		t.Fatal(err)
+	}
+	pc := &po.Options{Path: "/"}
+
+	tests := []struct {
+		path, expected string
+	}{
+		{"/base/v1/metrics/cpu", "/base/api/cpu"},
+		{"/base/health", "/base/health"},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", es.URL+test.path, nil)
+		r = r.WithContext(tc.WithResources(r.Context(),
+			request.NewResources(o, pc, nil, nil, nil, tu.NewTestTracer(), testLogger)))
+		u := r.URL
+
+		DoProxy(w, r, true)
+		resp := w.Result()
+		bodyBytes, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		err = testStringMatch(string(bodyBytes), test.expected)
+		if err != nil {
+			t.Error(err)
+		}
+		// the original URL, from which cache keys are derived, is unchanged
+		if u.Path != test.path {
+			t.Errorf("expected %s got %s", test.path, u.Path)
+		}
+	}
+}
+
+func TestDoProxyUpstreamHostHeader(t *testing.T) {
+
+	// the origin responds with the Host header it was requested with
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host))
+	}))
+	defer es.Close()
+
+	conf, _, err := config.Load("trickster", "test",
+		[]string{"-origin-url", es.URL, "-provider", "test", "-log-level", "debug"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+
+	o := conf.Backends["default"]
+	o.HTTPClient = http.DefaultClient
+	pc := &po.Options{Path: "/"}
+
+	tests := []struct {
+		override, expected string
+	}{
+		{"", strings.TrimPrefix(es.URL, "http://")},
+		{"origin.example.com", "origin.example.com"},
+	}
+
+	for _, test := range tests {
+		o.UpstreamHostHeader = test.override
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", es.URL+"/", nil)
+		r.Host = "trickster.example.com"
+		r = r.WithContext(tc.WithResources(r.Context(),
+			request.NewResources(o, pc, nil, nil, nil, tu.NewTestTracer(), testLogger)))
+
+		DoProxy(w, r, true)
+		bodyBytes, err := io.ReadAll(w.Result().Body)
+		if err != nil {
+			t.Error(err)
+		}
+		err = testStringMatch(string(bodyBytes), test.expected)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestDoProxyUpstreamUserAgent(t *testing.T) {
+
+	// the origin responds with the User-Agent header it was requested with
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.UserAgent()))
+	}))
+	defer es.Close()
+
+	conf, _, err := config.Load("trickster", "test",
+		[]string{"-origin-url", es.URL, "-provider", "test", "-log-level", "debug"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+
+	o := conf.Backends["default"]
+	o.HTTPClient = http.DefaultClient
+	pc := &po.Options{Path: "/"}
+
+	tests := []struct {
+		override, expected string
+	}{
+		{"", "test-client/1.0"},
+		{"trickster/2.0", "trickster/2.0"},
+	}
+
+	for _, test := range tests {
+		o.UpstreamUserAgent = test.override
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", es.URL+"/", nil)
+		r.Header.Set(headers.NameUserAgent, "test-client/1.0")
+		r = r.WithContext(tc.WithResources(r.Context(),
+			request.NewResources(o, pc, nil, nil, nil, tu.NewTestTracer(), testLogger)))
+
+		DoProxy(w, r, true)
+		bodyBytes, err := io.ReadAll(w.Result().Body)
+		if err != nil {
+			t.Error(err)
+		}
+		err = testStringMatch(string(bodyBytes), test.expected)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
+
 func TestProxyRequestBadGateway(t *testing.T) {
 
 	const badUpstream = "http://127.0.0.1:64389"
@@ -228,6 +376,79 @@ func TestDoProxyWithPCF(t *testing.T) {
 	}
 }
 
+func TestDoProxyWithPCFUnkeyedParams(t *testing.T) {
+
+	// the origin sends its headers, then holds the body until released, so that the
+	// second request arrives while the first is still being fetched
+	var calls atomic.Int32
+	release := make(chan struct{})
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set(headers.NameContentLength, "4")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		<-release
+		w.Write([]byte("test"))
+	}))
+	defer es.Close()
+
+	conf, _, err := config.Load("trickster", "test",
+		[]string{"-origin-url", es.URL, "-provider", "test", "-log-level", "debug"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+
+	o := conf.Backends["default"]
+	o.HTTPClient = http.DefaultClient
+	pc := &po.Options{
+		Path:                    "/",
+		CacheKeyParams:          []string{"query"},
+		CollapsedForwardingName: "progressive",
+		CollapsedForwardingType: forwarding.CFTypeProgressive,
+	}
+
+	// the requests differ only in the utm_source param, which is not part of the cache key
+	ws := make([]*httptest.ResponseRecorder, 2)
+	var wg sync.WaitGroup
+	for i, u := range []string{"/?query=up&utm_source=a", "/?query=up&utm_source=b"} {
+		ws[i] = httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, es.URL+u, nil)
+		r = r.WithContext(tc.WithResources(r.Context(),
+			request.NewResources(o, pc, nil, nil, nil, tu.NewTestTracer(), testLogger)))
+		wg.Add(1)
+		go func(w *httptest.ResponseRecorder) {
+			defer wg.Done()
+			DoProxy(w, r, true)
+		}(ws[i])
+		if i == 0 {
+			// wait for the first request to register its session
+			for j := 0; reqs.Len() == 0 && j < 200; j++ {
+				time.Sleep(10 * time.Millisecond)
+			}
+			if reqs.Len() == 0 {
+				close(release)
+				t.Fatal("expected a collapsed forwarding session")
+			}
+		}
+	}
+	// allow the second request to join the session before releasing the body
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected %d upstream call got %d", 1, n)
+	}
+	for _, w := range ws {
+		if err = testStringMatch(w.Body.String(), "test"); err != nil {
+			t.Error(err)
+		}
+	}
+	if reqs.Len() != 0 {
+		t.Errorf("expected finished sessions to be removed, got %d", reqs.Len())
+	}
+}
+
 func TestProxyRequestWithPCFMultipleClients(t *testing.T) {
 
 	es := tu.NewTestServer(http.StatusOK, "test", nil)
@@ -303,3 +524,25 @@ func TestPrepareFetchReaderErr(t *testing.T) {
 		t.Errorf("expected 0 got %d", i)
 	}
 }
+
+func TestMaxBytesBody(t *testing.T) {
+
+	rsc := request.NewResources(bo.New(), nil, nil, nil, nil, nil, testLogger)
+
+	// a body ending at the limit is read in full
+	b := &maxBytesBody{ReadCloser: io.NopCloser(strings.NewReader("1234")), remaining: 4, rsc: rsc}
+	out, err := io.ReadAll(b)
+	if err != nil || string(out) != "1234" || rsc.ResponseTooLarge {
+		t.Errorf("unexpected read %q %v %t", out, err, rsc.ResponseTooLarge)
+	}
+
+	// a body exceeding the limit is read no further than the limit
+	b = &maxBytesBody{ReadCloser: io.NopCloser(strings.NewReader("12345")), remaining: 4, rsc: rsc}
+	out, err = io.ReadAll(b)
+	if err != errResponseTooLarge || string(out) != "1234" || !rsc.ResponseTooLarge {
+		t.Errorf("unexpected read %q %v %t", out, err, rsc.ResponseTooLarge)
+	}
+	if _, err = b.Read(make([]byte, 1)); err != errResponseTooLarge {
+		t.Errorf("expected %v got %v", errResponseTooLarge, err)
+	}
+}
@@ -17,21 +17,36 @@
 package engines
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
 	"net/url"
 	"sort"
 	"strconv"
 	"strings"
 
+	bo "github.com/trickstercache/trickster/v2/pkg/backends/options"
+	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/errors"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/methods"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/params"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/request"
+	strutil "github.com/trickstercache/trickster/v2/pkg/util/strings"
 	"github.com/trickstercache/trickster/v2/pkg/checksum/md5"
 )
 
+// maxMultipartKeyMemory is the maximum number of bytes of a multipart body that are
+// parsed when deriving a cache key
+const maxMultipartKeyMemory = 1024 * 1024
+
+// maxCacheKeyPathLength is the maximum length of the escaped request path included in
+// a cache key, which keeps keys within the file name length limits of filesystem caches
+const maxCacheKeyPathLength = 128
+
 // DeriveCacheKey calculates a query-specific keyname based on the user request
 func (pr *proxyRequest) DeriveCacheKey(extra string) string {
 
@@ -39,7 +54,7 @@ func (pr *proxyRequest) DeriveCacheKey(extra string) string {
 	pc := rsc.PathConfig
 
 	if pc == nil {
-		return md5.Checksum(pr.URL.Path + extra)
+		return md5.Checksum(cacheKeyPath(rsc.BackendOptions, pr.URL.Path) + extra)
 	}
 
 	var qp url.Values
@@ -57,20 +72,38 @@ func (pr *proxyRequest) DeriveCacheKey(extra string) string {
 		qp = rsc.TimeRangeQuery.TemplateURL.Query()
 	} else {
 		var s string
-		qp, s, _ = params.GetRequestValues(r)
+		var isBody bool
+		qp, s, isBody = params.GetRequestValues(r)
 		b = []byte(s)
+		if isBody {
+			qp = withURLParams(qp, r.URL)
+		}
 	}
 
 	if pc.KeyHasher != nil {
 		var k string
-		k, r.Body = pc.KeyHasher(r.URL.Path, qp, r.Header, r.Body, extra)
+		k, r.Body = pc.KeyHasher(cacheKeyPath(rsc.BackendOptions, r.URL.Path),
+			qp, r.Header, r.Body, extra)
 		return k
 	}
 
 	vals := make([]string, 0, (len(pc.CacheKeyParams) + len(pc.CacheKeyHeaders) + len(pc.CacheKeyFormFields)*2))
 
-	if v := r.Header.Get(headers.NameAuthorization); v != "" {
+	// the key's components are only collected when they will be logged
+	var kc *keyComponents
+	if tl.IsDebugEnabled(rsc.Logger) {
+		kc = &keyComponents{redacted: pc.CacheKeyRedactedValues}
+	}
+
+	if pc.CacheKeyJWT != nil && rsc.CacheKeyClaim != "" {
+		// the bearer token is represented by its verified claim, so that all tokens
+		// with the same claim value share cache entries
+		n := "jwt." + pc.CacheKeyJWT.Claim
+		vals = append(vals, fmt.Sprintf("%s.%s.", n, rsc.CacheKeyClaim))
+		kc.addHeader(n, rsc.CacheKeyClaim)
+	} else if v := r.Header.Get(headers.NameAuthorization); v != "" {
 		vals = append(vals, fmt.Sprintf("%s.%s.", headers.NameAuthorization, v))
+		kc.addHeader(headers.NameAuthorization, v)
 	}
 
 	// Append the http method to the slice for creating the derived cache key
@@ -79,18 +112,45 @@ func (pr *proxyRequest) DeriveCacheKey(extra string) string {
 	if len(pc.CacheKeyParams) == 1 && pc.CacheKeyParams[0] == "*" {
 		for p := range qp {
 			vals = append(vals, fmt.Sprintf("%s.%s.", p, qp.Get(p)))
+			kc.addParam(p, qp.Get(p))
 		}
 	} else {
+		var seen map[string]bool
 		for _, p := range pc.CacheKeyParams {
+			if isKeyParamPrefix(p) {
+				// include each param starting with the prefix, once, unless it is
+				// already named exactly
+				if seen == nil {
+					seen = make(map[string]bool)
+				}
+				prefix := p[:len(p)-1]
+				for n := range qp {
+					if seen[n] || !strings.HasPrefix(n, prefix) ||
+						strutil.IndexInSlice(pc.CacheKeyParams, n) != -1 {
+						continue
+					}
+					if v := qp.Get(n); v != "" {
+						seen[n] = true
+						vals = append(vals, fmt.Sprintf("%s.%s.", n, v))
+						kc.addParam(n, v)
+					}
+				}
+				continue
+			}
 			if v := qp.Get(p); v != "" {
 				vals = append(vals, fmt.Sprintf("%s.%s.", p, v))
+				kc.addParam(p, v)
 			}
 		}
 	}
 
 	for _, p := range pc.CacheKeyHeaders {
 		if v := r.Header.Get(p); v != "" {
+			if isCaseInsensitiveHeader(pc.CacheKeyHeaderCaseInsensitive, p) {
+				v = strings.ToLower(v)
+			}
 			vals = append(vals, fmt.Sprintf("%s.%s.", p, v))
+			kc.addHeader(p, v)
 		}
 	}
 
@@ -99,7 +159,15 @@ func (pr *proxyRequest) DeriveCacheKey(extra string) string {
 		if ct == headers.ValueXFormURLEncoded ||
 			strings.HasPrefix(ct, headers.ValueMultipartFormData) || ct == headers.ValueApplicationJSON {
 			if strings.HasPrefix(ct, headers.ValueMultipartFormData) {
-				pr.ParseMultipartForm(1024 * 1024)
+				var mb []byte
+				if hasNestedFormFields(pc.CacheKeyFormFields) && pr.Request.Body != nil {
+					mb, _ = io.ReadAll(pr.Request.Body)
+					pr.Request.Body = io.NopCloser(bytes.NewReader(mb))
+				}
+				pr.ParseMultipartForm(maxMultipartKeyMemory)
+				if len(mb) > 0 {
+					pr.setNestedMultipartFields(ct, mb, pc.CacheKeyFormFields)
+				}
 			} else if ct == headers.ValueApplicationJSON {
 				var document map[string]interface{}
 				err := json.Unmarshal(b, &document)
@@ -122,48 +190,307 @@ func (pr *proxyRequest) DeriveCacheKey(extra string) string {
 			if _, ok := pr.Form[f]; ok {
 				if v := pr.FormValue(f); v != "" {
 					vals = append(vals, fmt.Sprintf("%s.%s.", f, v))
+					kc.addFormField(f, v)
 				}
 			}
 		}
 	}
 
 	sort.Strings(vals)
-	return md5.Checksum(pr.URL.Path + "." + strings.Join(vals, "") + extra)
+	path := cacheKeyPath(rsc.BackendOptions, pr.URL.Path)
+	k := md5.Checksum(path + "." + strings.Join(vals, "") + extra)
+
+	// fold in the values of any request headers named by the upstream's Vary header
+	pr.varyBase, pr.varyHeader, pr.varyNames = k, r.Header, nil
+	if v, ok := varyHeaders.Load(varyBackend(rsc), k); ok {
+		pr.varyNames = v
+		k += varyKeySuffix(pr.varyNames, r.Header)
+	}
+	if kc != nil {
+		for _, n := range pr.varyNames {
+			kc.addHeader(n, strings.Join(r.Header.Values(n), ","))
+		}
+		tl.Debug(rsc.Logger, "cache key components", kc.pairs(path, r.Method, k))
+	}
+	return k
 }
 
-func deepSearch(document map[string]interface{}, key string) (string, error) {
+// isKeyParamPrefix returns true if the cache key param name is a prefix pattern, such as
+// filter_*, which matches any param beginning with filter_
+func isKeyParamPrefix(p string) bool {
+	return len(p) > 1 && p[len(p)-1] == '*'
+}
 
-	if key == "" {
-		return "", fmt.Errorf("invalid key name: %s", key)
+// redactedValue replaces the value of any redacted cache key component in the log
+const redactedValue = "*****"
+
+// keyComponents collects the names and values of the params, headers and form fields
+// that contribute to a cache key, so they can be logged. Values of sensitive headers,
+// and of any components named in redacted, are replaced with redactedValue. All methods
+// are no-ops on a nil *keyComponents
+type keyComponents struct {
+	redacted   []string
+	params     []string
+	headers    []string
+	formFields []string
+}
+
+func (kc *keyComponents) addParam(name, value string) {
+	if kc != nil {
+		kc.params = append(kc.params, kc.format(name, value))
 	}
-	parts := strings.Split(key, "/")
-	m := document
-	l := len(parts) - 1
-	for i, p := range parts {
-		v, ok := m[p]
-		if !ok {
-			return "", errors.CouldNotFindKey(key)
+}
+
+func (kc *keyComponents) addHeader(name, value string) {
+	if kc != nil {
+		if isSensitiveKeyHeader(name) {
+			value = redactedValue
 		}
-		if l != i {
-			m, ok = v.(map[string]interface{})
-			if !ok {
-				return "", errors.CouldNotFindKey(key)
-			}
+		kc.headers = append(kc.headers, kc.format(name, value))
+	}
+}
+
+func (kc *keyComponents) addFormField(name, value string) {
+	if kc != nil {
+		kc.formFields = append(kc.formFields, kc.format(name, value))
+	}
+}
+
+func (kc *keyComponents) format(name, value string) string {
+	for _, n := range kc.redacted {
+		if strings.EqualFold(n, name) {
+			value = redactedValue
+			break
+		}
+	}
+	return name + "=" + value
+}
+
+// pairs returns the collected components as log Pairs, with each list sorted
+func (kc *keyComponents) pairs(path, method, key string) tl.Pairs {
+	sort.Strings(kc.params)
+	sort.Strings(kc.headers)
+	sort.Strings(kc.formFields)
+	return tl.Pairs{
+		"path":       path,
+		"method":     method,
+		"params":     strings.Join(kc.params, "&"),
+		"headers":    strings.Join(kc.headers, "&"),
+		"formFields": strings.Join(kc.formFields, "&"),
+		"cacheKey":   key,
+	}
+}
+
+// isSensitiveKeyHeader returns true if the named header carries credentials or session
+// state, and so its value is always redacted when cache key components are logged
+func isSensitiveKeyHeader(name string) bool {
+	return strings.EqualFold(name, headers.NameAuthorization) ||
+		strings.EqualFold(name, headers.NameCookie) ||
+		strings.EqualFold(name, headers.NameSetCookie)
+}
+
+// withURLParams returns a copy of the body params of a request, merged with the
+// request's URL query params, so both can contribute to the cache key. When a
+// param is present in both, the body's values are used
+func withURLParams(qp url.Values, u *url.URL) url.Values {
+	if u == nil || u.RawQuery == "" {
+		return qp
+	}
+	uq := u.Query()
+	out := make(url.Values, len(qp)+len(uq))
+	for k, v := range uq {
+		out[k] = v
+	}
+	for k, v := range qp {
+		out[k] = v
+	}
+	return out
+}
+
+// cacheKeyPath returns the path from which a cache key is derived, which is the rewritten
+// upstream path only when the backend has opted in with path_rewrite_cache_key
+func cacheKeyPath(o *bo.Options, path string) string {
+	if o == nil || !o.PathRewriteCacheKey {
+		return path
+	}
+	return o.RewriteUpstreamPath(path)
+}
+
+// cacheKey returns the key under which the engine stores the request's cache object,
+// which is the backend's CacheKeyPrefix, the engine name and the request hash. When the
+// backend's CacheKeyIncludePath is true, the escaped request path precedes the hash, so
+// that objects can be purged by request path prefix
+func (pr *proxyRequest) cacheKey(o *bo.Options, engine string) string {
+	if !o.CacheKeyIncludePath {
+		return o.CacheKeyPrefix + "." + engine + "." + pr.DeriveCacheKey("")
+	}
+	return o.CacheKeyPrefix + "." + engine + "." +
+		cacheKeyPathSegment(strings.TrimPrefix(pr.URL.Path, o.PathPrefix)) +
+		"." + pr.DeriveCacheKey("")
+}
+
+// cacheKeyPathSegment returns the request path escaped and truncated for inclusion in
+// a cache key
+func cacheKeyPathSegment(path string) string {
+	path = url.PathEscape(path)
+	if len(path) > maxCacheKeyPathLength {
+		path = path[:maxCacheKeyPathLength]
+	}
+	return path
+}
+
+// isCaseInsensitiveHeader returns true if the header name is in the list of header
+// names whose values are case-insensitive. header names are compared case-insensitively
+func isCaseInsensitiveHeader(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasNestedFormFields returns true if any of the provided form field names use the
+// dotted or slashed syntax to address a value nested within a multipart part
+func hasNestedFormFields(fields []string) bool {
+	for _, f := range fields {
+		if strings.IndexAny(f, "./") > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// setNestedMultipartFields resolves form field names like "meta.query.table" or
+// "meta/query/table" against the parts of a multipart body. The first segment
+// names the part; when that part is JSON, the remaining segments are resolved
+// with deepSearch, otherwise the part's raw value is used. Field names that
+// exactly match a part are left as-is.
+func (pr *proxyRequest) setNestedMultipartFields(ct string, body []byte, fields []string) {
+
+	_, ps, err := mime.ParseMediaType(ct)
+	if err != nil || ps["boundary"] == "" {
+		return
+	}
+
+	type formPart struct {
+		contentType string
+		value       []byte
+	}
+	parts := make(map[string]formPart)
+	mr := multipart.NewReader(bytes.NewReader(body), ps["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err != nil {
+			break
+		}
+		name := p.FormName()
+		if _, ok := parts[name]; ok || name == "" {
 			continue
 		}
+		b, err := io.ReadAll(io.LimitReader(p, maxMultipartKeyMemory))
+		if err != nil {
+			break
+		}
+		mt, _, _ := mime.ParseMediaType(p.Header.Get(headers.NameContentType))
+		parts[name] = formPart{contentType: mt, value: b}
+	}
 
-		if s, ok := v.(string); ok {
-			return s, nil
+	for _, f := range fields {
+		if _, ok := pr.Form[f]; ok {
+			continue
+		}
+		i := strings.IndexAny(f, "./")
+		if i < 1 {
+			continue
 		}
+		p, ok := parts[f[:i]]
+		if !ok {
+			continue
+		}
+		v := string(p.value)
+		if p.contentType == headers.ValueApplicationJSON {
+			var document map[string]interface{}
+			if err := json.Unmarshal(p.value, &document); err != nil {
+				continue
+			}
+			if v, err = deepSearch(document, strings.ReplaceAll(f[i+1:], ".", "/")); err != nil {
+				continue
+			}
+		}
+		if pr.Form == nil {
+			pr.Form = url.Values{}
+		}
+		pr.Form.Set(f, v)
+	}
+}
 
-		if i, ok := v.(float64); ok {
-			return strconv.FormatFloat(i, 'f', 4, 64), nil
+// deepSearch returns the value found at the provided slash-separated path in
+// the JSON document. A numeric path part indexes into an array, and a "*" part
+// matches every element of an array (or every value of an object, in key
+// order); all matched leaf values are joined with commas.
+func deepSearch(document map[string]interface{}, key string) (string, error) {
+
+	if key == "" {
+		return "", fmt.Errorf("invalid key name: %s", key)
+	}
+	vals := deepSearchValues(document, strings.Split(key, "/"))
+	if len(vals) == 0 {
+		return "", errors.CouldNotFindKey(key)
+	}
+	return strings.Join(vals, ","), nil
+}
+
+func deepSearchValues(v interface{}, parts []string) []string {
+
+	if len(parts) == 0 {
+		if s, ok := leafString(v); ok {
+			return []string{s}
 		}
+		return nil
+	}
 
-		if b, ok := v.(bool); ok {
-			return fmt.Sprintf("%t", b), nil
+	p := parts[0]
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if p == "*" {
+			keys := make([]string, 0, len(t))
+			for k := range t {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			var out []string
+			for _, k := range keys {
+				out = append(out, deepSearchValues(t[k], parts[1:])...)
+			}
+			return out
 		}
+		if c, ok := t[p]; ok {
+			return deepSearchValues(c, parts[1:])
+		}
+	case []interface{}:
+		if p == "*" {
+			var out []string
+			for _, c := range t {
+				out = append(out, deepSearchValues(c, parts[1:])...)
+			}
+			return out
+		}
+		if i, err := strconv.Atoi(p); err == nil && i >= 0 && i < len(t) {
+			return deepSearchValues(t[i], parts[1:])
+		}
+	}
+	return nil
+}
 
+func leafString(v interface{}) (string, bool) {
+	switch t := v.(type) {
+	case string:
+		return t, true
+	case float64:
+		return strconv.FormatFloat(t, 'f', 4, 64), true
+	case bool:
+		return fmt.Sprintf("%t", t), true
 	}
-	return "", errors.CouldNotFindKey(key)
+	return "", false
 }
@@ -19,20 +19,26 @@ package engines
 import (
 	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strconv"
+	"strings"
 	"testing"
 
 	bo "github.com/trickstercache/trickster/v2/pkg/backends/options"
 	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
 	ct "github.com/trickstercache/trickster/v2/pkg/proxy/context"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/jwt"
 	po "github.com/trickstercache/trickster/v2/pkg/proxy/paths/options"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/request"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/urls"
 	tu "github.com/trickstercache/trickster/v2/pkg/testutil"
 )
 
@@ -48,6 +54,23 @@ value2
 --------------------------d0509edbe55938c0--
 `
 
+const testMultipartJSONBody = `--------------------------d0509edbe55938c0
+Content-Disposition: form-data; name="field1"
+
+value1
+--------------------------d0509edbe55938c0
+Content-Disposition: form-data; name="meta"
+Content-Type: application/json
+
+{"query": {"table": "movies", "options": {"batchSize": 20}}}
+--------------------------d0509edbe55938c0
+Content-Disposition: form-data; name="field2"
+Content-Type: text/plain
+
+value2
+--------------------------d0509edbe55938c0--
+`
+
 const testJSONDocument = `
 {
 	"requestType": "query",
@@ -59,7 +82,12 @@ const testJSONDocument = `
 			"batchSize": 20,
 			"someArray": [ "test" ],
 			"booleanHere": true
-		}
+		},
+		"filters": [
+			{ "field": "year", "value": 1979 },
+			{ "field": "genre", "nested": [ [ "a", "b" ], [ "c" ] ] },
+			{ "value": "noField" }
+		]
 	},
 	"field1": "value1"
 }
@@ -111,6 +139,70 @@ func TestDeepSearch(t *testing.T) {
 	if err == nil {
 		t.Errorf("expected error: %s", "could not find key")
 	}
+
+	tests := []struct {
+		key      string
+		expected string
+		err      bool
+	}{
+		{"query/options/someArray/0", "test", false},
+		{"query/options/someArray/1", "", true},
+		{"query/options/someArray/-1", "", true},
+		{"query/options/someArray/x", "", true},
+		{"query/filters/0/value", "1979.0000", false},
+		{"query/filters/1/nested/0/1", "b", false},
+		{"query/filters/1/nested/1/0", "c", false},
+		{"query/filters/1/nested/2/0", "", true},
+		{"query/filters/*/field", "year,genre", false},
+		{"query/filters/*/nested/*/*", "a,b,c", false},
+		{"query/filters/*/missing", "", true},
+		{"query/options/*", "20.0000,true", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.key, func(t *testing.T) {
+			val, err := deepSearch(document, test.key)
+			if test.err {
+				if err == nil {
+					t.Errorf("expected error: %s", "could not find key")
+				}
+				return
+			}
+			if err != nil {
+				t.Error(err)
+			}
+			if val != test.expected {
+				t.Errorf("expected %s got %s", test.expected, val)
+			}
+		})
+	}
+}
+
+func TestDeriveCacheKeyPathRewrite(t *testing.T) {
+
+	cfg := &bo.Options{PathPrefix: "/base", PathRewriter: urls.NewPathRewriter(true)}
+	if err := cfg.PathRewriter.AddRule(`^/v1/metrics/(.*)$`, "/api/$1"); err != nil {
+		t.Fatal(err)
+	}
+	pc := &po.Options{Path: "/", CacheKeyParams: []string{"query"}}
+
+	deriveKey := func(path string) string {
+		r := httptest.NewRequest("GET", "http://127.0.0.1"+path+"?query=up", nil)
+		r = r.WithContext(ct.WithResources(context.Background(),
+			request.NewResources(cfg, pc, nil, nil, nil, nil, tl.ConsoleLogger("error"))))
+		return newProxyRequest(r, nil).DeriveCacheKey("")
+	}
+
+	original := deriveKey("/base/v1/metrics/cpu")
+	rewritten := deriveKey("/base/api/cpu")
+	if original == rewritten {
+		t.Error("expected the rewritten path to have a distinct key when not opted in")
+	}
+
+	cfg.PathRewriteCacheKey = true
+	if k := deriveKey("/base/v1/metrics/cpu"); k != rewritten {
+		t.Errorf("expected %s got %s", rewritten, k)
+	}
 }
 
 func TestDeriveCacheKey(t *testing.T) {
@@ -243,6 +335,235 @@ func TestDeriveCacheKeyAuthHeader(t *testing.T) {
 
 }
 
+func TestDeriveCacheKeyLogsComponents(t *testing.T) {
+
+	client, err := NewTestClient("test", &bo.Options{
+		Paths: map[string]*po.Options{
+			"root": {
+				Path:                   "/",
+				CacheKeyParams:         []string{"query", "step", "token"},
+				CacheKeyHeaders:        []string{"X-Test-Header", "Cookie"},
+				CacheKeyRedactedValues: []string{"token"},
+			},
+		},
+	}, nil, nil, nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	derive := func(logger interface{}) string {
+		tr := httptest.NewRequest("GET", "http://127.0.0.1/?query=12345&step=300&token=secret", nil)
+		tr = tr.WithContext(ct.WithResources(context.Background(),
+			request.NewResources(client.Configuration(), client.Configuration().Paths["root"],
+				nil, nil, nil, nil, logger)))
+		tr.Header.Add("Authorization", "test")
+		tr.Header.Add("X-Test-Header", "test2")
+		tr.Header.Add("Cookie", "session=abc")
+		return newProxyRequest(tr, nil).DeriveCacheKey("extra")
+	}
+
+	w := &bytes.Buffer{}
+	ck := derive(&tl.SyncLogger{Logger: tl.StreamLogger(w, "debug")})
+	out := w.String()
+
+	for _, s := range []string{"cache key components", "query=12345", "step=300",
+		"token=*****", "Authorization=*****", "Cookie=*****", "X-Test-Header=test2", ck} {
+		if !strings.Contains(out, s) {
+			t.Errorf("expected log to contain %s got %s", s, out)
+		}
+	}
+	for _, s := range []string{"secret", "session=abc", "Authorization=test"} {
+		if strings.Contains(out, s) {
+			t.Errorf("expected log to not contain %s got %s", s, out)
+		}
+	}
+
+	w.Reset()
+	if ck2 := derive(&tl.SyncLogger{Logger: tl.StreamLogger(w, "info")}); ck2 != ck {
+		t.Errorf("expected %s got %s", ck, ck2)
+	}
+	if w.Len() != 0 {
+		t.Errorf("expected no log output got %s", w.String())
+	}
+
+}
+
+func TestDeriveCacheKeyHeaderCaseInsensitive(t *testing.T) {
+
+	cfg := &bo.Options{
+		Paths: map[string]*po.Options{
+			"root": {
+				Path:                          "/",
+				CacheKeyHeaders:               []string{"X-Region", "X-Tenant"},
+				CacheKeyHeaderCaseInsensitive: []string{"x-region"},
+			},
+		},
+	}
+
+	deriveKey := func(region, tenant string) string {
+		r := httptest.NewRequest(http.MethodGet, "http://127.0.0.1/?query=12345", nil)
+		r = r.WithContext(ct.WithResources(context.Background(),
+			request.NewResources(cfg, cfg.Paths["root"], nil, nil, nil, nil, tl.ConsoleLogger("error"))))
+		r.Header.Set("X-Region", region)
+		r.Header.Set("X-Tenant", tenant)
+		return newProxyRequest(r, nil).DeriveCacheKey("")
+	}
+
+	k1 := deriveKey("US-East", "Acme")
+	if k2 := deriveKey("us-east", "Acme"); k1 != k2 {
+		t.Errorf("expected mixed-case X-Region values to share a key: %s != %s", k1, k2)
+	}
+	if k2 := deriveKey("US-EAST", "Acme"); k1 != k2 {
+		t.Errorf("expected mixed-case X-Region values to share a key: %s != %s", k1, k2)
+	}
+	// X-Tenant is not case-insensitive, so its exact case is kept
+	if k2 := deriveKey("US-East", "acme"); k1 == k2 {
+		t.Errorf("expected X-Tenant values differing by case to have different keys: %s", k1)
+	}
+}
+
+func TestDeriveCacheKeyParamPrefix(t *testing.T) {
+
+	cfg := &bo.Options{
+		Paths: map[string]*po.Options{
+			"root": {
+				Path:           "/",
+				CacheKeyParams: []string{"query", "filter_*", "filter_team"},
+			},
+		},
+	}
+
+	deriveKey := func(rawQuery string) string {
+		r := httptest.NewRequest(http.MethodGet, "http://127.0.0.1/?"+rawQuery, nil)
+		r = r.WithContext(ct.WithResources(context.Background(),
+			request.NewResources(cfg, cfg.Paths["root"], nil, nil, nil, nil, tl.ConsoleLogger("error"))))
+		return newProxyRequest(r, nil).DeriveCacheKey("")
+	}
+
+	k1 := deriveKey("query=up&filter_region=us&filter_team=a&filter_env=prod&page=1")
+
+	// params are folded into the key in sorted order, regardless of the request's order
+	if k2 := deriveKey("page=1&filter_env=prod&filter_team=a&query=up&filter_region=us"); k1 != k2 {
+		t.Errorf("expected reordered params to share a key: %s != %s", k1, k2)
+	}
+	// an unmatched param does not affect the key
+	if k2 := deriveKey("query=up&filter_region=us&filter_team=a&filter_env=prod&page=2"); k1 != k2 {
+		t.Errorf("expected an unmatched param to not affect the key: %s != %s", k1, k2)
+	}
+	if k2 := deriveKey("query=up&filter_region=us&filter_team=a&filter_env=prod"); k1 != k2 {
+		t.Errorf("expected an unmatched param to not affect the key: %s != %s", k1, k2)
+	}
+	// each matched param affects the key
+	for _, q := range []string{
+		"query=up&filter_region=eu&filter_team=a&filter_env=prod&page=1",
+		"query=up&filter_region=us&filter_team=b&filter_env=prod&page=1",
+		"query=up&filter_region=us&filter_team=a&filter_env=dev&page=1",
+		"query=up&filter_region=us&filter_team=a&page=1",
+	} {
+		if k2 := deriveKey(q); k1 == k2 {
+			t.Errorf("expected %s to have a distinct key", q)
+		}
+	}
+
+	// a param matched both exactly and by a prefix is only included once, so the
+	// key is the same as when it is only matched exactly
+	exact := deriveKey("query=up&filter_region=us&filter_team=a&filter_env=prod")
+	cfg.Paths["root"].CacheKeyParams = []string{"query", "filter_region", "filter_team", "filter_env"}
+	if k2 := deriveKey("query=up&filter_region=us&filter_team=a&filter_env=prod"); exact != k2 {
+		t.Errorf("expected %s got %s", exact, k2)
+	}
+}
+
+func TestDeriveCacheKeyURLParamsWithJSONBody(t *testing.T) {
+
+	cfg := &bo.Options{
+		Paths: map[string]*po.Options{
+			"root": {
+				Path:               "/",
+				CacheKeyParams:     []string{"tenant"},
+				CacheKeyFormFields: []string{"query/table"},
+			},
+		},
+	}
+
+	deriveKey := func(method, rawQuery, contentType, body string) string {
+		r := httptest.NewRequest(method, "http://127.0.0.1/rpc?"+rawQuery,
+			bytes.NewReader([]byte(body)))
+		r = r.WithContext(ct.WithResources(context.Background(),
+			request.NewResources(cfg, cfg.Paths["root"], nil, nil, nil, nil, tl.ConsoleLogger("error"))))
+		r.Header.Set(headers.NameContentType, contentType)
+		return newProxyRequest(r, nil).DeriveCacheKey("")
+	}
+	jsonKey := func(rawQuery, table string) string {
+		return deriveKey(http.MethodPost, rawQuery, headers.ValueApplicationJSON,
+			`{"query": {"table": "`+table+`", "limit": 10}}`)
+	}
+
+	k1 := jsonKey("tenant=acme", "movies")
+	if k2 := jsonKey("tenant=acme", "movies"); k1 != k2 {
+		t.Errorf("expected identical requests to share a key: %s != %s", k1, k2)
+	}
+	if k2 := jsonKey("tenant=acme&unkeyed=1", "movies"); k1 != k2 {
+		t.Errorf("expected unkeyed URL params to be ignored: %s != %s", k1, k2)
+	}
+	if k2 := jsonKey("tenant=globex", "movies"); k1 == k2 {
+		t.Errorf("expected the URL param to contribute to the key: %s", k1)
+	}
+	if k2 := jsonKey("tenant=acme", "shows"); k1 == k2 {
+		t.Errorf("expected the JSON body field to contribute to the key: %s", k1)
+	}
+
+	// a param present in both the URL and a form body uses the body's value
+	k1 = deriveKey(http.MethodPost, "", headers.ValueXFormURLEncoded, "tenant=acme")
+	if k2 := deriveKey(http.MethodPost, "tenant=globex", headers.ValueXFormURLEncoded,
+		"tenant=acme"); k1 != k2 {
+		t.Errorf("expected the form body value to take precedence: %s != %s", k1, k2)
+	}
+}
+
+func TestDeriveCacheKeyMultipartNested(t *testing.T) {
+
+	cfg := &bo.Options{
+		Paths: map[string]*po.Options{
+			"root": {
+				Path: "/",
+				CacheKeyFormFields: []string{"field1", "meta.query.table",
+					"meta/query/options/batchSize", "meta.missing", "field2.raw"},
+			},
+		},
+	}
+
+	newRequest := func(body string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "http://127.0.0.1/", bytes.NewReader([]byte(body)))
+		r = r.WithContext(ct.WithResources(context.Background(),
+			request.NewResources(cfg, cfg.Paths["root"], nil, nil, nil, nil, tl.ConsoleLogger("error"))))
+		r.Header.Set(headers.NameContentType, headers.ValueMultipartFormData+testMultipartBoundary)
+		r.Header.Set(headers.NameContentLength, strconv.Itoa(len(body)))
+		return r
+	}
+
+	const expected = "b1b8def3f9adaa8689ce49f21eb636cf"
+
+	pr := newProxyRequest(newRequest(testMultipartJSONBody), nil)
+	ck := pr.DeriveCacheKey("extra")
+	if ck != expected {
+		t.Errorf("expected %s got %s", expected, ck)
+	}
+	if v := pr.FormValue("meta.query.table"); v != "movies" {
+		t.Errorf("expected %s got %s", "movies", v)
+	}
+	if v := pr.FormValue("field2.raw"); v != "value2" {
+		t.Errorf("expected %s got %s", "value2", v)
+	}
+
+	// a different nested value must produce a different key
+	pr = newProxyRequest(newRequest(strings.Replace(testMultipartJSONBody,
+		`"movies"`, `"shows"`, 1)), nil)
+	if ck = pr.DeriveCacheKey("extra"); ck == expected {
+		t.Error("expected cache key to change with nested value")
+	}
+}
+
 func TestDeriveCacheKeyNoPathConfig(t *testing.T) {
 
 	client, err := NewTestClient("test", &bo.Options{
@@ -283,3 +604,54 @@ func TestDeriveCacheKeyNilURL(t *testing.T) {
 		t.Errorf("unexpected cache key: %s", k)
 	}
 }
+
+func TestDeriveCacheKeyJWTClaim(t *testing.T) {
+
+	jo := &jwt.Options{Claim: "tenant", Key: "s3cr3t"}
+	if err := jo.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &bo.Options{
+		Paths: map[string]*po.Options{
+			"root": {
+				Path:                "/",
+				CacheKeyParams:      []string{"query"},
+				CacheKeyJWT:         jo,
+				CacheKeyJWTVerifier: jwt.New(jo),
+			},
+		},
+	}
+	pc := cfg.Paths["root"]
+
+	newToken := func(tenant, user string) string {
+		enc := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+		signed := enc(`{"alg":"HS256","typ":"JWT"}`) + "." +
+			enc(`{"tenant":"`+tenant+`","sub":"`+user+`"}`)
+		m := hmac.New(sha256.New, []byte("s3cr3t"))
+		m.Write([]byte(signed))
+		return signed + "." + base64.RawURLEncoding.EncodeToString(m.Sum(nil))
+	}
+
+	deriveKey := func(token string) string {
+		rsc := request.NewResources(cfg, pc, nil, nil, nil, nil, tl.ConsoleLogger("error"))
+		r := httptest.NewRequest(http.MethodGet, "http://127.0.0.1/?query=up", nil)
+		r.Header.Set(headers.NameAuthorization, "Bearer "+token)
+		r = r.WithContext(ct.WithResources(context.Background(), rsc))
+		c, err := pc.CacheKeyJWTVerifier.Claim(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rsc.CacheKeyClaim = c
+		return newProxyRequest(r, nil).DeriveCacheKey("")
+	}
+
+	k1 := deriveKey(newToken("acme", "user1"))
+	// tokens with different tenant claims have distinct keys
+	if k2 := deriveKey(newToken("globex", "user1")); k1 == k2 {
+		t.Errorf("expected distinct keys for different tenants: %s", k1)
+	}
+	// tokens with the same tenant claim share a key
+	if k2 := deriveKey(newToken("acme", "user2")); k1 != k2 {
+		t.Errorf("expected %s got %s", k1, k2)
+	}
+}
@@ -0,0 +1,54 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"bytes"
+
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/request"
+)
+
+// replaceNegativeCacheResponse replaces the body and headers of a negative-cached
+// response with those configured for its status code, if any. It returns true
+// if the response was replaced
+func (pr *proxyRequest) replaceNegativeCacheResponse(d *HTTPDocument) bool {
+	rsc := request.GetResources(pr.Request)
+	if rsc == nil || rsc.BackendOptions == nil {
+		return false
+	}
+	nr, ok := rsc.BackendOptions.NegativeCacheResponses[d.StatusCode]
+	if !ok || nr == nil {
+		return false
+	}
+	h := pr.upstreamResponse.Header
+	for _, n := range []string{headers.NameContentLength, headers.NameContentEncoding,
+		headers.NameContentRange, headers.NameETag, headers.NameLastModified} {
+		h.Del(n)
+	}
+	if nr.ContentType != "" {
+		h.Set(headers.NameContentType, nr.ContentType)
+	}
+	for k, v := range nr.Headers {
+		h.Set(k, v)
+	}
+	// the replacement body is always served whole
+	pr.wantsRanges = false
+	pr.wantedRanges = nil
+	pr.upstreamReader = bytes.NewReader([]byte(nr.Body))
+	return true
+}
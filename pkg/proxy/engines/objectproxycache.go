@@ -27,10 +27,12 @@ import (
 	"github.com/trickstercache/trickster/v2/pkg/encoding/profile"
 	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
 	tspan "github.com/trickstercache/trickster/v2/pkg/observability/tracing/span"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/cacheevents"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/errors"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/forwarding"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/methods"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/ranges/byterange"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/request"
 
 	"go.opentelemetry.io/otel/attribute"
@@ -91,6 +93,7 @@ func handleCachePartialHit(pr *proxyRequest) error {
 		d.LoadRangeParts()
 
 		d2.Ranges = d2.RangeParts.Ranges()
+		d.dirtyRanges = d2.Ranges
 
 		d.RangeParts.Merge(d2.RangeParts)
 		d.Ranges = d.RangeParts.Ranges()
@@ -106,6 +109,8 @@ func handleCachePartialHit(pr *proxyRequest) error {
 		} else {
 			h, b := d.RangeParts.ExtractResponseRange(pr.wantedRanges, d.ContentLength, d.ContentType, nil)
 			pr.mapLock.Lock()
+			// the upstream's Content-Range describes only the fetched range, not the extracted ones
+			pr.upstreamResponse.Header.Del(headers.NameContentRange)
 			headers.Merge(pr.upstreamResponse.Header, h)
 			pr.mapLock.Unlock()
 			pr.upstreamReader = io.NopCloser(bytes.NewReader(b))
@@ -115,6 +120,7 @@ func handleCachePartialHit(pr *proxyRequest) error {
 		d.RangeParts = nil
 		d.Ranges = nil
 		d.StoredRangeParts = nil
+		d.dirtyRanges = nil
 		d.StatusCode = resp.StatusCode
 		d.headerLock.Lock()
 		http.Header(d.Headers).Del(headers.NameContentRange)
@@ -131,7 +137,22 @@ func confirmTrueCacheHit(pr *proxyRequest) (bool, error) {
 
 	pr.cachingPolicy.Merge(pr.cacheDocument.CachingPolicy)
 
-	if (!pr.checkCacheFreshness()) && (pr.cachingPolicy.CanRevalidate) {
+	isFresh := pr.checkCacheFreshness()
+	if !isFresh && pr.canServeStale() {
+		// serve the stale object now, and refresh it once the response is complete
+		pr.cacheStatus = status.LookupStatusStaleWhileRevalidate
+		pr.revalidateStale = true
+		return true, nil
+	}
+	if !isFresh && pr.canServeStaleOnCircuitOpen() {
+		// the origin is failing, so serve the stale object rather than a fast-failure
+		return true, nil
+	}
+	if !isFresh {
+		// keep the stale object in case the origin responds with an error
+		pr.staleDocument = pr.cacheDocument
+	}
+	if !isFresh && pr.cachingPolicy.CanRevalidate {
 		return false, handleCacheRevalidation(pr)
 	}
 	if !pr.cachingPolicy.IsFresh {
@@ -205,6 +226,9 @@ func handleCacheRevalidation(pr *proxyRequest) error {
 func handleCacheRevalidationResponse(pr *proxyRequest) error {
 
 	if pr.upstreamResponse.StatusCode == http.StatusNotModified {
+		if err := pr.cacheDocument.loadBody(); err != nil {
+			return handleUndecodableBody(pr, err)
+		}
 		pr.revalidation = RevalStatusOK
 		pr.cachingPolicy.IsFresh = true
 		pr.cachingPolicy.LocalDate = time.Now()
@@ -217,6 +241,9 @@ func handleCacheRevalidationResponse(pr *proxyRequest) error {
 	}
 
 	pr.revalidation = RevalStatusFailed
+	if serveStaleOnError(pr) {
+		return nil
+	}
 	pr.cacheStatus = status.LookupStatusKeyMiss
 	return handleAllWrites(pr)
 }
@@ -227,13 +254,25 @@ func handleTrueCacheHit(pr *proxyRequest) error {
 	if d == nil {
 		return errors.ErrNilCacheDocument
 	}
+	if err := d.loadBody(); err != nil {
+		return handleUndecodableBody(pr, err)
+	}
 
 	if pr.cachingPolicy.IsNegativeCache {
 		pr.cacheStatus = status.LookupStatusNegativeCacheHit
+	} else if pr.encodedVariant = pr.clientEncodedVariant(d); pr.encodedVariant != nil {
+		d = pr.encodedVariant
 	}
 
 	pr.upstreamResponse = &http.Response{StatusCode: d.StatusCode, Request: pr.Request,
 		Header: d.SafeHeaderClone()}
+	if pr.cacheStatus == status.LookupStatusStaleIfError {
+		pr.upstreamResponse.Header.Set(headers.NameWarning, headers.ValueWarningResponseIsStale+
+			", "+headers.ValueWarningRevalidationFailed)
+	}
+	if pr.cacheStatus == status.LookupStatusNegativeCacheHit && pr.replaceNegativeCacheResponse(d) {
+		return handleResponse(pr)
+	}
 	if pr.wantsRanges {
 		h, b := d.RangeParts.ExtractResponseRange(pr.wantedRanges, d.ContentLength, d.ContentType, d.Body)
 		headers.Merge(pr.upstreamResponse.Header, h)
@@ -262,9 +301,10 @@ func handleCacheKeyMiss(pr *proxyRequest) error {
 	rsc := request.GetResources(pr.Request)
 	pc := rsc.PathConfig
 
-	// if a we're using PCF, handle that separately
+	// if a we're using PCF, handle that separately, unless a stale object may need to be
+	// served in place of an error, since PCF streams the origin response to the client
 	if !methods.HasBody(pr.Method) && !pr.wantsRanges && pc != nil &&
-		pc.CollapsedForwardingType == forwarding.CFTypeProgressive {
+		pc.CollapsedForwardingType == forwarding.CFTypeProgressive && !pr.canServeStaleOnError() {
 		if err := handlePCF(pr); err != errors.ErrPCFContentLength {
 			// if err is nil, or something else, we'll proceed.
 			return err
@@ -273,6 +313,9 @@ func handleCacheKeyMiss(pr *proxyRequest) error {
 
 	pr.prepareUpstreamRequests()
 	handleUpstreamTransactions(pr)
+	if serveStaleOnError(pr) {
+		return nil
+	}
 	return handleAllWrites(pr)
 }
 
@@ -289,12 +332,11 @@ func handlePCF(pr *proxyRequest) error {
 	o := rsc.BackendOptions
 
 	pr.isPCF = true
-	pcfResult, pcfExists := reqs.Load(pr.key)
-	// a PCF session is in progress for this URL, join this client to it.
+	pcf, pcfExists := reqs.Load(pr.key)
+	// a PCF session is in progress for this cache key, join this client to it.
 	if pcfExists {
 		pr.cacheLock.Release()
 		pr.hasWriteLock = false
-		pcf := pcfResult.(ProgressiveCollapseForwarder)
 		pr.upstreamResponse = pcf.GetResp()
 		pr.mapLock.Lock()
 		pr.responseWriter = PrepareResponseWriter(pr.responseWriter, pr.upstreamResponse.StatusCode,
@@ -318,12 +360,12 @@ func handlePCF(pr *proxyRequest) error {
 	pr.responseWriter = PrepareResponseWriter(pr.responseWriter, resp.StatusCode, resp.Header)
 	// Check if we know the content length and if it is less than our max object size.
 	if contentLength > 0 && contentLength < int64(o.MaxObjectSizeBytes) {
-		pcf := NewPCF(resp, contentLength)
+		pcf = NewPCF(resp, contentLength)
 		reqs.Store(pr.key, pcf)
 		// Blocks until server completes
 
-		pr.cachingPolicy.Merge(GetResponseCachingPolicy(pr.upstreamResponse.StatusCode,
-			rsc.BackendOptions.NegativeCache, pr.upstreamResponse.Header))
+		pr.cachingPolicy.Merge(getBackendResponseCachingPolicy(rsc.BackendOptions,
+			pr.upstreamResponse))
 		pr.determineCacheability()
 
 		go func() {
@@ -334,7 +376,7 @@ func handlePCF(pr *proxyRequest) error {
 			}
 			io.Copy(dest, reader)
 			pcf.Close()
-			reqs.Delete(pr.key)
+			reqs.Delete(pr.key, pcf)
 		}()
 
 		pcf.AddClient(pr.responseWriter)
@@ -389,6 +431,10 @@ func fetchViaObjectProxyCache(w io.Writer, r *http.Request) (*http.Response, sta
 	o := rsc.BackendOptions
 	cc := rsc.CacheClient
 
+	if !rsc.KeyOnly && (rsc.ProxyOnly || !o.IsCacheablePath(r.URL.Path)) {
+		return nil, status.LookupStatusProxyOnly
+	}
+
 	pr := newProxyRequest(r, w)
 
 	_, span := tspan.NewChildSpan(r.Context(), rsc.Tracer, "ObjectProxyCacheRequest")
@@ -397,22 +443,36 @@ func fetchViaObjectProxyCache(w io.Writer, r *http.Request) (*http.Response, sta
 		defer span.End()
 	}
 
+	if pr.exceedsMaxRanges() {
+		return handleTooManyRanges(w, r), status.LookupStatusProxyError
+	}
 	pr.parseRequestRanges()
 
 	pr.cachingPolicy = GetRequestCachingPolicy(pr.Header)
 
-	pr.key = o.CacheKeyPrefix + ".opc." + pr.DeriveCacheKey("")
+	pr.key = pr.cacheKey(o, "opc")
+	if rsc.KeyOnly {
+		rsc.CacheKey = pr.key
+		return nil, status.LookupStatusPurge
+	}
+
+	// a cache bypass skips the cache read, but the fresh response is still cached
+	bypass := cacheBypassRequested(o, pr.Header, pr.cachingPolicy)
+	if bypass {
+		pr.cachingPolicy.NoCache = false
+		pr.upstreamRequest.Header.Del(o.CacheBypassHeader)
+	}
 
 	// if a PCF entry exists, or the client requested no-cache for this object, proxy out to it
-	pcfResult, pcfExists := reqs.Load(pr.key)
-	pr.isPCF = !methods.HasBody(pr.Method) && pcfExists && !pr.wantsRanges
+	pcf, pcfExists := reqs.Load(pr.key)
+	pr.isPCF = !bypass && !methods.HasBody(pr.Method) && pcfExists && !pr.wantsRanges
 
 	if pr.isPCF || pr.cachingPolicy.NoCache {
 		if pr.cachingPolicy.NoCache {
 			cc.Remove(pr.key)
+			notifyCacheEvent(rsc, cacheevents.OpPurge, pr.key, 0, 0)
 			return nil, status.LookupStatusProxyOnly
 		}
-		pcf := pcfResult.(ProgressiveCollapseForwarder)
 		pr.upstreamResponse = pcf.GetResp()
 		pr.mapLock.Lock()
 		writer := PrepareResponseWriter(w, pr.upstreamResponse.StatusCode, pr.upstreamResponse.Header)
@@ -424,13 +484,26 @@ func fetchViaObjectProxyCache(w io.Writer, r *http.Request) (*http.Response, sta
 	pr.cachingPolicy.ParseClientConditionals()
 
 	if !rsc.NoLock {
-		pr.cacheLock, _ = cc.Locker().RAcquire(pr.key)
+		var err error
+		pr.cacheLock, err = cc.Locker().RAcquireWithTimeout(pr.key, rsc.BackendOptions.CollapsedForwardingTimeout)
+		if err != nil {
+			if resp := handleCollapsedForwardingTimeout(w, r); resp != nil {
+				return resp, status.LookupStatusProxyError
+			}
+			return nil, status.LookupStatusProxyOnly
+		}
 		pr.hasReadLock = true
 	}
 
 	var err error
-	pr.cacheDocument, pr.cacheStatus, pr.neededRanges, err =
-		QueryCache(pr.upstreamRequest.Context(), cc, pr.key, pr.wantedRanges, nil)
+	if bypass {
+		pr.cacheStatus, pr.neededRanges = status.LookupStatusKeyMiss, pr.wantedRanges
+	} else {
+		lookupStart := time.Now()
+		pr.cacheDocument, pr.cacheStatus, pr.neededRanges, err = queryObject(pr, cc)
+		pr.isCached = err == nil
+		rsc.ServerTiming.AddCacheLookup(time.Since(lookupStart))
+	}
 	if err == nil || err == cache.ErrKNF {
 		if f, ok := cacheResponseHandlers[pr.cacheStatus]; ok {
 			f(pr)
@@ -456,6 +529,10 @@ func fetchViaObjectProxyCache(w io.Writer, r *http.Request) (*http.Response, sta
 		return nil, status.LookupStatusRevalidated
 	}
 
+	if pr.revalidateStale {
+		revalidateStaleObject(pr)
+	}
+
 	// newProxyRequest sets pr.started to time.Now()
 	pr.elapsed = time.Since(pr.started)
 	el := float64(pr.elapsed.Milliseconds()) / 1000.0
@@ -501,6 +578,29 @@ func recordOPCResult(pr *proxyRequest, cacheStatus status.LookupStatus, httpStat
 	pr.mapLock.Unlock()
 }
 
+// queryObject queries the cache for the request's object. When the cache stores separate
+// metadata records, only the object's metadata is decoded, so that a stale object can be
+// revalidated without decoding its body. Objects holding partial content are always fully
+// decoded, since the ranges they hold determine the lookup status
+func queryObject(pr *proxyRequest, cc cache.Cache) (*HTTPDocument, status.LookupStatus,
+	byterange.Ranges, error) {
+	ctx := pr.upstreamRequest.Context()
+	if co := cc.Configuration(); !co.SeparateMetadata || co.UseCacheChunking || pr.wantsRanges {
+		return QueryCache(ctx, cc, pr.key, pr.wantedRanges, nil)
+	}
+	return queryCacheMetadata(ctx, cc, pr.key, pr.wantedRanges)
+}
+
+// handleUndecodableBody fetches the object from the origin as a cache miss when the
+// body of a document whose metadata alone was queried from the cache can't be decoded
+func handleUndecodableBody(pr *proxyRequest, err error) error {
+	tl.Error(pr.Logger, "cache object body decoding failed", tl.Pairs{"detail": err.Error()})
+	pr.cacheDocument, pr.staleDocument, pr.revalidationRequest = nil, nil, nil
+	pr.revalidation = RevalStatusNone
+	pr.cacheStatus = status.LookupStatusKeyMiss
+	return handleCacheKeyMiss(pr)
+}
+
 func upgradeLock(pr *proxyRequest) (bool, bool) {
 	if pr.hasReadLock && !pr.hasWriteLock {
 		wasFirst := pr.cacheLock.Upgrade()
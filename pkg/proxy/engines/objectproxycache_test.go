@@ -18,17 +18,27 @@ package engines
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/trickstercache/mockster/pkg/mocks/byterange"
+	"github.com/trickstercache/trickster/v2/pkg/cache/negative"
 	"github.com/trickstercache/trickster/v2/pkg/cache/status"
 	"github.com/trickstercache/trickster/v2/pkg/locks"
+	"github.com/trickstercache/trickster/v2/pkg/observability/metrics"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/cacheevents"
+	ceo "github.com/trickstercache/trickster/v2/pkg/proxy/cacheevents/options"
 	tc "github.com/trickstercache/trickster/v2/pkg/proxy/context"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/errors"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/forwarding"
@@ -36,6 +46,9 @@ import (
 	po "github.com/trickstercache/trickster/v2/pkg/proxy/paths/options"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/request"
 	tu "github.com/trickstercache/trickster/v2/pkg/testutil"
+	"github.com/trickstercache/trickster/v2/pkg/util/middleware"
+
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 func setupTestHarnessOPC(file, body string, code int,
@@ -159,6 +172,158 @@ func TestObjectProxyCacheRequest(t *testing.T) {
 
 }
 
+// originFetchCount returns the number of observations in the origin request duration
+// histogram for the named backend
+func originFetchCount(t *testing.T, backendName string) uint64 {
+	metrics.ProxyOriginRequestDuration.WithLabelValues(backendName)
+	mfs, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mf := range mfs {
+		if mf.GetName() != "trickster_proxy_origin_request_duration_seconds" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "backend_name" && l.GetValue() == backendName {
+					return m.GetHistogram().GetSampleCount()
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func TestObjectProxyCacheRequestOriginDuration(t *testing.T) {
+
+	hdrs := map[string]string{"Cache-Control": "max-age=60"}
+	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusOK, hdrs)
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	o := rsc.BackendOptions
+	r.URL.Path = "/opc/origin-duration"
+	count := originFetchCount(t, o.Name)
+
+	_, e := testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+	if v := originFetchCount(t, o.Name); v != count+1 {
+		t.Errorf("expected %d got %d", count+1, v)
+	}
+
+	// a cache hit must not be observed as an origin fetch
+	_, e = testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "hit"})
+	for _, err = range e {
+		t.Error(err)
+	}
+	if v := originFetchCount(t, o.Name); v != count+1 {
+		t.Errorf("expected %d got %d", count+1, v)
+	}
+}
+
+func TestObjectProxyCacheRequestBrotliPartialContent(t *testing.T) {
+
+	hdrs := map[string]string{
+		"Cache-Control":    "max-age=60",
+		"Content-Encoding": "br",
+		"Content-Range":    "bytes 0-3/8",
+	}
+	ts, _, r, _, err := setupTestHarnessOPC("", "test", http.StatusPartialContent, hdrs)
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	r.Header.Add(headers.NameRange, "bytes=0-3")
+
+	w, e := testFetchOPC(r, http.StatusPartialContent, "test", map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+	if ce := w.Header().Get(headers.NameContentEncoding); ce != "br" {
+		t.Errorf("expected %s got %s", "br", ce)
+	}
+	if cr := w.Header().Get(headers.NameContentRange); cr != "bytes 0-3/8" {
+		t.Errorf("expected %s got %s", "bytes 0-3/8", cr)
+	}
+
+	// the encoded range must not have been cached, so this is another miss
+	_, e = testFetchOPC(r, http.StatusPartialContent, "test", map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+}
+
+func TestObjectProxyCacheRequestNonCacheablePath(t *testing.T) {
+
+	hdrs := map[string]string{"Cache-Control": "max-age=60"}
+	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusOK, hdrs)
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	o := rsc.BackendOptions
+	o.NonCacheablePathPatterns = []*regexp.Regexp{regexp.MustCompile(`^/opc$`)}
+
+	_, e := testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "proxy-only"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	// a denied path is never written to the cache, so it is proxied again
+	_, e = testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "proxy-only"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	o.NonCacheablePathPatterns = nil
+	o.CacheablePathPatterns = []*regexp.Regexp{regexp.MustCompile(`^/opc$`)}
+
+	_, e = testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	_, e = testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "hit"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+}
+
+func TestObjectProxyCacheKeyOnly(t *testing.T) {
+
+	ts, w, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusOK, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	pr := newProxyRequest(r, w)
+	expected := rsc.BackendOptions.CacheKeyPrefix + ".opc." + pr.DeriveCacheKey("")
+
+	rsc.KeyOnly = true
+	rw := httptest.NewRecorder()
+	ObjectProxyCacheRequest(rw, r)
+
+	if rsc.CacheKey != expected {
+		t.Errorf("expected %s got %s", expected, rsc.CacheKey)
+	}
+	if rw.Body.Len() != 0 {
+		t.Errorf("expected empty body got %s", rw.Body.String())
+	}
+	if _, _, err := rsc.CacheClient.Retrieve(expected, true); err == nil {
+		t.Error("expected key-only request to not populate the cache")
+	}
+}
+
 func TestObjectProxyCachePartialHit(t *testing.T) {
 
 	ts, _, r, rsc, err := setupTestHarnessOPCRange(nil)
@@ -625,6 +790,85 @@ func TestFetchViaObjectProxyCacheRequestClientNoCache(t *testing.T) {
 	}
 }
 
+func TestObjectProxyCacheRequestBypassHeader(t *testing.T) {
+
+	hdrs := map[string]string{"Cache-Control": "max-age=60"}
+	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusOK, hdrs)
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	o := rsc.BackendOptions
+	o.AllowCacheBypassHeader = true
+	r.URL.Path = "/opc/bypass"
+
+	_, e := testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	// the bypass skips the cached object and goes to the origin
+	r.Header.Set(o.CacheBypassHeader, "true")
+	count := originFetchCount(t, o.Name)
+	_, e = testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+	if v := originFetchCount(t, o.Name); v != count+1 {
+		t.Errorf("expected %d got %d", count+1, v)
+	}
+
+	// the fresh response was written back to the cache
+	r.Header.Del(o.CacheBypassHeader)
+	_, e = testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "hit"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	// client no-cache refreshes the object rather than purging it
+	o.RefreshOnClientNoCache = true
+	r.Header.Set(headers.NameCacheControl, headers.ValueNoCache)
+	_, e = testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	r.Header.Del(headers.NameCacheControl)
+	_, e = testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "hit"})
+	for _, err = range e {
+		t.Error(err)
+	}
+}
+
+func TestObjectProxyCacheRequestBypassHeaderDisabled(t *testing.T) {
+
+	hdrs := map[string]string{"Cache-Control": "max-age=60"}
+	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusOK, hdrs)
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	o := rsc.BackendOptions
+	if o.AllowCacheBypassHeader {
+		t.Errorf("expected %t got %t", false, o.AllowCacheBypassHeader)
+	}
+	r.URL.Path = "/opc/bypass-disabled"
+
+	_, e := testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	// the bypass header is ignored when the backend does not allow it
+	r.Header.Set(o.CacheBypassHeader, "true")
+	_, e = testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "hit"})
+	for _, err = range e {
+		t.Error(err)
+	}
+}
+
 func TestObjectProxyCacheRequestOriginNoCache(t *testing.T) {
 
 	headers := map[string]string{"Cache-Control": "no-cache"}
@@ -700,6 +944,47 @@ func TestObjectProxyCacheINM(t *testing.T) {
 	}
 }
 
+func TestObjectProxyCacheINMQuotedETag(t *testing.T) {
+
+	tests := []struct {
+		etag, inm    string
+		expectedCode int
+		expectedBody string
+	}{
+		{`"test"`, `"test"`, http.StatusNotModified, ""},
+		{`"test"`, `W/"test"`, http.StatusNotModified, ""},
+		{`W/"test"`, `"test"`, http.StatusNotModified, ""},
+		{`"test"`, `"other", "test"`, http.StatusNotModified, ""},
+		{`"test"`, `"other"`, http.StatusOK, "test"},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			rh := map[string]string{headers.NameCacheControl: "max-age=60", headers.NameETag: test.etag}
+			ts, _, r, _, err := setupTestHarnessOPC("", "test", http.StatusOK, rh)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ts.Close()
+
+			_, e := testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "kmiss"})
+			for _, err = range e {
+				t.Error(err)
+			}
+
+			r.Header.Set(headers.NameIfNoneMatch, test.inm)
+			w, e := testFetchOPC(r, test.expectedCode, test.expectedBody,
+				map[string]string{"status": "hit"})
+			for _, err = range e {
+				t.Error(err)
+			}
+			if v := w.Result().Header.Get(headers.NameETag); v != test.etag {
+				t.Errorf("expected %s got %s", test.etag, v)
+			}
+		})
+	}
+}
+
 func TestObjectProxyCacheNoRevalidate(t *testing.T) {
 
 	headers := map[string]string{headers.NameCacheControl: headers.ValueMaxAge + "=1"}
@@ -782,6 +1067,47 @@ func TestObjectProxyCacheRevalidated(t *testing.T) {
 	}
 }
 
+func TestObjectProxyCacheLastModified(t *testing.T) {
+
+	const dt = "Sun, 16 Jun 2019 14:19:04 GMT"
+
+	hdr := map[string]string{
+		headers.NameCacheControl: headers.ValueMaxAge + "=60",
+		headers.NameLastModified: dt,
+	}
+	ts, _, r, _, err := setupTestHarnessOPC("", "test", http.StatusOK, hdr)
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	_, e := testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	// modified since the client's date
+	r.Header.Set(headers.NameIfModifiedSince, "Sat, 15 Jun 2019 14:19:04 GMT")
+	_, e = testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "hit"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	// not modified since the client's date
+	r.Header.Set(headers.NameIfModifiedSince, "Mon, 17 Jun 2019 14:19:04 GMT")
+	_, e = testFetchOPC(r, http.StatusNotModified, "", map[string]string{"status": "hit"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	// malformed dates are ignored
+	r.Header.Set(headers.NameIfModifiedSince, "not a date")
+	_, e = testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "hit"})
+	for _, err = range e {
+		t.Error(err)
+	}
+}
+
 func TestObjectProxyCacheRequestNegativeCache(t *testing.T) {
 
 	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusNotFound, nil)
@@ -818,6 +1144,127 @@ func TestObjectProxyCacheRequestNegativeCache(t *testing.T) {
 	}
 }
 
+func TestObjectProxyCacheRequestNegativeCacheConditionalPurge(t *testing.T) {
+
+	ts, w, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusNotFound, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	pc := po.New()
+	cfg := rsc.BackendOptions
+	cfg.Paths = map[string]*po.Options{
+		"/": pc,
+	}
+	cfg.NegativeCache[404] = time.Second * 30
+	r = r.WithContext(tc.WithResources(r.Context(), request.NewResources(cfg, pc, rsc.CacheConfig,
+		rsc.CacheClient, rsc.BackendClient, nil, rsc.Logger)))
+
+	_, e := testFetchOPC(r, http.StatusNotFound, "test", map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+	_, e = testFetchOPC(r, http.StatusNotFound, "test", map[string]string{"status": "nchit"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	// a conditional request is served the cached 404 in full, rather than a 304
+	for _, h := range []string{headers.NameIfNoneMatch, headers.NameIfModifiedSince} {
+		v := `"abc"`
+		if h == headers.NameIfModifiedSince {
+			v = time.Now().UTC().Format(time.RFC1123)
+		}
+		r.Header.Set(h, v)
+		_, e = testFetchOPC(r, http.StatusNotFound, "test", map[string]string{"status": "nchit"})
+		for _, err = range e {
+			t.Error(h, err)
+		}
+		r.Header.Del(h)
+	}
+
+	// purging the negative-cached entry by its key sends the next request to the origin
+	key := cfg.CacheKeyPrefix + ".opc." + newProxyRequest(r, w).DeriveCacheKey("")
+	if _, _, err = rsc.CacheClient.Retrieve(key, false); err != nil {
+		t.Fatalf("expected negative-cached entry for %s: %v", key, err)
+	}
+	rsc.CacheClient.Remove(key)
+	_, e = testFetchOPC(r, http.StatusNotFound, "test", map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+}
+
+func TestObjectProxyCacheRequestNegativeCacheResponse(t *testing.T) {
+
+	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusNotFound, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	pc := po.New()
+	cfg := rsc.BackendOptions
+	cfg.Paths = map[string]*po.Options{
+		"/": pc,
+	}
+	cfg.NegativeCache[404] = time.Second * 30
+	cfg.NegativeCacheResponses = negative.ResponseLookup{404: {Body: `{"error":"not found"}`,
+		ContentType: headers.ValueApplicationJSON, Headers: map[string]string{"X-Test": "1"}}}
+	r = r.WithContext(tc.WithResources(r.Context(), request.NewResources(cfg, pc, rsc.CacheConfig,
+		rsc.CacheClient, rsc.BackendClient, nil, rsc.Logger)))
+
+	// the original response is served when first received
+	_, e := testFetchOPC(r, http.StatusNotFound, "test", map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	// the replacement is served for the negative-cached entry
+	w, e := testFetchOPC(r, http.StatusNotFound, `{"error":"not found"}`,
+		map[string]string{"status": "nchit"})
+	for _, err = range e {
+		t.Error(err)
+	}
+	if ct := w.Header().Get(headers.NameContentType); ct != headers.ValueApplicationJSON {
+		t.Errorf("expected %s got %s", headers.ValueApplicationJSON, ct)
+	}
+	if v := w.Header().Get("X-Test"); v != "1" {
+		t.Errorf("expected %s got %s", "1", v)
+	}
+}
+
+func TestObjectProxyCacheRequestMaxCacheableObjectBytes(t *testing.T) {
+
+	hdrs := map[string]string{"Cache-Control": "max-age=60"}
+	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusOK, hdrs)
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	// the document is just over the limit, so it is served but not cached
+	rsc.BackendOptions.MaxCacheableObjectBytes = 1
+
+	for i := 0; i < 2; i++ {
+		_, e := testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "kmiss"})
+		for _, err = range e {
+			t.Error(err)
+		}
+	}
+
+	rsc.BackendOptions.MaxCacheableObjectBytes = 0
+	_, e := testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+	_, e = testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "hit"})
+	for _, err = range e {
+		t.Error(err)
+	}
+}
+
 func TestHandleCacheRevalidation(t *testing.T) {
 
 	ts, _, r, _, err := setupTestHarnessOPC("", "test", http.StatusNotFound, nil)
@@ -1111,6 +1558,106 @@ func TestRangesExhaustive(t *testing.T) {
 	}
 }
 
+func TestObjectProxyCacheRequestOverlappingRanges(t *testing.T) {
+
+	ts, _, r, rsc, err := setupTestHarnessOPCRange(nil)
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	rsc.BackendOptions.RevalidationFactor = 2
+	r.URL.Path = "/byterange/test/overlapping"
+
+	req := r.Clone(context.Background())
+	full, err := getExpectedRangeBody(req, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.Header.Set(headers.NameRange, "bytes=0-6")
+	_, e := testFetchOPC(r, http.StatusPartialContent, full[0:7], map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	// out of order, overlapping and adjacent ranges are coalesced for the cache lookup,
+	// but each is still returned to the client as requested
+	r.Header.Set(headers.NameRange, "bytes=10-20,0-12,13-15")
+	w, _ := testFetchOPC(r, http.StatusPartialContent, "", map[string]string{"status": "phit"})
+	resp := w.Result()
+	_, params, err := mime.ParseMediaType(resp.Header.Get(headers.NameContentType))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mr := multipart.NewReader(strings.NewReader(w.Body.String()), params["boundary"])
+	expected := []struct {
+		start, end int
+	}{{0, 12}, {10, 20}, {13, 15}}
+	for i, ex := range expected {
+		p, err := mr.NextPart()
+		if err != nil {
+			t.Fatalf("part %d: %v", i, err)
+		}
+		cr := fmt.Sprintf("bytes %d-%d/%d", ex.start, ex.end, len(full))
+		if v := p.Header.Get(headers.NameContentRange); v != cr {
+			t.Errorf("part %d: expected %s got %s", i, cr, v)
+		}
+		b, _ := io.ReadAll(p)
+		if string(b) != full[ex.start:ex.end+1] {
+			t.Errorf("part %d: expected %s got %s", i, full[ex.start:ex.end+1], string(b))
+		}
+	}
+	if _, err := mr.NextPart(); err != io.EOF {
+		t.Errorf("expected %v got %v", io.EOF, err)
+	}
+}
+
+func TestObjectProxyCacheRequestMaxRanges(t *testing.T) {
+
+	ts, _, r, rsc, err := setupTestHarnessOPCRange(nil)
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	rsc.BackendOptions.MaxRangesPerRequest = 2
+	rsc.BackendOptions.MaxRangesStatusCode = http.StatusRequestedRangeNotSatisfiable
+
+	r.URL.Path = "/byterange/test/max-ranges"
+	r.Header.Set(headers.NameRange, "bytes=0-1,2-3,4-5")
+	_, e := testFetchOPC(r, http.StatusRequestedRangeNotSatisfiable, "", nil)
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	rsc.BackendOptions.MaxRangesStatusCode = http.StatusBadRequest
+	_, e = testFetchOPC(r, http.StatusBadRequest, "", nil)
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	// the rejected requests did no cache work, so a permitted request is a key miss
+	r.Header.Set(headers.NameRange, "bytes=0-6,25-32")
+	req := r.Clone(context.Background())
+	expectedBody, err := getExpectedRangeBody(req, "563a7014513fc6f0cbb4e8632dd107fc")
+	if err != nil {
+		t.Error(err)
+	}
+	_, e = testFetchOPC(r, http.StatusPartialContent, expectedBody, map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	// a limit of 0 permits any number of ranges
+	rsc.BackendOptions.MaxRangesPerRequest = 0
+	r.Header.Set(headers.NameRange, "bytes=0-1,2-3,4-5")
+	w, _ := testFetchOPC(r, http.StatusPartialContent, "", nil)
+	if w.Code != http.StatusPartialContent {
+		t.Errorf("expected %d got %d", http.StatusPartialContent, w.Code)
+	}
+}
+
 func testFetchOPC(r *http.Request, sc int, body string,
 	match map[string]string) (*httptest.ResponseRecorder, []error) {
 
@@ -1185,3 +1732,363 @@ func TestRerunRequest(t *testing.T) {
 		t.Error("expected true")
 	}
 }
+
+func TestObjectProxyCacheRequestMaxResponseBytes(t *testing.T) {
+
+	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusOK, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+
+	// the origin streams a cacheable 8KB body in chunks, with or without a Content-Length
+	chunk := strings.Repeat("x", 1024)
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headers.NameCacheControl, "max-age=60")
+		if r.URL.Query().Get("advertise") == "1" {
+			w.Header().Set(headers.NameContentLength, strconv.Itoa(8*len(chunk)))
+		}
+		w.WriteHeader(http.StatusOK)
+		for i := 0; i < 8; i++ {
+			w.Write([]byte(chunk))
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer origin.Close()
+	r.URL.Host = strings.TrimPrefix(origin.URL, "http://")
+	r.URL.Path = "/opc/max-response-bytes"
+
+	o := rsc.BackendOptions
+	o.MaxResponseBytes = 4096
+	o.MaxResponseBytesStatusCode = http.StatusBadGateway
+
+	// the response advertising an oversized body is abandoned, and nothing is cached or
+	// written to the client
+	r.URL.RawQuery = "advertise=1"
+	for i := 0; i < 2; i++ {
+		_, e := testFetchOPC(r, http.StatusBadGateway, "", map[string]string{"status": "kmiss"})
+		for _, err = range e {
+			t.Error(err)
+		}
+	}
+
+	// the streamed response is cut off at the limit, and is not cached
+	r.URL.RawQuery = "advertise=0"
+	for i := 0; i < 2; i++ {
+		rsc.ResponseTooLarge = false
+		_, e := testFetchOPC(r, http.StatusOK, strings.Repeat(chunk, 4),
+			map[string]string{"status": "kmiss"})
+		for _, err = range e {
+			t.Error(err)
+		}
+		if !rsc.ResponseTooLarge {
+			t.Error("expected response to be marked as too large")
+		}
+	}
+
+	// a response within the limit is cached as usual
+	rsc.ResponseTooLarge = false
+	o.MaxResponseBytes = 8192
+	body := strings.Repeat(chunk, 8)
+	_, e := testFetchOPC(r, http.StatusOK, body, map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+	_, e = testFetchOPC(r, http.StatusOK, body, map[string]string{"status": "hit"})
+	for _, err = range e {
+		t.Error(err)
+	}
+}
+
+func TestObjectProxyCacheRequestClientCacheControl(t *testing.T) {
+
+	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusOK, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headers.NameCacheControl, "max-age=300")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("test"))
+	}))
+	defer origin.Close()
+	r.URL.Host = strings.TrimPrefix(origin.URL, "http://")
+	r.URL.Path = "/opc/client-cache-control"
+
+	h := middleware.RewriteClientCacheControl("no-store",
+		http.HandlerFunc(ObjectProxyCacheRequest))
+
+	// clients are told not to store the response, but Trickster caches it per the origin
+	for _, s := range []string{"kmiss", "hit"} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		resp := w.Result()
+		if v := resp.Header.Get(headers.NameCacheControl); v != "no-store" {
+			t.Errorf("expected %s got %s", "no-store", v)
+		}
+		if err = testResultHeaderPartMatch(resp.Header, map[string]string{"status": s}); err != nil {
+			t.Error(err)
+		}
+	}
+
+	key := rsc.BackendOptions.CacheKeyPrefix + ".opc." + newProxyRequest(r, nil).DeriveCacheKey("")
+	d, _, _, err := QueryCache(r.Context(), rsc.CacheClient, key, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.CachingPolicy.FreshnessLifetime != 300 || d.CachingPolicy.NoCache {
+		t.Errorf("expected %d got %d", 300, d.CachingPolicy.FreshnessLifetime)
+	}
+}
+
+func TestObjectProxyCacheRequestCacheEvents(t *testing.T) {
+
+	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusOK, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headers.NameCacheControl, "max-age=300")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("test"))
+	}))
+	defer origin.Close()
+	r.URL.Host = strings.TrimPrefix(origin.URL, "http://")
+	r.URL.Path = "/opc/cache-events"
+
+	events := make(chan cacheevents.Event, 4)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e cacheevents.Event
+		json.NewDecoder(r.Body).Decode(&e)
+		events <- e
+	}))
+	defer webhook.Close()
+	rsc.BackendOptions.CacheEventNotifier = cacheevents.New("test",
+		&ceo.Options{WebhookURL: webhook.URL, BufferSize: 4, Timeout: time.Second})
+	defer func() { rsc.BackendOptions.CacheEventNotifier = nil }()
+
+	key := rsc.BackendOptions.CacheKeyPrefix + ".opc." + newProxyRequest(r, nil).DeriveCacheKey("")
+
+	// the write to the cache on a miss is reported, and then the client's no-cache purge
+	ObjectProxyCacheRequest(httptest.NewRecorder(), r)
+	r.Header.Set(headers.NameCacheControl, headers.ValueNoCache)
+	ObjectProxyCacheRequest(httptest.NewRecorder(), r)
+
+	for _, op := range []string{cacheevents.OpStore, cacheevents.OpPurge} {
+		select {
+		case e := <-events:
+			if e.Op != op || e.Key != key || e.Backend != "test" {
+				t.Errorf("expected %s %s %s got %s %s %s", op, key, "test", e.Op, e.Key, e.Backend)
+			}
+			if op == cacheevents.OpStore && (e.Size != 4 || e.TTLMS <= 0) {
+				t.Errorf("expected size %d and a ttl got %d %d", 4, e.Size, e.TTLMS)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %s event", op)
+		}
+	}
+}
+
+func TestObjectProxyCacheRequestUncacheablePurgeEvents(t *testing.T) {
+
+	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusOK, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+
+	var requests int32
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			// the first response is stored, and revalidated by the next request
+			w.Header().Set(headers.NameCacheControl, "max-age=0")
+			w.Header().Set(headers.NameETag, `"v1"`)
+		} else {
+			w.Header().Set(headers.NameCacheControl, headers.ValueNoStore)
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("test"))
+	}))
+	defer origin.Close()
+	r.URL.Host = strings.TrimPrefix(origin.URL, "http://")
+	r.URL.Path = "/opc/uncacheable-purge-events"
+
+	events := make(chan cacheevents.Event, 4)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e cacheevents.Event
+		json.NewDecoder(r.Body).Decode(&e)
+		events <- e
+	}))
+	defer webhook.Close()
+	rsc.BackendOptions.CacheEventNotifier = cacheevents.New("test",
+		&ceo.Options{WebhookURL: webhook.URL, BufferSize: 4, Timeout: time.Second})
+	defer func() { rsc.BackendOptions.CacheEventNotifier = nil }()
+
+	// the stored object is purged when its revalidation response can't be cached,
+	// while the uncacheable response to the next request, with nothing cached,
+	// reports no purge
+	for i := 0; i < 3; i++ {
+		ObjectProxyCacheRequest(httptest.NewRecorder(), r)
+	}
+
+	for _, op := range []string{cacheevents.OpStore, cacheevents.OpPurge} {
+		select {
+		case e := <-events:
+			if e.Op != op {
+				t.Errorf("expected %s got %s", op, e.Op)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %s event", op)
+		}
+	}
+	select {
+	case e := <-events:
+		t.Errorf("unexpected %s event", e.Op)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if n := atomic.LoadInt32(&requests); n != 3 {
+		t.Errorf("expected %d got %d", 3, n)
+	}
+}
+
+func TestObjectProxyCacheRequestNonCacheableResponseHeaders(t *testing.T) {
+
+	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusOK, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headers.NameCacheControl, "max-age=300")
+		w.Header().Set(headers.NameSetCookie, "session=abc123")
+		w.Header().Set("X-Request-ID", "req-1")
+		w.Header().Set("X-Origin", "test")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("test"))
+	}))
+	defer origin.Close()
+	r.URL.Host = strings.TrimPrefix(origin.URL, "http://")
+	r.URL.Path = "/opc/non-cacheable-response-headers"
+
+	rsc.BackendOptions.NonCacheableResponseHeaders = []string{headers.NameSetCookie, "x-request-id"}
+	defer func() { rsc.BackendOptions.NonCacheableResponseHeaders = nil }()
+
+	tests := []struct {
+		status   string
+		expected map[string]string
+	}{
+		// the client receiving the miss gets the origin's headers
+		{"kmiss", map[string]string{headers.NameSetCookie: "session=abc123",
+			"X-Request-ID": "req-1", "X-Origin": "test"}},
+		// but they are not replayed from the cache
+		{"hit", map[string]string{headers.NameSetCookie: "", "X-Request-ID": "",
+			"X-Origin": "test"}},
+	}
+
+	for i, test := range tests {
+		w := httptest.NewRecorder()
+		ObjectProxyCacheRequest(w, r)
+		resp := w.Result()
+		if err = testResultHeaderPartMatch(resp.Header,
+			map[string]string{"status": test.status}); err != nil {
+			t.Errorf("test %d: %s", i, err.Error())
+		}
+		for k, v := range test.expected {
+			if resp.Header.Get(k) != v {
+				t.Errorf("test %d expected %s for %s got %s", i, v, k, resp.Header.Get(k))
+			}
+		}
+	}
+}
+
+func TestObjectProxyCacheRequestSeparateMetadata(t *testing.T) {
+
+	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusOK, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+
+	var notModified bool
+	var inm []string
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inm = append(inm, r.Header.Get(headers.NameIfNoneMatch))
+		w.Header().Set(headers.NameETag, `"v1"`)
+		w.Header().Set(headers.NameCacheControl, "max-age=60")
+		if notModified && r.Header.Get(headers.NameIfNoneMatch) == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("new"))
+	}))
+	defer origin.Close()
+	r.URL.Host = strings.TrimPrefix(origin.URL, "http://")
+	r.URL.Path = "/opc/separate-metadata"
+
+	// make the cache not appear to be a memory cache, so objects are serialized
+	cc := rsc.CacheClient.Configuration()
+	provider := cc.Provider
+	cc.Provider, cc.SeparateMetadata = "test", true
+	defer func() { cc.Provider, cc.SeparateMetadata = provider, false }()
+
+	ctx := tc.WithResources(context.Background(), rsc)
+	key := newProxyRequest(r, nil).cacheKey(rsc.BackendOptions, "opc")
+
+	tests := []struct {
+		corrupt     bool
+		notModified bool
+		body        string
+		status      string
+		expected    []string
+	}{
+		// the stale object is revalidated and its cached body is served
+		{false, true, "test", "rhit", []string{`"v1"`}},
+		// the origin sends new content, so the undecodable cached body is never decoded
+		{true, false, "new", "kmiss", []string{`"v1"`}},
+		// the cached body is needed but can't be decoded, so the object is refetched
+		{true, true, "new", "kmiss", []string{`"v1"`, ""}},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			// store a stale, revalidatable object
+			d := newSeparateMetadataTestDocument([]byte("test"))
+			d.CachingPolicy = &CachingPolicy{ETag: `"v1"`, CanRevalidate: true,
+				FreshnessLifetime: 60, LocalDate: time.Now().Add(-time.Hour)}
+			if err := WriteCache(ctx, rsc.CacheClient, key, d, time.Minute, nil, nil); err != nil {
+				t.Fatal(err)
+			}
+			if test.corrupt {
+				b, _, _ := rsc.CacheClient.Retrieve(key, false)
+				meta, _, err := splitMetadata(b)
+				if err != nil || meta == nil {
+					t.Fatal("expected object stored with a metadata record")
+				}
+				rsc.CacheClient.Store(key, withMetadata(meta, []byte{compressionBrotli, 0xff}),
+					time.Minute)
+			}
+			notModified, inm = test.notModified, nil
+
+			w := httptest.NewRecorder()
+			ObjectProxyCacheRequest(w, r)
+			resp := w.Result()
+			body, _ := io.ReadAll(resp.Body)
+			if string(body) != test.body {
+				t.Errorf("expected %s got %s", test.body, string(body))
+			}
+			if err := testResultHeaderPartMatch(resp.Header,
+				map[string]string{"status": test.status}); err != nil {
+				t.Error(err)
+			}
+			if strings.Join(inm, ",") != strings.Join(test.expected, ",") {
+				t.Errorf("expected %v got %v", test.expected, inm)
+			}
+		})
+	}
+}
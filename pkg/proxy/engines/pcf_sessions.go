@@ -0,0 +1,74 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import "sync"
+
+// maxPCFSessions is the maximum number of Progressive Collapsed Forwarding sessions
+// that may be in progress at once. Requests arriving while the limit is reached are
+// still served, but do not register a session that later requests can join.
+const maxPCFSessions = 4096
+
+// pcfSessions tracks in-progress Progressive Collapsed Forwarding sessions, keyed on
+// the request's cache key, so that requests mapping to the same cache entry share a
+// single upstream fetch, even when their raw URLs differ in unkeyed parameters
+type pcfSessions struct {
+	mtx      sync.Mutex
+	sessions map[string]ProgressiveCollapseForwarder
+	max      int
+}
+
+func newPCFSessions(max int) *pcfSessions {
+	return &pcfSessions{sessions: make(map[string]ProgressiveCollapseForwarder), max: max}
+}
+
+// Load returns the session in progress for the cache key, if any
+func (s *pcfSessions) Load(key string) (ProgressiveCollapseForwarder, bool) {
+	s.mtx.Lock()
+	pcf, ok := s.sessions[key]
+	s.mtx.Unlock()
+	return pcf, ok
+}
+
+// Store registers the session for the cache key, and returns false if it was not
+// registered because the maximum number of sessions are already in progress
+func (s *pcfSessions) Store(key string, pcf ProgressiveCollapseForwarder) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if _, ok := s.sessions[key]; !ok && len(s.sessions) >= s.max {
+		return false
+	}
+	s.sessions[key] = pcf
+	return true
+}
+
+// Delete removes the finished session for the cache key, unless it has since been
+// replaced by another session
+func (s *pcfSessions) Delete(key string, pcf ProgressiveCollapseForwarder) {
+	s.mtx.Lock()
+	if s.sessions[key] == pcf {
+		delete(s.sessions, key)
+	}
+	s.mtx.Unlock()
+}
+
+// Len returns the number of sessions in progress
+func (s *pcfSessions) Len() int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return len(s.sessions)
+}
@@ -0,0 +1,55 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestPCFSessions(t *testing.T) {
+	s := newPCFSessions(2)
+	pcf1 := NewPCF(&http.Response{}, 1)
+	pcf2 := NewPCF(&http.Response{}, 1)
+	pcf3 := NewPCF(&http.Response{}, 1)
+
+	if !s.Store("a", pcf1) || !s.Store("b", pcf2) {
+		t.Fatal("expected sessions to be stored")
+	}
+	if s.Store("c", pcf3) {
+		t.Error("expected session to be refused when at capacity")
+	}
+	if _, ok := s.Load("c"); ok {
+		t.Error("expected no session for c")
+	}
+	// replacing an existing key does not grow the map
+	if !s.Store("b", pcf3) {
+		t.Error("expected session to be replaced when at capacity")
+	}
+
+	// a finished session does not remove the session that replaced it
+	s.Delete("b", pcf2)
+	if pcf, ok := s.Load("b"); !ok || pcf != pcf3 {
+		t.Error("expected replacement session for b")
+	}
+
+	s.Delete("a", pcf1)
+	s.Delete("b", pcf3)
+	if s.Len() != 0 {
+		t.Errorf("expected %d got %d", 0, s.Len())
+	}
+}
@@ -19,8 +19,10 @@ package engines
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
@@ -28,6 +30,7 @@ import (
 	"github.com/trickstercache/trickster/v2/pkg/locks"
 	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
 	tspan "github.com/trickstercache/trickster/v2/pkg/observability/tracing/span"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/cacheevents"
 	tctx "github.com/trickstercache/trickster/v2/pkg/proxy/context"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/methods"
@@ -59,6 +62,11 @@ type proxyRequest struct {
 	rerunCount int
 
 	cacheDocument *HTTPDocument
+	// encodedVariant is the client-encoded variant of cacheDocument served on a cache hit
+	encodedVariant *HTTPDocument
+	// staleDocument is the stale cached object that may be served in place of an
+	// origin error response
+	staleDocument *HTTPDocument
 	cacheBuffer   *bytes.Buffer
 	cacheLock     locks.NamedLock
 	mapLock       *sync.Mutex
@@ -77,6 +85,13 @@ type proxyRequest struct {
 
 	trueContentType string
 
+	// varyBase is the portion of key that is independent of upstream Vary headers,
+	// varyNames are the Vary header names folded into key, and varyHeader is the
+	// request header their values are read from
+	varyBase   string
+	varyNames  []string
+	varyHeader http.Header
+
 	collapsedForwarder ProgressiveCollapseForwarder
 	cachingPolicy      *CachingPolicy
 
@@ -89,6 +104,9 @@ type proxyRequest struct {
 	wantsRanges       bool
 	isPartialResponse bool
 	wasReconstituted  bool
+	revalidateStale   bool
+	// isCached is true when the cache held an object under key at lookup
+	isCached bool
 }
 
 // newProxyRequest accepts the original inbound HTTP Request and Response
@@ -129,6 +147,9 @@ func (pr *proxyRequest) Clone() *proxyRequest {
 		Logger:             pr.Logger,
 		cacheDocument:      pr.cacheDocument,
 		key:                pr.key,
+		varyBase:           pr.varyBase,
+		varyNames:          pr.varyNames,
+		varyHeader:         pr.varyHeader,
 		cacheStatus:        pr.cacheStatus,
 		writeToCache:       pr.writeToCache,
 		wantsRanges:        pr.wantsRanges,
@@ -170,6 +191,9 @@ func (pr *proxyRequest) Fetch() ([]byte, *http.Response, time.Duration) {
 	if err != nil {
 		tl.Error(pr.Logger, "error reading body from http response",
 			tl.Pairs{"url": pr.URL.String(), "detail": err.Error()})
+		if errors.Is(err, errResponseTooLarge) {
+			resp = newResponseTooLarge(pr.upstreamRequest, o.MaxResponseBytesStatusCode, pc)
+		}
 		return []byte{}, resp, 0
 	}
 
@@ -207,7 +231,7 @@ func (pr *proxyRequest) prepareRevalidationRequest() {
 		var wr byterange.Ranges
 
 		if pr.wantedRanges != nil && len(pr.wantedRanges) > 0 {
-			wr = pr.wantedRanges
+			wr = pr.wantedRanges.Coalesce()
 		} else {
 			wr = byterange.Ranges{{Start: 0, End: cl}}
 		}
@@ -355,16 +379,48 @@ func (pr *proxyRequest) parseRequestRanges() bool {
 	return pr.wantsRanges
 }
 
-func (pr *proxyRequest) stripConditionalHeaders() {
-	// don't proxy these up, their scope is only between Trickster and client
-	if pr.cachingPolicy != nil && pr.cachingPolicy.IsClientConditional {
-		stripConditionalHeaders(pr.upstreamRequest.Header)
+// exceedsMaxRanges returns true if the request's Range header has more ranges than the
+// Backend permits. Ranges are counted without being parsed, so the check is inexpensive
+func (pr *proxyRequest) exceedsMaxRanges() bool {
+	rsc := request.GetResources(pr.Request)
+	if rsc == nil || rsc.BackendOptions == nil || rsc.BackendOptions.MaxRangesPerRequest <= 0 {
+		return false
 	}
+	v := pr.Header.Get(headers.NameRange)
+	return v != "" && strings.Count(v, ",")+1 > rsc.BackendOptions.MaxRangesPerRequest
+}
+
+// handleTooManyRanges writes the Backend's configured rejection response for a request
+// with more ranges than permitted, and returns it
+func handleTooManyRanges(w io.Writer, r *http.Request) *http.Response {
+	rsc := request.GetResources(r)
+	o := rsc.BackendOptions
+	tl.Debug(rsc.Logger, "rejected request exceeding max ranges",
+		tl.Pairs{"backendName": o.Name, "maxRanges": o.MaxRangesPerRequest})
+	resp := &http.Response{StatusCode: o.MaxRangesStatusCode,
+		Request: r, Header: make(http.Header), Body: http.NoBody}
+	if rsc.PathConfig != nil {
+		headers.UpdateHeaders(resp.Header, rsc.PathConfig.ResponseHeaders)
+	}
+	if hw, ok := w.(http.ResponseWriter); ok {
+		headers.Merge(hw.Header(), resp.Header)
+		hw.WriteHeader(resp.StatusCode)
+	}
+	return resp
+}
+
+func (pr *proxyRequest) stripConditionalHeaders() {
+	// don't proxy these up, their scope is only between Trickster and client. this
+	// includes conditionals with malformed dates, which are ignored rather than resolved
+	stripConditionalHeaders(pr.upstreamRequest.Header)
 }
 
 func (pr *proxyRequest) writeResponseHeader() {
 	pr.mapLock.Lock()
 	headers.SetResultsHeader(pr.upstreamResponse.Header, "ObjectProxyCache", pr.cacheStatus.String(), "", nil)
+	if rsc := request.GetResources(pr.Request); rsc != nil {
+		rsc.ServerTiming.SetHeader(pr.upstreamResponse.Header)
+	}
 	pr.mapLock.Unlock()
 }
 
@@ -420,6 +476,14 @@ func (pr *proxyRequest) determineCacheability() {
 		return
 	}
 
+	// a partial response with an encoded body holds a range of the encoded
+	// representation, which can't be merged with other ranges in the cache
+	if resp != nil && resp.StatusCode == http.StatusPartialContent &&
+		isEncoded(resp.Header.Get(headers.NameContentEncoding)) {
+		pr.writeToCache = false
+		return
+	}
+
 	if pr.revalidation == RevalStatusLocal {
 
 		tpc := pr.cachingPolicy.Clone()
@@ -444,6 +508,11 @@ func (pr *proxyRequest) determineCacheability() {
 	if pr.cachingPolicy.NoCache || (!pr.cachingPolicy.CanRevalidate && pr.cachingPolicy.FreshnessLifetime <= 0) {
 		pr.writeToCache = false
 		rsc.CacheClient.Remove(pr.key)
+		// only report the purge when an object was actually removed
+		if pr.isCached {
+			pr.isCached = false
+			notifyCacheEvent(rsc, cacheevents.OpPurge, pr.key, 0, 0)
+		}
 		// is fresh, and we can cache, can revalidate and the freshness is greater than 0
 	} else if !pr.cachingPolicy.IsFresh {
 		pr.writeToCache = true
@@ -457,6 +526,9 @@ func (pr *proxyRequest) store() error {
 	}
 
 	d := pr.cacheDocument
+	if err := d.loadBody(); err != nil {
+		return err
+	}
 
 	pr.writeToCache = false // in case store is called again before the object has changed
 
@@ -473,14 +545,15 @@ func (pr *proxyRequest) store() error {
 	rsc := request.GetResources(pr.Request)
 	o := rsc.BackendOptions
 
-	rf := o.RevalidationFactor
+	rf, minTTL := o.RevalidationFactor, o.MinTTL
 	if rsc.AlternateCacheTTL > 0 {
-		rf = 1
+		rf, minTTL = 1, 0
 	}
 
 	d.CachingPolicy = pr.cachingPolicy
+	pr.applyVary(d.vary)
 	err := WriteCache(pr.upstreamRequest.Context(), rsc.CacheClient, pr.key, d,
-		pr.cachingPolicy.TTL(rf, o.MaxTTL), o.CompressibleTypes, nil)
+		pr.cachingPolicy.TTL(rf, minTTL, o.MaxTTL), o.CompressibleTypes, nil)
 	if err != nil {
 		return err
 	}
@@ -506,6 +579,9 @@ func (pr *proxyRequest) prepareResponse() {
 	pr.cachingPolicy.ResolveClientConditionals(pr.cacheStatus)
 
 	d := pr.cacheDocument
+	if pr.encodedVariant != nil {
+		d = pr.encodedVariant
+	}
 	resp := pr.upstreamResponse
 
 	// if all of the client conditional headers were satisfied,
@@ -525,6 +601,19 @@ func (pr *proxyRequest) prepareResponse() {
 		return
 	}
 
+	// gRPC-Web framing can't be split into byte ranges, so always serve the full body
+	if pr.wantsRanges && isGRPCWeb(resp.Header.Get(headers.NameContentType)) {
+		pr.wantsRanges = false
+		pr.wantedRanges = nil
+	}
+
+	// an encoded partial response holds a range of the encoded representation,
+	// so it is passed through as-is rather than having ranges extracted from it
+	if pr.wantsRanges && resp.StatusCode == http.StatusPartialContent &&
+		isEncoded(resp.Header.Get(headers.NameContentEncoding)) {
+		return
+	}
+
 	if pr.wantsRanges && (resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusPartialContent) {
 
 		// since the user wants ranges, we have to extract them from what we have already
@@ -564,7 +653,9 @@ func (pr *proxyRequest) prepareResponse() {
 		}
 		resp.Header.Del(headers.NameContentRange)
 		if pr.cacheStatus == status.LookupStatusHit || pr.cacheStatus == status.LookupStatusRevalidated ||
-			pr.cacheStatus == status.LookupStatusPartialHit {
+			pr.cacheStatus == status.LookupStatusPartialHit ||
+			pr.cacheStatus == status.LookupStatusStaleWhileRevalidate ||
+			pr.cacheStatus == status.LookupStatusStaleIfError {
 			pr.responseBody = d.Body
 		}
 	}
@@ -736,8 +827,8 @@ func (pr *proxyRequest) reconstituteResponses() {
 	if pr.upstreamResponse.StatusCode != http.StatusNotModified {
 		rsc := request.GetResources(pr.Request)
 		pr.mapLock.Lock()
-		pr.cachingPolicy.Merge(GetResponseCachingPolicy(pr.upstreamResponse.StatusCode,
-			rsc.BackendOptions.NegativeCache, pr.upstreamResponse.Header))
+		pr.cachingPolicy.Merge(getBackendResponseCachingPolicy(rsc.BackendOptions,
+			pr.upstreamResponse))
 		pr.mapLock.Unlock()
 
 	}
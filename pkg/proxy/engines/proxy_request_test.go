@@ -161,6 +161,16 @@ func TestDetermineCacheability(t *testing.T) {
 	if pr.cacheStatus != status.LookupStatusKeyMiss {
 		t.Errorf("expected %s got %s", status.LookupStatusKeyMiss, pr.cacheStatus)
 	}
+
+	// an encoded partial response is never cached
+	pr.writeToCache = true
+	pr.revalidation = RevalStatusNone
+	pr.upstreamResponse = &http.Response{StatusCode: http.StatusPartialContent,
+		Header: http.Header{headers.NameContentEncoding: []string{"br"}}}
+	pr.determineCacheability()
+	if pr.writeToCache {
+		t.Errorf("expected %t got %t", false, pr.writeToCache)
+	}
 }
 
 func TestStoreNoWrite(t *testing.T) {
@@ -0,0 +1,63 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"errors"
+
+	bo "github.com/trickstercache/trickster/v2/pkg/backends/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache"
+)
+
+// ErrPathPrefixNotInKey represents the error that occurs when purging by a path prefix
+// narrower than the backend root, when the backend does not include request paths in
+// its cache keys
+var ErrPathPrefixNotInKey = errors.New("cache keys do not include request paths; " +
+	"only the backend root path prefix can be purged unless cache_key_include_path is true")
+
+// PathPrefixKeyPrefixes returns the cache key prefixes under which a backend stores the
+// objects for requests whose path (without the OriginURL's path prefix) begins with the
+// path prefix. The root path prefix resolves to the backend's entire key space. Other
+// path prefixes resolve only when the backend includes request paths in its cache keys
+func PathPrefixKeyPrefixes(o *bo.Options, path string) ([]string, error) {
+	if path == "" || path == "/" {
+		return []string{o.CacheKeyPrefix + "."}, nil
+	}
+	if !o.CacheKeyIncludePath {
+		return nil, ErrPathPrefixNotInKey
+	}
+	seg := cacheKeyPathSegment(path)
+	return []string{o.CacheKeyPrefix + ".opc." + seg, o.CacheKeyPrefix + ".dpc." + seg}, nil
+}
+
+// PurgeByPathPrefix removes all of a backend's cached objects for requests under the
+// path prefix, and returns the number of objects removed
+func PurgeByPathPrefix(c cache.Cache, o *bo.Options, path string) (int, error) {
+	prefixes, err := PathPrefixKeyPrefixes(o, path)
+	if err != nil {
+		return 0, err
+	}
+	var total int
+	for _, prefix := range prefixes {
+		n, err := cache.PurgeByPrefix(c, prefix)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
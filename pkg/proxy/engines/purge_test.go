@@ -0,0 +1,99 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	bo "github.com/trickstercache/trickster/v2/pkg/backends/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache"
+	"github.com/trickstercache/trickster/v2/pkg/cache/bbolt"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/request"
+)
+
+func TestPurgeByPathPrefix(t *testing.T) {
+
+	c, err := bbolt.New(t.TempDir()+"/test.db", "trickster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	o := bo.New()
+	o.CacheKeyPrefix = "test"
+	o.PathPrefix = "/origin"
+	for _, key := range []string{"test.opc.%2Fapi%2Fv1%2Fquery.1", "test.dpc.%2Fapi%2Fv1%2Fseries.2",
+		"test.opc.%2Fapi%2Fv2%2Fquery.3", "test.opc.%2Fother.4", "test2.opc.%2Fapi%2Fv1%2Fquery.5"} {
+		if err = c.Store(key, []byte("data"), time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// by default, keys don't include the request path, so only the root can be purged
+	r := httptest.NewRequest(http.MethodGet, "http://example.com/origin/api/v1/query?q=1", nil)
+	r = request.SetResources(r, request.NewResources(o, nil, nil, nil, nil, nil, testLogger))
+	pr := newProxyRequest(r, nil)
+	if key, expected := pr.cacheKey(o, "opc"), "test.opc."+pr.DeriveCacheKey(""); key != expected {
+		t.Errorf("expected %s got %s", expected, key)
+	}
+	if _, err = PurgeByPathPrefix(c, o, "/api"); err != ErrPathPrefixNotInKey {
+		t.Errorf("expected %v got %v", ErrPathPrefixNotInKey, err)
+	}
+
+	// a key derived from a request is matched by its path's prefix
+	o.CacheKeyIncludePath = true
+	key := pr.cacheKey(o, "opc")
+	if !strings.HasPrefix(key, "test.opc.%2Fapi%2Fv1%2Fquery.") {
+		t.Errorf("expected key with escaped path got %s", key)
+	}
+
+	n, err := PurgeByPathPrefix(c, o, "/api/v1/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("expected %d got %d", 2, n)
+	}
+	if _, _, err = c.Retrieve("test.opc.%2Fapi%2Fv2%2Fquery.3", false); err != nil {
+		t.Error(err)
+	}
+
+	n, err = PurgeByPathPrefix(c, o, "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("expected %d got %d", 2, n)
+	}
+	if _, _, err = c.Retrieve("test2.opc.%2Fapi%2Fv1%2Fquery.5", false); err != nil {
+		t.Error(err)
+	}
+
+	ts, _, _, rsc, err := setupTestHarnessOPC("", "test", 200, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+	// the memory cache can't enumerate keys by prefix
+	if _, err = PurgeByPathPrefix(rsc.CacheClient, o, "/"); err != cache.ErrPrefixPurgeUnsupported {
+		t.Errorf("expected %v got %v", cache.ErrPrefixPurgeUnsupported, err)
+	}
+}
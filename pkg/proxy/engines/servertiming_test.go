@@ -0,0 +1,82 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/request"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/servertiming"
+)
+
+var serverTimingEntry = regexp.MustCompile(`^([a-z]+);desc="[^"]*";dur=\d+(\.\d+)?$`)
+
+// serverTimingNames returns the metric names of a Server-Timing header value, failing
+// the test if any entry is malformed
+func serverTimingNames(t *testing.T, v string) []string {
+	if v == "" {
+		t.Fatal("expected a Server-Timing header")
+	}
+	parts := strings.Split(v, ", ")
+	names := make([]string, len(parts))
+	for i, p := range parts {
+		m := serverTimingEntry.FindStringSubmatch(p)
+		if m == nil {
+			t.Fatalf("malformed Server-Timing entry %q in %q", p, v)
+		}
+		names[i] = m[1]
+	}
+	return names
+}
+
+func TestObjectProxyCacheServerTiming(t *testing.T) {
+
+	hdrs := map[string]string{headers.NameCacheControl: headers.ValueMaxAge + "=60"}
+	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusOK, hdrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+
+	rsc.PathConfig.ResponseHeaders = hdrs
+	rsc.BackendOptions.EmitServerTiming = true
+	r.URL.Path = "/servertiming"
+
+	fetch := func(expectedStatus string) []string {
+		// each client request gets its own timings, as it would from the middleware
+		trsc := rsc.Clone()
+		trsc.ServerTiming = servertiming.New()
+		tr := request.SetResources(r.Clone(r.Context()), trsc)
+		w, e := testFetchOPC(tr, http.StatusOK, "test",
+			map[string]string{"status": expectedStatus})
+		for _, err := range e {
+			t.Error(err)
+		}
+		return serverTimingNames(t, w.Header().Get(headers.NameServerTiming))
+	}
+
+	if names := strings.Join(fetch("kmiss"), ","); names != "cache,origin,total" {
+		t.Errorf("expected %s got %s", "cache,origin,total", names)
+	}
+	if names := strings.Join(fetch("hit"), ","); names != "cache,total" {
+		t.Errorf("expected %s got %s", "cache,total", names)
+	}
+}
@@ -0,0 +1,187 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/cache/status"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/methods"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/request"
+)
+
+// staleRevalidations tracks the cache keys with a background revalidation in progress
+var staleRevalidations sync.Map
+
+// canServeStale returns true if the subject's stale cache object may be served to the
+// client while it is revalidated in the background. This is permitted within the
+// object's stale-while-revalidate window, or for any revalidatable object when the
+// path is configured to serve stale on revalidate, but never beyond the Backend's MaxTTL
+func (pr *proxyRequest) canServeStale() bool {
+	cp := pr.cachingPolicy
+	if cp == nil || pr.cacheStatus != status.LookupStatusHit || cp.NoCache ||
+		cp.IsNegativeCache || cp.MustRevalidate || !methods.IsCacheable(pr.Method) {
+		return false
+	}
+	rsc := request.GetResources(pr.Request)
+	if rsc == nil || rsc.BackendOptions == nil {
+		return false
+	}
+	now := time.Now()
+	if o := rsc.BackendOptions; o.MaxTTL > 0 && now.After(cp.LocalDate.Add(o.MaxTTL)) {
+		return false
+	}
+	if cp.StaleWhileRevalidate > 0 {
+		swrEnd := cp.LocalDate.Add(time.Duration(cp.FreshnessLifetime+cp.StaleWhileRevalidate) *
+			time.Second)
+		if now.Before(swrEnd) {
+			return true
+		}
+	}
+	return cp.CanRevalidate && rsc.PathConfig != nil && rsc.PathConfig.ServeStaleOnRevalidate
+}
+
+// canServeStaleOnCircuitOpen returns true if the subject's stale cache object may be
+// served to the client because the Backend's circuit breaker is open, which would
+// otherwise fast-fail the upstream request. The Backend's MaxTTL is still respected.
+func (pr *proxyRequest) canServeStaleOnCircuitOpen() bool {
+	cp := pr.cachingPolicy
+	if cp == nil || pr.cacheStatus != status.LookupStatusHit || cp.IsNegativeCache ||
+		!methods.IsCacheable(pr.Method) {
+		return false
+	}
+	rsc := request.GetResources(pr.Request)
+	if rsc == nil || rsc.BackendOptions == nil {
+		return false
+	}
+	o := rsc.BackendOptions
+	if o.CircuitBreaker == nil || !o.CircuitBreaker.IsOpen() {
+		return false
+	}
+	return o.MaxTTL <= 0 || time.Now().Before(cp.LocalDate.Add(o.MaxTTL))
+}
+
+// canServeStaleOnError returns true if the subject's stale cache object may be served
+// to the client in place of an origin error response. This is permitted within the
+// object's stale-if-error window, or for any stale object when the path is configured
+// to serve stale on error, but never for uncacheable methods, objects that must be
+// revalidated, or beyond the Backend's MaxTTL
+func (pr *proxyRequest) canServeStaleOnError() bool {
+	d := pr.staleDocument
+	if d == nil || d.CachingPolicy == nil || !methods.IsCacheable(pr.Method) {
+		return false
+	}
+	cp := d.CachingPolicy
+	if cp.IsNegativeCache || cp.MustRevalidate {
+		return false
+	}
+	rsc := request.GetResources(pr.Request)
+	if rsc == nil || rsc.BackendOptions == nil {
+		return false
+	}
+	now := time.Now()
+	if o := rsc.BackendOptions; o.MaxTTL > 0 && now.After(cp.LocalDate.Add(o.MaxTTL)) {
+		return false
+	}
+	if rsc.PathConfig != nil && rsc.PathConfig.ServeStaleOnError {
+		return true
+	}
+	return cp.StaleIfError > 0 && now.Before(cp.LocalDate.Add(
+		time.Duration(cp.FreshnessLifetime+cp.StaleIfError)*time.Second))
+}
+
+// serveStaleOnError serves the subject's stale cache object to the client if the origin
+// responded with a server error and the object may be served stale, and returns true
+// if it did so. The error response is discarded and the cached object is left as is.
+func serveStaleOnError(pr *proxyRequest) bool {
+	resp := pr.upstreamResponse
+	if resp == nil || resp.StatusCode < http.StatusInternalServerError ||
+		!pr.canServeStaleOnError() || pr.staleDocument.loadBody() != nil {
+		return false
+	}
+	if resp.Body != nil {
+		resp.Body.Close()
+	}
+	pr.cacheDocument = pr.staleDocument
+	pr.cachingPolicy.Merge(pr.staleDocument.CachingPolicy)
+	pr.cacheStatus = status.LookupStatusStaleIfError
+	pr.writeToCache = false
+	handleTrueCacheHit(pr)
+	return true
+}
+
+// revalidateStaleObject starts a background refresh of a cache object that was served
+// stale to the client. Only one revalidation runs per cache key at a time, and none is
+// started if a Progressive Collapsed Forward is already fetching the object.
+func revalidateStaleObject(pr *proxyRequest) {
+	if _, ok := reqs.Load(pr.key); ok {
+		return
+	}
+	if _, loaded := staleRevalidations.LoadOrStore(pr.key, true); loaded {
+		return
+	}
+	go func() {
+		defer staleRevalidations.Delete(pr.key)
+		revalidateStale(pr)
+	}()
+}
+
+// revalidateStale revalidates the stale cache object, or refetches it if it cannot be
+// revalidated. The cache lock is only held once the upstream response is received, so
+// clients requesting the object in the meantime continue to be served from the cache.
+func revalidateStale(pr *proxyRequest) {
+	rsc := request.GetResources(pr.Request)
+	if rsc == nil || rsc.CacheClient == nil {
+		return
+	}
+
+	rpr := newProxyRequest(pr.Request, io.Discard)
+	rpr.key = pr.key
+	rpr.cacheDocument = pr.cacheDocument
+	rpr.cacheStatus = status.LookupStatusHit
+	rpr.cachingPolicy = pr.cachingPolicy.Clone()
+	rpr.cachingPolicy.ResetClientConditionals()
+	// the client's range and conditional headers do not apply to the revalidation
+	rpr.upstreamRequest.Header.Del(headers.NameRange)
+	stripConditionalHeaders(rpr.upstreamRequest.Header)
+
+	canRevalidate := rpr.cachingPolicy.CanRevalidate
+	if canRevalidate {
+		rpr.prepareRevalidationRequest()
+	} else {
+		rpr.cacheDocument = nil
+		rpr.cacheStatus = status.LookupStatusKeyMiss
+		rpr.prepareUpstreamRequests()
+	}
+	handleUpstreamTransactions(rpr)
+
+	if !rsc.NoLock {
+		rpr.cacheLock, _ = rsc.CacheClient.Locker().Acquire(rpr.key)
+		rpr.hasWriteLock = true
+		defer rpr.cacheLock.Release()
+	}
+
+	if canRevalidate {
+		handleCacheRevalidationResponse(rpr)
+		return
+	}
+	handleAllWrites(rpr)
+}
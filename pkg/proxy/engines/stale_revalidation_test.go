@@ -0,0 +1,289 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/trickstercache/mockster/pkg/mocks/byterange"
+	"github.com/trickstercache/trickster/v2/pkg/cache/status"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/request"
+)
+
+// waitForStaleRevalidations blocks until no background revalidations are in progress
+func waitForStaleRevalidations() {
+	for i := 0; i < 100; i++ {
+		var inFlight bool
+		staleRevalidations.Range(func(k, v interface{}) bool {
+			inFlight = true
+			return false
+		})
+		if !inFlight {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestObjectProxyCacheStaleWhileRevalidate(t *testing.T) {
+
+	hdrs := map[string]string{
+		headers.NameCacheControl: headers.ValueMaxAge + "=1, " +
+			headers.ValueStaleWhileRevalidate + "=30",
+	}
+	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusOK, hdrs)
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	rsc.PathConfig.ResponseHeaders = hdrs
+
+	_, e := testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	time.Sleep(1010 * time.Millisecond)
+
+	_, e = testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "swr"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	waitForStaleRevalidations()
+
+	_, e = testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "hit"})
+	for _, err = range e {
+		t.Error(err)
+	}
+}
+
+func TestObjectProxyCacheServeStaleOnRevalidate(t *testing.T) {
+
+	hdrs := map[string]string{
+		headers.NameCacheControl: headers.ValueMaxAge + "=1",
+		headers.NameETag:         "test-etag",
+	}
+	ts, _, r, rsc, err := setupTestHarnessOPCRange(nil)
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	rsc.PathConfig.ResponseHeaders = hdrs
+	rsc.PathConfig.ServeStaleOnRevalidate = true
+	rsc.BackendOptions.RevalidationFactor = 2
+
+	_, e := testFetchOPC(r, http.StatusOK, byterange.Body, map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	time.Sleep(1010 * time.Millisecond)
+
+	_, e = testFetchOPC(r, http.StatusOK, byterange.Body, map[string]string{"status": "swr"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	waitForStaleRevalidations()
+
+	_, e = testFetchOPC(r, http.StatusOK, byterange.Body, map[string]string{"status": "hit"})
+	for _, err = range e {
+		t.Error(err)
+	}
+}
+
+func TestCanServeStale(t *testing.T) {
+
+	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusOK, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	rsc.BackendOptions.MaxTTL = time.Hour
+
+	pr := newProxyRequest(r, httptest.NewRecorder())
+	if pr.canServeStale() {
+		t.Error("expected false for nil caching policy")
+	}
+
+	pr.cacheStatus = status.LookupStatusHit
+	pr.cachingPolicy = &CachingPolicy{LocalDate: time.Now().Add(-10 * time.Second),
+		FreshnessLifetime: 5, StaleWhileRevalidate: 30}
+	if !pr.canServeStale() {
+		t.Error("expected true within the stale-while-revalidate window")
+	}
+
+	pr.cachingPolicy.StaleWhileRevalidate = 1
+	if pr.canServeStale() {
+		t.Error("expected false beyond the stale-while-revalidate window")
+	}
+
+	pr.cachingPolicy.CanRevalidate = true
+	rsc.PathConfig.ServeStaleOnRevalidate = true
+	if !pr.canServeStale() {
+		t.Error("expected true for path configured to serve stale on revalidate")
+	}
+
+	pr.cachingPolicy.MustRevalidate = true
+	if pr.canServeStale() {
+		t.Error("expected false for must-revalidate")
+	}
+	pr.cachingPolicy.MustRevalidate = false
+
+	rsc.BackendOptions.MaxTTL = 5 * time.Second
+	if pr.canServeStale() {
+		t.Error("expected false beyond the backend's max ttl")
+	}
+
+	pr.cacheStatus = status.LookupStatusPartialHit
+	rsc.BackendOptions.MaxTTL = time.Hour
+	if pr.canServeStale() {
+		t.Error("expected false for partial hit")
+	}
+
+	r = request.SetResources(r, nil)
+	pr = newProxyRequest(r, httptest.NewRecorder())
+	pr.cacheStatus = status.LookupStatusHit
+	pr.cachingPolicy = &CachingPolicy{}
+	if pr.canServeStale() {
+		t.Error("expected false for nil resources")
+	}
+}
+
+func TestObjectProxyCacheStaleIfError(t *testing.T) {
+
+	hdrs := map[string]string{
+		headers.NameCacheControl: headers.ValueMaxAge + "=1, " +
+			headers.ValueStaleIfError + "=30",
+	}
+	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusOK, hdrs)
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	rsc.PathConfig.ResponseHeaders = hdrs
+
+	_, e := testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	time.Sleep(1010 * time.Millisecond)
+	// the origin is now unreachable, so its responses are errors
+	ts.Close()
+
+	w, e := testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "sie"})
+	for _, err = range e {
+		t.Error(err)
+	}
+	expected := headers.ValueWarningResponseIsStale + ", " + headers.ValueWarningRevalidationFailed
+	if v := w.Result().Header.Get(headers.NameWarning); v != expected {
+		t.Errorf("expected %s got %s", expected, v)
+	}
+}
+
+func TestObjectProxyCacheStaleIfErrorExpired(t *testing.T) {
+
+	hdrs := map[string]string{
+		headers.NameCacheControl: headers.ValueMaxAge + "=1, " +
+			headers.ValueStaleIfError + "=1",
+	}
+	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusOK, hdrs)
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	rsc.PathConfig.ResponseHeaders = hdrs
+	// keep the object in the cache beyond its stale-if-error window
+	rsc.BackendOptions.RevalidationFactor = 10
+	rsc.PathConfig.ResponseHeaders[headers.NameETag] = "test-etag"
+
+	_, e := testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	time.Sleep(2010 * time.Millisecond)
+	ts.Close()
+
+	w, _ := testFetchOPC(r, http.StatusBadGateway, "", nil)
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("expected %d got %d", http.StatusBadGateway, w.Code)
+	}
+	if v := w.Result().Header.Get(headers.NameWarning); v != "" {
+		t.Errorf("expected no warning header got %s", v)
+	}
+}
+
+func TestCanServeStaleOnError(t *testing.T) {
+
+	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusOK, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+
+	rsc.BackendOptions.MaxTTL = time.Hour
+
+	pr := newProxyRequest(r, httptest.NewRecorder())
+	if pr.canServeStaleOnError() {
+		t.Error("expected false for no stale document")
+	}
+
+	pr.staleDocument = &HTTPDocument{CachingPolicy: &CachingPolicy{
+		LocalDate: time.Now().Add(-10 * time.Second), FreshnessLifetime: 5, StaleIfError: 30}}
+	if !pr.canServeStaleOnError() {
+		t.Error("expected true within the stale-if-error window")
+	}
+
+	pr.staleDocument.CachingPolicy.StaleIfError = 1
+	if pr.canServeStaleOnError() {
+		t.Error("expected false beyond the stale-if-error window")
+	}
+
+	rsc.PathConfig.ServeStaleOnError = true
+	if !pr.canServeStaleOnError() {
+		t.Error("expected true for path configured to serve stale on error")
+	}
+
+	pr.staleDocument.CachingPolicy.MustRevalidate = true
+	if pr.canServeStaleOnError() {
+		t.Error("expected false for must-revalidate")
+	}
+	pr.staleDocument.CachingPolicy.MustRevalidate = false
+
+	rsc.BackendOptions.MaxTTL = 5 * time.Second
+	if pr.canServeStaleOnError() {
+		t.Error("expected false beyond the backend's max ttl")
+	}
+	rsc.BackendOptions.MaxTTL = time.Hour
+
+	pr.Method = http.MethodPost
+	if pr.canServeStaleOnError() {
+		t.Error("expected false for non-idempotent method")
+	}
+}
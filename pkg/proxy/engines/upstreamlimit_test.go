@@ -0,0 +1,75 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/upstreamlimit"
+)
+
+func TestObjectProxyCacheUpstreamLimit(t *testing.T) {
+
+	hdrs := map[string]string{headers.NameCacheControl: headers.ValueMaxAge + "=60"}
+	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusOK, hdrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+
+	rsc.PathConfig.ResponseHeaders = hdrs
+	o := rsc.BackendOptions
+	l := upstreamlimit.New(o.Name, 1, 100*time.Millisecond)
+	o.UpstreamLimiter = l
+	r.URL.Path = "/upstreamlimit/hit"
+
+	_, e := testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+	if v := l.InFlight(); v != 0 {
+		t.Errorf("expected upstream slot to be released, in flight %d", v)
+	}
+
+	// saturate the limiter
+	release, ok := l.Acquire(context.Background())
+	if !ok {
+		t.Fatal("expected slot to be acquired")
+	}
+	defer release()
+
+	// cache hits do not need an upstream slot
+	_, e = testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "hit"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	// cache misses queue for the timeout, then fail
+	r.URL.Path = "/upstreamlimit/miss"
+	start := time.Now()
+	_, e = testFetchOPC(r, http.StatusServiceUnavailable, "", nil)
+	for _, err = range e {
+		t.Error(err)
+	}
+	if d := time.Since(start); d < 100*time.Millisecond {
+		t.Errorf("expected request to queue for the timeout, waited %s", d)
+	}
+}
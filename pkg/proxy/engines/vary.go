@@ -0,0 +1,204 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"container/list"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/trickstercache/trickster/v2/pkg/checksum/md5"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/request"
+)
+
+// maxVaryIndexEntries is the maximum number of cache keys whose Vary header names
+// are retained by the vary index
+const maxVaryIndexEntries = 16384
+
+// varyHeaders is the vary index of all backends
+var varyHeaders = newVaryIndex(maxVaryIndexEntries)
+
+// varyIndex maps a backend's Vary-independent cache keys to the list of request
+// header names that the upstream named in the Vary header of its response. Entries
+// are kept in LRU order and the least recently used key is evicted once the index
+// holds its maximum number of entries.
+type varyIndex struct {
+	max int
+
+	mtx   sync.Mutex
+	items map[string]*list.Element
+	lru   *list.List
+}
+
+type varyIndexEntry struct {
+	backend string
+	key     string
+	names   []string
+}
+
+func newVaryIndex(max int) *varyIndex {
+	return &varyIndex{
+		max:   max,
+		items: make(map[string]*list.Element),
+		lru:   list.New(),
+	}
+}
+
+// Load returns the Vary header names recorded for the backend's cache key
+func (vi *varyIndex) Load(backend, key string) ([]string, bool) {
+	vi.mtx.Lock()
+	defer vi.mtx.Unlock()
+	e, ok := vi.items[backend+"."+key]
+	if !ok {
+		return nil, false
+	}
+	vi.lru.MoveToFront(e)
+	return e.Value.(*varyIndexEntry).names, true
+}
+
+// Store records the Vary header names for the backend's cache key
+func (vi *varyIndex) Store(backend, key string, names []string) {
+	vi.mtx.Lock()
+	defer vi.mtx.Unlock()
+	k := backend + "." + key
+	if e, ok := vi.items[k]; ok {
+		e.Value.(*varyIndexEntry).names = names
+		vi.lru.MoveToFront(e)
+		return
+	}
+	if vi.lru.Len() >= vi.max {
+		if e := vi.lru.Back(); e != nil {
+			ve := e.Value.(*varyIndexEntry)
+			delete(vi.items, ve.backend+"."+ve.key)
+			vi.lru.Remove(e)
+		}
+	}
+	vi.items[k] = vi.lru.PushFront(&varyIndexEntry{backend: backend, key: key, names: names})
+}
+
+// Purge removes all of the backend's entries
+func (vi *varyIndex) Purge(backend string) {
+	vi.mtx.Lock()
+	defer vi.mtx.Unlock()
+	for e := vi.lru.Front(); e != nil; {
+		next := e.Next()
+		if ve := e.Value.(*varyIndexEntry); ve.backend == backend {
+			delete(vi.items, backend+"."+ve.key)
+			vi.lru.Remove(e)
+		}
+		e = next
+	}
+}
+
+// Len returns the number of entries currently retained
+func (vi *varyIndex) Len() int {
+	vi.mtx.Lock()
+	defer vi.mtx.Unlock()
+	return vi.lru.Len()
+}
+
+// PurgeVaryIndex forgets the Vary header names learned for all of the backend's
+// objects, so they are relearned from the upstream once its objects are purged
+func PurgeVaryIndex(backend string) {
+	varyHeaders.Purge(backend)
+}
+
+// parseVary returns the sorted, canonicalized list of header names in the
+// provided Vary header values. A wildcard is returned as the only element
+func parseVary(h http.Header) []string {
+	if h == nil {
+		return nil
+	}
+	var names []string
+	seen := make(map[string]bool)
+	for _, v := range h.Values(headers.NameVary) {
+		for _, n := range strings.Split(v, ",") {
+			n = strings.TrimSpace(n)
+			if n == "" {
+				continue
+			}
+			if n == "*" {
+				return []string{"*"}
+			}
+			n = http.CanonicalHeaderKey(n)
+			if !seen[n] {
+				seen[n] = true
+				names = append(names, n)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// isVaryWildcard returns true if the response varies on aspects of the request
+// other than its headers, in which case it can't be cached
+func isVaryWildcard(h http.Header) bool {
+	names := parseVary(h)
+	return len(names) == 1 && names[0] == "*"
+}
+
+// varyBackend returns the name of the backend under which the request's Vary header
+// names are indexed
+func varyBackend(rsc *request.Resources) string {
+	if rsc == nil || rsc.BackendOptions == nil {
+		return ""
+	}
+	return rsc.BackendOptions.Name
+}
+
+// varyKeySuffix returns the cache key suffix that distinguishes the response
+// variant selected by the provided request header values
+func varyKeySuffix(names []string, h http.Header) string {
+	vals := make([]string, len(names))
+	for i, n := range names {
+		vals[i] = fmt.Sprintf("%s.%s.", n, strings.Join(h.Values(n), ","))
+	}
+	return ".vary." + md5.Checksum(strings.Join(vals, ""))
+}
+
+// applyVary records the upstream's Vary header names for the request's
+// Vary-independent cache key and, if they differ from the names already folded
+// into the key, re-keys the request so the document is stored as a variant
+func (pr *proxyRequest) applyVary(names []string) {
+	if pr.varyBase == "" || len(names) == 0 || sameVaryNames(pr.varyNames, names) {
+		return
+	}
+	i := strings.Index(pr.key, pr.varyBase)
+	if i < 0 {
+		return
+	}
+	varyHeaders.Store(varyBackend(request.GetResources(pr.Request)), pr.varyBase, names)
+	pr.varyNames = names
+	pr.key = pr.key[:i+len(pr.varyBase)] + varyKeySuffix(names, pr.varyHeader)
+}
+
+func sameVaryNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
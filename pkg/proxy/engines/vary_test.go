@@ -0,0 +1,174 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package engines
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+)
+
+func resetVaryHeaders() {
+	varyHeaders = newVaryIndex(maxVaryIndexEntries)
+}
+
+func TestParseVary(t *testing.T) {
+
+	tests := []struct {
+		vary     []string
+		expected []string
+	}{
+		{nil, nil},
+		{[]string{"Accept-Encoding"}, []string{"Accept-Encoding"}},
+		{[]string{"accept-language, Accept-Encoding", "Accept-Language"},
+			[]string{"Accept-Encoding", "Accept-Language"}},
+		{[]string{"Accept-Encoding, *"}, []string{"*"}},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			h := http.Header{}
+			for _, v := range test.vary {
+				h.Add(headers.NameVary, v)
+			}
+			names := parseVary(h)
+			if !sameVaryNames(names, test.expected) {
+				t.Errorf("expected %v got %v", test.expected, names)
+			}
+		})
+	}
+}
+
+func TestGetResponseCachingPolicyVaryWildcard(t *testing.T) {
+	h := http.Header{}
+	h.Set(headers.NameCacheControl, "max-age=60")
+	h.Set(headers.NameVary, "*")
+	cp := GetResponseCachingPolicy(http.StatusOK, nil, h)
+	if !cp.NoCache {
+		t.Error("expected Vary: * response to be uncacheable")
+	}
+}
+
+func TestObjectProxyCacheVary(t *testing.T) {
+
+	tests := []struct {
+		vary     string
+		requests []map[string]string
+		statuses []string
+	}{
+		{ // 0 single header
+			vary: "Accept-Language",
+			requests: []map[string]string{
+				{"Accept-Language": "en"},
+				{"Accept-Language": "en"},
+				{"Accept-Language": "fr"},
+				{"Accept-Language": "en"},
+			},
+			statuses: []string{"kmiss", "hit", "kmiss", "hit"},
+		},
+		{ // 1 multiple headers
+			vary: "Accept-Language, X-Tenant",
+			requests: []map[string]string{
+				{"Accept-Language": "en", "X-Tenant": "a"},
+				{"Accept-Language": "en", "X-Tenant": "b"},
+				{"Accept-Language": "en", "X-Tenant": "a"},
+				{"Accept-Language": "fr", "X-Tenant": "b"},
+			},
+			statuses: []string{"kmiss", "kmiss", "hit", "kmiss"},
+		},
+		{ // 2 wildcard
+			vary: "*",
+			requests: []map[string]string{
+				{"Accept-Language": "en"},
+				{"Accept-Language": "en"},
+			},
+			statuses: []string{"kmiss", "kmiss"},
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			resetVaryHeaders()
+			defer resetVaryHeaders()
+
+			hdrs := map[string]string{
+				headers.NameCacheControl: "max-age=60",
+				headers.NameVary:         test.vary,
+			}
+			ts, _, r, _, err := setupTestHarnessOPC("", "test", http.StatusOK, hdrs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ts.Close()
+
+			for j, rh := range test.requests {
+				for k, v := range rh {
+					r.Header.Set(k, v)
+				}
+				_, e := testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": test.statuses[j]})
+				for _, err = range e {
+					t.Errorf("request %d: %s", j, err)
+				}
+			}
+		})
+	}
+}
+
+func TestVaryKeySuffix(t *testing.T) {
+	h := http.Header{}
+	h.Set("Accept-Language", "en")
+	s1 := varyKeySuffix([]string{"Accept-Language"}, h)
+	h.Set("Accept-Language", "fr")
+	s2 := varyKeySuffix([]string{"Accept-Language"}, h)
+	if s1 == s2 {
+		t.Error("expected distinct suffixes for distinct header values")
+	}
+	if !strings.HasPrefix(s1, ".vary.") {
+		t.Errorf("unexpected suffix %s", s1)
+	}
+}
+
+func TestVaryIndex(t *testing.T) {
+
+	vi := newVaryIndex(2)
+	vi.Store("a", "1", []string{"Accept-Language"})
+	vi.Store("b", "1", []string{"X-Tenant"})
+	if names, ok := vi.Load("a", "1"); !ok || names[0] != "Accept-Language" {
+		t.Errorf("expected %s got %v", "Accept-Language", names)
+	}
+
+	// b.1 is the least recently used entry, so it is evicted
+	vi.Store("a", "2", []string{"Accept-Encoding"})
+	if vi.Len() != 2 {
+		t.Errorf("expected %d got %d", 2, vi.Len())
+	}
+	if _, ok := vi.Load("b", "1"); ok {
+		t.Error("expected b.1 to be evicted")
+	}
+
+	vi.Store("b", "1", []string{"X-Tenant"})
+	vi.Purge("a")
+	if _, ok := vi.Load("a", "2"); ok {
+		t.Error("expected a.2 to be purged")
+	}
+	if _, ok := vi.Load("b", "1"); !ok {
+		t.Error("expected b.1 to be retained")
+	}
+}
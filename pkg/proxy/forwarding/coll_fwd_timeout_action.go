@@ -0,0 +1,52 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package forwarding
+
+import "strconv"
+
+// CollapsedForwardingTimeoutAction enumerates the actions a collapsed request takes
+// when it times out waiting on the in-flight request it was collapsed into
+type CollapsedForwardingTimeoutAction int
+
+const (
+	// CFTimeoutActionFetch indicates the request issues its own upstream request
+	CFTimeoutActionFetch = CollapsedForwardingTimeoutAction(iota)
+	// CFTimeoutActionFail indicates the request fails with a 503 Service Unavailable
+	CFTimeoutActionFail
+)
+
+// CollapsedForwardingTimeoutActionNames is a map of timeout actions keyed by name
+var CollapsedForwardingTimeoutActionNames = map[string]CollapsedForwardingTimeoutAction{
+	"fetch": CFTimeoutActionFetch,
+	"fail":  CFTimeoutActionFail,
+}
+
+// CollapsedForwardingTimeoutActionValues is a map of timeout actions keyed by internal id
+var CollapsedForwardingTimeoutActionValues = make(map[CollapsedForwardingTimeoutAction]string)
+
+func init() {
+	for k, v := range CollapsedForwardingTimeoutActionNames {
+		CollapsedForwardingTimeoutActionValues[v] = k
+	}
+}
+
+func (a CollapsedForwardingTimeoutAction) String() string {
+	if v, ok := CollapsedForwardingTimeoutActionValues[a]; ok {
+		return v
+	}
+	return strconv.Itoa(int(a))
+}
@@ -0,0 +1,41 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package forwarding
+
+import (
+	"testing"
+)
+
+func TestCollapsedForwardingTimeoutActionString(t *testing.T) {
+
+	a1 := CFTimeoutActionFetch
+	a2 := CFTimeoutActionFail
+	var a3 CollapsedForwardingTimeoutAction = 13
+
+	if a1.String() != "fetch" {
+		t.Errorf("expected %s got %s", "fetch", a1.String())
+	}
+
+	if a2.String() != "fail" {
+		t.Errorf("expected %s got %s", "fail", a2.String())
+	}
+
+	if a3.String() != "13" {
+		t.Errorf("expected %s got %s", "13", a3.String())
+	}
+
+}
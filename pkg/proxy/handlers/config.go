@@ -18,17 +18,56 @@ package handlers
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/trickstercache/trickster/v2/cmd/trickster/config"
+	"github.com/trickstercache/trickster/v2/pkg/observability/logging"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/request"
 )
 
-// ConfigHandleFunc responds to the HTTP request with the running configuration
+// ConfigHandleFunc responds to the HTTP request with the running configuration, after
+// all defaults and overlays are applied, and with secrets redacted. The configuration
+// is YAML by default, or JSON when requested with format=json or an Accept header of
+// application/json. Any loader warnings are included in the response. When Purge
+// Handler credentials are configured, requests must be authenticated with them
 func ConfigHandleFunc(conf *config.Config) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set(headers.NameContentType, headers.ValueTextPlain)
+		if conf.Main != nil && len(conf.Main.PurgeHandlerCredentials) > 0 {
+			if user, ok := authenticateAdmin(conf, r); !ok {
+				var logger interface{}
+				if rsc := request.GetResources(r); rsc != nil {
+					logger = rsc.Logger
+				}
+				logging.Warn(logger, "unauthorized config request",
+					logging.Pairs{"user": user, "clientAddr": r.RemoteAddr})
+				w.Header().Set(headers.NameWWWAuthenticate, `Basic realm="trickster"`)
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+		}
 		w.Header().Set(headers.NameCacheControl, headers.ValueNoCache)
+		if r.URL.Query().Get("format") == "json" ||
+			strings.HasPrefix(r.Header.Get(headers.NameAccept), headers.ValueApplicationJSON) {
+			b, err := conf.JSON()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set(headers.NameContentType, headers.ValueApplicationJSON)
+			w.WriteHeader(http.StatusOK)
+			w.Write(b)
+			return
+		}
+		w.Header().Set(headers.NameContentType, headers.ValueTextPlain)
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(conf.String()))
+		// loader warnings are written as comments, so the output remains a valid config
+		if len(conf.LoaderWarnings) > 0 {
+			w.Write([]byte("\n# loader warnings:\n"))
+			for _, lw := range conf.LoaderWarnings {
+				w.Write([]byte("# - " + lw + "\n"))
+			}
+		}
 	}
 }
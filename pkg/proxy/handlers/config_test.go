@@ -17,12 +17,15 @@
 package handlers
 
 import (
+	"encoding/json"
 	"io"
+	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 
 	"github.com/trickstercache/trickster/v2/cmd/trickster/config"
+	fo "github.com/trickstercache/trickster/v2/pkg/frontend/options"
 )
 
 func TestConfigHandler(t *testing.T) {
@@ -61,3 +64,102 @@ func TestConfigHandler(t *testing.T) {
 	}
 
 }
+
+func TestConfigHandlerJSON(t *testing.T) {
+
+	conf, _, err := config.Load("trickster-test", "test",
+		[]string{"-origin-url", "http://1.2.3.4", "-provider", "prometheus"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+	conf.Frontend.TLSCertificates = []*fo.TLSCertificate{
+		{FullChainCertPath: "/path/to/cert.pem", PrivateKeyPath: "/path/to/key.pem"}}
+	conf.LoaderWarnings = []string{"test warning"}
+	configHandler := ConfigHandleFunc(conf)
+
+	for _, r := range []*http.Request{
+		httptest.NewRequest("GET", "http://0/trickster/config?format=json", nil),
+		func() *http.Request {
+			r := httptest.NewRequest("GET", "http://0/trickster/config", nil)
+			r.Header.Set("Accept", "application/json")
+			return r
+		}(),
+	} {
+		w := httptest.NewRecorder()
+		configHandler(w, r)
+		resp := w.Result()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("expected %d got %d", http.StatusOK, resp.StatusCode)
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("expected %s got %s", "application/json", ct)
+		}
+		doc := make(map[string]interface{})
+		if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := doc["backends"]; !ok {
+			t.Error("expected backends in config output")
+		}
+		lw, _ := doc["loader_warnings"].([]interface{})
+		if len(lw) != 1 || lw[0] != "test warning" {
+			t.Errorf("expected loader warnings got %v", doc["loader_warnings"])
+		}
+		fe := doc["frontend"].(map[string]interface{})
+		tc := fe["tls_certificates"].([]interface{})[0].(map[string]interface{})
+		if tc["private_key_path"] != "*****" {
+			t.Errorf("expected redacted key path got %v", tc["private_key_path"])
+		}
+		if tc["full_chain_cert_path"] != "/path/to/cert.pem" {
+			t.Errorf("expected %s got %v", "/path/to/cert.pem", tc["full_chain_cert_path"])
+		}
+	}
+
+	// yaml output includes the warnings as comments
+	w := httptest.NewRecorder()
+	configHandler(w, httptest.NewRequest("GET", "http://0/trickster/config", nil))
+	b, _ := io.ReadAll(w.Result().Body)
+	if !strings.HasSuffix(string(b), "# - test warning\n") {
+		t.Error("expected loader warnings in yaml output")
+	}
+	if strings.Contains(string(b), "/path/to/key.pem") {
+		t.Error("expected redacted key path in yaml output")
+	}
+}
+
+func TestConfigHandlerAuth(t *testing.T) {
+
+	conf, _, err := config.Load("trickster-test", "test",
+		[]string{"-origin-url", "http://1.2.3.4", "-provider", "prometheus"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+	conf.Main.PurgeHandlerCredentials = map[string]string{"admin": "secret"}
+	configHandler := ConfigHandleFunc(conf)
+
+	tests := []struct {
+		user, pass string
+		expected   int
+	}{
+		{"", "", http.StatusUnauthorized},
+		{"admin", "wrong", http.StatusUnauthorized},
+		{"admin", "secret", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "http://0/trickster/config", nil)
+		if test.user != "" {
+			r.SetBasicAuth(test.user, test.pass)
+		}
+		configHandler(w, r)
+		resp := w.Result()
+		if resp.StatusCode != test.expected {
+			t.Errorf("expected %d got %d", test.expected, resp.StatusCode)
+		}
+		b, _ := io.ReadAll(resp.Body)
+		if strings.Contains(string(b), "secret") {
+			t.Error("expected redacted credentials")
+		}
+	}
+}
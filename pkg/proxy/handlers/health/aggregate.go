@@ -0,0 +1,165 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package health
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/backends/healthcheck"
+	bo "github.com/trickstercache/trickster/v2/pkg/backends/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache"
+)
+
+// aggregateTTL is how long an aggregated health result is reused before the
+// component statuses are collected again
+const aggregateTTL = 2 * time.Second
+
+// cacheProbeKey is the key retrieved from each cache to confirm connectivity
+const cacheProbeKey = "trickster.healthcheck.probe"
+
+const (
+	componentTypeBackend = "backend"
+	componentTypeCache   = "cache"
+)
+
+type component struct {
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	Status    string `json:"status"`
+	Critical  bool   `json:"critical"`
+	LastCheck string `json:"lastCheck,omitempty"`
+	Detail    string `json:"detail,omitempty"`
+}
+
+// aggregator collects the status of each backend health check target and each
+// cache into a single readiness result, which is cached for aggregateTTL
+type aggregator struct {
+	hc       healthcheck.HealthChecker
+	backends bo.Lookup
+	caches   map[string]cache.Cache
+
+	mtx        sync.Mutex
+	updated    time.Time
+	healthy    bool
+	components []component
+	now        func() time.Time
+}
+
+func newAggregator(hc healthcheck.HealthChecker, backends bo.Lookup,
+	caches map[string]cache.Cache) *aggregator {
+	return &aggregator{
+		hc:       hc,
+		backends: backends,
+		caches:   caches,
+		now:      time.Now,
+	}
+}
+
+// get returns the aggregated health result, collecting it anew if the cached
+// result has expired
+func (a *aggregator) get() (bool, []component) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	if now := a.now(); a.updated.IsZero() || now.Sub(a.updated) >= aggregateTTL {
+		a.healthy, a.components = a.collect()
+		a.updated = now
+	}
+	return a.healthy, a.components
+}
+
+func (a *aggregator) collect() (bool, []component) {
+	healthy := true
+	var comps []component
+	if a.hc != nil {
+		st := a.hc.Statuses()
+		comps = make([]component, 0, len(st)+len(a.caches))
+		for k, v := range st {
+			c := component{
+				Name:     k,
+				Type:     componentTypeBackend,
+				Critical: a.isCritical(k),
+			}
+			switch v.Get() {
+			case 1:
+				c.Status = "available"
+			case -1:
+				c.Status = "unavailable"
+				c.Detail = v.Detail()
+				if c.Critical {
+					healthy = false
+				}
+			default:
+				c.Status = "unchecked"
+			}
+			if lc := v.LastCheck(); !lc.IsZero() {
+				c.LastCheck = lc.UTC().Format(time.RFC3339)
+			}
+			comps = append(comps, c)
+		}
+	}
+	for k, c := range a.caches {
+		cc := component{
+			Name:      k,
+			Type:      componentTypeCache,
+			Status:    "available",
+			Critical:  true,
+			LastCheck: a.now().UTC().Format(time.RFC3339),
+		}
+		if err := probeCache(c); err != nil {
+			cc.Status = "unavailable"
+			cc.Detail = err.Error()
+			healthy = false
+		}
+		comps = append(comps, cc)
+	}
+	sort.Slice(comps, func(i, j int) bool {
+		if comps[i].Type != comps[j].Type {
+			return comps[i].Type < comps[j].Type
+		}
+		return comps[i].Name < comps[j].Name
+	})
+	return healthy, comps
+}
+
+// isCritical returns true if the named health check target's backend is critical.
+// Upstream host targets are named <backend>/<host> and inherit their backend's setting
+func (a *aggregator) isCritical(name string) bool {
+	if i := strings.Index(name, "/"); i > 0 {
+		name = name[:i]
+	}
+	if o, ok := a.backends[name]; ok && o != nil {
+		return o.HealthCheckCritical
+	}
+	return bo.DefaultHealthCheckCritical
+}
+
+// probeCache confirms connectivity to the cache by retrieving a key; a cache miss
+// is a successful probe
+func probeCache(c cache.Cache) error {
+	if c == nil {
+		return nil
+	}
+	_, _, err := c.Retrieve(cacheProbeKey, false)
+	if err != nil && !errors.Is(err, cache.ErrKNF) {
+		return err
+	}
+	return nil
+}
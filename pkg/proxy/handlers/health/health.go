@@ -21,6 +21,7 @@ package health
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"sort"
@@ -30,6 +31,8 @@ import (
 	"time"
 
 	"github.com/trickstercache/trickster/v2/pkg/backends/healthcheck"
+	bo "github.com/trickstercache/trickster/v2/pkg/backends/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
 )
 
@@ -43,22 +46,27 @@ type healthDetail struct {
 // This handler spins up an infinitely looping background goroutine ("builder")
 // that updates the status text in real-time. So long as the HealthChecker
 // is closed with ShutDown(), the builder goroutine will exit
-func StatusHandler(hc healthcheck.HealthChecker) http.Handler {
+// The response code is 200 when every critical backend and cache is healthy,
+// and 503 otherwise, so the handler may be used as a readiness check
+func StatusHandler(hc healthcheck.HealthChecker, backends bo.Lookup,
+	caches map[string]cache.Cache) http.Handler {
 	if hc == nil {
 		return nil
 	}
 	hd := &healthDetail{} // stores the status text in JSON and Text
 	go builder(hc, hd)    // listens for rebuild notifications and updates the texts
+	agg := newAggregator(hc, backends, caches)
 
 	// the handler, when requested, simply prints out the static text stored in the healthDetail
 	// which is being updated in real time by the builder.
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var body, ct string
+		healthy, comps := agg.get()
 		hd.mtx.RLock()
 		if r != nil &&
 			((r.Header != nil && r.Header.Get(headers.NameAccept) == headers.ValueApplicationJSON) ||
 				(r.URL != nil && strings.Contains(r.URL.RawQuery, "json"))) {
-			body = hd.json
+			body = appendComponentsJSON(hd.json, healthy, comps)
 			ct = headers.ValueApplicationJSON
 		} else {
 			body = hd.text
@@ -66,7 +74,11 @@ func StatusHandler(hc healthcheck.HealthChecker) http.Handler {
 		}
 		hd.mtx.RUnlock()
 		w.Header().Set(headers.NameContentType, ct)
-		w.WriteHeader(200)
+		if healthy {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
 		w.Write([]byte(body))
 	})
 }
@@ -185,6 +197,19 @@ func udpateStatusText(hc healthcheck.HealthChecker, hd *healthDetail) {
 	hd.json = json.String()
 }
 
+// appendComponentsJSON adds the overall health and per-component breakdown to the
+// status JSON document
+func appendComponentsJSON(doc string, healthy bool, comps []component) string {
+	b, _ := json.Marshal(comps)
+	doc = strings.TrimSuffix(doc, "}")
+	if doc == "" {
+		doc = "{"
+	} else {
+		doc += ","
+	}
+	return fmt.Sprintf(`%s"healthy":%t,"components":%s}`, doc, healthy, string(b))
+}
+
 func statusToString(i int) string {
 	if i > 0 {
 		return "available"
@@ -0,0 +1,157 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/backends/healthcheck"
+	ho "github.com/trickstercache/trickster/v2/pkg/backends/healthcheck/options"
+	bo "github.com/trickstercache/trickster/v2/pkg/backends/options"
+	"github.com/trickstercache/trickster/v2/pkg/cache"
+	"github.com/trickstercache/trickster/v2/pkg/cache/status"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+)
+
+type testCache struct {
+	cache.Cache
+	err error
+}
+
+func (c *testCache) Retrieve(string, bool) ([]byte, status.LookupStatus, error) {
+	return nil, status.LookupStatusKeyMiss, c.err
+}
+
+func TestStatusHandler(t *testing.T) {
+
+	if StatusHandler(nil, nil, nil) != nil {
+		t.Error("expected nil handler")
+	}
+
+	hc := healthcheck.New()
+	defer hc.Shutdown()
+	st, err := hc.Register("test", "prometheus", ho.New(), http.DefaultClient, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backends := bo.Lookup{"test": bo.New()}
+	tc := &testCache{err: cache.ErrKNF}
+	caches := map[string]cache.Cache{"default": tc}
+
+	h := StatusHandler(hc, backends, caches)
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "http://0/trickster/health?json", nil)
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected %d got %d", http.StatusOK, w.Code)
+	}
+	if ct := w.Header().Get(headers.NameContentType); ct != headers.ValueApplicationJSON {
+		t.Errorf("expected %s got %s", headers.ValueApplicationJSON, ct)
+	}
+	var doc struct {
+		Healthy    bool        `json:"healthy"`
+		Components []component `json:"components"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if !doc.Healthy || len(doc.Components) != 2 {
+		t.Errorf("unexpected health document: %s", w.Body.String())
+	}
+	if doc.Components[0].Type != componentTypeBackend ||
+		doc.Components[0].Status != "unchecked" || !doc.Components[0].Critical {
+		t.Errorf("unexpected backend component: %v", doc.Components[0])
+	}
+
+	// the result is cached, so a status change is not reflected until the TTL expires
+	st.Set(-1)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected %d got %d", http.StatusOK, w.Code)
+	}
+}
+
+func TestAggregator(t *testing.T) {
+
+	hc := healthcheck.New()
+	defer hc.Shutdown()
+	st, err := hc.Register("test", "prometheus", ho.New(), http.DefaultClient, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ust, err := hc.Register("test/host-b:9090", "prometheus", ho.New(), http.DefaultClient, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o := bo.New()
+	tc := &testCache{err: cache.ErrKNF}
+	a := newAggregator(hc, bo.Lookup{"test": o}, map[string]cache.Cache{"default": tc})
+	now := time.Now()
+	a.now = func() time.Time { return now }
+
+	advance := func() {
+		now = now.Add(aggregateTTL)
+	}
+
+	if healthy, _ := a.get(); !healthy {
+		t.Error("expected healthy")
+	}
+
+	// a failing upstream host of a critical backend is critical
+	ust.Set(-1)
+	advance()
+	if healthy, _ := a.get(); healthy {
+		t.Error("expected unhealthy")
+	}
+
+	// a non-critical backend does not fail the overall check
+	ust.Set(1)
+	st.Set(-1)
+	o.HealthCheckCritical = false
+	advance()
+	healthy, comps := a.get()
+	if !healthy {
+		t.Error("expected healthy")
+	}
+	for _, c := range comps {
+		if c.Name == "test" && (c.Status != "unavailable" || c.Critical) {
+			t.Errorf("unexpected backend component: %v", c)
+		}
+	}
+
+	// an unreachable cache fails the overall check
+	tc.err = errors.New("connection refused")
+	advance()
+	healthy, comps = a.get()
+	if healthy {
+		t.Error("expected unhealthy")
+	}
+	if c := comps[0]; c.Type != componentTypeBackend {
+		t.Errorf("expected backends to sort first, got %v", c)
+	}
+	if c := comps[len(comps)-1]; c.Type != componentTypeCache || c.Detail != "connection refused" {
+		t.Errorf("unexpected cache component: %v", c)
+	}
+}
@@ -0,0 +1,147 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/cmd/trickster/config"
+	"github.com/trickstercache/trickster/v2/pkg/backends"
+	"github.com/trickstercache/trickster/v2/pkg/cache"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+)
+
+// Header names used by the Cache Inspect Handler to describe a cached object
+const (
+	nameCacheKey      = "X-Trickster-Cache-Key"
+	nameCacheTTL      = "X-Trickster-Cache-TTL-MS"
+	nameCacheSize     = "X-Trickster-Cache-Size"
+	nameCacheProvider = "X-Trickster-Cache-Provider"
+)
+
+// InspectResult is the JSON summary returned by the Cache Inspect Handler
+type InspectResult struct {
+	Backend    string     `json:"backend"`
+	Path       string     `json:"path,omitempty"`
+	Key        string     `json:"key"`
+	Cache      string     `json:"cache"`
+	Provider   string     `json:"provider"`
+	TTL        int64      `json:"ttl_ms"`
+	Expiration *time.Time `json:"expiration,omitempty"`
+	Size       int64      `json:"size"`
+	LastWrite  *time.Time `json:"last_write,omitempty"`
+	LastAccess *time.Time `json:"last_access,omitempty"`
+}
+
+// InspectHandlerFunc reports whether an object is in a backend's cache, along with its
+// remaining TTL and size, without retrieving its value. As with the Cache Purge Handler,
+// the object is identified either by its full cache key, or by a path and query parameters
+// from which the backend's request handlers derive the key. GET requests receive a JSON
+// summary, while HEAD requests receive the same details only as response headers.
+// Objects that are not in the cache receive a 404.
+func InspectHandlerFunc(conf *config.Config, from *backends.Backends) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			w.Header().Set(headers.NameAllow, http.MethodGet+", "+http.MethodHead)
+			adminError(w, http.StatusMethodNotAllowed, http.StatusText(http.StatusMethodNotAllowed))
+			return
+		}
+		qp := req.URL.Query()
+		inspectFrom := qp.Get("backend")
+		inspectKey := qp.Get("key")
+		inspectPath := qp.Get("path")
+		if inspectFrom == "" || (inspectKey == "" && inspectPath == "") {
+			adminError(w, http.StatusBadRequest, "Usage: "+config.DefaultInspectHandlerPath+
+				"?backend={backend}, plus key={key} or path={path}&{query params}")
+			return
+		}
+		fromBackend := from.Get(inspectFrom)
+		if fromBackend == nil {
+			adminError(w, http.StatusBadRequest, "Backend "+inspectFrom+" doesn't exist.")
+			return
+		}
+		fromCache := fromBackend.Cache()
+		if fromCache == nil {
+			adminError(w, http.StatusBadRequest, "Backend "+inspectFrom+" doesn't have a cache.")
+			return
+		}
+
+		if inspectKey == "" {
+			qp.Del("backend")
+			qp.Del("path")
+			k, err := deriveCacheKey(req.Context(), fromBackend, inspectPath, qp)
+			if err != nil {
+				adminError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			inspectKey = k
+		}
+
+		w.Header().Set(nameCacheKey, inspectKey)
+		oi, err := cache.Inspect(fromCache, inspectKey)
+		if err == cache.ErrKNF {
+			adminError(w, http.StatusNotFound, "Key "+inspectKey+" is not in the cache.")
+			return
+		}
+		if err == cache.ErrInspectUnsupported {
+			adminError(w, http.StatusNotImplemented, err.Error())
+			return
+		}
+		if err != nil {
+			adminError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+
+		// a TTL of -1 indicates an object that does not expire
+		ir := &InspectResult{Backend: inspectFrom, Path: inspectPath, Key: inspectKey,
+			TTL: -1, Size: oi.Size}
+		if ttl := oi.TTL(time.Now()); ttl >= 0 {
+			ir.TTL = ttl.Milliseconds()
+		}
+		if cc := fromCache.Configuration(); cc != nil {
+			ir.Cache = cc.Name
+			ir.Provider = cc.Provider
+		}
+		if !oi.Expiration.IsZero() {
+			ir.Expiration = &oi.Expiration
+		}
+		if !oi.LastWrite.IsZero() {
+			ir.LastWrite = &oi.LastWrite
+		}
+		if !oi.LastAccess.IsZero() {
+			ir.LastAccess = &oi.LastAccess
+		}
+
+		b, err := json.Marshal(ir)
+		if err != nil {
+			adminError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set(nameCacheTTL, strconv.FormatInt(ir.TTL, 10))
+		w.Header().Set(nameCacheSize, strconv.FormatInt(ir.Size, 10))
+		w.Header().Set(nameCacheProvider, ir.Provider)
+		w.Header().Set(headers.NameContentType, headers.ValueApplicationJSON)
+		w.Header().Set(headers.NameCacheControl, headers.ValueNoCache)
+		w.WriteHeader(http.StatusOK)
+		if req.Method == http.MethodGet {
+			w.Write(b)
+		}
+	}
+}
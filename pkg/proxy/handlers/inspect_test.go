@@ -0,0 +1,107 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/cmd/trickster/config"
+	"github.com/trickstercache/trickster/v2/pkg/backends"
+	cr "github.com/trickstercache/trickster/v2/pkg/cache/registration"
+	"github.com/trickstercache/trickster/v2/pkg/observability/logging"
+)
+
+func TestInspectHandler(t *testing.T) {
+
+	conf, _, err := config.Load("trickster-test", "test",
+		[]string{"-provider", "reverseproxycache", "-origin-url", "http://0/"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+
+	caches := cr.LoadCachesFromConfig(conf, logging.ConsoleLogger("error"))
+	defer cr.CloseCaches(caches)
+	c := caches["default"]
+
+	o := conf.Backends["default"]
+	b, err := backends.New("default", o, nil, http.NewServeMux(), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs := backends.Backends{"default": b}
+	inspectHandler := InspectHandlerFunc(conf, &bs)
+
+	if err = c.Store("test-key", []byte("test"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method string
+		query  string
+		code   int
+	}{
+		{http.MethodPost, "backend=default&key=test-key", http.StatusMethodNotAllowed},
+		{http.MethodGet, "backend=default", http.StatusBadRequest},
+		{http.MethodGet, "backend=missing&key=test-key", http.StatusBadRequest},
+		{http.MethodGet, "backend=default&key=missing-key", http.StatusNotFound},
+		{http.MethodHead, "backend=default&key=missing-key", http.StatusNotFound},
+		{http.MethodGet, "backend=default&key=test-key", http.StatusOK},
+		{http.MethodHead, "backend=default&key=test-key", http.StatusOK},
+	}
+
+	for i, test := range tests {
+		t.Run(test.method+" "+test.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(test.method, "http://0/trickster/inspect?"+test.query, nil)
+			inspectHandler(w, r)
+			if w.Code != test.code {
+				t.Fatalf("test %d expected %d got %d", i, test.code, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			if v := w.Header().Get(nameCacheSize); v != "4" {
+				t.Errorf("test %d expected %s got %s", i, "4", v)
+			}
+			if test.method == http.MethodHead {
+				if w.Body.Len() != 0 {
+					t.Errorf("test %d expected empty body got %s", i, w.Body.String())
+				}
+				return
+			}
+			ir := &InspectResult{}
+			if err := json.Unmarshal(w.Body.Bytes(), ir); err != nil {
+				t.Fatal(err)
+			}
+			if ir.Key != "test-key" || ir.Size != 4 || ir.Provider != "memory" {
+				t.Errorf("test %d unexpected result %s", i, w.Body.String())
+			}
+			if ir.TTL <= 0 || ir.TTL > time.Minute.Milliseconds() {
+				t.Errorf("test %d unexpected ttl %d", i, ir.TTL)
+			}
+		})
+	}
+
+	// inspecting does not retrieve the object, so its value is untouched
+	if d, _, err := c.Retrieve("test-key", false); err != nil || string(d) != "test" {
+		t.Errorf("expected %s got %s", "test", string(d))
+	}
+}
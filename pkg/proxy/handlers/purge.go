@@ -17,12 +17,19 @@
 package handlers
 
 import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
 	"net/http"
+	"net/url"
 
 	"github.com/trickstercache/trickster/v2/cmd/trickster/config"
 	"github.com/trickstercache/trickster/v2/pkg/backends"
 	"github.com/trickstercache/trickster/v2/pkg/checksum/md5"
 	"github.com/trickstercache/trickster/v2/pkg/observability/logging"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/cacheevents"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/engines"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/request"
 	"github.com/trickstercache/trickster/v2/pkg/router"
@@ -50,6 +57,8 @@ func PurgeKeyHandleFunc(conf *config.Config, from backends.Backends) func(http.R
 			return
 		}
 		fromCache.Remove(purgeKey)
+		engines.PurgeVaryIndex(purgeFrom)
+		fromBackend.Configuration().CacheEventNotifier.Notify(cacheevents.OpPurge, purgeKey, 0, 0)
 		w.Header().Set(headers.NameContentType, headers.ValueTextPlain)
 		w.Header().Set(headers.NameCacheControl, headers.ValueNoCache)
 		w.WriteHeader(http.StatusOK)
@@ -89,9 +98,172 @@ func PurgePathHandlerFunc(conf *config.Config, from *backends.Backends) func(htt
 			return
 		}
 		fromCache.Remove(purgeKey)
+		engines.PurgeVaryIndex(purgeFrom)
+		fromBackend.Configuration().CacheEventNotifier.Notify(cacheevents.OpPurge, purgeKey, 0, 0)
 		w.Header().Set(headers.NameContentType, headers.ValueTextPlain)
 		w.Header().Set(headers.NameCacheControl, headers.ValueNoCache)
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("Purged " + purgeFrom + ":" + purgePath + " (" + purgeKey + ")"))
 	}
 }
+
+// PurgeResult is the JSON summary returned by the Cache Purge Handler
+type PurgeResult struct {
+	Backend string `json:"backend"`
+	Path    string `json:"path,omitempty"`
+	Key     string `json:"key,omitempty"`
+	Prefix  string `json:"prefix,omitempty"`
+	Existed bool   `json:"existed"`
+	Removed int    `json:"removed"`
+}
+
+// PurgeHandlerFunc removes an object from a backend's cache. The object is identified
+// either by its full cache key, or by a path and query parameters, which are run through
+// the backend's request handlers to derive the key exactly as a client request would.
+// Alternatively, all objects under a path prefix are removed when prefix is provided.
+// Requests must be authenticated with HTTP Basic credentials from the main config's
+// purge_handler_credentials, and each purge is logged with the authenticated username.
+func PurgeHandlerFunc(conf *config.Config, from *backends.Backends) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		var logger interface{}
+		if rsc := request.GetResources(req); rsc != nil {
+			logger = rsc.Logger
+		}
+		if req.Method != http.MethodPost {
+			w.Header().Set(headers.NameAllow, http.MethodPost)
+			adminError(w, http.StatusMethodNotAllowed, http.StatusText(http.StatusMethodNotAllowed))
+			return
+		}
+		user, ok := authenticateAdmin(conf, req)
+		if !ok {
+			logging.Warn(logger, "unauthorized cache purge request",
+				logging.Pairs{"user": user, "clientAddr": req.RemoteAddr})
+			w.Header().Set(headers.NameWWWAuthenticate, `Basic realm="trickster"`)
+			adminError(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+			return
+		}
+		if err := req.ParseForm(); err != nil {
+			adminError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		qp := req.Form
+		purgeFrom := qp.Get("backend")
+		purgeKey := qp.Get("key")
+		purgePath := qp.Get("path")
+		purgePrefix, isPrefix := qp["prefix"]
+		if purgeFrom == "" || (purgeKey == "" && purgePath == "" && !isPrefix) {
+			adminError(w, http.StatusBadRequest, "Usage: "+config.DefaultPurgeHandlerPath+
+				" with backend={backend}, plus key={key}, prefix={path prefix}"+
+				" or path={path}&{query params}")
+			return
+		}
+		fromBackend := from.Get(purgeFrom)
+		if fromBackend == nil {
+			adminError(w, http.StatusBadRequest, "Backend "+purgeFrom+" doesn't exist.")
+			return
+		}
+		fromCache := fromBackend.Cache()
+		if fromCache == nil {
+			adminError(w, http.StatusBadRequest, "Backend "+purgeFrom+" doesn't have a cache.")
+			return
+		}
+
+		if isPrefix && purgeKey == "" && purgePath == "" {
+			prefix := purgePrefix[0]
+			o := fromBackend.Configuration()
+			n, err := engines.PurgeByPathPrefix(fromCache, o, prefix)
+			if err != nil {
+				adminError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			engines.PurgeVaryIndex(purgeFrom)
+			kps, _ := engines.PathPrefixKeyPrefixes(o, prefix)
+			for _, kp := range kps {
+				o.CacheEventNotifier.Notify(cacheevents.OpPurgePrefix, kp, 0, 0)
+			}
+			logging.Info(logger, "cache purge", logging.Pairs{"user": user, "clientAddr": req.RemoteAddr,
+				"backend": purgeFrom, "prefix": prefix, "removed": n})
+			writePurgeResult(w, &PurgeResult{Backend: purgeFrom, Prefix: prefix,
+				Existed: n > 0, Removed: n})
+			return
+		}
+
+		if purgeKey == "" {
+			qp.Del("backend")
+			qp.Del("path")
+			k, err := deriveCacheKey(req.Context(), fromBackend, purgePath, qp)
+			if err != nil {
+				adminError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			purgeKey = k
+		}
+
+		// hold the key's write lock so the purge doesn't interleave with an in-flight write
+		nl, _ := fromCache.Locker().Acquire(purgeKey)
+		_, _, err := fromCache.Retrieve(purgeKey, true)
+		existed := err == nil
+		if existed {
+			fromCache.Remove(purgeKey)
+		}
+		nl.Release()
+		engines.PurgeVaryIndex(purgeFrom)
+		// other caches may hold the object even when this one does not
+		fromBackend.Configuration().CacheEventNotifier.Notify(cacheevents.OpPurge, purgeKey, 0, 0)
+
+		logging.Info(logger, "cache purge", logging.Pairs{"user": user, "clientAddr": req.RemoteAddr,
+			"backend": purgeFrom, "key": purgeKey, "existed": existed})
+
+		pr := &PurgeResult{Backend: purgeFrom, Path: purgePath, Key: purgeKey, Existed: existed}
+		if existed {
+			pr.Removed = 1
+		}
+		writePurgeResult(w, pr)
+	}
+}
+
+// deriveCacheKey returns the cache key that the backend's path handlers derive for a
+// GET request to path with the provided query parameters, without fulfilling the request
+func deriveCacheKey(ctx context.Context, b backends.Backend, path string,
+	qp url.Values) (string, error) {
+	// the backend's path handlers will merge their resources into this collection
+	// and, since it is marked KeyOnly, derive the cache key without fulfilling the request
+	krsc := &request.Resources{KeyOnly: true}
+	kr, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		"http://trickster"+path+"?"+qp.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	kr = request.SetResources(kr, krsc)
+	b.Router().ServeHTTP(&captureResponseWriter{header: make(http.Header)}, kr)
+	if krsc.CacheKey == "" {
+		return "", errors.New("Request to " + path + " is not cacheable.")
+	}
+	return krsc.CacheKey, nil
+}
+
+func writePurgeResult(w http.ResponseWriter, pr *PurgeResult) {
+	b, err := json.Marshal(pr)
+	if err != nil {
+		adminError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set(headers.NameContentType, headers.ValueApplicationJSON)
+	w.Header().Set(headers.NameCacheControl, headers.ValueNoCache)
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+// authenticateAdmin returns the username provided in the request's Basic credentials,
+// and whether the credentials match those configured for the Cache Purge Handler
+func authenticateAdmin(conf *config.Config, req *http.Request) (string, bool) {
+	user, pass, ok := req.BasicAuth()
+	if !ok || conf == nil || conf.Main == nil {
+		return user, false
+	}
+	expected, ok := conf.Main.PurgeHandlerCredentials[user]
+	if !ok {
+		return user, false
+	}
+	return user, subtle.ConstantTimeCompare([]byte(pass), []byte(expected)) == 1
+}
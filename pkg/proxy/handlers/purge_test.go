@@ -0,0 +1,114 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/cmd/trickster/config"
+	"github.com/trickstercache/trickster/v2/pkg/backends"
+	cr "github.com/trickstercache/trickster/v2/pkg/cache/registration"
+	"github.com/trickstercache/trickster/v2/pkg/observability/logging"
+)
+
+func TestPurgeHandler(t *testing.T) {
+
+	conf, _, err := config.Load("trickster-test", "test",
+		[]string{"-provider", "reverseproxycache", "-origin-url", "http://0/"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+	conf.Main.PurgeHandlerCredentials = map[string]string{"admin": "secret"}
+
+	caches := cr.LoadCachesFromConfig(conf, logging.ConsoleLogger("error"))
+	defer cr.CloseCaches(caches)
+	c := caches["default"]
+
+	o := conf.Backends["default"]
+	b, err := backends.New("default", o, nil, http.NewServeMux(), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs := backends.Backends{"default": b}
+	purgeHandler := PurgeHandlerFunc(conf, &bs)
+
+	if err = c.Store("test-key", []byte("test"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method  string
+		body    string
+		user    string
+		pass    string
+		code    int
+		existed bool
+	}{
+		{http.MethodGet, "backend=default&key=test-key", "admin", "secret",
+			http.StatusMethodNotAllowed, false},
+		{http.MethodPost, "backend=default&key=test-key", "", "",
+			http.StatusUnauthorized, false},
+		{http.MethodPost, "backend=default&key=test-key", "admin", "wrong",
+			http.StatusUnauthorized, false},
+		{http.MethodPost, "backend=default", "admin", "secret",
+			http.StatusBadRequest, false},
+		{http.MethodPost, "backend=missing&key=test-key", "admin", "secret",
+			http.StatusBadRequest, false},
+		// the memory cache does not support purging by prefix
+		{http.MethodPost, "backend=default&prefix=/", "admin", "secret",
+			http.StatusBadRequest, false},
+		{http.MethodPost, "backend=default&key=test-key", "admin", "secret",
+			http.StatusOK, true},
+		{http.MethodPost, "backend=default&key=test-key", "admin", "secret",
+			http.StatusOK, false},
+	}
+
+	for i, test := range tests {
+		t.Run(test.method+" "+test.body, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(test.method, "http://0/trickster/purge",
+				strings.NewReader(test.body))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if test.user != "" {
+				r.SetBasicAuth(test.user, test.pass)
+			}
+			purgeHandler(w, r)
+			if w.Code != test.code {
+				t.Fatalf("test %d expected %d got %d", i, test.code, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			pr := &PurgeResult{}
+			if err := json.Unmarshal(w.Body.Bytes(), pr); err != nil {
+				t.Fatal(err)
+			}
+			if pr.Existed != test.existed {
+				t.Errorf("test %d expected %t got %t", i, test.existed, pr.Existed)
+			}
+		})
+	}
+
+	if _, _, err := c.Retrieve("test-key", true); err == nil {
+		t.Error("expected purged key to be removed from the cache")
+	}
+}
@@ -0,0 +1,134 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/trickstercache/trickster/v2/cmd/trickster/config"
+	"github.com/trickstercache/trickster/v2/pkg/backends"
+	"github.com/trickstercache/trickster/v2/pkg/observability/logging"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/request"
+)
+
+// WarmResult is the JSON summary returned by the Cache Warm Handler
+type WarmResult struct {
+	Backend string `json:"backend"`
+	Path    string `json:"path"`
+	Key     string `json:"key"`
+	TTL     int64  `json:"ttl_ms"`
+	Bytes   int    `json:"bytes"`
+	Status  string `json:"status"`
+}
+
+// WarmHandlerFunc pre-populates a backend's cache for a time series query by running
+// it through the backend's own request handlers. All query parameters other than
+// backend and path are passed to the backend unmodified, so the query and time range
+// are provided in the backend's native format.
+func WarmHandlerFunc(conf *config.Config, from *backends.Backends) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		var logger interface{}
+		if rsc := request.GetResources(req); rsc != nil {
+			logger = rsc.Logger
+		}
+		qp := req.URL.Query()
+		warmFrom := qp.Get("backend")
+		warmPath := qp.Get("path")
+		if warmFrom == "" || warmPath == "" {
+			logging.Warn(logger, "failed to get backend/path args", logging.Pairs{})
+			adminError(w, http.StatusBadRequest, "Usage: "+config.DefaultWarmHandlerPath+
+				"?backend={backend}&path={path}&{query params}")
+			return
+		}
+		fromBackend := from.Get(warmFrom)
+		if fromBackend == nil {
+			adminError(w, http.StatusBadRequest, "Backend "+warmFrom+" doesn't exist.")
+			return
+		}
+		if _, ok := fromBackend.(backends.TimeseriesBackend); !ok {
+			adminError(w, http.StatusBadRequest, "Backend "+warmFrom+" is not a time series backend.")
+			return
+		}
+		fromCache := fromBackend.Cache()
+		if fromCache == nil {
+			adminError(w, http.StatusBadRequest, "Backend "+warmFrom+" doesn't have a cache.")
+			return
+		}
+		o := fromBackend.Configuration()
+
+		qp.Del("backend")
+		qp.Del("path")
+
+		ctx := req.Context()
+		if o.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, o.Timeout)
+			defer cancel()
+		}
+
+		// the backend's path handlers will merge their resources into this collection,
+		// which lets us learn the cache key the delta proxy cache derived
+		wrsc := &request.Resources{}
+		wr, err := http.NewRequestWithContext(ctx, http.MethodGet,
+			"http://trickster"+warmPath+"?"+qp.Encode(), nil)
+		if err != nil {
+			adminError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		wr = request.SetResources(wr, wrsc)
+
+		logging.Debug(logger, "warming cache", logging.Pairs{"backend": warmFrom, "path": warmPath})
+
+		ww := &captureResponseWriter{header: make(http.Header), statusCode: http.StatusOK}
+		fromBackend.Router().ServeHTTP(ww, wr)
+
+		if ww.statusCode != http.StatusOK {
+			adminError(w, http.StatusBadGateway, "Upstream returned status "+http.StatusText(ww.statusCode))
+			return
+		}
+		if wrsc.CacheKey == "" {
+			adminError(w, http.StatusBadRequest, "Request to "+warmPath+" is not a cacheable time series query.")
+			return
+		}
+
+		// the cache write happens asynchronously while the key's write lock is held,
+		// so acquiring the lock here waits for the write to complete
+		if l, err := fromCache.Locker().RAcquire(wrsc.CacheKey); err == nil {
+			l.RRelease()
+		}
+
+		b, err := json.Marshal(&WarmResult{
+			Backend: warmFrom,
+			Path:    warmPath,
+			Key:     wrsc.CacheKey,
+			TTL:     o.TimeseriesTTL.Milliseconds(),
+			Bytes:   ww.bytes,
+			Status:  ww.header.Get(headers.NameTricksterResult),
+		})
+		if err != nil {
+			adminError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set(headers.NameContentType, headers.ValueApplicationJSON)
+		w.Header().Set(headers.NameCacheControl, headers.ValueNoCache)
+		w.WriteHeader(http.StatusOK)
+		w.Write(b)
+	}
+}
@@ -0,0 +1,136 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/cmd/trickster/config"
+	"github.com/trickstercache/trickster/v2/pkg/backends"
+	"github.com/trickstercache/trickster/v2/pkg/backends/irondb"
+	"github.com/trickstercache/trickster/v2/pkg/cache/registration"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+	"github.com/trickstercache/trickster/v2/pkg/router"
+	"github.com/trickstercache/trickster/v2/pkg/util/middleware"
+)
+
+func TestWarmHandler(t *testing.T) {
+
+	conf, _, err := config.Load("trickster-test", "test",
+		[]string{"-provider", "reverseproxycache", "-origin-url", "http://0/"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+
+	b := backends.Backends{}
+	warmHandler := WarmHandlerFunc(conf, &b)
+
+	tests := []struct {
+		url  string
+		code int
+	}{
+		{"http://0/trickster/warm", http.StatusBadRequest},
+		{"http://0/trickster/warm?backend=test", http.StatusBadRequest},
+		{"http://0/trickster/warm?backend=missing&path=/api/v1/query_range", http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", test.url, nil)
+			warmHandler(w, r)
+			if w.Code != test.code {
+				t.Errorf("expected %d got %d", test.code, w.Code)
+			}
+		})
+	}
+}
+
+func TestWarmHandlerFillsCache(t *testing.T) {
+
+	// the range must be recent enough to be retained in the cache
+	start := time.Now().Add(-2 * time.Hour).Truncate(5 * time.Minute).Unix()
+	var upstreamRequests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamRequests++
+		w.Header().Set(headers.NameContentType, headers.ValueApplicationJSON)
+		w.Write([]byte(fmt.Sprintf("[[%d,1],[%d,2],[%d,3],[%d,4]]",
+			start, start+300, start+600, start+900)))
+	}))
+	defer ts.Close()
+
+	conf, _, err := config.Load("trickster-test", "test",
+		[]string{"-provider", "irondb", "-origin-url", ts.URL})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+	caches := registration.LoadCachesFromConfig(conf, nil)
+	defer registration.CloseCaches(caches)
+	c := caches["default"]
+	o := conf.Backends["default"]
+
+	// route the backend's rollup path the same way routing.RegisterPathRoutes does
+	r := router.NewRouter()
+	client, err := irondb.NewClient("default", o, r, c, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.HTTPClient = client.HTTPClient()
+	p := client.DefaultPathConfigs(o)["/rollup/"]
+	r.PathPrefix(p.Path).Handler(middleware.WithResourcesContext(client, o, c, p, nil, nil,
+		client.Handlers()["rollup"]))
+
+	b := backends.Backends{"default": client}
+	warmHandler := WarmHandlerFunc(conf, &b)
+
+	const path = "/rollup/00112233-4455-6677-8899-aabbccddeeff/metric"
+	query := fmt.Sprintf("start_ts=%d&end_ts=%d&rollup_span=300s&type=average",
+		start, start+900)
+
+	w := httptest.NewRecorder()
+	warmHandler(w, httptest.NewRequest("GET",
+		"http://0/trickster/warm?backend=default&path="+path+"&"+query, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var wr WarmResult
+	if err = json.Unmarshal(w.Body.Bytes(), &wr); err != nil {
+		t.Fatal(err)
+	}
+	if wr.Key == "" || !strings.Contains(wr.Status, "status=kmiss") {
+		t.Errorf("unexpected warm result %v", wr)
+	}
+
+	// a follow-up request for the warmed range is served from the cache
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "http://0"+path+"?"+query, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d", http.StatusOK, w.Code)
+	}
+	if v := w.Header().Get(headers.NameTricksterResult); !strings.Contains(v, "status=hit") {
+		t.Errorf("expected a cache hit got %s", v)
+	}
+	if upstreamRequests != 1 {
+		t.Errorf("expected %d got %d", 1, upstreamRequests)
+	}
+}
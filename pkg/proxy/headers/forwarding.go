@@ -111,12 +111,66 @@ func IsValidForwardingType(input string) bool {
 	return ok
 }
 
-// AddForwardingHeaders sets or appends to the forwarding headers to the provided request
-func AddForwardingHeaders(r *http.Request, headerType string) {
+// TrustedProxies is a list of networks whose proxies are trusted to provide the
+// forwarding headers of the requests they send to Trickster
+type TrustedProxies []*net.IPNet
+
+// ParseTrustedProxies returns the TrustedProxies for the provided list of CIDRs. Entries
+// that are a single IP address are trusted as a /32 (or /128 for IPv6). If an entry
+// is invalid, it is returned along with an error
+func ParseTrustedProxies(cidrs []string) (TrustedProxies, string, error) {
+	if len(cidrs) == 0 {
+		return nil, "", nil
+	}
+	out := make(TrustedProxies, 0, len(cidrs))
+	for _, c := range cidrs {
+		if !strings.Contains(c, "/") {
+			ip := net.ParseIP(c)
+			if ip == nil {
+				return nil, c, fmt.Errorf("invalid ip address: %s", c)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			out = append(out, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, c, err
+		}
+		out = append(out, n)
+	}
+	return out, "", nil
+}
+
+// Contains returns true if the provided IP address is in one of the trusted networks
+func (tp TrustedProxies) Contains(addr string) bool {
+	if len(tp) == 0 {
+		return false
+	}
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, n := range tp {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddForwardingHeaders sets or appends to the forwarding headers to the provided request.
+// Any forwarding headers already in the request are retained, and extended with the
+// current hop, only when the request was received from a trusted proxy; otherwise,
+// they are replaced with headers describing only the current hop
+func AddForwardingHeaders(r *http.Request, headerType string, trusted TrustedProxies) {
 	if r == nil {
 		return
 	}
-	hop := HopsFromRequest(r)
+	hop := HopsFromRequest(r, trusted)
 	// Now we can safely remove any pre-existing Forwarding headers before we set them fresh
 	StripClientHeaders(r.Header)
 	StripForwardingHeaders(r.Header)
@@ -213,19 +267,40 @@ func SetVia(r *http.Request, hop *Hop) {
 	}
 }
 
-// HopsFromRequest extracts a Hop reference that includes a list of any previous hops
-func HopsFromRequest(r *http.Request) *Hop {
-	clientIP, _, _ := net.SplitHostPort(r.RemoteAddr)
+// HopsFromRequest extracts a Hop reference describing the request as received from the
+// client. The list of previous hops, and the client's original host and scheme, are
+// taken from the request's forwarding headers only when the client is a trusted proxy
+func HopsFromRequest(r *http.Request, trusted TrustedProxies) *Hop {
+	clientIP, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		clientIP = r.RemoteAddr
+	}
+	// the request URL may already address the origin, so the scheme of the client's
+	// connection is determined by whether it was made over TLS
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
 	hop := &Hop{
 		RemoteAddr: clientIP,
-		Scheme:     r.URL.Scheme,
+		Host:       r.Host,
+		Scheme:     scheme,
 		Protocol:   r.Proto,
 	}
 	if r.Header == nil {
 		return hop
 	}
 	hop.Via = r.Header.Get(NameVia)
+	if !trusted.Contains(clientIP) {
+		return hop
+	}
 	hop.Hops = HopsFromHeader(r.Header)
+	if v := r.Header.Get(NameXForwardedHost); v != "" {
+		hop.Host = v
+	}
+	if v := r.Header.Get(NameXForwardedProto); v != "" {
+		hop.Scheme = v
+	}
 	return hop
 }
 
@@ -297,17 +372,21 @@ func formatForwardedAddress(input string) string {
 }
 
 func parseXForwardHeaders(h http.Header) Hops {
-	xff := h.Get(NameXForwardedFor)
+	// a chain may be split across multiple X-Forwarded-For header lines
+	xff := strings.Join(h.Values(NameXForwardedFor), ",")
 	if xff != "" {
 		fwds := strings.Split(strings.Replace(xff, " ", "", -1), ",")
-		hops := make(Hops, len(fwds))
-		for i, f := range fwds {
+		hops := make(Hops, 0, len(fwds))
+		for _, f := range fwds {
+			if f == "" {
+				continue
+			}
 			hop := &Hop{RemoteAddr: f}
 			hop.Host = h.Get(NameXForwardedHost)
 			hop.Protocol = h.Get(NameXForwardedProto)
 			hop.Server = h.Get(NameXForwardedServer)
 			hop.normalizeAddresses()
-			hops[i] = hop
+			hops = append(hops, hop)
 		}
 		return hops
 	}
@@ -17,6 +17,7 @@
 package headers
 
 import (
+	"crypto/tls"
 	"net/http"
 	"testing"
 )
@@ -70,7 +71,7 @@ func TestFdFromRequest(t *testing.T) {
 	r.Header = nil
 	r.RemoteAddr = "1.2.3.4:5678"
 	r.ProtoMajor = 2
-	hop := HopsFromRequest(r)
+	hop := HopsFromRequest(r, nil)
 	if hop.RemoteAddr != testHops1.RemoteAddr {
 		t.Errorf("expected %s got %s", testHops1.RemoteAddr, hop.RemoteAddr)
 	}
@@ -99,21 +100,111 @@ func TestAddForwardingHeaders(t *testing.T) {
 	r, _ := http.NewRequest("GET", "https://bar.com/", nil)
 	r.RemoteAddr = "1.2.3.4:5678"
 	r.ProtoMajor = 2
-	AddForwardingHeaders(nil, "none")
+	AddForwardingHeaders(nil, "none", nil)
 	if _, ok := r.Header[NameXForwardedFor]; ok {
 		t.Error("did not expect X-Forwarded-For header to be set")
 	}
-	AddForwardingHeaders(r, "none")
+	AddForwardingHeaders(r, "none", nil)
 	if _, ok := r.Header[NameXForwardedFor]; ok {
 		t.Error("did not expect X-Forwarded-For header to be set")
 	}
-	AddForwardingHeaders(r, "x")
+	AddForwardingHeaders(r, "x", nil)
 	if _, ok := r.Header[NameXForwardedFor]; !ok {
 		t.Error("expected X-Forwarded-For header to be set")
 	}
 
 }
 
+func TestAddForwardingHeadersChain(t *testing.T) {
+
+	trusted, _, _ := ParseTrustedProxies([]string{"10.0.0.0/8"})
+
+	tests := []struct {
+		remoteAddr    string
+		tls           bool
+		xff           []string
+		xfHost        string
+		xfProto       string
+		expectedXFF   string
+		expectedHost  string
+		expectedProto string
+	}{
+		// a client connecting directly
+		{"1.1.1.1:5678", false, nil, "", "",
+			"1.1.1.1", "trickster.example.com", "http"},
+		{"1.1.1.1:5678", true, nil, "", "",
+			"1.1.1.1", "trickster.example.com", "https"},
+		// a trusted proxy, whose chain is extended
+		{"10.0.0.5:5678", false, []string{"1.1.1.1"}, "public.example.com", "https",
+			"1.1.1.1, 10.0.0.5", "public.example.com", "https"},
+		{"10.0.0.5:5678", false, []string{"1.1.1.1, 10.0.0.9"}, "", "",
+			"1.1.1.1, 10.0.0.9, 10.0.0.5", "trickster.example.com", "http"},
+		{"10.0.0.5:5678", false, []string{"1.1.1.1", "10.0.0.9"}, "", "",
+			"1.1.1.1, 10.0.0.9, 10.0.0.5", "trickster.example.com", "http"},
+		// an untrusted proxy (or a client spoofing the headers), whose chain is replaced
+		{"2.2.2.2:5678", false, []string{"1.1.1.1"}, "public.example.com", "https",
+			"2.2.2.2", "trickster.example.com", "http"},
+	}
+
+	for i, test := range tests {
+		r, _ := http.NewRequest(http.MethodGet, "http://origin.example.com/", nil)
+		r.Host = "trickster.example.com"
+		r.RemoteAddr = test.remoteAddr
+		if test.tls {
+			r.TLS = &tls.ConnectionState{}
+		}
+		for _, v := range test.xff {
+			r.Header.Add(NameXForwardedFor, v)
+		}
+		if test.xfHost != "" {
+			r.Header.Set(NameXForwardedHost, test.xfHost)
+		}
+		if test.xfProto != "" {
+			r.Header.Set(NameXForwardedProto, test.xfProto)
+		}
+		AddForwardingHeaders(r, "x", trusted)
+		if v := r.Header.Values(NameXForwardedFor); len(v) != 1 || v[0] != test.expectedXFF {
+			t.Errorf("test %d expected %s got %v", i, test.expectedXFF, v)
+		}
+		if v := r.Header.Get(NameXForwardedHost); v != test.expectedHost {
+			t.Errorf("test %d expected %s got %s", i, test.expectedHost, v)
+		}
+		if v := r.Header.Get(NameXForwardedProto); v != test.expectedProto {
+			t.Errorf("test %d expected %s got %s", i, test.expectedProto, v)
+		}
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+
+	tp, bad, err := ParseTrustedProxies(nil)
+	if tp != nil || bad != "" || err != nil {
+		t.Errorf("expected nil got %v %s %v", tp, bad, err)
+	}
+
+	tp, _, err = ParseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1", "fd00::/8", "::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, addr := range []string{"10.1.2.3", "192.168.1.1", "fd00::1", "::1"} {
+		if !tp.Contains(addr) {
+			t.Errorf("expected %s to be trusted", addr)
+		}
+	}
+	for _, addr := range []string{"11.0.0.1", "192.168.1.2", "fe80::1", "invalid", ""} {
+		if tp.Contains(addr) {
+			t.Errorf("expected %s to be untrusted", addr)
+		}
+	}
+
+	for _, c := range []string{"10.0.0.0/33", "not-an-ip"} {
+		_, bad, err = ParseTrustedProxies([]string{"10.0.0.0/8", c})
+		if err == nil || bad != c {
+			t.Errorf("expected error for %s got %s %v", c, bad, err)
+		}
+	}
+}
+
 func TestXHeader(t *testing.T) {
 	h := testHops1.XHeader()
 	if _, ok := h[NameXForwardedFor]; !ok {
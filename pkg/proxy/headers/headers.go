@@ -23,6 +23,8 @@ import (
 	"net/http"
 	"sort"
 	"strings"
+
+	"golang.org/x/net/http/httpguts"
 )
 
 const (
@@ -54,11 +56,27 @@ const (
 	ValuePublic = "public"
 	// ValueSharedMaxAge represents the HTTP Header Value of "s-maxage"
 	ValueSharedMaxAge = "s-maxage"
+	// ValueStaleWhileRevalidate represents the HTTP Header Value of "stale-while-revalidate"
+	ValueStaleWhileRevalidate = "stale-while-revalidate"
+	// ValueStaleIfError represents the HTTP Header Value of "stale-if-error"
+	ValueStaleIfError = "stale-if-error"
+	// ValueWarningResponseIsStale represents the HTTP Warning Header Value indicating a stale response
+	ValueWarningResponseIsStale = `110 - "Response is Stale"`
+	// ValueWarningRevalidationFailed represents the HTTP Warning Header Value indicating a
+	// stale response was served because the origin could not be reached
+	ValueWarningRevalidationFailed = `111 - "Revalidation Failed"`
 	// ValueTextPlain represents the HTTP Header Value of "text/plain"
 	ValueTextPlain = "text/plain"
 	// ValueXFormURLEncoded represents the HTTP Header Value of "application/x-www-form-urlencoded"
 	ValueXFormURLEncoded = "application/x-www-form-urlencoded"
 
+	// ValueApplicationGRPC represents the HTTP Header Value of "application/grpc"
+	ValueApplicationGRPC = "application/grpc"
+	// ValueApplicationGRPCWeb represents the HTTP Header prefix shared by all gRPC-Web Content Types
+	ValueApplicationGRPCWeb = "application/grpc-web"
+	// ValueTrailers represents the HTTP Header Value of "trailers"
+	ValueTrailers = "trailers"
+
 	// ValueMultipartByteRanges represents the HTTP Header prefix for a Multipart Byte Range response
 	ValueMultipartByteRanges = "multipart/byteranges; boundary="
 
@@ -84,8 +102,18 @@ const (
 	NameContentRange = "Content-Range"
 	// NameTricksterResult represents the HTTP Header Name of "X-Trickster-Result"
 	NameTricksterResult = "X-Trickster-Result"
+	// NameServerTiming represents the HTTP Header Name of "Server-Timing"
+	NameServerTiming = "Server-Timing"
 	// NameAcceptEncoding represents the HTTP Header Name of "Accept-Encoding"
 	NameAcceptEncoding = "Accept-Encoding"
+	// NameUserAgent represents the HTTP Header Name of "User-Agent"
+	NameUserAgent = "User-Agent"
+	// NameVary represents the HTTP Header Name of "Vary"
+	NameVary = "Vary"
+	// NameWarning represents the HTTP Header Name of "Warning"
+	NameWarning = "Warning"
+	// NameCookie represents the HTTP Header Name of "Cookie"
+	NameCookie = "Cookie"
 	// NameSetCookie represents the HTTP Header Name of "Set-Cookie"
 	NameSetCookie = "Set-Cookie"
 	// NameRange represents the HTTP Header Name of "Range"
@@ -126,6 +154,17 @@ const (
 	NameTrailer = "Trailer"
 	// NameUpgrade represents the HTTP Header Name of "Upgrade"
 	NameUpgrade = "Upgrade"
+	// NameAllow represents the HTTP Header Name of "Allow"
+	NameAllow = "Allow"
+	// NameWWWAuthenticate represents the HTTP Header Name of "WWW-Authenticate"
+	NameWWWAuthenticate = "Www-Authenticate"
+	// NameRetryAfter represents the HTTP Header Name of "Retry-After"
+	NameRetryAfter = "Retry-After"
+
+	// NameGRPCStatus represents the HTTP Header Name of "Grpc-Status"
+	NameGRPCStatus = "Grpc-Status"
+	// NameGRPCMessage represents the HTTP Header Name of "Grpc-Message"
+	NameGRPCMessage = "Grpc-Message"
 
 	// NameTrkHCStatus represents the HTTP Header Name of "Trk-HC-Status"
 	NameTrkHCStatus = "Trk-HC-Status"
@@ -169,7 +208,11 @@ func Merge(dst, src http.Header) {
 	}
 }
 
-// UpdateHeaders updates the provided headers collection with the provided updates
+// UpdateHeaders updates the provided headers collection with the provided updates.
+// A header name prefixed with '-' is removed, and one prefixed with '+' has the value
+// appended, unless the header already has that value; all others are set to the value.
+// Since each update is idempotent, the same updates may be applied to a response
+// when it is fetched from the origin and again when it is written to the client.
 func UpdateHeaders(headers http.Header, updates map[string]string) {
 	if headers == nil || updates == nil || len(updates) == 0 {
 		return
@@ -185,13 +228,42 @@ func UpdateHeaders(headers http.Header, updates map[string]string) {
 		}
 		if k[0:1] == "+" {
 			k = k[1:]
-			headers.Add(k, v)
+			if !hasValue(headers.Values(k), v) {
+				headers.Add(k, v)
+			}
 			continue
 		}
 		headers.Set(k, v)
 	}
 }
 
+func hasValue(values []string, v string) bool {
+	for _, val := range values {
+		if val == v {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateUpdates returns an error if any of the provided updates, in the format used
+// by UpdateHeaders, has an invalid header name or value
+func ValidateUpdates(updates map[string]string) error {
+	for k, v := range updates {
+		name := k
+		if len(name) > 0 && (name[0] == '-' || name[0] == '+') {
+			name = name[1:]
+		}
+		if !httpguts.ValidHeaderFieldName(name) {
+			return fmt.Errorf("invalid header name: %q", k)
+		}
+		if !httpguts.ValidHeaderFieldValue(v) {
+			return fmt.Errorf("invalid value for header %s: %q", name, v)
+		}
+	}
+	return nil
+}
+
 // ExtractHeader returns the value for the provided header name, and a boolean indicating if the header was present
 func ExtractHeader(headers http.Header, header string) (string, bool) {
 	if Value, ok := headers[header]; ok {
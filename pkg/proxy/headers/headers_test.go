@@ -73,6 +73,34 @@ func TestUpdateHeaders(t *testing.T) {
 		t.Errorf("mismatch\nexpected: %v\n     got: %v\n", expected, headers)
 	}
 
+	// applying the same updates again should not change the headers
+	UpdateHeaders(headers, map[string]string{"foo1": "bar", "-foo2": "",
+		"+foo3": "bar", "+foo5": "bar"})
+	if !reflect.DeepEqual(headers, expected) {
+		t.Errorf("mismatch\nexpected: %v\n     got: %v\n", expected, headers)
+	}
+
+}
+
+func TestValidateUpdates(t *testing.T) {
+	tests := []struct {
+		updates map[string]string
+		valid   bool
+	}{
+		{nil, true},
+		{map[string]string{"X-Test": "1", "+Vary": "Origin", "-X-Debug-Id": ""}, true},
+		{map[string]string{"": "1"}, false},
+		{map[string]string{"+": "1"}, false},
+		{map[string]string{"X Test": "1"}, false},
+		{map[string]string{"-X:Test": ""}, false},
+		{map[string]string{"X-Test": "1\r\nX-Injected: 1"}, false},
+	}
+	for i, test := range tests {
+		err := ValidateUpdates(test.updates)
+		if (err == nil) != test.valid {
+			t.Errorf("test %d: expected valid %t got error %v", i, test.valid, err)
+		}
+	}
 }
 
 func TestRemoveClientHeaders(t *testing.T) {
@@ -0,0 +1,131 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package headers
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// Injections is a collection of compiled request header templates, keyed by header name
+type Injections map[string]*template.Template
+
+// CompileInjections parses the provided map of header names to templates. Each template
+// is also test-executed against an empty request, so that references to unsupported
+// request fields are caught here rather than per-request. Headers with invalid templates
+// are omitted from the Injections, and the error for each is returned in the error map,
+// keyed by header name.
+func CompileInjections(in map[string]string) (Injections, map[string]error) {
+	if len(in) == 0 {
+		return nil, nil
+	}
+	out := make(Injections, len(in))
+	var errs map[string]error
+	for k, v := range in {
+		t, err := template.New(k).Parse(v)
+		if err == nil {
+			err = t.Execute(io.Discard, &injectionData{r: emptyRequest()})
+		}
+		if err != nil {
+			if errs == nil {
+				errs = make(map[string]error)
+			}
+			errs[k] = err
+			continue
+		}
+		out[http.CanonicalHeaderKey(k)] = t
+	}
+	return out, errs
+}
+
+func emptyRequest() *http.Request {
+	return &http.Request{URL: &url.URL{}, Header: make(http.Header)}
+}
+
+// Apply evaluates each injection template against the provided request and sets the
+// resulting values in the request's headers. Headers whose templates fail to execute
+// are left unmodified.
+func (inj Injections) Apply(r *http.Request) {
+	if len(inj) == 0 || r == nil {
+		return
+	}
+	if r.Header == nil {
+		r.Header = make(http.Header)
+	}
+	d := &injectionData{r: r}
+	keys := make([]string, 0, len(inj))
+	for k := range inj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	buf := &bytes.Buffer{}
+	for _, k := range keys {
+		buf.Reset()
+		if err := inj[k].Execute(buf, d); err != nil {
+			continue
+		}
+		r.Header.Set(k, buf.String())
+	}
+}
+
+// injectionData provides the request fields that are available to injection templates
+type injectionData struct {
+	r        *http.Request
+	segments []string
+}
+
+// PathSegment returns the 1-based nth segment of the request path, or an empty string
+// if the path does not have n segments. For /a/b/c, segment 1 is a, and 3 is c.
+func (d *injectionData) PathSegment(n int) string {
+	if d.segments == nil {
+		d.segments = strings.Split(strings.Trim(d.r.URL.Path, "/"), "/")
+	}
+	if n < 1 || n > len(d.segments) {
+		return ""
+	}
+	return d.segments[n-1]
+}
+
+// Query returns the first value of the named request query parameter
+func (d *injectionData) Query(name string) string {
+	return d.r.URL.Query().Get(name)
+}
+
+// Header returns the first value of the named request header
+func (d *injectionData) Header(name string) string {
+	return d.r.Header.Get(name)
+}
+
+// Method returns the request method
+func (d *injectionData) Method() string {
+	return d.r.Method
+}
+
+// Host returns the request host
+func (d *injectionData) Host() string {
+	return d.r.Host
+}
+
+// Path returns the request path
+func (d *injectionData) Path() string {
+	return d.r.URL.Path
+}
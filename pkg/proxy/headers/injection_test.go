@@ -0,0 +1,85 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package headers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCompileInjections(t *testing.T) {
+
+	inj, errs := CompileInjections(nil)
+	if inj != nil || errs != nil {
+		t.Error("expected nil injections and errors")
+	}
+
+	inj, errs = CompileInjections(map[string]string{
+		"x-tenant":  "{{ .PathSegment 1 }}",
+		"X-Static":  "static",
+		"X-Broken":  "{{ .PathSegment 1 ",
+		"X-Unknown": "{{ .NotAField }}",
+	})
+	if len(inj) != 2 {
+		t.Errorf("expected %d got %d", 2, len(inj))
+	}
+	if _, ok := inj["X-Tenant"]; !ok {
+		t.Error("expected canonicalized header name X-Tenant")
+	}
+	if len(errs) != 2 {
+		t.Errorf("expected %d got %d", 2, len(errs))
+	}
+	for _, k := range []string{"X-Broken", "X-Unknown"} {
+		if _, ok := errs[k]; !ok {
+			t.Errorf("expected error for %s", k)
+		}
+	}
+}
+
+func TestInjectionsApply(t *testing.T) {
+
+	inj, _ := CompileInjections(map[string]string{
+		"X-Tenant":  "{{ .PathSegment 1 }}",
+		"X-Missing": "{{ .PathSegment 9 }}",
+		"X-Org":     `{{ .Query "org" }}`,
+		"X-Static":  "static",
+		"X-Echo":    `{{ .Method }} {{ .Host }}{{ .Path }} {{ .Header "X-In" }}`,
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "http://example.com/acme/api/v1/query?org=eng", nil)
+	r.Header.Set("X-In", "test")
+	inj.Apply(r)
+
+	expected := map[string]string{
+		"X-Tenant":  "acme",
+		"X-Missing": "",
+		"X-Org":     "eng",
+		"X-Static":  "static",
+		"X-Echo":    "GET example.com/acme/api/v1/query test",
+	}
+	for k, v := range expected {
+		if h := r.Header.Get(k); h != v {
+			t.Errorf("expected %s got %s for %s", v, h, k)
+		}
+	}
+
+	// nil-safety
+	var empty Injections
+	empty.Apply(r)
+	inj.Apply(nil)
+}
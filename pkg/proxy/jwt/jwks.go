@@ -0,0 +1,195 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+)
+
+// ErrUnknownKey is an error for a token whose key ID is not in the JWKS
+var ErrUnknownKey = errors.New("token key id not found in jwks")
+
+// jwksTimeout is the timeout for requests to the JWKS URL
+const jwksTimeout = 10 * time.Second
+
+// minJWKSRefresh limits how often the JWKS is refetched on behalf of tokens with an
+// unknown key ID, or after a failed fetch
+const minJWKSRefresh = time.Minute
+
+// maxJWKSBytes is the maximum size of a JWKS response that is read
+const maxJWKSBytes = 1024 * 1024
+
+// jwks fetches and caches the public keys of a JSON Web Key Set, by key ID
+type jwks struct {
+	url     string
+	refresh time.Duration
+	client  *http.Client
+	now     func() time.Time
+
+	mtx         sync.Mutex
+	keys        map[string]crypto.PublicKey
+	fetched     time.Time
+	lastAttempt time.Time
+}
+
+func newJWKS(url string, refresh time.Duration) *jwks {
+	return &jwks{url: url, refresh: refresh, client: &http.Client{Timeout: jwksTimeout},
+		now: time.Now}
+}
+
+// key returns the public key for the key ID. The JWKS is refetched once it is older
+// than the refresh interval, or when the key ID is not found (such as after a key
+// rotation), at most once per minJWKSRefresh
+func (j *jwks) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+	now := j.now()
+	k, ok := j.lookup(kid)
+	stale := j.keys == nil || now.Sub(j.fetched) >= j.refresh
+	if (stale || !ok) && (j.lastAttempt.IsZero() || now.Sub(j.lastAttempt) >= minJWKSRefresh) {
+		j.lastAttempt = now
+		keys, err := j.fetch(ctx)
+		if err != nil {
+			// previously fetched keys continue to be used until a fetch succeeds
+			if j.keys == nil {
+				return nil, err
+			}
+		} else {
+			j.keys, j.fetched = keys, now
+		}
+		k, ok = j.lookup(kid)
+	}
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return k, nil
+}
+
+// lookup returns the key for the key ID. A token without a key ID matches the only
+// key of a single-key JWKS
+func (j *jwks) lookup(kid string) (crypto.PublicKey, bool) {
+	if k, ok := j.keys[kid]; ok {
+		return k, true
+	}
+	if kid == "" && len(j.keys) == 1 {
+		for _, k := range j.keys {
+			return k, true
+		}
+	}
+	return nil, false
+}
+
+// jwk is a JSON Web Key, per RFC 7517
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// fetch requests the JWKS and returns its RSA and EC signing keys. Keys of other
+// types, and invalid keys, are ignored
+func (j *jwks) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, j.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set(headers.NameAccept, headers.ValueApplicationJSON)
+	resp, err := j.client.Do(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reaching jwks url: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code [%d] from jwks url", resp.StatusCode)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err = json.NewDecoder(io.LimitReader(resp.Body, maxJWKSBytes)).Decode(&set); err != nil {
+		return nil, fmt.Errorf("invalid jwks response: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if pk := k.publicKey(); pk != nil {
+			keys[k.Kid] = pk
+		}
+	}
+	return keys, nil
+}
+
+// publicKey returns the RSA or ECDSA public key represented by the JWK, or nil
+func (k jwk) publicKey() crypto.PublicKey {
+	switch k.Kty {
+	case "RSA":
+		n, e := decodeBigInt(k.N), decodeBigInt(k.E)
+		if n == nil || e == nil || !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}
+	case "EC":
+		var c elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			c = elliptic.P256()
+		case "P-384":
+			c = elliptic.P384()
+		case "P-521":
+			c = elliptic.P521()
+		default:
+			return nil
+		}
+		x, y := decodeBigInt(k.X), decodeBigInt(k.Y)
+		if x == nil || y == nil || !c.IsOnCurve(x, y) {
+			return nil
+		}
+		return &ecdsa.PublicKey{Curve: c, X: x, Y: y}
+	}
+	return nil
+}
+
+// decodeBigInt returns the integer represented by a base64url-encoded, big-endian
+// JWK parameter, or nil
+func decodeBigInt(s string) *big.Int {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil
+	}
+	return new(big.Int).SetBytes(b)
+}
@@ -0,0 +1,271 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package jwt provides the verification of JWT bearer tokens, and the extraction
+// of a claim from them for inclusion in a request's cache key
+package jwt
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	_ "crypto/sha256" // registers crypto.SHA256
+	_ "crypto/sha512" // registers crypto.SHA384 and crypto.SHA512
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+)
+
+// ErrMissingToken is an error for a request without a bearer token
+var ErrMissingToken = errors.New("missing bearer token")
+
+// ErrMalformedToken is an error for a bearer token that is not a well-formed JWT
+var ErrMalformedToken = errors.New("malformed token")
+
+// ErrUnsupportedAlgorithm is an error for a token signed with an algorithm that is
+// unsupported, or that does not match the type of the verification key
+var ErrUnsupportedAlgorithm = errors.New("unsupported token signing algorithm")
+
+// ErrInvalidSignature is an error for a token whose signature does not verify
+var ErrInvalidSignature = errors.New("invalid token signature")
+
+// ErrTokenExpired is an error for a token whose exp claim has passed
+var ErrTokenExpired = errors.New("token is expired")
+
+// ErrTokenNotYetValid is an error for a token whose nbf claim has not yet passed
+var ErrTokenNotYetValid = errors.New("token is not yet valid")
+
+// ErrMissingTokenClaim is an error for a token that does not include the claim
+var ErrMissingTokenClaim = errors.New("token does not include the claim")
+
+// algorithms maps the supported JWS signing algorithms to their hash functions
+var algorithms = map[string]crypto.Hash{
+	"HS256": crypto.SHA256, "HS384": crypto.SHA384, "HS512": crypto.SHA512,
+	"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+	"PS256": crypto.SHA256, "PS384": crypto.SHA384, "PS512": crypto.SHA512,
+	"ES256": crypto.SHA256, "ES384": crypto.SHA384, "ES512": crypto.SHA512,
+}
+
+// Verifier verifies the signature and the exp and nbf claims of the bearer tokens
+// of requests against the configured key or JWKS, and returns the value of a claim
+type Verifier struct {
+	claim     []string
+	reject    bool
+	hmacKey   []byte
+	publicKey crypto.PublicKey
+	jwks      *jwks
+	now       func() time.Time
+}
+
+// New returns a new Verifier for the provided Options, which must be validated
+func New(o *Options) *Verifier {
+	v := &Verifier{
+		claim:     strings.Split(o.Claim, "."),
+		reject:    o.InvalidTokenAction == InvalidTokenActionReject,
+		publicKey: o.PublicKey,
+		now:       time.Now,
+	}
+	if o.JWKSURL != "" {
+		v.jwks = newJWKS(o.JWKSURL, o.JWKSRefresh)
+	} else if o.Key != "" && o.PublicKey == nil {
+		v.hmacKey = []byte(o.Key)
+	}
+	return v
+}
+
+// RejectsInvalid returns true if requests with a missing or invalid token should be
+// rejected, rather than proxied without using the cache
+func (v *Verifier) RejectsInvalid() bool {
+	return v.reject
+}
+
+// Claim returns the value of the claim from the request's bearer token, or an
+// error if the token is missing, invalid or expired
+func (v *Verifier) Claim(r *http.Request) (string, error) {
+	token, ok := bearerToken(r.Header)
+	if !ok {
+		return "", ErrMissingToken
+	}
+	return v.ClaimFromToken(r.Context(), token)
+}
+
+// ClaimFromToken returns the value of the claim from the provided token, or an
+// error if the token is invalid or expired. Object and array claim values are
+// returned as JSON
+func (v *Verifier) ClaimFromToken(ctx context.Context, token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", ErrMalformedToken
+	}
+	var hdr struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &hdr); err != nil {
+		return "", ErrMalformedToken
+	}
+	if err := v.verify(ctx, hdr.Alg, hdr.Kid, parts[0]+"."+parts[1], parts[2]); err != nil {
+		return "", err
+	}
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return "", ErrMalformedToken
+	}
+	now := v.now()
+	if t, ok := numericDate(claims["exp"]); ok && !now.Before(t) {
+		return "", ErrTokenExpired
+	}
+	if t, ok := numericDate(claims["nbf"]); ok && now.Before(t) {
+		return "", ErrTokenNotYetValid
+	}
+	var c interface{} = claims
+	for _, name := range v.claim {
+		m, ok := c.(map[string]interface{})
+		if !ok {
+			return "", ErrMissingTokenClaim
+		}
+		if c, ok = m[name]; !ok {
+			return "", ErrMissingTokenClaim
+		}
+	}
+	var s string
+	switch cv := c.(type) {
+	case string:
+		s = cv
+	case json.Number:
+		s = cv.String()
+	case bool:
+		s = strconv.FormatBool(cv)
+	case nil:
+	default:
+		b, _ := json.Marshal(cv)
+		s = string(b)
+	}
+	if s == "" {
+		return "", ErrMissingTokenClaim
+	}
+	return s, nil
+}
+
+// verify verifies the token signature against the configured key or JWKS
+func (v *Verifier) verify(ctx context.Context, alg, kid, signed, signature string) error {
+	hash, ok := algorithms[alg]
+	if !ok {
+		return ErrUnsupportedAlgorithm
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return ErrMalformedToken
+	}
+	if alg[:2] == "HS" {
+		// an HMAC-signed token is only accepted when the key is a shared secret, so
+		// that a public key can't be used as one
+		if v.hmacKey == nil {
+			return ErrUnsupportedAlgorithm
+		}
+		m := hmac.New(hash.New, v.hmacKey)
+		m.Write([]byte(signed))
+		if !hmac.Equal(m.Sum(nil), sig) {
+			return ErrInvalidSignature
+		}
+		return nil
+	}
+	key := v.publicKey
+	if v.jwks != nil {
+		if key, err = v.jwks.key(ctx, kid); err != nil {
+			return err
+		}
+	}
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+	switch alg[:2] {
+	case "RS", "PS":
+		k, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return ErrUnsupportedAlgorithm
+		}
+		if alg[0] == 'R' {
+			err = rsa.VerifyPKCS1v15(k, hash, digest, sig)
+		} else {
+			err = rsa.VerifyPSS(k, hash, digest, sig, nil)
+		}
+		if err != nil {
+			return ErrInvalidSignature
+		}
+	case "ES":
+		k, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return ErrUnsupportedAlgorithm
+		}
+		// the signature is the concatenation of the fixed-width R and S values
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size ||
+			!ecdsa.Verify(k, digest, new(big.Int).SetBytes(sig[:size]),
+				new(big.Int).SetBytes(sig[size:])) {
+			return ErrInvalidSignature
+		}
+	}
+	return nil
+}
+
+// bearerToken returns the token from the Authorization header, if it uses the
+// Bearer scheme
+func bearerToken(h http.Header) (string, bool) {
+	v := h.Get(headers.NameAuthorization)
+	if len(v) < 7 || !strings.EqualFold(v[:7], "bearer ") {
+		return "", false
+	}
+	v = strings.TrimSpace(v[7:])
+	return v, v != ""
+}
+
+// decodeSegment unmarshals a base64url-encoded JSON segment of a token. Numbers are
+// decoded as json.Number, so that large numeric claims are not rounded
+func decodeSegment(s string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return err
+	}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	return d.Decode(v)
+}
+
+// numericDate returns the time represented by a NumericDate claim value
+func numericDate(v interface{}) (time.Time, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	sec := math.Floor(f)
+	return time.Unix(int64(sec), int64((f-sec)*float64(time.Second))), true
+}
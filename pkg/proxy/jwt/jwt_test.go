@@ -0,0 +1,271 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+)
+
+var testRSAKey, _ = rsa.GenerateKey(rand.Reader, 2048)
+
+var testECKey, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+func encodeSegment(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// newTestToken returns a token with the provided claims, signed with key, which is
+// an HMAC secret ([]byte), *rsa.PrivateKey or *ecdsa.PrivateKey
+func newTestToken(alg, kid string, claims map[string]interface{}, key interface{}) string {
+	hdr := map[string]string{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		hdr["kid"] = kid
+	}
+	signed := encodeSegment(hdr) + "." + encodeSegment(claims)
+	digest := sha256.Sum256([]byte(signed))
+	var sig []byte
+	switch k := key.(type) {
+	case []byte:
+		m := hmac.New(sha256.New, k)
+		m.Write([]byte(signed))
+		sig = m.Sum(nil)
+	case *rsa.PrivateKey:
+		sig, _ = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		r, s, _ := ecdsa.Sign(rand.Reader, k, digest[:])
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func newTestVerifier(t *testing.T, o *Options) *Verifier {
+	if err := o.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	return New(o)
+}
+
+func TestValidate(t *testing.T) {
+
+	b, _ := x509.MarshalPKIXPublicKey(&testRSAKey.PublicKey)
+	pemKey := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: b}))
+
+	tests := []struct {
+		o        *Options
+		expected error
+	}{
+		{&Options{}, ErrMissingClaim},
+		{&Options{Claim: "tenant", InvalidTokenAction: "ignore"}, ErrInvalidTokenAction},
+		{&Options{Claim: "tenant", Key: "secret", JWKSURL: "https://example.com/jwks"},
+			ErrKeyWithJWKS},
+		{&Options{Claim: "tenant", JWKSURL: "/jwks"}, ErrInvalidJWKSURL},
+		{&Options{Claim: "tenant", Key: "-----BEGIN PUBLIC KEY-----\ninvalid\n-----END PUBLIC KEY-----"},
+			ErrInvalidPublicKey},
+		{&Options{Claim: "tenant", Key: "secret", InvalidTokenAction: "Reject"}, nil},
+		{&Options{Claim: "tenant", Key: pemKey}, nil},
+		{&Options{Claim: "tenant"}, ErrMissingKey},
+	}
+
+	for i, test := range tests {
+		if err := test.o.Validate(); err != test.expected {
+			t.Errorf("test %d expected %v got %v", i, test.expected, err)
+		}
+	}
+
+	o := tests[5].o
+	if o.InvalidTokenAction != InvalidTokenActionReject ||
+		o.JWKSRefresh != DefaultJWKSRefreshMS*time.Millisecond {
+		t.Errorf("unexpected defaults: %s %s", o.InvalidTokenAction, o.JWKSRefresh)
+	}
+	if !New(o).RejectsInvalid() {
+		t.Error("expected true")
+	}
+	if _, ok := tests[6].o.PublicKey.(*rsa.PublicKey); !ok {
+		t.Errorf("expected rsa public key got %T", tests[6].o.PublicKey)
+	}
+	if o2 := tests[6].o.Clone(); o2.Key != pemKey || o2.PublicKey == nil ||
+		o2.Claim != "tenant" || o2.InvalidTokenAction != InvalidTokenActionBypass {
+		t.Errorf("expected %v got %v", tests[6].o, o2)
+	}
+}
+
+func TestClaimHMAC(t *testing.T) {
+
+	secret := []byte("s3cr3t")
+	v := newTestVerifier(t, &Options{Claim: "org.tenant", Key: string(secret)})
+	now := time.Now()
+	v.now = func() time.Time { return now }
+
+	exp := now.Add(time.Hour).Unix()
+	tests := []struct {
+		token    string
+		expected string
+		err      error
+	}{
+		{newTestToken("HS256", "", map[string]interface{}{"exp": exp,
+			"org": map[string]interface{}{"tenant": "acme"}}, secret), "acme", nil},
+		{newTestToken("HS256", "", map[string]interface{}{
+			"org": map[string]interface{}{"tenant": 12345678901234567}}, secret),
+			"12345678901234567", nil},
+		{newTestToken("HS256", "", map[string]interface{}{"exp": exp,
+			"org": map[string]interface{}{"tenant": "acme"}}, []byte("wrong")), "",
+			ErrInvalidSignature},
+		{newTestToken("HS256", "", map[string]interface{}{"exp": now.Unix(),
+			"org": map[string]interface{}{"tenant": "acme"}}, secret), "", ErrTokenExpired},
+		{newTestToken("HS256", "", map[string]interface{}{"nbf": now.Add(time.Minute).Unix(),
+			"org": map[string]interface{}{"tenant": "acme"}}, secret), "", ErrTokenNotYetValid},
+		{newTestToken("HS256", "", map[string]interface{}{"tenant": "acme"}, secret), "",
+			ErrMissingTokenClaim},
+		{newTestToken("HS256", "", map[string]interface{}{"org": "acme"}, secret), "",
+			ErrMissingTokenClaim},
+		{newTestToken("none", "", map[string]interface{}{
+			"org": map[string]interface{}{"tenant": "acme"}}, nil), "", ErrUnsupportedAlgorithm},
+		{newTestToken("RS256", "", map[string]interface{}{
+			"org": map[string]interface{}{"tenant": "acme"}}, testRSAKey), "",
+			ErrUnsupportedAlgorithm},
+		{"not-a-token", "", ErrMalformedToken},
+	}
+
+	for i, test := range tests {
+		c, err := v.ClaimFromToken(context.Background(), test.token)
+		if c != test.expected || err != test.err {
+			t.Errorf("test %d expected %s %v got %s %v", i, test.expected, test.err, c, err)
+		}
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "http://127.0.0.1/", nil)
+	if _, err := v.Claim(r); err != ErrMissingToken {
+		t.Errorf("expected %v got %v", ErrMissingToken, err)
+	}
+	r.Header.Set(headers.NameAuthorization, "bearer "+tests[0].token)
+	if c, err := v.Claim(r); c != "acme" || err != nil {
+		t.Errorf("expected %s got %s %v", "acme", c, err)
+	}
+}
+
+func TestClaimPublicKey(t *testing.T) {
+
+	b, _ := x509.MarshalPKIXPublicKey(&testRSAKey.PublicKey)
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: b})
+	v := newTestVerifier(t, &Options{Claim: "tenant", Key: string(pemKey)})
+
+	claims := map[string]interface{}{"tenant": "acme"}
+	if c, err := v.ClaimFromToken(context.Background(),
+		newTestToken("RS256", "", claims, testRSAKey)); c != "acme" || err != nil {
+		t.Errorf("expected %s got %s %v", "acme", c, err)
+	}
+
+	// an HMAC token signed with the public key as its secret must not verify
+	if _, err := v.ClaimFromToken(context.Background(),
+		newTestToken("HS256", "", claims, pemKey)); err != ErrUnsupportedAlgorithm {
+		t.Errorf("expected %v got %v", ErrUnsupportedAlgorithm, err)
+	}
+
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	if _, err := v.ClaimFromToken(context.Background(),
+		newTestToken("RS256", "", claims, otherKey)); err != ErrInvalidSignature {
+		t.Errorf("expected %v got %v", ErrInvalidSignature, err)
+	}
+}
+
+func TestClaimWithoutKey(t *testing.T) {
+	// a Verifier is never left without a key by Validate, but one constructed
+	// directly must not accept unverified tokens
+	v := New(&Options{Claim: "tenant"})
+	for _, alg := range []string{"HS256", "RS256"} {
+		c, err := v.ClaimFromToken(context.Background(),
+			newTestToken(alg, "", map[string]interface{}{"tenant": "acme"}, []byte("any")))
+		if c != "" || err == nil {
+			t.Errorf("expected error for %s got %s", alg, c)
+		}
+	}
+}
+
+func TestClaimJWKS(t *testing.T) {
+
+	encodeInt := func(i *big.Int) string {
+		return base64.RawURLEncoding.EncodeToString(i.Bytes())
+	}
+	keys := []map[string]string{
+		{"kty": "RSA", "kid": "rsa-1", "use": "sig", "n": encodeInt(testRSAKey.N),
+			"e": encodeInt(big.NewInt(int64(testRSAKey.E)))},
+		{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": encodeInt(testECKey.X),
+			"y": encodeInt(testECKey.Y)},
+	}
+	var fetches int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+	}))
+	defer ts.Close()
+
+	v := newTestVerifier(t, &Options{Claim: "tenant", JWKSURL: ts.URL})
+	now := time.Now()
+	v.jwks.now = func() time.Time { return now }
+	claims := map[string]interface{}{"tenant": "acme"}
+
+	tests := []struct {
+		token string
+		err   error
+	}{
+		{newTestToken("RS256", "rsa-1", claims, testRSAKey), nil},
+		{newTestToken("ES256", "ec-1", claims, testECKey), nil},
+		{newTestToken("ES256", "rsa-1", claims, testECKey), ErrUnsupportedAlgorithm},
+		{newTestToken("ES256", "ec-2", claims, testECKey), ErrUnknownKey},
+		{newTestToken("ES256", "ec-2", claims, testECKey), ErrUnknownKey},
+	}
+	for i, test := range tests {
+		if _, err := v.ClaimFromToken(context.Background(), test.token); err != test.err {
+			t.Errorf("test %d expected %v got %v", i, test.err, err)
+		}
+	}
+	// an unknown key id doesn't trigger a refetch within minJWKSRefresh of the last one
+	if fetches != 1 {
+		t.Errorf("expected %d got %d", 1, fetches)
+	}
+
+	// a rotated key is found once the JWKS can be refetched
+	keys[1]["kid"] = "ec-2"
+	now = now.Add(minJWKSRefresh)
+	if _, err := v.ClaimFromToken(context.Background(), tests[3].token); err != nil {
+		t.Error(err)
+	}
+	if fetches != 2 {
+		t.Errorf("expected %d got %d", 2, fetches)
+	}
+}
@@ -0,0 +1,167 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// InvalidTokenActionBypass proxies requests with a missing or invalid token to the
+	// origin without using the cache
+	InvalidTokenActionBypass = "bypass"
+	// InvalidTokenActionReject responds to requests with a missing or invalid token with
+	// 401 Unauthorized
+	InvalidTokenActionReject = "reject"
+)
+
+// DefaultJWKSRefreshMS is the default interval at which the JWKS is refetched
+const DefaultJWKSRefreshMS = 3600000
+
+// ErrMissingClaim returns an error for a cache_key_jwt with no claim
+var ErrMissingClaim = errors.New("cache_key_jwt claim is required")
+
+// ErrInvalidTokenAction returns an error for an unsupported invalid_token_action
+var ErrInvalidTokenAction = errors.New("cache_key_jwt invalid_token_action must be " +
+	InvalidTokenActionBypass + " or " + InvalidTokenActionReject)
+
+// ErrKeyWithJWKS returns an error for a cache_key_jwt with both a key and a jwks_url
+var ErrKeyWithJWKS = errors.New("cache_key_jwt key and jwks_url are mutually exclusive")
+
+// ErrMissingKey returns an error for a cache_key_jwt with neither a key nor a jwks_url
+var ErrMissingKey = errors.New("cache_key_jwt key or jwks_url is required")
+
+// ErrInvalidJWKSURL returns an error for a jwks_url that is not an absolute http(s) URL
+var ErrInvalidJWKSURL = errors.New("cache_key_jwt jwks_url must be an absolute http or https url")
+
+// ErrInvalidPublicKey returns an error for a PEM-encoded key that is not an RSA or
+// ECDSA public key
+var ErrInvalidPublicKey = errors.New("cache_key_jwt key must be an RSA or ECDSA public key " +
+	"when PEM-encoded")
+
+// Options defines how a path derives a cache key component from a claim of the
+// request's JWT bearer token
+type Options struct {
+	// Claim is the name of the token claim whose value is included in the cache key.
+	// A claim nested within an object claim is named with dots, such as org.id
+	Claim string `yaml:"claim,omitempty"`
+	// Key is used to verify the token signature. It is either a PEM-encoded RSA or ECDSA
+	// public key, or a shared secret for HMAC-signed tokens
+	Key string `yaml:"key,omitempty"`
+	// JWKSURL is the URL of a JSON Web Key Set used to verify the token signature
+	JWKSURL string `yaml:"jwks_url,omitempty"`
+	// JWKSRefreshMS is the interval at which the JWKS is refetched
+	JWKSRefreshMS int `yaml:"jwks_refresh_ms,omitempty"`
+	// InvalidTokenAction is the handling of requests with a missing, invalid or expired
+	// token: 'bypass' (the default) or 'reject'
+	InvalidTokenAction string `yaml:"invalid_token_action,omitempty"`
+
+	// PublicKey is the parsed Key, when it is PEM-encoded
+	PublicKey crypto.PublicKey `yaml:"-"`
+	// JWKSRefresh is the time.Duration representation of JWKSRefreshMS
+	JWKSRefresh time.Duration `yaml:"-"`
+}
+
+// Clone returns an exact copy of the subject Options
+func (o *Options) Clone() *Options {
+	return &Options{
+		Claim:              o.Claim,
+		Key:                o.Key,
+		JWKSURL:            o.JWKSURL,
+		JWKSRefreshMS:      o.JWKSRefreshMS,
+		InvalidTokenAction: o.InvalidTokenAction,
+		PublicKey:          o.PublicKey,
+		JWKSRefresh:        o.JWKSRefresh,
+	}
+}
+
+// Validate validates the Options and sets defaults for any omitted values
+func (o *Options) Validate() error {
+	if o.Claim == "" {
+		return ErrMissingClaim
+	}
+	o.InvalidTokenAction = strings.ToLower(o.InvalidTokenAction)
+	switch o.InvalidTokenAction {
+	case "":
+		o.InvalidTokenAction = InvalidTokenActionBypass
+	case InvalidTokenActionBypass, InvalidTokenActionReject:
+	default:
+		return ErrInvalidTokenAction
+	}
+	if o.Key != "" && o.JWKSURL != "" {
+		return ErrKeyWithJWKS
+	}
+	if o.Key == "" && o.JWKSURL == "" {
+		return ErrMissingKey
+	}
+	if o.JWKSURL != "" {
+		u, err := url.Parse(o.JWKSURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return ErrInvalidJWKSURL
+		}
+	}
+	if o.JWKSRefreshMS <= 0 {
+		o.JWKSRefreshMS = DefaultJWKSRefreshMS
+	}
+	o.JWKSRefresh = time.Duration(o.JWKSRefreshMS) * time.Millisecond
+	o.PublicKey = nil
+	if strings.HasPrefix(strings.TrimSpace(o.Key), "-----BEGIN") {
+		k, err := parsePublicKey([]byte(o.Key))
+		if err != nil {
+			return err
+		}
+		o.PublicKey = k
+	}
+	return nil
+}
+
+// parsePublicKey returns the RSA or ECDSA public key in the PEM-encoded block
+func parsePublicKey(b []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, ErrInvalidPublicKey
+	}
+	var k interface{}
+	var err error
+	switch block.Type {
+	case "CERTIFICATE":
+		var c *x509.Certificate
+		if c, err = x509.ParseCertificate(block.Bytes); err == nil {
+			k = c.PublicKey
+		}
+	case "RSA PUBLIC KEY":
+		k, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		k, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, ErrInvalidPublicKey
+	}
+	switch k.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+		return k, nil
+	}
+	return nil, ErrInvalidPublicKey
+}
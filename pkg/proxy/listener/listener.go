@@ -32,7 +32,10 @@ import (
 	"github.com/trickstercache/trickster/v2/pkg/proxy/errors"
 	ph "github.com/trickstercache/trickster/v2/pkg/proxy/handlers"
 	sw "github.com/trickstercache/trickster/v2/pkg/proxy/tls"
+	to "github.com/trickstercache/trickster/v2/pkg/proxy/tls/options"
 
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 	"golang.org/x/net/netutil"
 )
 
@@ -46,6 +49,30 @@ type Listener struct {
 	exitOnError  bool
 }
 
+// Timeouts are the connection-level timeouts applied to a listener's http.Server.
+// A zero value disables the corresponding timeout
+type Timeouts struct {
+	// ReadHeader is the maximum duration for reading a request's headers
+	ReadHeader time.Duration
+	// Read is the maximum duration for reading an entire request, including the body
+	Read time.Duration
+	// Write is the maximum duration before timing out writes of a response
+	Write time.Duration
+	// Idle is the maximum duration to wait for the next request on a keep-alive connection
+	Idle time.Duration
+}
+
+// apply sets the Timeouts on the provided http.Server
+func (t *Timeouts) apply(svr *http.Server) {
+	if t == nil {
+		return
+	}
+	svr.ReadHeaderTimeout = t.ReadHeader
+	svr.ReadTimeout = t.Read
+	svr.WriteTimeout = t.Write
+	svr.IdleTimeout = t.Idle
+}
+
 type observedConnection struct {
 	*net.TCPConn
 }
@@ -162,10 +189,14 @@ func (lg *ListenerGroup) Get(name string) *Listener {
 	return nil
 }
 
-// StartListener starts a new HTTP listener and adds it to the listener group
+// StartListener starts a new HTTP listener and adds it to the listener group. When enableH2C
+// is true, a non-TLS listener also serves prior-knowledge HTTP/2 cleartext (h2c) requests.
+// Any provided timeouts are applied to the listener's http.Server. When keyPairs lists the
+// files from which each of the tlsConfig's Certificates was loaded, certificates rotated
+// in place on disk are reloaded without restarting the listener
 func (lg *ListenerGroup) StartListener(listenerName, address string, port int, connectionsLimit int,
-	tlsConfig *tls.Config, router http.Handler, wg *sync.WaitGroup, tracers tracing.Tracers,
-	f func(), drainTimeout time.Duration, logger interface{}) error {
+	tlsConfig *tls.Config, keyPairs []to.KeyPair, enableH2C bool, timeouts *Timeouts, router http.Handler, wg *sync.WaitGroup,
+	tracers tracing.Tracers, f func(), drainTimeout time.Duration, logger interface{}) error {
 	if wg != nil {
 		defer wg.Done()
 	}
@@ -173,6 +204,7 @@ func (lg *ListenerGroup) StartListener(listenerName, address string, port int, c
 	if tlsConfig != nil && len(tlsConfig.Certificates) > 0 {
 		l.tlsConfig = tlsConfig
 		l.tlsSwapper = sw.NewSwapper(tlsConfig.Certificates)
+		l.tlsSwapper.SetKeyPairs(keyPairs)
 		// Replace the normal GetCertificate function in the TLS config with lg.tlsSwapper's,
 		// so users swap certs in the config later without restarting the entire process
 		tlsConfig.GetCertificate = l.tlsSwapper.GetCert
@@ -204,6 +236,7 @@ func (lg *ListenerGroup) StartListener(listenerName, address string, port int, c
 			Handler:   l.routeSwapper,
 			TLSConfig: tlsConfig,
 		}
+		timeouts.apply(svr)
 		l.server = svr
 		err = svr.Serve(l)
 		if err != nil {
@@ -216,9 +249,14 @@ func (lg *ListenerGroup) StartListener(listenerName, address string, port int, c
 		return err
 	}
 
+	var h http.Handler = l.routeSwapper
+	if enableH2C {
+		h = h2c.NewHandler(h, &http2.Server{})
+	}
 	svr := &http.Server{
-		Handler: l.routeSwapper,
+		Handler: h,
 	}
+	timeouts.apply(svr)
 	l.server = svr
 	err = svr.Serve(l)
 	if err != nil {
@@ -250,7 +288,7 @@ func (lg *ListenerGroup) StartListenerRouter(listenerName, address string, port
 	router := http.NewServeMux()
 	router.Handle(path, handler)
 	return lg.StartListener(listenerName, address, port, connectionsLimit,
-		tlsConfig, router, wg, tracers, f, drainTimeout, logger)
+		tlsConfig, nil, false, nil, router, wg, tracers, f, drainTimeout, logger)
 }
 
 // DrainAndClose drains and closes the named listener
@@ -277,6 +315,42 @@ func (lg *ListenerGroup) DrainAndClose(listenerName string, drainWait time.Durat
 	return errors.ErrNoSuchListener
 }
 
+// Shutdown gracefully shuts down every listener in the group. Each listener stops accepting
+// new connections, and in-flight requests are permitted up to drainTimeout to complete.
+// Any listener still serving requests at the deadline is forcibly closed, and
+// context.DeadlineExceeded is returned
+func (lg *ListenerGroup) Shutdown(drainTimeout time.Duration) error {
+	lg.listenersLock.Lock()
+	members := lg.members
+	lg.members = make(map[string]*Listener)
+	lg.listenersLock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+
+	var err error
+	var mtx sync.Mutex
+	var wg sync.WaitGroup
+	for _, l := range members {
+		if l == nil || l.server == nil {
+			continue
+		}
+		l.exitOnError = false
+		wg.Add(1)
+		go func(svr *http.Server) {
+			defer wg.Done()
+			if e := svr.Shutdown(ctx); e != nil {
+				svr.Close()
+				mtx.Lock()
+				err = e
+				mtx.Unlock()
+			}
+		}(l.server)
+	}
+	wg.Wait()
+	return err
+}
+
 // UpdateFrontendRouters will swap out the routers across the named Listeners with the provided ones
 func (lg *ListenerGroup) UpdateFrontendRouters(mainRouter http.Handler, adminRouter http.Handler) {
 	lg.listenersLock.Lock()
@@ -38,6 +38,8 @@ import (
 	"github.com/trickstercache/trickster/v2/pkg/router"
 	testutil "github.com/trickstercache/trickster/v2/pkg/testutil"
 	tlstest "github.com/trickstercache/trickster/v2/pkg/testutil/tls"
+
+	"golang.org/x/net/http2"
 )
 
 func testListener() net.Listener {
@@ -61,7 +63,7 @@ func TestListeners(t *testing.T) {
 		}
 
 		err = testLG.StartListener("httpListener",
-			"", 0, 20, tc, http.NewServeMux(), wg, trs, nil, 0, tl.ConsoleLogger("info"))
+			"", 0, 20, tc, nil, false, nil, http.NewServeMux(), wg, trs, nil, 0, tl.ConsoleLogger("info"))
 	}()
 
 	time.Sleep(time.Millisecond * 300)
@@ -88,7 +90,7 @@ func TestListeners(t *testing.T) {
 
 	wg.Add(1)
 	err = testLG.StartListener("testBadPort",
-		"", -31, 20, nil, http.NewServeMux(), wg, trs, nil, 0, tl.ConsoleLogger("info"))
+		"", -31, 20, nil, nil, false, nil, http.NewServeMux(), wg, trs, nil, 0, tl.ConsoleLogger("info"))
 	if err == nil {
 		t.Error("expected invalid port error")
 	}
@@ -119,7 +121,7 @@ func TestListenerAccept(t *testing.T) {
 	var err error
 	go func() {
 		err = testLG.StartListener("httpListener",
-			"", 0, 20, nil, http.NewServeMux(), nil, nil, nil, 0, tl.ConsoleLogger("info"))
+			"", 0, 20, nil, nil, false, nil, http.NewServeMux(), nil, nil, nil, 0, tl.ConsoleLogger("info"))
 	}()
 	time.Sleep(time.Millisecond * 500)
 	if err != nil {
@@ -134,6 +136,98 @@ func TestListenerAccept(t *testing.T) {
 	l.Close()
 }
 
+func TestListenerTimeouts(t *testing.T) {
+	testLG := NewListenerGroup()
+	var err error
+	timeouts := &Timeouts{ReadHeader: 100 * time.Millisecond, Read: time.Second,
+		Idle: 2 * time.Second}
+	go func() {
+		err = testLG.StartListener("timeoutListener",
+			"", 0, 20, nil, nil, false, timeouts, http.NewServeMux(), nil, nil, nil, 0,
+			tl.ConsoleLogger("info"))
+	}()
+	time.Sleep(time.Millisecond * 500)
+	if err != nil {
+		t.Error(err)
+	}
+	l := testLG.Get("timeoutListener")
+	defer l.Close()
+
+	svr := l.server
+	if svr.ReadHeaderTimeout != timeouts.ReadHeader || svr.ReadTimeout != timeouts.Read ||
+		svr.WriteTimeout != 0 || svr.IdleTimeout != timeouts.Idle {
+		t.Errorf("expected timeouts %v got %v %v %v %v", timeouts, svr.ReadHeaderTimeout,
+			svr.ReadTimeout, svr.WriteTimeout, svr.IdleTimeout)
+	}
+
+	// a client that never finishes sending its headers is disconnected
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("GET / HTTP/1.1\r\nHost: trickster\r\n"))
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	start := time.Now()
+	_, err = io.ReadAll(conn)
+	if err != nil {
+		t.Error(err)
+	}
+	if d := time.Since(start); d > 4*time.Second {
+		t.Errorf("expected connection to be closed after header timeout, took %s", d)
+	}
+}
+
+func TestListenerH2C(t *testing.T) {
+	testLG := NewListenerGroup()
+	var err error
+	r := http.NewServeMux()
+	r.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	})
+	go func() {
+		err = testLG.StartListener("h2cListener",
+			"", 0, 20, nil, nil, true, nil, r, nil, nil, nil, 0, tl.ConsoleLogger("info"))
+	}()
+	time.Sleep(time.Millisecond * 500)
+	if err != nil {
+		t.Error(err)
+	}
+	l := testLG.Get("h2cListener")
+	defer l.Close()
+	u := "http://" + l.Addr().String() + "/"
+
+	// prior-knowledge h2c request
+	c := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string,
+			_ *tls.Config) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}}
+	resp, err := c.Get(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.ProtoMajor != 2 || string(b) != "HTTP/2.0" {
+		t.Errorf("expected %s got %s", "HTTP/2.0", string(b))
+	}
+
+	// HTTP/1.1 request on the same port
+	resp, err = http.Get(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(b) != "HTTP/1.1" {
+		t.Errorf("expected %s got %s", "HTTP/1.1", string(b))
+	}
+}
+
 func TestNewListenerTLS(t *testing.T) {
 
 	c := config.NewConfig()
@@ -301,6 +395,91 @@ func TestDrainAndClose(t *testing.T) {
 	}
 }
 
+func startSlowListener(t *testing.T, lg *ListenerGroup, delay time.Duration) string {
+	r := http.NewServeMux()
+	r.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		w.Write([]byte("done"))
+	})
+	go lg.StartListener("slowListener",
+		"", 0, 20, nil, nil, false, nil, r, nil, nil, nil, 0, tl.ConsoleLogger("info"))
+	time.Sleep(time.Millisecond * 500)
+	l := lg.Get("slowListener")
+	if l == nil {
+		t.Fatal("expected non-nil listener")
+	}
+	return "http://" + l.Addr().String() + "/"
+}
+
+func TestShutdown(t *testing.T) {
+	testLG := NewListenerGroup()
+	u := startSlowListener(t, testLG, 500*time.Millisecond)
+
+	type result struct {
+		body string
+		err  error
+	}
+	// a dedicated client, since other tests set a short http.DefaultClient timeout
+	c := &http.Client{}
+	ch := make(chan result, 1)
+	go func() {
+		resp, err := c.Get(u)
+		if err != nil {
+			ch <- result{err: err}
+			return
+		}
+		b, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		ch <- result{body: string(b), err: err}
+	}()
+	// allow the slow request to get in flight before shutting down
+	time.Sleep(time.Millisecond * 100)
+
+	err := testLG.Shutdown(5 * time.Second)
+	if err != nil {
+		t.Error(err)
+	}
+	res := <-ch
+	if res.err != nil {
+		t.Fatal(res.err)
+	}
+	if res.body != "done" {
+		t.Errorf("expected %s got %s", "done", res.body)
+	}
+	if testLG.Get("slowListener") != nil {
+		t.Error("expected listener to be removed from the group")
+	}
+	// the listener no longer accepts new connections
+	if _, err = c.Get(u); err == nil {
+		t.Error("expected connection error after shutdown")
+	}
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	testLG := NewListenerGroup()
+	u := startSlowListener(t, testLG, 2*time.Second)
+
+	c := &http.Client{}
+	ch := make(chan error, 1)
+	go func() {
+		resp, err := c.Get(u)
+		if err == nil {
+			_, err = io.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+		ch <- err
+	}()
+	time.Sleep(time.Millisecond * 100)
+
+	err := testLG.Shutdown(100 * time.Millisecond)
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected %v got %v", context.DeadlineExceeded, err)
+	}
+	if err = <-ch; err == nil {
+		t.Error("expected in-flight request to be cut off")
+	}
+}
+
 func TestUpdateRouters(t *testing.T) {
 	testRouter := http.NotFoundHandler()
 	l := &Listener{
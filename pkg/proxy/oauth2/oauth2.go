@@ -0,0 +1,213 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package oauth2 provides an http.RoundTripper that authenticates requests to a
+// backend's origin with a bearer token obtained by the OAuth2 client credentials flow
+package oauth2
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+	oo "github.com/trickstercache/trickster/v2/pkg/proxy/oauth2/options"
+)
+
+// refreshWindow is how long before its expiration that a token is refreshed. Tokens
+// with a shorter lifetime are refreshed once half of their lifetime has elapsed
+const refreshWindow = 30 * time.Second
+
+// errMissingAccessToken is an error for a token response without an access_token
+var errMissingAccessToken = errors.New("token response did not include an access_token")
+
+// Token is a bearer token issued by the token endpoint
+type Token struct {
+	AccessToken string
+	// RefreshAt is when the token should be replaced, and is zero for tokens
+	// without an expiration
+	RefreshAt time.Time
+}
+
+// TokenSource fetches bearer tokens from a token endpoint using the client credentials
+// grant, and caches each token until shortly before it expires
+type TokenSource struct {
+	options *oo.Options
+	client  *http.Client
+	now     func() time.Time
+
+	token Token
+	mtx   sync.Mutex
+}
+
+// NewTokenSource returns a new TokenSource for the provided Options
+func NewTokenSource(o *oo.Options) *TokenSource {
+	return &TokenSource{options: o, client: &http.Client{Timeout: o.TokenTimeout},
+		now: time.Now}
+}
+
+// Token returns the cached token, or a new token from the token endpoint when
+// there is no cached token or it is due to be refreshed
+func (ts *TokenSource) Token(ctx context.Context) (string, error) {
+	ts.mtx.Lock()
+	defer ts.mtx.Unlock()
+	if ts.token.AccessToken != "" &&
+		(ts.token.RefreshAt.IsZero() || ts.now().Before(ts.token.RefreshAt)) {
+		return ts.token.AccessToken, nil
+	}
+	t, err := ts.fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	ts.token = t
+	return t.AccessToken, nil
+}
+
+// Invalidate discards the cached token if it is the provided (rejected) access token,
+// so that the next call to Token fetches a new one. When the cached token has already
+// been replaced by another request, it is retained
+func (ts *TokenSource) Invalidate(accessToken string) {
+	ts.mtx.Lock()
+	if ts.token.AccessToken == accessToken {
+		ts.token = Token{}
+	}
+	ts.mtx.Unlock()
+}
+
+// fetch requests a new token from the token endpoint. The client credentials are
+// sent using HTTP Basic authentication, per RFC 6749 section 2.3.1
+func (ts *TokenSource) fetch(ctx context.Context) (Token, error) {
+	v := url.Values{"grant_type": {"client_credentials"}}
+	if len(ts.options.Scopes) > 0 {
+		v.Set("scope", strings.Join(ts.options.Scopes, " "))
+	}
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, ts.options.TokenURL,
+		strings.NewReader(v.Encode()))
+	if err != nil {
+		return Token{}, err
+	}
+	r.Header.Set(headers.NameContentType, headers.ValueXFormURLEncoded)
+	r.Header.Set(headers.NameAccept, headers.ValueApplicationJSON)
+	r.SetBasicAuth(url.QueryEscape(ts.options.ClientID), url.QueryEscape(ts.options.ClientSecret))
+	start := ts.now()
+	resp, err := ts.client.Do(r)
+	if err != nil {
+		return Token{}, fmt.Errorf("error reaching oauth2 token endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Token{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Token{}, fmt.Errorf("unexpected status code [%d] from oauth2 token endpoint",
+			resp.StatusCode)
+	}
+	var tr struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err = json.Unmarshal(b, &tr); err != nil {
+		return Token{}, fmt.Errorf("invalid oauth2 token response: %w", err)
+	}
+	if tr.AccessToken == "" {
+		return Token{}, errMissingAccessToken
+	}
+	t := Token{AccessToken: tr.AccessToken}
+	if tr.ExpiresIn > 0 {
+		lifetime := time.Duration(tr.ExpiresIn) * time.Second
+		window := refreshWindow
+		if window > lifetime/2 {
+			window = lifetime / 2
+		}
+		t.RefreshAt = start.Add(lifetime - window)
+	}
+	return t, nil
+}
+
+// Transport is an http.RoundTripper that sets an 'Authorization: Bearer' header on each
+// request before passing it to the wrapped RoundTripper. When the origin responds with
+// a 401, the token is refreshed and the request is retried once. Since the header is
+// added by the transport, it is never considered in the derivation of cache keys
+type Transport struct {
+	tokens    *TokenSource
+	transport http.RoundTripper
+}
+
+// New returns a new Transport for the provided Options, which passes authenticated
+// requests to the next RoundTripper
+func New(o *oo.Options, next http.RoundTripper) *Transport {
+	return NewWithTokenSource(NewTokenSource(o), next)
+}
+
+// NewWithTokenSource returns a new Transport that authenticates requests with tokens
+// from the provided TokenSource
+func NewWithTokenSource(ts *TokenSource, next http.RoundTripper) *Transport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Transport{tokens: ts, transport: next}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	// the body is buffered so that it can be resent when the request is retried
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	token, resp, err := t.roundTrip(r, body)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	// the origin rejected the token, so a new one is fetched and the request is retried
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	t.tokens.Invalidate(token)
+	_, resp, err = t.roundTrip(r, body)
+	return resp, err
+}
+
+// roundTrip sends a copy of the request with the current token, and returns the
+// token along with the response
+func (t *Transport) roundTrip(r *http.Request, body []byte) (string, *http.Response, error) {
+	token, err := t.tokens.Token(r.Context())
+	if err != nil {
+		return "", nil, err
+	}
+	// per the http.RoundTripper contract, the inbound request is not modified
+	r2 := r.Clone(r.Context())
+	if body != nil {
+		r2.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	r2.Header.Set(headers.NameAuthorization, "Bearer "+token)
+	resp, err := t.transport.RoundTrip(r2)
+	return token, resp, err
+}
@@ -0,0 +1,202 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package oauth2
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+	oo "github.com/trickstercache/trickster/v2/pkg/proxy/oauth2/options"
+)
+
+// newStubTokenServer returns a token endpoint that issues tokens named token-1,
+// token-2, etc., each valid for expiresIn seconds
+func newStubTokenServer(expiresIn int, issued *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != "trickster" || secret != "s3cr3t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		r.ParseForm()
+		if r.PostForm.Get("grant_type") != "client_credentials" ||
+			r.PostForm.Get("scope") != "metrics.read metrics.write" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		n := atomic.AddInt32(issued, 1)
+		w.Header().Set(headers.NameContentType, headers.ValueApplicationJSON)
+		w.Write([]byte(`{"access_token":"token-` + strconv.Itoa(int(n)) +
+			`","token_type":"Bearer","expires_in":` + strconv.Itoa(expiresIn) + `}`))
+	}))
+}
+
+func newTestOptions(tokenURL string) *oo.Options {
+	o := &oo.Options{TokenURL: tokenURL, ClientID: "trickster", ClientSecret: "s3cr3t",
+		Scopes: []string{"metrics.read", "metrics.write"}}
+	o.Validate()
+	return o
+}
+
+func TestRefreshOnExpiry(t *testing.T) {
+
+	var issued int32
+	tokenServer := newStubTokenServer(300, &issued)
+	defer tokenServer.Close()
+
+	var received string
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get(headers.NameAuthorization)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer origin.Close()
+
+	now := time.Now()
+	ts := NewTokenSource(newTestOptions(tokenServer.URL))
+	ts.now = func() time.Time { return now }
+	c := &http.Client{Transport: NewWithTokenSource(ts, nil)}
+
+	tests := []struct {
+		advance  time.Duration
+		expected string
+	}{
+		{0, "Bearer token-1"},
+		// the cached token is used until the refresh window before its expiration
+		{4 * time.Minute, "Bearer token-1"},
+		{30 * time.Second, "Bearer token-2"},
+		{time.Second, "Bearer token-2"},
+	}
+
+	for i, test := range tests {
+		now = now.Add(test.advance)
+		r, _ := http.NewRequest(http.MethodGet, origin.URL+"/api/v1/query", nil)
+		resp, err := c.Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if received != test.expected {
+			t.Errorf("test %d expected %s got %s", i, test.expected, received)
+		}
+		if v := r.Header.Get(headers.NameAuthorization); v != "" {
+			t.Errorf("test %d expected the inbound request to be unmodified got %s", i, v)
+		}
+	}
+
+	if issued != 2 {
+		t.Errorf("expected %d got %d", 2, issued)
+	}
+}
+
+func TestRefreshOn401(t *testing.T) {
+
+	var issued int32
+	tokenServer := newStubTokenServer(0, &issued)
+	defer tokenServer.Close()
+
+	// the origin accepts only the most recently issued token
+	var requests, rejectAll int32
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		expected := "Bearer token-" + strconv.Itoa(int(atomic.LoadInt32(&issued)))
+		if r.Header.Get(headers.NameAuthorization) != expected ||
+			atomic.LoadInt32(&rejectAll) == 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+		w.Write(b)
+	}))
+	defer origin.Close()
+
+	c := &http.Client{Transport: New(newTestOptions(tokenServer.URL), nil)}
+	const body = "query=up"
+
+	do := func() *http.Response {
+		r, _ := http.NewRequest(http.MethodPost, origin.URL+"/api/v1/query",
+			strings.NewReader(body))
+		resp, err := c.Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp := do()
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || issued != 1 || requests != 1 {
+		t.Errorf("expected %d %d %d got %d %d %d", http.StatusOK, 1, 1,
+			resp.StatusCode, issued, requests)
+	}
+
+	// the origin revokes token-1, so the next request is refreshed and retried once,
+	// with its body intact
+	atomic.AddInt32(&issued, 1)
+	resp = do()
+	b, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(b) != body || issued != 3 || requests != 3 {
+		t.Errorf("expected %d %s %d %d got %d %s %d %d", http.StatusOK, body, 3, 3,
+			resp.StatusCode, string(b), issued, requests)
+	}
+
+	// a 401 for the refreshed token is returned to the caller without further retries
+	atomic.StoreInt32(&rejectAll, 1)
+	resp = do()
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized || issued != 4 || requests != 5 {
+		t.Errorf("expected %d %d %d got %d %d %d", http.StatusUnauthorized, 4, 5,
+			resp.StatusCode, issued, requests)
+	}
+}
+
+func TestTokenErrors(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/invalid":
+			w.Write([]byte("{"))
+		case "/empty":
+			w.Write([]byte(`{"token_type":"Bearer"}`))
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer ts.Close()
+
+	for _, p := range []string{"/invalid", "/empty", "/denied"} {
+		_, err := NewTokenSource(newTestOptions(ts.URL + p)).Token(context.Background())
+		if err == nil {
+			t.Errorf("expected error for %s", p)
+		}
+	}
+
+	c := &http.Client{Transport: New(newTestOptions(ts.URL+"/denied"), nil)}
+	_, err := c.Get(ts.URL)
+	if err == nil {
+		t.Error("expected error for failed token request")
+	}
+}
@@ -0,0 +1,88 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package options provides the configuration of OAuth2 client credentials
+// authentication for requests to a backend's origin
+package options
+
+import (
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/util/copiers"
+)
+
+// DefaultTokenTimeoutMS is the default timeout for requests to the token endpoint
+const DefaultTokenTimeoutMS = 10000
+
+// ErrInvalidTokenURL is an error for a missing or invalid 'token_url'
+var ErrInvalidTokenURL = errors.New("oauth2 'token_url' must be an absolute http or https url")
+
+// ErrMissingClientID is an error for when no 'client_id' is configured
+var ErrMissingClientID = errors.New("oauth2 'client_id' is required")
+
+// ErrInvalidTokenTimeout is an error for a negative 'token_timeout_ms'
+var ErrInvalidTokenTimeout = errors.New("oauth2 'token_timeout_ms' must be >= 0")
+
+// Options stores the configuration of the OAuth2 client credentials flow used to
+// obtain the bearer token sent with upstream requests
+type Options struct {
+	// TokenURL is the URL of the authorization server's token endpoint
+	TokenURL string `yaml:"token_url,omitempty"`
+	// ClientID is the client identifier
+	ClientID string `yaml:"client_id,omitempty"`
+	// ClientSecret is the client secret
+	ClientSecret string `yaml:"client_secret,omitempty"`
+	// Scopes is the optional list of scopes requested for the token
+	Scopes []string `yaml:"scopes,omitempty"`
+	// TokenTimeoutMS is the timeout for requests to the token endpoint
+	TokenTimeoutMS int `yaml:"token_timeout_ms,omitempty"`
+
+	// TokenTimeout is the time.Duration representation of TokenTimeoutMS
+	TokenTimeout time.Duration `yaml:"-"`
+}
+
+// Clone returns an exact copy of the subject Options
+func (o *Options) Clone() *Options {
+	return &Options{
+		TokenURL:       o.TokenURL,
+		ClientID:       o.ClientID,
+		ClientSecret:   o.ClientSecret,
+		Scopes:         copiers.CopyStrings(o.Scopes),
+		TokenTimeoutMS: o.TokenTimeoutMS,
+		TokenTimeout:   o.TokenTimeout,
+	}
+}
+
+// Validate returns an error if the Options are invalid, and sets the TokenTimeout
+func (o *Options) Validate() error {
+	u, err := url.Parse(o.TokenURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidTokenURL
+	}
+	if o.ClientID == "" {
+		return ErrMissingClientID
+	}
+	if o.TokenTimeoutMS < 0 {
+		return ErrInvalidTokenTimeout
+	}
+	if o.TokenTimeoutMS == 0 {
+		o.TokenTimeoutMS = DefaultTokenTimeoutMS
+	}
+	o.TokenTimeout = time.Duration(o.TokenTimeoutMS) * time.Millisecond
+	return nil
+}
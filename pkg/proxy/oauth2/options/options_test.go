@@ -0,0 +1,61 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+
+	tests := []struct {
+		o        *Options
+		expected error
+	}{
+		{&Options{ClientID: "trickster"}, ErrInvalidTokenURL},
+		{&Options{TokenURL: "/oauth2/token", ClientID: "trickster"}, ErrInvalidTokenURL},
+		{&Options{TokenURL: "ftp://auth.example.com/token", ClientID: "trickster"}, ErrInvalidTokenURL},
+		{&Options{TokenURL: "https://auth.example.com/token"}, ErrMissingClientID},
+		{&Options{TokenURL: "https://auth.example.com/token", ClientID: "trickster",
+			TokenTimeoutMS: -1}, ErrInvalidTokenTimeout},
+		{&Options{TokenURL: "https://auth.example.com/token", ClientID: "trickster"}, nil},
+	}
+
+	for i, test := range tests {
+		if err := test.o.Validate(); err != test.expected {
+			t.Errorf("test %d expected %v got %v", i, test.expected, err)
+		}
+	}
+
+	o := &Options{TokenURL: "https://auth.example.com/token", ClientID: "trickster"}
+	o.Validate()
+	if o.TokenTimeout != DefaultTokenTimeoutMS*time.Millisecond {
+		t.Errorf("expected %d got %d", DefaultTokenTimeoutMS*time.Millisecond, o.TokenTimeout)
+	}
+}
+
+func TestClone(t *testing.T) {
+	o := &Options{TokenURL: "https://auth.example.com/token", ClientID: "trickster",
+		ClientSecret: "secret", Scopes: []string{"read", "write"}, TokenTimeoutMS: 500}
+	o2 := o.Clone()
+	if o2.TokenURL != o.TokenURL || o2.ClientID != o.ClientID ||
+		o2.ClientSecret != o.ClientSecret || len(o2.Scopes) != 2 || o2.Scopes[1] != "write" ||
+		o2.TokenTimeoutMS != o.TokenTimeoutMS {
+		t.Errorf("expected %v got %v", o, o2)
+	}
+}
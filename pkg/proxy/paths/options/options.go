@@ -20,16 +20,22 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"strings"
 
 	"github.com/trickstercache/trickster/v2/pkg/cache/key"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/forwarding"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/jwt"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/methods"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/paths/matching"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/ratelimit"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/request/rewriter"
 	"github.com/trickstercache/trickster/v2/pkg/util/copiers"
 	strutil "github.com/trickstercache/trickster/v2/pkg/util/strings"
 	"github.com/trickstercache/trickster/v2/pkg/util/yamlx"
+
+	"golang.org/x/net/http/httpguts"
 )
 
 // Options defines a URL Path that is associated with an HTTP Handler
@@ -47,15 +53,31 @@ type Options struct {
 	CacheKeyParams []string `yaml:"cache_key_params,omitempty"`
 	// CacheKeyHeaders provides the list of http request headers to be included in the hash for each request's cache key
 	CacheKeyHeaders []string `yaml:"cache_key_headers,omitempty"`
+	// CacheKeyHeaderCaseInsensitive provides the list of CacheKeyHeaders whose values are lowercased
+	// before being included in the hash, so that values differing only by case share a cache key
+	CacheKeyHeaderCaseInsensitive []string `yaml:"cache_key_header_case_insensitive,omitempty"`
 	// CacheKeyFormFields provides the list of http request body fields to be included
 	// in the hash for each request's cache key
 	CacheKeyFormFields []string `yaml:"cache_key_form_fields,omitempty"`
+	// CacheKeyRedactedValues provides the list of cache key params, headers and form fields
+	// whose values are redacted when the cache key components are logged at debug level
+	CacheKeyRedactedValues []string `yaml:"cache_key_redacted_values,omitempty"`
+	// CacheKeyJWT, when set, includes a claim of the request's JWT bearer token in the
+	// cache key, in place of the Authorization header value
+	CacheKeyJWT *jwt.Options `yaml:"cache_key_jwt,omitempty"`
 	// RequestHeaders is a map of headers that will be added to requests to the upstream Origin for this path
 	RequestHeaders map[string]string `yaml:"request_headers,omitempty"`
+	// RequestHeaderInjections maps header names to templates that are evaluated against each
+	// client request for this path. These take precedence over the Backend's injections
+	RequestHeaderInjections map[string]string `yaml:"request_header_injections,omitempty"`
 	// RequestParams is a map of headers that will be added to requests to the upstream Origin for this path
 	RequestParams map[string]string `yaml:"request_params,omitempty"`
 	// ResponseHeaders is a map of http headers that will be added to responses to the downstream client
 	ResponseHeaders map[string]string `yaml:"response_headers,omitempty"`
+	// ClientCacheControl, when set, replaces the Cache-Control header of non-error responses
+	// sent to downstream clients for this path. It does not affect how Trickster caches
+	// the response, which is determined by the origin's caching headers
+	ClientCacheControl string `yaml:"client_cache_control,omitempty"`
 	// ResponseCode sets a custom response code to be sent to downstream clients for this path.
 	ResponseCode int `yaml:"response_code,omitempty"`
 	// ResponseBody sets a custom response body to be sent to the donstream client for this path.
@@ -65,8 +87,20 @@ type Options struct {
 	// ReqRewriterName is the name of a configured Rewriter that will modify the request prior to
 	// processing by the backend client
 	ReqRewriterName string `yaml:"req_rewriter_name,omitempty"`
+	// KeyHasherName is the name of a registered key.HasherFunc that will derive the cache key
+	// for requests to this path, in place of the default cache key derivation
+	KeyHasherName string `yaml:"key_hasher_name,omitempty"`
 	// NoMetrics, when set to true, disables metrics decoration for the path
 	NoMetrics bool `yaml:"no_metrics"`
+	// ServeStaleOnRevalidate, when set to true, serves stale-but-revalidatable cached objects
+	// immediately while they are revalidated in the background, even when the upstream
+	// response does not include a stale-while-revalidate directive
+	ServeStaleOnRevalidate bool `yaml:"serve_stale_on_revalidate,omitempty"`
+	// ServeStaleOnError, when set to true, serves stale cached objects in place of origin
+	// error responses, regardless of the object's stale-if-error Cache-Control directive
+	ServeStaleOnError bool `yaml:"serve_stale_on_error,omitempty"`
+	// RateLimit, when set, limits the rate at which each client may make requests to this path
+	RateLimit *ratelimit.Options `yaml:"rate_limit,omitempty"`
 
 	// Handler is the HTTP Handler represented by the Path's HandlerName
 	Handler http.Handler `yaml:"-"`
@@ -77,12 +111,18 @@ type Options struct {
 	// CollapsedForwardingType is the typed representation of CollapsedForwardingName
 	CollapsedForwardingType forwarding.CollapsedForwardingType `yaml:"-"`
 	// KeyHasher points to an optional function that hashes the cacheKey with a custom algorithm
-	// NOTE: This is used by some backends like IronDB, but is not configurable by end users.
+	// NOTE: This is set by some backends like IronDB, or by end users via KeyHasherName
 	KeyHasher key.HasherFunc `yaml:"-"`
+	// HeaderInjections is the compiled version of RequestHeaderInjections
+	HeaderInjections headers.Injections `yaml:"-"`
 	// Custom is a compiled list of any custom settings for this path from the config file
 	Custom []string `yaml:"-"`
 	// ReqRewriter is the rewriter handler as indicated by RuleName
 	ReqRewriter rewriter.RewriteInstructions
+	// RateLimiter enforces RateLimit for this path
+	RateLimiter *ratelimit.Limiter `yaml:"-"`
+	// CacheKeyJWTVerifier verifies bearer tokens and extracts the CacheKeyJWT claim
+	CacheKeyJWTVerifier *jwt.Verifier `yaml:"-"`
 
 	// HasCustomResponseBody is a boolean indicating if the response body is custom
 	// this flag allows an empty string response to be configured as a return value
@@ -124,14 +164,20 @@ func (o *Options) Clone() *Options {
 		Handler:                 o.Handler,
 		RequestHeaders:          copiers.CopyStringLookup(o.RequestHeaders),
 		RequestParams:           copiers.CopyStringLookup(o.RequestParams),
+		RequestHeaderInjections: copiers.CopyStringLookup(o.RequestHeaderInjections),
+		HeaderInjections:        o.HeaderInjections,
 		ReqRewriter:             o.ReqRewriter,
 		ReqRewriterName:         o.ReqRewriterName,
+		KeyHasherName:           o.KeyHasherName,
 		ResponseHeaders:         copiers.CopyStringLookup(o.ResponseHeaders),
 		ResponseBody:            o.ResponseBody,
+		ClientCacheControl:      o.ClientCacheControl,
 		ResponseBodyBytes:       o.ResponseBodyBytes,
 		CollapsedForwardingName: o.CollapsedForwardingName,
 		CollapsedForwardingType: o.CollapsedForwardingType,
 		NoMetrics:               o.NoMetrics,
+		ServeStaleOnRevalidate:  o.ServeStaleOnRevalidate,
+		ServeStaleOnError:       o.ServeStaleOnError,
 		HasCustomResponseBody:   o.HasCustomResponseBody,
 		Methods:                 copiers.CopyStrings(o.Methods),
 		CacheKeyParams:          copiers.CopyStrings(o.CacheKeyParams),
@@ -140,6 +186,16 @@ func (o *Options) Clone() *Options {
 		Custom:                  copiers.CopyStrings(o.Custom),
 		KeyHasher:               o.KeyHasher,
 	}
+	c.CacheKeyHeaderCaseInsensitive = copiers.CopyStrings(o.CacheKeyHeaderCaseInsensitive)
+	c.CacheKeyRedactedValues = copiers.CopyStrings(o.CacheKeyRedactedValues)
+	if o.RateLimit != nil {
+		c.RateLimit = o.RateLimit.Clone()
+	}
+	c.RateLimiter = o.RateLimiter
+	if o.CacheKeyJWT != nil {
+		c.CacheKeyJWT = o.CacheKeyJWT.Clone()
+	}
+	c.CacheKeyJWTVerifier = o.CacheKeyJWTVerifier
 	return c
 }
 
@@ -165,28 +221,50 @@ func (o *Options) Merge(o2 *Options) {
 			o.CacheKeyParams = o2.CacheKeyParams
 		case "cache_key_headers":
 			o.CacheKeyHeaders = o2.CacheKeyHeaders
+		case "cache_key_header_case_insensitive":
+			o.CacheKeyHeaderCaseInsensitive = o2.CacheKeyHeaderCaseInsensitive
 		case "cache_key_form_fields":
 			o.CacheKeyFormFields = o2.CacheKeyFormFields
+		case "cache_key_redacted_values":
+			o.CacheKeyRedactedValues = o2.CacheKeyRedactedValues
+		case "cache_key_jwt":
+			o.CacheKeyJWT = o2.CacheKeyJWT
+			o.CacheKeyJWTVerifier = o2.CacheKeyJWTVerifier
 		case "request_headers":
 			o.RequestHeaders = o2.RequestHeaders
 		case "request_params":
 			o.RequestParams = o2.RequestParams
+		case "request_header_injections":
+			o.RequestHeaderInjections = o2.RequestHeaderInjections
+			o.HeaderInjections = o2.HeaderInjections
 		case "response_headers":
 			o.ResponseHeaders = o2.ResponseHeaders
 		case "response_code":
 			o.ResponseCode = o2.ResponseCode
+		case "client_cache_control":
+			o.ClientCacheControl = o2.ClientCacheControl
 		case "response_body":
 			o.ResponseBody = o2.ResponseBody
 			o.HasCustomResponseBody = true
 			o.ResponseBodyBytes = o2.ResponseBodyBytes
 		case "no_metrics":
 			o.NoMetrics = o2.NoMetrics
+		case "serve_stale_on_revalidate":
+			o.ServeStaleOnRevalidate = o2.ServeStaleOnRevalidate
+		case "serve_stale_on_error":
+			o.ServeStaleOnError = o2.ServeStaleOnError
 		case "collapsed_forwarding":
 			o.CollapsedForwardingName = o2.CollapsedForwardingName
 			o.CollapsedForwardingType = o2.CollapsedForwardingType
 		case "req_rewriter_name":
 			o.ReqRewriterName = o2.ReqRewriterName
 			o.ReqRewriter = o2.ReqRewriter
+		case "key_hasher_name":
+			o.KeyHasherName = o2.KeyHasherName
+			o.KeyHasher = o2.KeyHasher
+		case "rate_limit":
+			o.RateLimit = o2.RateLimit
+			o.RateLimiter = o2.RateLimiter
 		}
 	}
 	o.Custom = strutil.Unique(o.Custom)
@@ -195,11 +273,18 @@ func (o *Options) Merge(o2 *Options) {
 var pathMembers = []string{"path", "match_type", "handler", "methods", "cache_key_params",
 	"cache_key_headers", "default_ttl_ms", "request_headers", "response_headers",
 	"response_headers", "response_code", "response_body", "no_metrics", "collapsed_forwarding",
-	"req_rewriter_name",
+	"req_rewriter_name", "serve_stale_on_revalidate", "request_header_injections",
+	"cache_key_header_case_insensitive", "rate_limit", "key_hasher_name",
+	"cache_key_redacted_values", "serve_stale_on_error", "cache_key_jwt",
+	"client_cache_control",
 }
 
 var errInvalidConfigMetadata = errors.New("invalid config metadata")
 
+// sensitiveCacheKeyHeaders are headers that are almost never intended to be part of a
+// cache key, since they carry per-user credentials or session state
+var sensitiveCacheKeyHeaders = []string{"Authorization", "Cookie", "Set-Cookie"}
+
 func SetDefaults(
 	backendName string,
 	metadata yamlx.KeyLookup,
@@ -219,9 +304,31 @@ func SetDefaults(
 			}
 			p.ReqRewriter = ri
 		}
+		if metadata.IsDefined("backends", backendName, "paths", k, "key_hasher_name") &&
+			p.KeyHasherName != "" {
+			f, ok := key.GetHasher(p.KeyHasherName)
+			if !ok {
+				return fmt.Errorf("invalid key_hasher_name %s in path %s of backend options %s "+
+					"(valid names: %s)", p.KeyHasherName, k, backendName,
+					strings.Join(key.HasherNames(), ", "))
+			}
+			p.KeyHasher = f
+		}
 		if len(p.Methods) == 0 {
 			p.Methods = []string{http.MethodGet, http.MethodHead}
 		}
+		if err := headers.ValidateUpdates(p.ResponseHeaders); err != nil {
+			return fmt.Errorf("invalid response_headers in path %s of backend options %s: %w",
+				k, backendName, err)
+		}
+		if p.ClientCacheControl != "" && !httpguts.ValidHeaderFieldValue(p.ClientCacheControl) {
+			return fmt.Errorf("invalid client_cache_control in path %s of backend options %s: %s",
+				k, backendName, p.ClientCacheControl)
+		}
+		if d := strutil.Duplicates(p.CacheKeyParams); len(d) > 0 {
+			return fmt.Errorf("duplicate cache_key_params in path %s of backend options %s: %s",
+				k, backendName, strings.Join(d, ", "))
+		}
 		p.Custom = make([]string, 0)
 		for _, pm := range pathMembers {
 			if metadata.IsDefined("backends", backendName, "paths", k, pm) {
@@ -241,6 +348,22 @@ func SetDefaults(
 		} else {
 			p.CollapsedForwardingType = forwarding.CFTypeBasic
 		}
+		if metadata.IsDefined("backends", backendName, "paths", k, "rate_limit") &&
+			p.RateLimit != nil {
+			if err := p.RateLimit.Validate(); err != nil {
+				return fmt.Errorf("invalid rate_limit in path %s of backend options %s: %w",
+					k, backendName, err)
+			}
+			p.RateLimiter = ratelimit.New(p.RateLimit)
+		}
+		if metadata.IsDefined("backends", backendName, "paths", k, "cache_key_jwt") &&
+			p.CacheKeyJWT != nil {
+			if err := p.CacheKeyJWT.Validate(); err != nil {
+				return fmt.Errorf("invalid cache_key_jwt in path %s of backend options %s: %w",
+					k, backendName, err)
+			}
+			p.CacheKeyJWTVerifier = jwt.New(p.CacheKeyJWT)
+		}
 		if mt, ok := matching.Names[strings.ToLower(p.MatchTypeName)]; ok {
 			p.MatchType = mt
 			p.MatchTypeName = p.MatchType.String()
@@ -252,3 +375,47 @@ func SetDefaults(
 	}
 	return nil
 }
+
+// CacheKeyWarnings returns a warning for each path that includes a sensitive
+// header (e.g., Authorization) in its CacheKeyHeaders
+func (l Lookup) CacheKeyWarnings(backendName string) []string {
+	var lw []string
+	for k, p := range l {
+		for _, h := range p.CacheKeyHeaders {
+			ch := http.CanonicalHeaderKey(h)
+			for _, sh := range sensitiveCacheKeyHeaders {
+				if ch == sh {
+					lw = append(lw, fmt.Sprintf("cache_key_headers in path %s of backend options %s "+
+						"includes %s. this header carries per-user credentials or session state, so "+
+						"including it in the cache key may fragment the cache or key entries on secrets",
+						k, backendName, sh))
+				}
+			}
+		}
+	}
+	sort.Strings(lw)
+	return lw
+}
+
+// CompileHeaderInjections compiles the request header injection templates for each path,
+// and returns a loader warning for each template that is invalid
+func (l Lookup) CompileHeaderInjections(backendName string) []string {
+	var lw []string
+	seen := make(map[*Options]bool, len(l))
+	for _, p := range l {
+		// paths may be referenced by more than one key in the lookup
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		var errs map[string]error
+		p.HeaderInjections, errs = headers.CompileInjections(p.RequestHeaderInjections)
+		for h, err := range errs {
+			lw = append(lw, fmt.Sprintf("request_header_injections header %s in path %s of "+
+				"backend options %s is invalid and will not be injected: %s",
+				h, p.Path, backendName, err.Error()))
+		}
+	}
+	sort.Strings(lw)
+	return lw
+}
@@ -18,10 +18,14 @@ package options
 
 import (
 	"net/http"
+	"strings"
 	"testing"
 
+	"github.com/trickstercache/trickster/v2/pkg/cache/key"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/forwarding"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/jwt"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/paths/matching"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/ratelimit"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/request/rewriter"
 	"github.com/trickstercache/trickster/v2/pkg/util/yamlx"
 )
@@ -62,6 +66,7 @@ func TestPathMerge(t *testing.T) {
 
 	pc2.Custom = []string{"path", "match_type", "handler", "methods",
 		"cache_key_params", "cache_key_headers", "cache_key_form_fields",
+		"cache_key_header_case_insensitive", "cache_key_redacted_values",
 		"request_headers", "request_params", "response_headers",
 		"response_code", "response_body", "no_metrics", "collapsed_forwarding"}
 
@@ -74,6 +79,8 @@ func TestPathMerge(t *testing.T) {
 	pc2.Methods = []string{http.MethodPost}
 	pc2.CacheKeyParams = []string{"params"}
 	pc2.CacheKeyHeaders = []string{"headers"}
+	pc2.CacheKeyHeaderCaseInsensitive = []string{"headers"}
+	pc2.CacheKeyRedactedValues = []string{"params"}
 	pc2.CacheKeyFormFields = []string{"fields"}
 	pc2.RequestHeaders = map[string]string{"header1": "1"}
 	pc2.RequestParams = map[string]string{"param1": "foo"}
@@ -110,6 +117,14 @@ func TestPathMerge(t *testing.T) {
 		t.Errorf("expected %d got %d", 1, len(pc.CacheKeyFormFields))
 	}
 
+	if len(pc.CacheKeyHeaderCaseInsensitive) != 1 {
+		t.Errorf("expected %d got %d", 1, len(pc.CacheKeyHeaderCaseInsensitive))
+	}
+
+	if len(pc.CacheKeyRedactedValues) != 1 {
+		t.Errorf("expected %d got %d", 1, len(pc.CacheKeyRedactedValues))
+	}
+
 	if len(pc.RequestHeaders) != 1 {
 		t.Errorf("expected %d got %d", 1, len(pc.RequestHeaders))
 	}
@@ -199,6 +214,105 @@ func TestSetDefaults(t *testing.T) {
 	if err == nil {
 		t.Error("expected error for invalid collapsed_forwarding name")
 	}
+
+	o.CollapsedForwardingName = "basic"
+	o.CacheKeyParams = []string{"query", "step", "query"}
+	err = SetDefaults("test", kl, pl, crw)
+	if err == nil {
+		t.Error("expected error for duplicate cache_key_params")
+	}
+
+	o.CacheKeyParams = nil
+	o.RateLimit = &ratelimit.Options{RequestsPerSecond: 5}
+	err = SetDefaults("test", kl, pl, crw)
+	if err != nil {
+		t.Error(err)
+	}
+	if o.RateLimiter == nil {
+		t.Error("expected rate limiter")
+	}
+	if o.RateLimit.Burst != 5 {
+		t.Errorf("expected %d got %d", 5, o.RateLimit.Burst)
+	}
+
+	o.RateLimit = &ratelimit.Options{}
+	err = SetDefaults("test", kl, pl, crw)
+	if err == nil {
+		t.Error("expected error for invalid rate_limit")
+	}
+
+	o.RateLimit = nil
+	o.KeyHasherName = key.HasherJSONBody
+	err = SetDefaults("test", kl, pl, crw)
+	if err != nil {
+		t.Error(err)
+	}
+	if o.KeyHasher == nil {
+		t.Error("expected key hasher")
+	}
+
+	o.KeyHasherName = "invalid"
+	err = SetDefaults("test", kl, pl, crw)
+	if err == nil {
+		t.Error("expected error for invalid key_hasher_name")
+	}
+
+	o.KeyHasherName = ""
+	o.CacheKeyJWT = &jwt.Options{Claim: "tenant", Key: "s3cr3t"}
+	err = SetDefaults("test", kl, pl, crw)
+	if err != nil {
+		t.Error(err)
+	}
+	if o.CacheKeyJWTVerifier == nil {
+		t.Error("expected cache key jwt verifier")
+	}
+
+	o.CacheKeyJWT = &jwt.Options{}
+	err = SetDefaults("test", kl, pl, crw)
+	if err == nil {
+		t.Error("expected error for invalid cache_key_jwt")
+	}
+
+	o.CacheKeyJWT = nil
+	o.ClientCacheControl = "public, max-age=60\r\nX-Injected: 1"
+	err = SetDefaults("test", kl, pl, crw)
+	if err == nil {
+		t.Error("expected error for invalid client_cache_control")
+	}
+
+	o.ClientCacheControl = "public, max-age=60"
+	err = SetDefaults("test", kl, pl, crw)
+	if err != nil {
+		t.Error(err)
+	}
+	if o2 := o.Clone(); o2.ClientCacheControl != o.ClientCacheControl {
+		t.Errorf("expected %s got %s", o.ClientCacheControl, o2.ClientCacheControl)
+	}
+
+	o.ClientCacheControl = ""
+	o.ResponseHeaders = map[string]string{"-X Debug": ""}
+	err = SetDefaults("test", kl, pl, crw)
+	if err == nil {
+		t.Error("expected error for invalid response_headers")
+	}
+}
+
+func TestCacheKeyWarnings(t *testing.T) {
+
+	o := New()
+	o.CacheKeyHeaders = []string{"X-Test", "authorization", "Cookie"}
+	pl := Lookup{"root": o, "other": New()}
+
+	lw := pl.CacheKeyWarnings("test")
+	if len(lw) != 2 {
+		t.Fatalf("expected %d got %d", 2, len(lw))
+	}
+	if !strings.Contains(lw[0], "Authorization") {
+		t.Errorf("expected warning for %s got %s", "Authorization", lw[0])
+	}
+	if !strings.Contains(lw[1], "Cookie") {
+		t.Errorf("expected warning for %s got %s", "Cookie", lw[1])
+	}
 }
 
 const testYAML = `
@@ -251,4 +365,10 @@ backends:
         handler: proxycache
         response_body: trickster
         collapsed_forwarding: progressive
+        rate_limit:
+          requests_per_second: 5
+        key_hasher_name: json_body
+        cache_key_jwt:
+          claim: tenant
+          key: s3cr3t
 `
@@ -24,9 +24,12 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	bo "github.com/trickstercache/trickster/v2/pkg/backends/options"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/oauth2"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/sigv4"
 )
 
 // NewHTTPClient returns an HTTP client configured to the specifications of the
@@ -77,17 +80,55 @@ func NewHTTPClient(o *bo.Options) (*http.Client, error) {
 		}
 	}
 
+	var transport http.RoundTripper = &http.Transport{
+		Dial:                (&net.Dialer{KeepAlive: time.Duration(o.KeepAliveTimeoutMS) * time.Millisecond}).Dial,
+		MaxIdleConns:        o.MaxIdleConns,
+		MaxIdleConnsPerHost: o.MaxIdleConns,
+		TLSClientConfig:     TLSConfig,
+	}
+
+	// signing and token injection are applied by the innermost RoundTripper, after any header
+	// injection, path rewriting or upstream host selection, so they cover the final request
+	if o.SigV4 != nil {
+		transport = sigv4.New(o.SigV4, transport)
+	} else if o.OAuth2 != nil {
+		transport = oauth2.New(o.OAuth2, transport)
+	}
+
 	return &http.Client{
-		Timeout: o.Timeout,
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
-		},
-		Transport: &http.Transport{
-			Dial:                (&net.Dialer{KeepAlive: time.Duration(o.KeepAliveTimeoutMS) * time.Millisecond}).Dial,
-			MaxIdleConns:        o.MaxIdleConns,
-			MaxIdleConnsPerHost: o.MaxIdleConns,
-			TLSClientConfig:     TLSConfig,
-		},
+		Timeout:       o.OriginTimeout,
+		CheckRedirect: checkRedirect(o),
+		Transport:     transport,
 	}, nil
 
 }
+
+// checkRedirect returns the client's redirect policy. Unless the backend follows
+// redirects, every redirect response is returned to the caller as-is. Otherwise,
+// redirects to an allowed host are followed up to the configured maximum, and any
+// other redirect is returned unfollowed. Since redirects are followed by the client,
+// the cache key is always derived from the original request
+func checkRedirect(o *bo.Options) func(*http.Request, []*http.Request) error {
+	if !o.FollowRedirects {
+		return func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+	allowed := make(map[string]struct{}, len(o.RedirectAllowedHosts))
+	for _, h := range o.RedirectAllowedHosts {
+		allowed[strings.ToLower(h)] = struct{}{}
+	}
+	maxRedirects := o.MaxRedirects
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) > maxRedirects {
+			return http.ErrUseLastResponse
+		}
+		if _, ok := allowed[strings.ToLower(req.URL.Host)]; ok {
+			return nil
+		}
+		if _, ok := allowed[strings.ToLower(req.URL.Hostname())]; ok {
+			return nil
+		}
+		return http.ErrUseLastResponse
+	}
+}
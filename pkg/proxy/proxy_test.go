@@ -17,9 +17,17 @@
 package proxy
 
 import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	bo "github.com/trickstercache/trickster/v2/pkg/backends/options"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/oauth2"
+	oao "github.com/trickstercache/trickster/v2/pkg/proxy/oauth2/options"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/sigv4"
+	svo "github.com/trickstercache/trickster/v2/pkg/proxy/sigv4/options"
 	tlstest "github.com/trickstercache/trickster/v2/pkg/testutil/tls"
 )
 
@@ -98,3 +106,93 @@ func TestNewHTTPClient(t *testing.T) {
 		t.Errorf("failed to find any PEM data in key input for file %s", o.TLS.ClientKeyPath)
 	}
 }
+
+func TestNewHTTPClientSigV4(t *testing.T) {
+	o := bo.New()
+	o.SigV4 = &svo.Options{Region: "us-east-1", Service: "aps"}
+	c, err := NewHTTPClient(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Transport.(*sigv4.Signer); !ok {
+		t.Errorf("expected *sigv4.Signer got %T", c.Transport)
+	}
+}
+
+func TestNewHTTPClientOAuth2(t *testing.T) {
+	o := bo.New()
+	o.OAuth2 = &oao.Options{TokenURL: "https://auth.example.com/oauth2/token",
+		ClientID: "trickster"}
+	c, err := NewHTTPClient(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Transport.(*oauth2.Transport); !ok {
+		t.Errorf("expected *oauth2.Transport got %T", c.Transport)
+	}
+}
+
+func TestNewHTTPClientRedirects(t *testing.T) {
+
+	final := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("final"))
+	}))
+	defer final.Close()
+	// the final server is reached by a different host name than the origin
+	finalURL := strings.Replace(final.URL, "127.0.0.1", "localhost", 1)
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		default:
+			http.Redirect(w, r, finalURL+r.URL.Path, http.StatusFound)
+		}
+	}))
+	defer origin.Close()
+
+	tests := []struct {
+		follow       bool
+		allowedHosts []string
+		path         string
+		expected     int
+	}{
+		// redirects are passed through by default
+		{false, []string{"localhost"}, "/", http.StatusFound},
+		// a redirect to an allowed host is followed
+		{true, []string{"localhost"}, "/", http.StatusOK},
+		{true, []string{"LOCALHOST:" + final.URL[strings.LastIndex(final.URL, ":")+1:]},
+			"/", http.StatusOK},
+		// a redirect to a disallowed host is passed through
+		{true, []string{"example.com"}, "/", http.StatusFound},
+		// redirects beyond max_redirects are passed through
+		{true, []string{"127.0.0.1"}, "/loop", http.StatusFound},
+	}
+
+	for i, test := range tests {
+		o := bo.New()
+		o.FollowRedirects = test.follow
+		o.RedirectAllowedHosts = test.allowedHosts
+		o.MaxRedirects = 2
+		c, err := NewHTTPClient(o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := c.Get(origin.URL + test.path)
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != test.expected {
+			t.Errorf("test %d expected %d got %d", i, test.expected, resp.StatusCode)
+		}
+		if test.expected == http.StatusOK && string(b) != "final" {
+			t.Errorf("test %d expected %s got %s", i, "final", string(b))
+		}
+		if test.expected == http.StatusFound && resp.Header.Get("Location") == "" {
+			t.Errorf("test %d expected a Location header", i)
+		}
+	}
+}
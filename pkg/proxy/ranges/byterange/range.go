@@ -247,6 +247,47 @@ func (brs Ranges) Clone() Ranges {
 	return brs2
 }
 
+// Coalesce returns a sorted copy of the Ranges in which overlapping and adjacent ranges
+// are merged, so that each requested byte is covered by exactly one Range. Suffix ranges
+// (e.g., bytes=-500) are only merged with each other, since their position is not known
+// without the full content length. The subject Ranges are not modified
+func (brs Ranges) Coalesce() Ranges {
+	if len(brs) < 2 {
+		return brs
+	}
+	in := brs.Clone()
+	sort.Sort(in)
+	out := make(Ranges, 0, len(in))
+	for _, br := range in {
+		if len(out) == 0 {
+			out = append(out, br)
+			continue
+		}
+		last := &out[len(out)-1]
+		switch {
+		case br.Start == -1:
+			// sorting puts any suffix ranges first; the longest suffix covers the others
+			if last.Start == -1 {
+				if br.End > last.End {
+					last.End = br.End
+				}
+				continue
+			}
+		case last.Start == -1:
+		case last.End == -1:
+			// an open-ended range covers every range that starts after it
+			continue
+		case br.Start <= last.End+1:
+			if br.End == -1 || br.End > last.End {
+				last.End = br.End
+			}
+			continue
+		}
+		out = append(out, br)
+	}
+	return out
+}
+
 // Crop a byte slice to a series of ranges.
 // This results in a byte slice of a length equal to the maximum value within brs, where all values within brs are set
 // and all others are zero.
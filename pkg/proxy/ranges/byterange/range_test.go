@@ -248,6 +248,51 @@ func TestRangesEqual(t *testing.T) {
 
 }
 
+func TestRangesCoalesce(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		in       Ranges
+		expected Ranges
+	}{
+		{"empty", Ranges{}, Ranges{}},
+		{"single", Ranges{{Start: 5, End: 10}}, Ranges{{Start: 5, End: 10}}},
+		{"overlap", Ranges{{Start: 0, End: 99}, {Start: 50, End: 149}},
+			Ranges{{Start: 0, End: 149}}},
+		{"adjacent", Ranges{{Start: 0, End: 99}, {Start: 100, End: 149}, {Start: 150, End: 199}},
+			Ranges{{Start: 0, End: 199}}},
+		{"contained", Ranges{{Start: 0, End: 99}, {Start: 10, End: 20}},
+			Ranges{{Start: 0, End: 99}}},
+		{"out of order", Ranges{{Start: 150, End: 199}, {Start: 50, End: 149}, {Start: 0, End: 99},
+			{Start: 300, End: 399}}, Ranges{{Start: 0, End: 199}, {Start: 300, End: 399}}},
+		{"gap", Ranges{{Start: 0, End: 9}, {Start: 11, End: 20}},
+			Ranges{{Start: 0, End: 9}, {Start: 11, End: 20}}},
+		{"open-ended", Ranges{{Start: 100, End: -1}, {Start: 0, End: 99}, {Start: 200, End: 299}},
+			Ranges{{Start: 0, End: -1}}},
+		{"suffixes", Ranges{{Start: -1, End: 100}, {Start: 0, End: 9}, {Start: -1, End: 500}},
+			Ranges{{Start: -1, End: 500}, {Start: 0, End: 9}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			in := test.in.Clone()
+			out := test.in.Coalesce()
+			if !out.Equal(test.expected) {
+				t.Errorf("expected %s got %s", test.expected, out)
+			}
+			if !test.in.Equal(in) {
+				t.Errorf("expected input %s to be unmodified got %s", in, test.in)
+			}
+		})
+	}
+
+	// a coalesced ParseRangeHeader result is the minimal canonical set
+	r := ParseRangeHeader("bytes=150-199,0-99,50-149").Coalesce()
+	if s := r.String(); s != "bytes=0-199" {
+		t.Errorf("expected %s got %s", "bytes=0-199", s)
+	}
+}
+
 func TestRangeSort(t *testing.T) {
 	r := Ranges{Range{Start: 10, End: 20}, Range{Start: 0, End: 8}}
 	sort.Sort(r)
@@ -0,0 +1,68 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ratelimit
+
+import (
+	"errors"
+	"math"
+)
+
+// DefaultMaxClients is the default maximum number of clients whose token buckets
+// are retained by a Limiter
+const DefaultMaxClients = 10000
+
+// ErrInvalidRequestsPerSecond returns an error for a non-positive rate limit
+var ErrInvalidRequestsPerSecond = errors.New("rate_limit requests_per_second must be greater than 0")
+
+// Options defines the per-client rate limit for a path
+type Options struct {
+	// RequestsPerSecond is the sustained rate at which each client may make requests
+	RequestsPerSecond float64 `yaml:"requests_per_second,omitempty"`
+	// Burst is the number of requests a client may make in excess of the sustained rate.
+	// The default is RequestsPerSecond, rounded up
+	Burst int `yaml:"burst,omitempty"`
+	// ClientHeader is the name of a request header whose value identifies the client,
+	// such as X-Forwarded-For. When empty, the client's remote IP address is used
+	ClientHeader string `yaml:"client_header,omitempty"`
+	// MaxClients is the maximum number of clients whose state is retained. The least
+	// recently seen clients are evicted when this limit is reached
+	MaxClients int `yaml:"max_clients,omitempty"`
+}
+
+// Clone returns an exact copy of the subject Options
+func (o *Options) Clone() *Options {
+	return &Options{
+		RequestsPerSecond: o.RequestsPerSecond,
+		Burst:             o.Burst,
+		ClientHeader:      o.ClientHeader,
+		MaxClients:        o.MaxClients,
+	}
+}
+
+// Validate validates the Options and sets defaults for any omitted values
+func (o *Options) Validate() error {
+	if o.RequestsPerSecond <= 0 {
+		return ErrInvalidRequestsPerSecond
+	}
+	if o.Burst <= 0 {
+		o.Burst = int(math.Ceil(o.RequestsPerSecond))
+	}
+	if o.MaxClients <= 0 {
+		o.MaxClients = DefaultMaxClients
+	}
+	return nil
+}
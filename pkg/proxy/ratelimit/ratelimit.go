@@ -0,0 +1,117 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package ratelimit provides per-client token bucket rate limiting
+package ratelimit
+
+import (
+	"container/list"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limiter enforces a token bucket per client. Each client's bucket holds up to
+// Burst tokens and is refilled at RequestsPerSecond; a request is allowed when a
+// token is available. Buckets are kept in LRU order and the least recently seen
+// client is evicted once MaxClients is reached.
+type Limiter struct {
+	rate       float64
+	burst      float64
+	header     string
+	maxClients int
+
+	mtx     sync.Mutex
+	clients map[string]*list.Element
+	lru     *list.List
+	now     func() time.Time
+}
+
+type bucket struct {
+	client string
+	tokens float64
+	last   time.Time
+}
+
+// New returns a new Limiter for the provided Options, which must be validated
+func New(o *Options) *Limiter {
+	return &Limiter{
+		rate:       o.RequestsPerSecond,
+		burst:      float64(o.Burst),
+		header:     o.ClientHeader,
+		maxClients: o.MaxClients,
+		clients:    make(map[string]*list.Element),
+		lru:        list.New(),
+		now:        time.Now,
+	}
+}
+
+// Allow consumes a token for the client, returning true if the request is permitted.
+// When it is not, the duration until the next token is available is also returned
+func (l *Limiter) Allow(client string) (bool, time.Duration) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	now := l.now()
+	var b *bucket
+	if e, ok := l.clients[client]; ok {
+		b = e.Value.(*bucket)
+		b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+		b.last = now
+		l.lru.MoveToFront(e)
+	} else {
+		if l.lru.Len() >= l.maxClients {
+			if e := l.lru.Back(); e != nil {
+				delete(l.clients, e.Value.(*bucket).client)
+				l.lru.Remove(e)
+			}
+		}
+		b = &bucket{client: client, tokens: l.burst, last: now}
+		l.clients[client] = l.lru.PushFront(b)
+	}
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+}
+
+// Len returns the number of clients whose state is currently retained
+func (l *Limiter) Len() int {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	return l.lru.Len()
+}
+
+// ClientKey returns the value identifying the client that made the request: the
+// first value of the Limiter's client header if configured and present, or else
+// the host portion of the request's remote address
+func (l *Limiter) ClientKey(r *http.Request) string {
+	if l.header != "" {
+		if v := r.Header.Get(l.header); v != "" {
+			if i := strings.Index(v, ","); i >= 0 {
+				v = v[:i]
+			}
+			return strings.TrimSpace(v)
+		}
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
@@ -0,0 +1,146 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ratelimit
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func testLimiter(t *testing.T, o *Options) (*Limiter, *time.Time) {
+	if err := o.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	l := New(o)
+	now := time.Unix(1700000000, 0)
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func TestOptionsValidate(t *testing.T) {
+	o := &Options{}
+	if err := o.Validate(); err != ErrInvalidRequestsPerSecond {
+		t.Errorf("expected %v got %v", ErrInvalidRequestsPerSecond, err)
+	}
+	o.RequestsPerSecond = 2.5
+	if err := o.Validate(); err != nil {
+		t.Error(err)
+	}
+	if o.Burst != 3 {
+		t.Errorf("expected %d got %d", 3, o.Burst)
+	}
+	if o.MaxClients != DefaultMaxClients {
+		t.Errorf("expected %d got %d", DefaultMaxClients, o.MaxClients)
+	}
+	o2 := o.Clone()
+	if *o2 != *o {
+		t.Error("clone mismatch")
+	}
+}
+
+func TestAllow(t *testing.T) {
+
+	l, now := testLimiter(t, &Options{RequestsPerSecond: 2, Burst: 3})
+
+	// the full burst is permitted, then the client is limited
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("a"); !ok {
+			t.Fatalf("expected request %d to be allowed", i)
+		}
+	}
+	ok, wait := l.Allow("a")
+	if ok {
+		t.Fatal("expected request to be limited")
+	}
+	if wait != 500*time.Millisecond {
+		t.Errorf("expected %s got %s", 500*time.Millisecond, wait)
+	}
+
+	// other clients are unaffected
+	if ok, _ := l.Allow("b"); !ok {
+		t.Error("expected other client to be allowed")
+	}
+
+	// the client recovers as tokens are refilled
+	*now = now.Add(500 * time.Millisecond)
+	if ok, _ := l.Allow("a"); !ok {
+		t.Error("expected request to be allowed after refill")
+	}
+	if ok, _ := l.Allow("a"); ok {
+		t.Error("expected request to be limited")
+	}
+
+	// the bucket never refills beyond the burst
+	*now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("a"); !ok {
+			t.Fatalf("expected request %d to be allowed", i)
+		}
+	}
+	if ok, _ := l.Allow("a"); ok {
+		t.Error("expected request to be limited")
+	}
+}
+
+func TestAllowEviction(t *testing.T) {
+
+	l, _ := testLimiter(t, &Options{RequestsPerSecond: 1, Burst: 1, MaxClients: 3})
+
+	for i := 0; i < 3; i++ {
+		l.Allow(strconv.Itoa(i))
+	}
+	if l.Len() != 3 {
+		t.Errorf("expected %d got %d", 3, l.Len())
+	}
+
+	// client 0 is the most recently seen, so client 1 is evicted next
+	l.Allow("0")
+	l.Allow("3")
+	if l.Len() != 3 {
+		t.Errorf("expected %d got %d", 3, l.Len())
+	}
+	if _, ok := l.clients["1"]; ok {
+		t.Error("expected least recently seen client to be evicted")
+	}
+	if ok, _ := l.Allow("0"); ok {
+		t.Error("expected retained client to remain limited")
+	}
+	if ok, _ := l.Allow("1"); !ok {
+		t.Error("expected evicted client to start with a full bucket")
+	}
+}
+
+func TestClientKey(t *testing.T) {
+
+	l, _ := testLimiter(t, &Options{RequestsPerSecond: 1})
+	r := httptest.NewRequest("GET", "http://0/", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	if k := l.ClientKey(r); k != "192.0.2.1" {
+		t.Errorf("expected %s got %s", "192.0.2.1", k)
+	}
+
+	l, _ = testLimiter(t, &Options{RequestsPerSecond: 1, ClientHeader: "X-Forwarded-For"})
+	if k := l.ClientKey(r); k != "192.0.2.1" {
+		t.Errorf("expected %s got %s", "192.0.2.1", k)
+	}
+	r.Header.Set("X-Forwarded-For", "198.51.100.7, 192.0.2.1")
+	if k := l.ClientKey(r); k != "198.51.100.7" {
+		t.Errorf("expected %s got %s", "198.51.100.7", k)
+	}
+}
@@ -27,6 +27,7 @@ import (
 	"github.com/trickstercache/trickster/v2/pkg/observability/tracing"
 	tctx "github.com/trickstercache/trickster/v2/pkg/proxy/context"
 	po "github.com/trickstercache/trickster/v2/pkg/proxy/paths/options"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/servertiming"
 	"github.com/trickstercache/trickster/v2/pkg/timeseries"
 )
 
@@ -52,6 +53,22 @@ type Resources struct {
 	TS                timeseries.Timeseries
 	TSReqestOptions   *timeseries.RequestOptions
 	Response          *http.Response
+	// CacheKey is the key the request's response is cached under, once derived
+	CacheKey string
+	// KeyOnly indicates the request should only derive its CacheKey, and must
+	// not be fulfilled from the cache or the origin
+	KeyOnly bool
+	// CacheKeyClaim is the value of the path's cache_key_jwt claim from the request's
+	// bearer token, once verified
+	CacheKeyClaim string
+	// ProxyOnly indicates the request must be proxied to the origin without reading
+	// from or writing to the cache
+	ProxyOnly bool
+	// ServerTiming records the request's timings, when the backend emits Server-Timing headers
+	ServerTiming *servertiming.Timings
+	// ResponseTooLarge indicates the upstream response body exceeded the backend's
+	// max_response_bytes while it was read, so the truncated body must not be cached
+	ResponseTooLarge bool
 }
 
 // Clone returns an exact copy of the subject Resources collection
@@ -75,6 +92,11 @@ func (r *Resources) Clone() *Resources {
 		TSTransformer:     r.TSTransformer,
 		TS:                r.TS,
 		TSReqestOptions:   r.TSReqestOptions,
+		CacheKey:          r.CacheKey,
+		KeyOnly:           r.KeyOnly,
+		CacheKeyClaim:     r.CacheKeyClaim,
+		ProxyOnly:         r.ProxyOnly,
+		ServerTiming:      r.ServerTiming,
 	}
 }
 
@@ -129,4 +151,5 @@ func (r *Resources) Merge(r2 *Resources) {
 	r.TimeRangeQuery = r2.TimeRangeQuery
 	r.Tracer = r2.Tracer
 	r.Logger = r2.Logger
+	r.ServerTiming = r2.ServerTiming
 }
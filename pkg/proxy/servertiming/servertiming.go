@@ -0,0 +1,103 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package servertiming records the durations of a request's cache lookups and origin
+// fetches, and reports them to the client in a Server-Timing response header
+package servertiming
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+)
+
+// Timings records the durations of a request's phases. A nil *Timings is valid, and
+// ignores all recorded durations, so that callers need not check whether the backend
+// emits Server-Timing headers.
+type Timings struct {
+	start       time.Time
+	mtx         sync.Mutex
+	cacheLookup time.Duration
+	originFetch time.Duration
+	hasCache    bool
+	hasOrigin   bool
+}
+
+// New returns a new Timings for a request that started now
+func New() *Timings {
+	return &Timings{start: time.Now()}
+}
+
+// AddCacheLookup adds the duration of a cache lookup to the Timings
+func (t *Timings) AddCacheLookup(d time.Duration) {
+	if t == nil {
+		return
+	}
+	t.mtx.Lock()
+	t.cacheLookup += d
+	t.hasCache = true
+	t.mtx.Unlock()
+}
+
+// AddOriginFetch adds the duration of an origin fetch, measured until the response
+// headers were received, to the Timings. Concurrent fetches are summed.
+func (t *Timings) AddOriginFetch(d time.Duration) {
+	if t == nil {
+		return
+	}
+	t.mtx.Lock()
+	t.originFetch += d
+	t.hasOrigin = true
+	t.mtx.Unlock()
+}
+
+// String returns the Server-Timing header value for the Timings, including the total
+// time elapsed since the request started
+func (t *Timings) String() string {
+	if t == nil {
+		return ""
+	}
+	total := time.Since(t.start)
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	entries := make([]string, 0, 3)
+	if t.hasCache {
+		entries = append(entries, entry("cache", "Cache Lookup", t.cacheLookup))
+	}
+	if t.hasOrigin {
+		entries = append(entries, entry("origin", "Origin Fetch", t.originFetch))
+	}
+	entries = append(entries, entry("total", "Total", total))
+	return strings.Join(entries, ", ")
+}
+
+// SetHeader sets the Server-Timing header for the Timings on h
+func (t *Timings) SetHeader(h http.Header) {
+	if t == nil || h == nil {
+		return
+	}
+	h.Set(headers.NameServerTiming, t.String())
+}
+
+// entry returns a Server-Timing metric with the duration in milliseconds
+func entry(name, desc string, d time.Duration) string {
+	return name + `;desc="` + desc + `";dur=` +
+		strconv.FormatFloat(float64(d.Microseconds())/1000, 'f', -1, 64)
+}
@@ -0,0 +1,59 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package servertiming
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+)
+
+func TestTimings(t *testing.T) {
+
+	tm := New()
+	tm.start = time.Now().Add(-50 * time.Millisecond)
+	if v := tm.String(); !strings.HasPrefix(v, `total;desc="Total";dur=`) {
+		t.Errorf("expected only a total entry got %s", v)
+	}
+
+	tm.AddCacheLookup(1500 * time.Microsecond)
+	tm.AddOriginFetch(20 * time.Millisecond)
+	tm.AddOriginFetch(5 * time.Millisecond)
+	h := http.Header{}
+	tm.SetHeader(h)
+	const expected = `cache;desc="Cache Lookup";dur=1.5, origin;desc="Origin Fetch";dur=25, total;desc="Total";dur=`
+	if v := h.Get(headers.NameServerTiming); !strings.HasPrefix(v, expected) {
+		t.Errorf("expected prefix %s got %s", expected, v)
+	}
+}
+
+func TestNilTimings(t *testing.T) {
+	var tm *Timings
+	tm.AddCacheLookup(time.Second)
+	tm.AddOriginFetch(time.Second)
+	h := http.Header{}
+	tm.SetHeader(h)
+	if v := h.Get(headers.NameServerTiming); v != "" {
+		t.Errorf("expected empty header got %s", v)
+	}
+	if v := tm.String(); v != "" {
+		t.Errorf("expected empty string got %s", v)
+	}
+}
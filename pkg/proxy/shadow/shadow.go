@@ -0,0 +1,213 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package shadow provides a Mirror that asynchronously sends a sample of requests
+// to a shadow origin, and compares its responses to those of the primary origin
+package shadow
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/checksum/fnv"
+	"github.com/trickstercache/trickster/v2/pkg/observability/metrics"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+)
+
+// Mirror sends a sample of a backend's requests to a shadow origin. Shadow responses
+// are never cached or returned to the client; they are only compared to the primary
+// origin's responses, with the outcome recorded in metrics.
+type Mirror struct {
+	name       string
+	origin     *url.URL
+	sampleRate float64
+	timeout    time.Duration
+	client     *http.Client
+	random     func() float64
+}
+
+// Result describes a response to a mirrored request
+type Result struct {
+	// StatusCode is the HTTP status code of the response
+	StatusCode int
+	// Size is the number of bytes in the response body
+	Size int64
+	// Sum is the FNV-1a hash of the response body
+	Sum uint64
+	// Encoded is true when the response body has a Content-Encoding, and so its
+	// Size and Sum are not comparable to those of an unencoded body
+	Encoded bool
+	// Duration is the time taken to receive the response
+	Duration time.Duration
+	// Err is any error encountered while sending the request or reading the response
+	Err error
+}
+
+// New returns a new Mirror for the named backend, which sends sampleRate (0 to 1) of
+// requests to the origin, abandoning any that take longer than timeout
+func New(name string, origin *url.URL, sampleRate float64, timeout time.Duration) *Mirror {
+	return &Mirror{
+		name:       name,
+		origin:     origin,
+		sampleRate: sampleRate,
+		timeout:    timeout,
+		client:     &http.Client{Timeout: timeout},
+		random:     rand.Float64,
+	}
+}
+
+// Name returns the name of the backend whose requests are mirrored
+func (m *Mirror) Name() string {
+	return m.name
+}
+
+// Sample returns true if a request should be mirrored to the shadow origin
+func (m *Mirror) Sample() bool {
+	return m.sampleRate >= 1 || (m.sampleRate > 0 && m.random() < m.sampleRate)
+}
+
+// Send asynchronously sends a copy of the request, with the provided body, to the
+// shadow origin. The returned channel receives the Result once the response is read
+func (m *Mirror) Send(r *http.Request, body []byte) <-chan Result {
+	u := *r.URL
+	u.Scheme = m.origin.Scheme
+	u.Host = m.origin.Host
+	u.Path = m.origin.Path + r.URL.Path
+	u.RawPath = ""
+	h := r.Header.Clone()
+	// let the client negotiate and decode compression, so the body is comparable
+	h.Del(headers.NameAcceptEncoding)
+	method := r.Method
+
+	ch := make(chan Result, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+		defer cancel()
+		var res Result
+		start := time.Now()
+		sr, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+		if err != nil {
+			res.Err = err
+			ch <- res
+			return
+		}
+		sr.Header = h
+		resp, err := m.client.Do(sr)
+		if err != nil {
+			res.Err = err
+			ch <- res
+			return
+		}
+		defer resp.Body.Close()
+		sum := fnv.NewInlineFNV64a()
+		res.Size, res.Err = io.Copy(&sum, resp.Body)
+		res.StatusCode = resp.StatusCode
+		res.Sum = sum.Sum64()
+		res.Duration = time.Since(start)
+		ch <- res
+	}()
+	return ch
+}
+
+// Outcome names of a comparison between primary and shadow responses
+const (
+	OutcomeMatch    = "match"
+	OutcomeMismatch = "mismatch"
+	OutcomeError    = "error"
+)
+
+// Compare records the shadow origin's latency and its divergence from the primary
+// origin's response in metrics, and returns the Outcome of the comparison
+func (m *Mirror) Compare(primary, shadow Result) string {
+	if shadow.Err != nil {
+		metrics.ProxyShadowResults.WithLabelValues(m.name, OutcomeError).Inc()
+		return OutcomeError
+	}
+	metrics.ProxyShadowRequestDuration.WithLabelValues(m.name).Observe(shadow.Duration.Seconds())
+	match := primary.StatusCode == shadow.StatusCode
+	if !primary.Encoded {
+		d := primary.Size - shadow.Size
+		if d < 0 {
+			d = -d
+		}
+		metrics.ProxyShadowSizeDivergence.WithLabelValues(m.name).Observe(float64(d))
+		match = match && primary.Sum == shadow.Sum
+	}
+	if match {
+		metrics.ProxyShadowResults.WithLabelValues(m.name, OutcomeMatch).Inc()
+		return OutcomeMatch
+	}
+	metrics.ProxyShadowResults.WithLabelValues(m.name, OutcomeMismatch).Inc()
+	return OutcomeMismatch
+}
+
+// Recorder is an http.ResponseWriter that passes writes through to the wrapped
+// ResponseWriter, while recording the Result of the primary response
+type Recorder struct {
+	http.ResponseWriter
+	statusCode int
+	size       int64
+	sum        fnv.InlineFNV64a
+	encoded    bool
+	start      time.Time
+}
+
+// NewRecorder returns a new Recorder wrapping the provided ResponseWriter
+func NewRecorder(w http.ResponseWriter) *Recorder {
+	return &Recorder{ResponseWriter: w, sum: fnv.NewInlineFNV64a(), start: time.Now()}
+}
+
+// WriteHeader implements http.ResponseWriter
+func (rw *Recorder) WriteHeader(code int) {
+	if rw.statusCode == 0 {
+		rw.statusCode = code
+		ce := rw.Header().Get(headers.NameContentEncoding)
+		rw.encoded = ce != "" && ce != "identity"
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+// Write implements http.ResponseWriter
+func (rw *Recorder) Write(b []byte) (int, error) {
+	if rw.statusCode == 0 {
+		rw.WriteHeader(http.StatusOK)
+	}
+	rw.sum.Write(b)
+	rw.size += int64(len(b))
+	return rw.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher
+func (rw *Recorder) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Result returns the Result of the recorded response
+func (rw *Recorder) Result() Result {
+	code := rw.statusCode
+	if code == 0 {
+		code = http.StatusOK
+	}
+	return Result{StatusCode: code, Size: rw.size, Sum: rw.sum.Sum64(),
+		Encoded: rw.encoded, Duration: time.Since(rw.start)}
+}
@@ -0,0 +1,143 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package shadow
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func newTestMirror(t *testing.T, h http.HandlerFunc, rate float64) (*Mirror, func()) {
+	s := httptest.NewServer(h)
+	u, err := url.Parse(s.URL + "/shadow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New("test", u, rate, 100*time.Millisecond), s.Close
+}
+
+func TestSample(t *testing.T) {
+	tests := []struct {
+		rate     float64
+		random   float64
+		expected bool
+	}{
+		{0, 0, false},
+		{1, 0.99, true},
+		{0.5, 0.25, true},
+		{0.5, 0.75, false},
+	}
+	for _, test := range tests {
+		m := New("test", &url.URL{}, test.rate, time.Second)
+		m.random = func() float64 { return test.random }
+		if v := m.Sample(); v != test.expected {
+			t.Errorf("rate %g random %g: expected %t got %t",
+				test.rate, test.random, test.expected, v)
+		}
+	}
+}
+
+func TestSend(t *testing.T) {
+	var path, ae, body string
+	m, closer := newTestMirror(t, func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		ae = r.Header.Get("Accept-Encoding")
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+		w.Write([]byte("test"))
+	}, 1)
+	defer closer()
+
+	r := httptest.NewRequest(http.MethodPost, "http://trickster/api/v1/query?q=up", nil)
+	r.Header.Set("Accept-Encoding", "br")
+	res := <-m.Send(r, []byte("payload"))
+	if res.Err != nil {
+		t.Fatal(res.Err)
+	}
+	if path != "/shadow/api/v1/query" {
+		t.Errorf("unexpected path %s", path)
+	}
+	if ae == "br" {
+		t.Error("expected client Accept-Encoding to be stripped")
+	}
+	if body != "payload" {
+		t.Errorf("unexpected body %s", body)
+	}
+
+	rec := NewRecorder(httptest.NewRecorder())
+	rec.Write([]byte("test"))
+	if v := m.Compare(rec.Result(), res); v != OutcomeMatch {
+		t.Errorf("expected %s got %s", OutcomeMatch, v)
+	}
+}
+
+func TestSendTimeout(t *testing.T) {
+	m, closer := newTestMirror(t, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+	}, 1)
+	defer closer()
+	r := httptest.NewRequest(http.MethodGet, "http://trickster/", nil)
+	res := <-m.Send(r, nil)
+	if res.Err == nil {
+		t.Error("expected timeout error")
+	}
+	if v := m.Compare(Result{StatusCode: 200}, res); v != OutcomeError {
+		t.Errorf("expected %s got %s", OutcomeError, v)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	m := New("test", &url.URL{}, 1, time.Second)
+	tests := []struct {
+		primary, shadow Result
+		expected        string
+	}{
+		{Result{StatusCode: 200, Size: 4, Sum: 1}, Result{StatusCode: 200, Size: 4, Sum: 1}, OutcomeMatch},
+		{Result{StatusCode: 200, Size: 4, Sum: 1}, Result{StatusCode: 500, Size: 4, Sum: 1}, OutcomeMismatch},
+		{Result{StatusCode: 200, Size: 4, Sum: 1}, Result{StatusCode: 200, Size: 5, Sum: 2}, OutcomeMismatch},
+		{Result{StatusCode: 200, Size: 2, Sum: 1, Encoded: true},
+			Result{StatusCode: 200, Size: 5, Sum: 2}, OutcomeMatch},
+	}
+	for i, test := range tests {
+		if v := m.Compare(test.primary, test.shadow); v != test.expected {
+			t.Errorf("test %d: expected %s got %s", i, test.expected, v)
+		}
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	w := httptest.NewRecorder()
+	rec := NewRecorder(w)
+	rec.Header().Set("Content-Encoding", "gzip")
+	rec.WriteHeader(http.StatusNotFound)
+	rec.Write([]byte("test"))
+	rec.Flush()
+	res := rec.Result()
+	if res.StatusCode != http.StatusNotFound || w.Code != http.StatusNotFound {
+		t.Errorf("unexpected status %d", res.StatusCode)
+	}
+	if res.Size != 4 || w.Body.String() != "test" {
+		t.Errorf("unexpected size %d", res.Size)
+	}
+	if !res.Encoded {
+		t.Error("expected encoded response")
+	}
+}
@@ -0,0 +1,190 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sigv4
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	so "github.com/trickstercache/trickster/v2/pkg/proxy/sigv4/options"
+)
+
+// Credentials are the AWS credentials used to sign a request
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// Expiration is when temporary credentials expire, and is zero for
+	// credentials that do not expire
+	Expiration time.Time
+}
+
+// CredentialsProvider retrieves the Credentials used to sign a request
+type CredentialsProvider interface {
+	Retrieve(context.Context) (Credentials, error)
+}
+
+// ErrMissingEnvCredentials is an error for when the environment does not provide
+// AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY
+var ErrMissingEnvCredentials = errors.New(
+	"AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are not set in the environment")
+
+// NewCredentialsProvider returns the CredentialsProvider for the Options' Credential Source
+func NewCredentialsProvider(o *so.Options) CredentialsProvider {
+	switch o.CredentialSource {
+	case so.CredentialSourceStatic:
+		return StaticCredentials{AccessKeyID: o.AccessKeyID,
+			SecretAccessKey: o.SecretAccessKey, SessionToken: o.SessionToken}
+	case so.CredentialSourceInstanceProfile:
+		return &InstanceProfileCredentials{Endpoint: DefaultIMDSEndpoint}
+	}
+	return EnvCredentials{}
+}
+
+// StaticCredentials provides a fixed set of Credentials
+type StaticCredentials Credentials
+
+// Retrieve implements CredentialsProvider
+func (c StaticCredentials) Retrieve(context.Context) (Credentials, error) {
+	return Credentials(c), nil
+}
+
+// EnvCredentials provides Credentials from the environment on each retrieval,
+// so that credentials rotated into the environment are used without a restart
+type EnvCredentials struct{}
+
+// Retrieve implements CredentialsProvider
+func (EnvCredentials) Retrieve(context.Context) (Credentials, error) {
+	c := Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if c.AccessKeyID == "" || c.SecretAccessKey == "" {
+		return Credentials{}, ErrMissingEnvCredentials
+	}
+	return c, nil
+}
+
+// DefaultIMDSEndpoint is the base URL of the EC2 Instance Metadata Service
+const DefaultIMDSEndpoint = "http://169.254.169.254"
+
+// imdsRefreshWindow is how long before their expiration that instance profile
+// credentials are refreshed
+const imdsRefreshWindow = 5 * time.Minute
+
+const (
+	imdsTokenPath       = "/latest/api/token"
+	imdsCredentialsPath = "/latest/meta-data/iam/security-credentials/"
+	imdsTokenHeader     = "X-Aws-Ec2-Metadata-Token"
+	imdsTokenTTLHeader  = "X-Aws-Ec2-Metadata-Token-Ttl-Seconds"
+)
+
+// InstanceProfileCredentials provides the Credentials of the EC2 instance's IAM role,
+// retrieved from the Instance Metadata Service (IMDSv2) and cached until shortly
+// before they expire
+type InstanceProfileCredentials struct {
+	// Endpoint is the base URL of the Instance Metadata Service
+	Endpoint string
+	// Client is the HTTP Client used to reach the Instance Metadata Service.
+	// When nil, a client with a 5s timeout is used
+	Client *http.Client
+
+	creds Credentials
+	mtx   sync.Mutex
+}
+
+// Retrieve implements CredentialsProvider
+func (p *InstanceProfileCredentials) Retrieve(ctx context.Context) (Credentials, error) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if p.creds.AccessKeyID != "" &&
+		time.Now().Add(imdsRefreshWindow).Before(p.creds.Expiration) {
+		return p.creds, nil
+	}
+	if p.Client == nil {
+		p.Client = &http.Client{Timeout: 5 * time.Second}
+	}
+	token, err := p.get(ctx, http.MethodPut, imdsTokenPath, "")
+	if err != nil {
+		return Credentials{}, err
+	}
+	role, err := p.get(ctx, http.MethodGet, imdsCredentialsPath, token)
+	if err != nil {
+		return Credentials{}, err
+	}
+	role = strings.TrimSpace(strings.SplitN(role, "\n", 2)[0])
+	if role == "" {
+		return Credentials{}, errors.New("no iam role is attached to the instance")
+	}
+	b, err := p.get(ctx, http.MethodGet, imdsCredentialsPath+role, token)
+	if err != nil {
+		return Credentials{}, err
+	}
+	var ic struct {
+		AccessKeyID     string `json:"AccessKeyId"`
+		SecretAccessKey string
+		Token           string
+		Expiration      time.Time
+	}
+	if err = json.Unmarshal([]byte(b), &ic); err != nil {
+		return Credentials{}, fmt.Errorf("invalid instance profile credentials: %w", err)
+	}
+	if ic.AccessKeyID == "" || ic.SecretAccessKey == "" {
+		return Credentials{}, errors.New("incomplete instance profile credentials")
+	}
+	p.creds = Credentials{AccessKeyID: ic.AccessKeyID, SecretAccessKey: ic.SecretAccessKey,
+		SessionToken: ic.Token, Expiration: ic.Expiration}
+	return p.creds, nil
+}
+
+// get makes a request to the Instance Metadata Service and returns the response body.
+// A PUT requests a new session token, while a GET uses the provided session token
+func (p *InstanceProfileCredentials) get(ctx context.Context, method, path,
+	token string) (string, error) {
+	r, err := http.NewRequestWithContext(ctx, method, p.Endpoint+path, nil)
+	if err != nil {
+		return "", err
+	}
+	if method == http.MethodPut {
+		r.Header.Set(imdsTokenTTLHeader, "21600")
+	} else {
+		r.Header.Set(imdsTokenHeader, token)
+	}
+	resp, err := p.Client.Do(r)
+	if err != nil {
+		return "", fmt.Errorf("error reaching instance metadata service: %w", err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code [%d] from instance metadata service for %s",
+			resp.StatusCode, path)
+	}
+	return string(b), nil
+}
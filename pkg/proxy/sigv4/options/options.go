@@ -0,0 +1,104 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package options provides the configuration of AWS Signature Version 4
+// signing for requests to a backend's origin
+package options
+
+import "errors"
+
+// Credential Sources from which the signing credentials are retrieved
+const (
+	// CredentialSourceEnv reads AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+	// AWS_SESSION_TOKEN from the environment
+	CredentialSourceEnv = "env"
+	// CredentialSourceInstanceProfile retrieves the credentials of the EC2 instance's
+	// IAM role from the Instance Metadata Service
+	CredentialSourceInstanceProfile = "instance_profile"
+	// CredentialSourceStatic uses the credentials provided in the Options
+	CredentialSourceStatic = "static"
+)
+
+// DefaultCredentialSource is the default Credential Source
+const DefaultCredentialSource = CredentialSourceEnv
+
+// ErrMissingRegion is an error for when no region is configured
+var ErrMissingRegion = errors.New("sigv4 'region' is required")
+
+// ErrMissingService is an error for when no service is configured
+var ErrMissingService = errors.New("sigv4 'service' is required")
+
+// ErrInvalidCredentialSource is an error for an unsupported 'credential_source'
+var ErrInvalidCredentialSource = errors.New(
+	"sigv4 'credential_source' must be one of env, instance_profile or static")
+
+// ErrMissingStaticCredentials is an error for when the static credential source is
+// used without an access key id and secret access key
+var ErrMissingStaticCredentials = errors.New(
+	"sigv4 'access_key_id' and 'secret_access_key' are required for static credentials")
+
+// Options stores the configuration of AWS Signature Version 4 request signing
+type Options struct {
+	// Region is the AWS region of the origin (e.g., us-east-1)
+	Region string `yaml:"region,omitempty"`
+	// Service is the signing name of the AWS service (e.g., aps for Amazon Managed Prometheus)
+	Service string `yaml:"service,omitempty"`
+	// CredentialSource is where the signing credentials are retrieved from.
+	// Options are env, instance_profile and static. The default is env
+	CredentialSource string `yaml:"credential_source,omitempty"`
+	// AccessKeyID is the access key id used with the static credential source
+	AccessKeyID string `yaml:"access_key_id,omitempty"`
+	// SecretAccessKey is the secret access key used with the static credential source
+	SecretAccessKey string `yaml:"secret_access_key,omitempty"`
+	// SessionToken is the optional session token used with the static credential source
+	SessionToken string `yaml:"session_token,omitempty"`
+}
+
+// Clone returns an exact copy of the subject Options
+func (o *Options) Clone() *Options {
+	return &Options{
+		Region:           o.Region,
+		Service:          o.Service,
+		CredentialSource: o.CredentialSource,
+		AccessKeyID:      o.AccessKeyID,
+		SecretAccessKey:  o.SecretAccessKey,
+		SessionToken:     o.SessionToken,
+	}
+}
+
+// Validate returns an error if the Options are invalid, and sets the default
+// Credential Source when none is provided
+func (o *Options) Validate() error {
+	if o.Region == "" {
+		return ErrMissingRegion
+	}
+	if o.Service == "" {
+		return ErrMissingService
+	}
+	if o.CredentialSource == "" {
+		o.CredentialSource = DefaultCredentialSource
+	}
+	switch o.CredentialSource {
+	case CredentialSourceEnv, CredentialSourceInstanceProfile:
+	case CredentialSourceStatic:
+		if o.AccessKeyID == "" || o.SecretAccessKey == "" {
+			return ErrMissingStaticCredentials
+		}
+	default:
+		return ErrInvalidCredentialSource
+	}
+	return nil
+}
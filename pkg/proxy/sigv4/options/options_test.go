@@ -0,0 +1,60 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package options
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+
+	tests := []struct {
+		o        *Options
+		expected error
+	}{
+		{&Options{Service: "aps"}, ErrMissingRegion},
+		{&Options{Region: "us-east-1"}, ErrMissingService},
+		{&Options{Region: "us-east-1", Service: "aps"}, nil},
+		{&Options{Region: "us-east-1", Service: "aps",
+			CredentialSource: CredentialSourceInstanceProfile}, nil},
+		{&Options{Region: "us-east-1", Service: "aps",
+			CredentialSource: "invalid"}, ErrInvalidCredentialSource},
+		{&Options{Region: "us-east-1", Service: "aps",
+			CredentialSource: CredentialSourceStatic, AccessKeyID: "AKID"}, ErrMissingStaticCredentials},
+		{&Options{Region: "us-east-1", Service: "aps", CredentialSource: CredentialSourceStatic,
+			AccessKeyID: "AKID", SecretAccessKey: "SECRET"}, nil},
+	}
+
+	for i, test := range tests {
+		if err := test.o.Validate(); err != test.expected {
+			t.Errorf("test %d expected %v got %v", i, test.expected, err)
+		}
+	}
+
+	o := &Options{Region: "us-east-1", Service: "aps"}
+	o.Validate()
+	if o.CredentialSource != DefaultCredentialSource {
+		t.Errorf("expected %s got %s", DefaultCredentialSource, o.CredentialSource)
+	}
+}
+
+func TestClone(t *testing.T) {
+	o := &Options{Region: "us-east-1", Service: "aps", CredentialSource: CredentialSourceStatic,
+		AccessKeyID: "AKID", SecretAccessKey: "SECRET", SessionToken: "TOKEN"}
+	o2 := o.Clone()
+	if *o2 != *o {
+		t.Errorf("expected %v got %v", o, o2)
+	}
+}
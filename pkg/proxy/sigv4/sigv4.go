@@ -0,0 +1,233 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package sigv4 provides an http.RoundTripper that signs requests to a backend's
+// origin with AWS Signature Version 4, as required by origins like Amazon Managed
+// Prometheus
+package sigv4
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+	so "github.com/trickstercache/trickster/v2/pkg/proxy/sigv4/options"
+)
+
+const (
+	algorithm       = "AWS4-HMAC-SHA256"
+	timeFormat      = "20060102T150405Z"
+	shortFormat     = "20060102"
+	scopeTerminal   = "aws4_request"
+	nameAmzDate     = "X-Amz-Date"
+	nameAmzToken    = "X-Amz-Security-Token"
+	emptyBodySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// Signer is an http.RoundTripper that signs each request with AWS Signature Version 4
+// before passing it to the wrapped RoundTripper. Since signing covers the request's
+// host, path, query and body, the Signer must be the last RoundTripper to modify them
+type Signer struct {
+	region      string
+	service     string
+	credentials CredentialsProvider
+	transport   http.RoundTripper
+	now         func() time.Time
+}
+
+// New returns a new Signer for the provided Options, which passes signed requests
+// to the next RoundTripper
+func New(o *so.Options, next http.RoundTripper) *Signer {
+	return NewWithCredentials(o.Region, o.Service, NewCredentialsProvider(o), next)
+}
+
+// NewWithCredentials returns a new Signer for the region and service, which signs
+// requests using the Credentials retrieved from the CredentialsProvider
+func NewWithCredentials(region, service string, cp CredentialsProvider,
+	next http.RoundTripper) *Signer {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Signer{region: region, service: service, credentials: cp,
+		transport: next, now: time.Now}
+}
+
+// RoundTrip implements http.RoundTripper
+func (s *Signer) RoundTrip(r *http.Request) (*http.Response, error) {
+	c, err := s.credentials.Retrieve(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	// per the http.RoundTripper contract, the inbound request is not modified
+	r2 := r.Clone(r.Context())
+	if err = s.Sign(r2, c); err != nil {
+		return nil, err
+	}
+	return s.transport.RoundTrip(r2)
+}
+
+// Sign adds the X-Amz-Date, X-Amz-Security-Token (for temporary credentials) and
+// Authorization headers to the request. The request body, if any, is read in order
+// to hash it, and is replaced with an unread copy
+func (s *Signer) Sign(r *http.Request, c Credentials) error {
+	payloadHash := emptyBodySHA256
+	if r.Body != nil && r.Body != http.NoBody {
+		b, err := io.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return err
+		}
+		r.Body = io.NopCloser(bytes.NewReader(b))
+		payloadHash = hashHex(b)
+	}
+
+	t := s.now().UTC()
+	amzDate := t.Format(timeFormat)
+	r.Header.Del(headers.NameAuthorization)
+	r.Header.Set(nameAmzDate, amzDate)
+	if c.SessionToken != "" {
+		r.Header.Set(nameAmzToken, c.SessionToken)
+	} else {
+		r.Header.Del(nameAmzToken)
+	}
+
+	host := r.Host
+	if host == "" {
+		host = r.URL.Host
+	}
+	signed := map[string]string{
+		"host":       host,
+		"x-amz-date": amzDate,
+	}
+	if c.SessionToken != "" {
+		signed["x-amz-security-token"] = c.SessionToken
+	}
+	names := make([]string, 0, len(signed))
+	for k := range signed {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var ch strings.Builder
+	for _, k := range names {
+		ch.WriteString(k + ":" + strings.TrimSpace(signed[k]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		canonicalURI(r.URL),
+		canonicalQuery(r.URL),
+		ch.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{t.Format(shortFormat), s.region, s.service,
+		scopeTerminal}, "/")
+	stringToSign := strings.Join([]string{algorithm, amzDate, scope,
+		hashHex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+c.SecretAccessKey), t.Format(shortFormat))
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, s.service)
+	key = hmacSHA256(key, scopeTerminal)
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	r.Header.Set(headers.NameAuthorization, algorithm+" Credential="+c.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+	return nil
+}
+
+// canonicalURI returns the URI-encoded path of the URL. As with the AWS SDKs for all
+// services besides S3, the already-escaped path is encoded a second time
+func canonicalURI(u *url.URL) string {
+	p := u.Opaque
+	if p == "" {
+		p = u.EscapedPath()
+	}
+	if p == "" {
+		return "/"
+	}
+	return escape(p, false)
+}
+
+// canonicalQuery returns the URI-encoded query parameters of the URL, sorted by name
+// and then by value
+func canonicalQuery(u *url.URL) string {
+	qp := u.Query()
+	if len(qp) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(qp))
+	lookup := make(map[string][]string, len(qp))
+	for k, vals := range qp {
+		ek := escape(k, true)
+		ev := make([]string, len(vals))
+		for i, v := range vals {
+			ev[i] = escape(v, true)
+		}
+		sort.Strings(ev)
+		keys = append(keys, ek)
+		lookup[ek] = ev
+	}
+	sort.Strings(keys)
+	params := make([]string, 0, len(qp))
+	for _, k := range keys {
+		for _, v := range lookup[k] {
+			params = append(params, k+"="+v)
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+// escape URI-encodes every byte of s other than the unreserved characters, and other
+// than '/' when encodeSlash is false
+func escape(s string, encodeSlash bool) string {
+	const hexChars = "0123456789ABCDEF"
+	var sb strings.Builder
+	sb.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			sb.WriteByte(c)
+			continue
+		}
+		sb.WriteByte('%')
+		sb.WriteByte(hexChars[c>>4])
+		sb.WriteByte(hexChars[c&15])
+	}
+	return sb.String()
+}
+
+func hashHex(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,233 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sigv4
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+	so "github.com/trickstercache/trickster/v2/pkg/proxy/sigv4/options"
+)
+
+// the credentials, date and expected signatures are from the AWS SigV4 test suite
+var testCredentials = StaticCredentials{
+	AccessKeyID:     "AKIDEXAMPLE",
+	SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+}
+
+var testTime = time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+func newTestSigner(cp CredentialsProvider, next http.RoundTripper) *Signer {
+	s := NewWithCredentials("us-east-1", "service", cp, next)
+	s.now = func() time.Time { return testTime }
+	return s
+}
+
+func TestSign(t *testing.T) {
+
+	tests := []struct {
+		method, url, body string
+		expected          string
+	}{
+		{http.MethodGet, "https://example.amazonaws.com/", "",
+			"5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{http.MethodGet, "https://example.amazonaws.com/?Param2=value2&Param1=value1", "",
+			"b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"},
+		{http.MethodPost, "https://example.amazonaws.com/", "",
+			"5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b"},
+	}
+
+	s := newTestSigner(testCredentials, nil)
+	for i, test := range tests {
+		var body io.Reader
+		if test.body != "" {
+			body = strings.NewReader(test.body)
+		}
+		r, _ := http.NewRequest(test.method, test.url, body)
+		if err := s.Sign(r, Credentials(testCredentials)); err != nil {
+			t.Fatal(err)
+		}
+		expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+			"SignedHeaders=host;x-amz-date, Signature=" + test.expected
+		if v := r.Header.Get(headers.NameAuthorization); v != expected {
+			t.Errorf("test %d expected %s got %s", i, expected, v)
+		}
+		if v := r.Header.Get(nameAmzDate); v != "20150830T123600Z" {
+			t.Errorf("test %d expected %s got %s", i, "20150830T123600Z", v)
+		}
+	}
+}
+
+func TestCanonicalURI(t *testing.T) {
+	r, _ := http.NewRequest(http.MethodGet, "http://example.com/api/v1/query%20range/", nil)
+	if v := canonicalURI(r.URL); v != "/api/v1/query%2520range/" {
+		t.Errorf("expected %s got %s", "/api/v1/query%2520range/", v)
+	}
+	r, _ = http.NewRequest(http.MethodGet, "http://example.com", nil)
+	if v := canonicalURI(r.URL); v != "/" {
+		t.Errorf("expected %s got %s", "/", v)
+	}
+	r, _ = http.NewRequest(http.MethodGet, "http://example.com/?b=2&a=z&a=y&c=x+y~", nil)
+	if v := canonicalQuery(r.URL); v != "a=y&a=z&b=2&c=x%20y~" {
+		t.Errorf("expected %s got %s", "a=y&a=z&b=2&c=x%20y~", v)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+
+	var received http.Header
+	var receivedBody string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		b, _ := io.ReadAll(r.Body)
+		receivedBody = string(b)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	cp := StaticCredentials{AccessKeyID: "AKID", SecretAccessKey: "SECRET",
+		SessionToken: "TOKEN"}
+	c := &http.Client{Transport: newTestSigner(cp, nil)}
+
+	const body = "query=up&start=0&end=60&step=15"
+	r, _ := http.NewRequest(http.MethodPost, ts.URL+"/api/v1/query_range", strings.NewReader(body))
+	r.Header.Set(headers.NameAuthorization, "Basic dXNlcjpwYXNz")
+	resp, err := c.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if receivedBody != body {
+		t.Errorf("expected %s got %s", body, receivedBody)
+	}
+	if v := received.Get(nameAmzDate); v != "20150830T123600Z" {
+		t.Errorf("expected %s got %s", "20150830T123600Z", v)
+	}
+	if v := received.Get(nameAmzToken); v != "TOKEN" {
+		t.Errorf("expected %s got %s", "TOKEN", v)
+	}
+	const prefix = "AWS4-HMAC-SHA256 Credential=AKID/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date;x-amz-security-token, Signature="
+	auth := received.Get(headers.NameAuthorization)
+	if !strings.HasPrefix(auth, prefix) || len(auth) != len(prefix)+64 {
+		t.Errorf("unexpected authorization header: %s", auth)
+	}
+
+	// the inbound request is not modified
+	if v := r.Header.Get(headers.NameAuthorization); v != "Basic dXNlcjpwYXNz" {
+		t.Errorf("expected %s got %s", "Basic dXNlcjpwYXNz", v)
+	}
+	if v := r.Header.Get(nameAmzDate); v != "" {
+		t.Errorf("expected empty string got %s", v)
+	}
+}
+
+func TestRoundTripCredentialsError(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	s := New(&so.Options{Region: "us-east-1", Service: "aps",
+		CredentialSource: so.CredentialSourceEnv}, nil)
+	r, _ := http.NewRequest(http.MethodGet, "http://127.0.0.1/", nil)
+	_, err := s.RoundTrip(r)
+	if err != ErrMissingEnvCredentials {
+		t.Errorf("expected %v got %v", ErrMissingEnvCredentials, err)
+	}
+}
+
+func TestEnvCredentials(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "SECRET")
+	t.Setenv("AWS_SESSION_TOKEN", "TOKEN")
+	c, err := EnvCredentials{}.Retrieve(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.AccessKeyID != "AKID" || c.SecretAccessKey != "SECRET" || c.SessionToken != "TOKEN" {
+		t.Errorf("unexpected credentials: %v", c)
+	}
+}
+
+func TestInstanceProfileCredentials(t *testing.T) {
+
+	var credentialRequests int
+	exp := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPut && r.URL.Path == imdsTokenPath:
+			if r.Header.Get(imdsTokenTTLHeader) == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte("imds-token"))
+			return
+		case r.Header.Get(imdsTokenHeader) != "imds-token":
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Path == imdsCredentialsPath:
+			w.Write([]byte("test-role"))
+		case r.URL.Path == imdsCredentialsPath+"test-role":
+			credentialRequests++
+			w.Write([]byte(`{"Code":"Success","AccessKeyId":"AKID","SecretAccessKey":"SECRET",` +
+				`"Token":"TOKEN","Expiration":"` + exp + `"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	p := &InstanceProfileCredentials{Endpoint: ts.URL}
+	for i := 0; i < 2; i++ {
+		c, err := p.Retrieve(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.AccessKeyID != "AKID" || c.SecretAccessKey != "SECRET" || c.SessionToken != "TOKEN" {
+			t.Errorf("unexpected credentials: %v", c)
+		}
+	}
+	// the unexpired credentials are retrieved only once
+	if credentialRequests != 1 {
+		t.Errorf("expected %d got %d", 1, credentialRequests)
+	}
+
+	p = &InstanceProfileCredentials{Endpoint: ts.URL + "/invalid"}
+	if _, err := p.Retrieve(context.Background()); err == nil {
+		t.Error("expected error for invalid endpoint")
+	}
+}
+
+func TestNewCredentialsProvider(t *testing.T) {
+	o := &so.Options{CredentialSource: so.CredentialSourceStatic, AccessKeyID: "AKID",
+		SecretAccessKey: "SECRET"}
+	if _, ok := NewCredentialsProvider(o).(StaticCredentials); !ok {
+		t.Error("expected StaticCredentials")
+	}
+	o.CredentialSource = so.CredentialSourceInstanceProfile
+	if _, ok := NewCredentialsProvider(o).(*InstanceProfileCredentials); !ok {
+		t.Error("expected InstanceProfileCredentials")
+	}
+	o.CredentialSource = so.CredentialSourceEnv
+	if _, ok := NewCredentialsProvider(o).(EnvCredentials); !ok {
+		t.Error("expected EnvCredentials")
+	}
+}
@@ -45,6 +45,12 @@ type Options struct {
 	ClientKeyPath string `yaml:"client_key_path,omitempty"`
 }
 
+// KeyPair identifies the files containing a PEM-encoded certificate chain and its private key
+type KeyPair struct {
+	CertPath string
+	KeyPath  string
+}
+
 // New will return a *Options with the default settings
 func New() *Options {
 	return &Options{
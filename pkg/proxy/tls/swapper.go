@@ -19,14 +19,34 @@ package tls
 import (
 	"crypto/tls"
 	"errors"
+	"os"
 	"sync"
+	"time"
+
+	to "github.com/trickstercache/trickster/v2/pkg/proxy/tls/options"
 )
 
+// DefaultKeyPairCheckInterval is the default minimum time between checks of the
+// CertSwapper's key pair files for changes
+const DefaultKeyPairCheckInterval = 5 * time.Second
+
 // CertSwapper is used by a TLSConfig to dynamically update the running Listener's Certificate list
 // This allows Trickster to load and unload TLS certificate configs without restarting the process
 type CertSwapper struct {
 	*sync.Mutex
 	Certificates []tls.Certificate
+
+	keyPairs      []*keyPairFiles
+	checkInterval time.Duration
+	lastCheck     time.Time
+}
+
+// keyPairFiles is a KeyPair, along with the modification times of its files
+// when the corresponding Certificate was loaded
+type keyPairFiles struct {
+	to.KeyPair
+	certModTime time.Time
+	keyModTime  time.Time
 }
 
 var errNoCertificates = errors.New("tls: no certificates configured")
@@ -34,8 +54,9 @@ var errNoCertificates = errors.New("tls: no certificates configured")
 // NewSwapper returns a new *CertSwapper based on the provided certList
 func NewSwapper(certList []tls.Certificate) *CertSwapper {
 	return &CertSwapper{
-		Mutex:        &sync.Mutex{},
-		Certificates: certList,
+		Mutex:         &sync.Mutex{},
+		Certificates:  certList,
+		checkInterval: DefaultKeyPairCheckInterval,
 	}
 }
 
@@ -44,6 +65,8 @@ func (c *CertSwapper) GetCert(clientHello *tls.ClientHelloInfo) (*tls.Certificat
 	c.Lock()
 	defer c.Unlock()
 
+	c.reloadChangedKeyPairs()
+
 	if len(c.Certificates) == 0 {
 		return nil, errNoCertificates
 	}
@@ -63,9 +86,77 @@ func (c *CertSwapper) GetCert(clientHello *tls.ClientHelloInfo) (*tls.Certificat
 	return &c.Certificates[0], nil
 }
 
-// SetCerts safely updates the certs list for the subject *CertSwapper
+// SetCerts safely updates the certs list for the subject *CertSwapper. Any key pairs
+// previously set are cleared, since they no longer correspond to the certs
 func (c *CertSwapper) SetCerts(certs []tls.Certificate) {
 	c.Lock()
 	defer c.Unlock()
 	c.Certificates = certs
+	c.keyPairs = nil
+}
+
+// SetKeyPairs provides the files from which each of the swapper's Certificates was
+// loaded, in the same order. When set, the files are checked for changes at most once
+// per check interval during handshakes, and a changed key pair is reloaded, so that
+// certificates rotated in place on disk are served without a restart
+func (c *CertSwapper) SetKeyPairs(pairs []to.KeyPair) {
+	c.Lock()
+	defer c.Unlock()
+	if len(pairs) != len(c.Certificates) {
+		c.keyPairs = nil
+		return
+	}
+	c.keyPairs = make([]*keyPairFiles, len(pairs))
+	for i, p := range pairs {
+		kp := &keyPairFiles{KeyPair: p}
+		kp.certModTime, kp.keyModTime = kp.modTimes()
+		c.keyPairs[i] = kp
+	}
+	c.lastCheck = time.Now()
+}
+
+// reloadChangedKeyPairs reloads any Certificates whose key pair files have changed
+// since they were loaded. A key pair that fails to load (e.g., because only one of
+// its files has been rotated so far) keeps its current Certificate, and is retried
+// on a later check. The caller must hold the lock
+func (c *CertSwapper) reloadChangedKeyPairs() {
+	if len(c.keyPairs) == 0 || len(c.keyPairs) != len(c.Certificates) ||
+		time.Since(c.lastCheck) < c.checkInterval {
+		return
+	}
+	c.lastCheck = time.Now()
+	var certs []tls.Certificate
+	for i, kp := range c.keyPairs {
+		cm, km := kp.modTimes()
+		if cm.Equal(kp.certModTime) && km.Equal(kp.keyModTime) {
+			continue
+		}
+		cert, err := tls.LoadX509KeyPair(kp.CertPath, kp.KeyPath)
+		if err != nil {
+			continue
+		}
+		if certs == nil {
+			// copy on write, so a Certificate returned to an in-flight handshake is unchanged
+			certs = make([]tls.Certificate, len(c.Certificates))
+			copy(certs, c.Certificates)
+		}
+		certs[i] = cert
+		kp.certModTime, kp.keyModTime = cm, km
+	}
+	if certs != nil {
+		c.Certificates = certs
+	}
+}
+
+// modTimes returns the modification times of the key pair's files, which are
+// zero for any file that can't be read
+func (kp *keyPairFiles) modTimes() (time.Time, time.Time) {
+	var cm, km time.Time
+	if fi, err := os.Stat(kp.CertPath); err == nil {
+		cm = fi.ModTime()
+	}
+	if fi, err := os.Stat(kp.KeyPath); err == nil {
+		km = fi.ModTime()
+	}
+	return cm, km
 }
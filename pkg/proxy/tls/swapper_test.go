@@ -18,7 +18,13 @@ package tls
 
 import (
 	"crypto/tls"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
+
+	to "github.com/trickstercache/trickster/v2/pkg/proxy/tls/options"
+	tlstest "github.com/trickstercache/trickster/v2/pkg/testutil/tls"
 )
 
 func getSwapper(t *testing.T) (*CertSwapper, *tls.Config, func()) {
@@ -76,3 +82,166 @@ func TestGetSetCert(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestGetCertSNI(t *testing.T) {
+
+	names := []string{"default.example.com", "a.example.com", "b.example.com"}
+	certs := make([]tls.Certificate, len(names))
+	for i, name := range names {
+		k, c, err := tlstest.GetTestKeyAndCertForNames(false, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		certs[i], err = tls.X509KeyPair(c, k)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sw := NewSwapper(certs)
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{GetCertificate: sw.GetCert})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				conn.(*tls.Conn).Handshake()
+				conn.Close()
+			}()
+		}
+	}()
+
+	tests := []struct {
+		serverName string
+		expected   string
+	}{
+		{"a.example.com", "a.example.com"},
+		{"b.example.com", "b.example.com"},
+		{"default.example.com", "default.example.com"},
+		{"unknown.example.com", "default.example.com"},
+		{"", "default.example.com"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.serverName, func(t *testing.T) {
+			conn, err := tls.Dial("tcp", l.Addr().String(),
+				&tls.Config{ServerName: test.serverName, InsecureSkipVerify: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			pc := conn.ConnectionState().PeerCertificates
+			if len(pc) == 0 || len(pc[0].DNSNames) == 0 {
+				t.Fatal("expected a peer certificate")
+			}
+			if pc[0].DNSNames[0] != test.expected {
+				t.Errorf("expected %s got %s", test.expected, pc[0].DNSNames[0])
+			}
+		})
+	}
+}
+
+func TestGetCertKeyPairRotation(t *testing.T) {
+
+	dir := t.TempDir()
+	kp := to.KeyPair{
+		CertPath: filepath.Join(dir, "cert.pem"),
+		KeyPath:  filepath.Join(dir, "key.pem"),
+	}
+	writeKeyPair := func(name string, modTime time.Time) {
+		k, c, err := tlstest.GetTestKeyAndCertForNames(false, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = os.WriteFile(kp.CertPath, c, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err = os.WriteFile(kp.KeyPath, k, 0600); err != nil {
+			t.Fatal(err)
+		}
+		// set the modtimes explicitly, since the filesystem's resolution may be coarse
+		os.Chtimes(kp.CertPath, modTime, modTime)
+		os.Chtimes(kp.KeyPath, modTime, modTime)
+	}
+
+	now := time.Now()
+	writeKeyPair("original.example.com", now.Add(-time.Hour))
+	cert, err := tls.LoadX509KeyPair(kp.CertPath, kp.KeyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sw := NewSwapper([]tls.Certificate{cert})
+	sw.SetKeyPairs([]to.KeyPair{kp})
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{GetCertificate: sw.GetCert})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				conn.(*tls.Conn).Handshake()
+				conn.Close()
+			}()
+		}
+	}()
+
+	served := func() string {
+		conn, err := tls.Dial("tcp", l.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		pc := conn.ConnectionState().PeerCertificates
+		if len(pc) == 0 || len(pc[0].DNSNames) == 0 {
+			t.Fatal("expected a peer certificate")
+		}
+		return pc[0].DNSNames[0]
+	}
+
+	if v := served(); v != "original.example.com" {
+		t.Errorf("expected %s got %s", "original.example.com", v)
+	}
+
+	// the rotated key pair is not checked for until the check interval elapses
+	writeKeyPair("rotated.example.com", now)
+	if v := served(); v != "original.example.com" {
+		t.Errorf("expected %s got %s", "original.example.com", v)
+	}
+
+	sw.Lock()
+	sw.lastCheck = time.Time{}
+	sw.Unlock()
+	if v := served(); v != "rotated.example.com" {
+		t.Errorf("expected %s got %s", "rotated.example.com", v)
+	}
+
+	// a partially-rotated key pair that fails to load keeps the current certificate
+	if err = os.WriteFile(kp.CertPath, []byte("not a cert"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(kp.CertPath, now.Add(time.Hour), now.Add(time.Hour))
+	sw.Lock()
+	sw.lastCheck = time.Time{}
+	sw.Unlock()
+	if v := served(); v != "rotated.example.com" {
+		t.Errorf("expected %s got %s", "rotated.example.com", v)
+	}
+
+	// SetCerts clears the key pairs, which no longer correspond to the certs
+	sw.SetCerts([]tls.Certificate{cert})
+	if sw.keyPairs != nil {
+		t.Error("expected key pairs to be cleared")
+	}
+}
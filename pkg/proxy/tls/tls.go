@@ -19,8 +19,8 @@ package tls
 
 import "github.com/trickstercache/trickster/v2/cmd/trickster/config"
 
-// OptionsChanged will return true if the TLS options for any backend
-// is different between configs
+// OptionsChanged will return true if the frontend TLS certificates, or the TLS options
+// for any backend, are different between configs
 func OptionsChanged(conf, oldConf *config.Config) bool {
 
 	if conf == nil {
@@ -30,6 +30,11 @@ func OptionsChanged(conf, oldConf *config.Config) bool {
 		return true
 	}
 
+	if conf.Frontend != nil && oldConf.Frontend != nil &&
+		!conf.Frontend.TLSCertificatesEqual(oldConf.Frontend) {
+		return true
+	}
+
 	for k, v := range oldConf.Backends {
 		if v.TLS != nil && v.TLS.ServeTLS {
 			if o, ok := conf.Backends[k]; !ok ||
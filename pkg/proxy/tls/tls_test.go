@@ -22,6 +22,7 @@ import (
 	"testing"
 
 	"github.com/trickstercache/trickster/v2/cmd/trickster/config"
+	fo "github.com/trickstercache/trickster/v2/pkg/frontend/options"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/tls/options"
 	tlstest "github.com/trickstercache/trickster/v2/pkg/testutil/tls"
 )
@@ -279,4 +280,12 @@ func TestOptionsChanged(t *testing.T) {
 		t.Errorf("expected true")
 	}
 
+	delete(c1.Backends, "test1")
+	c1.Frontend.TLSCertificates = []*fo.TLSCertificate{
+		{FullChainCertPath: "cert.pem", PrivateKeyPath: "key.pem"}}
+
+	b = OptionsChanged(c1, c2)
+	if !b {
+		t.Errorf("expected true")
+	}
 }
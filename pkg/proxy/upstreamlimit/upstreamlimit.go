@@ -0,0 +1,109 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package upstreamlimit provides a Limiter that bounds the number of concurrent
+// requests a backend sends to its origin, so that a burst of cache misses can't
+// overwhelm a fragile origin
+package upstreamlimit
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/observability/metrics"
+)
+
+// Limiter is a per-backend semaphore around upstream requests. Requests beyond its
+// maximum wait in queue for a slot for up to its queue timeout.
+type Limiter struct {
+	name    string
+	slots   chan struct{}
+	timeout time.Duration
+}
+
+// New returns a new Limiter for the named backend permitting max concurrent
+// upstream requests, with others waiting for a slot for up to timeout
+func New(name string, max int, timeout time.Duration) *Limiter {
+	metrics.ProxyUpstreamInFlight.WithLabelValues(name).Set(0)
+	return &Limiter{
+		name:    name,
+		slots:   make(chan struct{}, max),
+		timeout: timeout,
+	}
+}
+
+// InFlight returns the number of upstream requests currently holding a slot
+func (l *Limiter) InFlight() int {
+	return len(l.slots)
+}
+
+// Acquire waits for an upstream slot, for up to the Limiter's queue timeout or until
+// ctx is done. It returns true, and a function that must be called to release the
+// slot, if one was acquired. The release function is safe to call more than once.
+func (l *Limiter) Acquire(ctx context.Context) (func(), bool) {
+	select {
+	case l.slots <- struct{}{}:
+	default:
+		if l.timeout <= 0 {
+			return nil, false
+		}
+		t := time.NewTimer(l.timeout)
+		defer t.Stop()
+		select {
+		case l.slots <- struct{}{}:
+		case <-t.C:
+			return nil, false
+		case <-ctx.Done():
+			return nil, false
+		}
+	}
+	metrics.ProxyUpstreamInFlight.WithLabelValues(l.name).Inc()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			<-l.slots
+			metrics.ProxyUpstreamInFlight.WithLabelValues(l.name).Dec()
+		})
+	}, true
+}
+
+// ReleaseOnDone returns an io.ReadCloser wrapping rc that calls release once rc is
+// closed or returns an error (including io.EOF), so the slot is held until the
+// upstream response body has been consumed
+func ReleaseOnDone(rc io.ReadCloser, release func()) io.ReadCloser {
+	return &releaser{ReadCloser: rc, release: release}
+}
+
+type releaser struct {
+	io.ReadCloser
+	release func()
+}
+
+func (r *releaser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if err != nil {
+		r.release()
+	}
+	return n, err
+}
+
+func (r *releaser) Close() error {
+	err := r.ReadCloser.Close()
+	r.release()
+	return err
+}
@@ -0,0 +1,124 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package upstreamlimit
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimiterQueuesThenTimesOut(t *testing.T) {
+
+	l := New("test", 2, 200*time.Millisecond)
+	ctx := context.Background()
+
+	// saturate the limiter
+	releases := make([]func(), 2)
+	for i := range releases {
+		rf, ok := l.Acquire(ctx)
+		if !ok {
+			t.Fatalf("expected slot %d to be acquired", i)
+		}
+		releases[i] = rf
+	}
+	if v := l.InFlight(); v != 2 {
+		t.Errorf("expected %d got %d", 2, v)
+	}
+
+	// a queued request acquires the slot once it is released within the timeout
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		releases[0]()
+	}()
+	start := time.Now()
+	rf, ok := l.Acquire(ctx)
+	if !ok {
+		t.Fatal("expected queued request to acquire a released slot")
+	}
+	if d := time.Since(start); d < 50*time.Millisecond {
+		t.Errorf("expected queued request to wait, waited %s", d)
+	}
+
+	// a queued request fails when no slot is released within the timeout
+	start = time.Now()
+	if _, ok := l.Acquire(ctx); ok {
+		t.Fatal("expected queued request to time out")
+	}
+	if d := time.Since(start); d < 200*time.Millisecond {
+		t.Errorf("expected queued request to wait for the timeout, waited %s", d)
+	}
+
+	// releasing more than once must not free additional slots
+	rf()
+	rf()
+	if v := l.InFlight(); v != 1 {
+		t.Errorf("expected %d got %d", 1, v)
+	}
+	releases[1]()
+	if v := l.InFlight(); v != 0 {
+		t.Errorf("expected %d got %d", 0, v)
+	}
+}
+
+func TestLimiterNoQueue(t *testing.T) {
+	l := New("test", 1, 0)
+	rf, ok := l.Acquire(context.Background())
+	if !ok {
+		t.Fatal("expected slot to be acquired")
+	}
+	defer rf()
+	if _, ok := l.Acquire(context.Background()); ok {
+		t.Error("expected request to fail immediately without a queue timeout")
+	}
+}
+
+func TestLimiterCanceledContext(t *testing.T) {
+	l := New("test", 1, time.Minute)
+	rf, ok := l.Acquire(context.Background())
+	if !ok {
+		t.Fatal("expected slot to be acquired")
+	}
+	defer rf()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, ok := l.Acquire(ctx); ok {
+		t.Error("expected queued request to fail when its context is done")
+	}
+}
+
+func TestReleaseOnDone(t *testing.T) {
+
+	l := New("test", 1, 0)
+	rf, _ := l.Acquire(context.Background())
+	rc := ReleaseOnDone(io.NopCloser(strings.NewReader("trickster")), rf)
+	if _, err := io.ReadAll(rc); err != nil {
+		t.Fatal(err)
+	}
+	if v := l.InFlight(); v != 0 {
+		t.Errorf("expected slot released at EOF, in flight %d", v)
+	}
+
+	rf, _ = l.Acquire(context.Background())
+	rc = ReleaseOnDone(io.NopCloser(strings.NewReader("trickster")), rf)
+	rc.Close()
+	if v := l.InFlight(); v != 0 {
+		t.Errorf("expected slot released on close, in flight %d", v)
+	}
+}
@@ -0,0 +1,68 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package urls
+
+import "regexp"
+
+// PathRewriter rewrites URL paths using an ordered list of regular expression rules
+type PathRewriter struct {
+	rules       []pathRewriteRule
+	stopOnMatch bool
+}
+
+type pathRewriteRule struct {
+	match       *regexp.Regexp
+	replacement string
+}
+
+// NewPathRewriter returns a new PathRewriter with no rules. When stopOnMatch is true,
+// a path is only rewritten by the first rule that matches it; otherwise, each
+// matching rule is applied to the output of the previous one
+func NewPathRewriter(stopOnMatch bool) *PathRewriter {
+	return &PathRewriter{stopOnMatch: stopOnMatch}
+}
+
+// AddRule compiles and appends a rule that replaces matches of the match expression
+// with the replacement template, which may reference capture groups as $1 or ${name}
+func (pw *PathRewriter) AddRule(match, replacement string) error {
+	re, err := regexp.Compile(match)
+	if err != nil {
+		return err
+	}
+	pw.rules = append(pw.rules, pathRewriteRule{match: re, replacement: replacement})
+	return nil
+}
+
+// Len returns the number of rules in the PathRewriter
+func (pw *PathRewriter) Len() int {
+	return len(pw.rules)
+}
+
+// Rewrite returns the path as rewritten by the PathRewriter's rules. Paths matching
+// no rules are returned unchanged
+func (pw *PathRewriter) Rewrite(path string) string {
+	for _, rule := range pw.rules {
+		if !rule.match.MatchString(path) {
+			continue
+		}
+		path = rule.match.ReplaceAllString(path, rule.replacement)
+		if pw.stopOnMatch {
+			break
+		}
+	}
+	return path
+}
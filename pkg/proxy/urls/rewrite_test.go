@@ -0,0 +1,65 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package urls
+
+import "testing"
+
+func TestPathRewriter(t *testing.T) {
+
+	tests := []struct {
+		stopOnMatch bool
+		path        string
+		expected    string
+	}{
+		// capture group substitution
+		{true, "/v1/metrics/cpu/usage", "/api/cpu/usage"},
+		// named capture group substitution
+		{true, "/v1/labels/job", "/api/v1/label/job/values"},
+		// no match passes through unchanged
+		{true, "/health", "/health"},
+		// only the first matching rule is applied
+		{true, "/v1/metrics/legacy", "/api/legacy"},
+		// each matching rule is applied in order
+		{false, "/v1/metrics/legacy", "/api/current"},
+	}
+
+	for _, test := range tests {
+		pw := NewPathRewriter(test.stopOnMatch)
+		for _, rule := range [][2]string{
+			{`^/v1/metrics/(.*)$`, "/api/$1"},
+			{`^/v1/labels/(?P<name>[^/]+)$`, "/api/v1/label/${name}/values"},
+			{`^/api/legacy$`, "/api/current"},
+		} {
+			if err := pw.AddRule(rule[0], rule[1]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if v := pw.Rewrite(test.path); v != test.expected {
+			t.Errorf("expected %s got %s", test.expected, v)
+		}
+	}
+}
+
+func TestPathRewriterInvalidRule(t *testing.T) {
+	pw := NewPathRewriter(true)
+	if err := pw.AddRule(`^/v1/(.*`, "/api/$1"); err == nil {
+		t.Error("expected error for invalid expression")
+	}
+	if pw.Len() != 0 {
+		t.Errorf("expected %d got %d", 0, pw.Len())
+	}
+}
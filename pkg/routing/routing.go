@@ -62,6 +62,21 @@ func RegisterPprofRoutes(routerName string, h *http.ServeMux, logger interface{}
 func RegisterProxyRoutes(conf *config.Config, r router.Router, metricsRouter *http.ServeMux,
 	caches map[string]cache.Cache, tracers tracing.Tracers,
 	logger interface{}, dryRun bool) (backends.Backends, error) {
+	return registerProxyRoutes(conf, r, metricsRouter, caches, tracers, nil, logger, dryRun)
+}
+
+// ReloadProxyRoutes registers the routes for the configured backends like RegisterProxyRoutes,
+// except that the non-virtual backends in unchanged, whose configurations are the same as in the
+// previous config and whose caches were kept, are reused instead of being constructed anew
+func ReloadProxyRoutes(conf *config.Config, r router.Router, metricsRouter *http.ServeMux,
+	caches map[string]cache.Cache, tracers tracing.Tracers, unchanged backends.Backends,
+	logger interface{}) (backends.Backends, error) {
+	return registerProxyRoutes(conf, r, metricsRouter, caches, tracers, unchanged, logger, false)
+}
+
+func registerProxyRoutes(conf *config.Config, r router.Router, metricsRouter *http.ServeMux,
+	caches map[string]cache.Cache, tracers tracing.Tracers, unchanged backends.Backends,
+	logger interface{}, dryRun bool) (backends.Backends, error) {
 
 	// a fake "top-level" backend representing the main frontend, so rules can route
 	// to it via the clients map
@@ -101,7 +116,7 @@ func RegisterProxyRoutes(conf *config.Config, r router.Router, metricsRouter *ht
 			continue
 		}
 		err = registerBackendRoutes(r, metricsRouter, conf,
-			k, o, clients, caches, tracers, logger, dryRun)
+			k, o, clients, caches, tracers, unchanged, logger, dryRun)
 		if err != nil {
 			return nil, err
 		}
@@ -112,7 +127,8 @@ func RegisterProxyRoutes(conf *config.Config, r router.Router, metricsRouter *ht
 			cdo = ndo
 			defaultBackend = "default"
 		} else {
-			err = registerBackendRoutes(r, nil, conf, "default", ndo, clients, caches, tracers, logger, dryRun)
+			err = registerBackendRoutes(r, nil, conf, "default", ndo, clients, caches, tracers,
+				unchanged, logger, dryRun)
 			if err != nil {
 				return nil, err
 			}
@@ -120,7 +136,7 @@ func RegisterProxyRoutes(conf *config.Config, r router.Router, metricsRouter *ht
 	}
 	if cdo != nil {
 		err = registerBackendRoutes(r, metricsRouter, conf,
-			defaultBackend, cdo, clients, caches, tracers, logger, dryRun)
+			defaultBackend, cdo, clients, caches, tracers, unchanged, logger, dryRun)
 		if err != nil {
 			return nil, err
 		}
@@ -146,13 +162,20 @@ var noCacheBackends = map[string]interface{}{
 }
 
 // RegisterHealthHandler registers the main health handler
-func RegisterHealthHandler(router *http.ServeMux, path string, hc healthcheck.HealthChecker) {
-	router.Handle(path, health.StatusHandler(hc))
+func RegisterHealthHandler(router *http.ServeMux, path string, hc healthcheck.HealthChecker,
+	clients backends.Backends, caches map[string]cache.Cache) {
+	lookup := make(bo.Lookup, len(clients))
+	for k, c := range clients {
+		if c != nil {
+			lookup[k] = c.Configuration()
+		}
+	}
+	router.Handle(path, health.StatusHandler(hc, lookup, caches))
 }
 
 func registerBackendRoutes(r router.Router, metricsRouter *http.ServeMux, conf *config.Config, k string,
 	o *bo.Options, clients backends.Backends, caches map[string]cache.Cache,
-	tracers tracing.Tracers, logger interface{}, dryRun bool) error {
+	tracers tracing.Tracers, unchanged backends.Backends, logger interface{}, dryRun bool) error {
 
 	var client backends.Backend
 	var c cache.Cache
@@ -170,12 +193,25 @@ func registerBackendRoutes(r router.Router, metricsRouter *http.ServeMux, conf *
 			"backendProvider": o.Provider, "upstreamHost": o.Host})
 	}
 
-	cf := registration.SupportedProviders()
-	if f, ok := cf[strings.ToLower(o.Provider)]; ok && f != nil {
-		client, err = f(k, o, router.NewRouter(), c, clients, cf)
-	}
-	if err != nil {
-		return err
+	if prev, ok := unchanged[k]; ok && prev != nil && !backends.IsVirtual(o.Provider) &&
+		prev.Cache() == c {
+		// keep the existing client and its options, which are unchanged, but take the
+		// freshly-loaded paths, since the previous ones were merged in place during
+		// their route registration; the routes are then registered anew on a new router
+		po1 := prev.Configuration()
+		po1.Paths = o.Paths
+		o = po1
+		conf.Backends[k] = o
+		client = prev
+		client.SetRouter(router.NewRouter())
+	} else {
+		cf := registration.SupportedProviders()
+		if f, ok := cf[strings.ToLower(o.Provider)]; ok && f != nil {
+			client, err = f(k, o, router.NewRouter(), c, clients, cf)
+		}
+		if err != nil {
+			return err
+		}
 	}
 
 	if client != nil && !dryRun {
@@ -229,9 +265,37 @@ func RegisterPathRoutes(r router.Router, handlers map[string]http.Handler,
 		if tr != nil {
 			h = middleware.Trace(tr, h)
 		}
+		// mirror a sample of requests to any shadow origin
+		if o.Shadow != nil {
+			h = middleware.Shadow(o.Shadow, logger, h)
+		}
 		// attach compression handler
 		h = encoding.HandleCompression(h, o.CompressibleTypes)
+		// bound the total time spent responding to each request
+		if o.ClientResponseTimeout > 0 {
+			h = middleware.ClientResponseTimeout(o.ClientResponseTimeout, h)
+		}
+		// apply any response header updates to all responses, including cache hits
+		if len(po1.ResponseHeaders) > 0 {
+			h = middleware.UpdateResponseHeaders(po1.ResponseHeaders, h)
+		}
+		// rewrite the Cache-Control header seen by clients, after any caching has occurred
+		if po1.ClientCacheControl != "" {
+			h = middleware.RewriteClientCacheControl(po1.ClientCacheControl, h)
+		}
+		// proxy websocket upgrades straight to the origin, bypassing the cache
+		if o.AllowWebSocketUpgrade {
+			h = middleware.WebSocketUpgrade(o, h)
+		}
 		// add Backend, Cache, and Path Configs to the HTTP Request's context
+		// inject any templated request headers
+		if len(o.HeaderInjections) > 0 || len(po1.HeaderInjections) > 0 {
+			h = middleware.InjectRequestHeaders(o.HeaderInjections, po1.HeaderInjections, h)
+		}
+		// include any bearer token claim in the cache key
+		if po1.CacheKeyJWTVerifier != nil {
+			h = middleware.CacheKeyClaim(po1.CacheKeyJWTVerifier, h)
+		}
 		h = middleware.WithResourcesContext(client, o, c, po1, tr, logger, h)
 		// attach any request rewriters
 		if len(o.ReqRewriter) > 0 {
@@ -240,6 +304,10 @@ func RegisterPathRoutes(r router.Router, handlers map[string]http.Handler,
 		if len(po1.ReqRewriter) > 0 {
 			h = rewriter.Rewrite(po1.ReqRewriter, h)
 		}
+		// enforce any per-client rate limit
+		if po1.RateLimiter != nil {
+			h = middleware.RateLimit(po1.RateLimiter, h)
+		}
 		// decorate frontend prometheus metrics
 		if !po1.NoMetrics {
 			h = middleware.Decorate(o.Name, o.Provider, po1.Path, h)
@@ -358,6 +426,22 @@ func RegisterDefaultBackendRoutes(router router.Router, bknds backends.Backends,
 		if tr != nil {
 			h = middleware.Trace(tr, h)
 		}
+		// mirror a sample of requests to any shadow origin
+		if o.Shadow != nil {
+			h = middleware.Shadow(o.Shadow, logger, h)
+		}
+		// bound the total time spent responding to each request
+		if o.ClientResponseTimeout > 0 {
+			h = middleware.ClientResponseTimeout(o.ClientResponseTimeout, h)
+		}
+		// rewrite the Cache-Control header seen by clients, after any caching has occurred
+		if po.ClientCacheControl != "" {
+			h = middleware.RewriteClientCacheControl(po.ClientCacheControl, h)
+		}
+		// include any bearer token claim in the cache key
+		if po.CacheKeyJWTVerifier != nil {
+			h = middleware.CacheKeyClaim(po.CacheKeyJWTVerifier, h)
+		}
 		// add Backend, Cache, and Path Configs to the HTTP Request's context
 		h = middleware.WithResourcesContext(client, o, c, po, tr, logger, h)
 		// attach any request rewriters
@@ -367,6 +451,10 @@ func RegisterDefaultBackendRoutes(router router.Router, bknds backends.Backends,
 		if len(po.ReqRewriter) > 0 {
 			h = rewriter.Rewrite(po.ReqRewriter, h)
 		}
+		// enforce any per-client rate limit
+		if po.RateLimiter != nil {
+			h = middleware.RateLimit(po.RateLimiter, h)
+		}
 		// decorate frontend prometheus metrics
 		if !po.NoMetrics {
 			h = middleware.Decorate(o.Name, o.Provider, po.Path, h)
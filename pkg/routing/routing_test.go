@@ -22,6 +22,7 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/trickstercache/trickster/v2/cmd/trickster/config"
 	"github.com/trickstercache/trickster/v2/pkg/backends"
@@ -35,9 +36,11 @@ import (
 	"github.com/trickstercache/trickster/v2/pkg/observability/tracing"
 	"github.com/trickstercache/trickster/v2/pkg/observability/tracing/exporters/zipkin"
 	to "github.com/trickstercache/trickster/v2/pkg/observability/tracing/options"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/methods"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/paths/matching"
 	po "github.com/trickstercache/trickster/v2/pkg/proxy/paths/options"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/ratelimit"
 	"github.com/trickstercache/trickster/v2/pkg/router"
 	testutil "github.com/trickstercache/trickster/v2/pkg/testutil"
 	tlstest "github.com/trickstercache/trickster/v2/pkg/testutil/tls"
@@ -58,7 +61,7 @@ func TestRegisterHealthHandler(t *testing.T) {
 	router := http.NewServeMux()
 	path := "/test"
 	hc := healthcheck.New()
-	RegisterHealthHandler(router, path, hc)
+	RegisterHealthHandler(router, path, hc, nil, nil)
 }
 
 func TestRegisterProxyRoutes(t *testing.T) {
@@ -178,6 +181,59 @@ func TestRegisterProxyRoutesInflux(t *testing.T) {
 
 }
 
+func TestReloadProxyRoutes(t *testing.T) {
+	args := []string{"-origin-url", "http://1", "-provider", "prometheus"}
+	conf, _, err := config.Load("trickster", "test", args)
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+
+	caches := registration.LoadCachesFromConfig(conf, logging.ConsoleLogger("error"))
+	defer registration.CloseCaches(caches)
+	clients, err := RegisterProxyRoutes(conf, router.NewRouter(), http.NewServeMux(), caches,
+		nil, logging.ConsoleLogger("error"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := clients["default"]
+	pathCount := len(prev.Configuration().Paths)
+
+	conf2, _, err := config.Load("trickster", "test", args)
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+	clients2, err := ReloadProxyRoutes(conf2, router.NewRouter(), http.NewServeMux(), caches,
+		nil, backends.Backends{"default": prev}, logging.ConsoleLogger("error"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clients2["default"] != prev {
+		t.Error("expected the unchanged backend to be kept")
+	}
+	if conf2.Backends["default"] != prev.Configuration() {
+		t.Error("expected the config to reference the kept backend's options")
+	}
+	if len(conf2.Backends["default"].Paths) != pathCount {
+		t.Errorf("expected %d got %d", pathCount, len(conf2.Backends["default"].Paths))
+	}
+
+	// a backend whose cache was not kept is constructed anew
+	conf3, _, err := config.Load("trickster", "test", args)
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+	caches3 := registration.LoadCachesFromConfig(conf3, logging.ConsoleLogger("error"))
+	defer registration.CloseCaches(caches3)
+	clients3, err := ReloadProxyRoutes(conf3, router.NewRouter(), http.NewServeMux(), caches3,
+		nil, backends.Backends{"default": prev}, logging.ConsoleLogger("error"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clients3["default"] == prev {
+		t.Error("expected a new backend")
+	}
+}
+
 func TestRegisterProxyRoutesReverseProxy(t *testing.T) {
 	conf, _, err := config.Load("trickster", "test",
 		[]string{"-log-level", "debug", "-origin-url", "http://1", "-provider", "rp"})
@@ -448,6 +504,73 @@ func TestRegisterPathRoutes(t *testing.T) {
 
 }
 
+func TestRegisterPathRoutesRateLimit(t *testing.T) {
+
+	conf, _, err := config.Load("trickster", "test",
+		[]string{"-log-level", "debug", "-origin-url", "http://1", "-provider", "rpc"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+
+	oo := conf.Backends["default"]
+	rpc, _ := reverseproxycache.NewClient("test", oo, router.NewRouter(), nil, nil, nil)
+
+	testHandler := http.HandlerFunc(testutil.BasicHTTPHandler)
+	handlers := map[string]http.Handler{"testHandler": testHandler}
+
+	rlo := &ratelimit.Options{RequestsPerSecond: 20, Burst: 2}
+	if err := rlo.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	r := router.NewRouter()
+	dpc := rpc.DefaultPathConfigs(oo)
+	limited := dpc["/-GET-HEAD"]
+	limited.Handler = testHandler
+	limited.HandlerName = "testHandler"
+	limited.RateLimit = rlo
+	limited.RateLimiter = ratelimit.New(rlo)
+
+	unlimited := po.New()
+	unlimited.Path = "/unlimited"
+	unlimited.Handler = testHandler
+	unlimited.HandlerName = "testHandler"
+	dpc["/unlimited-GET-HEAD"] = unlimited
+	RegisterPathRoutes(r, handlers, rpc, oo, nil, dpc, nil, "", logging.ConsoleLogger("INFO"))
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "http://0/default"+path, nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := get("/"); w.Code != http.StatusOK {
+			t.Errorf("expected %d got %d", http.StatusOK, w.Code)
+		}
+	}
+	w := get("/")
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("expected %d got %d", http.StatusTooManyRequests, w.Code)
+	}
+	if v := w.Header().Get("Retry-After"); v != "1" {
+		t.Errorf("expected %s got %s", "1", v)
+	}
+
+	for i := 0; i < 5; i++ {
+		if w := get("/unlimited"); w.Code != http.StatusOK {
+			t.Errorf("expected %d got %d for unlimited path", http.StatusOK, w.Code)
+		}
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if w := get("/"); w.Code != http.StatusOK {
+		t.Errorf("expected %d got %d after recovery", http.StatusOK, w.Code)
+	}
+}
+
 func TestValidateRuleClients(t *testing.T) {
 
 	c, err := rule.NewClient("test", nil, nil, nil, nil, nil)
@@ -509,6 +632,12 @@ func TestRegisterDefaultBackendRoutes(t *testing.T) {
 
 	r = router.NewRouter()
 	po1.MatchType = matching.PathMatchTypeExact
+	po1.ClientCacheControl = "public, max-age=60"
 	RegisterDefaultBackendRoutes(r, b, logger, tr)
 
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://0/", nil))
+	if v := w.Header().Get(headers.NameCacheControl); v != "public, max-age=60" {
+		t.Errorf("expected %s got %s", "public, max-age=60", v)
+	}
 }
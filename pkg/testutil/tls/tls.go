@@ -62,6 +62,24 @@ func WriteTestKeyAndCert(isCA bool, keyPath, certPath string) error {
 
 // GetTestKeyAndCert returns a self-sign test TLS key and certificate
 func GetTestKeyAndCert(isCA bool) ([]byte, []byte, error) {
+	return GetTestKeyAndCertForNames(isCA, "localhost")
+}
+
+// GetTestKeyAndCertForNames returns a self-sign test TLS key and certificate
+// that is valid for the provided DNS names
+func GetTestKeyAndCertForNames(isCA bool, dnsNames ...string) ([]byte, []byte, error) {
+	return getTestKeyAndCert(isCA, x509.ExtKeyUsageServerAuth, dnsNames)
+}
+
+// GetTestClientKeyAndCert returns a self-sign test TLS client key and certificate.
+// The certificate is its own CA, so it can be trusted as the client CA when verifying
+// itself
+func GetTestClientKeyAndCert() ([]byte, []byte, error) {
+	return getTestKeyAndCert(true, x509.ExtKeyUsageClientAuth, nil)
+}
+
+func getTestKeyAndCert(isCA bool, usage x509.ExtKeyUsage,
+	dnsNames []string) ([]byte, []byte, error) {
 	priv, _ := rsa.GenerateKey(rand.Reader, 2048)
 	notBefore := time.Now()
 	notAfter := notBefore.Add(time.Minute * 5)
@@ -77,10 +95,10 @@ func GetTestKeyAndCert(isCA bool) ([]byte, []byte, error) {
 		NotBefore:             notBefore,
 		NotAfter:              notAfter,
 		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
-		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		ExtKeyUsage:           []x509.ExtKeyUsage{usage},
 		BasicConstraintsValid: true,
 		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
-		DNSNames:              []string{"localhost"},
+		DNSNames:              dnsNames,
 	}
 	if isCA {
 		template.IsCA = true
@@ -30,6 +30,15 @@ func TestGetTestKeyAndCert(t *testing.T) {
 
 }
 
+func TestGetTestClientKeyAndCert(t *testing.T) {
+
+	_, _, err := GetTestClientKeyAndCert()
+	if err != nil {
+		t.Error(err)
+	}
+
+}
+
 func TestGetTestKeyAndCertFiles(t *testing.T) {
 
 	_, _, closer, err := GetTestKeyAndCertFiles("invalid-key")
@@ -546,10 +546,40 @@ func UnmarshalDataSet(b []byte, trq *timeseries.TimeRangeQuery) (timeseries.Time
 		} else {
 			ds.TimeRangeQuery = trq
 		}
+		ds.restoreUint64Values()
 	}
 	return ds, err
 }
 
+// restoreUint64Values converts the values of any Uint64 fields back to uint64.
+// msgpack encodes unsigned integers in their most compact form, so values that
+// fit into a signed integer are decoded as int64 rather than uint64
+func (ds *DataSet) restoreUint64Values() {
+	for _, r := range ds.Results {
+		if r == nil {
+			continue
+		}
+		for _, s := range r.SeriesList {
+			if s == nil {
+				continue
+			}
+			for i, fd := range s.Header.FieldsList {
+				if fd.DataType != timeseries.Uint64 {
+					continue
+				}
+				for j := range s.Points {
+					if i >= len(s.Points[j].Values) {
+						continue
+					}
+					if v, ok := s.Points[j].Values[i].(int64); ok && v >= 0 {
+						s.Points[j].Values[i] = uint64(v)
+					}
+				}
+			}
+		}
+	}
+}
+
 // MarshalDataSet marshals the dataset into a msgpack-formatted byte slice
 func MarshalDataSet(ts timeseries.Timeseries, rlo *timeseries.RequestOptions, status int) ([]byte, error) {
 	ds, ok := ts.(*DataSet)
@@ -17,6 +17,7 @@
 package dataset
 
 import (
+	"math"
 	"math/rand"
 	"testing"
 	"time"
@@ -277,6 +278,48 @@ func TestMarshalDataSet(t *testing.T) {
 	}
 }
 
+func TestMarshalDataSetUint64(t *testing.T) {
+
+	sh := testSeriesHeader()
+	sh.FieldsList = []timeseries.FieldDefinition{{Name: "value", DataType: timeseries.Uint64}}
+	vals := []uint64{0, 1, 1 << 53, (1 << 53) + 1, math.MaxUint64}
+	pts := make(Points, len(vals))
+	for i, v := range vals {
+		pts[i] = Point{
+			Epoch:  epoch.Epoch(int64(i+1) * int64(timeseries.Second)),
+			Size:   16,
+			Values: []interface{}{v},
+		}
+	}
+	ds := &DataSet{
+		TimeRangeQuery: &timeseries.TimeRangeQuery{Step: time.Duration(timeseries.Second)},
+		Results:        []*Result{{SeriesList: []*Series{{sh, pts, pts.Size()}}}},
+	}
+
+	b, err := MarshalDataSet(ds, &timeseries.RequestOptions{}, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts, err := UnmarshalDataSet(b, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := ts.(*DataSet).Results[0].SeriesList[0].Points
+	if len(out) != len(vals) {
+		t.Fatalf("expected %d points got %d", len(vals), len(out))
+	}
+	for i, v := range vals {
+		u, ok := out[i].Values[0].(uint64)
+		if !ok {
+			t.Errorf("expected uint64 got %T", out[i].Values[0])
+			continue
+		}
+		if u != v {
+			t.Errorf("expected %d got %d", v, u)
+		}
+	}
+}
+
 func TestCroppedClone(t *testing.T) {
 
 	// an extent fully inside of time series's extent
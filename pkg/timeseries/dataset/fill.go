@@ -0,0 +1,120 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dataset
+
+import (
+	"sort"
+
+	"github.com/trickstercache/trickster/v2/pkg/timeseries"
+	"github.com/trickstercache/trickster/v2/pkg/timeseries/epoch"
+)
+
+var _ timeseries.Filler = (*DataSet)(nil)
+
+// Fill fills the gaps between the first and last points of each Series in the DataSet
+// with a point at each missing step, whose values are determined by the FillPolicy.
+// Timestamps before the first point or after the last point of a Series are not filled
+func (ds *DataSet) Fill(policy timeseries.FillPolicy) {
+	step := epoch.Epoch(ds.Step())
+	if policy == timeseries.FillPolicyNone || step <= 0 {
+		return
+	}
+	for _, r := range ds.Results {
+		if r == nil {
+			continue
+		}
+		for _, s := range r.SeriesList {
+			if s == nil || len(s.Points) < 2 {
+				continue
+			}
+			s.fill(policy, step)
+		}
+	}
+}
+
+func (s *Series) fill(policy timeseries.FillPolicy, step epoch.Epoch) {
+	if !sort.IsSorted(s.Points) {
+		sort.Sort(s.Points)
+	}
+	var filled Points
+	for i := 1; i < len(s.Points); i++ {
+		prev := s.Points[i-1]
+		if s.Points[i].Epoch-prev.Epoch <= step {
+			continue
+		}
+		if filled == nil {
+			filled = make(Points, 0, len(s.Points)*2)
+			filled = append(filled, s.Points[:i]...)
+		}
+		for e := prev.Epoch + step; e < s.Points[i].Epoch; e += step {
+			filled = append(filled, fillPoint(policy, prev, e))
+		}
+		filled = append(filled, s.Points[i])
+		// append the remainder of any points between this gap and the next
+		for i+1 < len(s.Points) && s.Points[i+1].Epoch-s.Points[i].Epoch <= step {
+			i++
+			filled = append(filled, s.Points[i])
+		}
+	}
+	if filled != nil {
+		s.Points = filled
+		s.PointSize = filled.Size()
+	}
+}
+
+// fillPoint returns a new point at the provided epoch to fill a gap following prev
+func fillPoint(policy timeseries.FillPolicy, prev Point, e epoch.Epoch) Point {
+	switch policy {
+	case timeseries.FillPolicyPrevious:
+		p := prev.Clone()
+		p.Epoch = e
+		return p
+	case timeseries.FillPolicyZero:
+		p := Point{Epoch: e, Size: 16, Values: make([]interface{}, len(prev.Values))}
+		for i, v := range prev.Values {
+			p.Values[i] = zeroValue(v)
+		}
+		return p
+	}
+	return Point{Epoch: e, Size: 16, Values: make([]interface{}, len(prev.Values))}
+}
+
+// zeroValue returns the zero value having the same type as v. Values stored as
+// strings are zeroed as "0"
+func zeroValue(v interface{}) interface{} {
+	switch v.(type) {
+	case string:
+		return "0"
+	case float64:
+		return float64(0)
+	case float32:
+		return float32(0)
+	case int64:
+		return int64(0)
+	case int:
+		return 0
+	case int16:
+		return int16(0)
+	case uint64:
+		return uint64(0)
+	case byte:
+		return byte(0)
+	case bool:
+		return false
+	}
+	return nil
+}
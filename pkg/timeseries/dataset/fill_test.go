@@ -0,0 +1,119 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dataset
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/trickstercache/trickster/v2/pkg/timeseries"
+	"github.com/trickstercache/trickster/v2/pkg/timeseries/epoch"
+)
+
+// testGapDataSet returns a DataSet merged from two extents, 5s-10s and 25s-30s,
+// leaving a gap at 15s and 20s
+func testGapDataSet() *DataSet {
+	ds := testDataSet()
+	ds2 := testDataSet()
+	ds2.ExtentList = timeseries.ExtentList{timeseries.Extent{Start: time.Unix(25, 0), End: time.Unix(30, 0)}}
+	ds2.Results[0].SeriesList[0].Points = Points{
+		Point{Epoch: epoch.Epoch(25 * timeseries.Second), Size: 27, Values: []interface{}{3, 11}},
+		Point{Epoch: epoch.Epoch(30 * timeseries.Second), Size: 27, Values: []interface{}{4, 12}},
+	}
+	ds.Merge(true, ds2)
+	return ds
+}
+
+func TestFill(t *testing.T) {
+
+	tests := []struct {
+		policy   timeseries.FillPolicy
+		expected [][]interface{}
+	}{
+		{
+			timeseries.FillPolicyNone,
+			[][]interface{}{{1, 37}, {1, 24}, {3, 11}, {4, 12}},
+		},
+		{
+			timeseries.FillPolicyNull,
+			[][]interface{}{{1, 37}, {1, 24}, {nil, nil}, {nil, nil}, {3, 11}, {4, 12}},
+		},
+		{
+			timeseries.FillPolicyPrevious,
+			[][]interface{}{{1, 37}, {1, 24}, {1, 24}, {1, 24}, {3, 11}, {4, 12}},
+		},
+		{
+			timeseries.FillPolicyZero,
+			[][]interface{}{{1, 37}, {1, 24}, {0, 0}, {0, 0}, {3, 11}, {4, 12}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.policy.String(), func(t *testing.T) {
+			ds := testGapDataSet()
+			ds.Fill(test.policy)
+			s := ds.Results[0].SeriesList[0]
+			if len(s.Points) != len(test.expected) {
+				t.Fatalf("expected %d got %d", len(test.expected), len(s.Points))
+			}
+			for i, p := range s.Points {
+				if !reflect.DeepEqual(p.Values, test.expected[i]) {
+					t.Errorf("point %d: expected %v got %v", i, test.expected[i], p.Values)
+				}
+				if i > 0 && p.Epoch <= s.Points[i-1].Epoch {
+					t.Errorf("point %d: epoch %d is not after %d", i, p.Epoch, s.Points[i-1].Epoch)
+				}
+			}
+			if s.PointSize != s.Points.Size() {
+				t.Errorf("expected %d got %d", s.Points.Size(), s.PointSize)
+			}
+		})
+	}
+}
+
+func TestFillMultipleGaps(t *testing.T) {
+	ds := testDataSet()
+	ds.Results[0].SeriesList[0].Points = Points{
+		Point{Epoch: epoch.Epoch(5 * timeseries.Second), Values: []interface{}{"1"}},
+		Point{Epoch: epoch.Epoch(15 * timeseries.Second), Values: []interface{}{"2"}},
+		Point{Epoch: epoch.Epoch(20 * timeseries.Second), Values: []interface{}{"3"}},
+		Point{Epoch: epoch.Epoch(30 * timeseries.Second), Values: []interface{}{"4"}},
+	}
+	ds.Fill(timeseries.FillPolicyZero)
+	expected := []interface{}{"1", "0", "2", "3", "0", "4"}
+	s := ds.Results[0].SeriesList[0]
+	if len(s.Points) != len(expected) {
+		t.Fatalf("expected %d got %d", len(expected), len(s.Points))
+	}
+	for i, p := range s.Points {
+		if p.Epoch != epoch.Epoch(int64(i+1)*5*timeseries.Second) {
+			t.Errorf("point %d: unexpected epoch %d", i, p.Epoch)
+		}
+		if p.Values[0] != expected[i] {
+			t.Errorf("point %d: expected %v got %v", i, expected[i], p.Values[0])
+		}
+	}
+
+	// no step, no fill
+	ds = testGapDataSet()
+	ds.TimeRangeQuery = nil
+	ds.Fill(timeseries.FillPolicyNull)
+	if n := len(ds.Results[0].SeriesList[0].Points); n != 4 {
+		t.Errorf("expected %d got %d", 4, n)
+	}
+}
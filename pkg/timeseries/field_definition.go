@@ -32,6 +32,7 @@ const (
 	Bool
 	Byte
 	Int16
+	Uint64
 )
 
 // FieldDataType is a byte representing the data type of a Field
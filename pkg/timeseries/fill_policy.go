@@ -0,0 +1,63 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package timeseries
+
+import "strconv"
+
+// FillPolicy enumerates the methodologies for filling gaps between the points of a series
+type FillPolicy int
+
+const (
+	// FillPolicyNone leaves gaps unfilled
+	FillPolicyNone = FillPolicy(iota)
+	// FillPolicyNull fills gaps with points having null values
+	FillPolicyNull
+	// FillPolicyPrevious fills gaps with copies of the last point preceding the gap
+	FillPolicyPrevious
+	// FillPolicyZero fills gaps with points having zero values
+	FillPolicyZero
+)
+
+// FillPolicyNames is a map of FillPolicies keyed by string name
+var FillPolicyNames = map[string]FillPolicy{
+	"none":     FillPolicyNone,
+	"null":     FillPolicyNull,
+	"previous": FillPolicyPrevious,
+	"zero":     FillPolicyZero,
+}
+
+// FillPolicyValues is a map of FillPolicies valued by string name
+var FillPolicyValues = make(map[FillPolicy]string)
+
+func init() {
+	for k, v := range FillPolicyNames {
+		FillPolicyValues[v] = k
+	}
+}
+
+func (p FillPolicy) String() string {
+	if v, ok := FillPolicyValues[p]; ok {
+		return v
+	}
+	return strconv.Itoa(int(p))
+}
+
+// Filler is implemented by Timeseries that can fill the gaps between the points of
+// their series, at the Timeseries's step, using the provided FillPolicy
+type Filler interface {
+	Fill(FillPolicy)
+}
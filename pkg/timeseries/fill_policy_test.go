@@ -0,0 +1,38 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package timeseries
+
+import "testing"
+
+func TestFillPolicyString(t *testing.T) {
+
+	p1 := FillPolicyNull
+	p2 := FillPolicyPrevious
+	var p3 FillPolicy = 13
+
+	if p1.String() != "null" {
+		t.Errorf("expected %s got %s", "null", p1.String())
+	}
+
+	if p2.String() != "previous" {
+		t.Errorf("expected %s got %s", "previous", p2.String())
+	}
+
+	if p3.String() != "13" {
+		t.Errorf("expected %s got %s", "13", p3.String())
+	}
+}
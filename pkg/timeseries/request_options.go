@@ -28,6 +28,9 @@ type RequestOptions struct {
 	// OutputFormat is a field usable by time series implementations to pass data between the parsed time range query
 	// and the data unmarshaler/marshaler to give indications about the content type of the serialized output
 	OutputFormat byte
+	// ChunkSize is a field usable by time series implementations to indicate the client requested the
+	// serialized output be chunked, with up to ChunkSize points per chunk. 0 disables chunking
+	ChunkSize int
 	// FastForwardDisable indicates whether the Time Range Query result should include fast forward data
 	FastForwardDisable bool
 	// BaseTimestampFieldName holds the name of the Base Timestamp Field (in case it is aliased with AS) to help
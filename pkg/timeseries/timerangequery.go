@@ -36,6 +36,9 @@ type TimeRangeQuery struct {
 	Extent Extent `msg:"ex"`
 	// Step indicates the amount of time in seconds between each datapoint in a TimeRangeQuery's resulting timeseries
 	Step time.Duration `msg:"-"`
+	// StepOffset shifts the Step boundaries away from the epoch, for queries whose time buckets
+	// are offset, such as InfluxQL's GROUP BY time(interval, offset)
+	StepOffset time.Duration `msg:"-"`
 	// TemplateURL is used by some Backend providers for templatization of url parameters containing timestamps
 	TemplateURL *url.URL `msg:"-"`
 	// IsOffset is true if the query uses a relative offset modifier
@@ -64,6 +67,7 @@ func (trq *TimeRangeQuery) Clone() *TimeRangeQuery {
 		Statement:           trq.Statement,
 		Step:                trq.Step,
 		StepNS:              trq.StepNS,
+		StepOffset:          trq.StepOffset,
 		Extent:              Extent{Start: trq.Extent.Start, End: trq.Extent.End},
 		IsOffset:            trq.IsOffset,
 		TimestampDefinition: trq.TimestampDefinition.Clone(),
@@ -96,11 +100,24 @@ func (trq *TimeRangeQuery) NormalizeExtent() {
 		if !trq.IsOffset && trq.Extent.End.After(time.Now()) {
 			trq.Extent.End = time.Now()
 		}
-		trq.Extent.Start = trq.Extent.Start.Truncate(trq.Step)
-		trq.Extent.End = trq.Extent.End.Truncate(trq.Step)
+		trq.Extent.Start = trq.AlignTime(trq.Extent.Start)
+		trq.Extent.End = trq.AlignTime(trq.Extent.End)
 	}
 }
 
+// AlignTime returns the result of rounding t down to the nearest Step boundary,
+// with the boundaries shifted by the StepOffset
+func (trq *TimeRangeQuery) AlignTime(t time.Time) time.Time {
+	if trq.Step <= 0 {
+		return t
+	}
+	offset := trq.StepOffset % trq.Step
+	if offset < 0 {
+		offset += trq.Step
+	}
+	return t.Add(-offset).Truncate(trq.Step).Add(offset)
+}
+
 func (trq *TimeRangeQuery) String() string {
 	return fmt.Sprintf(`{ "statement": "%s", "step": "%s", "extent": "%s", "tsd": "%s", "td": %s, "vd": %s }`,
 		strings.Replace(trq.Statement, `"`, `\"`, -1), trq.Step.String(),
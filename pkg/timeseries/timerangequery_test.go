@@ -72,6 +72,31 @@ func TestNormalizeExtent(t *testing.T) {
 	}
 }
 
+func TestAlignTime(t *testing.T) {
+
+	tests := []struct {
+		t, stepSecs, offsetSecs int64
+		expected                int64
+	}{
+		{103, 10, 0, 100},
+		{103, 10, 5, 95},
+		{107, 10, 5, 105},
+		{103, 10, -5, 95},
+		{103, 10, 25, 95},
+		{103, 0, 5, 103},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			trq := TimeRangeQuery{Step: time.Duration(test.stepSecs) * time.Second,
+				StepOffset: time.Duration(test.offsetSecs) * time.Second}
+			if v := trq.AlignTime(time.Unix(test.t, 0)).Unix(); v != test.expected {
+				t.Errorf("expected %d got %d", test.expected, v)
+			}
+		})
+	}
+}
+
 func TestClone(t *testing.T) {
 	u, _ := url.Parse("http://127.0.0.1/")
 	trq := &TimeRangeQuery{Statement: "1234", Extent: Extent{Start: time.Unix(5, 0),
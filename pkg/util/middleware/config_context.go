@@ -28,6 +28,7 @@ import (
 	"github.com/trickstercache/trickster/v2/pkg/proxy/context"
 	po "github.com/trickstercache/trickster/v2/pkg/proxy/paths/options"
 	"github.com/trickstercache/trickster/v2/pkg/proxy/request"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/servertiming"
 )
 
 func init() {
@@ -50,6 +51,9 @@ func WithResourcesContext(client backends.Backend, o *bo.Options,
 		} else {
 			resources = request.NewResources(o, p, c.Configuration(), c, client, t, l)
 		}
+		if o != nil && o.EmitServerTiming {
+			resources.ServerTiming = servertiming.New()
+		}
 		ctx := r.Context()
 		rsc, ok := context.Resources(ctx).(*request.Resources)
 		if !ok {
@@ -0,0 +1,117 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+)
+
+// InjectRequestHeaders evaluates the provided Backend and Path header injections against
+// the request and sets the resulting headers before passing it to next. Path injections
+// are applied last, so they take precedence over Backend injections for the same header.
+func InjectRequestHeaders(backendInjections, pathInjections headers.Injections,
+	next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backendInjections.Apply(r)
+		pathInjections.Apply(r)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// UpdateResponseHeaders applies the provided updates, in the format used by
+// headers.UpdateHeaders, to the response headers immediately before they are written
+// to the client. This ensures the updates are applied to every response, including
+// those served from cache, regardless of the headers that were cached with the object.
+func UpdateResponseHeaders(updates map[string]string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(&responseHeaderUpdater{ResponseWriter: w, updates: updates}, r)
+	})
+}
+
+type responseHeaderUpdater struct {
+	http.ResponseWriter
+	updates     map[string]string
+	wroteHeader bool
+}
+
+func (w *responseHeaderUpdater) WriteHeader(statusCode int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		headers.UpdateHeaders(w.ResponseWriter.Header(), w.updates)
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *responseHeaderUpdater) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher
+func (w *responseHeaderUpdater) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// RewriteClientCacheControl replaces the Cache-Control header of each non-error response
+// with the provided value immediately before it is written to the client, and removes
+// any Expires and Pragma headers that could contradict it. Since any caching of the
+// response has already occurred, this does not affect Trickster's own caching policy.
+// Error responses are written unchanged, so that downstream caches don't retain them.
+func RewriteClientCacheControl(value string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(&cacheControlRewriter{ResponseWriter: w, value: value}, r)
+	})
+}
+
+type cacheControlRewriter struct {
+	http.ResponseWriter
+	value       string
+	wroteHeader bool
+}
+
+func (w *cacheControlRewriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if statusCode < http.StatusBadRequest {
+			h := w.ResponseWriter.Header()
+			h.Set(headers.NameCacheControl, w.value)
+			h.Del(headers.NameExpires)
+			h.Del(headers.NamePragma)
+		}
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *cacheControlRewriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher
+func (w *cacheControlRewriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
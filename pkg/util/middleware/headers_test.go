@@ -0,0 +1,99 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestUpdateResponseHeaders(t *testing.T) {
+	// the next handler stands in for a response served from cache, whose headers
+	// were stored before the updates were configured
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "https://origin.example.com")
+		w.Header().Set("Vary", "Accept-Encoding")
+		w.Header().Set("X-Debug-Id", "1234")
+		w.Write([]byte("trickster"))
+	})
+	updates := map[string]string{
+		"Access-Control-Allow-Origin": "*",
+		"+Vary":                       "Origin",
+		"-X-Debug-Id":                 "",
+	}
+	h := UpdateResponseHeaders(updates, next)
+
+	// the second request checks that updates are not compounded
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://0/", nil))
+		resp := w.Result()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("expected %d got %d", http.StatusOK, resp.StatusCode)
+		}
+		if v := resp.Header.Get("Access-Control-Allow-Origin"); v != "*" {
+			t.Errorf("expected %s got %s", "*", v)
+		}
+		if v := resp.Header.Values("Vary"); !reflect.DeepEqual(v,
+			[]string{"Accept-Encoding", "Origin"}) {
+			t.Errorf("expected %v got %v", []string{"Accept-Encoding", "Origin"}, v)
+		}
+		if _, ok := resp.Header["X-Debug-Id"]; ok {
+			t.Error("expected X-Debug-Id to be removed")
+		}
+		if w.Body.String() != "trickster" {
+			t.Errorf("expected %s got %s", "trickster", w.Body.String())
+		}
+	}
+}
+
+func TestRewriteClientCacheControl(t *testing.T) {
+
+	tests := []struct {
+		code     int
+		expected string
+	}{
+		{http.StatusOK, "public, max-age=60"},
+		{http.StatusNotModified, "public, max-age=60"},
+		{http.StatusBadGateway, "no-store"},
+	}
+
+	for i, test := range tests {
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", "no-store")
+			w.Header().Set("Expires", "0")
+			w.Header().Set("Pragma", "no-cache")
+			w.WriteHeader(test.code)
+		})
+		w := httptest.NewRecorder()
+		RewriteClientCacheControl("public, max-age=60", next).ServeHTTP(w,
+			httptest.NewRequest(http.MethodGet, "http://0/", nil))
+		resp := w.Result()
+		if v := resp.Header.Get("Cache-Control"); v != test.expected {
+			t.Errorf("test %d expected %s got %s", i, test.expected, v)
+		}
+		rewritten := test.code < http.StatusBadRequest
+		if _, ok := resp.Header["Expires"]; ok == rewritten {
+			t.Errorf("test %d expected Expires present to be %t", i, !rewritten)
+		}
+		if _, ok := resp.Header["Pragma"]; ok == rewritten {
+			t.Errorf("test %d expected Pragma present to be %t", i, !rewritten)
+		}
+	}
+}
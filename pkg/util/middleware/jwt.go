@@ -0,0 +1,55 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package middleware
+
+import (
+	"net/http"
+
+	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/jwt"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/request"
+)
+
+// CacheKeyClaim verifies the request's bearer token and stores its claim in the
+// request Resources, for inclusion in the cache key. A request with a missing or
+// invalid token is either rejected with 401 Unauthorized, or passed to next to be
+// proxied without using the cache, per the Verifier. It must be applied after the
+// Resources are added to the request's context
+func CacheKeyClaim(v *jwt.Verifier, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rsc := request.GetResources(r)
+		c, err := v.Claim(r)
+		if err != nil {
+			if rsc != nil {
+				tl.Debug(rsc.Logger, "cache key claim unavailable",
+					tl.Pairs{"path": r.URL.Path, "detail": err.Error()})
+			}
+			if v.RejectsInvalid() {
+				w.Header().Set(headers.NameWWWAuthenticate, `Bearer error="invalid_token"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if rsc != nil {
+				rsc.ProxyOnly = true
+			}
+		} else if rsc != nil {
+			rsc.CacheKeyClaim = c
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -69,6 +69,12 @@ func (w *responseObserver) WriteHeader(statusCode int) {
 	}
 }
 
+// Unwrap returns the underlying ResponseWriter, so that http.ResponseController can
+// reach its optional interfaces (e.g., http.Hijacker for protocol upgrades)
+func (w *responseObserver) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 func (w *responseObserver) Write(b []byte) (int, error) {
 	bytesWritten, err := w.ResponseWriter.Write(b)
 
@@ -0,0 +1,59 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package middleware
+
+import (
+	"net/http"
+
+	tl "github.com/trickstercache/trickster/v2/pkg/observability/logging"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/request"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/shadow"
+)
+
+// Shadow mirrors a sample of requests to the Mirror's shadow origin, and compares
+// the shadow response to the response from next once both have completed, logging
+// any mismatch. The client only ever receives the response from next
+func Shadow(m *shadow.Mirror, logger interface{}, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !m.Sample() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ch := m.Send(r, request.GetBody(r))
+		rec := shadow.NewRecorder(w)
+		next.ServeHTTP(rec, r)
+		primary := rec.Result()
+		path := r.URL.Path
+		go func() {
+			sr := <-ch
+			switch m.Compare(primary, sr) {
+			case shadow.OutcomeError:
+				tl.Debug(logger, "shadow request failed",
+					tl.Pairs{"backendName": m.Name(), "path": path, "detail": sr.Err.Error()})
+			case shadow.OutcomeMismatch:
+				tl.Info(logger, "shadow response mismatch", tl.Pairs{
+					"backendName":      m.Name(),
+					"path":             path,
+					"primaryStatus":    primary.StatusCode,
+					"shadowStatus":     sr.StatusCode,
+					"primarySizeBytes": primary.Size,
+					"shadowSizeBytes":  sr.Size,
+				})
+			}
+		}()
+	})
+}
@@ -0,0 +1,126 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ClientResponseTimeout responds with 503 Service Unavailable to any request whose
+// response is not complete within d. The request's context carries the deadline, and
+// the response is passed through to the client as it is written, so that streamed and
+// progressively collapsed responses are flushed as they arrive. A response already begun
+// when the deadline passes is ended at that point. Origin requests are made independently
+// of the client's request, and are bounded only by the backend's origin timeout, so a slow
+// origin response abandoned by the client is still cached for subsequent requests
+func ClientResponseTimeout(d time.Duration, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+		tw := &timeoutWriter{ResponseWriter: w, header: make(http.Header)}
+		done := make(chan struct{})
+		panicked := make(chan interface{}, 1)
+		go func() {
+			defer func() {
+				// handler panics are propagated to the server's goroutine
+				if p := recover(); p != nil {
+					panicked <- p
+				}
+			}()
+			next.ServeHTTP(tw, r.WithContext(ctx))
+			close(done)
+		}()
+		select {
+		case p := <-panicked:
+			panic(p)
+		case <-done:
+		case <-ctx.Done():
+			tw.timeout()
+		}
+	})
+}
+
+// timeoutWriter passes a response through to the client until its deadline passes,
+// after which any further writes are discarded. Headers are buffered until they are
+// written, so that a timeout response never races with the handler's header updates
+type timeoutWriter struct {
+	http.ResponseWriter
+	header      http.Header
+	mtx         sync.Mutex
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(statusCode int) {
+	tw.mtx.Lock()
+	defer tw.mtx.Unlock()
+	tw.writeHeader(statusCode)
+}
+
+func (tw *timeoutWriter) writeHeader(statusCode int) {
+	if tw.timedOut || tw.wroteHeader {
+		return
+	}
+	tw.wroteHeader = true
+	h := tw.ResponseWriter.Header()
+	for k, v := range tw.header {
+		h[k] = v
+	}
+	tw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mtx.Lock()
+	defer tw.mtx.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	tw.writeHeader(http.StatusOK)
+	return tw.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher
+func (tw *timeoutWriter) Flush() {
+	tw.mtx.Lock()
+	defer tw.mtx.Unlock()
+	if tw.timedOut {
+		return
+	}
+	tw.writeHeader(http.StatusOK)
+	if f, ok := tw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// timeout discards any further writes, and responds with 503 Service Unavailable
+// if the response has not yet begun
+func (tw *timeoutWriter) timeout() {
+	tw.mtx.Lock()
+	defer tw.mtx.Unlock()
+	if !tw.wroteHeader {
+		tw.ResponseWriter.WriteHeader(http.StatusServiceUnavailable)
+		tw.wroteHeader = true
+	}
+	tw.timedOut = true
+}
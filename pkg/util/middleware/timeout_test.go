@@ -0,0 +1,73 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientResponseTimeout(t *testing.T) {
+
+	// responses within the deadline are passed through and flushed as they are written
+	flushed := make(chan bool, 1)
+	h := ClientResponseTimeout(time.Second, http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+		if _, ok := r.Context().Deadline(); !ok {
+			t.Error("expected request context deadline")
+		}
+		w.Header().Set("X-Test", "1")
+		w.Write([]byte("chunk"))
+		f, ok := w.(http.Flusher)
+		if ok {
+			f.Flush()
+		}
+		flushed <- ok
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !<-flushed {
+		t.Error("expected http.Flusher")
+	}
+	if w.Code != http.StatusOK || w.Body.String() != "chunk" || !w.Flushed {
+		t.Errorf("unexpected response %d %s %t", w.Code, w.Body.String(), w.Flushed)
+	}
+	if v := w.Header().Get("X-Test"); v != "1" {
+		t.Errorf("expected %s got %s", "1", v)
+	}
+
+	// responses not begun within the deadline are a 503, and later writes are discarded
+	release := make(chan bool)
+	h = ClientResponseTimeout(10*time.Millisecond, http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+		<-r.Context().Done()
+		<-release
+		if _, err := w.Write([]byte("late")); err != http.ErrHandlerTimeout {
+			t.Errorf("expected %v got %v", http.ErrHandlerTimeout, err)
+		}
+		release <- true
+	}))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	release <- true
+	<-release
+	if w.Code != http.StatusServiceUnavailable || w.Body.Len() != 0 {
+		t.Errorf("unexpected response %d %s", w.Code, w.Body.String())
+	}
+}
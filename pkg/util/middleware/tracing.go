@@ -43,7 +43,9 @@ func Trace(tr *tracing.Tracer, next http.Handler) http.Handler {
 					[]attribute.KeyValue{
 						attribute.String("backend.name", rsc.BackendOptions.Name),
 						attribute.String("backend.provider", rsc.BackendOptions.Provider),
+						attribute.String("backend.origin", rsc.BackendOptions.OriginURL),
 						attribute.String("router.path", rsc.PathConfig.Path),
+						attribute.String("router.handler", rsc.PathConfig.HandlerName),
 						attribute.String("cache.name", rsc.CacheConfig.Name),
 						attribute.String("cache.provider", rsc.CacheConfig.Provider),
 					}...,
@@ -0,0 +1,70 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package middleware
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+
+	bo "github.com/trickstercache/trickster/v2/pkg/backends/options"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/headers"
+	"github.com/trickstercache/trickster/v2/pkg/proxy/urls"
+)
+
+// WebSocketUpgrade proxies WebSocket upgrade requests directly to the Backend's origin,
+// then relays the upgraded connection in both directions until either side closes it.
+// These requests bypass next, and so are never cached. All other requests are passed
+// to next.
+func WebSocketUpgrade(o *bo.Options, next http.Handler) http.Handler {
+	base := &url.URL{Scheme: o.Scheme, Host: o.Host, Path: o.PathPrefix}
+	rp := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.Out.URL = urls.BuildUpstreamURL(pr.In, base)
+			pr.Out.URL.Path = o.RewriteUpstreamPath(pr.Out.URL.Path)
+			pr.Out.Host = o.UpstreamHostHeader
+			if o.UpstreamUserAgent != "" {
+				pr.Out.Header.Set(headers.NameUserAgent, o.UpstreamUserAgent)
+			}
+		},
+	}
+	if o.HTTPClient != nil {
+		rp.Transport = o.HTTPClient.Transport
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isWebSocketUpgrade(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rp.ServeHTTP(w, r)
+	})
+}
+
+func isWebSocketUpgrade(r *http.Request) bool {
+	if !strings.EqualFold(r.Header.Get(headers.NameUpgrade), "websocket") {
+		return false
+	}
+	for _, v := range r.Header.Values(headers.NameConnection) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
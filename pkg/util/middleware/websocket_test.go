@@ -0,0 +1,90 @@
+/*
+ * Copyright 2018 The Trickster Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	bo "github.com/trickstercache/trickster/v2/pkg/backends/options"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestWebSocketUpgrade(t *testing.T) {
+
+	// the origin echoes websocket messages, and answers plain requests directly
+	mux := http.NewServeMux()
+	mux.Handle("/base/ws", websocket.Handler(func(ws *websocket.Conn) {
+		io.Copy(ws, ws)
+	}))
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("origin"))
+	})
+	origin := httptest.NewServer(mux)
+	defer origin.Close()
+	ou, _ := url.Parse(origin.URL)
+
+	o := bo.New()
+	o.Scheme = ou.Scheme
+	o.Host = ou.Host
+	o.PathPrefix = "/base"
+
+	// next stands in for the backend's caching path handler
+	var nextCalls int
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nextCalls++
+		w.Write([]byte("next"))
+	})
+	// the metrics decorator wraps the ResponseWriter, and must not prevent the hijack
+	ts := httptest.NewServer(Decorate("test", "rpc", "/", WebSocketUpgrade(o, next)))
+	defer ts.Close()
+
+	ws, err := websocket.Dial("ws"+ts.URL[len("http"):]+"/ws", "", ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	if _, err = ws.Write([]byte("trickster")); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 32)
+	n, err := ws.Read(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b[:n]) != "trickster" {
+		t.Errorf("expected %s got %s", "trickster", string(b[:n]))
+	}
+	if nextCalls != 0 {
+		t.Errorf("expected upgrade to bypass next, got %d calls", nextCalls)
+	}
+
+	// a non-upgrade request to the same path is passed to next
+	resp, err := http.Get(ts.URL + "/ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "next" || nextCalls != 1 {
+		t.Errorf("expected %s from next got %s", "next", string(body))
+	}
+}
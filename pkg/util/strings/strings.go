@@ -89,6 +89,20 @@ func Unique(in []string) []string {
 	return out
 }
 
+// Duplicates returns the values that appear more than once in the list,
+// in the order of their second appearance
+func Duplicates(in []string) []string {
+	var out []string
+	m := make(map[string]int)
+	for _, v := range in {
+		m[v]++
+		if m[v] == 2 {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
 // ErrKeyNotInMap represents an error for key not found in map
 var ErrKeyNotInMap = errors.New("key not found in map")
 
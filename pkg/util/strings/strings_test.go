@@ -123,6 +123,20 @@ func TestUnique(t *testing.T) {
 	}
 }
 
+func TestDuplicates(t *testing.T) {
+	initial := []string{"test", "test1", "test", "test2", "test2", "test", "test3"}
+	expected := "test,test2"
+	after := strings.Join(Duplicates(initial), ",")
+	if expected != after {
+		t.Errorf("expected %s got %s", expected, after)
+	}
+
+	empty := Duplicates([]string{"test", "test1"})
+	if len(empty) != 0 {
+		t.Error("expected empty list")
+	}
+}
+
 func TestGetInt(t *testing.T) {
 
 	m := StringMap{"trickster": "proxy", "test": "1"}